@@ -1,9 +1,14 @@
 package main
 
 import (
+	"datatom/internal/rest"
 	cfg "datatom/pkg/config"
+	"fmt"
+	"time"
 )
 
+const configDateLayout = "2006-01-02"
+
 type config struct {
 	ConfigFilePath string `conf:"flag:config_file_path,short:c,env:CONFIG_FILE_PATH"`
 
@@ -12,6 +17,27 @@ type config struct {
 
 	RESTPort       uint `conf:"flag:rest_port,short:r,env:REST_PORT" toml:"rest_port" zero:"no"`
 	RESTTimeoutSec uint `conf:"flag:rest_timeout,short:r,env:REST_TIMEOUT" toml:"rest_timeout"`
+	// Routes at the root are deprecated aliases of /v1 ones, dates are like 2006-01-02
+	RESTLegacyDeprecatedAt string `conf:"flag:rest_legacy_deprecated_at,env:REST_LEGACY_DEPRECATED_AT" toml:"rest_legacy_deprecated_at"`
+	RESTLegacySunset       string `conf:"flag:rest_legacy_sunset,env:REST_LEGACY_SUNSET" toml:"rest_legacy_sunset"`
+
+	GRPCPort uint `conf:"flag:grpc_port,env:GRPC_PORT" toml:"grpc_port" zero:"no"`
+	// Metrics are served in the Prometheus format at /metrics of the port
+	MetricsPort uint `conf:"flag:metrics_port,env:METRICS_PORT" toml:"metrics_port" zero:"no"`
+	// Spans are exported by otlp or stdout exporters, they are not exported if it is none or not set
+	TracingExporter string `conf:"flag:tracing_exporter,env:TRACING_EXPORTER" toml:"tracing_exporter"`
+	// host:port of the OTLP HTTP receiver, OTEL_EXPORTER_OTLP_* variables are used if it is not set
+	TracingOTLPEndpoint string `conf:"flag:tracing_otlp_endpoint,env:TRACING_OTLP_ENDPOINT" toml:"tracing_otlp_endpoint"`
+	TracingOTLPInsecure bool   `conf:"flag:tracing_otlp_insecure,env:TRACING_OTLP_INSECURE" toml:"tracing_otlp_insecure"`
+
+	// API keys, JWT and roles, requests are not authenticated if the file is not set
+	AuthConfigFilePath string `conf:"flag:auth_config_file,env:AUTH_CONFIG_FILE" toml:"auth_config_file"`
+	// Keys of encrypted properties, values of them can not be set or decrypted if the file is not set
+	KeyringFilePath string `conf:"flag:keyring_file,env:KEYRING_FILE" toml:"keyring_file"`
+
+	IdempotencyKeyTTLSec uint `conf:"flag:idempotency_key_ttl,env:IDEMPOTENCY_KEY_TTL" toml:"idempotency_key_ttl"`
+	// Changes are kept in the log for the retention and until all feed consumers have read them
+	ChangeLogRetentionHours uint `conf:"flag:change_log_retention,env:CHANGE_LOG_RETENTION" toml:"change_log_retention"`
 
 	PostgresAddress  string `conf:"flag:postgres_address,env:POSTGRES_ADDRESS" toml:"postgres_address" zero:"no"`
 	PostgresPort     uint   `conf:"flag:postgres_port,env:POSTGRES_PORT" toml:"postgres_port" zero:"no"`
@@ -29,6 +55,8 @@ type config struct {
 	RMQVHost        string `conf:"flag:rmq_vhost,env:RMQ_VHOST" toml:"rmq_vhost" zero:"no"`
 	RMQConsumeQueue string `conf:"flag:rmq_consume_queue,env:RMQ_CONSUME_QUEUE" toml:"rmq_consume_queue" zero:"no"`
 	RMQDLE          string `conf:"flag:rmq_dle,env:RMQ_DLE" toml:"rmq_dle" zero:"no"`
+	// Values of encrypted properties are sent masked or as envelopes if it is ciphertext
+	RMQEncryptedValues string `conf:"flag:rmq_encrypted_values,env:RMQ_ENCRYPTED_VALUES" toml:"rmq_encrypted_values"`
 
 	DWExchange   string `conf:"flag:dw_exchange,env:DW_EXCHANGE" toml:"dw_exchange" zero:"no"`
 	DWRoutingKey string `conf:"flag:dw_routing_key,env:DW_ROUTING_KEY" toml:"dw_routing_key" zero:"no"`
@@ -42,5 +70,28 @@ func parse(args []string, c *config) error {
 	if c.RESTPort == 0 {
 		c.RESTPort = 8080
 	}
+	if c.GRPCPort == 0 {
+		c.GRPCPort = 9090
+	}
+	if c.MetricsPort == 0 {
+		c.MetricsPort = 9100
+	}
 	return cfg.Configure(args, c, cfg.WithConfigFilePathField("ConfigFilePath"))
 }
+
+// legacyRESTDeprecation of routes at the root, dates which are not set are zero.
+func (c *config) legacyRESTDeprecation() (*rest.Deprecation, error) {
+	var out rest.Deprecation
+	var err error
+	if c.RESTLegacyDeprecatedAt != "" {
+		if out.Since, err = time.Parse(configDateLayout, c.RESTLegacyDeprecatedAt); err != nil {
+			return nil, fmt.Errorf("parse rest_legacy_deprecated_at error: %s", err)
+		}
+	}
+	if c.RESTLegacySunset != "" {
+		if out.Sunset, err = time.Parse(configDateLayout, c.RESTLegacySunset); err != nil {
+			return nil, fmt.Errorf("parse rest_legacy_sunset error: %s", err)
+		}
+	}
+	return &out, nil
+}
@@ -10,14 +10,20 @@ import (
 	"datatom/internal/adapter/pg"
 	"datatom/internal/adapter/rmq"
 	"datatom/internal/api"
+	"datatom/internal/auth"
+	"datatom/internal/domain"
 	"datatom/internal/grpc"
+	grpcserver "datatom/internal/grpc/server"
 	"datatom/internal/handlers"
+	"datatom/internal/keyring"
 	"datatom/internal/migrations"
 	"datatom/internal/rest"
 	"datatom/internal/routines"
 	pkgpg "datatom/pkg/db/pg"
 	pkglog "datatom/pkg/log"
 	pkgrmq "datatom/pkg/message_broker/rmq"
+	pkgmetrics "datatom/pkg/metrics"
+	pkgtracing "datatom/pkg/tracing"
 	"github.com/go-co-op/gocron"
 	"golang.org/x/sync/errgroup"
 )
@@ -37,6 +43,29 @@ func main() {
 	info := internal.NewInfo(c.Title, c.Description)
 	info.SetVersion(Version)
 
+	tracingExporter, err := pkgtracing.ExporterFromCode(c.TracingExporter)
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	shutdownTracing, err := pkgtracing.Init(context.Background(), pkgtracing.Config{
+		Exporter:       tracingExporter,
+		OTLPEndpoint:   c.TracingOTLPEndpoint,
+		OTLPInsecure:   c.TracingOTLPInsecure,
+		ServiceName:    internal.ServiceName,
+		ServiceVersion: Version,
+	})
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			l.Errorf("tracing shutdown error: %s", err)
+		}
+	}()
+	l.Infof("tracing configured, spans are exported by %s exporter", tracingExporter)
+
 	dbCC, err := pkgpg.NewConnConfig(pkgpg.Config{
 		Address:      c.PostgresAddress,
 		Port:         c.PostgresPort,
@@ -56,6 +85,7 @@ func main() {
 		User:         c.PostgresUser,
 		Password:     c.PostgresPassword,
 		DatabaseName: c.PostgresDBName,
+		TraceChanges: tracingExporter != pkgtracing.ExporterNone,
 	})
 	if err != nil {
 		l.Fatal(err.Error())
@@ -73,6 +103,9 @@ func main() {
 		l.Fatal(err.Error())
 	}
 	defer repo.Close()
+	if err := pkgmetrics.Register(pkgpg.NewPoolCollector(repo.Pool)); err != nil {
+		l.Fatal(err.Error())
+	}
 	l.Info("repository configured")
 
 	amqConn, err := pkgrmq.NewConnection(pkgrmq.ConnectionConfig{
@@ -100,11 +133,34 @@ func main() {
 	defer publisher.Close()
 	l.Infoln("RMQ publisher configured")
 
+	encryptedValues, err := rmq.EncryptedValuesModeFromCode(c.RMQEncryptedValues)
+	if err != nil {
+		l.Fatal(err.Error())
+	}
 	broker, err := rmq.NewBroker(rmq.Config{
-		Publisher: publisher,
-		Logger:    l,
+		Publisher:       publisher,
+		Logger:          l,
+		EncryptedValues: encryptedValues,
 	})
 
+	var cipher domain.ValueCipher
+	var maskKey []byte
+	if c.KeyringFilePath != "" {
+		kc, err := keyring.LoadConfig(c.KeyringFilePath)
+		if err != nil {
+			l.Fatal(err.Error())
+		}
+		kr, err := keyring.New(*kc)
+		if err != nil {
+			l.Fatal(err.Error())
+		}
+		cipher = kr
+		maskKey = kr.MaskKey()
+		l.Info("keyring configured")
+	} else {
+		l.Warn("keyring file is not set, values of encrypted properties can not be set or decrypted and hash masks hide values")
+	}
+
 	refTypeManager, err := api.NewRefTypeManager(api.RefTypeConfig{
 		Repository: repo,
 		Broker:     broker,
@@ -121,9 +177,22 @@ func main() {
 	}
 	l.Info("database manager configured")
 
+	maskManager, err := api.NewMaskManager(api.MaskConfig{
+		PropertyRepository: repo,
+		AuditRepository:    repo,
+		HashKey:            maskKey,
+		Timeout:            time.Second,
+	})
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	l.Info("mask manager configured")
+
 	recordManager, err := api.NewRecordManager(api.RecordConfig{
 		Repository: repo,
 		Broker:     broker,
+		Cipher:     cipher,
+		Masker:     maskManager,
 		Timeout:    time.Second,
 	})
 	if err != nil {
@@ -142,9 +211,12 @@ func main() {
 	l.Info("properties manager configured")
 
 	valueManager, err := api.NewValueManager(api.ValueConfig{
-		Repository: repo,
-		Broker:     broker,
-		Timeout:    time.Second,
+		Repository:       repo,
+		RecordRepository: repo,
+		Broker:           broker,
+		Cipher:           cipher,
+		Masker:           maskManager,
+		Timeout:          time.Second,
 	})
 	if err != nil {
 		l.Fatal(err.Error())
@@ -169,12 +241,117 @@ func main() {
 	}
 	l.Info("stored configs manager configured")
 
+	importManager, err := api.NewImportManager(api.ImportConfig{
+		DBManager:        dbManager,
+		RecordRepository: repo,
+		ValueRepository:  repo,
+		Cipher:           cipher,
+		Timeout:          time.Second,
+	})
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	l.Info("import manager configured")
+
+	dumpManager, err := api.NewDumpManager(api.DumpConfig{
+		Repository:       repo,
+		RecordRepository: repo,
+		Timeout:          time.Hour,
+	})
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	l.Info("dump manager configured")
+
+	tableManager, err := api.NewTableManager(api.TableConfig{
+		Repository: repo,
+		Cipher:     cipher,
+		Masker:     maskManager,
+		Timeout:    time.Minute * 10,
+	})
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	l.Info("table manager configured")
+
+	idempotencyManager, err := api.NewIdempotencyManager(api.IdempotencyConfig{
+		Repository: repo,
+		TTL:        time.Second * time.Duration(c.IdempotencyKeyTTLSec),
+		Timeout:    time.Second,
+	})
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	l.Info("idempotency manager configured")
+
+	changeLogManager, err := api.NewChangeLogManager(api.ChangeLogConfig{
+		Repository: repo,
+		Retention:  time.Hour * time.Duration(c.ChangeLogRetentionHours),
+	})
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	l.Info("change log manager configured")
+
+	webhookManager, err := api.NewWebhookManager(api.WebhookConfig{
+		Repository:       repo,
+		ChangeLogManager: changeLogManager,
+	})
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	l.Info("webhook manager configured")
+
+	authConfig := api.AuthConfig{
+		RecordManager:   recordManager,
+		PropertyManager: propertyManager,
+	}
+	if c.AuthConfigFilePath != "" {
+		ac, err := auth.LoadConfig(c.AuthConfigFilePath)
+		if err != nil {
+			l.Fatal(err.Error())
+		}
+		if authConfig.Authenticators, err = ac.Authenticators(); err != nil {
+			l.Fatal(err.Error())
+		}
+		if authConfig.Roles, err = ac.DomainRoles(); err != nil {
+			l.Fatal(err.Error())
+		}
+	}
+	authManager, err := api.NewAuthManager(authConfig)
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	if authManager.Enabled() {
+		l.Info("auth manager configured")
+	} else {
+		l.Warn("auth config file is not set, requests are not authenticated")
+	}
+
+	batchManager, err := api.NewBatchManager(api.BatchConfig{
+		DBManager:          dbManager,
+		RecordRepository:   repo,
+		PropertyRepository: repo,
+		ValueRepository:    repo,
+		AuthManager:        authManager,
+		Cipher:             cipher,
+		Timeout:            time.Second,
+	})
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	l.Info("batch manager configured")
+
 	dwGRPCConn := grpc.NewConnection(grpc.Config{
 		Logger:  l,
 		Address: c.DatawayGRPCAddress,
 		Port:    c.DatawayGRPCPort,
 	})
 
+	legacyDeprecation, err := c.legacyRESTDeprecation()
+	if err != nil {
+		l.Fatal(err.Error())
+	}
 	restServer, err := rest.NewServer(rest.Config{
 		Logger:  l,
 		Port:    c.RESTPort,
@@ -187,14 +364,40 @@ func main() {
 		PropertyManager:      propertyManager,
 		ValueManager:         valueManager,
 		StoredConfigsManager: storedConfigsManager,
+		BatchManager:         batchManager,
+		ImportManager:        importManager,
+		DumpManager:          dumpManager,
+		TableManager:         tableManager,
+		IdempotencyManager:   idempotencyManager,
+		ChangeLogManager:     changeLogManager,
+		WebhookManager:       webhookManager,
+		AuthManager:          authManager,
+		MaskManager:          maskManager,
 
 		DatawayGRPCConnection: dwGRPCConn,
+
+		Deprecations: map[string]*rest.Deprecation{"": legacyDeprecation},
+	})
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+
+	grpcServer, err := grpcserver.NewServer(grpcserver.Config{
+		Logger: l,
+		Port:   c.GRPCPort,
+
+		RefTypeManager:  refTypeManager,
+		RecordManager:   recordManager,
+		PropertyManager: propertyManager,
+		ValueManager:    valueManager,
+		TableManager:    tableManager,
+		AuthManager:     authManager,
 	})
 	if err != nil {
 		l.Fatal(err.Error())
 	}
 
-	g, _ := errgroup.WithContext(context.Background())
+	g, gCtx := errgroup.WithContext(context.Background())
 
 	g.Go(func() error {
 		err := restServer.Serve()
@@ -203,6 +406,41 @@ func main() {
 	})
 	l.Infof("REST server listens at port: %d", c.RESTPort)
 
+	g.Go(func() error {
+		err := grpcServer.Serve()
+		l.Errorln("gRPC server error:", err.Error())
+		return err
+	})
+	l.Infof("gRPC server listens at port: %d", c.GRPCPort)
+
+	metricsServer := pkgmetrics.NewServer(pkgmetrics.Config{Port: c.MetricsPort})
+	g.Go(func() error {
+		err := metricsServer.Serve()
+		l.Errorln("metrics server error:", err.Error())
+		return err
+	})
+	l.Infof("metrics server listens at port: %d", c.MetricsPort)
+
+	g.Go(func() error {
+		// Streams of changes poll the log while listening is restarted
+		for {
+			err := changeLogManager.Listen(gCtx)
+			if gCtx.Err() != nil {
+				return nil
+			}
+			l.Errorf("listen changes error: %s", err)
+			time.Sleep(time.Second)
+		}
+	})
+
+	g.Go(func() error {
+		return routines.DeliverWebhooks(gCtx, routines.DeliverWebhooksConfig{
+			Logger:           l,
+			WebhookManager:   webhookManager,
+			ChangeLogManager: changeLogManager,
+		})
+	})
+
 	g.Go(func() error {
 		if amqConn == nil {
 			return nil
@@ -218,6 +456,7 @@ func main() {
 				ValueManager:    valueManager,
 				PropertyManager: propertyManager,
 				RecordManager:   recordManager,
+				AuthManager:     authManager,
 			}),
 		})
 		if err := consumer.Consume(); err != nil {
@@ -245,6 +484,24 @@ func main() {
 			l.Fatalf("add routine job error: %s", err)
 		}
 	}
+	if _, err := s.Every(1).Hour().SingletonMode().Do(routines.NewPurgeIdempotencyKeysRoutine(routines.PurgeIdempotencyKeysConfig{
+		Logger:             l,
+		IdempotencyManager: idempotencyManager,
+	})); err != nil {
+		l.Fatalf("add routine job error: %s", err)
+	}
+	if _, err := s.Every(1).Hour().SingletonMode().Do(routines.NewPurgeChangeLogRoutine(routines.PurgeChangeLogConfig{
+		Logger:           l,
+		ChangeLogManager: changeLogManager,
+	})); err != nil {
+		l.Fatalf("add routine job error: %s", err)
+	}
+	if _, err := s.Every(1).Hour().SingletonMode().Do(routines.NewPurgeWebhookDeliveriesRoutine(routines.PurgeWebhookDeliveriesConfig{
+		Logger:         l,
+		WebhookManager: webhookManager,
+	})); err != nil {
+		l.Fatalf("add routine job error: %s", err)
+	}
 	s.StartAsync()
 	l.Infof("routines are running")
 
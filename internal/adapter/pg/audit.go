@@ -0,0 +1,59 @@
+package pg
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"datatom/pkg/db/pg"
+	"encoding/json"
+	"fmt"
+)
+
+func (r *Repository) RegisterUnmaskedReads(ctx context.Context, reads []UnmaskedRead) error {
+	if len(reads) == 0 {
+		return nil
+	}
+	schemas := make([]UnmaskedReadSchema, 0, len(reads))
+	for _, read := range reads {
+		schemas = append(schemas, unmaskedReadToSchema(read))
+	}
+	readsJSON, err := json.Marshal(schemas)
+	if err != nil {
+		return fmt.Errorf("unmasked reads marshal error: %s", err)
+	}
+	query := `SELECT register_unmasked_reads($1);`
+	if _, err := r.Exec(ctx, query, string(readsJSON)); err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return nil
+}
+
+func (r *Repository) GetUnmaskedReads(ctx context.Context, req UnmaskedReadsRequest) ([]UnmaskedRead, error) {
+	args := []any{
+		req.AfterID,
+		pg.NullUUID(req.PropertyID),
+		pg.NullString(req.Subject),
+		req.Limit,
+	}
+	query := `SELECT * FROM get_unmasked_reads($1, $2, $3, $4);`
+	rows, err := r.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	out := make([]UnmaskedRead, 0, req.Limit)
+	for rows.Next() {
+		var readJSON []byte
+		if err := rows.Scan(&readJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema UnmaskedReadSchema
+		if err := json.Unmarshal(readJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, readJSON)
+		}
+		out = append(out, schema.UnmaskedRead())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
@@ -0,0 +1,37 @@
+package pg
+
+import (
+	"datatom/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UnmaskedReadSchema struct {
+	ID         int64     `json:"id,omitempty"`
+	Subject    string    `json:"subject"`
+	Roles      []string  `json:"roles"`
+	RecordID   uuid.UUID `json:"record_id"`
+	PropertyID uuid.UUID `json:"property_id"`
+	ReadAt     time.Time `json:"read_at"`
+}
+
+func (urs *UnmaskedReadSchema) UnmaskedRead() domain.UnmaskedRead {
+	return domain.UnmaskedRead{
+		ID:         urs.ID,
+		Subject:    urs.Subject,
+		Roles:      urs.Roles,
+		RecordID:   urs.RecordID,
+		PropertyID: urs.PropertyID,
+		ReadAt:     urs.ReadAt.UTC(),
+	}
+}
+
+func unmaskedReadToSchema(ur domain.UnmaskedRead) UnmaskedReadSchema {
+	return UnmaskedReadSchema{
+		Subject:    ur.Subject,
+		Roles:      ur.Roles,
+		RecordID:   ur.RecordID,
+		PropertyID: ur.PropertyID,
+	}
+}
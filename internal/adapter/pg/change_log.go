@@ -0,0 +1,158 @@
+package pg
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"datatom/pkg/db/pg"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// changesChannel is notified by the change log with IDs of changes.
+const changesChannel = "changes"
+
+func (r *Repository) GetChangeLog(ctx context.Context, req ChangeLogRequest) ([]Change, error) {
+	args := []any{
+		req.AfterID,
+		pg.ArrayUUID(req.Filter.RefTypeIDs),
+		pg.ArrayUUID(req.Filter.PropertyIDs),
+		int(req.Limit),
+		changeTypesToCodes(req.Filter.ChangeTypes),
+	}
+	query := `SELECT * FROM get_change_log($1, $2, $3, $4, $5);`
+	rows, err := r.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	out := make([]Change, 0, req.Limit)
+	for rows.Next() {
+		var changeJSON []byte
+		if err := rows.Scan(&changeJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema ChangeSchema
+		if err := json.Unmarshal(changeJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, changeJSON)
+		}
+		out = append(out, schema.Change())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+func (r *Repository) GetLastChangeID(ctx context.Context) (int64, error) {
+	var out int64
+	query := `SELECT get_last_change_id();`
+	if err := r.QueryRow(ctx, query).Scan(&out); err != nil {
+		return 0, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+// ListenChanges holds a connection of the pool while it listens, the connection
+// is closed afterwards rather than released cause it is still subscribed.
+func (r *Repository) ListenChanges(ctx context.Context, notify func(int64)) error {
+	conn, err := r.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection error: %w", err)
+	}
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+	query := `LISTEN ` + changesChannel + `;`
+	if _, err := pgConn.Exec(ctx, query); err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	for {
+		n, err := pgConn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("wait for notification error: %w", err)
+		}
+		id, err := strconv.ParseInt(n.Payload, 10, 64)
+		if err != nil {
+			r.l.Warnf("change notification payload %q error: %s", n.Payload, err)
+			continue
+		}
+		notify(id)
+	}
+}
+
+func (r *Repository) PurgeChangeLog(ctx context.Context, retention time.Duration) (int64, error) {
+	var out int64
+	query := `SELECT purge_change_log($1);`
+	if err := r.QueryRow(ctx, query, retention).Scan(&out); err != nil {
+		return 0, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+func (r *Repository) GetChangeConsumers(ctx context.Context) ([]ChangeConsumer, error) {
+	query := `SELECT * FROM get_change_consumers();`
+	rows, err := r.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	var out []ChangeConsumer
+	for rows.Next() {
+		var consumerJSON []byte
+		if err := rows.Scan(&consumerJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema ChangeConsumerSchema
+		if err := json.Unmarshal(consumerJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, consumerJSON)
+		}
+		out = append(out, *schema.ChangeConsumer())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+func (r *Repository) GetChangeConsumer(ctx context.Context, name string) (*ChangeConsumer, error) {
+	var consumerJSON []byte
+	query := `SELECT get_change_consumer($1);`
+	if err := r.QueryRow(ctx, query, name).Scan(&consumerJSON); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	if consumerJSON == nil {
+		return nil, ErrChangeConsumerNotFound
+	}
+	var schema ChangeConsumerSchema
+	if err := json.Unmarshal(consumerJSON, &schema); err != nil {
+		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, consumerJSON)
+	}
+	return schema.ChangeConsumer(), nil
+}
+
+// SetChangeConsumer creates the consumer or moves its cursor.
+func (r *Repository) SetChangeConsumer(ctx context.Context, name string, lastChangeID int64) (*ChangeConsumer, error) {
+	var consumerJSON []byte
+	query := `SELECT set_change_consumer($1, $2);`
+	if err := r.QueryRow(ctx, query, name, lastChangeID).Scan(&consumerJSON); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	var schema ChangeConsumerSchema
+	if err := json.Unmarshal(consumerJSON, &schema); err != nil {
+		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, consumerJSON)
+	}
+	return schema.ChangeConsumer(), nil
+}
+
+func (r *Repository) DeleteChangeConsumer(ctx context.Context, name string) error {
+	var deleted bool
+	query := `SELECT delete_change_consumer($1);`
+	if err := r.QueryRow(ctx, query, name).Scan(&deleted); err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	if !deleted {
+		return ErrChangeConsumerNotFound
+	}
+	return nil
+}
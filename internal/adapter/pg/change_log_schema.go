@@ -0,0 +1,66 @@
+package pg
+
+import (
+	"datatom/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ChangeSchema struct {
+	ID         int64     `json:"id"`
+	ChangeType string    `json:"change_type"`
+	RefTypeID  uuid.UUID `json:"reference_type_id"`
+	PropertyID uuid.UUID `json:"property_id"`
+	RecordID   uuid.UUID `json:"record_id"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+func (cs *ChangeSchema) Change() domain.Change {
+	return domain.Change{
+		ID:         cs.ID,
+		DataType:   domain.ChangedDataTypeFromCode(cs.ChangeType),
+		RefTypeID:  cs.RefTypeID,
+		PropertyID: cs.PropertyID,
+		RecordID:   cs.RecordID,
+		ChangedAt:  cs.ChangedAt.UTC(),
+	}
+}
+
+type ChangeConsumerSchema struct {
+	Name         string    `json:"name"`
+	LastChangeID int64     `json:"last_change_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (cs *ChangeConsumerSchema) ChangeConsumer() *domain.ChangeConsumer {
+	return &domain.ChangeConsumer{
+		Name:         cs.Name,
+		LastChangeID: cs.LastChangeID,
+		CreatedAt:    cs.CreatedAt.UTC(),
+		UpdatedAt:    cs.UpdatedAt.UTC(),
+	}
+}
+
+func changeTypesToCodes(in []domain.ChangedDataType) []string {
+	if in == nil {
+		return nil
+	}
+	out := make([]string, 0, len(in))
+	for _, t := range in {
+		out = append(out, t.String())
+	}
+	return out
+}
+
+func changeTypesFromCodes(in []string) []domain.ChangedDataType {
+	if in == nil {
+		return nil
+	}
+	out := make([]domain.ChangedDataType, 0, len(in))
+	for _, code := range in {
+		out = append(out, domain.ChangedDataTypeFromCode(code))
+	}
+	return out
+}
@@ -5,7 +5,10 @@ import (
 	"datatom/internal/domain"
 	"datatom/pkg/db"
 	"datatom/pkg/db/pg"
+	"encoding/json"
 	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 func (r *Repository) GetChanges(ctx context.Context) ([]domain.ChangedData, error) {
@@ -13,6 +16,7 @@ func (r *Repository) GetChanges(ctx context.Context) ([]domain.ChangedData, erro
 	var id int64
 	var dataType string
 	var key []byte
+	var traceParent pgtype.Text
 	query := `SELECT * FROM get_changes();`
 	rows, err := r.Query(ctx, query)
 	if err != nil {
@@ -22,13 +26,14 @@ func (r *Repository) GetChanges(ctx context.Context) ([]domain.ChangedData, erro
 		return nil, fmt.Errorf("database error: %w, %s", err, query)
 	}
 	for rows.Next() {
-		if err := rows.Scan(&id, &dataType, &key); err != nil {
+		if err := rows.Scan(&id, &dataType, &key, &traceParent); err != nil {
 			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
 		}
 		out = append(out, domain.ChangedData{
-			ID:       id,
-			DataType: domain.ChangedDataTypeFromCode(dataType),
-			Key:      key,
+			ID:          id,
+			DataType:    domain.ChangedDataTypeFromCode(dataType),
+			Key:         key,
+			TraceParent: traceParent.String,
 		})
 	}
 	return out, nil
@@ -46,3 +51,16 @@ func (r *Repository) PurgeChanges(ctx context.Context, id int64, tx db.Transacti
 	}
 	return nil
 }
+
+func (r *Repository) GetPendingChanges(ctx context.Context) (domain.PendingChanges, error) {
+	var out []byte
+	query := `SELECT get_pending_changes();`
+	if err := r.QueryRow(ctx, query).Scan(&out); err != nil {
+		return domain.PendingChanges{}, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	var schema PendingChangesSchema
+	if err := json.Unmarshal(out, &schema); err != nil {
+		return domain.PendingChanges{}, fmt.Errorf("db result unmarshal error: %s, %s", err, out)
+	}
+	return schema.PendingChanges(), nil
+}
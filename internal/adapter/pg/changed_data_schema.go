@@ -4,6 +4,7 @@ import (
 	"datatom/internal/domain"
 	"encoding/json"
 	"github.com/google/uuid"
+	"time"
 )
 
 type changedValueKeySchema struct {
@@ -33,3 +34,16 @@ func getDataRequestByKey(key []byte) (uuid.UUID, error) {
 	}
 	return schema.ID, nil
 }
+
+type PendingChangesSchema struct {
+	Count           int64      `json:"count"`
+	OldestChangedAt *time.Time `json:"oldest_changed_at"`
+}
+
+func (pcs *PendingChangesSchema) PendingChanges() domain.PendingChanges {
+	out := domain.PendingChanges{Count: pcs.Count}
+	if pcs.OldestChangedAt != nil {
+		out.OldestChangedAt = pcs.OldestChangedAt.UTC()
+	}
+	return out
+}
@@ -0,0 +1,312 @@
+package pg
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"datatom/pkg/db/pg"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var dumpQueries = []struct {
+	kind  DumpItemKind
+	query string
+}{
+	{DumpItemRefType, `SELECT * FROM dump_reference_types();`},
+	{DumpItemProperty, `SELECT * FROM dump_properties();`},
+	{DumpItemSequenceCounter, `SELECT * FROM dump_sequence_counters();`},
+	{DumpItemRecord, `SELECT * FROM dump_records();`},
+	{DumpItemValue, `SELECT * FROM dump_values();`},
+}
+
+// ExportDump passes all the data to emit in the dependency order.
+// The data is read from the one snapshot so the dump is consistent.
+func (r *Repository) ExportDump(ctx context.Context, emit func(DumpItem) error) error {
+	tx, err := r.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("begin transaction error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	for _, dq := range dumpQueries {
+		if err := exportDumpItems(ctx, tx, dq.kind, dq.query, emit); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func exportDumpItems(ctx context.Context, tx pgx.Tx, kind DumpItemKind, query string, emit func(DumpItem) error) error {
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var itemJSON []byte
+		if err := rows.Scan(&itemJSON); err != nil {
+			return fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		item, err := dumpItemFromJSON(kind, itemJSON)
+		if err != nil {
+			return err
+		}
+		if err := emit(*item); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return nil
+}
+
+// ImportDump inserts items of the source with their IDs within the one transaction.
+// Values go last and are copied by the COPY protocol.
+func (r *Repository) ImportDump(ctx context.Context, src DumpSource, opts ImportDumpOptions) (*DumpStats, error) {
+	tx, err := r.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	query := `SELECT set_config('datatom.import', 'on', true), set_config('datatom.skip_changes', $1, true);`
+	skipChanges := "on"
+	if opts.RegisterChanges {
+		skipChanges = "off"
+	}
+	if _, err := tx.Exec(ctx, query, skipChanges); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	var out DumpStats
+	last := DumpItemRefType
+	for {
+		item, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if item.Kind < last {
+			return nil, fmt.Errorf("%w: %s after %s", ErrDumpItemOrder, item.Kind.String(), last.String())
+		}
+		last = item.Kind
+		if item.Kind == DumpItemValue {
+			n, err := importDumpValues(ctx, tx, item, src)
+			if err != nil {
+				return nil, err
+			}
+			out.Values = n
+			break
+		}
+		if err := importDumpItem(ctx, tx, item); err != nil {
+			return nil, err
+		}
+		switch item.Kind {
+		case DumpItemRefType:
+			out.RefTypes++
+		case DumpItemProperty:
+			out.Properties++
+		case DumpItemSequenceCounter:
+			out.SequenceCounters++
+		case DumpItemRecord:
+			out.Records++
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit transaction error: %w", err)
+	}
+	return &out, nil
+}
+
+func importDumpItem(ctx context.Context, tx pgx.Tx, item *DumpItem) error {
+	var query string
+	var args []any
+	var id uuid.UUID
+	switch {
+	case item.Kind == DumpItemRefType && item.RefType != nil:
+		id = item.RefType.ID
+		query = `INSERT INTO reference_types (id, "name", description) VALUES ($1, $2, $3);`
+		args = []any{id, item.RefType.Name, item.RefType.Description}
+	case item.Kind == DumpItemProperty && item.Property != nil:
+		var err error
+		id = item.Property.ID
+		query = `SELECT insert_property($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14);`
+		args, err = insertPropertyArgs(item.Property)
+		if err != nil {
+			return err
+		}
+	case item.Kind == DumpItemSequenceCounter && item.SequenceCounter != nil:
+		id = item.SequenceCounter.PropertyID
+		query = `INSERT INTO sequence_counters (property_id, "period", last_number) VALUES ($1, $2, $3);`
+		args = []any{id, item.SequenceCounter.Period, item.SequenceCounter.LastNumber}
+	case item.Kind == DumpItemRecord && item.Record != nil:
+		id = item.Record.ID
+		query = `INSERT INTO records (id, reference_type_id, "name", description, deletion_mark) VALUES ($1, $2, $3, $4, $5);`
+		args = []any{
+			id,
+			pg.NullUUID(item.Record.ReferenceTypeID),
+			item.Record.Name,
+			item.Record.Description,
+			item.Record.DeletionMark,
+		}
+	default:
+		return fmt.Errorf("%w %s item", ErrExpected, item.Kind.String())
+	}
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		return dumpItemError(err, fmt.Sprintf("%s %s", item.Kind.String(), id), query)
+	}
+	if item.Kind == DumpItemRecord && len(item.Record.ACL) != 0 {
+		return importDumpRecordACL(ctx, tx, item.Record)
+	}
+	if item.Kind == DumpItemProperty && item.Property.Mask != nil {
+		return importDumpPropertyMask(ctx, tx, item.Property)
+	}
+	return nil
+}
+
+func importDumpPropertyMask(ctx context.Context, tx pgx.Tx, property *Property) error {
+	maskJSON, err := json.Marshal(maskPolicyToSchema(property.Mask))
+	if err != nil {
+		return fmt.Errorf("mask marshal error: %s", err)
+	}
+	query := `SELECT set_property_mask($1, $2);`
+	if _, err := tx.Exec(ctx, query, property.ID, string(maskJSON)); err != nil {
+		return dumpItemError(err, fmt.Sprintf("%s %s", DumpItemProperty.String(), property.ID), query)
+	}
+	return nil
+}
+
+func importDumpRecordACL(ctx context.Context, tx pgx.Tx, record *Record) error {
+	aclJSON, err := json.Marshal(aclToSchema(record.ACL))
+	if err != nil {
+		return fmt.Errorf("ACL marshal error: %s", err)
+	}
+	query := `SELECT set_record_acl($1, $2);`
+	if _, err := tx.Exec(ctx, query, record.ID, string(aclJSON)); err != nil {
+		return dumpItemError(err, fmt.Sprintf("%s %s", DumpItemRecord.String(), record.ID), query)
+	}
+	return nil
+}
+
+func insertPropertyArgs(p *Property) ([]any, error) {
+	args := []any{
+		p.ID,
+		p.Name,
+		p.Description,
+		TypesToCodes(p.Types),
+		pg.ArrayUUID(p.RefTypeIDs),
+		pg.NullUUID(p.OwnerRefTypeID),
+		p.Kind.Code(),
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		p.Encrypted,
+	}
+	if p.Sequence != nil {
+		args[7] = p.Sequence.Prefix
+		if p.Sequence.Template != "" {
+			args[8] = p.Sequence.Template
+		}
+		args[9] = p.Sequence.ResetPeriod.Code()
+	}
+	if p.StateMachine != nil {
+		transitions, err := json.Marshal(stateTransitionsToSchema(p.StateMachine.Transitions))
+		if err != nil {
+			return nil, fmt.Errorf("state transitions marshal error: %s", err)
+		}
+		args[10] = p.StateMachine.States
+		args[11] = p.StateMachine.Initial
+		args[12] = string(transitions)
+	}
+	return args, nil
+}
+
+func importDumpValues(ctx context.Context, tx pgx.Tx, first *DumpItem, src DumpSource) (int64, error) {
+	// The COPY protocol is binary so the enum of types has to be known by the connection
+	t, err := tx.Conn().LoadType(ctx, "types")
+	if err != nil {
+		return 0, fmt.Errorf("load type error: %w", err)
+	}
+	tx.Conn().TypeMap().RegisterType(t)
+	columns := []string{"owner_id", "property_id", "type", "reference_type_id", "value"}
+	n, err := tx.CopyFrom(ctx, pgx.Identifier{"values"}, columns, &dumpValuesSource{next: first, src: src})
+	if err != nil {
+		return n, dumpItemError(err, "value", "COPY values")
+	}
+	return n, nil
+}
+
+func dumpItemError(err error, item, query string) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		// Error of the dump source
+		return err
+	}
+	switch {
+	case pg.IsNotUniqueError(pgErr):
+		return fmt.Errorf("%s: %w", item, ErrDumpItemDuplicated)
+	case pg.IsForeignKeyError(pgErr):
+		return fmt.Errorf("%s: %w", item, ErrDumpItemReferenceMissing)
+	}
+	if errException, ok := pgExceptionAsDomainError(pgErr); ok {
+		return fmt.Errorf("%s: %w", item, errException)
+	}
+	return fmt.Errorf("database error: %w, %s", err, query)
+}
+
+// dumpValuesSource feeds the COPY by value items of the dump source.
+type dumpValuesSource struct {
+	next   *DumpItem
+	src    DumpSource
+	values []any
+	err    error
+}
+
+func (dvs *dumpValuesSource) Next() bool {
+	item := dvs.next
+	dvs.next = nil
+	if item == nil {
+		var err error
+		item, err = dvs.src.Next()
+		if errors.Is(err, io.EOF) {
+			return false
+		}
+		if err != nil {
+			dvs.err = err
+			return false
+		}
+	}
+	if item.Kind != DumpItemValue || item.Value == nil {
+		dvs.err = fmt.Errorf("%w: %s after value", ErrDumpItemOrder, item.Kind.String())
+		return false
+	}
+	v := item.Value
+	value, err := ValueAsJSON(v.Value, v.Type)
+	if err != nil {
+		dvs.err = err
+		return false
+	}
+	var refTypeID any
+	if v.RefTypeID != uuid.Nil {
+		refTypeID = [16]byte(v.RefTypeID)
+	}
+	dvs.values = []any{[16]byte(v.RecordID), [16]byte(v.PropertyID), v.Type.Code(), refTypeID, string(value)}
+	return true
+}
+
+func (dvs *dumpValuesSource) Values() ([]any, error) {
+	return dvs.values, nil
+}
+
+func (dvs *dumpValuesSource) Err() error {
+	return dvs.err
+}
@@ -0,0 +1,62 @@
+package pg
+
+import (
+	. "datatom/internal/domain"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type SequenceCounterSchema struct {
+	PropertyID uuid.UUID `json:"property_id"`
+	Period     string    `json:"period"`
+	LastNumber int64     `json:"last_number"`
+}
+
+func (scs *SequenceCounterSchema) SequenceCounter() *SequenceCounter {
+	return &SequenceCounter{
+		PropertyID: scs.PropertyID,
+		Period:     scs.Period,
+		LastNumber: scs.LastNumber,
+	}
+}
+
+// dumpItemFromJSON converts a row of the dump function of the kind to the dump item.
+func dumpItemFromJSON(kind DumpItemKind, b []byte) (*DumpItem, error) {
+	out := DumpItem{Kind: kind}
+	var err error
+	switch kind {
+	case DumpItemRefType:
+		var schema RefTypeSchema
+		if err = json.Unmarshal(b, &schema); err == nil {
+			out.RefType = schema.RefType()
+		}
+	case DumpItemProperty:
+		var schema PropertySchema
+		if err = json.Unmarshal(b, &schema); err == nil {
+			out.Property, err = schema.Property()
+		}
+	case DumpItemSequenceCounter:
+		var schema SequenceCounterSchema
+		if err = json.Unmarshal(b, &schema); err == nil {
+			out.SequenceCounter = schema.SequenceCounter()
+		}
+	case DumpItemRecord:
+		var schema RecordSchema
+		if err = json.Unmarshal(b, &schema); err == nil {
+			out.Record, err = schema.Record()
+		}
+	case DumpItemValue:
+		var schema ValueSchema
+		if err = json.Unmarshal(b, &schema); err == nil {
+			out.Value, err = schema.Value()
+		}
+	default:
+		return nil, fmt.Errorf("%w %s", ErrUnknownType, kind.String())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, b)
+	}
+	return &out, nil
+}
@@ -3,6 +3,8 @@ package pg
 import (
 	. "datatom/internal/domain"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/jackc/pgx/v5/pgconn"
 )
@@ -11,6 +13,9 @@ var (
 	pgExceptions map[string]error
 
 	errCanNotGetUniqueID = fmt.Errorf("can not get unique ID")
+
+	// pgDetailRe matches details of the exceptions like KEYS(records.id) VALUES(...)
+	pgDetailRe = regexp.MustCompile(`^KEYS\((.*?)\) VALUES?\((.*)\)$`)
 )
 
 func init() {
@@ -24,6 +29,20 @@ func init() {
 		"unexpected reference type ID":                          ErrUnexpectedRefTypePG,
 		"reference type ID missing":                             ErrRefTypeExpectedPG,
 		"no need reference type ID cause type is not reference": ErrRefTypeIsRedundantPG,
+		"sequence types mismatch":                               ErrSequenceTypeMismatchPG,
+		"sequence owner reference type ID missing":              ErrSequenceOwnerExpectedPG,
+		"sequence template without number placeholder":          ErrSequenceTemplatePG,
+		"state machine types mismatch":                          ErrStateMachineTypeMismatchPG,
+		"property is not a state machine":                       ErrNotStateMachinePG,
+		"unknown state":                                         ErrUnknownStatePG,
+		"state transition not allowed":                          ErrStateTransitionNotAllowedPG,
+		"encrypted property kind mismatch":                      ErrEncryptedPropertyKindPG,
+		"value of encrypted property is not encrypted":          ErrValueNotEncryptedPG,
+		"record not found":                                      ErrRecordNotFound,
+		"reference type not found":                              ErrRefTypeNotFound,
+		"sum mismatch":                                          ErrSumMismatch,
+		"idempotency key reused":                                ErrIdempotencyKeyReused,
+		"idempotency key in progress":                           ErrIdempotencyKeyInProgress,
 	}
 }
 
@@ -39,7 +58,61 @@ func pgExceptionAsDomainError(err error) (error, bool) {
 		return err, false
 	}
 	if errException, ok := pgExceptions[pgErr.Message]; ok {
-		return errException, true
+		if pgErr.Detail == "" {
+			return errException, true
+		}
+		field, details := parsePgExceptionDetail(pgErr.Detail)
+		return &DetailedError{Err: errException, Field: field, Details: details}, true
 	}
 	return err, false
 }
+
+// parsePgExceptionDetail returns values of the detail by keys and the column if it is the only one among keys.
+// The detail which can not be parsed is returned as is by the "detail" key.
+func parsePgExceptionDetail(detail string) (string, map[string]string) {
+	m := pgDetailRe.FindStringSubmatch(detail)
+	if m == nil {
+		return "", map[string]string{"detail": detail}
+	}
+	keys := strings.Split(strings.ReplaceAll(m[1], `"`, ""), ", ")
+	values := splitPgDetailValues(m[2])
+	if len(values) == 1 && len(keys) > 1 && strings.HasPrefix(values[0], "{") && strings.HasSuffix(values[0], "}") {
+		// Values of several keys may be grouped as {a, b}
+		values = splitPgDetailValues(values[0][1 : len(values[0])-1])
+	}
+	if len(keys) != len(values) {
+		return "", map[string]string{"detail": detail}
+	}
+	var columns []string
+	details := make(map[string]string, len(keys))
+	for i, k := range keys {
+		details[k] = values[i]
+		if _, column, ok := strings.Cut(k, "."); ok {
+			columns = append(columns, column)
+		}
+	}
+	if len(columns) != 1 {
+		return "", details
+	}
+	return columns[0], details
+}
+
+// splitPgDetailValues splits the values by commas out of braces of arrays.
+func splitPgDetailValues(s string) []string {
+	var out []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(out, strings.TrimSpace(s[start:]))
+}
@@ -0,0 +1,61 @@
+package pg
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ReserveIdempotencyKey returns nil if the key is reserved for the request or its reservation is taken over
+// after the lease, and the stored response if the request has been already processed.
+func (r *Repository) ReserveIdempotencyKey(ctx context.Context, key IdempotencyKey, ttl, lease time.Duration) (*IdempotentResponse, error) {
+	var responseJSON []byte
+	query := `SELECT reserve_idempotency_key($1, $2, $3, $4, $5);`
+	if err := r.QueryRow(ctx, query, key.Key, key.Scope, key.Fingerprint, ttl, lease).Scan(&responseJSON); err != nil {
+		if errException, ok := pgExceptionAsDomainError(err); ok {
+			return nil, errException
+		}
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	if responseJSON == nil {
+		return nil, nil
+	}
+	var schema IdempotentResponseSchema
+	if err := json.Unmarshal(responseJSON, &schema); err != nil {
+		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, responseJSON)
+	}
+	return schema.IdempotentResponse(), nil
+}
+
+func (r *Repository) SaveIdempotentResponse(ctx context.Context, key IdempotencyKey, resp IdempotentResponse) error {
+	headers, err := json.Marshal(resp.Headers)
+	if err != nil {
+		return fmt.Errorf("headers marshal error: %s", err)
+	}
+	query := `SELECT save_idempotent_response($1, $2, $3, $4, $5);`
+	if _, err := r.Exec(ctx, query, key.Key, key.Scope, resp.Status, string(headers), resp.Body); err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey deletes the reservation of the key which response is not saved,
+// so the request can be retried.
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, key IdempotencyKey) error {
+	query := `SELECT release_idempotency_key($1, $2);`
+	if _, err := r.Exec(ctx, query, key.Key, key.Scope); err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return nil
+}
+
+func (r *Repository) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	var out int64
+	query := `SELECT purge_idempotency_keys();`
+	if err := r.QueryRow(ctx, query).Scan(&out); err != nil {
+		return 0, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
@@ -0,0 +1,20 @@
+package pg
+
+import (
+	"datatom/internal/domain"
+)
+
+type IdempotentResponseSchema struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	// Body is encoded by base64 which is the JSON encoding of byte slices
+	Body []byte `json:"body"`
+}
+
+func (irs *IdempotentResponseSchema) IdempotentResponse() *domain.IdempotentResponse {
+	return &domain.IdempotentResponse{
+		Status:  irs.Status,
+		Headers: irs.Headers,
+		Body:    irs.Body,
+	}
+}
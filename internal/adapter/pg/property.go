@@ -3,14 +3,16 @@ package pg
 import (
 	"context"
 	. "datatom/internal/domain"
+	"datatom/pkg/db"
 	"datatom/pkg/db/pg"
 	"encoding/json"
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
-func (r *Repository) AddProperty(ctx context.Context, req AddPropertyRequest) (uuid.UUID, error) {
+func (r *Repository) AddProperty(ctx context.Context, req AddPropertyRequest, tx db.Transaction) (uuid.UUID, error) {
 	var out uuid.UUID
 	args := []any{
 		req.Name,
@@ -18,10 +20,51 @@ func (r *Repository) AddProperty(ctx context.Context, req AddPropertyRequest) (u
 		TypesToCodes(req.Types),
 		pg.ArrayUUID(req.RefTypeIDs),
 		pg.NullUUID(req.OwnerRefTypeID),
+		req.Kind.Code(),
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		req.Encrypted,
 	}
-	query := `SELECT new_property($1, $2, $3, $4, $5);`
+	if req.Sequence != nil {
+		args[6] = req.Sequence.Prefix
+		if req.Sequence.Template != "" {
+			args[7] = req.Sequence.Template
+		}
+		args[8] = req.Sequence.ResetPeriod.Code()
+	}
+	if req.StateMachine != nil {
+		transitions, err := json.Marshal(stateTransitionsToSchema(req.StateMachine.Transitions))
+		if err != nil {
+			return out, fmt.Errorf("state transitions marshal error: %s", err)
+		}
+		args[9] = req.StateMachine.States
+		args[10] = req.StateMachine.Initial
+		args[11] = string(transitions)
+	}
+	queryRow, err := funcQueryRow(r, tx)
+	if err != nil {
+		return out, fmt.Errorf("transaction error: %w", err)
+	}
+	if req.ID != uuid.Nil {
+		query := `SELECT insert_property($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14);`
+		if err := queryRow(ctx, query, append([]any{req.ID}, args...)...).Scan(&out); err != nil {
+			if pg.IsNotUniqueError(err) {
+				return out, ErrPropertyAlreadyExists
+			}
+			if errException, ok := pgExceptionAsDomainError(err); ok {
+				return out, errException
+			}
+			return out, fmt.Errorf("database error: %w, %s", err, query)
+		}
+		return out, nil
+	}
+	query := `SELECT new_property($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);`
 	for attempts := 0; attempts < getUUIDAttemptsThreshold; attempts++ {
-		if err := r.QueryRow(ctx, query, args...).Scan(&out); err != nil {
+		if err := scanRowAttempt(ctx, r, tx, query, args, &out); err != nil {
 			if pg.IsNotUniqueError(err) {
 				continue
 			}
@@ -35,10 +78,15 @@ func (r *Repository) AddProperty(ctx context.Context, req AddPropertyRequest) (u
 	return out, errCanNotGetUniqueID
 }
 
-func (r *Repository) UpdateProperty(ctx context.Context, req UpdPropertyRequest) (*Property, error) {
+func (r *Repository) UpdateProperty(ctx context.Context, req UpdPropertyRequest, tx db.Transaction) (*Property, error) {
+	queryRow, err := funcQueryRow(r, tx)
+	if err != nil {
+		return nil, fmt.Errorf("transaction error: %w", err)
+	}
 	emptyReq := true
-	args := make([]any, 3)
+	args := make([]any, 4)
 	args[0] = req.ID
+	args[3] = pg.NullString(req.ExpectedSum)
 	if req.Name != nil {
 		args[1] = *req.Name
 		emptyReq = false
@@ -48,27 +96,56 @@ func (r *Repository) UpdateProperty(ctx context.Context, req UpdPropertyRequest)
 		emptyReq = false
 	}
 	if emptyReq {
-		return r.GetProperty(ctx, req.ID)
+		out, err := getProperty(ctx, queryRow, req.ID)
+		if err == nil && req.ExpectedSum != "" && out.Sum != req.ExpectedSum {
+			return nil, ErrSumMismatch
+		}
+		return out, err
 	}
 	var propertyJSON []byte
-	query := `SELECT update_property($1, $2, $3);`
-	if err := r.QueryRow(ctx, query, args...).Scan(&propertyJSON); err != nil {
+	query := `SELECT update_property($1, $2, $3, $4);`
+	if err := queryRow(ctx, query, args...).Scan(&propertyJSON); err != nil {
 		if pg.IsNoRowsError(err) {
 			return nil, ErrPropertyNotFound
 		}
+		if errException, ok := pgExceptionAsDomainError(err); ok {
+			return nil, errException
+		}
 		return nil, fmt.Errorf("database error: %w, %s", err, query)
 	}
 	var schema PropertySchema
 	if err := json.Unmarshal(propertyJSON, &schema); err != nil {
 		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, propertyJSON)
 	}
-	return schema.Property(), nil
+	return schema.Property()
 }
 
 func (r *Repository) GetProperty(ctx context.Context, id uuid.UUID) (*Property, error) {
+	return getProperty(ctx, r.QueryRow, id)
+}
+
+// GetPropertyOwnerRefTypeID reads the property within the transaction, so properties added by it are found.
+// Zero ID is returned if the property is missing or it has no owner.
+func (r *Repository) GetPropertyOwnerRefTypeID(ctx context.Context, id uuid.UUID, tx db.Transaction) (uuid.UUID, error) {
+	var out uuid.UUID
+	query := `SELECT owner_reference_type_id FROM properties WHERE id = $1;`
+	queryRow, err := funcQueryRow(r, tx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("transaction error: %w", err)
+	}
+	if err := queryRow(ctx, query, id).Scan(&out); err != nil {
+		if pg.IsNoRowsError(err) {
+			return uuid.Nil, nil
+		}
+		return uuid.Nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+func getProperty(ctx context.Context, queryRow func(context.Context, string, ...any) pgx.Row, id uuid.UUID) (*Property, error) {
 	var propertyJSON []byte
 	query := `SELECT get_property($1);`
-	if err := r.QueryRow(ctx, query, id).Scan(&propertyJSON); err != nil {
+	if err := queryRow(ctx, query, id).Scan(&propertyJSON); err != nil {
 		if pg.IsNoRowsError(err) {
 			return nil, ErrPropertyNotFound
 		}
@@ -78,5 +155,91 @@ func (r *Repository) GetProperty(ctx context.Context, id uuid.UUID) (*Property,
 	if err := json.Unmarshal(propertyJSON, &schema); err != nil {
 		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, propertyJSON)
 	}
-	return schema.Property(), nil
+	return schema.Property()
+}
+
+func (r *Repository) GetProperties(ctx context.Context, ids []uuid.UUID) ([]Property, error) {
+	query := `SELECT * FROM get_properties($1);`
+	rows, err := r.Query(ctx, query, pg.ArrayUUID(ids))
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	out := make([]Property, 0, len(ids))
+	for rows.Next() {
+		var propertyJSON []byte
+		if err := rows.Scan(&propertyJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema PropertySchema
+		if err := json.Unmarshal(propertyJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, propertyJSON)
+		}
+		property, err := schema.Property()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *property)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+// GetPropertyMasks returns masks of the found properties which have them.
+func (r *Repository) GetPropertyMasks(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]MaskPolicy, error) {
+	query := `SELECT * FROM get_property_masks($1);`
+	rows, err := r.Query(ctx, query, pg.ArrayUUID(ids))
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	out := make(map[uuid.UUID]MaskPolicy)
+	for rows.Next() {
+		var maskJSON []byte
+		if err := rows.Scan(&maskJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema PropertyMaskSchema
+		if err := json.Unmarshal(maskJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, maskJSON)
+		}
+		mask, err := schema.Mask.MaskPolicy()
+		if err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, maskJSON)
+		}
+		if mask != nil {
+			out[schema.PropertyID] = *mask
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+// SetPropertyMask replaces the mask of the property, the nil mask removes it.
+func (r *Repository) SetPropertyMask(ctx context.Context, id uuid.UUID, mask *MaskPolicy) (*Property, error) {
+	var maskArg any
+	if mask != nil {
+		maskJSON, err := json.Marshal(maskPolicyToSchema(mask))
+		if err != nil {
+			return nil, fmt.Errorf("mask marshal error: %s", err)
+		}
+		maskArg = string(maskJSON)
+	}
+	var propertyJSON []byte
+	query := `SELECT set_property_mask($1, $2);`
+	if err := r.QueryRow(ctx, query, id, maskArg).Scan(&propertyJSON); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	if propertyJSON == nil {
+		return nil, ErrPropertyNotFound
+	}
+	var schema PropertySchema
+	if err := json.Unmarshal(propertyJSON, &schema); err != nil {
+		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, propertyJSON)
+	}
+	return schema.Property()
 }
@@ -8,17 +8,118 @@ import (
 )
 
 type PropertySchema struct {
-	ID             uuid.UUID   `json:"id"`
-	Types          []string    `json:"types"`
-	RefTypeIDs     []uuid.UUID `json:"reference_type_ids"`
-	Name           string      `json:"name"`
-	Description    string      `json:"description"`
-	OwnerRefTypeID uuid.UUID   `json:"owner_reference_type_id"`
-	Sum            string      `json:"sum"`
-	ChangeAt       time.Time   `json:"change_at"`
+	ID             uuid.UUID           `json:"id"`
+	Types          []string            `json:"types"`
+	RefTypeIDs     []uuid.UUID         `json:"reference_type_ids"`
+	Name           string              `json:"name"`
+	Description    string              `json:"description"`
+	OwnerRefTypeID uuid.UUID           `json:"owner_reference_type_id"`
+	Kind           string              `json:"kind"`
+	Sequence       *SequenceSchema     `json:"sequence"`
+	StateMachine   *StateMachineSchema `json:"state_machine"`
+	Encrypted      bool                `json:"encrypted"`
+	Mask           *MaskPolicySchema   `json:"mask"`
+	Sum            string              `json:"sum"`
+	ChangeAt       time.Time           `json:"change_at"`
 }
 
-func (rs *PropertySchema) Property() *Property {
+type SequenceSchema struct {
+	Prefix      string `json:"prefix"`
+	Template    string `json:"template"`
+	ResetPeriod string `json:"reset_period"`
+}
+
+func (ss *SequenceSchema) Sequence() (*Sequence, error) {
+	if ss == nil {
+		return nil, nil
+	}
+	resetPeriod, err := SequenceResetPeriodFromCode(ss.ResetPeriod)
+	if err != nil {
+		return nil, err
+	}
+	return &Sequence{
+		Prefix:      ss.Prefix,
+		Template:    ss.Template,
+		ResetPeriod: resetPeriod,
+	}, nil
+}
+
+type StateMachineSchema struct {
+	States      []string                `json:"states"`
+	Initial     string                  `json:"initial"`
+	Transitions []StateTransitionSchema `json:"transitions"`
+}
+
+type StateTransitionSchema struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (sms *StateMachineSchema) StateMachine() *StateMachine {
+	if sms == nil {
+		return nil
+	}
+	transitions := make([]StateTransition, 0, len(sms.Transitions))
+	for _, t := range sms.Transitions {
+		transitions = append(transitions, StateTransition{From: t.From, To: t.To})
+	}
+	return &StateMachine{
+		States:      sms.States,
+		Initial:     sms.Initial,
+		Transitions: transitions,
+	}
+}
+
+func stateTransitionsToSchema(transitions []StateTransition) []StateTransitionSchema {
+	out := make([]StateTransitionSchema, 0, len(transitions))
+	for _, t := range transitions {
+		out = append(out, StateTransitionSchema{From: t.From, To: t.To})
+	}
+	return out
+}
+
+type MaskPolicySchema struct {
+	Kind          string   `json:"kind"`
+	Reveal        uint     `json:"reveal"`
+	UnmaskedRoles []string `json:"unmasked_roles"`
+}
+
+func (mps *MaskPolicySchema) MaskPolicy() (*MaskPolicy, error) {
+	if mps == nil {
+		return nil, nil
+	}
+	kind, err := MaskKindFromCode(mps.Kind)
+	if err != nil {
+		return nil, err
+	}
+	return &MaskPolicy{Kind: kind, Reveal: mps.Reveal, UnmaskedRoles: mps.UnmaskedRoles}, nil
+}
+
+func maskPolicyToSchema(mp *MaskPolicy) *MaskPolicySchema {
+	if mp == nil {
+		return nil
+	}
+	return &MaskPolicySchema{Kind: mp.Kind.Code(), Reveal: mp.Reveal, UnmaskedRoles: mp.UnmaskedRoles}
+}
+
+type PropertyMaskSchema struct {
+	PropertyID uuid.UUID         `json:"property_id"`
+	Mask       *MaskPolicySchema `json:"mask"`
+}
+
+func (rs *PropertySchema) Property() (*Property, error) {
+	kind, err := PropertyKindFromCode(rs.Kind)
+	if err != nil {
+		return nil, err
+	}
+	sequence, err := rs.Sequence.Sequence()
+	if err != nil {
+		return nil, err
+	}
+	mask, err := rs.Mask.MaskPolicy()
+	if err != nil {
+		return nil, err
+	}
 	return &Property{
 		ID:             rs.ID,
 		Name:           rs.Name,
@@ -26,7 +127,12 @@ func (rs *PropertySchema) Property() *Property {
 		Types:          TypesFromCodes(rs.Types),
 		RefTypeIDs:     rs.RefTypeIDs,
 		OwnerRefTypeID: rs.OwnerRefTypeID,
+		Kind:           kind,
+		Sequence:       sequence,
+		StateMachine:   rs.StateMachine.StateMachine(),
+		Encrypted:      rs.Encrypted,
+		Mask:           mask,
 		Sum:            rs.Sum,
 		ChangeAt:       rs.ChangeAt.UTC(),
-	}
+	}, nil
 }
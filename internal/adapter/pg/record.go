@@ -3,14 +3,16 @@ package pg
 import (
 	"context"
 	. "datatom/internal/domain"
+	"datatom/pkg/db"
 	"datatom/pkg/db/pg"
 	"encoding/json"
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
-func (r *Repository) AddRecord(ctx context.Context, req AddRecordRequest) (uuid.UUID, error) {
+func (r *Repository) AddRecord(ctx context.Context, req AddRecordRequest, tx db.Transaction) (uuid.UUID, error) {
 	var out uuid.UUID
 	args := []any{
 		req.Name,
@@ -18,9 +20,23 @@ func (r *Repository) AddRecord(ctx context.Context, req AddRecordRequest) (uuid.
 		req.DeletionMark,
 		pg.NullUUID(req.ReferenceTypeID),
 	}
+	queryRow, err := funcQueryRow(r, tx)
+	if err != nil {
+		return out, fmt.Errorf("transaction error: %w", err)
+	}
+	if req.ID != uuid.Nil {
+		query := `SELECT insert_record($1, $2, $3, $4, $5);`
+		if err := queryRow(ctx, query, append([]any{req.ID}, args...)...).Scan(&out); err != nil {
+			if pg.IsNotUniqueError(err) {
+				return out, ErrRecordAlreadyExists
+			}
+			return out, fmt.Errorf("database error: %w, %s", err, query)
+		}
+		return out, nil
+	}
 	query := `SELECT new_record($1, $2, $3, $4);`
 	for attempts := 0; attempts < getUUIDAttemptsThreshold; attempts++ {
-		if err := r.QueryRow(ctx, query, args...).Scan(&out); err != nil {
+		if err := scanRowAttempt(ctx, r, tx, query, args, &out); err != nil {
 			if pg.IsNotUniqueError(err) {
 				continue
 			}
@@ -31,10 +47,15 @@ func (r *Repository) AddRecord(ctx context.Context, req AddRecordRequest) (uuid.
 	return out, errCanNotGetUniqueID
 }
 
-func (r *Repository) UpdateRecord(ctx context.Context, req UpdRecordRequest) (*Record, error) {
+func (r *Repository) UpdateRecord(ctx context.Context, req UpdRecordRequest, tx db.Transaction) (*Record, error) {
+	queryRow, err := funcQueryRow(r, tx)
+	if err != nil {
+		return nil, fmt.Errorf("transaction error: %w", err)
+	}
 	emptyReq := true
-	args := make([]any, 4)
+	args := make([]any, 5)
 	args[0] = req.ID
+	args[4] = pg.NullString(req.ExpectedSum)
 	if req.Name != nil {
 		args[1] = *req.Name
 		emptyReq = false
@@ -48,27 +69,56 @@ func (r *Repository) UpdateRecord(ctx context.Context, req UpdRecordRequest) (*R
 		emptyReq = false
 	}
 	if emptyReq {
-		return r.GetRecord(ctx, req.ID)
+		out, err := getRecord(ctx, queryRow, req.ID)
+		if err == nil && req.ExpectedSum != "" && out.Sum != req.ExpectedSum {
+			return nil, ErrSumMismatch
+		}
+		return out, err
 	}
 	var recordJSON []byte
-	query := `SELECT * FROM update_record($1, $2, $3, $4);`
-	if err := r.QueryRow(ctx, query, args...).Scan(&recordJSON); err != nil {
+	query := `SELECT * FROM update_record($1, $2, $3, $4, $5);`
+	if err := queryRow(ctx, query, args...).Scan(&recordJSON); err != nil {
 		if pg.IsNoRowsError(err) {
 			return nil, ErrRecordNotFound
 		}
+		if errException, ok := pgExceptionAsDomainError(err); ok {
+			return nil, errException
+		}
 		return nil, fmt.Errorf("database error: %w, %s", err, query)
 	}
 	var schema RecordSchema
 	if err := json.Unmarshal(recordJSON, &schema); err != nil {
 		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, recordJSON)
 	}
-	return schema.Record(), nil
+	return schema.Record()
 }
 
 func (r *Repository) GetRecord(ctx context.Context, id uuid.UUID) (*Record, error) {
+	return getRecord(ctx, r.QueryRow, id)
+}
+
+// GetRecordRefTypeID reads the record within the transaction, so records added by it are found.
+// Zero ID is returned if the record is missing.
+func (r *Repository) GetRecordRefTypeID(ctx context.Context, id uuid.UUID, tx db.Transaction) (uuid.UUID, error) {
+	var out uuid.UUID
+	query := `SELECT reference_type_id FROM records WHERE id = $1;`
+	queryRow, err := funcQueryRow(r, tx)
+	if err != nil {
+		return out, fmt.Errorf("transaction error: %w", err)
+	}
+	if err := queryRow(ctx, query, id).Scan(&out); err != nil {
+		if pg.IsNoRowsError(err) {
+			return uuid.Nil, nil
+		}
+		return out, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+func getRecord(ctx context.Context, queryRow func(context.Context, string, ...any) pgx.Row, id uuid.UUID) (*Record, error) {
 	var recordJSON []byte
 	query := `SELECT * FROM get_record($1);`
-	if err := r.QueryRow(ctx, query, id).Scan(&recordJSON); err != nil {
+	if err := queryRow(ctx, query, id).Scan(&recordJSON); err != nil {
 		if pg.IsNoRowsError(err) {
 			return nil, ErrRecordNotFound
 		}
@@ -78,5 +128,196 @@ func (r *Repository) GetRecord(ctx context.Context, id uuid.UUID) (*Record, erro
 	if err := json.Unmarshal(recordJSON, &schema); err != nil {
 		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, recordJSON)
 	}
-	return schema.Record(), nil
+	return schema.Record()
+}
+
+func (r *Repository) GetReferencedBy(ctx context.Context, req ReferencedByRequest) ([]RecordReference, error) {
+	args := []any{
+		req.RecordID,
+		pg.NullUUID(req.PropertyID),
+		pg.NullUUID(req.RefTypeID),
+		int(req.Limit),
+		int(req.Offset),
+	}
+	query := `SELECT * FROM get_referenced_by($1, $2, $3, $4, $5);`
+	rows, err := r.Query(ctx, query, args...)
+	if err != nil {
+		if errException, ok := pgExceptionAsDomainError(err); ok {
+			return nil, errException
+		}
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	out := make([]RecordReference, 0, req.Limit)
+	for rows.Next() {
+		var referenceJSON []byte
+		if err := rows.Scan(&referenceJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema RecordReferenceSchema
+		if err := json.Unmarshal(referenceJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, referenceJSON)
+		}
+		out = append(out, schema.RecordReference())
+	}
+	if err := rows.Err(); err != nil {
+		if errException, ok := pgExceptionAsDomainError(err); ok {
+			return nil, errException
+		}
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+func (r *Repository) GetRecordGraph(ctx context.Context, req GraphRequest) (*Graph, error) {
+	var graphJSON []byte
+	args := []any{
+		req.RecordID,
+		pg.ArrayUUID(req.PropertyIDs),
+		int(req.MaxDepth),
+		nil,
+		nil,
+	}
+	if req.Principal != nil {
+		args[3] = req.Principal.Subject
+		args[4] = req.Principal.Groups
+	}
+	query := `SELECT get_record_graph($1, $2, $3, $4, $5);`
+	if err := r.QueryRow(ctx, query, args...).Scan(&graphJSON); err != nil {
+		if errException, ok := pgExceptionAsDomainError(err); ok {
+			return nil, errException
+		}
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	var schema GraphSchema
+	if err := json.Unmarshal(graphJSON, &schema); err != nil {
+		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, graphJSON)
+	}
+	return schema.Graph(), nil
+}
+
+func (r *Repository) GetRecords(ctx context.Context, ids []uuid.UUID, withValues bool) ([]RecordWithValues, error) {
+	query := `SELECT * FROM get_records($1, $2);`
+	rows, err := r.Query(ctx, query, pg.ArrayUUID(ids), withValues)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	out := make([]RecordWithValues, 0, len(ids))
+	for rows.Next() {
+		var recordJSON []byte
+		if err := rows.Scan(&recordJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema RecordWithValuesSchema
+		if err := json.Unmarshal(recordJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, recordJSON)
+		}
+		record, err := schema.RecordWithValues()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+func (r *Repository) ListRecords(ctx context.Context, req ListRecordsRequest) ([]Record, error) {
+	var name any
+	if req.Filter.Name != "" {
+		name = req.Filter.Name
+	}
+	args := []any{
+		pg.NullUUID(req.RefTypeID),
+		req.Filter.DeletionMark,
+		name,
+		int(req.Limit),
+		int(req.Offset),
+		nil,
+		nil,
+	}
+	if req.Principal != nil {
+		args[5] = req.Principal.Subject
+		args[6] = req.Principal.Groups
+	}
+	query := `SELECT * FROM list_records($1, $2, $3, $4, $5, $6, $7);`
+	rows, err := r.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	out := make([]Record, 0, req.Limit)
+	for rows.Next() {
+		var recordJSON []byte
+		if err := rows.Scan(&recordJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema RecordSchema
+		if err := json.Unmarshal(recordJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, recordJSON)
+		}
+		record, err := schema.Record()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+// GetRecordACLs returns ACLs of the found records which have them.
+func (r *Repository) GetRecordACLs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]ACL, error) {
+	query := `SELECT * FROM get_record_acls($1);`
+	rows, err := r.Query(ctx, query, pg.ArrayUUID(ids))
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	out := make(map[uuid.UUID]ACL)
+	for rows.Next() {
+		var aclJSON []byte
+		if err := rows.Scan(&aclJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema RecordACLSchema
+		if err := json.Unmarshal(aclJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, aclJSON)
+		}
+		acl, err := aclFromSchema(schema.ACL)
+		if err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, aclJSON)
+		}
+		out[schema.RecordID] = acl
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+// SetRecordACL replaces the ACL of the record, the empty ACL removes the restriction.
+func (r *Repository) SetRecordACL(ctx context.Context, id uuid.UUID, acl ACL) (*Record, error) {
+	aclJSON, err := json.Marshal(aclToSchema(acl))
+	if err != nil {
+		return nil, fmt.Errorf("ACL marshal error: %s", err)
+	}
+	var recordJSON []byte
+	query := `SELECT set_record_acl($1, $2);`
+	if err := r.QueryRow(ctx, query, id, string(aclJSON)).Scan(&recordJSON); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	if recordJSON == nil {
+		return nil, ErrRecordNotFound
+	}
+	var schema RecordSchema
+	if err := json.Unmarshal(recordJSON, &schema); err != nil {
+		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, recordJSON)
+	}
+	return schema.Record()
 }
@@ -8,16 +8,21 @@ import (
 )
 
 type RecordSchema struct {
-	ID              uuid.UUID `json:"id"`
-	ReferenceTypeID uuid.UUID `json:"reference_type_id"`
-	Name            string    `json:"name"`
-	Description     string    `json:"description"`
-	DeletionMark    bool      `json:"deletion_mark"`
-	Sum             string    `json:"sum"`
-	ChangeAt        time.Time `json:"change_at"`
+	ID              uuid.UUID        `json:"id"`
+	ReferenceTypeID uuid.UUID        `json:"reference_type_id"`
+	Name            string           `json:"name"`
+	Description     string           `json:"description"`
+	DeletionMark    bool             `json:"deletion_mark"`
+	Sum             string           `json:"sum"`
+	ChangeAt        time.Time        `json:"change_at"`
+	ACL             []ACLEntrySchema `json:"acl"`
 }
 
-func (rs *RecordSchema) Record() *Record {
+func (rs *RecordSchema) Record() (*Record, error) {
+	acl, err := aclFromSchema(rs.ACL)
+	if err != nil {
+		return nil, err
+	}
 	return &Record{
 		ID:              rs.ID,
 		ReferenceTypeID: rs.ReferenceTypeID,
@@ -26,5 +31,163 @@ func (rs *RecordSchema) Record() *Record {
 		DeletionMark:    rs.DeletionMark,
 		Sum:             rs.Sum,
 		ChangeAt:        rs.ChangeAt.UTC(),
+		ACL:             acl,
+	}, nil
+}
+
+type ACLEntrySchema struct {
+	GranteeType string `json:"grantee_type"`
+	Grantee     string `json:"grantee"`
+	Access      string `json:"access"`
+}
+
+type RecordACLSchema struct {
+	RecordID uuid.UUID        `json:"record_id"`
+	ACL      []ACLEntrySchema `json:"acl"`
+}
+
+func aclFromSchema(in []ACLEntrySchema) (ACL, error) {
+	if len(in) == 0 {
+		return nil, nil
+	}
+	out := make(ACL, 0, len(in))
+	for _, e := range in {
+		granteeType, err := GranteeTypeFromCode(e.GranteeType)
+		if err != nil {
+			return nil, err
+		}
+		access, err := AccessFromCode(e.Access)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ACLEntry{GranteeType: granteeType, Grantee: e.Grantee, Access: access})
+	}
+	return out, nil
+}
+
+func aclToSchema(in ACL) []ACLEntrySchema {
+	out := make([]ACLEntrySchema, 0, len(in))
+	for _, e := range in {
+		out = append(out, ACLEntrySchema{
+			GranteeType: e.GranteeType.Code(),
+			Grantee:     e.Grantee,
+			Access:      e.Access.Code(),
+		})
+	}
+	return out
+}
+
+type RecordReferenceSchema struct {
+	RecordID   uuid.UUID `json:"record_id"`
+	PropertyID uuid.UUID `json:"property_id"`
+	RefTypeID  uuid.UUID `json:"reference_type_id"`
+}
+
+func (rrs *RecordReferenceSchema) RecordReference() RecordReference {
+	return RecordReference{
+		RecordID:   rrs.RecordID,
+		PropertyID: rrs.PropertyID,
+		RefTypeID:  rrs.RefTypeID,
+	}
+}
+
+type GraphSchema struct {
+	Nodes     []GraphNodeSchema `json:"nodes"`
+	Edges     []GraphEdgeSchema `json:"edges"`
+	Truncated bool              `json:"truncated"`
+}
+
+type GraphNodeSchema struct {
+	RecordID  uuid.UUID `json:"record_id"`
+	RefTypeID uuid.UUID `json:"reference_type_id"`
+	Depth     uint      `json:"depth"`
+}
+
+type GraphEdgeSchema struct {
+	From       uuid.UUID `json:"from"`
+	To         uuid.UUID `json:"to"`
+	PropertyID uuid.UUID `json:"property_id"`
+	Cycle      bool      `json:"cycle"`
+}
+
+func (gs *GraphSchema) Graph() *Graph {
+	out := &Graph{
+		Nodes:     make([]GraphNode, 0, len(gs.Nodes)),
+		Edges:     make([]GraphEdge, 0, len(gs.Edges)),
+		Truncated: gs.Truncated,
+	}
+	for _, n := range gs.Nodes {
+		out.Nodes = append(out.Nodes, GraphNode{
+			RecordID:  n.RecordID,
+			RefTypeID: n.RefTypeID,
+			Depth:     n.Depth,
+		})
+	}
+	for _, e := range gs.Edges {
+		out.Edges = append(out.Edges, GraphEdge{
+			From:       e.From,
+			To:         e.To,
+			PropertyID: e.PropertyID,
+			Cycle:      e.Cycle,
+		})
+	}
+	return out
+}
+
+type RecordWithValuesSchema struct {
+	RecordSchema
+	Values []ValueSchema `json:"values"`
+}
+
+func (rs *RecordWithValuesSchema) RecordWithValues() (*RecordWithValues, error) {
+	record, err := rs.Record()
+	if err != nil {
+		return nil, err
+	}
+	out := &RecordWithValues{Record: *record}
+	if rs.Values == nil {
+		return out, nil
+	}
+	out.Values = make([]Value, 0, len(rs.Values))
+	for _, vs := range rs.Values {
+		value, err := vs.Value()
+		if err != nil {
+			return nil, err
+		}
+		out.Values = append(out.Values, *value)
+	}
+	return out, nil
+}
+
+type TableValueSchema struct {
+	ValueSchema
+	RefName *string `json:"ref_name"`
+}
+
+type TableRowSchema struct {
+	RecordSchema
+	Values []TableValueSchema `json:"values"`
+}
+
+func (trs *TableRowSchema) TableRow() (*TableRow, error) {
+	record, err := trs.Record()
+	if err != nil {
+		return nil, err
+	}
+	out := &TableRow{
+		Record: *record,
+		Values: make([]TableValue, 0, len(trs.Values)),
+	}
+	for _, vs := range trs.Values {
+		value, err := vs.Value()
+		if err != nil {
+			return nil, err
+		}
+		tv := TableValue{Value: *value}
+		if vs.RefName != nil {
+			tv.RefName = *vs.RefName
+		}
+		out.Values = append(out.Values, tv)
 	}
+	return out, nil
 }
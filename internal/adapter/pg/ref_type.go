@@ -16,6 +16,16 @@ func (r *Repository) AddRefType(ctx context.Context, req AddRefTypeRequest) (uui
 		req.Name,
 		req.Description,
 	}
+	if req.ID != uuid.Nil {
+		query := `SELECT insert_ref_type($1, $2, $3);`
+		if err := r.QueryRow(ctx, query, append([]any{req.ID}, args...)...).Scan(&out); err != nil {
+			if pg.IsNotUniqueError(err) {
+				return out, ErrRefTypeAlreadyExists
+			}
+			return out, fmt.Errorf("database error: %w, %s", err, query)
+		}
+		return out, nil
+	}
 	query := `SELECT new_ref_type($1, $2);`
 	for attempts := 0; attempts < getUUIDAttemptsThreshold; attempts++ {
 		if err := r.QueryRow(ctx, query, args...).Scan(&out); err != nil {
@@ -30,10 +40,10 @@ func (r *Repository) AddRefType(ctx context.Context, req AddRefTypeRequest) (uui
 }
 
 func (r *Repository) UpdateRefType(ctx context.Context, req UpdRefTypeRequest) (*RefType, error) {
-	var out RefType
 	emptyReq := true
-	args := make([]any, 3)
+	args := make([]any, 4)
 	args[0] = req.ID
+	args[3] = pg.NullString(req.ExpectedSum)
 	if req.Name != nil {
 		args[1] = *req.Name
 		emptyReq = false
@@ -43,16 +53,28 @@ func (r *Repository) UpdateRefType(ctx context.Context, req UpdRefTypeRequest) (
 		emptyReq = false
 	}
 	if emptyReq {
-		return r.GetRefType(ctx, req.ID)
+		out, err := r.GetRefType(ctx, req.ID)
+		if err == nil && req.ExpectedSum != "" && out.Sum != req.ExpectedSum {
+			return nil, ErrSumMismatch
+		}
+		return out, err
 	}
-	query := `SELECT * FROM update_ref_type($1, $2, $3);`
-	if err := r.QueryRow(ctx, query, args...).Scan(&out.ID, &out.Name, &out.Description); err != nil {
+	var refTypeJSON []byte
+	query := `SELECT * FROM update_ref_type($1, $2, $3, $4);`
+	if err := r.QueryRow(ctx, query, args...).Scan(&refTypeJSON); err != nil {
 		if pg.IsNoRowsError(err) {
 			return nil, ErrRefTypeNotFound
 		}
+		if errException, ok := pgExceptionAsDomainError(err); ok {
+			return nil, errException
+		}
 		return nil, fmt.Errorf("database error: %w, %s", err, query)
 	}
-	return &out, nil
+	var schema RefTypeSchema
+	if err := json.Unmarshal(refTypeJSON, &schema); err != nil {
+		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, refTypeJSON)
+	}
+	return schema.RefType(), nil
 }
 
 func (r *Repository) GetRefType(ctx context.Context, id uuid.UUID) (*RefType, error) {
@@ -70,3 +92,28 @@ func (r *Repository) GetRefType(ctx context.Context, id uuid.UUID) (*RefType, er
 	}
 	return schema.RefType(), nil
 }
+
+func (r *Repository) GetRefTypes(ctx context.Context, ids []uuid.UUID) ([]RefType, error) {
+	query := `SELECT * FROM get_ref_types($1);`
+	rows, err := r.Query(ctx, query, pg.ArrayUUID(ids))
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	out := make([]RefType, 0, len(ids))
+	for rows.Next() {
+		var refTypeJSON []byte
+		if err := rows.Scan(&refTypeJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema RefTypeSchema
+		if err := json.Unmarshal(refTypeJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, refTypeJSON)
+		}
+		out = append(out, *schema.RefType())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
@@ -0,0 +1,89 @@
+package pg
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ExportTable reads columns and rows from the one snapshot and passes them to the writer.
+func (r *Repository) ExportTable(ctx context.Context, req TableRequest, w TableWriter) error {
+	tx, err := r.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("begin transaction error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	columns, err := getTableColumns(ctx, tx, req)
+	if err != nil {
+		return err
+	}
+	if err := w.WriteHeader(columns); err != nil {
+		return err
+	}
+	var name any
+	if req.Filter.Name != "" {
+		name = req.Filter.Name
+	}
+	query := `SELECT * FROM get_table_rows($1, $2, $3);`
+	rows, err := tx.Query(ctx, query, req.RefTypeID, req.Filter.DeletionMark, name)
+	if err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rowJSON []byte
+		if err := rows.Scan(&rowJSON); err != nil {
+			return fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema TableRowSchema
+		if err := json.Unmarshal(rowJSON, &schema); err != nil {
+			return fmt.Errorf("db result unmarshal error: %s, %s", err, rowJSON)
+		}
+		row, err := schema.TableRow()
+		if err != nil {
+			return err
+		}
+		if err := w.WriteRow(*row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return tx.Commit(ctx)
+}
+
+func getTableColumns(ctx context.Context, tx pgx.Tx, req TableRequest) ([]Property, error) {
+	query := `SELECT * FROM get_table_columns($1);`
+	rows, err := tx.Query(ctx, query, req.RefTypeID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	out := []Property{}
+	for rows.Next() {
+		var propertyJSON []byte
+		if err := rows.Scan(&propertyJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema PropertySchema
+		if err := json.Unmarshal(propertyJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, propertyJSON)
+		}
+		property, err := schema.Property()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *property)
+	}
+	if err := rows.Err(); err != nil {
+		if errException, ok := pgExceptionAsDomainError(err); ok {
+			return nil, errException
+		}
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
@@ -52,3 +52,29 @@ func funcQueryRow(r *Repository, t db.Transaction) (func(context.Context, string
 	}
 	return tx.QueryRow, nil
 }
+
+// scanRowAttempt scans the row of the query which may be attempted again. Within the transaction the attempt
+// goes in the savepoint, so its failure is rolled back to it and does not abort the transaction.
+func scanRowAttempt(ctx context.Context, r *Repository, t db.Transaction, query string, args []any, dest ...any) error {
+	if t == nil {
+		return r.QueryRow(ctx, query, args...).Scan(dest...)
+	}
+	tx, err := unwrapTransaction(t)
+	if err != nil {
+		return err
+	}
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin savepoint error: %w", err)
+	}
+	if err := sp.QueryRow(ctx, query, args...).Scan(dest...); err != nil {
+		if errRollback := sp.Rollback(ctx); errRollback != nil {
+			return fmt.Errorf("rollback savepoint error: %w", errRollback)
+		}
+		return err
+	}
+	if err := sp.Commit(ctx); err != nil {
+		return fmt.Errorf("release savepoint error: %w", err)
+	}
+	return nil
+}
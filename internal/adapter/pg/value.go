@@ -3,12 +3,15 @@ package pg
 import (
 	"context"
 	. "datatom/internal/domain"
+	"datatom/pkg/db"
 	"datatom/pkg/db/pg"
 	"encoding/json"
 	"fmt"
+
+	"github.com/google/uuid"
 )
 
-func (r *Repository) SetValue(ctx context.Context, req SetValueRequest) (*Value, error) {
+func (r *Repository) SetValue(ctx context.Context, req SetValueRequest, tx db.Transaction) (*Value, error) {
 	value, err := ValueAsJSON(req.Value, req.Type)
 	if err != nil {
 		return nil, err
@@ -20,9 +23,14 @@ func (r *Repository) SetValue(ctx context.Context, req SetValueRequest) (*Value,
 		req.Type.Code(),
 		pg.NullUUID(req.RefTypeID),
 		string(value),
+		pg.NullString(req.ExpectedSum),
 	}
-	query := `SELECT set_value($1, $2, $3, $4, $5);`
-	if err := r.QueryRow(ctx, query, args...).Scan(&valueJSON); err != nil {
+	query := `SELECT set_value($1, $2, $3, $4, $5, $6);`
+	queryRow, err := funcQueryRow(r, tx)
+	if err != nil {
+		return nil, fmt.Errorf("transaction error: %w", err)
+	}
+	if err := queryRow(ctx, query, args...).Scan(&valueJSON); err != nil {
 		if errException, ok := pgExceptionAsDomainError(err); ok {
 			return nil, errException
 		}
@@ -55,6 +63,46 @@ func (r *Repository) GetValue(ctx context.Context, req GetValueRequest) (*Value,
 	return schema.Value()
 }
 
+// IsEncryptedProperty returns false for missing properties, values of them are rejected by set_value.
+func (r *Repository) IsEncryptedProperty(ctx context.Context, id uuid.UUID, tx db.Transaction) (bool, error) {
+	var out bool
+	query := `SELECT encrypted FROM properties WHERE id = $1;`
+	queryRow, err := funcQueryRow(r, tx)
+	if err != nil {
+		return false, fmt.Errorf("transaction error: %w", err)
+	}
+	if err := queryRow(ctx, query, id).Scan(&out); err != nil {
+		if pg.IsNoRowsError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+func (r *Repository) GetStateTransitions(ctx context.Context, req GetValueRequest) (*StateTransitions, error) {
+	var transitionsJSON []byte
+	args := []any{
+		req.RecordID,
+		req.PropertyID,
+	}
+	query := `SELECT get_state_transitions($1, $2);`
+	if err := r.QueryRow(ctx, query, args...).Scan(&transitionsJSON); err != nil {
+		if pg.IsNoRowsError(err) {
+			return nil, fmt.Errorf("record or property %w", ErrNotFound)
+		}
+		if errException, ok := pgExceptionAsDomainError(err); ok {
+			return nil, errException
+		}
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	var schema StateTransitionsSchema
+	if err := json.Unmarshal(transitionsJSON, &schema); err != nil {
+		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, transitionsJSON)
+	}
+	return schema.StateTransitions(), nil
+}
+
 func (r *Repository) ChangedValues(ctx context.Context) ([]Value, error) {
 	query := `SELECT * FROM get_changed_values();`
 	rows, err := r.Query(ctx, query)
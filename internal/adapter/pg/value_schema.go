@@ -19,7 +19,7 @@ type ValueSchema struct {
 
 func (vs *ValueSchema) Value() (*Value, error) {
 	tp := TypeFromCode(vs.Type)
-	value, err := ValidatedValue(vs.Val.V, tp)
+	value, err := vs.Val.Validated(tp)
 	if err != nil {
 		return nil, err
 	}
@@ -33,3 +33,19 @@ func (vs *ValueSchema) Value() (*Value, error) {
 		ChangeAt:   vs.ChangeAt.UTC(),
 	}, nil
 }
+
+type StateTransitionsSchema struct {
+	RecordID    uuid.UUID `json:"record_id"`
+	PropertyID  uuid.UUID `json:"property_id"`
+	State       *string   `json:"state"`
+	Transitions []string  `json:"transitions"`
+}
+
+func (sts *StateTransitionsSchema) StateTransitions() *StateTransitions {
+	return &StateTransitions{
+		RecordID:    sts.RecordID,
+		PropertyID:  sts.PropertyID,
+		State:       sts.State,
+		Transitions: sts.Transitions,
+	}
+}
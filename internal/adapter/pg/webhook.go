@@ -0,0 +1,168 @@
+package pg
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"datatom/pkg/db/pg"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (r *Repository) AddWebhook(ctx context.Context, wh Webhook) (uuid.UUID, error) {
+	var out uuid.UUID
+	args := []any{
+		wh.URL,
+		wh.Secret,
+		changeTypesToCodes(wh.Filter.ChangeTypes),
+		pg.ArrayUUID(wh.Filter.RefTypeIDs),
+		pg.ArrayUUID(wh.Filter.PropertyIDs),
+	}
+	query := `SELECT new_webhook($1, $2, $3, $4, $5);`
+	if err := r.QueryRow(ctx, query, args...).Scan(&out); err != nil {
+		return out, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+// UpdateWebhook keeps the secret if it is empty.
+func (r *Repository) UpdateWebhook(ctx context.Context, wh Webhook) error {
+	var updated bool
+	args := []any{
+		wh.ID,
+		wh.URL,
+		pg.NullString(wh.Secret),
+		changeTypesToCodes(wh.Filter.ChangeTypes),
+		pg.ArrayUUID(wh.Filter.RefTypeIDs),
+		pg.ArrayUUID(wh.Filter.PropertyIDs),
+		wh.Enabled,
+	}
+	query := `SELECT update_webhook($1, $2, $3, $4, $5, $6, $7);`
+	if err := r.QueryRow(ctx, query, args...).Scan(&updated); err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	if !updated {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *Repository) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	var deleted bool
+	query := `SELECT delete_webhook($1);`
+	if err := r.QueryRow(ctx, query, id).Scan(&deleted); err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *Repository) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	return r.getWebhooks(ctx, `SELECT * FROM get_webhooks();`)
+}
+
+func (r *Repository) GetDueWebhooks(ctx context.Context) ([]Webhook, error) {
+	return r.getWebhooks(ctx, `SELECT * FROM get_due_webhooks();`)
+}
+
+func (r *Repository) getWebhooks(ctx context.Context, query string) ([]Webhook, error) {
+	rows, err := r.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	var out []Webhook
+	for rows.Next() {
+		var webhookJSON []byte
+		if err := rows.Scan(&webhookJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema WebhookSchema
+		if err := json.Unmarshal(webhookJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, webhookJSON)
+		}
+		out = append(out, *schema.Webhook())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+func (r *Repository) GetWebhook(ctx context.Context, id uuid.UUID) (*Webhook, error) {
+	var webhookJSON []byte
+	query := `SELECT get_webhook($1);`
+	if err := r.QueryRow(ctx, query, id).Scan(&webhookJSON); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	if webhookJSON == nil {
+		return nil, ErrWebhookNotFound
+	}
+	var schema WebhookSchema
+	if err := json.Unmarshal(webhookJSON, &schema); err != nil {
+		return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, webhookJSON)
+	}
+	return schema.Webhook(), nil
+}
+
+func (r *Repository) SetWebhookState(ctx context.Context, id uuid.UUID, state WebhookState, d *WebhookDelivery) error {
+	args := []any{
+		id,
+		state.LastChangeID,
+		state.Failures,
+		pgtype.Timestamptz{Time: state.NextAttemptAt, Valid: !state.NextAttemptAt.IsZero()},
+		pg.NullString(state.DisabledReason),
+		nil, nil, nil, nil, nil,
+	}
+	if d != nil {
+		args[5] = d.ChangeID
+		args[6] = d.Attempt
+		args[7] = pgtype.Int4{Int32: int32(d.StatusCode), Valid: d.StatusCode != 0}
+		args[8] = pg.NullString(d.Error)
+		args[9] = int(d.Duration.Milliseconds())
+	}
+	query := `SELECT set_webhook_state($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`
+	if _, err := r.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return nil
+}
+
+func (r *Repository) GetWebhookDeliveries(ctx context.Context, id uuid.UUID, limit uint) ([]WebhookDelivery, error) {
+	query := `SELECT * FROM get_webhook_deliveries($1, $2);`
+	rows, err := r.Query(ctx, query, id, int(limit))
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	defer rows.Close()
+	out := make([]WebhookDelivery, 0, limit)
+	for rows.Next() {
+		var deliveryJSON []byte
+		if err := rows.Scan(&deliveryJSON); err != nil {
+			return nil, fmt.Errorf("database scan error: %w, %s", err, query)
+		}
+		var schema WebhookDeliverySchema
+		if err := json.Unmarshal(deliveryJSON, &schema); err != nil {
+			return nil, fmt.Errorf("db result unmarshal error: %s, %s", err, deliveryJSON)
+		}
+		out = append(out, schema.WebhookDelivery())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
+
+func (r *Repository) PurgeWebhookDeliveries(ctx context.Context, retention time.Duration) (int64, error) {
+	var out int64
+	query := `SELECT purge_webhook_deliveries($1);`
+	if err := r.QueryRow(ctx, query, retention).Scan(&out); err != nil {
+		return 0, fmt.Errorf("database error: %w, %s", err, query)
+	}
+	return out, nil
+}
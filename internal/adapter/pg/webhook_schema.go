@@ -0,0 +1,82 @@
+package pg
+
+import (
+	"datatom/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookSchema struct {
+	ID             uuid.UUID   `json:"id"`
+	URL            string      `json:"url"`
+	Secret         *string     `json:"secret"`
+	ChangeTypes    []string    `json:"change_types"`
+	RefTypeIDs     []uuid.UUID `json:"reference_type_ids"`
+	PropertyIDs    []uuid.UUID `json:"property_ids"`
+	Enabled        bool        `json:"enabled"`
+	LastChangeID   int64       `json:"last_change_id"`
+	Failures       int         `json:"failures"`
+	NextAttemptAt  *time.Time  `json:"next_attempt_at"`
+	DisabledReason *string     `json:"disabled_reason"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+func (ws *WebhookSchema) Webhook() *domain.Webhook {
+	out := &domain.Webhook{
+		ID:  ws.ID,
+		URL: ws.URL,
+		Filter: domain.ChangeFilter{
+			ChangeTypes: changeTypesFromCodes(ws.ChangeTypes),
+			RefTypeIDs:  ws.RefTypeIDs,
+			PropertyIDs: ws.PropertyIDs,
+		},
+		Enabled: ws.Enabled,
+		WebhookState: domain.WebhookState{
+			LastChangeID: ws.LastChangeID,
+			Failures:     ws.Failures,
+		},
+		CreatedAt: ws.CreatedAt.UTC(),
+		UpdatedAt: ws.UpdatedAt.UTC(),
+	}
+	if ws.Secret != nil {
+		out.Secret = *ws.Secret
+	}
+	if ws.NextAttemptAt != nil {
+		out.NextAttemptAt = ws.NextAttemptAt.UTC()
+	}
+	if ws.DisabledReason != nil {
+		out.DisabledReason = *ws.DisabledReason
+	}
+	return out
+}
+
+type WebhookDeliverySchema struct {
+	ID          int64     `json:"id"`
+	WebhookID   uuid.UUID `json:"webhook_id"`
+	ChangeID    int64     `json:"change_id"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  *int      `json:"status_code"`
+	Error       *string   `json:"error"`
+	DurationMS  int64     `json:"duration_ms"`
+	DeliveredAt time.Time `json:"delivered_at"`
+}
+
+func (ds *WebhookDeliverySchema) WebhookDelivery() domain.WebhookDelivery {
+	out := domain.WebhookDelivery{
+		ID:          ds.ID,
+		WebhookID:   ds.WebhookID,
+		ChangeID:    ds.ChangeID,
+		Attempt:     ds.Attempt,
+		Duration:    time.Duration(ds.DurationMS) * time.Millisecond,
+		DeliveredAt: ds.DeliveredAt.UTC(),
+	}
+	if ds.StatusCode != nil {
+		out.StatusCode = *ds.StatusCode
+	}
+	if ds.Error != nil {
+		out.Error = *ds.Error
+	}
+	return out
+}
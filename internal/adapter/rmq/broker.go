@@ -1,23 +1,63 @@
 package rmq
 
 import (
+	"datatom/internal/domain"
 	"datatom/pkg/message_broker/rmq"
+	"fmt"
+
 	"go.uber.org/zap"
 )
 
+type EncryptedValuesMode uint
+
+// Values of encrypted properties are sent masked unless consumers hold the keyring to decrypt them.
+const (
+	EncryptedValuesMasked EncryptedValuesMode = iota
+	EncryptedValuesCiphertext
+)
+
+func (m EncryptedValuesMode) String() string {
+	switch m {
+	case EncryptedValuesMasked:
+		return "masked"
+	case EncryptedValuesCiphertext:
+		return "ciphertext"
+	default:
+		return "unknown"
+	}
+}
+
+func (m EncryptedValuesMode) Code() string {
+	return m.String()
+}
+
+func EncryptedValuesModeFromCode(code string) (EncryptedValuesMode, error) {
+	switch code {
+	case "masked", "":
+		return EncryptedValuesMasked, nil
+	case "ciphertext":
+		return EncryptedValuesCiphertext, nil
+	default:
+		return EncryptedValuesMasked, fmt.Errorf(`%w "%s" of encrypted values mode`, domain.ErrUnknownType, code)
+	}
+}
+
 type Config struct {
-	Publisher *rmq.Publisher
-	Logger    *zap.SugaredLogger
+	Publisher       *rmq.Publisher
+	Logger          *zap.SugaredLogger
+	EncryptedValues EncryptedValuesMode
 }
 
 type Broker struct {
-	publisher *rmq.Publisher
-	l         *zap.SugaredLogger
+	publisher       *rmq.Publisher
+	l               *zap.SugaredLogger
+	encryptedValues EncryptedValuesMode
 }
 
 func NewBroker(c Config) (*Broker, error) {
 	return &Broker{
-		publisher: c.Publisher,
-		l:         c.Logger,
+		publisher:       c.Publisher,
+		l:               c.Logger,
+		encryptedValues: c.EncryptedValues,
 	}, nil
 }
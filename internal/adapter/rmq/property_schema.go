@@ -12,6 +12,23 @@ type PropertySchema struct {
 	Types          []string `json:"types"`
 	RefTypeIDs     []string `json:"reference_type_ids"`
 	OwnerRefTypeID *string  `json:"owner_reference_type_id"`
+	Kind           string   `json:"kind"`
+	Encrypted      bool     `json:"encrypted"`
+	// Mask lets consumers mask values sent as they are like the service does in responses
+	Mask *PropertyMaskSchema `json:"mask,omitempty"`
+}
+
+type PropertyMaskSchema struct {
+	Kind          string   `json:"kind"`
+	Reveal        uint     `json:"reveal"`
+	UnmaskedRoles []string `json:"unmasked_roles"`
+}
+
+func propertyMaskToSchema(mp *domain.MaskPolicy) *PropertyMaskSchema {
+	if mp == nil {
+		return nil
+	}
+	return &PropertyMaskSchema{Kind: mp.Kind.Code(), Reveal: mp.Reveal, UnmaskedRoles: mp.UnmaskedRoles}
 }
 
 func propertyToSchema(p domain.Property) PropertySchema {
@@ -34,5 +51,8 @@ func propertyToSchema(p domain.Property) PropertySchema {
 		Types:          domain.TypesToCodes(p.Types),
 		RefTypeIDs:     refTypeIDs,
 		OwnerRefTypeID: ownerRefTypeID,
+		Kind:           p.Kind.Code(),
+		Encrypted:      p.Encrypted,
+		Mask:           propertyMaskToSchema(p.Mask),
 	}
 }
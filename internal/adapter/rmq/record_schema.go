@@ -5,12 +5,21 @@ import (
 	"datatom/pkg/helper"
 )
 
+// RecordSchema carries the ACL of the record so consumers are able to enforce it,
+// the ACL is omitted if the record is not restricted.
 type RecordSchema struct {
-	ID              string  `json:"id"`
-	Name            string  `json:"name"`
-	Description     string  `json:"description"`
-	DeletionMark    bool    `json:"deletion_mark"`
-	ReferenceTypeID *string `json:"reference_type_id"`
+	ID              string           `json:"id"`
+	Name            string           `json:"name"`
+	Description     string           `json:"description"`
+	DeletionMark    bool             `json:"deletion_mark"`
+	ReferenceTypeID *string          `json:"reference_type_id"`
+	ACL             []ACLEntrySchema `json:"acl,omitempty"`
+}
+
+type ACLEntrySchema struct {
+	GranteeType string `json:"grantee_type"`
+	Grantee     string `json:"grantee"`
+	Access      string `json:"access"`
 }
 
 func recordToSchema(r domain.Record) RecordSchema {
@@ -25,5 +34,21 @@ func recordToSchema(r domain.Record) RecordSchema {
 		Description:     r.Description,
 		DeletionMark:    r.DeletionMark,
 		ReferenceTypeID: refTypeID,
+		ACL:             aclToSchema(r.ACL),
+	}
+}
+
+func aclToSchema(acl domain.ACL) []ACLEntrySchema {
+	if len(acl) == 0 {
+		return nil
+	}
+	out := make([]ACLEntrySchema, 0, len(acl))
+	for _, e := range acl {
+		out = append(out, ACLEntrySchema{
+			GranteeType: e.GranteeType.Code(),
+			Grantee:     e.Grantee,
+			Access:      e.Access.Code(),
+		})
 	}
+	return out
 }
@@ -8,8 +8,13 @@ import (
 	rmq "github.com/wagslane/go-rabbitmq"
 )
 
+// ValueMessage is the body of the published value, values of encrypted properties are sent by the mode.
+func ValueMessage(req domain.SendValueRequest, mode EncryptedValuesMode) ([]byte, error) {
+	return json.Marshal(valueToSchema(req.Value, req.ACL, mode))
+}
+
 func (b *Broker) SendValue(ctx context.Context, req domain.SendValueRequest) error {
-	msg, err := json.Marshal(valueToSchema(req.Value))
+	msg, err := ValueMessage(req, b.encryptedValues)
 	if err != nil {
 		return err
 	}
@@ -5,25 +5,40 @@ import (
 	"datatom/pkg/helper"
 )
 
+// ValueSchema carries the ACL of the owner record. Values of encrypted properties are null,
+// they are either masked or sent as envelopes in Encrypted.
 type ValueSchema struct {
-	RecordID        string  `json:"record_id"`
-	PropertyID      string  `json:"property_id"`
-	Type            string  `json:"type"`
-	ReferenceTypeID *string `json:"reference_type_id"`
-	Value           any     `json:"value"`
+	RecordID        string                       `json:"record_id"`
+	PropertyID      string                       `json:"property_id"`
+	Type            string                       `json:"type"`
+	ReferenceTypeID *string                      `json:"reference_type_id"`
+	Value           any                          `json:"value"`
+	Masked          bool                         `json:"masked,omitempty"`
+	Encrypted       *domain.EncryptedValueSchema `json:"encrypted,omitempty"`
+	ACL             []ACLEntrySchema             `json:"acl,omitempty"`
 }
 
-func valueToSchema(v domain.Value) ValueSchema {
+func valueToSchema(v domain.Value, acl domain.ACL, mode EncryptedValuesMode) ValueSchema {
 	var referenceTypeID *string
 	if !helper.IsZeroUUID(v.RefTypeID) {
 		rtID := v.RefTypeID.String()
 		referenceTypeID = &rtID
 	}
-	return ValueSchema{
+	out := ValueSchema{
 		RecordID:        v.RecordID.String(),
 		PropertyID:      v.PropertyID.String(),
 		Type:            v.Type.Code(),
 		ReferenceTypeID: referenceTypeID,
 		Value:           v.Value,
+		ACL:             aclToSchema(acl),
 	}
+	if ev, ok := v.Value.(domain.EncryptedValue); ok {
+		out.Value = nil
+		if mode == EncryptedValuesCiphertext {
+			out.Encrypted = domain.EncryptedValueToSchema(ev)
+		} else {
+			out.Masked = true
+		}
+	}
+	return out
 }
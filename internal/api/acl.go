@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// GetACLs returns ACLs of the records which have them, e.g. to send them with values of the records.
+func (rm *RecordManager) GetACLs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]ACL, error) {
+	ctx, cancel := context.WithTimeout(ctx, rm.Timeout)
+	defer cancel()
+	return rm.Repository.GetRecordACLs(ctx, ids)
+}
+
+// SetACL replaces the ACL of the record. The principal needs the write access to the record by its current ACL,
+// so the principal is able to restrict the record but not to take it over.
+func (rm *RecordManager) SetACL(ctx context.Context, id uuid.UUID, acl ACL) (*Record, error) {
+	ctx, cancel := context.WithTimeout(ctx, rm.Timeout)
+	defer cancel()
+	if err := authorizeRecord(ctx, rm.Repository, id, AccessWrite); err != nil {
+		return nil, err
+	}
+	return rm.Repository.SetRecordACL(ctx, id, acl)
+}
+
+// authorizeRecord checks the ACL of the record against the principal of the context. Records which are hidden
+// from the principal are not found, so their IDs do not reveal them. Missing records are left to the caller.
+func authorizeRecord(ctx context.Context, repo RecordRepository, id uuid.UUID, access Access) error {
+	p := PrincipalFromContext(ctx)
+	if p == nil {
+		return nil
+	}
+	acls, err := repo.GetRecordACLs(ctx, []uuid.UUID{id})
+	if err != nil {
+		return err
+	}
+	acl := acls[id]
+	if acl.Allows(p, access) {
+		return nil
+	}
+	if !acl.Allows(p, AccessRead) {
+		return ErrRecordNotFound
+	}
+	return fmt.Errorf("%w: %s access to record %s is not granted to %s by its ACL", ErrForbidden, access, id, p.Subject)
+}
+
+// readableRecordIDs returns the IDs of the records the principal of the context may read,
+// nil is returned if the principal is not set so nothing is filtered.
+func readableRecordIDs(ctx context.Context, repo RecordRepository, ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	p := PrincipalFromContext(ctx)
+	if p == nil || len(ids) == 0 {
+		return nil, nil
+	}
+	acls, err := repo.GetRecordACLs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		out[id] = acls[id].Allows(p, AccessRead)
+	}
+	return out, nil
+}
+
+// readableRecords filters records by their ACLs which are read with them.
+func readableRecords(ctx context.Context, records []RecordWithValues) []RecordWithValues {
+	p := PrincipalFromContext(ctx)
+	if p == nil {
+		return records
+	}
+	out := records[:0]
+	for _, r := range records {
+		if r.ACL.Allows(p, AccessRead) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// aclTableWriter skips rows of records which are hidden from the principal.
+type aclTableWriter struct {
+	TableWriter
+	principal *Principal
+}
+
+func (w aclTableWriter) WriteRow(row TableRow) error {
+	if !row.ACL.Allows(w.principal, AccessRead) {
+		return nil
+	}
+	return w.TableWriter.WriteRow(row)
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type principalContextKey struct{}
+
+// ContextWithPrincipal returns the context of the request made by the authenticated principal.
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+// PrincipalFromContext returns nil if the request is not authenticated.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return p
+}
+
+type AuthManager struct {
+	AuthConfig
+	roles map[string]Role
+}
+
+// AuthConfig of the manager. Authentication is disabled if there are no authenticators, so requests
+// are served anonymously. Records and properties are read to find reference types requests are scoped by.
+type AuthConfig struct {
+	Authenticators  []Authenticator
+	Roles           []Role
+	RecordManager   *RecordManager
+	PropertyManager *PropertyManager
+}
+
+func NewAuthManager(c AuthConfig) (*AuthManager, error) {
+	if c.RecordManager == nil {
+		return nil, fmt.Errorf("record manager can not be nil")
+	}
+	if c.PropertyManager == nil {
+		return nil, fmt.Errorf("property manager can not be nil")
+	}
+	roles := make(map[string]Role, len(c.Roles))
+	for _, r := range c.Roles {
+		if _, ok := roles[r.Name]; ok {
+			return nil, fmt.Errorf("role %s is duplicated", r.Name)
+		}
+		roles[r.Name] = r
+	}
+	return &AuthManager{AuthConfig: c, roles: roles}, nil
+}
+
+func (am *AuthManager) Enabled() bool {
+	return len(am.Authenticators) != 0
+}
+
+// Authenticate returns the principal of the first authenticator which accepts the credentials.
+func (am *AuthManager) Authenticate(ctx context.Context, c Credentials) (*Principal, error) {
+	if c.IsEmpty() {
+		return nil, fmt.Errorf("%w: credentials expected", ErrUnauthenticated)
+	}
+	for _, a := range am.Authenticators {
+		p, err := a.Authenticate(ctx, c)
+		if err != nil {
+			if errors.Is(err, ErrUnauthenticated) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %s", ErrUnauthenticated, err)
+		}
+		if p != nil {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: credentials are not accepted", ErrUnauthenticated)
+}
+
+// Authorize checks grants of roles of the principal, requests without the principal are made
+// by the service itself or served while authentication is disabled.
+func (am *AuthManager) Authorize(_ context.Context, p *Principal, perm Permission) error {
+	if p == nil {
+		return nil
+	}
+	for _, name := range p.Roles {
+		for _, g := range am.roles[name].Grants {
+			if g.Allows(perm) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s is not granted to %s", ErrForbidden, perm, p.Subject)
+}
+
+// RecordPermission is scoped by the reference type of the record. Requests to missing records
+// are not scoped, so they are allowed by grants for all reference types only.
+// The record is read regardless of its ACL which is checked by the record manager.
+func (am *AuthManager) RecordPermission(ctx context.Context, op Operation, access Access, id uuid.UUID) (Permission, error) {
+	out := Permission{Operation: op, Access: access}
+	r, err := am.RecordManager.Get(ContextWithPrincipal(ctx, nil), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return out, nil
+		}
+		return out, err
+	}
+	out.RefTypeID = r.ReferenceTypeID
+	return out, nil
+}
+
+// PropertyPermission is scoped by the owner reference type of the property.
+// Properties without the owner are shared by reference types, so they are not scoped.
+func (am *AuthManager) PropertyPermission(ctx context.Context, op Operation, access Access, id uuid.UUID) (Permission, error) {
+	out := Permission{Operation: op, Access: access}
+	p, err := am.PropertyManager.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return out, nil
+		}
+		return out, err
+	}
+	out.RefTypeID = p.OwnerRefTypeID
+	return out, nil
+}
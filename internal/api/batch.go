@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"datatom/pkg/db"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultBatchManagerTimeout = time.Second
+
+// BatchManager applies writes of a batch within the one transaction.
+// Timeout limits every single operation but not the whole batch.
+// Operations are authorized by reference types they touch like the same requests out of batches are.
+type BatchManager struct {
+	BatchConfig
+}
+
+type BatchConfig struct {
+	DBManager          *DBManager
+	RecordRepository   RecordRepository
+	PropertyRepository PropertyRepository
+	ValueRepository    ValueRepository
+	AuthManager        *AuthManager
+	// Cipher encrypts values of encrypted properties if it is set
+	Cipher  ValueCipher
+	Timeout time.Duration
+}
+
+func NewBatchManager(c BatchConfig) (*BatchManager, error) {
+	if c.DBManager == nil {
+		return nil, fmt.Errorf("DB manager can not be nil")
+	}
+	if c.RecordRepository == nil {
+		return nil, fmt.Errorf("record repository can not be nil")
+	}
+	if c.PropertyRepository == nil {
+		return nil, fmt.Errorf("property repository can not be nil")
+	}
+	if c.ValueRepository == nil {
+		return nil, fmt.Errorf("value repository can not be nil")
+	}
+	if c.AuthManager == nil {
+		return nil, fmt.Errorf("auth manager can not be nil")
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultBatchManagerTimeout
+	}
+	return &BatchManager{c}, nil
+}
+
+func (bm *BatchManager) BeginTransaction(ctx context.Context) (db.Transaction, error) {
+	return bm.DBManager.BeginTransaction(ctx)
+}
+
+func (bm *BatchManager) AddRecord(ctx context.Context, req AddRecordRequest, transaction db.Transaction) (uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, bm.Timeout)
+	defer cancel()
+	if err := bm.authorize(ctx, OperationRecords, func() (uuid.UUID, error) { return req.ReferenceTypeID, nil }); err != nil {
+		return uuid.Nil, err
+	}
+	return bm.RecordRepository.AddRecord(ctx, req, transaction)
+}
+
+func (bm *BatchManager) UpdateRecord(ctx context.Context, req UpdRecordRequest, transaction db.Transaction) (*Record, error) {
+	ctx, cancel := context.WithTimeout(ctx, bm.Timeout)
+	defer cancel()
+	if err := bm.authorize(ctx, OperationRecords, func() (uuid.UUID, error) {
+		return bm.RecordRepository.GetRecordRefTypeID(ctx, req.ID, transaction)
+	}); err != nil {
+		return nil, err
+	}
+	if err := authorizeRecord(ctx, bm.RecordRepository, req.ID, AccessWrite); err != nil {
+		return nil, err
+	}
+	return bm.RecordRepository.UpdateRecord(ctx, req, transaction)
+}
+
+func (bm *BatchManager) AddProperty(ctx context.Context, req AddPropertyRequest, transaction db.Transaction) (uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, bm.Timeout)
+	defer cancel()
+	if err := bm.authorize(ctx, OperationProperties, func() (uuid.UUID, error) { return req.OwnerRefTypeID, nil }); err != nil {
+		return uuid.Nil, err
+	}
+	return bm.PropertyRepository.AddProperty(ctx, req, transaction)
+}
+
+func (bm *BatchManager) UpdateProperty(ctx context.Context, req UpdPropertyRequest, transaction db.Transaction) (*Property, error) {
+	ctx, cancel := context.WithTimeout(ctx, bm.Timeout)
+	defer cancel()
+	if err := bm.authorize(ctx, OperationProperties, func() (uuid.UUID, error) {
+		return bm.PropertyRepository.GetPropertyOwnerRefTypeID(ctx, req.ID, transaction)
+	}); err != nil {
+		return nil, err
+	}
+	return bm.PropertyRepository.UpdateProperty(ctx, req, transaction)
+}
+
+func (bm *BatchManager) SetValue(ctx context.Context, req SetValueRequest, transaction db.Transaction) (*Value, error) {
+	ctx, cancel := context.WithTimeout(ctx, bm.Timeout)
+	defer cancel()
+	if err := bm.authorize(ctx, OperationValues, func() (uuid.UUID, error) {
+		return bm.RecordRepository.GetRecordRefTypeID(ctx, req.RecordID, transaction)
+	}); err != nil {
+		return nil, err
+	}
+	if err := authorizeRecord(ctx, bm.RecordRepository, req.RecordID, AccessWrite); err != nil {
+		return nil, err
+	}
+	req, err := encryptValue(ctx, bm.Cipher, bm.ValueRepository, req, transaction)
+	if err != nil {
+		return nil, err
+	}
+	v, err := bm.ValueRepository.SetValue(ctx, req, transaction)
+	if err != nil {
+		return nil, err
+	}
+	return decryptValue(bm.Cipher, v)
+}
+
+// authorize checks the write access of the principal of the context to the operation on the reference type
+// found by scope. Scopes are read within the transaction, so objects added by the batch are scoped too,
+// missing ones are not scoped like out of batches.
+func (bm *BatchManager) authorize(ctx context.Context, op Operation, scope func() (uuid.UUID, error)) error {
+	p := PrincipalFromContext(ctx)
+	if p == nil {
+		return nil
+	}
+	refTypeID, err := scope()
+	if err != nil {
+		return err
+	}
+	return bm.AuthManager.Authorize(ctx, p, Permission{Operation: op, Access: AccessWrite, RefTypeID: refTypeID})
+}
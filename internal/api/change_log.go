@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultChangeLogManagerTimeout = time.Second * 5
+	defaultChangeLogRetention      = time.Hour * 24 * 7
+	defaultChangeLogPollInterval   = time.Second * 5
+	changeLogPageLimit             = 1000
+	defaultChangeFeedLimit         = 100
+)
+
+type ChangeLogManager struct {
+	ChangeLogConfig
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+// ChangeLogConfig of the manager. Streams read the log when they are notified of changes
+// and every PollInterval in case notifications are missed, e.g. while listening is restarted.
+type ChangeLogConfig struct {
+	Repository   ChangeLogRepository
+	Retention    time.Duration
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+func NewChangeLogManager(c ChangeLogConfig) (*ChangeLogManager, error) {
+	if c.Repository == nil {
+		return nil, fmt.Errorf("change log repository can not be nil")
+	}
+	if c.Retention == 0 {
+		c.Retention = defaultChangeLogRetention
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = defaultChangeLogPollInterval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultChangeLogManagerTimeout
+	}
+	return &ChangeLogManager{ChangeLogConfig: c, changed: make(chan struct{})}, nil
+}
+
+// Listen wakes streams up on notifications of the repository until the context is done or listening fails.
+func (cm *ChangeLogManager) Listen(ctx context.Context) error {
+	return cm.Repository.ListenChanges(ctx, func(int64) {
+		cm.notify()
+	})
+}
+
+// notify closes the channel streams wait on, so all of them are woken up at once.
+func (cm *ChangeLogManager) notify() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	close(cm.changed)
+	cm.changed = make(chan struct{})
+}
+
+// Changed returns the channel which is closed when changes are notified.
+func (cm *ChangeLogManager) Changed() <-chan struct{} {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.changed
+}
+
+// LastID returns the ID of the last logged change, streams started after it get new changes only.
+func (cm *ChangeLogManager) LastID(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, cm.Timeout)
+	defer cancel()
+	return cm.Repository.GetLastChangeID(ctx)
+}
+
+// Stream emits changes after req.AfterID in order of their IDs until the context is done
+// or emit fails, the limit of the request is the size of pages the log is read by.
+// The log is read up to the horizon of commits, so the stream and streams resumed by IDs
+// of emitted changes wait for lower IDs which are not committed yet instead of skipping them.
+func (cm *ChangeLogManager) Stream(ctx context.Context, req ChangeLogRequest, emit func(Change) error) error {
+	if req.Limit == 0 || req.Limit > changeLogPageLimit {
+		req.Limit = changeLogPageLimit
+	}
+	ticker := time.NewTicker(cm.PollInterval)
+	defer ticker.Stop()
+	for {
+		// The channel is taken before the log is read, so changes logged meanwhile are not missed
+		changed := cm.Changed()
+		changes, err := cm.get(ctx, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for _, c := range changes {
+			if err := emit(c); err != nil {
+				return err
+			}
+			req.AfterID = c.ID
+		}
+		if len(changes) == int(req.Limit) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cm *ChangeLogManager) get(ctx context.Context, req ChangeLogRequest) ([]Change, error) {
+	ctx, cancel := context.WithTimeout(ctx, cm.Timeout)
+	defer cancel()
+	return cm.Repository.GetChangeLog(ctx, req)
+}
+
+// Get returns a page of changes after req.AfterID for consumers polling the log.
+func (cm *ChangeLogManager) Get(ctx context.Context, req ChangeLogRequest) ([]Change, error) {
+	if req.Limit == 0 {
+		req.Limit = defaultChangeFeedLimit
+	}
+	if req.Limit > changeLogPageLimit {
+		req.Limit = changeLogPageLimit
+	}
+	return cm.get(ctx, req)
+}
+
+func (cm *ChangeLogManager) GetConsumers(ctx context.Context) ([]ChangeConsumer, error) {
+	ctx, cancel := context.WithTimeout(ctx, cm.Timeout)
+	defer cancel()
+	return cm.Repository.GetChangeConsumers(ctx)
+}
+
+func (cm *ChangeLogManager) GetConsumer(ctx context.Context, name string) (*ChangeConsumer, error) {
+	ctx, cancel := context.WithTimeout(ctx, cm.Timeout)
+	defer cancel()
+	return cm.Repository.GetChangeConsumer(ctx, name)
+}
+
+// SetConsumer stores the cursor of the consumer, changes up to lastChangeID are not retained for it anymore.
+func (cm *ChangeLogManager) SetConsumer(ctx context.Context, name string, lastChangeID int64) (*ChangeConsumer, error) {
+	ctx, cancel := context.WithTimeout(ctx, cm.Timeout)
+	defer cancel()
+	return cm.Repository.SetChangeConsumer(ctx, name, lastChangeID)
+}
+
+func (cm *ChangeLogManager) DeleteConsumer(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, cm.Timeout)
+	defer cancel()
+	return cm.Repository.DeleteChangeConsumer(ctx, name)
+}
+
+// Purge deletes changes logged earlier than the retention which are read by all consumers.
+func (cm *ChangeLogManager) Purge(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, cm.Timeout)
+	defer cancel()
+	return cm.Repository.PurgeChangeLog(ctx, cm.Retention)
+}
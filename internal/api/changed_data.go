@@ -45,6 +45,13 @@ func (cdm *ChangedDataManager) Purge(ctx context.Context, id int64, transaction
 	return cdm.Repository.PurgeChanges(ctx, id, transaction)
 }
 
+// Pending returns the count and age of changes which are not sent yet.
+func (cdm *ChangedDataManager) Pending(ctx context.Context) (domain.PendingChanges, error) {
+	ctx, cancel := context.WithTimeout(ctx, cdm.Timeout)
+	defer cancel()
+	return cdm.Repository.GetPendingChanges(ctx)
+}
+
 type changedValueKeySchema struct {
 	OwnerID    *uuid.UUID `json:"owner_id,omitempty"`
 	PropertyID *uuid.UUID `json:"property_id,omitempty"`
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Dump of the whole tom may be large so its timeout is much longer than of other managers.
+const defaultDumpManagerTimeout = time.Hour
+
+type DumpManager struct {
+	DumpConfig
+}
+
+// ACLs of records are read by RecordRepository, so the dump of the principal holds only the records
+// it may read and the import does not change the records it may not write.
+type DumpConfig struct {
+	Repository       DumpRepository
+	RecordRepository RecordRepository
+	Timeout          time.Duration
+}
+
+func NewDumpManager(c DumpConfig) (*DumpManager, error) {
+	if c.Repository == nil {
+		return nil, fmt.Errorf("dump repository can not be nil")
+	}
+	if c.RecordRepository == nil {
+		return nil, fmt.Errorf("record repository can not be nil")
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultDumpManagerTimeout
+	}
+	return &DumpManager{c}, nil
+}
+
+// Export skips records which are hidden from the principal of the context by their ACLs and values of them.
+// Records go before values in the dump, so values are skipped by the records skipped before.
+func (dm *DumpManager) Export(ctx context.Context, emit func(DumpItem) error) error {
+	ctx, cancel := context.WithTimeout(ctx, dm.Timeout)
+	defer cancel()
+	p := PrincipalFromContext(ctx)
+	if p == nil {
+		return dm.Repository.ExportDump(ctx, emit)
+	}
+	hidden := make(map[uuid.UUID]bool)
+	return dm.Repository.ExportDump(ctx, func(item DumpItem) error {
+		switch {
+		case item.Kind == DumpItemRecord && item.Record != nil && !item.Record.ACL.Allows(p, AccessRead):
+			hidden[item.Record.ID] = true
+			return nil
+		case item.Kind == DumpItemValue && item.Value != nil && hidden[item.Value.RecordID]:
+			return nil
+		}
+		return emit(item)
+	})
+}
+
+// Import fails on records and values of the existing records which the principal of the context
+// may not write by their ACLs.
+func (dm *DumpManager) Import(ctx context.Context, src DumpSource, opts ImportDumpOptions) (*DumpStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, dm.Timeout)
+	defer cancel()
+	if PrincipalFromContext(ctx) != nil {
+		src = &aclDumpSource{DumpSource: src, ctx: ctx, repo: dm.RecordRepository, writable: make(map[uuid.UUID]bool)}
+	}
+	return dm.Repository.ImportDump(ctx, src, opts)
+}
+
+// aclDumpSource checks the write access of the principal to records of the items it passes.
+// Each record is checked once, so values of the record do not read its ACL again.
+type aclDumpSource struct {
+	DumpSource
+	ctx      context.Context
+	repo     RecordRepository
+	writable map[uuid.UUID]bool
+}
+
+func (s *aclDumpSource) Next() (*DumpItem, error) {
+	item, err := s.DumpSource.Next()
+	if err != nil {
+		return nil, err
+	}
+	var id uuid.UUID
+	switch {
+	case item.Kind == DumpItemRecord && item.Record != nil:
+		id = item.Record.ID
+	case item.Kind == DumpItemValue && item.Value != nil:
+		id = item.Value.RecordID
+	default:
+		return item, nil
+	}
+	if s.writable[id] {
+		return item, nil
+	}
+	if err := authorizeRecord(s.ctx, s.repo, id, AccessWrite); err != nil {
+		return nil, err
+	}
+	s.writable[id] = true
+	return item, nil
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"datatom/pkg/db"
+)
+
+// encryptValue replaces the plain value of the encrypted property by its envelope. Values are not
+// encrypted without the cipher, so plain values of encrypted properties are rejected by the repository.
+func encryptValue(ctx context.Context, c ValueCipher, repo ValueRepository, req SetValueRequest, tx db.Transaction) (SetValueRequest, error) {
+	if c == nil {
+		return req, nil
+	}
+	encrypted, err := repo.IsEncryptedProperty(ctx, req.PropertyID, tx)
+	if err != nil || !encrypted {
+		return req, err
+	}
+	ev, err := c.Encrypt(Value{
+		RecordID:   req.RecordID,
+		PropertyID: req.PropertyID,
+		Type:       req.Type,
+		RefTypeID:  req.RefTypeID,
+		Value:      req.Value,
+	})
+	if err != nil {
+		return req, err
+	}
+	req.Value = ev
+	return req, nil
+}
+
+// decryptValues replaces envelopes of the read values by plain values.
+// Envelopes are left as is without the cipher.
+func decryptValues(c ValueCipher, values []Value) error {
+	if c == nil {
+		return nil
+	}
+	for i := range values {
+		if _, ok := values[i].Value.(EncryptedValue); !ok {
+			continue
+		}
+		plain, err := c.Decrypt(values[i])
+		if err != nil {
+			return err
+		}
+		values[i].Value = plain
+	}
+	return nil
+}
+
+func decryptValue(c ValueCipher, v *Value) (*Value, error) {
+	values := []Value{*v}
+	if err := decryptValues(c, values); err != nil {
+		return nil, err
+	}
+	return &values[0], nil
+}
+
+func decryptRecordValues(c ValueCipher, records []RecordWithValues) error {
+	for _, r := range records {
+		if err := decryptValues(c, r.Values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decryptingTableWriter decrypts values of rows before they are written.
+type decryptingTableWriter struct {
+	TableWriter
+	cipher ValueCipher
+}
+
+func (w decryptingTableWriter) WriteRow(row TableRow) error {
+	for i := range row.Values {
+		if _, ok := row.Values[i].Value.Value.(EncryptedValue); !ok {
+			continue
+		}
+		plain, err := w.cipher.Decrypt(row.Values[i].Value)
+		if err != nil {
+			return err
+		}
+		row.Values[i].Value.Value = plain
+	}
+	return w.TableWriter.WriteRow(row)
+}
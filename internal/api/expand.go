@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	. "datatom/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// GetExpanded reads the record with its values and embeds referenced records
+// level by level, so each level costs one batched query.
+func (rm *RecordManager) GetExpanded(ctx context.Context, id uuid.UUID, req ExpandRequest) (*ExpandedRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, rm.Timeout)
+	defer cancel()
+	records, err := rm.Repository.GetRecords(ctx, []uuid.UUID{id}, req.Values || req.Refs)
+	if err != nil {
+		return nil, err
+	}
+	records = readableRecords(ctx, records)
+	if len(records) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	if err := rm.readValues(ctx, records); err != nil {
+		return nil, err
+	}
+	out := newExpandedRecord(records[0])
+	if !req.Refs {
+		return out, nil
+	}
+	fetched := map[uuid.UUID]*ExpandedRecord{id: out}
+	if err := rm.expand(ctx, []*ExpandedRecord{out}, fetched, req); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ExpandValue embeds the record referenced by the value.
+func (rm *RecordManager) ExpandValue(ctx context.Context, value Value, req ExpandRequest) (*ExpandedValue, error) {
+	ctx, cancel := context.WithTimeout(ctx, rm.Timeout)
+	defer cancel()
+	owner := &ExpandedRecord{Values: []ExpandedValue{{Value: value}}}
+	if req.Refs {
+		if err := rm.expand(ctx, []*ExpandedRecord{owner}, map[uuid.UUID]*ExpandedRecord{}, req); err != nil {
+			return nil, err
+		}
+	}
+	return &owner.Values[0], nil
+}
+
+func (rm *RecordManager) expand(ctx context.Context, level []*ExpandedRecord, fetched map[uuid.UUID]*ExpandedRecord, req ExpandRequest) error {
+	for depth := uint(0); depth < req.Depth && len(level) > 0; depth++ {
+		var ids []uuid.UUID
+		for _, rec := range level {
+			for _, v := range rec.Values {
+				refID, ok := refValueID(v.Value)
+				if !ok {
+					continue
+				}
+				if _, ok := fetched[refID]; !ok {
+					fetched[refID] = nil
+					ids = append(ids, refID)
+				}
+			}
+		}
+		var next []*ExpandedRecord
+		if len(ids) > 0 {
+			// Records of inner levels need their values to be expanded further
+			records, err := rm.Repository.GetRecords(ctx, ids, req.Values || depth+1 < req.Depth)
+			if err != nil {
+				return err
+			}
+			// Hidden records are not embedded like the deleted ones
+			records = readableRecords(ctx, records)
+			if err := rm.readValues(ctx, records); err != nil {
+				return err
+			}
+			next = make([]*ExpandedRecord, 0, len(records))
+			for _, r := range records {
+				er := newExpandedRecord(r)
+				fetched[r.ID] = er
+				next = append(next, er)
+			}
+		}
+		for _, rec := range level {
+			for i := range rec.Values {
+				if refID, ok := refValueID(rec.Values[i].Value); ok {
+					rec.Values[i].Ref = fetched[refID]
+				}
+			}
+		}
+		level = next
+	}
+	return nil
+}
+
+func newExpandedRecord(r RecordWithValues) *ExpandedRecord {
+	out := &ExpandedRecord{Record: r.Record}
+	if r.Values != nil {
+		out.Values = make([]ExpandedValue, 0, len(r.Values))
+		for _, v := range r.Values {
+			out.Values = append(out.Values, ExpandedValue{Value: v})
+		}
+	}
+	return out
+}
+
+func refValueID(v Value) (uuid.UUID, bool) {
+	if v.Type != TypeReference {
+		return uuid.Nil, false
+	}
+	id, ok := v.Value.(uuid.UUID)
+	return id, ok
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultIdempotencyManagerTimeout = time.Second
+	defaultIdempotencyKeyTTL         = time.Hour * 24
+	defaultIdempotencyKeyLease       = time.Minute
+)
+
+type IdempotencyManager struct {
+	IdempotencyConfig
+}
+
+// IdempotencyConfig of the manager, TTL is the time a response is kept for retries of the request.
+// Lease is the time the request is in progress, its retries take over the key after it, e.g. if
+// the service crashed, so it has to be longer than the time of processing of any request.
+type IdempotencyConfig struct {
+	Repository IdempotencyRepository
+	TTL        time.Duration
+	Lease      time.Duration
+	Timeout    time.Duration
+}
+
+func NewIdempotencyManager(c IdempotencyConfig) (*IdempotencyManager, error) {
+	if c.Repository == nil {
+		return nil, fmt.Errorf("idempotency repository can not be nil")
+	}
+	if c.TTL == 0 {
+		c.TTL = defaultIdempotencyKeyTTL
+	}
+	if c.Lease == 0 {
+		c.Lease = defaultIdempotencyKeyLease
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultIdempotencyManagerTimeout
+	}
+	return &IdempotencyManager{c}, nil
+}
+
+// Reserve returns nil if the request has to be processed and the response to the first request otherwise.
+func (im *IdempotencyManager) Reserve(ctx context.Context, key IdempotencyKey) (*IdempotentResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, im.Timeout)
+	defer cancel()
+	return im.Repository.ReserveIdempotencyKey(ctx, key, im.TTL, im.Lease)
+}
+
+func (im *IdempotencyManager) Save(ctx context.Context, key IdempotencyKey, resp IdempotentResponse) error {
+	ctx, cancel := context.WithTimeout(ctx, im.Timeout)
+	defer cancel()
+	return im.Repository.SaveIdempotentResponse(ctx, key, resp)
+}
+
+func (im *IdempotencyManager) Release(ctx context.Context, key IdempotencyKey) error {
+	ctx, cancel := context.WithTimeout(ctx, im.Timeout)
+	defer cancel()
+	return im.Repository.ReleaseIdempotencyKey(ctx, key)
+}
+
+// Purge deletes expired keys and returns their number.
+func (im *IdempotencyManager) Purge(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, im.Timeout)
+	defer cancel()
+	return im.Repository.PurgeIdempotencyKeys(ctx)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"datatom/pkg/db"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const defaultImportManagerTimeout = time.Second
+
+// ImportManager adds records of rows with their values within the one transaction.
+// Timeout limits writes of every single row but not the whole import.
+type ImportManager struct {
+	ImportConfig
+}
+
+type ImportConfig struct {
+	DBManager        *DBManager
+	RecordRepository RecordRepository
+	ValueRepository  ValueRepository
+	// Cipher encrypts values of encrypted properties if it is set
+	Cipher  ValueCipher
+	Timeout time.Duration
+}
+
+func NewImportManager(c ImportConfig) (*ImportManager, error) {
+	if c.DBManager == nil {
+		return nil, fmt.Errorf("DB manager can not be nil")
+	}
+	if c.RecordRepository == nil {
+		return nil, fmt.Errorf("record repository can not be nil")
+	}
+	if c.ValueRepository == nil {
+		return nil, fmt.Errorf("value repository can not be nil")
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultImportManagerTimeout
+	}
+	return &ImportManager{c}, nil
+}
+
+// ImportRows applies every row in its own savepoint, so errors of all the rows are reported.
+// The transaction is committed only if there are no errors and it is not a dry run.
+func (im *ImportManager) ImportRows(ctx context.Context, src ImportRowSource, opts ImportRowsOptions) (*ImportReport, error) {
+	tx, err := im.DBManager.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction error: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	out := &ImportReport{Errors: []ImportRowError{}}
+	for {
+		row, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		out.Rows++
+		rowErrs := row.Errors
+		if len(rowErrs) == 0 {
+			rowErr, err := im.importRow(ctx, *row, opts, tx)
+			if err != nil {
+				return nil, err
+			}
+			if rowErr != nil {
+				rowErrs = []ImportRowError{*rowErr}
+			}
+		}
+		if len(rowErrs) > 0 {
+			for _, e := range rowErrs {
+				e.Line = row.Line
+				out.Errors = append(out.Errors, e)
+			}
+			continue
+		}
+		out.Valid++
+	}
+	if opts.DryRun || len(out.Errors) > 0 {
+		if err := tx.Rollback(ctx); err != nil {
+			return nil, fmt.Errorf("rollback transaction error: %w", err)
+		}
+		return out, nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit transaction error: %w", err)
+	}
+	out.Imported = out.Valid
+	return out, nil
+}
+
+// importRow returns the error of data of the row, the error is returned only if the import has to be stopped.
+func (im *ImportManager) importRow(ctx context.Context, row ImportRow, opts ImportRowsOptions, tx db.Transaction) (*ImportRowError, error) {
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin savepoint error: %w", err)
+	}
+	rowErr, err := im.applyRow(ctx, row, opts, sp)
+	if err != nil || rowErr != nil {
+		if errRollback := sp.Rollback(ctx); errRollback != nil {
+			return nil, fmt.Errorf("rollback savepoint error: %w", errRollback)
+		}
+		return rowErr, err
+	}
+	if err := sp.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("release savepoint error: %w", err)
+	}
+	return nil, nil
+}
+
+func (im *ImportManager) applyRow(ctx context.Context, row ImportRow, opts ImportRowsOptions, tx db.Transaction) (*ImportRowError, error) {
+	ctx, cancel := context.WithTimeout(ctx, im.Timeout)
+	defer cancel()
+	id, err := im.RecordRepository.AddRecord(ctx, row.Record, tx)
+	if err != nil {
+		return importRowError(opts, "", err)
+	}
+	for i, v := range row.Values {
+		v.RecordID = id
+		v, err = encryptValue(ctx, im.Cipher, im.ValueRepository, v, tx)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := im.ValueRepository.SetValue(ctx, v, tx); err != nil {
+			return importRowError(opts, row.Columns[i], err)
+		}
+	}
+	return nil, nil
+}
+
+func importRowError(opts ImportRowsOptions, column string, err error) (*ImportRowError, error) {
+	if opts.IsRowError == nil || !opts.IsRowError(err) {
+		return nil, err
+	}
+	return &ImportRowError{Column: column, Error: err.Error()}, nil
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultMaskManagerTimeout = time.Second
+
+const defaultUnmaskedReadsLimit = 100
+
+// MaskManager masks values of masked properties read by principals without unmasking roles
+// and audits the reads of principals with them.
+type MaskManager struct {
+	MaskConfig
+}
+
+// Values of hash masks are HMACs by HashKey, they are hidden if it is not set.
+type MaskConfig struct {
+	PropertyRepository PropertyRepository
+	AuditRepository    AuditRepository
+	HashKey            []byte
+	Timeout            time.Duration
+}
+
+func NewMaskManager(c MaskConfig) (*MaskManager, error) {
+	if c.PropertyRepository == nil {
+		return nil, fmt.Errorf("property repository can not be nil")
+	}
+	if c.AuditRepository == nil {
+		return nil, fmt.Errorf("audit repository can not be nil")
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultMaskManagerTimeout
+	}
+	return &MaskManager{c}, nil
+}
+
+// SetMask replaces the mask of the property, the nil mask removes it.
+func (pm *PropertyManager) SetMask(ctx context.Context, id uuid.UUID, mask *MaskPolicy) (*Property, error) {
+	ctx, cancel := context.WithTimeout(ctx, pm.Timeout)
+	defer cancel()
+	return pm.Repository.SetPropertyMask(ctx, id, mask)
+}
+
+func (mm *MaskManager) UnmaskedReads(ctx context.Context, req UnmaskedReadsRequest) ([]UnmaskedRead, error) {
+	ctx, cancel := context.WithTimeout(ctx, mm.Timeout)
+	defer cancel()
+	if req.Limit <= 0 {
+		req.Limit = defaultUnmaskedReadsLimit
+	}
+	return mm.AuditRepository.GetUnmaskedReads(ctx, req)
+}
+
+// maskValues replaces values of masked properties by masked ones for the principal of the context.
+// Nothing is masked without the principal, so the service itself and clients without authentication
+// read values as they are. The read is failed if its audit is not registered.
+func (mm *MaskManager) maskValues(ctx context.Context, values []Value) error {
+	p := PrincipalFromContext(ctx)
+	if mm == nil || p == nil || len(values) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(values))
+	for _, v := range values {
+		ids = append(ids, v.PropertyID)
+	}
+	masks, err := mm.PropertyRepository.GetPropertyMasks(ctx, ids)
+	if err != nil {
+		return err
+	}
+	return mm.apply(ctx, p, masks, values)
+}
+
+func (mm *MaskManager) apply(ctx context.Context, p *Principal, masks map[uuid.UUID]MaskPolicy, values []Value) error {
+	var reads []UnmaskedRead
+	for i, v := range values {
+		mask, ok := masks[v.PropertyID]
+		if !ok {
+			continue
+		}
+		if mask.Unmasks(p) {
+			reads = append(reads, UnmaskedRead{Subject: p.Subject, Roles: p.Roles, RecordID: v.RecordID, PropertyID: v.PropertyID})
+			continue
+		}
+		values[i].Value = mask.Mask(v, mm.HashKey)
+	}
+	if len(reads) == 0 {
+		return nil
+	}
+	return mm.AuditRepository.RegisterUnmaskedReads(ctx, reads)
+}
+
+func (mm *MaskManager) maskValue(ctx context.Context, v *Value) (*Value, error) {
+	values := []Value{*v}
+	if err := mm.maskValues(ctx, values); err != nil {
+		return nil, err
+	}
+	return &values[0], nil
+}
+
+// maskRecordValues masks values of all the records at once, so masks are read in one query.
+func (mm *MaskManager) maskRecordValues(ctx context.Context, records []RecordWithValues) error {
+	var values []Value
+	for _, r := range records {
+		values = append(values, r.Values...)
+	}
+	if err := mm.maskValues(ctx, values); err != nil {
+		return err
+	}
+	for _, r := range records {
+		n := copy(r.Values, values)
+		values = values[n:]
+	}
+	return nil
+}
+
+// maskingTableWriter masks values of rows by masks of the properties of the header.
+type maskingTableWriter struct {
+	TableWriter
+	ctx       context.Context
+	man       *MaskManager
+	principal *Principal
+	masks     map[uuid.UUID]MaskPolicy
+}
+
+func (w *maskingTableWriter) WriteHeader(properties []Property) error {
+	w.masks = make(map[uuid.UUID]MaskPolicy)
+	for _, p := range properties {
+		if p.Mask != nil {
+			w.masks[p.ID] = *p.Mask
+		}
+	}
+	return w.TableWriter.WriteHeader(properties)
+}
+
+func (w *maskingTableWriter) WriteRow(row TableRow) error {
+	if len(w.masks) != 0 {
+		values := make([]Value, 0, len(row.Values))
+		for _, v := range row.Values {
+			values = append(values, v.Value)
+		}
+		if err := w.man.apply(w.ctx, w.principal, w.masks, values); err != nil {
+			return err
+		}
+		for i := range row.Values {
+			row.Values[i].Value = values[i]
+			if _, ok := values[i].Value.(MaskedValue); ok {
+				row.Values[i].RefName = ""
+			}
+		}
+	}
+	return w.TableWriter.WriteRow(row)
+}
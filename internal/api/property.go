@@ -38,13 +38,13 @@ func NewPropertyManager(c PropertyConfig) (*PropertyManager, error) {
 func (pm *PropertyManager) Add(ctx context.Context, req AddPropertyRequest) (uuid.UUID, error) {
 	ctx, cancel := context.WithTimeout(ctx, pm.Timeout)
 	defer cancel()
-	return pm.Repository.AddProperty(ctx, req)
+	return pm.Repository.AddProperty(ctx, req, nil)
 }
 
 func (pm *PropertyManager) Update(ctx context.Context, req UpdPropertyRequest) (*Property, error) {
 	ctx, cancel := context.WithTimeout(ctx, pm.Timeout)
 	defer cancel()
-	return pm.Repository.UpdateProperty(ctx, req)
+	return pm.Repository.UpdateProperty(ctx, req, nil)
 }
 
 func (pm *PropertyManager) Get(ctx context.Context, id uuid.UUID) (*Property, error) {
@@ -53,6 +53,13 @@ func (pm *PropertyManager) Get(ctx context.Context, id uuid.UUID) (*Property, er
 	return pm.Repository.GetProperty(ctx, id)
 }
 
+// GetMany reads the found properties in one query.
+func (pm *PropertyManager) GetMany(ctx context.Context, ids []uuid.UUID) ([]Property, error) {
+	ctx, cancel := context.WithTimeout(ctx, pm.Timeout)
+	defer cancel()
+	return pm.Repository.GetProperties(ctx, ids)
+}
+
 func (pm *PropertyManager) GetByKey(ctx context.Context, key []byte) (*Property, error) {
 	req, err := getDataRequestByKey(key)
 	if err != nil {
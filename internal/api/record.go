@@ -16,9 +16,13 @@ type RecordManager struct {
 	RecordConfig
 }
 
+// RecordConfig of the manager, values of encrypted properties of read records are decrypted by Cipher if it is set
+// and values of masked properties are masked by Masker if it is set.
 type RecordConfig struct {
 	Repository RecordRepository
 	Broker     RecordBroker
+	Cipher     ValueCipher
+	Masker     *MaskManager
 	Timeout    time.Duration
 }
 
@@ -38,19 +42,93 @@ func NewRecordManager(c RecordConfig) (*RecordManager, error) {
 func (rm *RecordManager) Add(ctx context.Context, req AddRecordRequest) (uuid.UUID, error) {
 	ctx, cancel := context.WithTimeout(ctx, rm.Timeout)
 	defer cancel()
-	return rm.Repository.AddRecord(ctx, req)
+	return rm.Repository.AddRecord(ctx, req, nil)
 }
 
 func (rm *RecordManager) Update(ctx context.Context, req UpdRecordRequest) (*Record, error) {
 	ctx, cancel := context.WithTimeout(ctx, rm.Timeout)
 	defer cancel()
-	return rm.Repository.UpdateRecord(ctx, req)
+	if err := authorizeRecord(ctx, rm.Repository, req.ID, AccessWrite); err != nil {
+		return nil, err
+	}
+	return rm.Repository.UpdateRecord(ctx, req, nil)
 }
 
+// Get returns ErrRecordNotFound if the ACL of the record hides it from the principal of the context.
 func (rm *RecordManager) Get(ctx context.Context, id uuid.UUID) (*Record, error) {
 	ctx, cancel := context.WithTimeout(ctx, rm.Timeout)
 	defer cancel()
-	return rm.Repository.GetRecord(ctx, id)
+	r, err := rm.Repository.GetRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !r.ACL.Allows(PrincipalFromContext(ctx), AccessRead) {
+		return nil, ErrRecordNotFound
+	}
+	return r, nil
+}
+
+// GetMany reads the found records in one query, values are read if withValues is set.
+// Records hidden by their ACLs are not found.
+func (rm *RecordManager) GetMany(ctx context.Context, ids []uuid.UUID, withValues bool) ([]RecordWithValues, error) {
+	ctx, cancel := context.WithTimeout(ctx, rm.Timeout)
+	defer cancel()
+	records, err := rm.Repository.GetRecords(ctx, ids, withValues)
+	if err != nil {
+		return nil, err
+	}
+	records = readableRecords(ctx, records)
+	if err := rm.readValues(ctx, records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (rm *RecordManager) List(ctx context.Context, req ListRecordsRequest) ([]Record, error) {
+	ctx, cancel := context.WithTimeout(ctx, rm.Timeout)
+	defer cancel()
+	req.Principal = PrincipalFromContext(ctx)
+	return rm.Repository.ListRecords(ctx, req)
+}
+
+// ReferencedBy skips references of hidden records, so pages may be shorter than the limit.
+func (rm *RecordManager) ReferencedBy(ctx context.Context, req ReferencedByRequest) ([]RecordReference, error) {
+	ctx, cancel := context.WithTimeout(ctx, rm.Timeout)
+	defer cancel()
+	if err := authorizeRecord(ctx, rm.Repository, req.RecordID, AccessRead); err != nil {
+		return nil, err
+	}
+	refs, err := rm.Repository.GetReferencedBy(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(refs))
+	for _, ref := range refs {
+		ids = append(ids, ref.RecordID)
+	}
+	readable, err := readableRecordIDs(ctx, rm.Repository, ids)
+	if err != nil || readable == nil {
+		return refs, err
+	}
+	out := refs[:0]
+	for _, ref := range refs {
+		if readable[ref.RecordID] {
+			out = append(out, ref)
+		}
+	}
+	return out, nil
+}
+
+// Graph is walked by the principal, so hidden records are not passed and records
+// reachable through them only are not visited.
+func (rm *RecordManager) Graph(ctx context.Context, req GraphRequest) (*Graph, error) {
+	ctx, cancel := context.WithTimeout(ctx, rm.Timeout)
+	defer cancel()
+	if err := authorizeRecord(ctx, rm.Repository, req.RecordID, AccessRead); err != nil {
+		return nil, err
+	}
+	req.Principal = PrincipalFromContext(ctx)
+	return rm.Repository.GetRecordGraph(ctx, req)
 }
 
 func (rm *RecordManager) GetByKey(ctx context.Context, key []byte) (*Record, error) {
@@ -85,3 +163,11 @@ func (rm *RecordManager) GetSender(req SendRecordRequest) *RecordSender {
 		req: req,
 	}
 }
+
+// readValues decrypts and then masks values of the read records.
+func (rm *RecordManager) readValues(ctx context.Context, records []RecordWithValues) error {
+	if err := decryptRecordValues(rm.Cipher, records); err != nil {
+		return err
+	}
+	return rm.Masker.maskRecordValues(ctx, records)
+}
@@ -53,6 +53,13 @@ func (rtm *RefTypeManager) Get(ctx context.Context, id uuid.UUID) (*RefType, err
 	return rtm.Repository.GetRefType(ctx, id)
 }
 
+// GetMany reads the found reference types in one query.
+func (rtm *RefTypeManager) GetMany(ctx context.Context, ids []uuid.UUID) ([]RefType, error) {
+	ctx, cancel := context.WithTimeout(ctx, rtm.Timeout)
+	defer cancel()
+	return rtm.Repository.GetRefTypes(ctx, ids)
+}
+
 func (rtm *RefTypeManager) GetByKey(ctx context.Context, key []byte) (*RefType, error) {
 	req, err := getDataRequestByKey(key)
 	if err != nil {
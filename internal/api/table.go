@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	. "datatom/internal/domain"
+	"fmt"
+	"time"
+)
+
+// Table of a large reference type is streamed long so its timeout is longer than of other managers.
+const defaultTableManagerTimeout = time.Minute * 10
+
+type TableManager struct {
+	TableConfig
+}
+
+// TableConfig of the manager, values of encrypted properties are decrypted by Cipher if it is set
+// and values of masked properties are masked by Masker if it is set.
+type TableConfig struct {
+	Repository TableRepository
+	Cipher     ValueCipher
+	Masker     *MaskManager
+	Timeout    time.Duration
+}
+
+func NewTableManager(c TableConfig) (*TableManager, error) {
+	if c.Repository == nil {
+		return nil, fmt.Errorf("table repository can not be nil")
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultTableManagerTimeout
+	}
+	return &TableManager{c}, nil
+}
+
+// Export skips rows of records which ACLs hide them from the principal of the context
+// and masks values of masked properties for it.
+func (tm *TableManager) Export(ctx context.Context, req TableRequest, w TableWriter) error {
+	ctx, cancel := context.WithTimeout(ctx, tm.Timeout)
+	defer cancel()
+	p := PrincipalFromContext(ctx)
+	// Writers are called from the last wrapped one, so rows are filtered, decrypted and then masked
+	if tm.Masker != nil && p != nil {
+		w = &maskingTableWriter{TableWriter: w, ctx: ctx, man: tm.Masker, principal: p}
+	}
+	if tm.Cipher != nil {
+		w = decryptingTableWriter{TableWriter: w, cipher: tm.Cipher}
+	}
+	if p != nil {
+		w = aclTableWriter{TableWriter: w, principal: p}
+	}
+	return tm.Repository.ExportTable(ctx, req, w)
+}
@@ -14,16 +14,25 @@ type ValueManager struct {
 	ValueConfig
 }
 
+// ValueConfig of the manager, ACLs of owner records of values are read from RecordRepository.
+// Values of encrypted properties are encrypted and decrypted by Cipher if it is set.
+// Read values of masked properties are masked by Masker if it is set.
 type ValueConfig struct {
-	Repository ValueRepository
-	Broker     ValueBroker
-	Timeout    time.Duration
+	Repository       ValueRepository
+	RecordRepository RecordRepository
+	Broker           ValueBroker
+	Cipher           ValueCipher
+	Masker           *MaskManager
+	Timeout          time.Duration
 }
 
 func NewValueManager(c ValueConfig) (*ValueManager, error) {
 	if c.Repository == nil {
 		return nil, fmt.Errorf("value repository can not be nil")
 	}
+	if c.RecordRepository == nil {
+		return nil, fmt.Errorf("record repository can not be nil")
+	}
 	if c.Broker == nil {
 		return nil, fmt.Errorf("value broker can not be nil")
 	}
@@ -33,24 +42,59 @@ func NewValueManager(c ValueConfig) (*ValueManager, error) {
 	return &ValueManager{c}, nil
 }
 
+// Set returns the value as it is set, so it is not masked for the principal who has just set it.
 func (vm *ValueManager) Set(ctx context.Context, req SetValueRequest) (*Value, error) {
 	ctx, cancel := context.WithTimeout(ctx, vm.Timeout)
 	defer cancel()
-	return vm.Repository.SetValue(ctx, req)
+	if err := authorizeRecord(ctx, vm.RecordRepository, req.RecordID, AccessWrite); err != nil {
+		return nil, err
+	}
+	req, err := encryptValue(ctx, vm.Cipher, vm.Repository, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	v, err := vm.Repository.SetValue(ctx, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decryptValue(vm.Cipher, v)
 }
 
 func (vm *ValueManager) Get(ctx context.Context, req GetValueRequest) (*Value, error) {
 	ctx, cancel := context.WithTimeout(ctx, vm.Timeout)
 	defer cancel()
-	return vm.Repository.GetValue(ctx, req)
+	if err := authorizeRecord(ctx, vm.RecordRepository, req.RecordID, AccessRead); err != nil {
+		return nil, err
+	}
+	v, err := vm.Repository.GetValue(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if v, err = decryptValue(vm.Cipher, v); err != nil {
+		return nil, err
+	}
+	return vm.Masker.maskValue(ctx, v)
+}
+
+func (vm *ValueManager) Transitions(ctx context.Context, req GetValueRequest) (*StateTransitions, error) {
+	ctx, cancel := context.WithTimeout(ctx, vm.Timeout)
+	defer cancel()
+	if err := authorizeRecord(ctx, vm.RecordRepository, req.RecordID, AccessRead); err != nil {
+		return nil, err
+	}
+	return vm.Repository.GetStateTransitions(ctx, req)
 }
 
+// GetByKey reads the changed value as it is stored to send it, so values of encrypted properties
+// are neither decrypted nor masked and the broker sends them as envelopes or masked ones.
 func (vm *ValueManager) GetByKey(ctx context.Context, key []byte) (*Value, error) {
 	req, err := getValueRequestByKey(key)
 	if err != nil {
 		return nil, fmt.Errorf("invalid key error: %w, %s", err, key)
 	}
-	return vm.Get(ctx, *req)
+	ctx, cancel := context.WithTimeout(ctx, vm.Timeout)
+	defer cancel()
+	return vm.Repository.GetValue(ctx, *req)
 }
 
 func (vm *ValueManager) GetSentState(ctx context.Context, req GetValueRequest, transaction db.Transaction) (*ValueSentState, error) {
@@ -65,6 +109,7 @@ func (vm *ValueManager) SetSentState(ctx context.Context, state ValueSentState,
 	return vm.Repository.SetSentValue(ctx, state, transaction)
 }
 
+// ChangedValues are read as they are stored, so values of encrypted properties are sent as envelopes.
 func (vm *ValueManager) ChangedValues(ctx context.Context) ([]Value, error) {
 	ctx, cancel := context.WithTimeout(ctx, vm.Timeout)
 	defer cancel()
@@ -0,0 +1,318 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	. "datatom/internal/domain"
+	"datatom/pkg/helper"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultWebhookManagerTimeout    = time.Second * 5
+	defaultWebhookClientTimeout     = time.Second * 10
+	defaultWebhookRetryBase         = time.Second * 10
+	defaultWebhookRetryMax          = time.Hour
+	defaultWebhookMaxFailures       = 10
+	defaultWebhookDeliveryRetention = time.Hour * 24 * 7
+	defaultWebhookBatchSize         = 100
+	webhookResponseBodyLimit        = 64 << 10
+	webhookSignaturePrefix          = "sha256="
+	maxWebhookBackoffShift          = 30
+)
+
+// Headers of webhook deliveries
+const (
+	WebhookIDHeader        = "X-Datatom-Webhook-ID"
+	WebhookChangeIDHeader  = "X-Datatom-Change-ID"
+	WebhookTimestampHeader = "X-Datatom-Timestamp"
+	WebhookSignatureHeader = "X-Datatom-Signature"
+)
+
+type WebhookManager struct {
+	WebhookConfig
+}
+
+// WebhookConfig of the manager. Failed deliveries are retried after RetryBase doubled by each failure
+// up to RetryMax, the webhook is disabled after MaxFailures failures in a row.
+type WebhookConfig struct {
+	Repository        WebhookRepository
+	ChangeLogManager  *ChangeLogManager
+	Client            *http.Client
+	RetryBase         time.Duration
+	RetryMax          time.Duration
+	MaxFailures       int
+	DeliveryRetention time.Duration
+	BatchSize         uint
+	Timeout           time.Duration
+}
+
+func NewWebhookManager(c WebhookConfig) (*WebhookManager, error) {
+	if c.Repository == nil {
+		return nil, fmt.Errorf("webhook repository can not be nil")
+	}
+	if c.ChangeLogManager == nil {
+		return nil, fmt.Errorf("change log manager can not be nil")
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: defaultWebhookClientTimeout}
+	}
+	if c.RetryBase == 0 {
+		c.RetryBase = defaultWebhookRetryBase
+	}
+	if c.RetryMax == 0 {
+		c.RetryMax = defaultWebhookRetryMax
+	}
+	if c.MaxFailures == 0 {
+		c.MaxFailures = defaultWebhookMaxFailures
+	}
+	if c.DeliveryRetention == 0 {
+		c.DeliveryRetention = defaultWebhookDeliveryRetention
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = defaultWebhookBatchSize
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultWebhookManagerTimeout
+	}
+	return &WebhookManager{WebhookConfig: c}, nil
+}
+
+// Add returns the ID of the webhook which is delivered changes logged after it is added.
+func (wm *WebhookManager) Add(ctx context.Context, wh Webhook) (uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, wm.Timeout)
+	defer cancel()
+	return wm.Repository.AddWebhook(ctx, wh)
+}
+
+// Update keeps the secret if it is empty, enabling of the disabled webhook resets its failures.
+func (wm *WebhookManager) Update(ctx context.Context, wh Webhook) error {
+	ctx, cancel := context.WithTimeout(ctx, wm.Timeout)
+	defer cancel()
+	return wm.Repository.UpdateWebhook(ctx, wh)
+}
+
+func (wm *WebhookManager) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, wm.Timeout)
+	defer cancel()
+	return wm.Repository.DeleteWebhook(ctx, id)
+}
+
+func (wm *WebhookManager) GetAll(ctx context.Context) ([]Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, wm.Timeout)
+	defer cancel()
+	return wm.Repository.GetWebhooks(ctx)
+}
+
+func (wm *WebhookManager) Get(ctx context.Context, id uuid.UUID) (*Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, wm.Timeout)
+	defer cancel()
+	return wm.Repository.GetWebhook(ctx, id)
+}
+
+// GetDeliveries returns the last deliveries of the webhook, the latest first.
+func (wm *WebhookManager) GetDeliveries(ctx context.Context, id uuid.UUID, limit uint) ([]WebhookDelivery, error) {
+	if _, err := wm.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, wm.Timeout)
+	defer cancel()
+	return wm.Repository.GetWebhookDeliveries(ctx, id, limit)
+}
+
+// PurgeDeliveries deletes deliveries logged earlier than the retention.
+func (wm *WebhookManager) PurgeDeliveries(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, wm.Timeout)
+	defer cancel()
+	return wm.Repository.PurgeWebhookDeliveries(ctx, wm.DeliveryRetention)
+}
+
+// Deliver sends changes to webhooks which are due. Webhooks are delivered concurrently,
+// changes of each webhook are delivered one by one and the first failure stops its delivery till the retry.
+func (wm *WebhookManager) Deliver(ctx context.Context) error {
+	webhooks, err := wm.getDue(ctx)
+	if err != nil {
+		return fmt.Errorf("get due webhooks error: %w", err)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(webhooks))
+	for i := range webhooks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := wm.deliver(ctx, webhooks[i]); err != nil {
+				errs[i] = fmt.Errorf("deliver webhook %s error: %w", webhooks[i].ID, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (wm *WebhookManager) getDue(ctx context.Context) ([]Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, wm.Timeout)
+	defer cancel()
+	return wm.Repository.GetDueWebhooks(ctx)
+}
+
+func (wm *WebhookManager) deliver(ctx context.Context, wh Webhook) error {
+	// The last ID is the horizon of commits read before the log, so changes up to it are final and
+	// the cursor is not moved over changes logged meanwhile or committed later with lower IDs
+	horizonID, err := wm.ChangeLogManager.LastID(ctx)
+	if err != nil {
+		return err
+	}
+	changes, err := wm.ChangeLogManager.Get(ctx, ChangeLogRequest{
+		AfterID: wh.LastChangeID,
+		Filter:  wh.Filter,
+		Limit:   wm.BatchSize,
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		d := wm.post(ctx, wh, c)
+		if d.IsSuccessful() {
+			wh.LastChangeID = c.ID
+			wh.Failures = 0
+			wh.NextAttemptAt = time.Time{}
+		} else {
+			wh.Failures++
+			if wh.Failures >= wm.MaxFailures {
+				wh.DisabledReason = fmt.Sprintf("%d deliveries of change %d failed, the last one with: %s", wh.Failures, c.ID, d.Error)
+			} else {
+				wh.NextAttemptAt = d.DeliveredAt.Add(wm.backoff(wh.Failures))
+			}
+		}
+		if err := wm.setState(ctx, wh, &d); err != nil {
+			return err
+		}
+		if !d.IsSuccessful() {
+			return nil
+		}
+	}
+	// Changes up to the horizon which are not delivered do not match the filter, they are not kept for the webhook
+	if len(changes) < int(wm.BatchSize) && horizonID > wh.LastChangeID {
+		wh.LastChangeID = horizonID
+		return wm.setState(ctx, wh, nil)
+	}
+	return nil
+}
+
+func (wm *WebhookManager) setState(ctx context.Context, wh Webhook, d *WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, wm.Timeout)
+	defer cancel()
+	return wm.Repository.SetWebhookState(ctx, wh.ID, wh.WebhookState, d)
+}
+
+// backoff before the retry after the number of failures.
+func (wm *WebhookManager) backoff(failures int) time.Duration {
+	n := failures - 1
+	if n > maxWebhookBackoffShift {
+		n = maxWebhookBackoffShift
+	}
+	out := wm.RetryBase << n
+	if out > wm.RetryMax || out <= 0 {
+		return wm.RetryMax
+	}
+	return out
+}
+
+// webhookPayload is the body of the delivery, the change is like the one of the change feed.
+type webhookPayload struct {
+	WebhookID uuid.UUID     `json:"webhook_id"`
+	Attempt   int           `json:"attempt"`
+	Change    webhookChange `json:"change"`
+}
+
+type webhookChange struct {
+	ID         int64      `json:"id"`
+	Type       string     `json:"type"`
+	RefTypeID  *uuid.UUID `json:"reference_type_id"`
+	PropertyID *uuid.UUID `json:"property_id"`
+	RecordID   *uuid.UUID `json:"record_id"`
+	ChangedAt  time.Time  `json:"changed_at"`
+}
+
+func newWebhookPayload(wh Webhook, c Change) webhookPayload {
+	optionalID := func(id uuid.UUID) *uuid.UUID {
+		if helper.IsZeroUUID(id) {
+			return nil
+		}
+		return &id
+	}
+	return webhookPayload{
+		WebhookID: wh.ID,
+		Attempt:   wh.Failures + 1,
+		Change: webhookChange{
+			ID:         c.ID,
+			Type:       c.DataType.String(),
+			RefTypeID:  optionalID(c.RefTypeID),
+			PropertyID: optionalID(c.PropertyID),
+			RecordID:   optionalID(c.RecordID),
+			ChangedAt:  c.ChangedAt,
+		},
+	}
+}
+
+// post sends the change to the webhook, errors of the delivery are returned in it.
+func (wm *WebhookManager) post(ctx context.Context, wh Webhook, c Change) WebhookDelivery {
+	out := WebhookDelivery{
+		WebhookID:   wh.ID,
+		ChangeID:    c.ID,
+		Attempt:     wh.Failures + 1,
+		DeliveredAt: time.Now().UTC(),
+	}
+	body, err := json.Marshal(newWebhookPayload(wh, c))
+	if err != nil {
+		out.Error = fmt.Sprintf("payload marshal error: %s", err)
+		return out
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		out.Error = fmt.Sprintf("new request error: %s", err)
+		return out
+	}
+	timestamp := out.DeliveredAt.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, wh.ID.String())
+	req.Header.Set(WebhookChangeIDHeader, strconv.FormatInt(c.ID, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(wh.Secret, timestamp, body))
+	resp, err := wm.Client.Do(req)
+	out.Duration = time.Since(out.DeliveredAt)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	// The body is read for the connection to be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, webhookResponseBodyLimit))
+	_ = resp.Body.Close()
+	out.StatusCode = resp.StatusCode
+	if !out.IsSuccessful() {
+		out.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+	}
+	return out
+}
+
+// SignWebhookPayload returns the signature header of the payload, it is the HMAC-SHA256
+// of the timestamp and the body joined by the dot, so receivers can reject replayed deliveries.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"datatom/internal/domain"
+	"encoding/hex"
+	"fmt"
+)
+
+// APIKey is stored by the SHA-256 hash of the key, so the configuration does not disclose keys.
+type APIKey struct {
+	Subject string
+	SHA256  string
+	Roles   []string
+	Groups  []string
+}
+
+type APIKeyAuthenticator struct {
+	principals map[[sha256.Size]byte]domain.Principal
+}
+
+func NewAPIKeyAuthenticator(keys []APIKey) (*APIKeyAuthenticator, error) {
+	out := &APIKeyAuthenticator{principals: make(map[[sha256.Size]byte]domain.Principal, len(keys))}
+	for _, k := range keys {
+		if k.Subject == "" {
+			return nil, fmt.Errorf("subject of the API key expected")
+		}
+		b, err := hex.DecodeString(k.SHA256)
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("SHA-256 of the API key of %s must be 64 hex digits", k.Subject)
+		}
+		var sum [sha256.Size]byte
+		copy(sum[:], b)
+		if _, ok := out.principals[sum]; ok {
+			return nil, fmt.Errorf("API key of %s is duplicated", k.Subject)
+		}
+		out.principals[sum] = domain.Principal{Subject: k.Subject, Roles: k.Roles, Groups: k.Groups}
+	}
+	return out, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(_ context.Context, c domain.Credentials) (*domain.Principal, error) {
+	if c.APIKey == "" {
+		return nil, nil
+	}
+	p, ok := a.principals[sha256.Sum256([]byte(c.APIKey))]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown API key", domain.ErrUnauthenticated)
+	}
+	return &p, nil
+}
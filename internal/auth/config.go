@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"datatom/internal/domain"
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/uuid"
+)
+
+// Config is the TOML file of authentication and authorization, e.g.
+//
+//	[[api_keys]]
+//	subject = "erp"
+//	sha256 = "<hex SHA-256 of the key>"
+//	roles = ["editor"]
+//	groups = ["hr"]
+//
+//	[jwt]
+//	jwks_file = "/etc/datatom/jwks.json"
+//	issuer = "https://id.example.com"
+//	audience = "datatom"
+//	roles_claim = "realm_access.roles"
+//	groups_claim = "groups"
+//
+//	[[roles]]
+//	name = "editor"
+//	[[roles.grants]]
+//	operations = ["records", "values"]
+//	reference_types = ["3f1c5e4a-8d4b-4d6e-9b8a-2f9c1d7e6a50"]
+//	access = "write"
+type Config struct {
+	APIKeys []APIKeyConfig `toml:"api_keys"`
+	JWT     *JWTFileConfig `toml:"jwt"`
+	Roles   []RoleConfig   `toml:"roles"`
+}
+
+// APIKeyConfig groups are matched by ACLs of records.
+type APIKeyConfig struct {
+	Subject string   `toml:"subject"`
+	SHA256  string   `toml:"sha256"`
+	Roles   []string `toml:"roles"`
+	Groups  []string `toml:"groups"`
+}
+
+type JWTFileConfig struct {
+	JWKSFile    string `toml:"jwks_file"`
+	Issuer      string `toml:"issuer"`
+	Audience    string `toml:"audience"`
+	RolesClaim  string `toml:"roles_claim"`
+	GroupsClaim string `toml:"groups_claim"`
+	LeewaySec   uint   `toml:"leeway"`
+}
+
+// RoleConfig grants are added up, operations and reference types which are not set mean all of them.
+type RoleConfig struct {
+	Name   string        `toml:"name"`
+	Grants []GrantConfig `toml:"grants"`
+}
+
+type GrantConfig struct {
+	Operations     []string `toml:"operations"`
+	ReferenceTypes []string `toml:"reference_types"`
+	Access         string   `toml:"access"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	var out Config
+	if _, err := toml.DecodeFile(path, &out); err != nil {
+		return nil, fmt.Errorf("auth config file parse error: %w", err)
+	}
+	return &out, nil
+}
+
+// Authenticators of API keys and JWTs which are configured.
+func (c *Config) Authenticators() ([]domain.Authenticator, error) {
+	var out []domain.Authenticator
+	if len(c.APIKeys) != 0 {
+		roles := make(map[string]bool, len(c.Roles))
+		for _, r := range c.Roles {
+			roles[r.Name] = true
+		}
+		keys := make([]APIKey, 0, len(c.APIKeys))
+		for _, k := range c.APIKeys {
+			for _, r := range k.Roles {
+				if !roles[r] {
+					return nil, fmt.Errorf("role %s of the API key of %s is not configured", r, k.Subject)
+				}
+			}
+			keys = append(keys, APIKey{Subject: k.Subject, SHA256: k.SHA256, Roles: k.Roles, Groups: k.Groups})
+		}
+		a, err := NewAPIKeyAuthenticator(keys)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	if c.JWT != nil {
+		a, err := NewJWTAuthenticator(JWTConfig{
+			JWKSFile:    c.JWT.JWKSFile,
+			Issuer:      c.JWT.Issuer,
+			Audience:    c.JWT.Audience,
+			RolesClaim:  c.JWT.RolesClaim,
+			GroupsClaim: c.JWT.GroupsClaim,
+			Leeway:      time.Second * time.Duration(c.JWT.LeewaySec),
+		})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("API keys or JWT expected in the auth config")
+	}
+	return out, nil
+}
+
+func (c *Config) DomainRoles() ([]domain.Role, error) {
+	out := make([]domain.Role, 0, len(c.Roles))
+	for _, r := range c.Roles {
+		if r.Name == "" {
+			return nil, fmt.Errorf("name of the role expected")
+		}
+		role := domain.Role{Name: r.Name}
+		for i, g := range r.Grants {
+			grant, err := g.grant()
+			if err != nil {
+				return nil, fmt.Errorf("grant %d of role %s error: %w", i, r.Name, err)
+			}
+			role.Grants = append(role.Grants, grant)
+		}
+		out = append(out, role)
+	}
+	return out, nil
+}
+
+func (g GrantConfig) grant() (domain.Grant, error) {
+	var out domain.Grant
+	var err error
+	if out.Access, err = domain.AccessFromCode(g.Access); err != nil {
+		return out, err
+	}
+	for _, code := range g.Operations {
+		op, err := domain.OperationFromCode(code)
+		if err != nil {
+			return out, err
+		}
+		out.Operations = append(out.Operations, op)
+	}
+	for _, s := range g.ReferenceTypes {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return out, fmt.Errorf("parse reference type %s error: %s", s, err)
+		}
+		out.RefTypeIDs = append(out.RefTypeIDs, id)
+	}
+	return out, nil
+}
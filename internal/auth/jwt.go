@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"datatom/internal/domain"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultRolesClaim  = "roles"
+	defaultGroupsClaim = "groups"
+)
+
+var jwtMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// JWTConfig of the authenticator. Tokens are signed by keys of the JWKS file, the issuer and the audience
+// are checked if they are set. RolesClaim is the path of the claim with roles like realm_access.roles,
+// GroupsClaim is the path of the claim with groups matched by ACLs of records.
+type JWTConfig struct {
+	JWKSFile    string
+	Issuer      string
+	Audience    string
+	RolesClaim  string
+	GroupsClaim string
+	Leeway      time.Duration
+}
+
+type JWTAuthenticator struct {
+	keys        map[string]crypto.PublicKey
+	parser      *jwt.Parser
+	rolesClaim  []string
+	groupsClaim []string
+}
+
+func NewJWTAuthenticator(c JWTConfig) (*JWTAuthenticator, error) {
+	if c.JWKSFile == "" {
+		return nil, fmt.Errorf("JWKS file expected")
+	}
+	b, err := os.ReadFile(c.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("read JWKS file error: %s", err)
+	}
+	keys, err := parseJWKS(b)
+	if err != nil {
+		return nil, err
+	}
+	if c.RolesClaim == "" {
+		c.RolesClaim = defaultRolesClaim
+	}
+	if c.GroupsClaim == "" {
+		c.GroupsClaim = defaultGroupsClaim
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(jwtMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(c.Leeway),
+	}
+	if c.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(c.Issuer))
+	}
+	if c.Audience != "" {
+		opts = append(opts, jwt.WithAudience(c.Audience))
+	}
+	return &JWTAuthenticator{
+		keys:        keys,
+		parser:      jwt.NewParser(opts...),
+		rolesClaim:  strings.Split(c.RolesClaim, "."),
+		groupsClaim: strings.Split(c.GroupsClaim, "."),
+	}, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(_ context.Context, c domain.Credentials) (*domain.Principal, error) {
+	if c.Token == "" {
+		return nil, nil
+	}
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(c.Token, claims, a.key); err != nil {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUnauthenticated, err)
+	}
+	sub, err := claims.GetSubject()
+	if err != nil || sub == "" {
+		return nil, fmt.Errorf("%w: subject of the token expected", domain.ErrUnauthenticated)
+	}
+	return &domain.Principal{
+		Subject: sub,
+		Roles:   claimStrings(claims, a.rolesClaim),
+		Groups:  claimStrings(claims, a.groupsClaim),
+	}, nil
+}
+
+// key of the token is found by its ID, the ID may be omitted if the set has the only key.
+func (a *JWTAuthenticator) key(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// claimStrings reads the claim by its path from the array of strings or the string of items
+// separated by spaces like scopes.
+func claimStrings(claims jwt.MapClaims, path []string) []string {
+	var v any = map[string]any(claims)
+	for _, name := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"fmt"
+)
+
+type GranteeType uint
+
+// Grantees of ACL entries are subjects of principals or groups they belong to.
+const (
+	GranteeSubject GranteeType = iota + 1
+	GranteeGroup
+)
+
+func (gt GranteeType) String() string {
+	switch gt {
+	case GranteeSubject:
+		return "subject"
+	case GranteeGroup:
+		return "group"
+	default:
+		return "unknown"
+	}
+}
+
+func (gt GranteeType) Code() string {
+	return gt.String()
+}
+
+func GranteeTypeFromCode(code string) (GranteeType, error) {
+	switch code {
+	case "subject":
+		return GranteeSubject, nil
+	case "group":
+		return GranteeGroup, nil
+	default:
+		return GranteeSubject, fmt.Errorf(`%w "%s" of grantee type`, ErrUnknownType, code)
+	}
+}
+
+type ACLEntry struct {
+	GranteeType GranteeType
+	Grantee     string
+	Access      Access
+}
+
+// ACL of the record restricts it to its grantees on top of the grants of roles.
+// The record without entries is not restricted.
+type ACL []ACLEntry
+
+// Allows returns true if the ACL is empty or any entry of the subject or the groups of the principal
+// allows the access. The nil principal is the service itself or a client while authentication is disabled.
+func (acl ACL) Allows(p *Principal, access Access) bool {
+	if len(acl) == 0 || p == nil {
+		return true
+	}
+	for _, e := range acl {
+		if e.Access < access {
+			continue
+		}
+		switch e.GranteeType {
+		case GranteeSubject:
+			if e.Grantee == p.Subject {
+				return true
+			}
+		case GranteeGroup:
+			if contains(p.Groups, e.Grantee) {
+				return true
+			}
+		}
+	}
+	return false
+}
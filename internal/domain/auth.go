@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Authenticator identifies the principal of the request by its credentials. It returns nil without an error
+// if the credentials are not of its kind, so the next authenticator is tried.
+type Authenticator interface {
+	Authenticate(context.Context, Credentials) (*Principal, error)
+}
+
+// Credentials of the request, Token is the bearer token of the Authorization header and APIKey is the static key.
+type Credentials struct {
+	Token  string
+	APIKey string
+}
+
+func (c Credentials) IsEmpty() bool {
+	return c.Token == "" && c.APIKey == ""
+}
+
+// Principal is the authenticated client, its roles are names of roles configured for the service.
+// Groups are matched by ACLs of records.
+type Principal struct {
+	Subject string
+	Roles   []string
+	Groups  []string
+}
+
+type Operation uint
+
+// Operations are the groups of API requests which are granted to roles.
+const (
+	OperationRefTypes Operation = iota
+	OperationProperties
+	OperationRecords
+	OperationValues
+	OperationBatch
+	OperationGraphQL
+	OperationDump
+	OperationImport
+	OperationExport
+	OperationChanges
+	OperationWebhooks
+	OperationDataway
+	OperationAudit
+)
+
+func (op Operation) String() string {
+	switch op {
+	case OperationRefTypes:
+		return "ref_types"
+	case OperationProperties:
+		return "properties"
+	case OperationRecords:
+		return "records"
+	case OperationValues:
+		return "values"
+	case OperationBatch:
+		return "batch"
+	case OperationGraphQL:
+		return "graphql"
+	case OperationDump:
+		return "dump"
+	case OperationImport:
+		return "import"
+	case OperationExport:
+		return "export"
+	case OperationChanges:
+		return "changes"
+	case OperationWebhooks:
+		return "webhooks"
+	case OperationDataway:
+		return "dataway"
+	case OperationAudit:
+		return "audit"
+	default:
+		return "unknown"
+	}
+}
+
+func (op Operation) Code() string {
+	return op.String()
+}
+
+func OperationFromCode(code string) (Operation, error) {
+	for op := OperationRefTypes; op <= OperationAudit; op++ {
+		if op.Code() == code {
+			return op, nil
+		}
+	}
+	return OperationRefTypes, fmt.Errorf(`%w "%s" of operation`, ErrUnknownType, code)
+}
+
+type Access uint
+
+// Write access implies the read one.
+const (
+	AccessRead Access = iota + 1
+	AccessWrite
+)
+
+func (a Access) String() string {
+	switch a {
+	case AccessRead:
+		return "read"
+	case AccessWrite:
+		return "write"
+	default:
+		return "unknown"
+	}
+}
+
+func (a Access) Code() string {
+	return a.String()
+}
+
+func AccessFromCode(code string) (Access, error) {
+	switch code {
+	case "read":
+		return AccessRead, nil
+	case "write":
+		return AccessWrite, nil
+	default:
+		return AccessRead, fmt.Errorf(`%w "%s" of access`, ErrUnknownType, code)
+	}
+}
+
+// Permission the request needs, RefTypeID is zero if the request is not scoped by a reference type,
+// e.g. batches and dumps which may touch all of them.
+type Permission struct {
+	Operation Operation
+	Access    Access
+	RefTypeID uuid.UUID
+}
+
+func (p Permission) String() string {
+	if p.RefTypeID == uuid.Nil {
+		return fmt.Sprintf("%s access to %s", p.Access, p.Operation)
+	}
+	return fmt.Sprintf("%s access to %s of reference type %s", p.Access, p.Operation, p.RefTypeID)
+}
+
+// Grant of the role allows the access to the operations on the reference types, empty lists mean all of them.
+// Grants restricted by reference types do not allow requests which are not scoped by one.
+type Grant struct {
+	Operations []Operation
+	RefTypeIDs []uuid.UUID
+	Access     Access
+}
+
+func (g Grant) Allows(p Permission) bool {
+	if g.Access < p.Access {
+		return false
+	}
+	if len(g.Operations) != 0 && !contains(g.Operations, p.Operation) {
+		return false
+	}
+	if len(g.RefTypeIDs) != 0 && (p.RefTypeID == uuid.Nil || !contains(g.RefTypeIDs, p.RefTypeID)) {
+		return false
+	}
+	return true
+}
+
+type Role struct {
+	Name   string
+	Grants []Grant
+}
+
+func contains[T comparable](items []T, item T) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChangeLogRepository keeps registered changes after they are sent to dat(A)way.
+// IDs of changes are taken before commit, so changes are read up to the first change which may be
+// preceded by a lower ID of a transaction in progress. Cursors set to read changes do not skip them.
+type ChangeLogRepository interface {
+	GetChangeLog(context.Context, ChangeLogRequest) ([]Change, error)
+	// GetLastChangeID returns the ID of the last change which is read by GetChangeLog
+	GetLastChangeID(context.Context) (int64, error)
+	// ListenChanges calls the function with IDs of committed changes until the context is done or the connection fails
+	ListenChanges(context.Context, func(int64)) error
+	// PurgeChangeLog deletes changes logged earlier than the retention which are read by all consumers
+	PurgeChangeLog(context.Context, time.Duration) (int64, error)
+	GetChangeConsumers(context.Context) ([]ChangeConsumer, error)
+	GetChangeConsumer(context.Context, string) (*ChangeConsumer, error)
+	SetChangeConsumer(context.Context, string, int64) (*ChangeConsumer, error)
+	DeleteChangeConsumer(context.Context, string) error
+}
+
+// Change is the logged change with the ID of changed_data_id_seq. RefTypeID is the reference type
+// of the changed record or value and the owner reference type of the changed property.
+type Change struct {
+	ID         int64
+	DataType   ChangedDataType
+	RefTypeID  uuid.UUID
+	PropertyID uuid.UUID
+	RecordID   uuid.UUID
+	ChangedAt  time.Time
+}
+
+// ChangeFilter selects changes of any of the types, reference types and properties, empty lists mean no filter.
+// Changes of reference types and records have no property, so they are not selected by properties.
+type ChangeFilter struct {
+	ChangeTypes []ChangedDataType
+	RefTypeIDs  []uuid.UUID
+	PropertyIDs []uuid.UUID
+}
+
+type ChangeLogRequest struct {
+	AfterID int64
+	Filter  ChangeFilter
+	Limit   uint
+}
+
+// ChangeConsumer is the named cursor of the change log stored by the service, changes after
+// LastChangeID are retained until the consumer has read them.
+type ChangeConsumer struct {
+	Name         string
+	LastChangeID int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"datatom/pkg/db"
 	"fmt"
+	"time"
 )
 
 type ChangedDataType uint
@@ -11,12 +12,22 @@ type ChangedDataType uint
 type ChangedDataRepository interface {
 	GetChanges(context.Context) ([]ChangedData, error)
 	PurgeChanges(context.Context, int64, db.Transaction) error
+	GetPendingChanges(context.Context) (PendingChanges, error)
 }
 
+// PendingChanges are registered changes which are not sent yet, OldestChangedAt is zero if there are none.
+type PendingChanges struct {
+	Count           int64
+	OldestChangedAt time.Time
+}
+
+// ChangedData is the registered change, TraceParent is the W3C traceparent of the trace it is made in
+// and it is empty if the change is not traced.
 type ChangedData struct {
-	ID       int64
-	DataType ChangedDataType
-	Key      []byte
+	ID          int64
+	DataType    ChangedDataType
+	Key         []byte
+	TraceParent string
 }
 
 const (
@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type DumpRepository interface {
+	ExportDump(context.Context, func(DumpItem) error) error
+	ImportDump(context.Context, DumpSource, ImportDumpOptions) (*DumpStats, error)
+}
+
+// DumpSource yields items of the dump one by one and returns io.EOF when items are over.
+type DumpSource interface {
+	Next() (*DumpItem, error)
+}
+
+type DumpItemKind uint
+
+// Kinds are declared in the dependency order: items of a kind may refer only to items of the previous kinds.
+const (
+	DumpItemRefType DumpItemKind = iota
+	DumpItemProperty
+	DumpItemSequenceCounter
+	DumpItemRecord
+	DumpItemValue
+)
+
+func (dik DumpItemKind) String() string {
+	switch dik {
+	case DumpItemRefType:
+		return "reference_type"
+	case DumpItemProperty:
+		return "property"
+	case DumpItemSequenceCounter:
+		return "sequence_counter"
+	case DumpItemRecord:
+		return "record"
+	case DumpItemValue:
+		return "value"
+	default:
+		return "unknown"
+	}
+}
+
+func (dik DumpItemKind) Code() string {
+	return dik.String()
+}
+
+func DumpItemKindFromCode(code string) (DumpItemKind, error) {
+	switch code {
+	case "reference_type":
+		return DumpItemRefType, nil
+	case "property":
+		return DumpItemProperty, nil
+	case "sequence_counter":
+		return DumpItemSequenceCounter, nil
+	case "record":
+		return DumpItemRecord, nil
+	case "value":
+		return DumpItemValue, nil
+	default:
+		return DumpItemRefType, fmt.Errorf(`%w "%s" of dump item kind`, ErrUnknownType, code)
+	}
+}
+
+// DumpItem holds exactly one object of the kind.
+type DumpItem struct {
+	Kind            DumpItemKind
+	RefType         *RefType
+	Property        *Property
+	SequenceCounter *SequenceCounter
+	Record          *Record
+	Value           *Value
+}
+
+// SequenceCounter is the last taken number of the sequence property within the period.
+type SequenceCounter struct {
+	PropertyID uuid.UUID
+	Period     string
+	LastNumber int64
+}
+
+// ImportDumpOptions of the import. Imported objects are registered as changes
+// and published by the dataway only if RegisterChanges is set.
+type ImportDumpOptions struct {
+	RegisterChanges bool
+}
+
+type DumpStats struct {
+	RefTypes         int64
+	Properties       int64
+	SequenceCounters int64
+	Records          int64
+	Values           int64
+}
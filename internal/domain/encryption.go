@@ -0,0 +1,51 @@
+package domain
+
+import "encoding/json"
+
+// EncryptedValue is the envelope of the value of the encrypted property as it is stored and sent.
+// The plain value is sealed by DataKey which is sealed in turn by the keyring key KeyID.
+// MAC is the keyed hash of the plain value, so the sum of the value changes only with the plain value.
+type EncryptedValue struct {
+	KeyID      string
+	DataKey    []byte
+	Ciphertext []byte
+	MAC        string
+}
+
+// MarshalJSON renders the envelope which is not decrypted like it is stored, as {"enc": {...}}.
+func (ev EncryptedValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ValueJSONSchema{Enc: EncryptedValueToSchema(ev)})
+}
+
+// ValueCipher seals values of encrypted properties. Envelopes are bound to the record and the property
+// of the value, so they can not be moved to other values.
+type ValueCipher interface {
+	Encrypt(Value) (EncryptedValue, error)
+	// Decrypt returns the plain value of the envelope in Value.Value
+	Decrypt(Value) (any, error)
+}
+
+type EncryptedValueSchema struct {
+	KeyID      string `json:"kid"`
+	DataKey    []byte `json:"dk"`
+	Ciphertext []byte `json:"ct"`
+	MAC        string `json:"mac"`
+}
+
+func (evs *EncryptedValueSchema) EncryptedValue() EncryptedValue {
+	return EncryptedValue{
+		KeyID:      evs.KeyID,
+		DataKey:    evs.DataKey,
+		Ciphertext: evs.Ciphertext,
+		MAC:        evs.MAC,
+	}
+}
+
+func EncryptedValueToSchema(ev EncryptedValue) *EncryptedValueSchema {
+	return &EncryptedValueSchema{
+		KeyID:      ev.KeyID,
+		DataKey:    ev.DataKey,
+		Ciphertext: ev.Ciphertext,
+		MAC:        ev.MAC,
+	}
+}
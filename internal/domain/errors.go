@@ -13,6 +13,14 @@ var (
 	ErrValueNotFound    = fmt.Errorf("value %w", ErrNotFound)
 	ErrSentDataNotFound = fmt.Errorf("sent data %w", ErrNotFound)
 
+	ErrChangeConsumerNotFound = fmt.Errorf("change consumer %w", ErrNotFound)
+	ErrWebhookNotFound        = fmt.Errorf("webhook %w", ErrNotFound)
+
+	ErrAlreadyExists         = errors.New("already exists")
+	ErrRecordAlreadyExists   = fmt.Errorf("record %w", ErrAlreadyExists)
+	ErrRefTypeAlreadyExists  = fmt.Errorf("reference type %w", ErrAlreadyExists)
+	ErrPropertyAlreadyExists = fmt.Errorf("property %w", ErrAlreadyExists)
+
 	ErrStoredConfigTomIDNotSet = errors.New("tom ID not set")
 
 	ErrExpected       = errors.New("expected")
@@ -20,14 +28,136 @@ var (
 	ErrUnknownType    = errors.New("unknown type")
 	ErrParseError     = errors.New("parse")
 
+	// ErrSumMismatch is returned when the object has been changed since the expected sum was read
+	ErrSumMismatch = errors.New("sum mismatch")
+
+	ErrIdempotencyKeyInProgress = errors.New("request with the idempotency key is in progress")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key is reused with another request")
+
+	ErrDumpItemOrder            = errors.New("dump item out of dependency order")
+	ErrDumpItemDuplicated       = errors.New("dump item duplicated")
+	ErrDumpItemReferenceMissing = errors.New("dump item refers to missing object")
+
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+
 	// PostgreSQL exceptions
-	ErrTypesExpectedPG            = errors.New("types expected")
-	ErrTypesConditionNotMatchedPG = errors.New("types and reference type condition not matched")
-	ErrTypeDuplicatedPG           = errors.New("type duplicated")
-	ErrRefTypeDuplicatedPG        = errors.New("reference type duplicated")
-	ErrUnknownRefTypePG           = errors.New("unknown reference type")
-	ErrUnexpectedTypePG           = fmt.Errorf("%w", ErrUnexpectedType)
-	ErrUnexpectedRefTypePG        = errors.New("unexpected reference type")
-	ErrRefTypeExpectedPG          = errors.New("reference type expected")
-	ErrRefTypeIsRedundantPG       = errors.New("no need reference type ID cause type is not reference")
+	ErrTypesExpectedPG             = errors.New("types expected")
+	ErrTypesConditionNotMatchedPG  = errors.New("types and reference type condition not matched")
+	ErrTypeDuplicatedPG            = errors.New("type duplicated")
+	ErrRefTypeDuplicatedPG         = errors.New("reference type duplicated")
+	ErrUnknownRefTypePG            = errors.New("unknown reference type")
+	ErrUnexpectedTypePG            = fmt.Errorf("%w", ErrUnexpectedType)
+	ErrUnexpectedRefTypePG         = errors.New("unexpected reference type")
+	ErrRefTypeExpectedPG           = errors.New("reference type expected")
+	ErrRefTypeIsRedundantPG        = errors.New("no need reference type ID cause type is not reference")
+	ErrSequenceTypeMismatchPG      = errors.New("sequence property must be of text type only")
+	ErrSequenceOwnerExpectedPG     = errors.New("sequence property must have owner reference type")
+	ErrSequenceTemplatePG          = errors.New("sequence template without number placeholder")
+	ErrStateMachineTypeMismatchPG  = errors.New("state machine property must be of text type only")
+	ErrNotStateMachinePG           = errors.New("property is not a state machine")
+	ErrUnknownStatePG              = errors.New("unknown state")
+	ErrStateTransitionNotAllowedPG = errors.New("state transition not allowed")
+	ErrEncryptedPropertyKindPG     = errors.New("encrypted property must be regular and not of reference type")
+	ErrValueNotEncryptedPG         = errors.New("value of encrypted property is not encrypted")
 )
+
+// errorCodes are the stable codes of the domain errors for clients which must not depend on messages.
+// More specific errors go first cause they wrap the general ones.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrRecordNotFound, "record_not_found"},
+	{ErrRefTypeNotFound, "reference_type_not_found"},
+	{ErrPropertyNotFound, "property_not_found"},
+	{ErrValueNotFound, "value_not_found"},
+	{ErrSentDataNotFound, "sent_data_not_found"},
+	{ErrChangeConsumerNotFound, "change_consumer_not_found"},
+	{ErrWebhookNotFound, "webhook_not_found"},
+	{ErrNotFound, "not_found"},
+	{ErrRecordAlreadyExists, "record_already_exists"},
+	{ErrRefTypeAlreadyExists, "reference_type_already_exists"},
+	{ErrPropertyAlreadyExists, "property_already_exists"},
+	{ErrAlreadyExists, "already_exists"},
+	{ErrStoredConfigTomIDNotSet, "tom_id_not_set"},
+	{ErrExpected, "expected"},
+	{ErrUnexpectedType, "unexpected_type"},
+	{ErrUnknownType, "unknown_type"},
+	{ErrParseError, "parse_error"},
+	{ErrSumMismatch, "sum_mismatch"},
+	{ErrIdempotencyKeyInProgress, "idempotency_key_in_progress"},
+	{ErrIdempotencyKeyReused, "idempotency_key_reused"},
+	{ErrDumpItemOrder, "dump_item_order"},
+	{ErrDumpItemDuplicated, "dump_item_duplicated"},
+	{ErrDumpItemReferenceMissing, "dump_item_reference_missing"},
+	{ErrUnauthenticated, "unauthenticated"},
+	{ErrForbidden, "forbidden"},
+	{ErrTypesExpectedPG, "types_expected"},
+	{ErrTypesConditionNotMatchedPG, "types_condition_not_matched"},
+	{ErrTypeDuplicatedPG, "type_duplicated"},
+	{ErrRefTypeDuplicatedPG, "reference_type_duplicated"},
+	{ErrUnknownRefTypePG, "unknown_reference_type"},
+	{ErrUnexpectedRefTypePG, "unexpected_reference_type"},
+	{ErrRefTypeExpectedPG, "reference_type_expected"},
+	{ErrRefTypeIsRedundantPG, "reference_type_redundant"},
+	{ErrSequenceTypeMismatchPG, "sequence_type_mismatch"},
+	{ErrSequenceOwnerExpectedPG, "sequence_owner_expected"},
+	{ErrSequenceTemplatePG, "sequence_template_invalid"},
+	{ErrStateMachineTypeMismatchPG, "state_machine_type_mismatch"},
+	{ErrNotStateMachinePG, "not_state_machine"},
+	{ErrUnknownStatePG, "unknown_state"},
+	{ErrStateTransitionNotAllowedPG, "state_transition_not_allowed"},
+	{ErrEncryptedPropertyKindPG, "encrypted_property_kind"},
+	{ErrValueNotEncryptedPG, "value_not_encrypted"},
+}
+
+// ErrorCode returns the stable code of the domain error wrapped by err
+// and an empty string if err is not a domain one.
+func ErrorCode(err error) string {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ""
+}
+
+// DetailedError annotates the error with the offending field of the request and details of the cause,
+// e.g. keys and values of the PostgreSQL exception. It has the message of the annotated error.
+type DetailedError struct {
+	Err     error
+	Field   string
+	Details map[string]string
+}
+
+func (e *DetailedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DetailedError) Unwrap() error {
+	return e.Err
+}
+
+// NewFieldError annotates err with the field of the request which caused it.
+func NewFieldError(field string, err error) error {
+	return &DetailedError{Err: err, Field: field}
+}
+
+// ErrorField returns the offending field of the error if known.
+func ErrorField(err error) string {
+	var de *DetailedError
+	if errors.As(err, &de) {
+		return de.Field
+	}
+	return ""
+}
+
+// ErrorDetails returns details of the cause of the error if any.
+func ErrorDetails(err error) map[string]string {
+	var de *DetailedError
+	if errors.As(err, &de) {
+		return de.Details
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package domain
+
+// ExpandRequest embeds records referenced by ref values up to Depth hops.
+// Values adds values to the read record and to the embedded ones, records of inner levels
+// always have values as their ref values hold the records of the next level.
+type ExpandRequest struct {
+	Values bool
+	Refs   bool
+	Depth  uint
+}
+
+type RecordWithValues struct {
+	Record
+	Values []Value
+}
+
+type ExpandedRecord struct {
+	Record
+	Values []ExpandedValue
+}
+
+// ExpandedValue holds the referenced record in Ref for ref values.
+// Records referring to each other share pointers so Ref may lead to a cycle.
+type ExpandedValue struct {
+	Value
+	Ref *ExpandedRecord
+}
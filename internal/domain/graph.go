@@ -0,0 +1,36 @@
+package domain
+
+import "github.com/google/uuid"
+
+// GraphRequest walks ref values from the record up to MaxDepth hops.
+// Empty PropertyIDs means all ref properties. The walk does not pass records
+// hidden from the Principal by their ACLs, it passes all records if it is nil.
+type GraphRequest struct {
+	RecordID    uuid.UUID
+	PropertyIDs []uuid.UUID
+	MaxDepth    uint
+	Principal   *Principal
+}
+
+// Graph is Truncated when the walk stopped at the limit of hops or edges before MaxDepth.
+type Graph struct {
+	Nodes     []GraphNode
+	Edges     []GraphEdge
+	Truncated bool
+}
+
+// GraphNode is a visited record with the least number of hops to reach it.
+type GraphNode struct {
+	RecordID  uuid.UUID
+	RefTypeID uuid.UUID
+	Depth     uint
+}
+
+// GraphEdge is a ref value of From record pointing to To record.
+// Cycle marks the edge back to a record on the path from the root to From record, e.g. to the root.
+type GraphEdge struct {
+	From       uuid.UUID
+	To         uuid.UUID
+	PropertyID uuid.UUID
+	Cycle      bool
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ReserveIdempotencyKey of IdempotencyRepository keeps the key for the TTL and the request in progress
+// for the lease, which is the last argument, a retry takes over the reservation after the lease.
+type IdempotencyRepository interface {
+	ReserveIdempotencyKey(context.Context, IdempotencyKey, time.Duration, time.Duration) (*IdempotentResponse, error)
+	SaveIdempotentResponse(context.Context, IdempotencyKey, IdempotentResponse) error
+	ReleaseIdempotencyKey(context.Context, IdempotencyKey) error
+	PurgeIdempotencyKeys(context.Context) (int64, error)
+}
+
+// IdempotencyKey identifies the request which has to be processed only once.
+// The key is sent by the client and is scoped by the endpoint, Fingerprint
+// is the hash of the request body which must not change on retries.
+type IdempotencyKey struct {
+	Key         string
+	Scope       string
+	Fingerprint string
+}
+
+// IdempotentResponse is the response to the first request, it is replayed to the repeated ones.
+type IdempotentResponse struct {
+	Status  int
+	Headers map[string]string
+	Body    []byte
+}
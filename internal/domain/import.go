@@ -0,0 +1,39 @@
+package domain
+
+// ImportRowSource yields rows of the imported file one by one and returns io.EOF when rows are over.
+type ImportRowSource interface {
+	Next() (*ImportRow, error)
+}
+
+// ImportRow is the record of the row with its values, Columns name the columns of Values so their errors
+// are reported by columns. The row with Errors, e.g. of parsing its cells, is not imported.
+type ImportRow struct {
+	Line    int
+	Record  AddRecordRequest
+	Values  []SetValueRequest
+	Columns []string
+	Errors  []ImportRowError
+}
+
+// ImportRowError is the error of the row, Column is empty if the error is of the whole row.
+type ImportRowError struct {
+	Line   int
+	Column string
+	Error  string
+}
+
+// ImportRowsOptions of the import. IsRowError tells errors of data of the rows, e.g. values of wrong types,
+// from errors which stop the import.
+type ImportRowsOptions struct {
+	DryRun     bool
+	IsRowError func(error) bool
+}
+
+// ImportReport counts the read rows and the valid ones. Rows are Imported only if all of them are valid
+// and the import is not a dry run.
+type ImportReport struct {
+	Rows     int
+	Valid    int
+	Imported int
+	Errors   []ImportRowError
+}
@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MaskKind uint
+
+// Values of hidden properties are not shown at all, partial masks reveal the last runes of the values
+// and hashed values are comparable with each other but not readable.
+const (
+	MaskHide MaskKind = iota + 1
+	MaskPartial
+	MaskHash
+)
+
+func (mk MaskKind) String() string {
+	switch mk {
+	case MaskHide:
+		return "hide"
+	case MaskPartial:
+		return "partial"
+	case MaskHash:
+		return "hash"
+	default:
+		return "unknown"
+	}
+}
+
+func (mk MaskKind) Code() string {
+	return mk.String()
+}
+
+func MaskKindFromCode(code string) (MaskKind, error) {
+	switch code {
+	case "hide":
+		return MaskHide, nil
+	case "partial":
+		return MaskPartial, nil
+	case "hash":
+		return MaskHash, nil
+	default:
+		return MaskHide, fmt.Errorf(`%w "%s" of mask kind`, ErrUnknownType, code)
+	}
+}
+
+// maskRune replaces the hidden runes of partially masked values.
+const maskRune = '*'
+
+// MaskPolicy of the property masks its values in responses and exports for principals without
+// any of UnmaskedRoles. Reveal is the number of the last runes shown by partial masks.
+type MaskPolicy struct {
+	Kind          MaskKind
+	Reveal        uint
+	UnmaskedRoles []string
+}
+
+// Unmasks returns true if the principal reads values as they are. The nil principal is the service itself
+// or a client while authentication is disabled.
+func (mp MaskPolicy) Unmasks(p *Principal) bool {
+	if p == nil {
+		return true
+	}
+	for _, r := range p.Roles {
+		if contains(mp.UnmaskedRoles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask returns the masked value, envelopes which are not decrypted are always hidden.
+// Hashes are HMACs by the secret hashKey, so they are not matched with hashes of guessed values.
+// Hashed values are hidden if the key is not set.
+func (mp MaskPolicy) Mask(v Value, hashKey []byte) MaskedValue {
+	if v.Value == nil {
+		return MaskedValue{}
+	}
+	if _, ok := v.Value.(EncryptedValue); ok {
+		return MaskedValue{}
+	}
+	text := maskedText(v.Value)
+	switch mp.Kind {
+	case MaskPartial:
+		runes := []rune(text)
+		hidden := len(runes)
+		if uint(len(runes)) > mp.Reveal {
+			hidden = len(runes) - int(mp.Reveal)
+		}
+		return MaskedValue{Value: strings.Repeat(string(maskRune), hidden) + string(runes[hidden:])}
+	case MaskHash:
+		if len(hashKey) == 0 {
+			return MaskedValue{}
+		}
+		h := hmac.New(sha256.New, hashKey)
+		h.Write([]byte(text))
+		return MaskedValue{Value: hex.EncodeToString(h.Sum(nil))}
+	default:
+		return MaskedValue{}
+	}
+}
+
+func maskedText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// MaskedValue replaces the value the principal is not allowed to read, Value is nil if it is hidden
+// and the text of the masked value otherwise.
+type MaskedValue struct {
+	Value any
+}
+
+func (mv MaskedValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(mv.Value)
+}
+
+// UnmaskedRead is the audit record of the value of the masked property read as is by the principal.
+type UnmaskedRead struct {
+	ID         int64
+	Subject    string
+	Roles      []string
+	RecordID   uuid.UUID
+	PropertyID uuid.UUID
+	ReadAt     time.Time
+}
+
+// UnmaskedReadsRequest pages audit records after AfterID, zero fields mean no filter.
+type UnmaskedReadsRequest struct {
+	AfterID    int64
+	PropertyID uuid.UUID
+	Subject    string
+	Limit      int
+}
+
+type AuditRepository interface {
+	RegisterUnmaskedReads(context.Context, []UnmaskedRead) error
+	GetUnmaskedReads(context.Context, UnmaskedReadsRequest) ([]UnmaskedRead, error)
+}
@@ -3,6 +3,7 @@ package domain
 import (
 	"context"
 	"datatom/pkg/db"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,12 +11,54 @@ import (
 
 const DeliveryTypeProperty = "property"
 
+type PropertyKind uint
+
+const (
+	PropertyKindRegular PropertyKind = iota
+	PropertyKindSequence
+	PropertyKindStateMachine
+)
+
+func (pk PropertyKind) String() string {
+	switch pk {
+	case PropertyKindRegular:
+		return "regular"
+	case PropertyKindSequence:
+		return "sequence"
+	case PropertyKindStateMachine:
+		return "state_machine"
+	default:
+		return "unknown"
+	}
+}
+
+func (pk PropertyKind) Code() string {
+	return pk.String()
+}
+
+func PropertyKindFromCode(code string) (PropertyKind, error) {
+	switch code {
+	case "regular", "":
+		return PropertyKindRegular, nil
+	case "sequence":
+		return PropertyKindSequence, nil
+	case "state_machine":
+		return PropertyKindStateMachine, nil
+	default:
+		return PropertyKindRegular, fmt.Errorf(`%w "%s" of property kind`, ErrUnknownType, code)
+	}
+}
+
 type PropertyRepository interface {
-	AddProperty(context.Context, AddPropertyRequest) (uuid.UUID, error)
-	UpdateProperty(context.Context, UpdPropertyRequest) (*Property, error)
+	AddProperty(context.Context, AddPropertyRequest, db.Transaction) (uuid.UUID, error)
+	UpdateProperty(context.Context, UpdPropertyRequest, db.Transaction) (*Property, error)
 	GetProperty(context.Context, uuid.UUID) (*Property, error)
+	GetPropertyOwnerRefTypeID(context.Context, uuid.UUID, db.Transaction) (uuid.UUID, error)
+	GetProperties(context.Context, []uuid.UUID) ([]Property, error)
 	GetPropertySentStateForUpdate(context.Context, uuid.UUID, db.Transaction) (*PropertySentState, error)
 	SetSentProperty(context.Context, PropertySentState, db.Transaction) (*PropertySentState, error)
+	GetPropertyMasks(context.Context, []uuid.UUID) (map[uuid.UUID]MaskPolicy, error)
+	SetPropertyMask(context.Context, uuid.UUID, *MaskPolicy) (*Property, error)
 }
 
 type PropertyBroker interface {
@@ -29,8 +72,15 @@ type Property struct {
 	Name           string
 	Description    string
 	OwnerRefTypeID uuid.UUID
-	Sum            string
-	ChangeAt       time.Time
+	Kind           PropertyKind
+	Sequence       *Sequence
+	StateMachine   *StateMachine
+	// Encrypted properties keep envelopes of their values, they are regular ones not of reference type
+	Encrypted bool
+	// Mask of values in responses and exports, nil if they are not masked
+	Mask     *MaskPolicy
+	Sum      string
+	ChangeAt time.Time
 }
 
 type PropertySentState struct {
@@ -39,18 +89,26 @@ type PropertySentState struct {
 	SentAt time.Time
 }
 
+// AddPropertyRequest with zero ID makes the repository generate it.
 type AddPropertyRequest struct {
+	ID             uuid.UUID
 	Types          []Type
 	RefTypeIDs     []uuid.UUID
 	Name           string
 	Description    string
 	OwnerRefTypeID uuid.UUID
+	Kind           PropertyKind
+	Sequence       *Sequence
+	StateMachine   *StateMachine
+	Encrypted      bool
 }
 
 type UpdPropertyRequest struct {
 	ID          uuid.UUID
 	Name        *string
 	Description *string
+	// ExpectedSum is checked against the current sum of the property if it is not empty
+	ExpectedSum string
 }
 
 type SendPropertyRequest struct {
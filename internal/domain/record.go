@@ -11,11 +11,18 @@ import (
 const DeliveryTypeRecord = "record"
 
 type RecordRepository interface {
-	AddRecord(context.Context, AddRecordRequest) (uuid.UUID, error)
-	UpdateRecord(context.Context, UpdRecordRequest) (*Record, error)
+	AddRecord(context.Context, AddRecordRequest, db.Transaction) (uuid.UUID, error)
+	UpdateRecord(context.Context, UpdRecordRequest, db.Transaction) (*Record, error)
 	GetRecord(context.Context, uuid.UUID) (*Record, error)
+	GetRecordRefTypeID(context.Context, uuid.UUID, db.Transaction) (uuid.UUID, error)
 	GetRecordSentStateForUpdate(context.Context, uuid.UUID, db.Transaction) (*RecordSentState, error)
 	SetSentRecord(context.Context, RecordSentState, db.Transaction) (*RecordSentState, error)
+	GetReferencedBy(context.Context, ReferencedByRequest) ([]RecordReference, error)
+	GetRecordGraph(context.Context, GraphRequest) (*Graph, error)
+	GetRecords(context.Context, []uuid.UUID, bool) ([]RecordWithValues, error)
+	ListRecords(context.Context, ListRecordsRequest) ([]Record, error)
+	GetRecordACLs(context.Context, []uuid.UUID) (map[uuid.UUID]ACL, error)
+	SetRecordACL(context.Context, uuid.UUID, ACL) (*Record, error)
 }
 
 type RecordBroker interface {
@@ -30,6 +37,7 @@ type Record struct {
 	DeletionMark    bool
 	Sum             string
 	ChangeAt        time.Time
+	ACL             ACL
 }
 
 type RecordSentState struct {
@@ -38,7 +46,9 @@ type RecordSentState struct {
 	SentAt time.Time
 }
 
+// AddRecordRequest with zero ID makes the repository generate it.
 type AddRecordRequest struct {
+	ID              uuid.UUID
 	Name            string
 	Description     string
 	DeletionMark    bool
@@ -50,6 +60,34 @@ type UpdRecordRequest struct {
 	Name         *string
 	Description  *string
 	DeletionMark *bool
+	// ExpectedSum is checked against the current sum of the record if it is not empty
+	ExpectedSum string
+}
+
+// ReferencedByRequest selects records which refer to the record by ref values.
+// Zero PropertyID and RefTypeID mean no filter.
+type ReferencedByRequest struct {
+	RecordID   uuid.UUID
+	PropertyID uuid.UUID
+	RefTypeID  uuid.UUID
+	Limit      uint
+	Offset     uint
+}
+
+// ListRecordsRequest with zero RefTypeID selects records of all reference types.
+// Records which ACLs do not allow the principal to read are skipped, nil Principal skips nothing.
+type ListRecordsRequest struct {
+	RefTypeID uuid.UUID
+	Filter    RecordFilter
+	Limit     uint
+	Offset    uint
+	Principal *Principal
+}
+
+type RecordReference struct {
+	RecordID   uuid.UUID
+	PropertyID uuid.UUID
+	RefTypeID  uuid.UUID
 }
 
 type SendRecordRequest struct {
@@ -14,6 +14,7 @@ type RefTypeRepository interface {
 	AddRefType(context.Context, AddRefTypeRequest) (uuid.UUID, error)
 	UpdateRefType(context.Context, UpdRefTypeRequest) (*RefType, error)
 	GetRefType(context.Context, uuid.UUID) (*RefType, error)
+	GetRefTypes(context.Context, []uuid.UUID) ([]RefType, error)
 	GetRefTypeSentStateForUpdate(context.Context, uuid.UUID, db.Transaction) (*RefTypeSentState, error)
 	SetSentRefType(context.Context, RefTypeSentState, db.Transaction) (*RefTypeSentState, error)
 }
@@ -36,7 +37,9 @@ type RefTypeSentState struct {
 	SentAt time.Time
 }
 
+// AddRefTypeRequest with zero ID makes the repository generate it.
 type AddRefTypeRequest struct {
+	ID          uuid.UUID
 	Name        string
 	Description string
 }
@@ -45,6 +48,8 @@ type UpdRefTypeRequest struct {
 	ID          uuid.UUID
 	Name        *string
 	Description *string
+	// ExpectedSum is checked against the current sum of the reference type if it is not empty
+	ExpectedSum string
 }
 
 type SendRefTypeRequest struct {
@@ -0,0 +1,52 @@
+package domain
+
+import "fmt"
+
+const DefaultSequenceTemplate = "{prefix}{n}"
+
+type SequenceResetPeriod uint
+
+const (
+	SequenceResetNever SequenceResetPeriod = iota
+	SequenceResetYearly
+	SequenceResetMonthly
+)
+
+func (srp SequenceResetPeriod) String() string {
+	switch srp {
+	case SequenceResetNever:
+		return "never"
+	case SequenceResetYearly:
+		return "yearly"
+	case SequenceResetMonthly:
+		return "monthly"
+	default:
+		return "unknown"
+	}
+}
+
+func (srp SequenceResetPeriod) Code() string {
+	return srp.String()
+}
+
+func SequenceResetPeriodFromCode(code string) (SequenceResetPeriod, error) {
+	switch code {
+	case "never", "":
+		return SequenceResetNever, nil
+	case "yearly":
+		return SequenceResetYearly, nil
+	case "monthly":
+		return SequenceResetMonthly, nil
+	default:
+		return SequenceResetNever, fmt.Errorf(`%w "%s" of sequence reset period`, ErrUnknownType, code)
+	}
+}
+
+// Sequence describes auto-numbering of a sequence property.
+// Template may contain placeholders {prefix}, {yyyy}, {yy}, {mm} and {n} or {n:<width>}
+// for a number padded by zeros up to width.
+type Sequence struct {
+	Prefix      string
+	Template    string
+	ResetPeriod SequenceResetPeriod
+}
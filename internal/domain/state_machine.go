@@ -0,0 +1,25 @@
+package domain
+
+import "github.com/google/uuid"
+
+// StateMachine restricts values of a state machine property to States.
+// A record gets Initial state first and then moves along Transitions only.
+type StateMachine struct {
+	States      []string
+	Initial     string
+	Transitions []StateTransition
+}
+
+type StateTransition struct {
+	From string
+	To   string
+}
+
+// StateTransitions lists states available from the current State of the record.
+// State is nil while the record has no value of the property.
+type StateTransitions struct {
+	RecordID    uuid.UUID
+	PropertyID  uuid.UUID
+	State       *string
+	Transitions []string
+}
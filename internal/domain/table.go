@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type TableRepository interface {
+	ExportTable(context.Context, TableRequest, TableWriter) error
+}
+
+// TableWriter receives the properties owned by the reference type as columns first
+// and then records of the type row by row.
+type TableWriter interface {
+	WriteHeader([]Property) error
+	WriteRow(TableRow) error
+}
+
+// RecordFilter selects records, zero fields mean no filter.
+// Name is matched as a case insensitive substring.
+type RecordFilter struct {
+	DeletionMark *bool
+	Name         string
+}
+
+type TableRequest struct {
+	RefTypeID uuid.UUID
+	Filter    RecordFilter
+}
+
+type TableRow struct {
+	Record
+	Values []TableValue
+}
+
+// TableValue holds the name of the referenced record in RefName for ref values.
+type TableValue struct {
+	Value
+	RefName string
+}
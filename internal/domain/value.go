@@ -13,11 +13,14 @@ import (
 const DeliveryTypeValue = "value"
 
 type ValueRepository interface {
-	SetValue(context.Context, SetValueRequest) (*Value, error)
+	SetValue(context.Context, SetValueRequest, db.Transaction) (*Value, error)
 	GetValue(context.Context, GetValueRequest) (*Value, error)
 	ChangedValues(context.Context) ([]Value, error)
 	GetValueSentStateForUpdate(context.Context, GetValueRequest, db.Transaction) (*ValueSentState, error)
 	SetSentValue(context.Context, ValueSentState, db.Transaction) (*ValueSentState, error)
+	GetStateTransitions(context.Context, GetValueRequest) (*StateTransitions, error)
+	// IsEncryptedProperty reads the property in the transaction, so properties added by it are found too
+	IsEncryptedProperty(context.Context, uuid.UUID, db.Transaction) (bool, error)
 }
 
 type ValueBroker interface {
@@ -52,20 +55,38 @@ type SetValueRequest struct {
 	Type       Type
 	RefTypeID  uuid.UUID
 	Value      any
+	// ExpectedSum is checked against the current sum of the value if it is not empty,
+	// a missing value does not match any sum
+	ExpectedSum string
 }
 
+// SendValueRequest carries the ACL of the owner record, the record is sent again when its ACL is changed.
 type SendValueRequest struct {
 	Value
+	ACL         ACL
 	TomID       uuid.UUID
 	Exchange    string
 	RoutingKeys []string
 }
 
+// ValueJSONSchema is stored in the value column, values of encrypted properties are stored as envelopes in Enc.
 type ValueJSONSchema struct {
-	V any `json:"v"`
+	V   any                   `json:"v,omitempty"`
+	Enc *EncryptedValueSchema `json:"enc,omitempty"`
+}
+
+// Validated returns the envelope of the encrypted value as is and the validated plain value otherwise.
+func (s ValueJSONSchema) Validated(t Type) (any, error) {
+	if s.Enc != nil {
+		return s.Enc.EncryptedValue(), nil
+	}
+	return ValidatedValue(s.V, t)
 }
 
 func ValueAsJSON(v any, t Type) ([]byte, error) {
+	if ev, ok := v.(EncryptedValue); ok {
+		return json.Marshal(ValueJSONSchema{Enc: EncryptedValueToSchema(ev)})
+	}
 	switch t {
 	case TypeText:
 		switch v.(type) {
@@ -108,7 +129,7 @@ func ValueAsJSON(v any, t Type) ([]byte, error) {
 	default:
 		return nil, fmt.Errorf("%w %s", ErrUnexpectedTypePG, t.String())
 	}
-	return json.Marshal(ValueJSONSchema{v})
+	return json.Marshal(ValueJSONSchema{V: v})
 }
 
 func ValidatedValue(v any, t Type) (any, error) {
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookRepository interface {
+	AddWebhook(context.Context, Webhook) (uuid.UUID, error)
+	UpdateWebhook(context.Context, Webhook) error
+	DeleteWebhook(context.Context, uuid.UUID) error
+	GetWebhooks(context.Context) ([]Webhook, error)
+	GetWebhook(context.Context, uuid.UUID) (*Webhook, error)
+	// GetDueWebhooks returns enabled webhooks which retries are due with their secrets
+	GetDueWebhooks(context.Context) ([]Webhook, error)
+	// SetWebhookState saves the state after the delivery and logs the delivery if it is not nil
+	SetWebhookState(context.Context, uuid.UUID, WebhookState, *WebhookDelivery) error
+	GetWebhookDeliveries(context.Context, uuid.UUID, uint) ([]WebhookDelivery, error)
+	PurgeWebhookDeliveries(context.Context, time.Duration) (int64, error)
+}
+
+// Webhook is delivered changes of the change log which match the filter one by one in order of their IDs.
+// The secret signs deliveries, it is read by the delivering worker only.
+type Webhook struct {
+	ID     uuid.UUID
+	URL    string
+	Secret string
+	Filter ChangeFilter
+	// Enabled webhooks keep changes after LastChangeID in the change log
+	Enabled bool
+	WebhookState
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WebhookState of deliveries. Failures are the failed attempts to deliver the change after LastChangeID,
+// the webhook is disabled with DisabledReason after too many of them.
+type WebhookState struct {
+	LastChangeID   int64
+	Failures       int
+	NextAttemptAt  time.Time
+	DisabledReason string
+}
+
+// WebhookDelivery is the attempt to deliver the change, StatusCode is zero if there is no response.
+type WebhookDelivery struct {
+	ID          int64
+	WebhookID   uuid.UUID
+	ChangeID    int64
+	Attempt     int
+	StatusCode  int
+	Error       string
+	Duration    time.Duration
+	DeliveredAt time.Time
+}
+
+// IsSuccessful reports whether the change is delivered.
+func (d WebhookDelivery) IsSuccessful() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
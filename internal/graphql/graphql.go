@@ -0,0 +1,214 @@
+// Package graphql serves reference types, properties, records and values by the GraphQL schema.
+// Objects referenced by the response are read by batches per request to avoid a query per object.
+package graphql
+
+import (
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// maxQueryDepth limits nesting of selections, e.g. references of references
+	maxQueryDepth = 16
+
+	errorCodeBadRequest = "bad_request"
+	errorCodeInternal   = "internal_server_error"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+type Config struct {
+	RefTypeManager  *api.RefTypeManager
+	RecordManager   *api.RecordManager
+	PropertyManager *api.PropertyManager
+	ValueManager    *api.ValueManager
+	// AuthManager checks permissions of principals of requests to mutations and to objects
+	// of the response by reference types of the objects
+	AuthManager *api.AuthManager
+	// ErrorHandler receives errors which are not exposed to clients
+	ErrorHandler func(error)
+}
+
+type Schema struct {
+	schema *graphql.Schema
+	c      Config
+}
+
+// Request of GraphQL over HTTP.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type Response = graphql.Response
+
+func NewSchema(c Config) (*Schema, error) {
+	if c.RefTypeManager == nil {
+		return nil, fmt.Errorf("reference type manager must be not nil")
+	}
+	if c.RecordManager == nil {
+		return nil, fmt.Errorf("record manager must be not nil")
+	}
+	if c.PropertyManager == nil {
+		return nil, fmt.Errorf("property manager must be not nil")
+	}
+	if c.ValueManager == nil {
+		return nil, fmt.Errorf("value manager must be not nil")
+	}
+	if c.AuthManager == nil {
+		return nil, fmt.Errorf("auth manager must be not nil")
+	}
+	if c.ErrorHandler == nil {
+		c.ErrorHandler = func(error) {}
+	}
+	schema, err := graphql.ParseSchema(schemaSDL, &resolver{c: c}, graphql.UseFieldResolvers(), graphql.MaxDepth(maxQueryDepth))
+	if err != nil {
+		return nil, fmt.Errorf("GraphQL schema error: %s", err)
+	}
+	return &Schema{schema: schema, c: c}, nil
+}
+
+// Exec runs the request with its own loaders.
+func (s *Schema) Exec(ctx context.Context, req Request) *Response {
+	ctx = withLoaders(ctx, newLoaders(s.c))
+	return s.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+}
+
+// Error of a resolver. Its extensions hold the code and the field like problems of the REST API do,
+// messages of internal errors are not exposed.
+type Error struct {
+	err     error
+	code    string
+	field   string
+	details map[string]string
+}
+
+func (e *Error) Error() string {
+	if e.code == errorCodeInternal {
+		return "internal server error"
+	}
+	return e.err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+func (e *Error) Extensions() map[string]any {
+	out := map[string]any{"code": e.code}
+	if e.field != "" {
+		out["field"] = e.field
+	}
+	if len(e.details) > 0 {
+		out["details"] = e.details
+	}
+	return out
+}
+
+// requestError is the error of arguments of the request.
+func requestError(err error) error {
+	out := newError(err)
+	if out.code == errorCodeInternal {
+		out.code = errorCodeBadRequest
+	}
+	return out
+}
+
+// managerError is the error of a manager, errors which are not domain ones are internal.
+func (r *resolver) managerError(err error) error {
+	out := newError(err)
+	if out.code == errorCodeInternal {
+		r.c.ErrorHandler(err)
+	}
+	return out
+}
+
+func newError(err error) *Error {
+	var out *Error
+	if errors.As(err, &out) {
+		return out
+	}
+	out = &Error{
+		err:     err,
+		code:    domain.ErrorCode(err),
+		field:   camelCase(domain.ErrorField(err)),
+		details: domain.ErrorDetails(err),
+	}
+	if out.code == "" {
+		out.code = errorCodeInternal
+		if out.field != "" {
+			out.code = "invalid_field"
+		}
+	}
+	return out
+}
+
+// authorizeMutation checks the write access to GraphQL, the read access is checked before the request is run.
+func (r *resolver) authorizeMutation(ctx context.Context) error {
+	return r.authorize(ctx, unscoped(domain.OperationGraphQL, domain.AccessWrite))
+}
+
+// permission of objects of the request like routes of the REST API have, it is found
+// only for requests made by principals since it may read the object.
+type permission func(ctx context.Context, am *api.AuthManager) (domain.Permission, error)
+
+// authorize checks the permission of the principal of the request.
+func (r *resolver) authorize(ctx context.Context, perm permission) error {
+	p := api.PrincipalFromContext(ctx)
+	if p == nil {
+		return nil
+	}
+	out, err := perm(ctx, r.c.AuthManager)
+	if err != nil {
+		return r.managerError(err)
+	}
+	if err := r.c.AuthManager.Authorize(ctx, p, out); err != nil {
+		return r.managerError(err)
+	}
+	return nil
+}
+
+func unscoped(op domain.Operation, access domain.Access) permission {
+	return refTypeScoped(op, access, uuid.Nil)
+}
+
+// refTypeScoped permissions of objects without the reference type, e.g. properties shared by reference types,
+// need grants for all reference types.
+func refTypeScoped(op domain.Operation, access domain.Access, refTypeID uuid.UUID) permission {
+	return func(context.Context, *api.AuthManager) (domain.Permission, error) {
+		return domain.Permission{Operation: op, Access: access, RefTypeID: refTypeID}, nil
+	}
+}
+
+func recordScoped(op domain.Operation, access domain.Access, recordID uuid.UUID) permission {
+	return func(ctx context.Context, am *api.AuthManager) (domain.Permission, error) {
+		return am.RecordPermission(ctx, op, access, recordID)
+	}
+}
+
+func propertyScoped(op domain.Operation, access domain.Access, propertyID uuid.UUID) permission {
+	return func(ctx context.Context, am *api.AuthManager) (domain.Permission, error) {
+		return am.PropertyPermission(ctx, op, access, propertyID)
+	}
+}
+
+// camelCase converts names of fields of the REST API like reference_type_id to names of arguments like refTypeId.
+func camelCase(s string) string {
+	parts := strings.Split(strings.ReplaceAll(s, "reference_type", "ref_type"), "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,143 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+
+	"datatom/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// loader reads objects by IDs within one request. IDs which are known to be needed soon,
+// e.g. records referenced by loaded values, are queued by prime and the first load reads
+// all queued IDs in one query, so a level of the response costs one query whatever its size.
+type loader[V any] struct {
+	mu     sync.Mutex
+	fetch  func(context.Context, []uuid.UUID) ([]V, error)
+	id     func(V) uuid.UUID
+	loaded func(V)
+	queued map[uuid.UUID]struct{}
+	cache  map[uuid.UUID]*V
+}
+
+func newLoader[V any](fetch func(context.Context, []uuid.UUID) ([]V, error), id func(V) uuid.UUID) *loader[V] {
+	return &loader[V]{
+		fetch:  fetch,
+		id:     id,
+		queued: make(map[uuid.UUID]struct{}),
+		cache:  make(map[uuid.UUID]*V),
+	}
+}
+
+func (l *loader[V]) prime(ids ...uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queue(ids...)
+}
+
+func (l *loader[V]) queue(ids ...uuid.UUID) {
+	for _, id := range ids {
+		if id == uuid.Nil {
+			continue
+		}
+		if _, ok := l.cache[id]; !ok {
+			l.queued[id] = struct{}{}
+		}
+	}
+}
+
+// forget drops the cached object after it is changed by a mutation of the request.
+func (l *loader[V]) forget(id uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.cache, id)
+}
+
+// load returns nil if the object is not found.
+func (l *loader[V]) load(ctx context.Context, id uuid.UUID) (*V, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v, ok := l.cache[id]; ok {
+		return v, nil
+	}
+	l.queue(id)
+	ids := make([]uuid.UUID, 0, len(l.queued))
+	for qid := range l.queued {
+		ids = append(ids, qid)
+	}
+	l.queued = make(map[uuid.UUID]struct{})
+	objects, err := l.fetch(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, qid := range ids {
+		l.cache[qid] = nil
+	}
+	for i := range objects {
+		l.cache[l.id(objects[i])] = &objects[i]
+	}
+	// Objects are primed after they are cached so references between them are not queued
+	if l.loaded != nil {
+		for _, v := range objects {
+			l.loaded(v)
+		}
+	}
+	return l.cache[id], nil
+}
+
+// loaders of one request, they must not be shared by requests to keep results fresh.
+type loaders struct {
+	records    *loader[domain.RecordWithValues]
+	properties *loader[domain.Property]
+	refTypes   *loader[domain.RefType]
+}
+
+func newLoaders(c Config) *loaders {
+	out := &loaders{
+		records: newLoader(func(ctx context.Context, ids []uuid.UUID) ([]domain.RecordWithValues, error) {
+			return c.RecordManager.GetMany(ctx, ids, true)
+		}, func(r domain.RecordWithValues) uuid.UUID {
+			return r.ID
+		}),
+		properties: newLoader(c.PropertyManager.GetMany, func(p domain.Property) uuid.UUID {
+			return p.ID
+		}),
+		refTypes: newLoader(c.RefTypeManager.GetMany, func(rt domain.RefType) uuid.UUID {
+			return rt.ID
+		}),
+	}
+	// Hooks run under the lock of their loader, so referenced records are queued without locking
+	out.records.loaded = func(r domain.RecordWithValues) {
+		out.refTypes.prime(r.ReferenceTypeID)
+		for _, v := range r.Values {
+			out.properties.prime(v.PropertyID)
+			if id, ok := refValueID(v); ok {
+				out.records.queue(id)
+			}
+		}
+	}
+	out.properties.loaded = func(p domain.Property) {
+		out.refTypes.prime(p.OwnerRefTypeID)
+		out.refTypes.prime(p.RefTypeIDs...)
+	}
+	return out
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+func refValueID(v domain.Value) (uuid.UUID, bool) {
+	if v.Type != domain.TypeReference {
+		return uuid.Nil, false
+	}
+	id, ok := v.Value.(uuid.UUID)
+	return id, ok
+}
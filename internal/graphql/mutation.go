@@ -0,0 +1,304 @@
+package graphql
+
+import (
+	"context"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"fmt"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+// Mutations parse arguments by request schemas of the REST API, so both APIs accept the same values.
+// Besides the write access to GraphQL they need permissions the routes of the REST API need,
+// scoped by reference types of objects the mutations change.
+
+type addRefTypeInput struct {
+	ID          *graphql.ID
+	Name        string
+	Description *string
+}
+
+func (r *resolver) AddRefType(ctx context.Context, args struct{ Input addRefTypeInput }) (*refTypeResolver, error) {
+	if err := r.authorizeMutation(ctx); err != nil {
+		return nil, err
+	}
+	req, err := handlers.AddRefTypeRequestSchema{
+		ID:          idString(args.Input.ID),
+		Name:        args.Input.Name,
+		Description: value(args.Input.Description),
+	}.AddRefTypeRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	if err := r.authorize(ctx, unscoped(domain.OperationRefTypes, domain.AccessWrite)); err != nil {
+		return nil, err
+	}
+	id, err := r.c.RefTypeManager.Add(ctx, req)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	rt, err := r.c.RefTypeManager.Get(ctx, id)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	return &refTypeResolver{refType: *rt}, nil
+}
+
+type updateRefTypeInput struct {
+	ID          graphql.ID
+	Name        *string
+	Description *string
+	ExpectedSum *string
+}
+
+func (r *resolver) UpdateRefType(ctx context.Context, args struct{ Input updateRefTypeInput }) (*refTypeResolver, error) {
+	if err := r.authorizeMutation(ctx); err != nil {
+		return nil, err
+	}
+	schema := handlers.UpdRefTypeRequestSchema{
+		ID:          string(args.Input.ID),
+		Name:        args.Input.Name,
+		Description: args.Input.Description,
+		ExpectedSum: value(args.Input.ExpectedSum),
+	}
+	req, err := schema.UpdRefTypeRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	if err := r.authorize(ctx, refTypeScoped(domain.OperationRefTypes, domain.AccessWrite, req.ID)); err != nil {
+		return nil, err
+	}
+	rt, err := r.c.RefTypeManager.Update(ctx, req)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	loadersFrom(ctx).refTypes.forget(rt.ID)
+	return &refTypeResolver{refType: *rt}, nil
+}
+
+type addPropertyInput struct {
+	ID             *graphql.ID
+	Name           string
+	Description    *string
+	Types          *[]string
+	RefTypeIDs     *[]graphql.ID
+	OwnerRefTypeID *graphql.ID
+	Kind           *string
+	Sequence       *sequenceInput
+	StateMachine   *stateMachineInput
+	Encrypted      *bool
+}
+
+type sequenceInput struct {
+	Prefix      *string
+	Template    string
+	ResetPeriod *string
+}
+
+type stateMachineInput struct {
+	States      []string
+	Initial     string
+	Transitions []stateTransitionInput
+}
+
+type stateTransitionInput struct {
+	From string
+	To   string
+}
+
+func (r *resolver) AddProperty(ctx context.Context, args struct{ Input addPropertyInput }) (*propertyResolver, error) {
+	if err := r.authorizeMutation(ctx); err != nil {
+		return nil, err
+	}
+	in := args.Input
+	schema := handlers.AddPropertyRequestSchema{
+		ID:             idString(in.ID),
+		Name:           in.Name,
+		Description:    value(in.Description),
+		Types:          value(in.Types),
+		OwnerRefTypeID: idString(in.OwnerRefTypeID),
+		Kind:           value(in.Kind),
+		Encrypted:      value(in.Encrypted),
+	}
+	for _, id := range value(in.RefTypeIDs) {
+		schema.RefTypeIDs = append(schema.RefTypeIDs, string(id))
+	}
+	if in.Sequence != nil {
+		schema.Sequence = &handlers.PropertySequenceSchema{
+			Prefix:      value(in.Sequence.Prefix),
+			Template:    in.Sequence.Template,
+			ResetPeriod: value(in.Sequence.ResetPeriod),
+		}
+	}
+	if in.StateMachine != nil {
+		schema.StateMachine = &handlers.PropertyStateMachineSchema{
+			States:  in.StateMachine.States,
+			Initial: in.StateMachine.Initial,
+		}
+		for _, t := range in.StateMachine.Transitions {
+			schema.StateMachine.Transitions = append(schema.StateMachine.Transitions, handlers.PropertyStateTransitionSchema{From: t.From, To: t.To})
+		}
+	}
+	req, unknownTypes, err := schema.AddPropertyRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	if len(unknownTypes) > 0 {
+		return nil, requestError(domain.NewFieldError("types", fmt.Errorf("unknown types: %v", unknownTypes)))
+	}
+	if len(req.Types) == 0 {
+		return nil, requestError(domain.NewFieldError("types", fmt.Errorf("types %w", domain.ErrExpected)))
+	}
+	if err := r.authorize(ctx, refTypeScoped(domain.OperationProperties, domain.AccessWrite, req.OwnerRefTypeID)); err != nil {
+		return nil, err
+	}
+	id, err := r.c.PropertyManager.Add(ctx, req)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	p, err := r.c.PropertyManager.Get(ctx, id)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	return &propertyResolver{r: r, property: *p}, nil
+}
+
+type updatePropertyInput struct {
+	ID          graphql.ID
+	Name        *string
+	Description *string
+	ExpectedSum *string
+}
+
+func (r *resolver) UpdateProperty(ctx context.Context, args struct{ Input updatePropertyInput }) (*propertyResolver, error) {
+	if err := r.authorizeMutation(ctx); err != nil {
+		return nil, err
+	}
+	schema := handlers.UpdPropertyRequestSchema{
+		ID:          string(args.Input.ID),
+		Name:        args.Input.Name,
+		Description: args.Input.Description,
+		ExpectedSum: value(args.Input.ExpectedSum),
+	}
+	req, err := schema.UpdPropertyRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	if err := r.authorize(ctx, propertyScoped(domain.OperationProperties, domain.AccessWrite, req.ID)); err != nil {
+		return nil, err
+	}
+	p, err := r.c.PropertyManager.Update(ctx, req)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	loadersFrom(ctx).properties.forget(p.ID)
+	return &propertyResolver{r: r, property: *p}, nil
+}
+
+type addRecordInput struct {
+	ID           *graphql.ID
+	RefTypeID    *graphql.ID
+	Name         string
+	Description  *string
+	DeletionMark *bool
+}
+
+func (r *resolver) AddRecord(ctx context.Context, args struct{ Input addRecordInput }) (*recordResolver, error) {
+	if err := r.authorizeMutation(ctx); err != nil {
+		return nil, err
+	}
+	req, err := handlers.AddRecordRequestSchema{
+		ID:              idString(args.Input.ID),
+		ReferenceTypeID: idString(args.Input.RefTypeID),
+		Name:            args.Input.Name,
+		Description:     value(args.Input.Description),
+		DeletionMark:    value(args.Input.DeletionMark),
+	}.AddRecordRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	if err := r.authorize(ctx, refTypeScoped(domain.OperationRecords, domain.AccessWrite, req.ReferenceTypeID)); err != nil {
+		return nil, err
+	}
+	id, err := r.c.RecordManager.Add(ctx, req)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	rec, err := r.c.RecordManager.Get(ctx, id)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	return &recordResolver{r: r, record: *rec}, nil
+}
+
+type updateRecordInput struct {
+	ID           graphql.ID
+	Name         *string
+	Description  *string
+	DeletionMark *bool
+	ExpectedSum  *string
+}
+
+func (r *resolver) UpdateRecord(ctx context.Context, args struct{ Input updateRecordInput }) (*recordResolver, error) {
+	if err := r.authorizeMutation(ctx); err != nil {
+		return nil, err
+	}
+	req, err := handlers.UpdRecordRequestSchema{
+		ID:           string(args.Input.ID),
+		Name:         args.Input.Name,
+		Description:  args.Input.Description,
+		DeletionMark: args.Input.DeletionMark,
+		ExpectedSum:  value(args.Input.ExpectedSum),
+	}.UpdRecordRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	if err := r.authorize(ctx, recordScoped(domain.OperationRecords, domain.AccessWrite, req.ID)); err != nil {
+		return nil, err
+	}
+	rec, err := r.c.RecordManager.Update(ctx, req)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	loadersFrom(ctx).records.forget(rec.ID)
+	return &recordResolver{r: r, record: *rec}, nil
+}
+
+type setValueInput struct {
+	RecordID    graphql.ID
+	PropertyID  graphql.ID
+	Type        string
+	RefTypeID   *graphql.ID
+	Value       *JSON
+	ExpectedSum *string
+}
+
+func (r *resolver) SetValue(ctx context.Context, args struct{ Input setValueInput }) (*valueResolver, error) {
+	if err := r.authorizeMutation(ctx); err != nil {
+		return nil, err
+	}
+	schema := handlers.SetValueRequestSchema{
+		RecordID:    string(args.Input.RecordID),
+		PropertyID:  string(args.Input.PropertyID),
+		Type:        args.Input.Type,
+		RefTypeID:   idString(args.Input.RefTypeID),
+		ExpectedSum: value(args.Input.ExpectedSum),
+	}
+	if args.Input.Value != nil {
+		schema.Value = args.Input.Value.Value
+	}
+	req, err := schema.SetValueRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	if err := r.authorize(ctx, recordScoped(domain.OperationValues, domain.AccessWrite, req.RecordID)); err != nil {
+		return nil, err
+	}
+	v, err := r.c.ValueManager.Set(ctx, req)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	loadersFrom(ctx).records.forget(v.RecordID)
+	return &valueResolver{r: r, value: *v}, nil
+}
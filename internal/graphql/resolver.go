@@ -0,0 +1,494 @@
+package graphql
+
+import (
+	"context"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// resolver of queries and mutations. Objects of responses are read by principals which are granted
+// to read them by their reference types, whatever the level of the response they are resolved at.
+type resolver struct {
+	c Config
+}
+
+func (r *resolver) RefType(ctx context.Context, args struct{ ID graphql.ID }) (*refTypeResolver, error) {
+	id, err := parseID("id", args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return r.loadRefType(ctx, id)
+}
+
+func (r *resolver) Property(ctx context.Context, args struct{ ID graphql.ID }) (*propertyResolver, error) {
+	id, err := parseID("id", args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return r.loadProperty(ctx, id)
+}
+
+func (r *resolver) Record(ctx context.Context, args struct{ ID graphql.ID }) (*recordResolver, error) {
+	id, err := parseID("id", args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return r.loadRecord(ctx, id)
+}
+
+type recordFilterInput struct {
+	DeletionMark *bool
+	Name         *string
+}
+
+func (r *resolver) Records(ctx context.Context, args struct {
+	RefTypeID *graphql.ID
+	Filter    *recordFilterInput
+	Limit     *int32
+	Offset    *int32
+}) ([]*recordResolver, error) {
+	req := domain.ListRecordsRequest{Limit: defaultPageLimit}
+	if args.RefTypeID != nil {
+		id, err := parseID("refTypeId", *args.RefTypeID)
+		if err != nil {
+			return nil, err
+		}
+		req.RefTypeID = id
+	}
+	if args.Filter != nil {
+		req.Filter.DeletionMark = args.Filter.DeletionMark
+		req.Filter.Name = value(args.Filter.Name)
+	}
+	if args.Limit != nil {
+		if *args.Limit < 1 || *args.Limit > maxPageLimit {
+			return nil, requestError(domain.NewFieldError("limit", fmt.Errorf("limit must be between 1 and %d", maxPageLimit)))
+		}
+		req.Limit = uint(*args.Limit)
+	}
+	if args.Offset != nil {
+		if *args.Offset < 0 {
+			return nil, requestError(domain.NewFieldError("offset", errors.New("offset must not be negative")))
+		}
+		req.Offset = uint(*args.Offset)
+	}
+	// Records of all reference types are listed to principals which are granted to read all of them
+	if err := r.authorize(ctx, refTypeScoped(domain.OperationRecords, domain.AccessRead, req.RefTypeID)); err != nil {
+		return nil, err
+	}
+	records, err := r.c.RecordManager.List(ctx, req)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	// Values of the listed records are read by one query when the first of them is resolved
+	l := loadersFrom(ctx)
+	out := make([]*recordResolver, 0, len(records))
+	for _, rec := range records {
+		l.records.prime(rec.ID)
+		l.refTypes.prime(rec.ReferenceTypeID)
+		out = append(out, &recordResolver{r: r, record: rec})
+	}
+	return out, nil
+}
+
+func (r *resolver) Value(ctx context.Context, args struct {
+	RecordID   graphql.ID
+	PropertyID graphql.ID
+}) (*valueResolver, error) {
+	req, err := handlers.GetValueRequestSchema{RecordID: string(args.RecordID), PropertyID: string(args.PropertyID)}.GetValueRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	if err := r.authorize(ctx, recordScoped(domain.OperationValues, domain.AccessRead, req.RecordID)); err != nil {
+		return nil, err
+	}
+	v, err := r.c.ValueManager.Get(ctx, req)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, r.managerError(err)
+	}
+	return &valueResolver{r: r, value: *v}, nil
+}
+
+func (r *resolver) loadRefType(ctx context.Context, id uuid.UUID) (*refTypeResolver, error) {
+	rt, err := loadersFrom(ctx).refTypes.load(ctx, id)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	if rt == nil {
+		return nil, nil
+	}
+	if err := r.authorize(ctx, refTypeScoped(domain.OperationRefTypes, domain.AccessRead, rt.ID)); err != nil {
+		return nil, err
+	}
+	return &refTypeResolver{refType: *rt}, nil
+}
+
+func (r *resolver) loadProperty(ctx context.Context, id uuid.UUID) (*propertyResolver, error) {
+	p, err := loadersFrom(ctx).properties.load(ctx, id)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	if p == nil {
+		return nil, nil
+	}
+	if err := r.authorize(ctx, refTypeScoped(domain.OperationProperties, domain.AccessRead, p.OwnerRefTypeID)); err != nil {
+		return nil, err
+	}
+	return &propertyResolver{r: r, property: *p}, nil
+}
+
+func (r *resolver) loadRecord(ctx context.Context, id uuid.UUID) (*recordResolver, error) {
+	rec, err := loadersFrom(ctx).records.load(ctx, id)
+	if err != nil {
+		return nil, r.managerError(err)
+	}
+	if rec == nil {
+		return nil, nil
+	}
+	if err := r.authorize(ctx, refTypeScoped(domain.OperationRecords, domain.AccessRead, rec.ReferenceTypeID)); err != nil {
+		return nil, err
+	}
+	return &recordResolver{r: r, record: rec.Record, values: rec.Values, loaded: true}, nil
+}
+
+type refTypeResolver struct {
+	refType domain.RefType
+}
+
+func (rtr *refTypeResolver) ID() graphql.ID {
+	return graphql.ID(rtr.refType.ID.String())
+}
+
+func (rtr *refTypeResolver) Name() string {
+	return rtr.refType.Name
+}
+
+func (rtr *refTypeResolver) Description() string {
+	return rtr.refType.Description
+}
+
+func (rtr *refTypeResolver) Sum() string {
+	return rtr.refType.Sum
+}
+
+func (rtr *refTypeResolver) ChangeAt() graphql.Time {
+	return graphql.Time{Time: rtr.refType.ChangeAt}
+}
+
+type propertyResolver struct {
+	r        *resolver
+	property domain.Property
+}
+
+func (pr *propertyResolver) ID() graphql.ID {
+	return graphql.ID(pr.property.ID.String())
+}
+
+func (pr *propertyResolver) Name() string {
+	return pr.property.Name
+}
+
+func (pr *propertyResolver) Description() string {
+	return pr.property.Description
+}
+
+func (pr *propertyResolver) Types() []string {
+	return domain.TypesToCodes(pr.property.Types)
+}
+
+func (pr *propertyResolver) RefTypeIDs() []graphql.ID {
+	out := make([]graphql.ID, 0, len(pr.property.RefTypeIDs))
+	for _, id := range pr.property.RefTypeIDs {
+		out = append(out, graphql.ID(id.String()))
+	}
+	return out
+}
+
+func (pr *propertyResolver) RefTypes(ctx context.Context) ([]*refTypeResolver, error) {
+	out := make([]*refTypeResolver, 0, len(pr.property.RefTypeIDs))
+	for _, id := range pr.property.RefTypeIDs {
+		rt, err := pr.r.loadRefType(ctx, id)
+		if err != nil {
+			// Reference types the principal is not granted to read are not listed
+			if errors.Is(err, domain.ErrForbidden) {
+				continue
+			}
+			return nil, err
+		}
+		if rt != nil {
+			out = append(out, rt)
+		}
+	}
+	return out, nil
+}
+
+func (pr *propertyResolver) OwnerRefTypeID() *graphql.ID {
+	return optionalID(pr.property.OwnerRefTypeID)
+}
+
+func (pr *propertyResolver) OwnerRefType(ctx context.Context) (*refTypeResolver, error) {
+	if pr.property.OwnerRefTypeID == uuid.Nil {
+		return nil, nil
+	}
+	return pr.r.loadRefType(ctx, pr.property.OwnerRefTypeID)
+}
+
+func (pr *propertyResolver) Kind() string {
+	return pr.property.Kind.Code()
+}
+
+func (pr *propertyResolver) Sequence() *handlers.PropertySequenceSchema {
+	return handlers.SequenceToSchema(pr.property.Sequence)
+}
+
+func (pr *propertyResolver) StateMachine() *handlers.PropertyStateMachineSchema {
+	return handlers.StateMachineToSchema(pr.property.StateMachine)
+}
+
+func (pr *propertyResolver) Encrypted() bool {
+	return pr.property.Encrypted
+}
+
+func (pr *propertyResolver) Sum() string {
+	return pr.property.Sum
+}
+
+func (pr *propertyResolver) ChangeAt() graphql.Time {
+	return graphql.Time{Time: pr.property.ChangeAt}
+}
+
+// recordResolver reads values by the loader unless they are loaded with the record.
+type recordResolver struct {
+	r      *resolver
+	record domain.Record
+	values []domain.Value
+	loaded bool
+}
+
+func (rr *recordResolver) ID() graphql.ID {
+	return graphql.ID(rr.record.ID.String())
+}
+
+func (rr *recordResolver) RefTypeID() *graphql.ID {
+	return optionalID(rr.record.ReferenceTypeID)
+}
+
+func (rr *recordResolver) RefType(ctx context.Context) (*refTypeResolver, error) {
+	if rr.record.ReferenceTypeID == uuid.Nil {
+		return nil, nil
+	}
+	return rr.r.loadRefType(ctx, rr.record.ReferenceTypeID)
+}
+
+func (rr *recordResolver) Name() string {
+	return rr.record.Name
+}
+
+func (rr *recordResolver) Description() string {
+	return rr.record.Description
+}
+
+func (rr *recordResolver) DeletionMark() bool {
+	return rr.record.DeletionMark
+}
+
+func (rr *recordResolver) Sum() string {
+	return rr.record.Sum
+}
+
+func (rr *recordResolver) ChangeAt() graphql.Time {
+	return graphql.Time{Time: rr.record.ChangeAt}
+}
+
+func (rr *recordResolver) Values(ctx context.Context) ([]*valueResolver, error) {
+	values, err := rr.loadValues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*valueResolver, 0, len(values))
+	for _, v := range values {
+		out = append(out, &valueResolver{r: rr.r, value: v})
+	}
+	return out, nil
+}
+
+func (rr *recordResolver) Value(ctx context.Context, args struct{ PropertyID graphql.ID }) (*valueResolver, error) {
+	id, err := parseID("propertyId", args.PropertyID)
+	if err != nil {
+		return nil, err
+	}
+	values, err := rr.loadValues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		if v.PropertyID == id {
+			return &valueResolver{r: rr.r, value: v}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (rr *recordResolver) loadValues(ctx context.Context) ([]domain.Value, error) {
+	if err := rr.r.authorize(ctx, refTypeScoped(domain.OperationValues, domain.AccessRead, rr.record.ReferenceTypeID)); err != nil {
+		return nil, err
+	}
+	if rr.loaded {
+		return rr.values, nil
+	}
+	rec, err := loadersFrom(ctx).records.load(ctx, rr.record.ID)
+	if err != nil {
+		return nil, rr.r.managerError(err)
+	}
+	if rec == nil {
+		return nil, nil
+	}
+	return rec.Values, nil
+}
+
+type valueResolver struct {
+	r     *resolver
+	value domain.Value
+}
+
+func (vr *valueResolver) RecordID() graphql.ID {
+	return graphql.ID(vr.value.RecordID.String())
+}
+
+func (vr *valueResolver) Record(ctx context.Context) (*recordResolver, error) {
+	return vr.r.loadRecord(ctx, vr.value.RecordID)
+}
+
+func (vr *valueResolver) PropertyID() graphql.ID {
+	return graphql.ID(vr.value.PropertyID.String())
+}
+
+func (vr *valueResolver) Property(ctx context.Context) (*propertyResolver, error) {
+	return vr.r.loadProperty(ctx, vr.value.PropertyID)
+}
+
+func (vr *valueResolver) Type() string {
+	return vr.value.Type.Code()
+}
+
+func (vr *valueResolver) RefTypeID() *graphql.ID {
+	return optionalID(vr.value.RefTypeID)
+}
+
+func (vr *valueResolver) Value() *JSON {
+	v := vr.value.Value
+	if mv, ok := v.(domain.MaskedValue); ok {
+		v = mv.Value
+	}
+	if v == nil {
+		return nil
+	}
+	return &JSON{Value: v}
+}
+
+func (vr *valueResolver) Masked() bool {
+	_, ok := vr.value.Value.(domain.MaskedValue)
+	return ok
+}
+
+func (vr *valueResolver) Ref(ctx context.Context) (*recordResolver, error) {
+	id, ok := refValueID(vr.value)
+	if !ok {
+		return nil, nil
+	}
+	return vr.r.loadRecord(ctx, id)
+}
+
+func (vr *valueResolver) Sum() string {
+	return vr.value.Sum
+}
+
+func (vr *valueResolver) ChangeAt() graphql.Time {
+	return graphql.Time{Time: vr.value.ChangeAt}
+}
+
+// JSON scalar holds values of any type. Integers of literals are converted to floats
+// like numbers of JSON documents are.
+type JSON struct {
+	Value any
+}
+
+func (JSON) ImplementsGraphQLType(name string) bool {
+	return name == "JSON"
+}
+
+func (j *JSON) UnmarshalGraphQL(input any) error {
+	j.Value = jsonValue(input)
+	return nil
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.Value)
+}
+
+func jsonValue(v any) any {
+	switch x := v.(type) {
+	case int32:
+		return float64(x)
+	case int:
+		return float64(x)
+	case []any:
+		out := make([]any, 0, len(x))
+		for _, item := range x {
+			out = append(out, jsonValue(item))
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, item := range x {
+			out[k] = jsonValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func parseID(field string, id graphql.ID) (uuid.UUID, error) {
+	out, err := uuid.Parse(string(id))
+	if err != nil {
+		return out, requestError(domain.NewFieldError(field, fmt.Errorf("parse %s error: %s", field, err)))
+	}
+	return out, nil
+}
+
+func optionalID(id uuid.UUID) *graphql.ID {
+	if id == uuid.Nil {
+		return nil
+	}
+	out := graphql.ID(id.String())
+	return &out
+}
+
+func idString(id *graphql.ID) string {
+	if id == nil {
+		return ""
+	}
+	return string(*id)
+}
+
+func value[T any](p *T) T {
+	var out T
+	if p != nil {
+		out = *p
+	}
+	return out
+}
@@ -48,6 +48,7 @@ func (c Connection) dial() (*grpc.ClientConn, error) {
 	return grpc.Dial(
 		c.address,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(tracingUnaryInterceptor),
 	)
 }
 
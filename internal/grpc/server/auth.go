@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/internal/pb"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// methodPermission returns the permission the request of the method needs.
+type methodPermission func(ctx context.Context, am *api.AuthManager, in any) (domain.Permission, error)
+
+// methodPermissions of methods of the Datatom service like routes of the REST API have them,
+// the server is not created if any method is missing here.
+var methodPermissions = map[string]methodPermission{
+	pb.Datatom_AddRefType_FullMethodName:    unscoped(domain.OperationRefTypes, domain.AccessWrite),
+	pb.Datatom_UpdateRefType_FullMethodName: refTypeScoped(domain.OperationRefTypes, domain.AccessWrite, (*pb.UpdateRefTypeRequest).GetId),
+	pb.Datatom_GetRefType_FullMethodName:    refTypeScoped(domain.OperationRefTypes, domain.AccessRead, idOf),
+
+	pb.Datatom_AddProperty_FullMethodName:    refTypeScoped(domain.OperationProperties, domain.AccessWrite, (*pb.AddPropertyRequest).GetOwnerReferenceTypeId),
+	pb.Datatom_UpdateProperty_FullMethodName: propertyScoped(domain.OperationProperties, domain.AccessWrite, (*pb.UpdatePropertyRequest).GetId),
+	pb.Datatom_GetProperty_FullMethodName:    propertyScoped(domain.OperationProperties, domain.AccessRead, idOf),
+
+	pb.Datatom_AddRecord_FullMethodName:       refTypeScoped(domain.OperationRecords, domain.AccessWrite, (*pb.AddRecordRequest).GetReferenceTypeId),
+	pb.Datatom_UpdateRecord_FullMethodName:    recordScoped(domain.OperationRecords, domain.AccessWrite, (*pb.UpdateRecordRequest).GetId),
+	pb.Datatom_GetRecord_FullMethodName:       recordScoped(domain.OperationRecords, domain.AccessRead, idOf),
+	pb.Datatom_GetReferencedBy_FullMethodName: recordScoped(domain.OperationRecords, domain.AccessRead, (*pb.ReferencedByRequest).GetId),
+	pb.Datatom_ListRecords_FullMethodName:     refTypeScoped(domain.OperationRecords, domain.AccessRead, (*pb.ListRecordsRequest).GetReferenceTypeId),
+	// Records of the request may be of any reference type
+	pb.Datatom_GetRecords_FullMethodName: unscoped(domain.OperationRecords, domain.AccessRead),
+
+	pb.Datatom_SetValue_FullMethodName:            recordScoped(domain.OperationValues, domain.AccessWrite, (*pb.SetValueRequest).GetRecordId),
+	pb.Datatom_GetValue_FullMethodName:            recordScoped(domain.OperationValues, domain.AccessRead, (*pb.GetValueRequest).GetRecordId),
+	pb.Datatom_GetStateTransitions_FullMethodName: recordScoped(domain.OperationValues, domain.AccessRead, (*pb.GetValueRequest).GetRecordId),
+
+	pb.Datatom_ExportTable_FullMethodName: refTypeScoped(domain.OperationExport, domain.AccessRead, (*pb.ExportTableRequest).GetReferenceTypeId),
+}
+
+func checkMethodPermissions() error {
+	for _, m := range pb.Datatom_ServiceDesc.Methods {
+		if err := checkMethodPermission(m.MethodName); err != nil {
+			return err
+		}
+	}
+	for _, s := range pb.Datatom_ServiceDesc.Streams {
+		if err := checkMethodPermission(s.StreamName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkMethodPermission(name string) error {
+	if _, ok := methodPermissions[fmt.Sprintf("/%s/%s", pb.Datatom_ServiceDesc.ServiceName, name)]; !ok {
+		return fmt.Errorf("permission of method %s is not set", name)
+	}
+	return nil
+}
+
+func idOf(in *pb.UUID) *pb.UUID {
+	return in
+}
+
+// scopeID returns false if the ID is not set or not valid.
+func scopeID(in *pb.UUID) (uuid.UUID, bool) {
+	if in == nil {
+		return uuid.Nil, false
+	}
+	out, err := pb.UUIDFromPb(in)
+	return out, err == nil
+}
+
+func unscoped(op domain.Operation, access domain.Access) methodPermission {
+	return func(context.Context, *api.AuthManager, any) (domain.Permission, error) {
+		return domain.Permission{Operation: op, Access: access}, nil
+	}
+}
+
+// Requests with IDs which are not valid are not scoped, so they need grants for all reference types.
+
+func refTypeScoped[T any](op domain.Operation, access domain.Access, id func(T) *pb.UUID) methodPermission {
+	return func(_ context.Context, _ *api.AuthManager, in any) (domain.Permission, error) {
+		out := domain.Permission{Operation: op, Access: access}
+		if t, ok := in.(T); ok {
+			out.RefTypeID, _ = scopeID(id(t))
+		}
+		return out, nil
+	}
+}
+
+func recordScoped[T any](op domain.Operation, access domain.Access, id func(T) *pb.UUID) methodPermission {
+	return func(ctx context.Context, am *api.AuthManager, in any) (domain.Permission, error) {
+		t, ok := in.(T)
+		if !ok {
+			return domain.Permission{Operation: op, Access: access}, nil
+		}
+		recordID, ok := scopeID(id(t))
+		if !ok {
+			return domain.Permission{Operation: op, Access: access}, nil
+		}
+		return am.RecordPermission(ctx, op, access, recordID)
+	}
+}
+
+func propertyScoped[T any](op domain.Operation, access domain.Access, id func(T) *pb.UUID) methodPermission {
+	return func(ctx context.Context, am *api.AuthManager, in any) (domain.Permission, error) {
+		t, ok := in.(T)
+		if !ok {
+			return domain.Permission{Operation: op, Access: access}, nil
+		}
+		propertyID, ok := scopeID(id(t))
+		if !ok {
+			return domain.Permission{Operation: op, Access: access}, nil
+		}
+		return am.PropertyPermission(ctx, op, access, propertyID)
+	}
+}
+
+// authenticator checks credentials of the authorization and x-api-key metadata
+// and permissions of the first request message of the call.
+type authenticator struct {
+	man          *api.AuthManager
+	errorHandler func(error)
+}
+
+// authenticate returns the context with the principal, methods of other services like reflection are public.
+func (a authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if !a.man.Enabled() || !strings.HasPrefix(method, "/"+pb.Datatom_ServiceDesc.ServiceName+"/") {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	res, p, err := handlers.Authenticate(ctx, a.man, handlers.AuthRequestSchema{
+		Authorization: firstValue(md, "authorization"),
+		APIKey:        firstValue(md, "x-api-key"),
+	})
+	if err != nil {
+		return nil, a.statusError(res.Status, err)
+	}
+	return api.ContextWithPrincipal(ctx, p), nil
+}
+
+func (a authenticator) authorize(ctx context.Context, method string, in any) error {
+	p := api.PrincipalFromContext(ctx)
+	if p == nil {
+		return nil
+	}
+	permission, ok := methodPermissions[method]
+	if !ok {
+		return newStatusError(codes.PermissionDenied, fmt.Errorf("%w: method %s", domain.ErrForbidden, method), "")
+	}
+	perm, err := permission(ctx, a.man, in)
+	if err != nil {
+		a.errorHandler(err)
+		return newStatusError(codes.Internal, errors.New("internal server error"), errorCodeInternal)
+	}
+	res, err := handlers.Authorize(ctx, a.man, p, perm)
+	if err != nil {
+		return a.statusError(res.Status, err)
+	}
+	return nil
+}
+
+// statusError converts the REST status of the auth error to the gRPC one.
+func (a authenticator) statusError(status int, err error) error {
+	switch status {
+	case http.StatusUnauthorized:
+		return newStatusError(codes.Unauthenticated, err, "")
+	case http.StatusForbidden:
+		return newStatusError(codes.PermissionDenied, err, "")
+	}
+	a.errorHandler(err)
+	return newStatusError(codes.Internal, errors.New("internal server error"), errorCodeInternal)
+}
+
+func (a authenticator) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.authorize(ctx, info.FullMethod, req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a authenticator) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx, auth: a, method: info.FullMethod})
+}
+
+// authorizedStream checks permissions of the request before it is passed to the handler,
+// streams of the service receive the only request message.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	auth   authenticator
+	method string
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authorizedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.auth.authorize(s.ctx, s.method, m)
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) != 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"datatom/internal/domain"
+	"datatom/internal/pb"
+	"datatom/pkg/helper"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Requests are converted to request schemas of the REST API and parsed by them, so both APIs accept the same values.
+
+// idStrings converts IDs of a request to strings of request schemas and keeps the first error.
+type idStrings struct {
+	err error
+}
+
+// get returns an empty string if the ID is not set.
+func (s *idStrings) get(field string, id *pb.UUID) string {
+	if id == nil || len(id.Value) == 0 || s.err != nil {
+		return ""
+	}
+	out, err := pb.UUIDFromPb(id)
+	if err != nil {
+		s.err = domain.NewFieldError(field, fmt.Errorf("parse %s error: %s", field, err))
+		return ""
+	}
+	return out.String()
+}
+
+func optionalUUIDToPb(id uuid.UUID) *pb.UUID {
+	if helper.IsZeroUUID(id) {
+		return nil
+	}
+	return pb.UUIDToPb(id)
+}
+
+func timeToPb(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func refTypeToPb(rt domain.RefType) *pb.RefType {
+	return &pb.RefType{
+		Id:          pb.UUIDToPb(rt.ID),
+		Name:        rt.Name,
+		Description: rt.Description,
+		Sum:         rt.Sum,
+		ChangeAt:    timeToPb(rt.ChangeAt),
+	}
+}
+
+func propertyToPb(p domain.Property) *pb.Property {
+	out := &pb.Property{
+		Id:                   pb.UUIDToPb(p.ID),
+		Name:                 p.Name,
+		Description:          p.Description,
+		Types:                domain.TypesToCodes(p.Types),
+		OwnerReferenceTypeId: optionalUUIDToPb(p.OwnerRefTypeID),
+		Kind:                 p.Kind.Code(),
+		Sum:                  p.Sum,
+		ChangeAt:             timeToPb(p.ChangeAt),
+	}
+	for _, id := range p.RefTypeIDs {
+		out.ReferenceTypeIds = append(out.ReferenceTypeIds, pb.UUIDToPb(id))
+	}
+	if p.Sequence != nil {
+		out.Sequence = &pb.Sequence{
+			Prefix:      p.Sequence.Prefix,
+			Template:    p.Sequence.Template,
+			ResetPeriod: p.Sequence.ResetPeriod.Code(),
+		}
+	}
+	if p.StateMachine != nil {
+		out.StateMachine = &pb.StateMachine{
+			States:  p.StateMachine.States,
+			Initial: p.StateMachine.Initial,
+		}
+		for _, t := range p.StateMachine.Transitions {
+			out.StateMachine.Transitions = append(out.StateMachine.Transitions, &pb.StateTransition{From: t.From, To: t.To})
+		}
+	}
+	return out
+}
+
+func recordToPb(r domain.Record) *pb.Record {
+	return &pb.Record{
+		Id:              pb.UUIDToPb(r.ID),
+		ReferenceTypeId: optionalUUIDToPb(r.ReferenceTypeID),
+		Name:            r.Name,
+		Description:     r.Description,
+		DeletionMark:    r.DeletionMark,
+		Sum:             r.Sum,
+		ChangeAt:        timeToPb(r.ChangeAt),
+	}
+}
+
+func recordWithValuesToPb(r domain.RecordWithValues) (*pb.Record, error) {
+	out := recordToPb(r.Record)
+	for _, v := range r.Values {
+		value, err := valueToPb(v)
+		if err != nil {
+			return nil, err
+		}
+		out.Values = append(out.Values, value)
+	}
+	return out, nil
+}
+
+func valueToPb(v domain.Value) (*pb.Value, error) {
+	value, err := jsonValueToPb(v.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Value{
+		RecordId:        pb.UUIDToPb(v.RecordID),
+		PropertyId:      pb.UUIDToPb(v.PropertyID),
+		Type:            v.Type.Code(),
+		ReferenceTypeId: optionalUUIDToPb(v.RefTypeID),
+		Value:           value,
+		Sum:             v.Sum,
+		ChangeAt:        timeToPb(v.ChangeAt),
+	}, nil
+}
+
+// jsonValueToPb converts the value like the REST API encodes it to JSON, e.g. dates become strings of RFC 3339.
+func jsonValueToPb(v any) (*structpb.Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("value marshal error: %s", err)
+	}
+	var out structpb.Value
+	if err := protojson.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("value unmarshal error: %s", err)
+	}
+	return &out, nil
+}
+
+func stateTransitionsToPb(st domain.StateTransitions) *pb.StateTransitions {
+	return &pb.StateTransitions{
+		RecordId:    pb.UUIDToPb(st.RecordID),
+		PropertyId:  pb.UUIDToPb(st.PropertyID),
+		State:       st.State,
+		Transitions: st.Transitions,
+	}
+}
+
+func recordReferenceToPb(r domain.RecordReference) *pb.RecordReference {
+	return &pb.RecordReference{
+		RecordId:        pb.UUIDToPb(r.RecordID),
+		PropertyId:      pb.UUIDToPb(r.PropertyID),
+		ReferenceTypeId: optionalUUIDToPb(r.RefTypeID),
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/pb"
+	"datatom/pkg/log"
+	"fmt"
+	"net"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+type server struct {
+	srv  *grpc.Server
+	port uint
+}
+
+func (s *server) Serve() error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	if err != nil {
+		return err
+	}
+	return s.srv.Serve(lis)
+}
+
+type Config struct {
+	Logger       *zap.SugaredLogger
+	Port         uint
+	ErrorHandler func(error)
+
+	RefTypeManager  *api.RefTypeManager
+	RecordManager   *api.RecordManager
+	PropertyManager *api.PropertyManager
+	ValueManager    *api.ValueManager
+	TableManager    *api.TableManager
+	// AuthManager authenticates calls by the authorization and x-api-key metadata and authorizes them by methods
+	AuthManager *api.AuthManager
+}
+
+// NewServer serves the Datatom service of datatom.proto, its reflection is registered for tools like grpcurl.
+func NewServer(c Config) (domain.Server, error) {
+	svc, err := NewService(c)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := NewServerOptions(c)
+	if err != nil {
+		return nil, err
+	}
+	srv := grpc.NewServer(opts...)
+	pb.RegisterDatatomServer(srv, svc)
+	reflection.Register(srv)
+	return &server{srv: srv, port: c.Port}, nil
+}
+
+type service struct {
+	pb.UnimplementedDatatomServer
+	errorHandler func(error)
+
+	refTypeManager  *api.RefTypeManager
+	recordManager   *api.RecordManager
+	propertyManager *api.PropertyManager
+	valueManager    *api.ValueManager
+	tableManager    *api.TableManager
+}
+
+// NewServerOptions returns interceptors which authenticate and authorize calls of the service.
+func NewServerOptions(c Config) ([]grpc.ServerOption, error) {
+	eh, err := errorHandler(c)
+	if err != nil {
+		return nil, err
+	}
+	if c.AuthManager == nil {
+		return nil, fmt.Errorf("auth manager must be not nil")
+	}
+	if err := checkMethodPermissions(); err != nil {
+		return nil, err
+	}
+	a := authenticator{man: c.AuthManager, errorHandler: eh}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(a.unary),
+		grpc.ChainStreamInterceptor(a.stream),
+	}, nil
+}
+
+func errorHandler(c Config) (func(error), error) {
+	if c.ErrorHandler != nil {
+		return c.ErrorHandler, nil
+	}
+	l := c.Logger
+	if l == nil {
+		var err error
+		l, err = log.NewLogger()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return func(e error) {
+		l.Errorln(e.Error())
+	}, nil
+}
+
+// NewService implements the Datatom service on top of the managers the REST API uses.
+func NewService(c Config) (pb.DatatomServer, error) {
+	eh, err := errorHandler(c)
+	if err != nil {
+		return nil, err
+	}
+	if c.RefTypeManager == nil {
+		return nil, fmt.Errorf("reference type manager must be not nil")
+	}
+	if c.RecordManager == nil {
+		return nil, fmt.Errorf("record manager must be not nil")
+	}
+	if c.PropertyManager == nil {
+		return nil, fmt.Errorf("property manager must be not nil")
+	}
+	if c.ValueManager == nil {
+		return nil, fmt.Errorf("value manager must be not nil")
+	}
+	if c.TableManager == nil {
+		return nil, fmt.Errorf("table manager must be not nil")
+	}
+	return &service{
+		errorHandler:    eh,
+		refTypeManager:  c.RefTypeManager,
+		recordManager:   c.RecordManager,
+		propertyManager: c.PropertyManager,
+		valueManager:    c.ValueManager,
+		tableManager:    c.TableManager,
+	}, nil
+}
@@ -0,0 +1,491 @@
+package server
+
+import (
+	"context"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/internal/pb"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// listPageSize is the number of records ListRecords reads by one query while it streams them.
+const listPageSize = 1000
+
+func (s *service) AddRefType(ctx context.Context, in *pb.AddRefTypeRequest) (*pb.RefType, error) {
+	var ids idStrings
+	schema := handlers.AddRefTypeRequestSchema{
+		ID:          ids.get("id", in.GetId()),
+		Name:        in.GetName(),
+		Description: in.GetDescription(),
+	}
+	if ids.err != nil {
+		return nil, requestError(ids.err)
+	}
+	req, err := schema.AddRefTypeRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	id, err := s.refTypeManager.Add(ctx, req)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return s.GetRefType(ctx, pb.UUIDToPb(id))
+}
+
+func (s *service) UpdateRefType(ctx context.Context, in *pb.UpdateRefTypeRequest) (*pb.RefType, error) {
+	var ids idStrings
+	schema := handlers.UpdRefTypeRequestSchema{
+		ID:          ids.get("id", in.GetId()),
+		Name:        in.Name,
+		Description: in.Description,
+		ExpectedSum: in.GetExpectedSum(),
+	}
+	if ids.err != nil {
+		return nil, requestError(ids.err)
+	}
+	req, err := schema.UpdRefTypeRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	rt, err := s.refTypeManager.Update(ctx, req)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return refTypeToPb(*rt), nil
+}
+
+func (s *service) GetRefType(ctx context.Context, in *pb.UUID) (*pb.RefType, error) {
+	id, err := requiredID("id", in)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	rt, err := s.refTypeManager.Get(ctx, id)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return refTypeToPb(*rt), nil
+}
+
+func (s *service) AddProperty(ctx context.Context, in *pb.AddPropertyRequest) (*pb.Property, error) {
+	var ids idStrings
+	schema := handlers.AddPropertyRequestSchema{
+		ID:             ids.get("id", in.GetId()),
+		Name:           in.GetName(),
+		Description:    in.GetDescription(),
+		Types:          in.GetTypes(),
+		OwnerRefTypeID: ids.get("owner_reference_type_id", in.GetOwnerReferenceTypeId()),
+		Kind:           in.GetKind(),
+	}
+	for _, id := range in.GetReferenceTypeIds() {
+		schema.RefTypeIDs = append(schema.RefTypeIDs, ids.get("reference_type_ids", id))
+	}
+	if ids.err != nil {
+		return nil, requestError(ids.err)
+	}
+	if seq := in.GetSequence(); seq != nil {
+		schema.Sequence = &handlers.PropertySequenceSchema{
+			Prefix:      seq.GetPrefix(),
+			Template:    seq.GetTemplate(),
+			ResetPeriod: seq.GetResetPeriod(),
+		}
+	}
+	if sm := in.GetStateMachine(); sm != nil {
+		schema.StateMachine = &handlers.PropertyStateMachineSchema{
+			States:  sm.GetStates(),
+			Initial: sm.GetInitial(),
+		}
+		for _, t := range sm.GetTransitions() {
+			schema.StateMachine.Transitions = append(schema.StateMachine.Transitions, handlers.PropertyStateTransitionSchema{From: t.GetFrom(), To: t.GetTo()})
+		}
+	}
+	req, unknownTypes, err := schema.AddPropertyRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	if len(unknownTypes) > 0 {
+		return nil, requestError(domain.NewFieldError("types", fmt.Errorf("unknown types: %v", unknownTypes)))
+	}
+	if len(req.Types) == 0 {
+		return nil, requestError(domain.NewFieldError("types", fmt.Errorf("types %w", domain.ErrExpected)))
+	}
+	id, err := s.propertyManager.Add(ctx, req)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return s.GetProperty(ctx, pb.UUIDToPb(id))
+}
+
+func (s *service) UpdateProperty(ctx context.Context, in *pb.UpdatePropertyRequest) (*pb.Property, error) {
+	var ids idStrings
+	schema := handlers.UpdPropertyRequestSchema{
+		ID:          ids.get("id", in.GetId()),
+		Name:        in.Name,
+		Description: in.Description,
+		ExpectedSum: in.GetExpectedSum(),
+	}
+	if ids.err != nil {
+		return nil, requestError(ids.err)
+	}
+	req, err := schema.UpdPropertyRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	p, err := s.propertyManager.Update(ctx, req)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return propertyToPb(*p), nil
+}
+
+func (s *service) GetProperty(ctx context.Context, in *pb.UUID) (*pb.Property, error) {
+	id, err := requiredID("id", in)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	p, err := s.propertyManager.Get(ctx, id)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return propertyToPb(*p), nil
+}
+
+func (s *service) AddRecord(ctx context.Context, in *pb.AddRecordRequest) (*pb.Record, error) {
+	var ids idStrings
+	schema := handlers.AddRecordRequestSchema{
+		ID:              ids.get("id", in.GetId()),
+		ReferenceTypeID: ids.get("reference_type_id", in.GetReferenceTypeId()),
+		Name:            in.GetName(),
+		Description:     in.GetDescription(),
+		DeletionMark:    in.GetDeletionMark(),
+	}
+	if ids.err != nil {
+		return nil, requestError(ids.err)
+	}
+	req, err := schema.AddRecordRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	id, err := s.recordManager.Add(ctx, req)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	r, err := s.recordManager.Get(ctx, id)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return recordToPb(*r), nil
+}
+
+func (s *service) UpdateRecord(ctx context.Context, in *pb.UpdateRecordRequest) (*pb.Record, error) {
+	var ids idStrings
+	schema := handlers.UpdRecordRequestSchema{
+		ID:           ids.get("id", in.GetId()),
+		Name:         in.Name,
+		Description:  in.Description,
+		DeletionMark: in.DeletionMark,
+		ExpectedSum:  in.GetExpectedSum(),
+	}
+	if ids.err != nil {
+		return nil, requestError(ids.err)
+	}
+	req, err := schema.UpdRecordRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	r, err := s.recordManager.Update(ctx, req)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return recordToPb(*r), nil
+}
+
+// GetRecord returns the record with its values like GET of the REST API does.
+func (s *service) GetRecord(ctx context.Context, in *pb.UUID) (*pb.Record, error) {
+	id, err := requiredID("id", in)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	records, err := s.recordManager.GetMany(ctx, []uuid.UUID{id}, true)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	if len(records) == 0 {
+		return nil, s.managerError(domain.ErrRecordNotFound)
+	}
+	out, err := recordWithValuesToPb(records[0])
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return out, nil
+}
+
+func (s *service) GetReferencedBy(ctx context.Context, in *pb.ReferencedByRequest) (*pb.ReferencedByResponse, error) {
+	var ids idStrings
+	schema := handlers.ReferencedByRequestSchema{
+		ID:         ids.get("id", in.GetId()),
+		PropertyID: ids.get("property_id", in.GetPropertyId()),
+		RefTypeID:  ids.get("reference_type_id", in.GetReferenceTypeId()),
+		Offset:     strconv.FormatUint(uint64(in.GetOffset()), 10),
+	}
+	if in.GetLimit() != 0 {
+		schema.Limit = strconv.FormatUint(uint64(in.GetLimit()), 10)
+	}
+	if ids.err != nil {
+		return nil, requestError(ids.err)
+	}
+	req, err := schema.ReferencedByRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	refs, err := s.recordManager.ReferencedBy(ctx, req)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	out := &pb.ReferencedByResponse{
+		Limit:  uint32(req.Limit),
+		Offset: uint32(req.Offset),
+	}
+	for _, ref := range refs {
+		out.Items = append(out.Items, recordReferenceToPb(ref))
+	}
+	return out, nil
+}
+
+func (s *service) SetValue(ctx context.Context, in *pb.SetValueRequest) (*pb.Value, error) {
+	var ids idStrings
+	schema := handlers.SetValueRequestSchema{
+		RecordID:    ids.get("record_id", in.GetRecordId()),
+		PropertyID:  ids.get("property_id", in.GetPropertyId()),
+		Type:        in.GetType(),
+		RefTypeID:   ids.get("reference_type_id", in.GetReferenceTypeId()),
+		ExpectedSum: in.GetExpectedSum(),
+	}
+	if ids.err != nil {
+		return nil, requestError(ids.err)
+	}
+	if in.GetValue() != nil {
+		schema.Value = in.GetValue().AsInterface()
+	}
+	req, err := schema.SetValueRequest()
+	if err != nil {
+		return nil, requestError(err)
+	}
+	v, err := s.valueManager.Set(ctx, req)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	out, err := valueToPb(*v)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return out, nil
+}
+
+func (s *service) GetValue(ctx context.Context, in *pb.GetValueRequest) (*pb.Value, error) {
+	req, err := getValueRequest(in)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	v, err := s.valueManager.Get(ctx, req)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	out, err := valueToPb(*v)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return out, nil
+}
+
+func (s *service) GetStateTransitions(ctx context.Context, in *pb.GetValueRequest) (*pb.StateTransitions, error) {
+	req, err := getValueRequest(in)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	st, err := s.valueManager.Transitions(ctx, req)
+	if err != nil {
+		return nil, s.managerError(err)
+	}
+	return stateTransitionsToPb(*st), nil
+}
+
+// ListRecords reads records by pages, so a stream of a large reference type does not hold all of them at once.
+func (s *service) ListRecords(in *pb.ListRecordsRequest, stream pb.Datatom_ListRecordsServer) error {
+	req := domain.ListRecordsRequest{
+		Filter: domain.RecordFilter{DeletionMark: in.DeletionMark, Name: in.GetName()},
+		Limit:  listPageSize,
+	}
+	if in.GetReferenceTypeId() != nil {
+		id, err := requiredID("reference_type_id", in.GetReferenceTypeId())
+		if err != nil {
+			return requestError(err)
+		}
+		req.RefTypeID = id
+	}
+	ctx := stream.Context()
+	for {
+		records, err := s.recordManager.List(ctx, req)
+		if err != nil {
+			return s.managerError(err)
+		}
+		if err := s.sendRecords(ctx, records, in.GetWithValues(), stream.Send); err != nil {
+			return err
+		}
+		if len(records) < listPageSize {
+			return nil
+		}
+		req.Offset += listPageSize
+	}
+}
+
+func (s *service) GetRecords(in *pb.GetRecordsRequest, stream pb.Datatom_GetRecordsServer) error {
+	recordIDs := make([]uuid.UUID, 0, len(in.GetIds()))
+	for _, pbID := range in.GetIds() {
+		id, err := requiredID("ids", pbID)
+		if err != nil {
+			return requestError(err)
+		}
+		recordIDs = append(recordIDs, id)
+	}
+	records, err := s.recordManager.GetMany(stream.Context(), recordIDs, in.GetWithValues())
+	if err != nil {
+		return s.managerError(err)
+	}
+	for _, r := range records {
+		out, err := recordWithValuesToPb(r)
+		if err != nil {
+			return s.managerError(err)
+		}
+		if err := stream.Send(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendRecords sends listed records and reads their values by one query if they are requested.
+func (s *service) sendRecords(ctx context.Context, records []domain.Record, withValues bool, send func(*pb.Record) error) error {
+	if !withValues {
+		for _, r := range records {
+			if err := send(recordToPb(r)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	recordIDs := make([]uuid.UUID, 0, len(records))
+	for _, r := range records {
+		recordIDs = append(recordIDs, r.ID)
+	}
+	withValuesByID := make(map[uuid.UUID]domain.RecordWithValues, len(records))
+	fetched, err := s.recordManager.GetMany(ctx, recordIDs, true)
+	if err != nil {
+		return s.managerError(err)
+	}
+	for _, r := range fetched {
+		withValuesByID[r.ID] = r
+	}
+	// Records are sent in the order of the list, records deleted since they were listed are skipped
+	for _, r := range records {
+		rwv, ok := withValuesByID[r.ID]
+		if !ok {
+			continue
+		}
+		out, err := recordWithValuesToPb(rwv)
+		if err != nil {
+			return s.managerError(err)
+		}
+		if err := send(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) ExportTable(in *pb.ExportTableRequest, stream pb.Datatom_ExportTableServer) error {
+	var ids idStrings
+	schema := handlers.TableRequestSchema{
+		RefTypeID: ids.get("reference_type_id", in.GetReferenceTypeId()),
+		Name:      in.GetName(),
+	}
+	if ids.err != nil {
+		return requestError(ids.err)
+	}
+	if in.DeletionMark != nil {
+		schema.DeletionMark = strconv.FormatBool(in.GetDeletionMark())
+	}
+	req, err := schema.TableRequest()
+	if err != nil {
+		return requestError(err)
+	}
+	w := &tableWriter{stream: stream}
+	if err := s.tableManager.Export(stream.Context(), req, w); err != nil {
+		if w.sendErr != nil {
+			return w.sendErr
+		}
+		return s.managerError(err)
+	}
+	return nil
+}
+
+// tableWriter sends the table by chunks, errors of the stream are kept
+// to return them as they are rather than as errors of the export.
+type tableWriter struct {
+	stream  pb.Datatom_ExportTableServer
+	sendErr error
+}
+
+func (w *tableWriter) WriteHeader(properties []domain.Property) error {
+	columns := &pb.TableColumns{}
+	for _, p := range properties {
+		columns.Properties = append(columns.Properties, propertyToPb(p))
+	}
+	return w.send(&pb.TableChunk{Chunk: &pb.TableChunk_Columns{Columns: columns}})
+}
+
+func (w *tableWriter) WriteRow(row domain.TableRow) error {
+	out := recordToPb(row.Record)
+	for _, v := range row.Values {
+		value, err := valueToPb(v.Value)
+		if err != nil {
+			return err
+		}
+		value.RefName = v.RefName
+		out.Values = append(out.Values, value)
+	}
+	return w.send(&pb.TableChunk{Chunk: &pb.TableChunk_Row{Row: out}})
+}
+
+func (w *tableWriter) send(chunk *pb.TableChunk) error {
+	if err := w.stream.Send(chunk); err != nil {
+		w.sendErr = err
+		return err
+	}
+	return nil
+}
+
+func getValueRequest(in *pb.GetValueRequest) (domain.GetValueRequest, error) {
+	var ids idStrings
+	schema := handlers.GetValueRequestSchema{
+		RecordID:   ids.get("record_id", in.GetRecordId()),
+		PropertyID: ids.get("property_id", in.GetPropertyId()),
+	}
+	if ids.err != nil {
+		return domain.GetValueRequest{}, ids.err
+	}
+	return schema.GetValueRequest()
+}
+
+func requiredID(field string, id *pb.UUID) (uuid.UUID, error) {
+	if id == nil {
+		return uuid.Nil, domain.NewFieldError(field, fmt.Errorf("%s %w", field, domain.ErrExpected))
+	}
+	out, err := pb.UUIDFromPb(id)
+	if err != nil {
+		return uuid.Nil, domain.NewFieldError(field, fmt.Errorf("parse %s error: %s", field, err))
+	}
+	return out, nil
+}
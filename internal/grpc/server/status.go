@@ -0,0 +1,71 @@
+package server
+
+import (
+	"datatom/internal/domain"
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	errorDomain = "datatom"
+
+	errorCodeBadRequest   = "bad_request"
+	errorCodeInvalidField = "invalid_field"
+	errorCodeInternal     = "internal_server_error"
+)
+
+// requestError is the error of the request which is rejected before managers are called.
+func requestError(err error) error {
+	return newStatusError(codes.InvalidArgument, err, errorCodeBadRequest)
+}
+
+// managerError converts the error of a manager to the status of the REST status the same error gets,
+// errors which are not domain ones are internal and their messages are not exposed.
+func (s *service) managerError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return newStatusError(codes.NotFound, err, "")
+	case errors.Is(err, domain.ErrAlreadyExists):
+		return newStatusError(codes.AlreadyExists, err, "")
+	case errors.Is(err, domain.ErrForbidden):
+		return newStatusError(codes.PermissionDenied, err, "")
+	case errors.Is(err, domain.ErrSumMismatch), errors.Is(err, domain.ErrStateTransitionNotAllowedPG):
+		return newStatusError(codes.FailedPrecondition, err, "")
+	case domain.ErrorCode(err) != "" || domain.ErrorField(err) != "":
+		return newStatusError(codes.InvalidArgument, err, "")
+	}
+	s.errorHandler(err)
+	return newStatusError(codes.Internal, errors.New("internal server error"), errorCodeInternal)
+}
+
+// newStatusError attaches the stable code of the error, its field and details
+// by the ErrorInfo, the fallback code is used for errors without a domain code.
+func newStatusError(c codes.Code, err error, fallback string) error {
+	reason := domain.ErrorCode(err)
+	field := domain.ErrorField(err)
+	if reason == "" {
+		reason = fallback
+		if field != "" {
+			reason = errorCodeInvalidField
+		}
+	}
+	info := &errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   errorDomain,
+		Metadata: make(map[string]string),
+	}
+	for k, v := range domain.ErrorDetails(err) {
+		info.Metadata[k] = v
+	}
+	if field != "" {
+		info.Metadata["field"] = field
+	}
+	st, detailsErr := status.New(c, err.Error()).WithDetails(info)
+	if detailsErr != nil {
+		return status.Error(c, err.Error())
+	}
+	return st.Err()
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+func GetRecordACL(ctx context.Context, man *api.RecordManager, id string) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	rid, err := uuid.Parse(id)
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, domain.NewFieldError("id", fmt.Errorf("parse record id error: %s", err))
+	}
+	record, err := man.Get(ctx, rid)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	b, err := json.Marshal(RecordACLToResponseSchema(*record))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	out.Sum = record.Sum
+	return out, nil
+}
+
+func SetRecordACL(ctx context.Context, man *api.RecordManager, req SetRecordACLRequestSchema) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	rid, err := uuid.Parse(req.ID)
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, domain.NewFieldError("id", fmt.Errorf("parse record id error: %s", err))
+	}
+	acl, err := req.RecordACL()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	record, err := man.SetACL(ctx, rid, acl)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		} else if errors.Is(err, domain.ErrForbidden) {
+			out.Status = http.StatusForbidden
+		}
+		return out, err
+	}
+	b, err := json.Marshal(RecordACLToResponseSchema(*record))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	out.Sum = record.Sum
+	return out, nil
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"datatom/internal/domain"
+	"fmt"
+)
+
+type ACLEntrySchema struct {
+	GranteeType string `json:"grantee_type"`
+	Grantee     string `json:"grantee"`
+	Access      string `json:"access"`
+}
+
+type SetRecordACLRequestSchema struct {
+	ID  string           `json:"-"`
+	ACL []ACLEntrySchema `json:"acl"`
+}
+
+// RecordACL returns an error if any entry is not valid or grants the access to the same grantee twice.
+func (s SetRecordACLRequestSchema) RecordACL() (domain.ACL, error) {
+	out := make(domain.ACL, 0, len(s.ACL))
+	seen := make(map[ACLEntrySchema]bool, len(s.ACL))
+	for i, e := range s.ACL {
+		field := fmt.Sprintf("acl[%d]", i)
+		granteeType, err := domain.GranteeTypeFromCode(e.GranteeType)
+		if err != nil {
+			return nil, domain.NewFieldError(field+".grantee_type", err)
+		}
+		if e.Grantee == "" {
+			return nil, domain.NewFieldError(field+".grantee", fmt.Errorf("grantee %w", domain.ErrExpected))
+		}
+		access, err := domain.AccessFromCode(e.Access)
+		if err != nil {
+			return nil, domain.NewFieldError(field+".access", err)
+		}
+		key := ACLEntrySchema{GranteeType: e.GranteeType, Grantee: e.Grantee}
+		if seen[key] {
+			return nil, domain.NewFieldError(field, fmt.Errorf("%s %s is duplicated", e.GranteeType, e.Grantee))
+		}
+		seen[key] = true
+		out = append(out, domain.ACLEntry{GranteeType: granteeType, Grantee: e.Grantee, Access: access})
+	}
+	return out, nil
+}
+
+type RecordACLResponseSchema struct {
+	RecordID string           `json:"record_id"`
+	ACL      []ACLEntrySchema `json:"acl"`
+}
+
+func RecordACLToResponseSchema(r domain.Record) RecordACLResponseSchema {
+	out := RecordACLResponseSchema{
+		RecordID: r.ID.String(),
+		ACL:      make([]ACLEntrySchema, 0, len(r.ACL)),
+	}
+	for _, e := range r.ACL {
+		out.ACL = append(out.ACL, ACLEntrySchema{
+			GranteeType: e.GranteeType.Code(),
+			Grantee:     e.Grantee,
+			Access:      e.Access.Code(),
+		})
+	}
+	return out
+}
@@ -5,11 +5,15 @@ import (
 	"datatom/internal/api"
 	"datatom/internal/domain"
 	"datatom/pkg/log"
+	pkgrmq "datatom/pkg/message_broker/rmq"
+	"datatom/pkg/tracing"
 	"encoding/json"
 	"errors"
 	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
 	rmq "github.com/wagslane/go-rabbitmq"
 	"go.uber.org/zap"
+	"net/http"
 	"time"
 )
 
@@ -19,6 +23,9 @@ type ConsumeHandlerConfig struct {
 	ValueManager    *api.ValueManager
 	PropertyManager *api.PropertyManager
 	RecordManager   *api.RecordManager
+	// AuthManager authenticates messages by their Authorization and X-API-Key headers,
+	// messages are not checked if it is nil or authentication is disabled
+	AuthManager *api.AuthManager
 }
 
 func NewConsumeHandler(c ConsumeHandlerConfig) rmq.Handler {
@@ -29,19 +36,35 @@ func NewConsumeHandler(c ConsumeHandlerConfig) rmq.Handler {
 		if c.Logger == nil {
 			c.Logger = log.GlobalLogger()
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+		// The span continues the trace of the publisher by headers of the delivery
+		ctx, span := pkgrmq.StartConsumeSpan(d)
+		ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 		defer cancel()
 		var err error
 		var isInnerError bool
-		switch d.Type {
-		case domain.DeliveryTypeValue:
-			isInnerError, err = processMessageWithValue(ctx, c.ValueManager, d.Body)
-		case domain.DeliveryTypeProperty:
-			isInnerError, err = processMessageWithProperty(ctx, c.PropertyManager, d.Body)
-		case domain.DeliveryTypeRecord:
-			isInnerError, err = processMessageWithRecord(ctx, c.RecordManager, d.Body)
-		default:
-			err = fmt.Errorf("unexpected delivery type %s", d.Type)
+		var p *domain.Principal
+		if c.AuthManager != nil && c.AuthManager.Enabled() {
+			var res Result
+			res, p, err = Authenticate(ctx, c.AuthManager, AuthRequestSchema{
+				Authorization: headerString(d.Headers, "Authorization"),
+				APIKey:        headerString(d.Headers, "X-API-Key"),
+			})
+			isInnerError = res.Status == http.StatusInternalServerError
+			// ACLs of records are checked by managers against the principal of the context
+			ctx = api.ContextWithPrincipal(ctx, p)
+		}
+		if err == nil {
+			auth := messageAuth{man: c.AuthManager, principal: p}
+			switch d.Type {
+			case domain.DeliveryTypeValue:
+				isInnerError, err = processMessageWithValue(ctx, c.ValueManager, auth, d.Body)
+			case domain.DeliveryTypeProperty:
+				isInnerError, err = processMessageWithProperty(ctx, c.PropertyManager, auth, d.Body)
+			case domain.DeliveryTypeRecord:
+				isInnerError, err = processMessageWithRecord(ctx, c.RecordManager, auth, d.Body)
+			default:
+				err = fmt.Errorf("unexpected delivery type %s", d.Type)
+			}
 		}
 		if err != nil {
 			action = rmq.NackDiscard
@@ -52,11 +75,35 @@ func NewConsumeHandler(c ConsumeHandlerConfig) rmq.Handler {
 				c.Logger.Infof(template, d.MessageId, err)
 			}
 		}
+		tracing.End(span, err)
 		return action
 	}
 }
 
-func processMessageWithValue(ctx context.Context, man *api.ValueManager, message []byte) (bool, error) {
+func headerString(headers amqp.Table, name string) string {
+	v, _ := headers[name].(string)
+	return v
+}
+
+// messageAuth checks permissions of the principal of the message, nothing is checked without the principal.
+type messageAuth struct {
+	man       *api.AuthManager
+	principal *domain.Principal
+}
+
+// authorize returns true if the permission is not checked cause of an inner error.
+func (a messageAuth) authorize(ctx context.Context, scope func() (domain.Permission, error)) (bool, error) {
+	if a.principal == nil {
+		return false, nil
+	}
+	perm, err := scope()
+	if err != nil {
+		return true, err
+	}
+	return false, a.man.Authorize(ctx, a.principal, perm)
+}
+
+func processMessageWithValue(ctx context.Context, man *api.ValueManager, auth messageAuth, message []byte) (bool, error) {
 	var schema SetValueRequestSchema
 	if err := json.Unmarshal(message, &schema); err != nil {
 		return false, err
@@ -65,13 +112,18 @@ func processMessageWithValue(ctx context.Context, man *api.ValueManager, message
 	if err != nil {
 		return false, err
 	}
+	if isInnerError, err := auth.authorize(ctx, func() (domain.Permission, error) {
+		return auth.man.RecordPermission(ctx, domain.OperationValues, domain.AccessWrite, req.RecordID)
+	}); err != nil {
+		return isInnerError, err
+	}
 	if _, err := man.Set(ctx, req); err != nil {
-		return !errors.Is(err, domain.ErrNotFound), err
+		return !errors.Is(err, domain.ErrNotFound) && !errors.Is(err, domain.ErrStateTransitionNotAllowedPG) && !errors.Is(err, domain.ErrSumMismatch) && !errors.Is(err, domain.ErrForbidden), err
 	}
 	return false, nil
 }
 
-func processMessageWithProperty(ctx context.Context, man *api.PropertyManager, message []byte) (bool, error) {
+func processMessageWithProperty(ctx context.Context, man *api.PropertyManager, auth messageAuth, message []byte) (bool, error) {
 	var schema UpdPropertyRequestSchema
 	if err := json.Unmarshal(message, &schema); err != nil {
 		return false, err
@@ -80,13 +132,18 @@ func processMessageWithProperty(ctx context.Context, man *api.PropertyManager, m
 	if err != nil {
 		return false, err
 	}
+	if isInnerError, err := auth.authorize(ctx, func() (domain.Permission, error) {
+		return auth.man.PropertyPermission(ctx, domain.OperationProperties, domain.AccessWrite, req.ID)
+	}); err != nil {
+		return isInnerError, err
+	}
 	if _, err := man.Update(ctx, req); err != nil {
-		return !errors.Is(err, domain.ErrNotFound), err
+		return !errors.Is(err, domain.ErrNotFound) && !errors.Is(err, domain.ErrSumMismatch), err
 	}
 	return false, nil
 }
 
-func processMessageWithRecord(ctx context.Context, man *api.RecordManager, message []byte) (bool, error) {
+func processMessageWithRecord(ctx context.Context, man *api.RecordManager, auth messageAuth, message []byte) (bool, error) {
 	var schema UpdRecordRequestSchema
 	if err := json.Unmarshal(message, &schema); err != nil {
 		return false, err
@@ -95,8 +152,13 @@ func processMessageWithRecord(ctx context.Context, man *api.RecordManager, messa
 	if err != nil {
 		return false, err
 	}
+	if isInnerError, err := auth.authorize(ctx, func() (domain.Permission, error) {
+		return auth.man.RecordPermission(ctx, domain.OperationRecords, domain.AccessWrite, req.ID)
+	}); err != nil {
+		return isInnerError, err
+	}
 	if _, err := man.Update(ctx, req); err != nil {
-		return !errors.Is(err, domain.ErrNotFound), err
+		return !errors.Is(err, domain.ErrNotFound) && !errors.Is(err, domain.ErrSumMismatch) && !errors.Is(err, domain.ErrForbidden), err
 	}
 	return false, nil
 }
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"errors"
+	"net/http"
+)
+
+// Authenticate returns the principal of the request, the status is 401 if credentials are missing or not accepted.
+func Authenticate(ctx context.Context, man *api.AuthManager, req AuthRequestSchema) (Result, *domain.Principal, error) {
+	out := Result{Status: http.StatusOK}
+	c, err := req.Credentials()
+	if err != nil {
+		out.Status = http.StatusUnauthorized
+		return out, nil, err
+	}
+	p, err := man.Authenticate(ctx, c)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrUnauthenticated) {
+			out.Status = http.StatusUnauthorized
+		}
+		return out, nil, err
+	}
+	return out, p, nil
+}
+
+// Authorize returns the status 403 if the permission is not granted to the principal.
+func Authorize(ctx context.Context, man *api.AuthManager, p *domain.Principal, perm domain.Permission) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	if err := man.Authorize(ctx, p, perm); err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrForbidden) {
+			out.Status = http.StatusForbidden
+		}
+		return out, err
+	}
+	return out, nil
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"datatom/internal/domain"
+	"fmt"
+	"strings"
+)
+
+// AuthRequestSchema holds credentials of the request: the bearer token of the Authorization header
+// and the static key of the X-API-Key header. Messages of the broker carry them in headers of the same names.
+type AuthRequestSchema struct {
+	Authorization string
+	APIKey        string
+}
+
+func (s AuthRequestSchema) Credentials() (domain.Credentials, error) {
+	out := domain.Credentials{APIKey: s.APIKey}
+	if s.Authorization == "" {
+		return out, nil
+	}
+	scheme, token, ok := strings.Cut(s.Authorization, " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return out, fmt.Errorf("%w: bearer token expected", domain.ErrUnauthenticated)
+	}
+	out.Token = token
+	return out, nil
+}
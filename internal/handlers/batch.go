@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/pkg/db"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func Batch(ctx context.Context, man *api.BatchManager, req BatchRequestSchema) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	if len(req.Operations) == 0 {
+		out.Status = http.StatusBadRequest
+		return out, domain.NewFieldError("operations", fmt.Errorf("operations %w", domain.ErrExpected))
+	}
+	if len(req.Operations) > maxBatchOperations {
+		out.Status = http.StatusBadRequest
+		return out, fmt.Errorf("too many operations %d, limit is %d", len(req.Operations), maxBatchOperations)
+	}
+	declared := make(map[string]struct{})
+	for i, op := range req.Operations {
+		if op.TempID == "" {
+			continue
+		}
+		if _, ok := declared[op.TempID]; ok {
+			return batchOperationError(out, i, op, http.StatusBadRequest, fmt.Errorf("temp ID %s duplicated", op.TempID))
+		}
+		declared[op.TempID] = struct{}{}
+	}
+
+	tx, err := man.BeginTransaction(ctx)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, fmt.Errorf("begin transaction error: %w", err)
+	}
+	ids := make(map[string]string, len(declared))
+	results := make([]BatchOperationResultSchema, 0, len(req.Operations))
+	for i, op := range req.Operations {
+		id, status, err := applyBatchOperation(ctx, man, op, ids, declared, tx)
+		if err != nil {
+			if errRollback := tx.Rollback(ctx); errRollback != nil {
+				err = fmt.Errorf("%w, rollback error: %s", err, errRollback)
+			}
+			return batchOperationError(out, i, op, status, err)
+		}
+		if op.TempID != "" {
+			ids[op.TempID] = id
+		}
+		results = append(results, BatchOperationResultSchema{
+			Index:  i,
+			Op:     op.Op,
+			TempID: op.TempID,
+			ID:     id,
+		})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, fmt.Errorf("commit transaction error: %w", err)
+	}
+	b, err := json.Marshal(BatchResponseSchema{Results: results})
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
+
+// applyBatchOperation returns ID of the added or updated object and status of the failure.
+func applyBatchOperation(ctx context.Context, man *api.BatchManager, op BatchOperationSchema, ids map[string]string, declared map[string]struct{}, tx db.Transaction) (string, int, error) {
+	data, err := op.resolvedData(ids, declared)
+	if err != nil {
+		return "", http.StatusBadRequest, err
+	}
+	switch op.Op {
+	case BatchOpAddRecord:
+		var schema AddRecordRequestSchema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return "", http.StatusBadRequest, fmt.Errorf("data unmarshal error: %s", err)
+		}
+		r, err := schema.AddRecordRequest()
+		if err != nil {
+			return "", http.StatusBadRequest, err
+		}
+		id, err := man.AddRecord(ctx, r, tx)
+		if err != nil {
+			return "", batchErrorStatus(err), err
+		}
+		return id.String(), 0, nil
+	case BatchOpUpdateRecord:
+		var schema UpdRecordRequestSchema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return "", http.StatusBadRequest, fmt.Errorf("data unmarshal error: %s", err)
+		}
+		r, err := schema.UpdRecordRequest()
+		if err != nil {
+			return "", http.StatusBadRequest, err
+		}
+		record, err := man.UpdateRecord(ctx, r, tx)
+		if err != nil {
+			return "", batchErrorStatus(err), err
+		}
+		return record.ID.String(), 0, nil
+	case BatchOpAddProperty:
+		var schema AddPropertyRequestSchema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return "", http.StatusBadRequest, fmt.Errorf("data unmarshal error: %s", err)
+		}
+		r, unknownTypes, err := schema.AddPropertyRequest()
+		if err != nil {
+			return "", http.StatusBadRequest, err
+		}
+		if len(unknownTypes) > 0 {
+			return "", http.StatusBadRequest, fmt.Errorf("unknown types: %v", unknownTypes)
+		}
+		if len(r.Types) == 0 {
+			return "", http.StatusBadRequest, fmt.Errorf("types %w", domain.ErrExpected)
+		}
+		id, err := man.AddProperty(ctx, r, tx)
+		if err != nil {
+			return "", batchErrorStatus(err), err
+		}
+		return id.String(), 0, nil
+	case BatchOpUpdateProperty:
+		var schema UpdPropertyRequestSchema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return "", http.StatusBadRequest, fmt.Errorf("data unmarshal error: %s", err)
+		}
+		r, err := schema.UpdPropertyRequest()
+		if err != nil {
+			return "", http.StatusBadRequest, err
+		}
+		property, err := man.UpdateProperty(ctx, r, tx)
+		if err != nil {
+			return "", batchErrorStatus(err), err
+		}
+		return property.ID.String(), 0, nil
+	case BatchOpSetValue:
+		var schema SetValueRequestSchema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return "", http.StatusBadRequest, fmt.Errorf("data unmarshal error: %s", err)
+		}
+		r, err := schema.SetValueRequest()
+		if err != nil {
+			return "", http.StatusBadRequest, err
+		}
+		if _, err := man.SetValue(ctx, r, tx); err != nil {
+			return "", batchErrorStatus(err), err
+		}
+		return "", 0, nil
+	default:
+		return "", http.StatusBadRequest, fmt.Errorf(`%w "%s" of batch operation`, domain.ErrUnknownType, op.Op)
+	}
+}
+
+func batchErrorStatus(err error) int {
+	switch {
+	case isBadRequestError(err):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrStateTransitionNotAllowedPG), errors.Is(err, domain.ErrAlreadyExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// batchOperationError fills the result by the failed operation. Details of internal
+// errors are not exposed.
+func batchOperationError(out Result, index int, op BatchOperationSchema, status int, err error) (Result, error) {
+	out.Status = status
+	schema := BatchErrorResponseSchema{
+		Index: index,
+		Op:    op.Op,
+		Error: err.Error(),
+	}
+	if status == http.StatusInternalServerError {
+		schema.Error = http.StatusText(status)
+	}
+	b, errMarshal := json.Marshal(schema)
+	if errMarshal != nil {
+		return out, fmt.Errorf("%w, marshal error: %s", err, errMarshal)
+	}
+	out.Payload = b
+	return out, fmt.Errorf("batch operation %d error: %w", index, err)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"datatom/internal/domain"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	BatchOpAddRecord      = "add_record"
+	BatchOpUpdateRecord   = "update_record"
+	BatchOpAddProperty    = "add_property"
+	BatchOpUpdateProperty = "update_property"
+	BatchOpSetValue       = "set_value"
+
+	maxBatchOperations = 10000
+
+	// batchTempIDPrefix marks a string of operation data as a reference to temp_id of a former operation
+	batchTempIDPrefix = "$"
+)
+
+type BatchRequestSchema struct {
+	Operations []BatchOperationSchema `json:"operations"`
+}
+
+type BatchOperationSchema struct {
+	Op     string          `json:"op"`
+	TempID string          `json:"temp_id,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type BatchOperationResultSchema struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	TempID string `json:"temp_id,omitempty"`
+	ID     string `json:"id,omitempty"`
+}
+
+type BatchResponseSchema struct {
+	Results []BatchOperationResultSchema `json:"results"`
+}
+
+type BatchErrorResponseSchema struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	Error string `json:"error"`
+}
+
+// batchIDFields are fields of the operation data which hold IDs, so only they may refer to temp IDs.
+// Value of the set_value operation of ref type is an ID as well.
+var batchIDFields = []string{
+	"id",
+	"record_id",
+	"property_id",
+	"reference_type_id",
+	"owner_reference_type_id",
+	"reference_type_ids",
+}
+
+// resolvedData replaces strings like "$<temp_id>" of ID fields of the operation data by IDs
+// which were given to former operations of the batch. Strings which do not name any temp_id
+// of the batch and strings of other fields, e.g. names or text values, are kept as is.
+func (s BatchOperationSchema) resolvedData(ids map[string]string, declared map[string]struct{}) ([]byte, error) {
+	if len(s.Data) == 0 {
+		return nil, fmt.Errorf("operation data expected")
+	}
+	var data map[string]any
+	if err := json.Unmarshal(s.Data, &data); err != nil {
+		return nil, fmt.Errorf("operation data unmarshal error: %s", err)
+	}
+	fields := batchIDFields
+	if tp, _ := data["type"].(string); s.Op == BatchOpSetValue && domain.TypeFromCode(tp) == domain.TypeReference {
+		fields = append(fields[:len(fields):len(fields)], "value")
+	}
+	for _, k := range fields {
+		v, ok := data[k]
+		if !ok {
+			continue
+		}
+		x, err := resolveTempIDs(v, ids, declared)
+		if err != nil {
+			return nil, err
+		}
+		data[k] = x
+	}
+	return json.Marshal(data)
+}
+
+// resolveTempIDs replaces the string or strings of the array.
+func resolveTempIDs(data any, ids map[string]string, declared map[string]struct{}) (any, error) {
+	switch v := data.(type) {
+	case string:
+		if !strings.HasPrefix(v, batchTempIDPrefix) {
+			return v, nil
+		}
+		tempID := strings.TrimPrefix(v, batchTempIDPrefix)
+		if _, ok := declared[tempID]; !ok {
+			return v, nil
+		}
+		id, ok := ids[tempID]
+		if !ok {
+			return nil, fmt.Errorf("temp ID %s is used before its operation", tempID)
+		}
+		return id, nil
+	case []any:
+		for i := range v {
+			x, err := resolveTempIDs(v[i], ids, declared)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = x
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
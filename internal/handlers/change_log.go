@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ChangeWriter sends changes of the stream, Open is called once the request is accepted.
+type ChangeWriter interface {
+	Open() error
+	WriteChange(ChangeResponseSchema) error
+}
+
+// StreamChanges writes changes to w until the context is done or writing fails.
+// Status of the result makes sense only if w is not opened yet.
+func StreamChanges(ctx context.Context, man *api.ChangeLogManager, req ChangeStreamRequestSchema, w ChangeWriter) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	r, err := req.ChangeLogRequest()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	if !req.IsResumed() {
+		if r.AfterID, err = man.LastID(ctx); err != nil {
+			out.Status = http.StatusInternalServerError
+			return out, err
+		}
+	}
+	if err := w.Open(); err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	err = man.Stream(ctx, r, func(c domain.Change) error {
+		schema, err := ChangeToResponseSchema(c)
+		if err != nil {
+			return err
+		}
+		return w.WriteChange(schema)
+	})
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	return out, nil
+}
+
+// GetChangeFeed returns the page of changes after the requested ID or the cursor of the consumer.
+func GetChangeFeed(ctx context.Context, man *api.ChangeLogManager, req ChangeFeedRequestSchema) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	r, err := req.ChangeLogRequest()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	if req.After == "" && req.Consumer != "" {
+		consumer, err := man.GetConsumer(ctx, req.Consumer)
+		if err != nil {
+			out.Status = http.StatusInternalServerError
+			if errors.Is(err, domain.ErrNotFound) {
+				out.Status = http.StatusNotFound
+			}
+			return out, err
+		}
+		r.AfterID = consumer.LastChangeID
+	}
+	changes, err := man.Get(ctx, r)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	resp := ChangeFeedResponseSchema{
+		Changes: make([]ChangeResponseSchema, 0, len(changes)),
+		Next:    r.AfterID,
+	}
+	for _, c := range changes {
+		schema, err := ChangeToResponseSchema(c)
+		if err != nil {
+			out.Status = http.StatusInternalServerError
+			return out, err
+		}
+		resp.Changes = append(resp.Changes, schema)
+		resp.Next = c.ID
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
+
+func GetChangeConsumers(ctx context.Context, man *api.ChangeLogManager) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	consumers, err := man.GetConsumers(ctx)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	resp := make([]ChangeConsumerResponseSchema, 0, len(consumers))
+	for _, c := range consumers {
+		resp = append(resp, ChangeConsumerToResponseSchema(c))
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
+
+func GetChangeConsumer(ctx context.Context, man *api.ChangeLogManager, name string) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	if err := validateChangeConsumerName(name); err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	consumer, err := man.GetConsumer(ctx, name)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	b, err := json.Marshal(ChangeConsumerToResponseSchema(*consumer))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
+
+// SetChangeConsumer creates the consumer or moves its cursor.
+func SetChangeConsumer(ctx context.Context, man *api.ChangeLogManager, req SetChangeConsumerRequestSchema) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	if err := req.Validate(); err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	consumer, err := man.SetConsumer(ctx, req.Name, *req.LastChangeID)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	b, err := json.Marshal(ChangeConsumerToResponseSchema(*consumer))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
+
+func DeleteChangeConsumer(ctx context.Context, man *api.ChangeLogManager, name string) (Result, error) {
+	out := Result{Status: http.StatusNoContent}
+	if err := validateChangeConsumerName(name); err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	if err := man.DeleteConsumer(ctx, name); err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	return out, nil
+}
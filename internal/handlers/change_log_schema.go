@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"datatom/internal/domain"
+	"datatom/pkg/helper"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var changeConsumerNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,128}$`)
+
+type ChangeStreamRequestSchema struct {
+	// After is the ID of the last seen change, the Last-Event-ID of a reconnected
+	// event source takes precedence over it. New changes are streamed if both are empty.
+	After       string
+	LastEventID string
+	RefTypeIDs  []string
+	PropertyIDs []string
+}
+
+// IsResumed reports whether the stream continues from the last seen change.
+func (s ChangeStreamRequestSchema) IsResumed() bool {
+	return s.After != "" || s.LastEventID != ""
+}
+
+func (s ChangeStreamRequestSchema) ChangeLogRequest() (domain.ChangeLogRequest, error) {
+	var out domain.ChangeLogRequest
+	field, after := "after", s.After
+	if s.LastEventID != "" {
+		field, after = "last_event_id", s.LastEventID
+	}
+	if after != "" {
+		id, err := parseChangeID(field, after)
+		if err != nil {
+			return out, err
+		}
+		out.AfterID = id
+	}
+	filter, err := parseChangeFilter(s.RefTypeIDs, s.PropertyIDs)
+	if err != nil {
+		return out, err
+	}
+	out.Filter = filter
+	return out, nil
+}
+
+func parseChangeID(field, v string) (int64, error) {
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id < 0 {
+		return 0, domain.NewFieldError(field, fmt.Errorf("parse %s error: %s is not a change ID", field, v))
+	}
+	return id, nil
+}
+
+func parseChangeFilter(refTypeIDs, propertyIDs []string) (domain.ChangeFilter, error) {
+	var out domain.ChangeFilter
+	for _, v := range refTypeIDs {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return out, domain.NewFieldError("reference_type_id", fmt.Errorf("parse reference type id error: %s", err))
+		}
+		out.RefTypeIDs = append(out.RefTypeIDs, id)
+	}
+	for _, v := range propertyIDs {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return out, domain.NewFieldError("property_id", fmt.Errorf("parse property id error: %s", err))
+		}
+		out.PropertyIDs = append(out.PropertyIDs, id)
+	}
+	return out, nil
+}
+
+// ChangeResponseSchema holds IDs of the changed object, a value is identified by the record and the property.
+type ChangeResponseSchema struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	RefTypeID  *string   `json:"reference_type_id"`
+	PropertyID *string   `json:"property_id"`
+	RecordID   *string   `json:"record_id"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+func ChangeToResponseSchema(c domain.Change) (ChangeResponseSchema, error) {
+	tp, err := c.DataType.Code()
+	if err != nil {
+		return ChangeResponseSchema{}, err
+	}
+	optionalID := func(id uuid.UUID) *string {
+		if helper.IsZeroUUID(id) {
+			return nil
+		}
+		v := id.String()
+		return &v
+	}
+	return ChangeResponseSchema{
+		ID:         c.ID,
+		Type:       tp,
+		RefTypeID:  optionalID(c.RefTypeID),
+		PropertyID: optionalID(c.PropertyID),
+		RecordID:   optionalID(c.RecordID),
+		ChangedAt:  c.ChangedAt,
+	}, nil
+}
+
+type ChangeFeedRequestSchema struct {
+	// After is the ID of the last read change, the cursor of the consumer is read from if it is empty.
+	After       string
+	Consumer    string
+	Limit       string
+	RefTypeIDs  []string
+	PropertyIDs []string
+}
+
+// ChangeLogRequest parses the request, AfterID is not set if the cursor of the consumer should be read.
+func (s ChangeFeedRequestSchema) ChangeLogRequest() (domain.ChangeLogRequest, error) {
+	var out domain.ChangeLogRequest
+	if s.After != "" {
+		id, err := parseChangeID("after", s.After)
+		if err != nil {
+			return out, err
+		}
+		out.AfterID = id
+	}
+	if s.Consumer != "" && !changeConsumerNameRegexp.MatchString(s.Consumer) {
+		return out, domain.NewFieldError("consumer", fmt.Errorf("consumer name %q is invalid", s.Consumer))
+	}
+	if s.Limit != "" {
+		limit, err := strconv.ParseUint(s.Limit, 10, 32)
+		if err != nil || limit == 0 {
+			return out, domain.NewFieldError("limit", fmt.Errorf("parse limit error: %s is not a positive number", s.Limit))
+		}
+		out.Limit = uint(limit)
+	}
+	filter, err := parseChangeFilter(s.RefTypeIDs, s.PropertyIDs)
+	if err != nil {
+		return out, err
+	}
+	out.Filter = filter
+	return out, nil
+}
+
+// ChangeFeedResponseSchema holds the page of changes, Next is the ID to request changes after
+// and to store as the cursor of the consumer once the changes are processed.
+type ChangeFeedResponseSchema struct {
+	Changes []ChangeResponseSchema `json:"changes"`
+	Next    int64                  `json:"next"`
+}
+
+type SetChangeConsumerRequestSchema struct {
+	Name         string `json:"-"`
+	LastChangeID *int64 `json:"last_change_id"`
+}
+
+func (s SetChangeConsumerRequestSchema) Validate() error {
+	if err := validateChangeConsumerName(s.Name); err != nil {
+		return err
+	}
+	if s.LastChangeID == nil {
+		return domain.NewFieldError("last_change_id", errors.New("last change ID expected"))
+	}
+	if *s.LastChangeID < 0 {
+		return domain.NewFieldError("last_change_id", fmt.Errorf("%d is not a change ID", *s.LastChangeID))
+	}
+	return nil
+}
+
+func validateChangeConsumerName(name string) error {
+	if !changeConsumerNameRegexp.MatchString(name) {
+		return domain.NewFieldError("name", fmt.Errorf("consumer name %q is invalid", name))
+	}
+	return nil
+}
+
+type ChangeConsumerResponseSchema struct {
+	Name         string    `json:"name"`
+	LastChangeID int64     `json:"last_change_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func ChangeConsumerToResponseSchema(c domain.ChangeConsumer) ChangeConsumerResponseSchema {
+	return ChangeConsumerResponseSchema{
+		Name:         c.Name,
+		LastChangeID: c.LastChangeID,
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// errTooManyCSVRows stops the import of the CSV.
+var errTooManyCSVRows = fmt.Errorf("too many rows, limit is %d", maxCSVImportRows)
+
+// ImportCSV adds a record of the reference type for every row of the CSV and sets its values
+// within the one transaction, see api.ImportManager.
+func ImportCSV(ctx context.Context, rtm *api.RefTypeManager, pm *api.PropertyManager, im *api.ImportManager, req CSVImportRequestSchema, r io.Reader) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	refTypeID, err := uuid.Parse(req.RefTypeID)
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, domain.NewFieldError("ref_type_id", fmt.Errorf("parse reference type id error: %s", err))
+	}
+	if _, err := rtm.Get(ctx, refTypeID); err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	delimiter, err := req.Mapping.delimiter()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	cr := csv.NewReader(r)
+	cr.Comma = delimiter
+	cr.FieldsPerRecord = -1
+	var header []string
+	if req.Mapping.Header {
+		header, err = cr.Read()
+		if err != nil {
+			out.Status = http.StatusBadRequest
+			if errors.Is(err, io.EOF) {
+				return out, fmt.Errorf("CSV header %w", domain.ErrExpected)
+			}
+			return out, fmt.Errorf("read CSV header error: %s", err)
+		}
+	}
+	columns, status, err := csvColumns(ctx, pm, req.Mapping, header)
+	if err != nil {
+		out.Status = status
+		return out, err
+	}
+
+	src := &csvRowSource{reader: cr, refTypeID: refTypeID, columns: columns}
+	report, err := im.ImportRows(ctx, src, domain.ImportRowsOptions{
+		DryRun: req.DryRun,
+		IsRowError: func(err error) bool {
+			return batchErrorStatus(err) != http.StatusInternalServerError
+		},
+	})
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, errTooManyCSVRows) {
+			out.Status = http.StatusBadRequest
+		}
+		return out, err
+	}
+	b, err := json.Marshal(CSVImportToResponseSchema(*report, req.DryRun))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	if !req.DryRun && len(report.Errors) > 0 {
+		out.Status = http.StatusBadRequest
+		return out, fmt.Errorf("CSV has %d errors, nothing imported", len(report.Errors))
+	}
+	return out, nil
+}
+
+// csvColumns resolves columns of the mapping and returns status of the failure.
+func csvColumns(ctx context.Context, pm *api.PropertyManager, mapping CSVMappingSchema, header []string) ([]csvColumn, int, error) {
+	if len(mapping.Columns) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("mapping columns %w", domain.ErrExpected)
+	}
+	out := make([]csvColumn, 0, len(mapping.Columns))
+	targets := make(map[string]struct{}, len(mapping.Columns))
+	properties := make(map[uuid.UUID]*domain.Property)
+	for _, s := range mapping.Columns {
+		c, err := s.column(header)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		target := c.target
+		if target == CSVTargetProperty {
+			target = c.propertyID.String()
+		}
+		if _, ok := targets[target]; ok {
+			return nil, http.StatusBadRequest, fmt.Errorf("column %s: target %s duplicated", c.name, target)
+		}
+		targets[target] = struct{}{}
+		if c.target == CSVTargetProperty {
+			p, ok := properties[c.propertyID]
+			if !ok {
+				p, err = pm.Get(ctx, c.propertyID)
+				if err != nil {
+					if errors.Is(err, domain.ErrNotFound) {
+						return nil, http.StatusBadRequest, fmt.Errorf("column %s: %w", c.name, err)
+					}
+					return nil, http.StatusInternalServerError, err
+				}
+				properties[c.propertyID] = p
+			}
+			if err := c.resolveProperty(*p); err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+		}
+		out = append(out, c)
+	}
+	return out, 0, nil
+}
+
+// csvRowSource reads rows of the CSV and parses their cells by the columns.
+type csvRowSource struct {
+	reader    *csv.Reader
+	refTypeID uuid.UUID
+	columns   []csvColumn
+	rows      int
+}
+
+func (src *csvRowSource) Next() (*domain.ImportRow, error) {
+	record, err := src.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	src.rows++
+	if src.rows > maxCSVImportRows {
+		return nil, errTooManyCSVRows
+	}
+	if err != nil {
+		// Malformed rows have no positions of fields, their lines are taken from errors
+		var errParse *csv.ParseError
+		if !errors.As(err, &errParse) {
+			return nil, fmt.Errorf("read CSV error: %w", err)
+		}
+		return &domain.ImportRow{
+			Line:   errParse.StartLine,
+			Errors: []domain.ImportRowError{{Error: err.Error()}},
+		}, nil
+	}
+	line, _ := src.reader.FieldPos(0)
+	return csvImportRow(line, src.refTypeID, src.columns, record), nil
+}
+
+// csvImportRow parses cells of the record, the row has errors of the cells which are not parsed.
+func csvImportRow(line int, refTypeID uuid.UUID, columns []csvColumn, record []string) *domain.ImportRow {
+	out := &domain.ImportRow{
+		Line:   line,
+		Record: domain.AddRecordRequest{ReferenceTypeID: refTypeID},
+	}
+	for _, c := range columns {
+		if c.index >= len(record) {
+			out.Errors = append(out.Errors, domain.ImportRowError{Column: c.name, Error: "column missing"})
+			continue
+		}
+		cell := record[c.index]
+		switch c.target {
+		case CSVTargetName:
+			out.Record.Name = cell
+		case CSVTargetDescription:
+			out.Record.Description = cell
+		case CSVTargetProperty:
+			v, err := c.parse(cell)
+			if err != nil {
+				out.Errors = append(out.Errors, domain.ImportRowError{Column: c.name, Error: err.Error()})
+				continue
+			}
+			if v == nil {
+				continue
+			}
+			out.Values = append(out.Values, domain.SetValueRequest{
+				PropertyID: c.propertyID,
+				Type:       c.tp,
+				RefTypeID:  c.refTypeID,
+				Value:      v,
+			})
+			out.Columns = append(out.Columns, c.name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"datatom/internal/domain"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CSVTargetName        = "name"
+	CSVTargetDescription = "description"
+	CSVTargetProperty    = "property"
+
+	maxCSVImportRows = 100000
+)
+
+var defaultCSVDateLayouts = []string{time.RFC3339, "2006-01-02"}
+
+type CSVImportRequestSchema struct {
+	RefTypeID string
+	DryRun    bool
+	Mapping   CSVMappingSchema
+}
+
+// CSVMappingSchema assigns columns of the CSV to record fields or properties.
+// Columns are addressed by header names if the first line is a header
+// and by zero based indexes otherwise.
+type CSVMappingSchema struct {
+	Header    bool              `json:"header"`
+	Delimiter string            `json:"delimiter,omitempty"`
+	Columns   []CSVColumnSchema `json:"columns"`
+}
+
+type CSVColumnSchema struct {
+	Header     string `json:"header,omitempty"`
+	Index      *int   `json:"index,omitempty"`
+	Target     string `json:"target"`
+	PropertyID string `json:"property_id,omitempty"`
+	// Type is one of the property types, it may be omitted if the property has the only type
+	Type      string `json:"type,omitempty"`
+	RefTypeID string `json:"reference_type_id,omitempty"`
+	// DateLayouts are tried in order, RFC 3339 and 2006-01-02 are used by default
+	DateLayouts []string `json:"date_layouts,omitempty"`
+	// DecimalSeparator and ThousandsSeparator of numbers, "." and none by default
+	DecimalSeparator   string `json:"decimal_separator,omitempty"`
+	ThousandsSeparator string `json:"thousands_separator,omitempty"`
+}
+
+type CSVImportResponseSchema struct {
+	DryRun   bool                `json:"dry_run"`
+	Rows     int                 `json:"rows"`
+	Valid    int                 `json:"valid"`
+	Imported int                 `json:"imported"`
+	Errors   []CSVRowErrorSchema `json:"errors"`
+}
+
+func CSVImportToResponseSchema(r domain.ImportReport, dryRun bool) CSVImportResponseSchema {
+	out := CSVImportResponseSchema{
+		DryRun:   dryRun,
+		Rows:     r.Rows,
+		Valid:    r.Valid,
+		Imported: r.Imported,
+		Errors:   make([]CSVRowErrorSchema, 0, len(r.Errors)),
+	}
+	for _, e := range r.Errors {
+		out.Errors = append(out.Errors, CSVRowErrorSchema{Row: e.Line, Column: e.Column, Error: e.Error})
+	}
+	return out
+}
+
+// CSVRowErrorSchema describes an error of the row, Row is the line number of the CSV.
+type CSVRowErrorSchema struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// csvColumn is the mapping of the column resolved against the header and the property.
+type csvColumn struct {
+	name               string
+	index              int
+	target             string
+	propertyID         uuid.UUID
+	tp                 domain.Type
+	refTypeID          uuid.UUID
+	dateLayouts        []string
+	decimalSeparator   string
+	thousandsSeparator string
+}
+
+func (s CSVMappingSchema) delimiter() (rune, error) {
+	if s.Delimiter == "" {
+		return ',', nil
+	}
+	r, size := utf8.DecodeRuneInString(s.Delimiter)
+	if size != len(s.Delimiter) || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q", s.Delimiter)
+	}
+	return r, nil
+}
+
+// column resolves the column of the header, header is nil if the CSV has no header.
+func (s CSVColumnSchema) column(header []string) (csvColumn, error) {
+	out := csvColumn{
+		target:             s.Target,
+		dateLayouts:        s.DateLayouts,
+		decimalSeparator:   s.DecimalSeparator,
+		thousandsSeparator: s.ThousandsSeparator,
+	}
+	switch {
+	case s.Index != nil:
+		out.index = *s.Index
+		out.name = strconv.Itoa(out.index)
+		if out.index < 0 || (header != nil && out.index >= len(header)) {
+			return out, fmt.Errorf("column index %d out of range", out.index)
+		}
+		if header != nil {
+			out.name = header[out.index]
+		}
+	case s.Header != "":
+		if header == nil {
+			return out, fmt.Errorf("column %s can not be found without header", s.Header)
+		}
+		out.index = -1
+		for i, h := range header {
+			if h == s.Header {
+				out.index = i
+				break
+			}
+		}
+		if out.index < 0 {
+			return out, fmt.Errorf("column %s not found in header", s.Header)
+		}
+		out.name = s.Header
+	default:
+		return out, fmt.Errorf("column header or index %w", domain.ErrExpected)
+	}
+	if len(out.dateLayouts) == 0 {
+		out.dateLayouts = defaultCSVDateLayouts
+	}
+	if out.decimalSeparator == "" {
+		out.decimalSeparator = "."
+	}
+	switch s.Target {
+	case CSVTargetName, CSVTargetDescription:
+		return out, nil
+	case CSVTargetProperty:
+	default:
+		return out, fmt.Errorf(`column %s: %w "%s" of target`, out.name, domain.ErrUnknownType, s.Target)
+	}
+	id, err := uuid.Parse(s.PropertyID)
+	if err != nil {
+		return out, fmt.Errorf("column %s: parse property id error: %s", out.name, err)
+	}
+	out.propertyID = id
+	if s.Type != "" {
+		out.tp = domain.TypeFromCode(s.Type)
+		if out.tp == domain.UndefinedType {
+			return out, fmt.Errorf("column %s: unknown type %s", out.name, s.Type)
+		}
+	}
+	if s.RefTypeID != "" {
+		rtID, err := uuid.Parse(s.RefTypeID)
+		if err != nil {
+			return out, fmt.Errorf("column %s: parse reference type id error: %s", out.name, err)
+		}
+		out.refTypeID = rtID
+	}
+	return out, nil
+}
+
+// resolveProperty checks the type of the column by the property and takes its
+// only type or reference type if they are not set by the mapping.
+func (c *csvColumn) resolveProperty(p domain.Property) error {
+	if c.tp == domain.UndefinedType {
+		if len(p.Types) != 1 {
+			return fmt.Errorf("column %s: type %w, property has %d types", c.name, domain.ErrExpected, len(p.Types))
+		}
+		c.tp = p.Types[0]
+	}
+	allowed := false
+	for _, tp := range p.Types {
+		if tp == c.tp {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("column %s: property has no type %s", c.name, c.tp.Code())
+	}
+	if c.tp == domain.TypeReference && c.refTypeID == uuid.Nil {
+		if len(p.RefTypeIDs) != 1 {
+			return fmt.Errorf("column %s: reference type %w, property has %d reference types", c.name, domain.ErrExpected, len(p.RefTypeIDs))
+		}
+		c.refTypeID = p.RefTypeIDs[0]
+	}
+	if c.tp != domain.TypeReference {
+		c.refTypeID = uuid.Nil
+	}
+	return nil
+}
+
+// parse converts the cell to the value of the column type. Empty cells give nil.
+func (c csvColumn) parse(cell string) (any, error) {
+	if c.tp != domain.TypeText {
+		cell = strings.TrimSpace(cell)
+	}
+	if cell == "" {
+		return nil, nil
+	}
+	var v any
+	switch c.tp {
+	case domain.TypeText:
+		v = cell
+	case domain.TypeNumber:
+		if c.thousandsSeparator != "" {
+			cell = strings.ReplaceAll(cell, c.thousandsSeparator, "")
+		}
+		cell = strings.Replace(cell, c.decimalSeparator, ".", 1)
+		x, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w number error: %s", domain.ErrParseError, err)
+		}
+		v = x
+	case domain.TypeBool:
+		x, err := strconv.ParseBool(cell)
+		if err != nil {
+			return nil, fmt.Errorf("%w bool error: %s", domain.ErrParseError, err)
+		}
+		v = x
+	case domain.TypeDate:
+		var errParse error
+		for _, layout := range c.dateLayouts {
+			x, err := time.Parse(layout, cell)
+			if err == nil {
+				v = x
+				break
+			}
+			errParse = err
+		}
+		if v == nil {
+			return nil, fmt.Errorf("%w date error: %s", domain.ErrParseError, errParse)
+		}
+	default:
+		v = cell
+	}
+	return domain.ValidatedValue(v, c.tp)
+}
@@ -90,7 +90,7 @@ func Subscribe(ctx context.Context, req SubscribeRequest) (Result, error) {
 	propertyID, err := uuid.Parse(req.Schema.PropertyID)
 	if err != nil {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("parse property ID error: %s", err)
+		return out, domain.NewFieldError("property_id", fmt.Errorf("parse property ID error: %s", err))
 	}
 	if _, err := req.PropertyMan.Get(ctx, propertyID); err != nil {
 		out.Status = http.StatusInternalServerError
@@ -102,7 +102,7 @@ func Subscribe(ctx context.Context, req SubscribeRequest) (Result, error) {
 	consumerID, err := uuid.Parse(req.Schema.ConsumerID)
 	if err != nil {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("parse consumer ID error: %s", err)
+		return out, domain.NewFieldError("consumer_id", fmt.Errorf("parse consumer ID error: %s", err))
 	}
 	client, err := req.GRPCConn.NewClient()
 	if err != nil {
@@ -139,7 +139,7 @@ func DeleteSubscription(ctx context.Context, req DeleteSubscriptionRequest) (Res
 	propertyID, err := uuid.Parse(req.Schema.PropertyID)
 	if err != nil {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("parse property ID error: %s", err)
+		return out, domain.NewFieldError("property_id", fmt.Errorf("parse property ID error: %s", err))
 	}
 	if _, err := req.PropertyMan.Get(ctx, propertyID); err != nil {
 		out.Status = http.StatusInternalServerError
@@ -151,7 +151,7 @@ func DeleteSubscription(ctx context.Context, req DeleteSubscriptionRequest) (Res
 	consumerID, err := uuid.Parse(req.Schema.ConsumerID)
 	if err != nil {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("parse consumer ID error: %s", err)
+		return out, domain.NewFieldError("consumer_id", fmt.Errorf("parse consumer ID error: %s", err))
 	}
 	client, err := req.GRPCConn.NewClient()
 	if err != nil {
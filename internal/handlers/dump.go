@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ExportDump writes the whole tom to w as NDJSON in the dependency order.
+// Status of the result makes sense only if nothing is written yet.
+func ExportDump(ctx context.Context, man *api.DumpManager, w io.Writer) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	enc := json.NewEncoder(w)
+	err := man.Export(ctx, func(item domain.DumpItem) error {
+		schema, err := DumpItemToSchema(item)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(schema)
+	})
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	return out, nil
+}
+
+// ImportDump reads NDJSON of the export from r and inserts it with the same IDs.
+func ImportDump(ctx context.Context, man *api.DumpManager, r io.Reader, opts domain.ImportDumpOptions) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxDumpLineSize)
+	stats, err := man.Import(ctx, &dumpLineSource{scanner: scanner}, opts)
+	if err != nil {
+		out.Status = dumpErrorStatus(err)
+		return out, err
+	}
+	b, err := json.Marshal(DumpStatsToResponseSchema(*stats))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
+
+func dumpErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrDumpItemOrder),
+		errors.Is(err, domain.ErrDumpItemDuplicated),
+		errors.Is(err, domain.ErrDumpItemReferenceMissing),
+		isBadRequestError(err),
+		isBadRequestError(errors.Unwrap(err)):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// dumpLineSource parses items of the dump line by line, so the import does not hold the whole dump.
+type dumpLineSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (dls *dumpLineSource) Next() (*domain.DumpItem, error) {
+	for dls.scanner.Scan() {
+		dls.line++
+		b := dls.scanner.Bytes()
+		if len(b) == 0 {
+			continue
+		}
+		var schema DumpItemSchema
+		if err := json.Unmarshal(b, &schema); err != nil {
+			return nil, fmt.Errorf("%w line %d: %s", domain.ErrParseError, dls.line, err)
+		}
+		item, err := schema.DumpItem()
+		if err != nil {
+			return nil, fmt.Errorf("%w line %d: %s", domain.ErrParseError, dls.line, err)
+		}
+		return item, nil
+	}
+	if err := dls.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("%w line %d: %s", domain.ErrParseError, dls.line+1, err)
+		}
+		return nil, fmt.Errorf("read dump error: %w", err)
+	}
+	return nil, io.EOF
+}
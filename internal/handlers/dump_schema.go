@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"datatom/internal/domain"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Lines of the NDJSON dump are limited to keep memory of the import bounded.
+const maxDumpLineSize = 16 << 20
+
+// DumpItemSchema is a line of the NDJSON dump.
+// Data is of the response schema of the kind with the ID of the object.
+type DumpItemSchema struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+type DumpPropertySchema struct {
+	ID   string              `json:"id"`
+	Mask *PropertyMaskSchema `json:"mask,omitempty"`
+	AddPropertyRequestSchema
+}
+
+type DumpRecordSchema struct {
+	ID string `json:"id"`
+	AddRecordRequestSchema
+}
+
+type SequenceCounterSchema struct {
+	PropertyID string `json:"property_id"`
+	Period     string `json:"period"`
+	LastNumber int64  `json:"last_number"`
+}
+
+type DumpStatsResponseSchema struct {
+	RefTypes         int64 `json:"reference_types"`
+	Properties       int64 `json:"properties"`
+	SequenceCounters int64 `json:"sequence_counters"`
+	Records          int64 `json:"records"`
+	Values           int64 `json:"values"`
+}
+
+func DumpItemToSchema(item domain.DumpItem) (DumpItemSchema, error) {
+	out := DumpItemSchema{Kind: item.Kind.Code()}
+	var data any
+	switch {
+	case item.Kind == domain.DumpItemRefType && item.RefType != nil:
+		data = RefTypeToResponseSchema(*item.RefType)
+	case item.Kind == domain.DumpItemProperty && item.Property != nil:
+		data = PropertyToResponseSchema(*item.Property)
+	case item.Kind == domain.DumpItemSequenceCounter && item.SequenceCounter != nil:
+		data = SequenceCounterSchema{
+			PropertyID: item.SequenceCounter.PropertyID.String(),
+			Period:     item.SequenceCounter.Period,
+			LastNumber: item.SequenceCounter.LastNumber,
+		}
+	case item.Kind == domain.DumpItemRecord && item.Record != nil:
+		data = RecordToResponseSchema(*item.Record)
+	case item.Kind == domain.DumpItemValue && item.Value != nil:
+		data = ValueToResponseSchema(*item.Value)
+	default:
+		return out, fmt.Errorf("%w %s item", domain.ErrExpected, item.Kind.String())
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return out, err
+	}
+	out.Data = b
+	return out, nil
+}
+
+func (s DumpItemSchema) DumpItem() (*domain.DumpItem, error) {
+	kind, err := domain.DumpItemKindFromCode(s.Kind)
+	if err != nil {
+		return nil, err
+	}
+	out := domain.DumpItem{Kind: kind}
+	switch kind {
+	case domain.DumpItemRefType:
+		var schema RefTypeResponseSchema
+		if err := json.Unmarshal(s.Data, &schema); err != nil {
+			return nil, fmt.Errorf("data unmarshal error: %s", err)
+		}
+		id, err := uuid.Parse(schema.ID)
+		if err != nil {
+			return nil, fmt.Errorf("parse reference type id error: %s", err)
+		}
+		out.RefType = &domain.RefType{
+			ID:          id,
+			Name:        schema.Name,
+			Description: schema.Description,
+		}
+	case domain.DumpItemProperty:
+		var schema DumpPropertySchema
+		if err := json.Unmarshal(s.Data, &schema); err != nil {
+			return nil, fmt.Errorf("data unmarshal error: %s", err)
+		}
+		id, err := uuid.Parse(schema.ID)
+		if err != nil {
+			return nil, fmt.Errorf("parse property id error: %s", err)
+		}
+		r, unknownTypes, err := schema.AddPropertyRequest()
+		if err != nil {
+			return nil, err
+		}
+		if len(unknownTypes) > 0 {
+			return nil, fmt.Errorf("unknown types %v", unknownTypes)
+		}
+		var mask *domain.MaskPolicy
+		if schema.Mask != nil {
+			if mask, err = schema.Mask.MaskPolicy(); err != nil {
+				return nil, err
+			}
+		}
+		out.Property = &domain.Property{
+			ID:             id,
+			Types:          r.Types,
+			RefTypeIDs:     r.RefTypeIDs,
+			Name:           r.Name,
+			Description:    r.Description,
+			OwnerRefTypeID: r.OwnerRefTypeID,
+			Kind:           r.Kind,
+			Sequence:       r.Sequence,
+			StateMachine:   r.StateMachine,
+			Encrypted:      r.Encrypted,
+			Mask:           mask,
+		}
+	case domain.DumpItemSequenceCounter:
+		var schema SequenceCounterSchema
+		if err := json.Unmarshal(s.Data, &schema); err != nil {
+			return nil, fmt.Errorf("data unmarshal error: %s", err)
+		}
+		id, err := uuid.Parse(schema.PropertyID)
+		if err != nil {
+			return nil, fmt.Errorf("parse property id error: %s", err)
+		}
+		out.SequenceCounter = &domain.SequenceCounter{
+			PropertyID: id,
+			Period:     schema.Period,
+			LastNumber: schema.LastNumber,
+		}
+	case domain.DumpItemRecord:
+		var schema DumpRecordSchema
+		if err := json.Unmarshal(s.Data, &schema); err != nil {
+			return nil, fmt.Errorf("data unmarshal error: %s", err)
+		}
+		id, err := uuid.Parse(schema.ID)
+		if err != nil {
+			return nil, fmt.Errorf("parse record id error: %s", err)
+		}
+		r, err := schema.AddRecordRequest()
+		if err != nil {
+			return nil, err
+		}
+		out.Record = &domain.Record{
+			ID:              id,
+			ReferenceTypeID: r.ReferenceTypeID,
+			Name:            r.Name,
+			Description:     r.Description,
+			DeletionMark:    r.DeletionMark,
+		}
+	case domain.DumpItemValue:
+		var schema SetValueRequestSchema
+		if err := json.Unmarshal(s.Data, &schema); err != nil {
+			return nil, fmt.Errorf("data unmarshal error: %s", err)
+		}
+		r, err := schema.SetValueRequest()
+		if err != nil {
+			return nil, err
+		}
+		value, err := dumpValue(r.Value, r.Type)
+		if err != nil {
+			return nil, err
+		}
+		out.Value = &domain.Value{
+			RecordID:   r.RecordID,
+			PropertyID: r.PropertyID,
+			Type:       r.Type,
+			RefTypeID:  r.RefTypeID,
+			Value:      value,
+		}
+	}
+	return &out, nil
+}
+
+// dumpValue keeps envelopes of values of encrypted properties which are dumped as {"enc": {...}}.
+func dumpValue(v any, t domain.Type) (any, error) {
+	if _, ok := v.(map[string]any); !ok {
+		return domain.ValidatedValue(v, t)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var schema domain.ValueJSONSchema
+	if err := json.Unmarshal(b, &schema); err != nil {
+		return nil, fmt.Errorf("%w encrypted value error: %s", domain.ErrParseError, err)
+	}
+	if schema.Enc == nil {
+		return nil, fmt.Errorf("%w %T for %s", domain.ErrUnexpectedTypePG, v, t.String())
+	}
+	return schema.Validated(t)
+}
+
+func DumpStatsToResponseSchema(ds domain.DumpStats) DumpStatsResponseSchema {
+	return DumpStatsResponseSchema{
+		RefTypes:         ds.RefTypes,
+		Properties:       ds.Properties,
+		SequenceCounters: ds.SequenceCounters,
+		Records:          ds.Records,
+		Values:           ds.Values,
+	}
+}
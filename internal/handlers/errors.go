@@ -18,10 +18,23 @@ func init() {
 		ErrUnexpectedRefTypePG:        {},
 		ErrRefTypeExpectedPG:          {},
 		ErrRefTypeIsRedundantPG:       {},
+		ErrSequenceTypeMismatchPG:     {},
+		ErrSequenceOwnerExpectedPG:    {},
+		ErrSequenceTemplatePG:         {},
+		ErrStateMachineTypeMismatchPG: {},
+		ErrNotStateMachinePG:          {},
+		ErrUnknownStatePG:             {},
+		ErrEncryptedPropertyKindPG:    {},
 	}
 }
 
+// isBadRequestError reports whether err wraps one of the bad request errors,
+// they may be annotated with details of the PostgreSQL exceptions.
 func isBadRequestError(err error) bool {
-	_, ok := badRequestErrors[err]
-	return ok || errors.Is(err, ErrParseError)
+	for e := range badRequestErrors {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return errors.Is(err, ErrParseError)
 }
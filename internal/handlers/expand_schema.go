@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"datatom/internal/domain"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultExpandDepth = 1
+	maxExpandDepth     = 3
+)
+
+type ExpandRequestSchema struct {
+	Expand string
+	Depth  string
+}
+
+func (s ExpandRequestSchema) ExpandRequest() (domain.ExpandRequest, error) {
+	out := domain.ExpandRequest{Depth: defaultExpandDepth}
+	for _, v := range strings.Split(s.Expand, ",") {
+		switch strings.TrimSpace(v) {
+		case "values":
+			out.Values = true
+		case "refs":
+			out.Refs = true
+		case "":
+		default:
+			return out, fmt.Errorf(`%w "%s" of expand`, domain.ErrUnknownType, v)
+		}
+	}
+	if s.Depth != "" {
+		depth, err := strconv.ParseUint(s.Depth, 10, 32)
+		if err != nil {
+			return out, domain.NewFieldError("depth", fmt.Errorf("parse depth error: %s", err))
+		}
+		if depth == 0 || depth > maxExpandDepth {
+			return out, fmt.Errorf("depth must be between 1 and %d", maxExpandDepth)
+		}
+		out.Depth = uint(depth)
+	}
+	return out, nil
+}
+
+func (s ExpandRequestSchema) IsEmpty() bool {
+	return s.Expand == "" && s.Depth == ""
+}
+
+// ExpandedRecordToResponseSchema embeds referenced records up to depth hops
+// which also breaks cycles of records referring to each other.
+func ExpandedRecordToResponseSchema(r domain.ExpandedRecord, depth uint) RecordResponseSchema {
+	out := RecordToResponseSchema(r.Record)
+	if r.Values != nil {
+		out.Values = make([]ValueResponseSchema, 0, len(r.Values))
+		for _, v := range r.Values {
+			out.Values = append(out.Values, ExpandedValueToResponseSchema(v, depth))
+		}
+	}
+	return out
+}
+
+func ExpandedValueToResponseSchema(v domain.ExpandedValue, depth uint) ValueResponseSchema {
+	out := ValueToResponseSchema(v.Value)
+	if v.Ref != nil && depth > 0 {
+		ref := ExpandedRecordToResponseSchema(*v.Ref, depth-1)
+		out.Ref = &ref
+	}
+	return out
+}
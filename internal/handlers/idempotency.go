@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"errors"
+	"net/http"
+)
+
+// ReserveIdempotencyKey returns the response to the first request if the request is repeated.
+// The request has to be processed if the response is nil.
+func ReserveIdempotencyKey(ctx context.Context, man *api.IdempotencyManager, req IdempotencyRequestSchema) (Result, *domain.IdempotentResponse, error) {
+	out := Result{Status: http.StatusOK}
+	key, err := req.IdempotencyKey()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, nil, err
+	}
+	resp, err := man.Reserve(ctx, key)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrIdempotencyKeyInProgress) {
+			out.Status = http.StatusConflict
+		} else if errors.Is(err, domain.ErrIdempotencyKeyReused) {
+			out.Status = http.StatusUnprocessableEntity
+		}
+		return out, nil, err
+	}
+	return out, resp, nil
+}
+
+// SaveIdempotentResponse stores the response to be replayed. Responses with server errors
+// are not stored and the key is released, so the request can be retried.
+func SaveIdempotentResponse(ctx context.Context, man *api.IdempotencyManager, req IdempotencyRequestSchema, resp domain.IdempotentResponse) error {
+	key, err := req.IdempotencyKey()
+	if err != nil {
+		return err
+	}
+	if resp.Status >= http.StatusInternalServerError {
+		return man.Release(ctx, key)
+	}
+	return man.Save(ctx, key, resp)
+}
+
+// ReleaseIdempotencyKey lets the request be retried if its response is not stored, e.g. its handler panicked.
+func ReleaseIdempotencyKey(ctx context.Context, man *api.IdempotencyManager, req IdempotencyRequestSchema) error {
+	key, err := req.IdempotencyKey()
+	if err != nil {
+		return err
+	}
+	return man.Release(ctx, key)
+}
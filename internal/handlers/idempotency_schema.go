@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"datatom/internal/domain"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyRequestSchema is the request with the key sent by the client, the key is scoped by method and path
+// and by Subject of the authenticated principal, so principals do not get responses to each other.
+type IdempotencyRequestSchema struct {
+	Key     string
+	Method  string
+	Path    string
+	Subject string
+	Body    []byte
+}
+
+func (s IdempotencyRequestSchema) IdempotencyKey() (domain.IdempotencyKey, error) {
+	var out domain.IdempotencyKey
+	key := strings.TrimSpace(s.Key)
+	if key == "" {
+		return out, fmt.Errorf("idempotency key %w", domain.ErrExpected)
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return out, fmt.Errorf("idempotency key is longer than %d", maxIdempotencyKeyLength)
+	}
+	sum := sha256.Sum256(s.Body)
+	out.Key = key
+	out.Scope = fmt.Sprintf("%s %s", s.Method, s.Path)
+	if s.Subject != "" {
+		out.Scope = fmt.Sprintf("%s %q", out.Scope, s.Subject)
+	}
+	out.Fingerprint = hex.EncodeToString(sum[:])
+	return out, nil
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+func SetPropertyMask(ctx context.Context, man *api.PropertyManager, req SetPropertyMaskRequestSchema) (Result, error) {
+	mask, err := req.MaskPolicy()
+	if err != nil {
+		return Result{Status: http.StatusBadRequest}, err
+	}
+	return setPropertyMask(ctx, man, req.ID, mask)
+}
+
+func DeletePropertyMask(ctx context.Context, man *api.PropertyManager, id string) (Result, error) {
+	return setPropertyMask(ctx, man, id, nil)
+}
+
+func setPropertyMask(ctx context.Context, man *api.PropertyManager, id string, mask *domain.MaskPolicy) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	pid, err := uuid.Parse(id)
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, domain.NewFieldError("id", fmt.Errorf("parse property id error: %s", err))
+	}
+	property, err := man.SetMask(ctx, pid, mask)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	b, err := json.Marshal(PropertyToResponseSchema(*property))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	out.Sum = property.Sum
+	return out, nil
+}
+
+func GetUnmaskedReads(ctx context.Context, man *api.MaskManager, req UnmaskedReadsRequestSchema) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	r, err := req.UnmaskedReadsRequest()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	reads, err := man.UnmaskedReads(ctx, r)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	b, err := json.Marshal(UnmaskedReadsToResponseSchema(reads, r.AfterID))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
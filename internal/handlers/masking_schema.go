@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"datatom/internal/domain"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PropertyMaskSchema struct {
+	Kind          string   `json:"kind"`
+	Reveal        uint     `json:"reveal,omitempty"`
+	UnmaskedRoles []string `json:"unmasked_roles,omitempty"`
+}
+
+// MaskPolicy returns an error if the kind is unknown or the number of revealed runes is set for
+// the mask which is not partial.
+func (s PropertyMaskSchema) MaskPolicy() (*domain.MaskPolicy, error) {
+	kind, err := domain.MaskKindFromCode(s.Kind)
+	if err != nil {
+		return nil, domain.NewFieldError("kind", err)
+	}
+	if s.Reveal != 0 && kind != domain.MaskPartial {
+		return nil, domain.NewFieldError("reveal", fmt.Errorf("reveal is %w only by partial masks", domain.ErrExpected))
+	}
+	for i, r := range s.UnmaskedRoles {
+		if r == "" {
+			return nil, domain.NewFieldError(fmt.Sprintf("unmasked_roles[%d]", i), fmt.Errorf("role %w", domain.ErrExpected))
+		}
+	}
+	return &domain.MaskPolicy{Kind: kind, Reveal: s.Reveal, UnmaskedRoles: s.UnmaskedRoles}, nil
+}
+
+func MaskPolicyToSchema(mp *domain.MaskPolicy) *PropertyMaskSchema {
+	if mp == nil {
+		return nil
+	}
+	return &PropertyMaskSchema{
+		Kind:          mp.Kind.Code(),
+		Reveal:        mp.Reveal,
+		UnmaskedRoles: mp.UnmaskedRoles,
+	}
+}
+
+type SetPropertyMaskRequestSchema struct {
+	ID string `json:"-"`
+	PropertyMaskSchema
+}
+
+type UnmaskedReadsRequestSchema struct {
+	// After is the ID of the last read audit record
+	After      string
+	PropertyID string
+	Subject    string
+	Limit      string
+}
+
+func (s UnmaskedReadsRequestSchema) UnmaskedReadsRequest() (domain.UnmaskedReadsRequest, error) {
+	out := domain.UnmaskedReadsRequest{Subject: s.Subject}
+	if s.After != "" {
+		id, err := strconv.ParseInt(s.After, 10, 64)
+		if err != nil || id < 0 {
+			return out, domain.NewFieldError("after", fmt.Errorf("parse after error: %s is not a read ID", s.After))
+		}
+		out.AfterID = id
+	}
+	if s.PropertyID != "" {
+		id, err := uuid.Parse(s.PropertyID)
+		if err != nil {
+			return out, domain.NewFieldError("property_id", fmt.Errorf("parse property id error: %s", err))
+		}
+		out.PropertyID = id
+	}
+	if s.Limit != "" {
+		limit, err := strconv.ParseUint(s.Limit, 10, 16)
+		if err != nil || limit == 0 {
+			return out, domain.NewFieldError("limit", fmt.Errorf("parse limit error: %s is not a positive number", s.Limit))
+		}
+		out.Limit = int(limit)
+	}
+	return out, nil
+}
+
+type UnmaskedReadResponseSchema struct {
+	ID         int64     `json:"id"`
+	Subject    string    `json:"subject"`
+	Roles      []string  `json:"roles"`
+	RecordID   string    `json:"record_id"`
+	PropertyID string    `json:"property_id"`
+	ReadAt     time.Time `json:"read_at"`
+}
+
+// UnmaskedReadsResponseSchema holds the page of audit records, Next is the ID to request records after.
+type UnmaskedReadsResponseSchema struct {
+	Reads []UnmaskedReadResponseSchema `json:"reads"`
+	Next  int64                        `json:"next"`
+}
+
+func UnmaskedReadsToResponseSchema(reads []domain.UnmaskedRead, after int64) UnmaskedReadsResponseSchema {
+	out := UnmaskedReadsResponseSchema{
+		Reads: make([]UnmaskedReadResponseSchema, 0, len(reads)),
+		Next:  after,
+	}
+	for _, r := range reads {
+		roles := r.Roles
+		if roles == nil {
+			roles = []string{}
+		}
+		out.Reads = append(out.Reads, UnmaskedReadResponseSchema{
+			ID:         r.ID,
+			Subject:    r.Subject,
+			Roles:      roles,
+			RecordID:   r.RecordID.String(),
+			PropertyID: r.PropertyID.String(),
+			ReadAt:     r.ReadAt,
+		})
+		out.Next = r.ID
+	}
+	return out
+}
@@ -25,13 +25,15 @@ func AddProperty(ctx context.Context, man *api.PropertyManager, req AddPropertyR
 	}
 	if len(r.Types) == 0 {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("types %w", domain.ErrExpected)
+		return out, domain.NewFieldError("types", fmt.Errorf("types %w", domain.ErrExpected))
 	}
 	id, err := man.Add(ctx, r)
 	if err != nil {
 		out.Status = http.StatusInternalServerError
 		if isBadRequestError(err) {
 			out.Status = http.StatusBadRequest
+		} else if errors.Is(err, domain.ErrAlreadyExists) {
+			out.Status = http.StatusConflict
 		}
 		return out, err
 	}
@@ -43,24 +45,28 @@ func UpdateProperty(ctx context.Context, man *api.PropertyManager, req UpdProper
 	out := Result{Status: http.StatusNoContent}
 	if req.Name == nil {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("name %w", domain.ErrExpected)
+		return out, domain.NewFieldError("name", fmt.Errorf("name %w", domain.ErrExpected))
 	}
 	if req.Description == nil {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("description %w", domain.ErrExpected)
+		return out, domain.NewFieldError("description", fmt.Errorf("description %w", domain.ErrExpected))
 	}
 	r, err := req.UpdPropertyRequest()
 	if err != nil {
 		out.Status = http.StatusBadRequest
 		return out, err
 	}
-	if _, err := man.Update(ctx, r); err != nil {
+	property, err := man.Update(ctx, r)
+	if err != nil {
 		out.Status = http.StatusInternalServerError
 		if errors.Is(err, domain.ErrNotFound) {
 			out.Status = http.StatusNotFound
+		} else if errors.Is(err, domain.ErrSumMismatch) {
+			out.Status = http.StatusPreconditionFailed
 		}
 		return out, err
 	}
+	out.Sum = property.Sum
 	return out, nil
 }
 
@@ -76,6 +82,8 @@ func PatchProperty(ctx context.Context, man *api.PropertyManager, req UpdPropert
 		out.Status = http.StatusInternalServerError
 		if errors.Is(err, domain.ErrNotFound) {
 			out.Status = http.StatusNotFound
+		} else if errors.Is(err, domain.ErrSumMismatch) {
+			out.Status = http.StatusPreconditionFailed
 		}
 		return out, err
 	}
@@ -85,6 +93,7 @@ func PatchProperty(ctx context.Context, man *api.PropertyManager, req UpdPropert
 		return out, err
 	}
 	out.Payload = b
+	out.Sum = property.Sum
 	return out, nil
 }
 
@@ -94,7 +103,7 @@ func GetProperty(ctx context.Context, man *api.PropertyManager, id string) (Resu
 	if err != nil {
 		out.Status = http.StatusBadRequest
 		out.Payload = []byte(fmt.Sprintf("parse property id error: %s", err))
-		return out, err
+		return out, domain.NewFieldError("id", err)
 	}
 	property, err := man.Get(ctx, rid)
 	if err != nil {
@@ -110,5 +119,6 @@ func GetProperty(ctx context.Context, man *api.PropertyManager, id string) (Resu
 		return out, err
 	}
 	out.Payload = b
+	out.Sum = property.Sum
 	return out, nil
 }
@@ -8,18 +8,111 @@ import (
 	"github.com/google/uuid"
 )
 
+type PropertySequenceSchema struct {
+	Prefix      string `json:"prefix"`
+	Template    string `json:"template"`
+	ResetPeriod string `json:"reset_period"`
+}
+
+func (s PropertySequenceSchema) Sequence() (*domain.Sequence, error) {
+	resetPeriod, err := domain.SequenceResetPeriodFromCode(s.ResetPeriod)
+	if err != nil {
+		return nil, err
+	}
+	template := s.Template
+	if template == "" {
+		template = domain.DefaultSequenceTemplate
+	}
+	return &domain.Sequence{
+		Prefix:      s.Prefix,
+		Template:    template,
+		ResetPeriod: resetPeriod,
+	}, nil
+}
+
+func SequenceToSchema(s *domain.Sequence) *PropertySequenceSchema {
+	if s == nil {
+		return nil
+	}
+	return &PropertySequenceSchema{
+		Prefix:      s.Prefix,
+		Template:    s.Template,
+		ResetPeriod: s.ResetPeriod.Code(),
+	}
+}
+
+type PropertyStateMachineSchema struct {
+	States      []string                        `json:"states"`
+	Initial     string                          `json:"initial"`
+	Transitions []PropertyStateTransitionSchema `json:"transitions"`
+}
+
+type PropertyStateTransitionSchema struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (s PropertyStateMachineSchema) StateMachine() (*domain.StateMachine, error) {
+	if len(s.States) == 0 {
+		return nil, fmt.Errorf("states %w", domain.ErrExpected)
+	}
+	initial := s.Initial
+	if initial == "" {
+		initial = s.States[0]
+	}
+	transitions := make([]domain.StateTransition, 0, len(s.Transitions))
+	for _, t := range s.Transitions {
+		transitions = append(transitions, domain.StateTransition{From: t.From, To: t.To})
+	}
+	return &domain.StateMachine{
+		States:      s.States,
+		Initial:     initial,
+		Transitions: transitions,
+	}, nil
+}
+
+func StateMachineToSchema(sm *domain.StateMachine) *PropertyStateMachineSchema {
+	if sm == nil {
+		return nil
+	}
+	transitions := make([]PropertyStateTransitionSchema, 0, len(sm.Transitions))
+	for _, t := range sm.Transitions {
+		transitions = append(transitions, PropertyStateTransitionSchema{From: t.From, To: t.To})
+	}
+	return &PropertyStateMachineSchema{
+		States:      sm.States,
+		Initial:     sm.Initial,
+		Transitions: transitions,
+	}
+}
+
 type AddPropertyRequestSchema struct {
-	Name           string   `json:"name"`
-	Description    string   `json:"description"`
-	Types          []string `json:"types"`
-	RefTypeIDs     []string `json:"reference_type_ids"`
-	OwnerRefTypeID string   `json:"owner_reference_type_id"`
+	// ID is generated if it is not set by the client
+	ID             string                      `json:"id,omitempty"`
+	Name           string                      `json:"name"`
+	Description    string                      `json:"description"`
+	Types          []string                    `json:"types"`
+	RefTypeIDs     []string                    `json:"reference_type_ids"`
+	OwnerRefTypeID string                      `json:"owner_reference_type_id"`
+	Kind           string                      `json:"kind"`
+	Sequence       *PropertySequenceSchema     `json:"sequence,omitempty"`
+	StateMachine   *PropertyStateMachineSchema `json:"state_machine,omitempty"`
+	// Encrypted values are stored and sent as envelopes, encrypted properties are regular ones not of ref type
+	Encrypted bool `json:"encrypted,omitempty"`
 }
 
 func (s AddPropertyRequestSchema) AddPropertyRequest() (domain.AddPropertyRequest, []string, error) {
 	out := domain.AddPropertyRequest{
 		Name:        s.Name,
 		Description: s.Description,
+		Encrypted:   s.Encrypted,
+	}
+	if s.ID != "" {
+		id, err := uuid.Parse(s.ID)
+		if err != nil {
+			return out, nil, domain.NewFieldError("id", fmt.Errorf("parse property id error: %s", err))
+		}
+		out.ID = id
 	}
 
 	var unknownTypes []string
@@ -39,7 +132,7 @@ func (s AddPropertyRequestSchema) AddPropertyRequest() (domain.AddPropertyReques
 	for _, v := range s.RefTypeIDs {
 		id, err := uuid.Parse(v)
 		if err != nil {
-			return out, nil, fmt.Errorf("parse reference type id error: %s", err)
+			return out, nil, domain.NewFieldError("reference_type_ids", fmt.Errorf("parse reference type id error: %s", err))
 		}
 		rtps = append(rtps, id)
 	}
@@ -48,11 +141,46 @@ func (s AddPropertyRequestSchema) AddPropertyRequest() (domain.AddPropertyReques
 	if s.OwnerRefTypeID != "" {
 		ortID, err := uuid.Parse(s.OwnerRefTypeID)
 		if err != nil {
-			return out, nil, fmt.Errorf("parse owner reference type id error: %s", err)
+			return out, nil, domain.NewFieldError("owner_reference_type_id", fmt.Errorf("parse owner reference type id error: %s", err))
 		}
 		out.OwnerRefTypeID = ortID
 	}
 
+	kind, err := domain.PropertyKindFromCode(s.Kind)
+	if err != nil {
+		return out, nil, err
+	}
+	out.Kind = kind
+	switch kind {
+	case domain.PropertyKindSequence:
+		if s.Sequence == nil {
+			return out, nil, fmt.Errorf("sequence settings %w", domain.ErrExpected)
+		}
+		sequence, err := s.Sequence.Sequence()
+		if err != nil {
+			return out, nil, err
+		}
+		out.Sequence = sequence
+	case domain.PropertyKindStateMachine:
+		if s.StateMachine == nil {
+			return out, nil, fmt.Errorf("state machine settings %w", domain.ErrExpected)
+		}
+		stateMachine, err := s.StateMachine.StateMachine()
+		if err != nil {
+			return out, nil, err
+		}
+		out.StateMachine = stateMachine
+	}
+	if kind != domain.PropertyKindSequence && s.Sequence != nil {
+		return out, nil, fmt.Errorf("sequence settings are redundant for %s property", kind.String())
+	}
+	if kind != domain.PropertyKindStateMachine && s.StateMachine != nil {
+		return out, nil, fmt.Errorf("state machine settings are redundant for %s property", kind.String())
+	}
+	if kind != domain.PropertyKindRegular && len(out.Types) == 0 && len(unknownTypes) == 0 {
+		out.Types = []domain.Type{domain.TypeText}
+	}
+
 	if len(unknownTypes) > 0 {
 		return out, unknownTypes, nil
 	}
@@ -63,28 +191,36 @@ type UpdPropertyRequestSchema struct {
 	ID          string  `json:"id"`
 	Name        *string `json:"name,omitempty"`
 	Description *string `json:"description,omitempty"`
+	// ExpectedSum makes the update fail unless the current sum is equal to it
+	ExpectedSum string `json:"expected_sum,omitempty"`
 }
 
 func (s *UpdPropertyRequestSchema) UpdPropertyRequest() (domain.UpdPropertyRequest, error) {
 	out := domain.UpdPropertyRequest{
 		Name:        s.Name,
 		Description: s.Description,
+		ExpectedSum: s.ExpectedSum,
 	}
 	id, err := uuid.Parse(s.ID)
 	if err != nil {
-		return out, fmt.Errorf("parse property id error: %s", err)
+		return out, domain.NewFieldError("id", fmt.Errorf("parse property id error: %s", err))
 	}
 	out.ID = id
 	return out, nil
 }
 
 type PropertyResponseSchema struct {
-	ID             string   `json:"id"`
-	Name           string   `json:"name"`
-	Description    string   `json:"description"`
-	Types          []string `json:"types"`
-	RefTypeIDs     []string `json:"reference_type_ids"`
-	OwnerRefTypeID *string  `json:"owner_reference_type_id"`
+	ID             string                      `json:"id"`
+	Name           string                      `json:"name"`
+	Description    string                      `json:"description"`
+	Types          []string                    `json:"types"`
+	RefTypeIDs     []string                    `json:"reference_type_ids"`
+	OwnerRefTypeID *string                     `json:"owner_reference_type_id"`
+	Kind           string                      `json:"kind"`
+	Sequence       *PropertySequenceSchema     `json:"sequence,omitempty"`
+	StateMachine   *PropertyStateMachineSchema `json:"state_machine,omitempty"`
+	Encrypted      bool                        `json:"encrypted,omitempty"`
+	Mask           *PropertyMaskSchema         `json:"mask,omitempty"`
 }
 
 func PropertyToResponseSchema(p domain.Property) PropertyResponseSchema {
@@ -107,5 +243,10 @@ func PropertyToResponseSchema(p domain.Property) PropertyResponseSchema {
 		Types:          domain.TypesToCodes(p.Types),
 		RefTypeIDs:     refTypeIDs,
 		OwnerRefTypeID: ownerRefTypeID,
+		Kind:           p.Kind.Code(),
+		Sequence:       SequenceToSchema(p.Sequence),
+		StateMachine:   StateMachineToSchema(p.StateMachine),
+		Encrypted:      p.Encrypted,
+		Mask:           MaskPolicyToSchema(p.Mask),
 	}
 }
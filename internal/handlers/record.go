@@ -22,6 +22,9 @@ func AddRecord(ctx context.Context, man *api.RecordManager, req AddRecordRequest
 	id, err := man.Add(ctx, r)
 	if err != nil {
 		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			out.Status = http.StatusConflict
+		}
 		return out, err
 	}
 	out.Payload = id.String()
@@ -32,28 +35,34 @@ func UpdateRecord(ctx context.Context, man *api.RecordManager, req UpdRecordRequ
 	out := Result{Status: http.StatusNoContent}
 	if req.Name == nil {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("name %w", domain.ErrExpected)
+		return out, domain.NewFieldError("name", fmt.Errorf("name %w", domain.ErrExpected))
 	}
 	if req.Description == nil {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("description %w", domain.ErrExpected)
+		return out, domain.NewFieldError("description", fmt.Errorf("description %w", domain.ErrExpected))
 	}
 	if req.DeletionMark == nil {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("deletion mark %w", domain.ErrExpected)
+		return out, domain.NewFieldError("deletion_mark", fmt.Errorf("deletion mark %w", domain.ErrExpected))
 	}
 	r, err := req.UpdRecordRequest()
 	if err != nil {
 		out.Status = http.StatusBadRequest
 		return out, err
 	}
-	if _, err := man.Update(ctx, r); err != nil {
+	record, err := man.Update(ctx, r)
+	if err != nil {
 		out.Status = http.StatusInternalServerError
 		if errors.Is(err, domain.ErrNotFound) {
 			out.Status = http.StatusNotFound
+		} else if errors.Is(err, domain.ErrSumMismatch) {
+			out.Status = http.StatusPreconditionFailed
+		} else if errors.Is(err, domain.ErrForbidden) {
+			out.Status = http.StatusForbidden
 		}
 		return out, err
 	}
+	out.Sum = record.Sum
 	return out, nil
 }
 
@@ -69,6 +78,10 @@ func PatchRecord(ctx context.Context, man *api.RecordManager, req UpdRecordReque
 		out.Status = http.StatusInternalServerError
 		if errors.Is(err, domain.ErrNotFound) {
 			out.Status = http.StatusNotFound
+		} else if errors.Is(err, domain.ErrSumMismatch) {
+			out.Status = http.StatusPreconditionFailed
+		} else if errors.Is(err, domain.ErrForbidden) {
+			out.Status = http.StatusForbidden
 		}
 		return out, err
 	}
@@ -78,6 +91,7 @@ func PatchRecord(ctx context.Context, man *api.RecordManager, req UpdRecordReque
 		return out, err
 	}
 	out.Payload = b
+	out.Sum = record.Sum
 	return out, nil
 }
 
@@ -87,7 +101,7 @@ func GetRecord(ctx context.Context, man *api.RecordManager, id string) (Result,
 	if err != nil {
 		out.Status = http.StatusBadRequest
 		out.Payload = []byte(fmt.Sprintf("parse record id error: %s", err))
-		return out, err
+		return out, domain.NewFieldError("id", err)
 	}
 	record, err := man.Get(ctx, rid)
 	if err != nil {
@@ -103,5 +117,83 @@ func GetRecord(ctx context.Context, man *api.RecordManager, id string) (Result,
 		return out, err
 	}
 	out.Payload = b
+	out.Sum = record.Sum
+	return out, nil
+}
+
+func GetReferencedBy(ctx context.Context, man *api.RecordManager, req ReferencedByRequestSchema) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	r, err := req.ReferencedByRequest()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	refs, err := man.ReferencedBy(ctx, r)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	b, err := json.Marshal(ReferencedByToResponseSchema(refs, r))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
+
+func GetRecordGraph(ctx context.Context, man *api.RecordManager, req GraphRequestSchema) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	r, err := req.GraphRequest()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	graph, err := man.Graph(ctx, r)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	b, err := json.Marshal(GraphToResponseSchema(*graph))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
+
+func GetExpandedRecord(ctx context.Context, man *api.RecordManager, id string, expand ExpandRequestSchema) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	rid, err := uuid.Parse(id)
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, domain.NewFieldError("id", fmt.Errorf("parse record id error: %s", err))
+	}
+	req, err := expand.ExpandRequest()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	record, err := man.GetExpanded(ctx, rid, req)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	b, err := json.Marshal(ExpandedRecordToResponseSchema(*record, req.Depth))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
 	return out, nil
 }
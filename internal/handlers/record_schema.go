@@ -4,11 +4,22 @@ import (
 	"datatom/internal/domain"
 	"datatom/pkg/helper"
 	"fmt"
+	"strconv"
 
 	"github.com/google/uuid"
 )
 
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+
+	defaultGraphDepth = 3
+	maxGraphDepth     = 16
+)
+
 type AddRecordRequestSchema struct {
+	// ID is generated if it is not set by the client
+	ID              string `json:"id,omitempty"`
 	Name            string `json:"name"`
 	Description     string `json:"description"`
 	DeletionMark    bool   `json:"deletion_mark"`
@@ -21,10 +32,17 @@ func (s AddRecordRequestSchema) AddRecordRequest() (domain.AddRecordRequest, err
 		Description:  s.Description,
 		DeletionMark: s.DeletionMark,
 	}
+	if s.ID != "" {
+		id, err := uuid.Parse(s.ID)
+		if err != nil {
+			return out, domain.NewFieldError("id", fmt.Errorf("parse record id error: %s", err))
+		}
+		out.ID = id
+	}
 	if s.ReferenceTypeID != "" {
 		id, err := uuid.Parse(s.ReferenceTypeID)
 		if err != nil {
-			return out, fmt.Errorf("parse reference type id error: %s", err)
+			return out, domain.NewFieldError("reference_type_id", fmt.Errorf("parse reference type id error: %s", err))
 		}
 		out.ReferenceTypeID = id
 	}
@@ -36,6 +54,8 @@ type UpdRecordRequestSchema struct {
 	Name         *string `json:"name,omitempty"`
 	Description  *string `json:"description,omitempty"`
 	DeletionMark *bool   `json:"deletion_mark,omitempty"`
+	// ExpectedSum makes the update fail unless the current sum is equal to it
+	ExpectedSum string `json:"expected_sum,omitempty"`
 }
 
 func (s UpdRecordRequestSchema) UpdRecordRequest() (domain.UpdRecordRequest, error) {
@@ -43,21 +63,23 @@ func (s UpdRecordRequestSchema) UpdRecordRequest() (domain.UpdRecordRequest, err
 		Name:         s.Name,
 		Description:  s.Description,
 		DeletionMark: s.DeletionMark,
+		ExpectedSum:  s.ExpectedSum,
 	}
 	id, err := uuid.Parse(s.ID)
 	if err != nil {
-		return out, fmt.Errorf("parse record id error: %s", err)
+		return out, domain.NewFieldError("id", fmt.Errorf("parse record id error: %s", err))
 	}
 	out.ID = id
 	return out, nil
 }
 
 type RecordResponseSchema struct {
-	ID              string  `json:"id"`
-	Name            string  `json:"name"`
-	Description     string  `json:"description"`
-	DeletionMark    bool    `json:"deletion_mark"`
-	ReferenceTypeID *string `json:"reference_type_id"`
+	ID              string                `json:"id"`
+	Name            string                `json:"name"`
+	Description     string                `json:"description"`
+	DeletionMark    bool                  `json:"deletion_mark"`
+	ReferenceTypeID *string               `json:"reference_type_id"`
+	Values          []ValueResponseSchema `json:"values,omitempty"`
 }
 
 func RecordToResponseSchema(r domain.Record) RecordResponseSchema {
@@ -74,3 +96,169 @@ func RecordToResponseSchema(r domain.Record) RecordResponseSchema {
 		ReferenceTypeID: refTypeID,
 	}
 }
+
+type ReferencedByRequestSchema struct {
+	ID         string
+	PropertyID string
+	RefTypeID  string
+	Limit      string
+	Offset     string
+}
+
+func (s ReferencedByRequestSchema) ReferencedByRequest() (domain.ReferencedByRequest, error) {
+	out := domain.ReferencedByRequest{Limit: defaultPageLimit}
+	id, err := uuid.Parse(s.ID)
+	if err != nil {
+		return out, domain.NewFieldError("id", fmt.Errorf("parse record id error: %s", err))
+	}
+	out.RecordID = id
+	if s.PropertyID != "" {
+		pID, err := uuid.Parse(s.PropertyID)
+		if err != nil {
+			return out, domain.NewFieldError("property_id", fmt.Errorf("parse property id error: %s", err))
+		}
+		out.PropertyID = pID
+	}
+	if s.RefTypeID != "" {
+		rtID, err := uuid.Parse(s.RefTypeID)
+		if err != nil {
+			return out, domain.NewFieldError("reference_type_id", fmt.Errorf("parse reference type id error: %s", err))
+		}
+		out.RefTypeID = rtID
+	}
+	if s.Limit != "" {
+		limit, err := strconv.ParseUint(s.Limit, 10, 32)
+		if err != nil {
+			return out, domain.NewFieldError("limit", fmt.Errorf("parse limit error: %s", err))
+		}
+		if limit == 0 || limit > maxPageLimit {
+			return out, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+		out.Limit = uint(limit)
+	}
+	if s.Offset != "" {
+		offset, err := strconv.ParseUint(s.Offset, 10, 32)
+		if err != nil {
+			return out, domain.NewFieldError("offset", fmt.Errorf("parse offset error: %s", err))
+		}
+		out.Offset = uint(offset)
+	}
+	return out, nil
+}
+
+type RecordReferenceResponseSchema struct {
+	RecordID   string  `json:"record_id"`
+	PropertyID string  `json:"property_id"`
+	RefTypeID  *string `json:"reference_type_id"`
+}
+
+type ReferencedByResponseSchema struct {
+	Items  []RecordReferenceResponseSchema `json:"items"`
+	Limit  uint                            `json:"limit"`
+	Offset uint                            `json:"offset"`
+}
+
+func ReferencedByToResponseSchema(refs []domain.RecordReference, req domain.ReferencedByRequest) ReferencedByResponseSchema {
+	items := make([]RecordReferenceResponseSchema, 0, len(refs))
+	for _, ref := range refs {
+		var refTypeID *string
+		if !helper.IsZeroUUID(ref.RefTypeID) {
+			rtID := ref.RefTypeID.String()
+			refTypeID = &rtID
+		}
+		items = append(items, RecordReferenceResponseSchema{
+			RecordID:   ref.RecordID.String(),
+			PropertyID: ref.PropertyID.String(),
+			RefTypeID:  refTypeID,
+		})
+	}
+	return ReferencedByResponseSchema{
+		Items:  items,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	}
+}
+
+type GraphRequestSchema struct {
+	ID          string
+	PropertyIDs []string
+	Depth       string
+}
+
+func (s GraphRequestSchema) GraphRequest() (domain.GraphRequest, error) {
+	out := domain.GraphRequest{MaxDepth: defaultGraphDepth}
+	id, err := uuid.Parse(s.ID)
+	if err != nil {
+		return out, domain.NewFieldError("id", fmt.Errorf("parse record id error: %s", err))
+	}
+	out.RecordID = id
+	if len(s.PropertyIDs) > 0 {
+		out.PropertyIDs = make([]uuid.UUID, 0, len(s.PropertyIDs))
+		for _, v := range s.PropertyIDs {
+			pID, err := uuid.Parse(v)
+			if err != nil {
+				return out, domain.NewFieldError("property_id", fmt.Errorf("parse property id error: %s", err))
+			}
+			out.PropertyIDs = append(out.PropertyIDs, pID)
+		}
+	}
+	if s.Depth != "" {
+		depth, err := strconv.ParseUint(s.Depth, 10, 32)
+		if err != nil {
+			return out, domain.NewFieldError("depth", fmt.Errorf("parse depth error: %s", err))
+		}
+		if depth == 0 || depth > maxGraphDepth {
+			return out, fmt.Errorf("depth must be between 1 and %d", maxGraphDepth)
+		}
+		out.MaxDepth = uint(depth)
+	}
+	return out, nil
+}
+
+type GraphNodeResponseSchema struct {
+	RecordID  string  `json:"record_id"`
+	RefTypeID *string `json:"reference_type_id"`
+	Depth     uint    `json:"depth"`
+}
+
+type GraphEdgeResponseSchema struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	PropertyID string `json:"property_id"`
+	Cycle      bool   `json:"cycle"`
+}
+
+type GraphResponseSchema struct {
+	Nodes     []GraphNodeResponseSchema `json:"nodes"`
+	Edges     []GraphEdgeResponseSchema `json:"edges"`
+	Truncated bool                      `json:"truncated"`
+}
+
+func GraphToResponseSchema(g domain.Graph) GraphResponseSchema {
+	out := GraphResponseSchema{
+		Nodes:     make([]GraphNodeResponseSchema, 0, len(g.Nodes)),
+		Edges:     make([]GraphEdgeResponseSchema, 0, len(g.Edges)),
+		Truncated: g.Truncated,
+	}
+	for _, n := range g.Nodes {
+		var refTypeID *string
+		if !helper.IsZeroUUID(n.RefTypeID) {
+			rtID := n.RefTypeID.String()
+			refTypeID = &rtID
+		}
+		out.Nodes = append(out.Nodes, GraphNodeResponseSchema{
+			RecordID:  n.RecordID.String(),
+			RefTypeID: refTypeID,
+			Depth:     n.Depth,
+		})
+	}
+	for _, e := range g.Edges {
+		out.Edges = append(out.Edges, GraphEdgeResponseSchema{
+			From:       e.From.String(),
+			To:         e.To.String(),
+			PropertyID: e.PropertyID.String(),
+			Cycle:      e.Cycle,
+		})
+	}
+	return out
+}
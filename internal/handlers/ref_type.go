@@ -14,9 +14,17 @@ import (
 
 func AddRefType(ctx context.Context, man *api.RefTypeManager, req AddRefTypeRequestSchema) (TextResult, error) {
 	out := TextResult{Status: http.StatusCreated}
-	id, err := man.Add(ctx, req.AddRefTypeRequest())
+	r, err := req.AddRefTypeRequest()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	id, err := man.Add(ctx, r)
 	if err != nil {
 		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			out.Status = http.StatusConflict
+		}
 		return out, err
 	}
 	out.Payload = id.String()
@@ -27,24 +35,28 @@ func UpdateRefType(ctx context.Context, man *api.RefTypeManager, req UpdRefTypeR
 	out := Result{Status: http.StatusNoContent}
 	if req.Name == nil {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("name %w", domain.ErrExpected)
+		return out, domain.NewFieldError("name", fmt.Errorf("name %w", domain.ErrExpected))
 	}
 	if req.Description == nil {
 		out.Status = http.StatusBadRequest
-		return out, fmt.Errorf("description %w", domain.ErrExpected)
+		return out, domain.NewFieldError("description", fmt.Errorf("description %w", domain.ErrExpected))
 	}
 	r, err := req.UpdRefTypeRequest()
 	if err != nil {
 		out.Status = http.StatusBadRequest
 		return out, err
 	}
-	if _, err := man.Update(ctx, r); err != nil {
+	refType, err := man.Update(ctx, r)
+	if err != nil {
 		out.Status = http.StatusInternalServerError
 		if errors.Is(err, domain.ErrNotFound) {
 			out.Status = http.StatusNotFound
+		} else if errors.Is(err, domain.ErrSumMismatch) {
+			out.Status = http.StatusPreconditionFailed
 		}
 		return out, err
 	}
+	out.Sum = refType.Sum
 	return out, nil
 }
 
@@ -60,6 +72,8 @@ func PatchRefType(ctx context.Context, man *api.RefTypeManager, req UpdRefTypeRe
 		out.Status = http.StatusInternalServerError
 		if errors.Is(err, domain.ErrNotFound) {
 			out.Status = http.StatusNotFound
+		} else if errors.Is(err, domain.ErrSumMismatch) {
+			out.Status = http.StatusPreconditionFailed
 		}
 		return out, err
 	}
@@ -69,6 +83,7 @@ func PatchRefType(ctx context.Context, man *api.RefTypeManager, req UpdRefTypeRe
 		return out, err
 	}
 	out.Payload = b
+	out.Sum = refType.Sum
 	return out, nil
 }
 
@@ -78,7 +93,7 @@ func GetRefType(ctx context.Context, man *api.RefTypeManager, id string) (Result
 	if err != nil {
 		out.Status = http.StatusBadRequest
 		out.Payload = []byte(fmt.Sprintf("parse reference type id error: %s", err))
-		return out, err
+		return out, domain.NewFieldError("id", err)
 	}
 	refType, err := man.Get(ctx, rid)
 	if err != nil {
@@ -94,5 +109,6 @@ func GetRefType(ctx context.Context, man *api.RefTypeManager, id string) (Result
 		return out, err
 	}
 	out.Payload = b
+	out.Sum = refType.Sum
 	return out, nil
 }
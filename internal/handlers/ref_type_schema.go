@@ -8,31 +8,44 @@ import (
 )
 
 type AddRefTypeRequestSchema struct {
+	// ID is generated if it is not set by the client
+	ID          string `json:"id,omitempty"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
 }
 
-func (s AddRefTypeRequestSchema) AddRefTypeRequest() domain.AddRefTypeRequest {
-	return domain.AddRefTypeRequest{
+func (s AddRefTypeRequestSchema) AddRefTypeRequest() (domain.AddRefTypeRequest, error) {
+	out := domain.AddRefTypeRequest{
 		Name:        s.Name,
 		Description: s.Description,
 	}
+	if s.ID != "" {
+		id, err := uuid.Parse(s.ID)
+		if err != nil {
+			return out, domain.NewFieldError("id", fmt.Errorf("parse reference type id error: %s", err))
+		}
+		out.ID = id
+	}
+	return out, nil
 }
 
 type UpdRefTypeRequestSchema struct {
 	ID          string  `json:"id"`
 	Name        *string `json:"name,omitempty"`
 	Description *string `json:"description,omitempty"`
+	// ExpectedSum makes the update fail unless the current sum is equal to it
+	ExpectedSum string `json:"expected_sum,omitempty"`
 }
 
 func (s *UpdRefTypeRequestSchema) UpdRefTypeRequest() (domain.UpdRefTypeRequest, error) {
 	out := domain.UpdRefTypeRequest{
 		Name:        s.Name,
 		Description: s.Description,
+		ExpectedSum: s.ExpectedSum,
 	}
 	id, err := uuid.Parse(s.ID)
 	if err != nil {
-		return out, fmt.Errorf("parse reference type id error: %s", err)
+		return out, domain.NewFieldError("id", fmt.Errorf("parse reference type id error: %s", err))
 	}
 	out.ID = id
 	return out, nil
@@ -3,6 +3,8 @@ package handlers
 type Result struct {
 	Payload []byte
 	Status  int
+	// Sum of the returned object, it is sent as the entity tag
+	Sum string
 }
 
 type TextResult struct {
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/pkg/xlsx"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ExportTable writes records of the reference type to w as a table of the requested format.
+// Status of the result makes sense only if nothing is written yet.
+func ExportTable(ctx context.Context, man *api.TableManager, req TableRequestSchema, w io.Writer) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	r, err := req.TableRequest()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	format, err := req.format()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	var tw tableWriter
+	switch format {
+	case TableFormatXLSX:
+		tw = &xlsxTableWriter{w: w}
+	default:
+		tw = &csvTableWriter{w: csv.NewWriter(w)}
+	}
+	if err := man.Export(ctx, r, tw); err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	if err := tw.Close(); err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	return out, nil
+}
+
+type tableWriter interface {
+	domain.TableWriter
+	Close() error
+}
+
+type csvTableWriter struct {
+	w       *csv.Writer
+	columns []domain.Property
+}
+
+func (ctw *csvTableWriter) WriteHeader(columns []domain.Property) error {
+	ctw.columns = columns
+	return ctw.write(tableHeader(columns))
+}
+
+func (ctw *csvTableWriter) WriteRow(row domain.TableRow) error {
+	return ctw.write(tableCells(ctw.columns, row))
+}
+
+func (ctw *csvTableWriter) write(cells []any) error {
+	record := make([]string, 0, len(cells))
+	for _, cell := range cells {
+		record = append(record, tableCellString(cell))
+	}
+	return ctw.w.Write(record)
+}
+
+func (ctw *csvTableWriter) Close() error {
+	ctw.w.Flush()
+	return ctw.w.Error()
+}
+
+// xlsxTableWriter starts the workbook on the header, so nothing is written if the export fails before.
+type xlsxTableWriter struct {
+	w       io.Writer
+	xw      *xlsx.Writer
+	columns []domain.Property
+}
+
+func (xtw *xlsxTableWriter) WriteHeader(columns []domain.Property) error {
+	xw, err := xlsx.NewWriter(xtw.w, "Records")
+	if err != nil {
+		return err
+	}
+	xtw.xw = xw
+	xtw.columns = columns
+	return xw.WriteRow(tableHeader(columns))
+}
+
+func (xtw *xlsxTableWriter) WriteRow(row domain.TableRow) error {
+	return xtw.xw.WriteRow(tableCells(xtw.columns, row))
+}
+
+func (xtw *xlsxTableWriter) Close() error {
+	if xtw.xw == nil {
+		return nil
+	}
+	return xtw.xw.Close()
+}
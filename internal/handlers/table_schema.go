@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"datatom/internal/domain"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TableFormatCSV  = "csv"
+	TableFormatXLSX = "xlsx"
+)
+
+// Columns of the table preceding the properties of the reference type.
+var tableRecordColumns = []any{"ID", "Name", "Description", "Deletion mark"}
+
+type TableRequestSchema struct {
+	RefTypeID    string
+	Format       string
+	DeletionMark string
+	Name         string
+}
+
+func (s TableRequestSchema) TableRequest() (domain.TableRequest, error) {
+	var out domain.TableRequest
+	id, err := uuid.Parse(s.RefTypeID)
+	if err != nil {
+		return out, domain.NewFieldError("ref_type_id", fmt.Errorf("parse reference type id error: %s", err))
+	}
+	out.RefTypeID = id
+	if s.DeletionMark != "" {
+		deletionMark, err := strconv.ParseBool(s.DeletionMark)
+		if err != nil {
+			return out, domain.NewFieldError("deletion_mark", fmt.Errorf("parse deletion_mark error: %s", err))
+		}
+		out.Filter.DeletionMark = &deletionMark
+	}
+	out.Filter.Name = s.Name
+	return out, nil
+}
+
+func (s TableRequestSchema) format() (string, error) {
+	switch s.Format {
+	case "", TableFormatCSV:
+		return TableFormatCSV, nil
+	case TableFormatXLSX:
+		return TableFormatXLSX, nil
+	default:
+		return "", fmt.Errorf(`%w "%s" of table format`, domain.ErrUnknownType, s.Format)
+	}
+}
+
+// ContentType of the table in the requested format, unknown formats give an empty string.
+func (s TableRequestSchema) ContentType() string {
+	format, _ := s.format()
+	switch format {
+	case TableFormatCSV:
+		return "text/csv; charset=UTF-8"
+	case TableFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return ""
+	}
+}
+
+// FileName of the table in the requested format.
+func (s TableRequestSchema) FileName() string {
+	format, _ := s.format()
+	return fmt.Sprintf("%s.%s", s.RefTypeID, format)
+}
+
+func tableHeader(columns []domain.Property) []any {
+	out := make([]any, 0, len(tableRecordColumns)+len(columns))
+	out = append(out, tableRecordColumns...)
+	for _, p := range columns {
+		out = append(out, p.Name)
+	}
+	return out
+}
+
+// tableCells places values of the row under the columns of their properties.
+// Ref values are shown by names of the referenced records or by their IDs if the records are missing.
+func tableCells(columns []domain.Property, row domain.TableRow) []any {
+	out := make([]any, len(tableRecordColumns)+len(columns))
+	out[0] = row.ID.String()
+	out[1] = row.Name
+	out[2] = row.Description
+	out[3] = row.DeletionMark
+	index := make(map[uuid.UUID]int, len(columns))
+	for i, p := range columns {
+		index[p.ID] = len(tableRecordColumns) + i
+	}
+	for _, v := range row.Values {
+		i, ok := index[v.PropertyID]
+		if !ok {
+			continue
+		}
+		switch x := v.Value.Value.(type) {
+		case domain.EncryptedValue:
+			// Envelopes are not decrypted without the keyring so cells are left empty
+		case domain.MaskedValue:
+			out[i] = x.Value
+		case time.Time:
+			out[i] = x.Format(time.RFC3339)
+		case uuid.UUID:
+			out[i] = x.String()
+			if v.Type == domain.TypeReference && v.RefName != "" {
+				out[i] = v.RefName
+			}
+		default:
+			out[i] = x
+		}
+	}
+	return out
+}
+
+func tableCellString(cell any) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -3,6 +3,9 @@ package handlers
 import (
 	"context"
 	"datatom/internal/api"
+	"datatom/internal/domain"
+	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -13,12 +16,86 @@ func SetValue(ctx context.Context, man *api.ValueManager, req SetValueRequestSch
 		out.Status = http.StatusBadRequest
 		return out, err
 	}
-	if _, err := man.Set(ctx, r); err != nil {
+	value, err := man.Set(ctx, r)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if isBadRequestError(err) {
+			out.Status = http.StatusBadRequest
+		} else if errors.Is(err, domain.ErrStateTransitionNotAllowedPG) {
+			out.Status = http.StatusConflict
+		} else if errors.Is(err, domain.ErrSumMismatch) {
+			out.Status = http.StatusPreconditionFailed
+		} else if errors.Is(err, domain.ErrRecordNotFound) {
+			out.Status = http.StatusNotFound
+		} else if errors.Is(err, domain.ErrForbidden) {
+			out.Status = http.StatusForbidden
+		}
+		return out, err
+	}
+	out.Sum = value.Sum
+	return out, nil
+}
+
+func GetStateTransitions(ctx context.Context, man *api.ValueManager, req GetValueRequestSchema) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	r, err := req.GetValueRequest()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	transitions, err := man.Transitions(ctx, r)
+	if err != nil {
 		out.Status = http.StatusInternalServerError
 		if isBadRequestError(err) {
 			out.Status = http.StatusBadRequest
+		} else if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	b, err := json.Marshal(StateTransitionsToResponseSchema(*transitions))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
+
+func GetValue(ctx context.Context, vm *api.ValueManager, rm *api.RecordManager, req GetValueRequestSchema, expand ExpandRequestSchema) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	r, err := req.GetValueRequest()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	e, err := expand.ExpandRequest()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	value, err := vm.Get(ctx, r)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
 		}
 		return out, err
 	}
+	expanded, err := rm.ExpandValue(ctx, *value, e)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	b, err := json.Marshal(ExpandedValueToResponseSchema(*expanded, e.Depth))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	if expand.IsEmpty() {
+		// The sum does not cover referenced records of the expanded value
+		out.Sum = value.Sum
+	}
 	return out, nil
 }
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"datatom/internal/domain"
+	"datatom/pkg/helper"
 	"fmt"
 	"github.com/google/uuid"
 )
@@ -12,19 +13,22 @@ type SetValueRequestSchema struct {
 	Type       string `json:"type"`
 	RefTypeID  string `json:"reference_type_id"`
 	Value      any    `json:"value"`
+	// ExpectedSum makes the update fail unless the current sum is equal to it
+	ExpectedSum string `json:"expected_sum,omitempty"`
 }
 
 func (s SetValueRequestSchema) SetValueRequest() (domain.SetValueRequest, error) {
 	out := domain.SetValueRequest{
-		Value: s.Value,
+		Value:       s.Value,
+		ExpectedSum: s.ExpectedSum,
 	}
 	recordID, err := uuid.Parse(s.RecordID)
 	if err != nil {
-		return out, fmt.Errorf("parse record id error: %s", err)
+		return out, domain.NewFieldError("record_id", fmt.Errorf("parse record id error: %s", err))
 	}
 	propertyID, err := uuid.Parse(s.PropertyID)
 	if err != nil {
-		return out, fmt.Errorf("parse property id error: %s", err)
+		return out, domain.NewFieldError("property_id", fmt.Errorf("parse property id error: %s", err))
 	}
 	tp := domain.TypeFromCode(s.Type)
 	if tp == domain.UndefinedType {
@@ -33,7 +37,7 @@ func (s SetValueRequestSchema) SetValueRequest() (domain.SetValueRequest, error)
 	if s.RefTypeID != "" {
 		id, err := uuid.Parse(s.RefTypeID)
 		if err != nil {
-			return out, fmt.Errorf("parse reference type id error: %s", err)
+			return out, domain.NewFieldError("reference_type_id", fmt.Errorf("parse reference type id error: %s", err))
 		}
 		out.RefTypeID = id
 	}
@@ -42,3 +46,74 @@ func (s SetValueRequestSchema) SetValueRequest() (domain.SetValueRequest, error)
 	out.Type = tp
 	return out, nil
 }
+
+type GetValueRequestSchema struct {
+	RecordID   string `json:"record_id"`
+	PropertyID string `json:"property_id"`
+}
+
+func (s GetValueRequestSchema) GetValueRequest() (domain.GetValueRequest, error) {
+	var out domain.GetValueRequest
+	recordID, err := uuid.Parse(s.RecordID)
+	if err != nil {
+		return out, domain.NewFieldError("record_id", fmt.Errorf("parse record id error: %s", err))
+	}
+	propertyID, err := uuid.Parse(s.PropertyID)
+	if err != nil {
+		return out, domain.NewFieldError("property_id", fmt.Errorf("parse property id error: %s", err))
+	}
+	out.RecordID = recordID
+	out.PropertyID = propertyID
+	return out, nil
+}
+
+type StateTransitionsResponseSchema struct {
+	RecordID    string   `json:"record_id"`
+	PropertyID  string   `json:"property_id"`
+	State       *string  `json:"state"`
+	Transitions []string `json:"transitions"`
+}
+
+func StateTransitionsToResponseSchema(st domain.StateTransitions) StateTransitionsResponseSchema {
+	transitions := st.Transitions
+	if transitions == nil {
+		transitions = []string{}
+	}
+	return StateTransitionsResponseSchema{
+		RecordID:    st.RecordID.String(),
+		PropertyID:  st.PropertyID.String(),
+		State:       st.State,
+		Transitions: transitions,
+	}
+}
+
+// ValueResponseSchema is Masked if the value is hidden, partially revealed or hashed by the mask of the property.
+type ValueResponseSchema struct {
+	RecordID   string                `json:"record_id"`
+	PropertyID string                `json:"property_id"`
+	Type       string                `json:"type"`
+	RefTypeID  *string               `json:"reference_type_id"`
+	Value      any                   `json:"value"`
+	Masked     bool                  `json:"masked,omitempty"`
+	Ref        *RecordResponseSchema `json:"ref,omitempty"`
+}
+
+func ValueToResponseSchema(v domain.Value) ValueResponseSchema {
+	var refTypeID *string
+	if !helper.IsZeroUUID(v.RefTypeID) {
+		rtID := v.RefTypeID.String()
+		refTypeID = &rtID
+	}
+	out := ValueResponseSchema{
+		RecordID:   v.RecordID.String(),
+		PropertyID: v.PropertyID.String(),
+		Type:       v.Type.Code(),
+		RefTypeID:  refTypeID,
+		Value:      v.Value,
+	}
+	if mv, ok := v.Value.(domain.MaskedValue); ok {
+		out.Value = mv.Value
+		out.Masked = true
+	}
+	return out
+}
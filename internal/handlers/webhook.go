@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// Numbers of the last deliveries returned if the limit is not requested and at most
+const (
+	defaultWebhookDeliveriesLimit = 100
+	maxWebhookDeliveriesLimit     = 1000
+)
+
+func AddWebhook(ctx context.Context, man *api.WebhookManager, req AddWebhookRequestSchema) (TextResult, error) {
+	out := TextResult{Status: http.StatusCreated}
+	wh, err := req.Webhook()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	id, err := man.Add(ctx, wh)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = id.String()
+	return out, nil
+}
+
+func UpdateWebhook(ctx context.Context, man *api.WebhookManager, req UpdWebhookRequestSchema) (Result, error) {
+	out := Result{Status: http.StatusNoContent}
+	wh, err := req.Webhook()
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, err
+	}
+	if err := man.Update(ctx, wh); err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	return out, nil
+}
+
+func DeleteWebhook(ctx context.Context, man *api.WebhookManager, id string) (Result, error) {
+	out := Result{Status: http.StatusNoContent}
+	wid, err := uuid.Parse(id)
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, domain.NewFieldError("id", fmt.Errorf("parse webhook id error: %s", err))
+	}
+	if err := man.Delete(ctx, wid); err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	return out, nil
+}
+
+func GetWebhooks(ctx context.Context, man *api.WebhookManager) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	webhooks, err := man.GetAll(ctx)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	resp := make([]WebhookResponseSchema, 0, len(webhooks))
+	for _, wh := range webhooks {
+		resp = append(resp, WebhookToResponseSchema(wh))
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
+
+func GetWebhook(ctx context.Context, man *api.WebhookManager, id string) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	wid, err := uuid.Parse(id)
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, domain.NewFieldError("id", fmt.Errorf("parse webhook id error: %s", err))
+	}
+	wh, err := man.Get(ctx, wid)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	b, err := json.Marshal(WebhookToResponseSchema(*wh))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
+
+// GetWebhookDeliveries returns the last deliveries of the webhook, the latest first.
+func GetWebhookDeliveries(ctx context.Context, man *api.WebhookManager, id string, limit string) (Result, error) {
+	out := Result{Status: http.StatusOK}
+	wid, err := uuid.Parse(id)
+	if err != nil {
+		out.Status = http.StatusBadRequest
+		return out, domain.NewFieldError("id", fmt.Errorf("parse webhook id error: %s", err))
+	}
+	n := uint64(defaultWebhookDeliveriesLimit)
+	if limit != "" {
+		if n, err = strconv.ParseUint(limit, 10, 32); err != nil || n == 0 {
+			out.Status = http.StatusBadRequest
+			return out, domain.NewFieldError("limit", fmt.Errorf("parse limit error: %s is not a positive number", limit))
+		}
+	}
+	if n > maxWebhookDeliveriesLimit {
+		n = maxWebhookDeliveriesLimit
+	}
+	deliveries, err := man.GetDeliveries(ctx, wid, uint(n))
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		if errors.Is(err, domain.ErrNotFound) {
+			out.Status = http.StatusNotFound
+		}
+		return out, err
+	}
+	resp := make([]WebhookDeliveryResponseSchema, 0, len(deliveries))
+	for _, d := range deliveries {
+		resp = append(resp, WebhookDeliveryToResponseSchema(d))
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		out.Status = http.StatusInternalServerError
+		return out, err
+	}
+	out.Payload = b
+	return out, nil
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"datatom/internal/domain"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// minWebhookSecretLength keeps signatures of deliveries from being guessed.
+const minWebhookSecretLength = 16
+
+type AddWebhookRequestSchema struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret"`
+	ChangeTypes []string `json:"change_types"`
+	RefTypeIDs  []string `json:"reference_type_ids"`
+	PropertyIDs []string `json:"property_ids"`
+}
+
+func (s AddWebhookRequestSchema) Webhook() (domain.Webhook, error) {
+	out := domain.Webhook{Enabled: true}
+	if err := validateWebhookURL(s.URL); err != nil {
+		return out, err
+	}
+	out.URL = s.URL
+	if err := validateWebhookSecret(s.Secret); err != nil {
+		return out, err
+	}
+	out.Secret = s.Secret
+	filter, err := parseWebhookFilter(s.ChangeTypes, s.RefTypeIDs, s.PropertyIDs)
+	if err != nil {
+		return out, err
+	}
+	out.Filter = filter
+	return out, nil
+}
+
+// UpdWebhookRequestSchema replaces the webhook, the secret is kept if it is empty.
+type UpdWebhookRequestSchema struct {
+	ID          string   `json:"-"`
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret"`
+	ChangeTypes []string `json:"change_types"`
+	RefTypeIDs  []string `json:"reference_type_ids"`
+	PropertyIDs []string `json:"property_ids"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+func (s UpdWebhookRequestSchema) Webhook() (domain.Webhook, error) {
+	var out domain.Webhook
+	id, err := uuid.Parse(s.ID)
+	if err != nil {
+		return out, domain.NewFieldError("id", fmt.Errorf("parse webhook id error: %s", err))
+	}
+	out.ID = id
+	if err := validateWebhookURL(s.URL); err != nil {
+		return out, err
+	}
+	out.URL = s.URL
+	if s.Secret != "" {
+		if err := validateWebhookSecret(s.Secret); err != nil {
+			return out, err
+		}
+		out.Secret = s.Secret
+	}
+	if s.Enabled == nil {
+		return out, domain.NewFieldError("enabled", fmt.Errorf("enabled %w", domain.ErrExpected))
+	}
+	out.Enabled = *s.Enabled
+	filter, err := parseWebhookFilter(s.ChangeTypes, s.RefTypeIDs, s.PropertyIDs)
+	if err != nil {
+		return out, err
+	}
+	out.Filter = filter
+	return out, nil
+}
+
+func validateWebhookURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil {
+		return domain.NewFieldError("url", fmt.Errorf("parse url error: %s", err))
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return domain.NewFieldError("url", fmt.Errorf("url %q is not an absolute HTTP one", v))
+	}
+	return nil
+}
+
+func validateWebhookSecret(v string) error {
+	if len(v) < minWebhookSecretLength {
+		return domain.NewFieldError("secret", fmt.Errorf("secret must be at least %d characters long", minWebhookSecretLength))
+	}
+	return nil
+}
+
+// parseWebhookFilter keeps lists nil if they are empty, so they mean no filter.
+func parseWebhookFilter(changeTypes, refTypeIDs, propertyIDs []string) (domain.ChangeFilter, error) {
+	var out domain.ChangeFilter
+	for _, code := range changeTypes {
+		t := domain.ChangedDataTypeFromCode(code)
+		if t == domain.UnknownChangedDataType {
+			return out, domain.NewFieldError("change_types", fmt.Errorf("%w of change %q", domain.ErrUnknownType, code))
+		}
+		out.ChangeTypes = append(out.ChangeTypes, t)
+	}
+	for _, v := range refTypeIDs {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return out, domain.NewFieldError("reference_type_ids", fmt.Errorf("parse reference type id error: %s", err))
+		}
+		out.RefTypeIDs = append(out.RefTypeIDs, id)
+	}
+	for _, v := range propertyIDs {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return out, domain.NewFieldError("property_ids", fmt.Errorf("parse property id error: %s", err))
+		}
+		out.PropertyIDs = append(out.PropertyIDs, id)
+	}
+	return out, nil
+}
+
+// WebhookResponseSchema holds the webhook without its secret.
+type WebhookResponseSchema struct {
+	ID             string     `json:"id"`
+	URL            string     `json:"url"`
+	ChangeTypes    []string   `json:"change_types"`
+	RefTypeIDs     []string   `json:"reference_type_ids"`
+	PropertyIDs    []string   `json:"property_ids"`
+	Enabled        bool       `json:"enabled"`
+	LastChangeID   int64      `json:"last_change_id"`
+	Failures       int        `json:"failures"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	DisabledReason *string    `json:"disabled_reason"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func WebhookToResponseSchema(wh domain.Webhook) WebhookResponseSchema {
+	out := WebhookResponseSchema{
+		ID:           wh.ID.String(),
+		URL:          wh.URL,
+		ChangeTypes:  make([]string, 0, len(wh.Filter.ChangeTypes)),
+		RefTypeIDs:   make([]string, 0, len(wh.Filter.RefTypeIDs)),
+		PropertyIDs:  make([]string, 0, len(wh.Filter.PropertyIDs)),
+		Enabled:      wh.Enabled,
+		LastChangeID: wh.LastChangeID,
+		Failures:     wh.Failures,
+		CreatedAt:    wh.CreatedAt,
+		UpdatedAt:    wh.UpdatedAt,
+	}
+	for _, t := range wh.Filter.ChangeTypes {
+		out.ChangeTypes = append(out.ChangeTypes, t.String())
+	}
+	for _, id := range wh.Filter.RefTypeIDs {
+		out.RefTypeIDs = append(out.RefTypeIDs, id.String())
+	}
+	for _, id := range wh.Filter.PropertyIDs {
+		out.PropertyIDs = append(out.PropertyIDs, id.String())
+	}
+	if !wh.NextAttemptAt.IsZero() {
+		out.NextAttemptAt = &wh.NextAttemptAt
+	}
+	if wh.DisabledReason != "" {
+		out.DisabledReason = &wh.DisabledReason
+	}
+	return out
+}
+
+type WebhookDeliveryResponseSchema struct {
+	ID          int64     `json:"id"`
+	ChangeID    int64     `json:"change_id"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  *int      `json:"status_code"`
+	Error       *string   `json:"error"`
+	DurationMS  int64     `json:"duration_ms"`
+	DeliveredAt time.Time `json:"delivered_at"`
+}
+
+func WebhookDeliveryToResponseSchema(d domain.WebhookDelivery) WebhookDeliveryResponseSchema {
+	out := WebhookDeliveryResponseSchema{
+		ID:          d.ID,
+		ChangeID:    d.ChangeID,
+		Attempt:     d.Attempt,
+		DurationMS:  d.Duration.Milliseconds(),
+		DeliveredAt: d.DeliveredAt,
+	}
+	if d.StatusCode != 0 {
+		out.StatusCode = &d.StatusCode
+	}
+	if d.Error != "" {
+		out.Error = &d.Error
+	}
+	return out
+}
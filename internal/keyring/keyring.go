@@ -0,0 +1,196 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"datatom/internal/domain"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+// keySize of AES-256 keys of the keyring, data keys and the MAC key.
+const keySize = 32
+
+// Config is the TOML keyring file, keys are base64 of 32 random bytes, e.g.
+//
+//	primary = "2026-10"
+//	mac_key = "<base64>"
+//
+//	[[keys]]
+//	id = "2026-10"
+//	key = "<base64>"
+//
+// New values are encrypted by the primary key, the others decrypt values encrypted before the rotation.
+// The MAC key is not rotated cause sums of values are of MACs of them.
+type Config struct {
+	Primary string      `toml:"primary"`
+	MACKey  string      `toml:"mac_key"`
+	Keys    []KeyConfig `toml:"keys"`
+}
+
+type KeyConfig struct {
+	ID  string `toml:"id"`
+	Key string `toml:"key"`
+}
+
+// Keyring envelope-encrypts values: each value is sealed by its own random data key
+// which is sealed by the primary key of the keyring.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+	mac     []byte
+}
+
+func LoadConfig(path string) (*Config, error) {
+	var out Config
+	if _, err := toml.DecodeFile(path, &out); err != nil {
+		return nil, fmt.Errorf("keyring file parse error: %w", err)
+	}
+	return &out, nil
+}
+
+func New(c Config) (*Keyring, error) {
+	out := &Keyring{primary: c.Primary, keys: make(map[string]cipher.AEAD, len(c.Keys))}
+	var err error
+	if out.mac, err = decodeKey(c.MACKey); err != nil {
+		return nil, fmt.Errorf("MAC key error: %w", err)
+	}
+	for _, k := range c.Keys {
+		if k.ID == "" {
+			return nil, fmt.Errorf("ID of the keyring key expected")
+		}
+		if _, ok := out.keys[k.ID]; ok {
+			return nil, fmt.Errorf("keyring key %s is duplicated", k.ID)
+		}
+		key, err := decodeKey(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("keyring key %s error: %w", k.ID, err)
+		}
+		if out.keys[k.ID], err = newAEAD(key); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := out.keys[out.primary]; !ok {
+		return nil, fmt.Errorf("primary key %s is not in the keyring", out.primary)
+	}
+	return out, nil
+}
+
+func (k *Keyring) Encrypt(v domain.Value) (domain.EncryptedValue, error) {
+	var out domain.EncryptedValue
+	plain, err := domain.ValueAsJSON(v.Value, v.Type)
+	if err != nil {
+		return out, err
+	}
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return out, fmt.Errorf("data key error: %w", err)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return out, err
+	}
+	ad := associatedData(v)
+	if out.Ciphertext, err = seal(data, plain, ad); err != nil {
+		return out, err
+	}
+	if out.DataKey, err = seal(k.keys[k.primary], dataKey, []byte(k.primary)); err != nil {
+		return out, err
+	}
+	out.KeyID = k.primary
+	out.MAC = k.sum(ad, plain)
+	return out, nil
+}
+
+func (k *Keyring) Decrypt(v domain.Value) (any, error) {
+	ev, ok := v.Value.(domain.EncryptedValue)
+	if !ok {
+		return nil, fmt.Errorf("%w %T of encrypted value", domain.ErrUnexpectedType, v.Value)
+	}
+	kek, ok := k.keys[ev.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("keyring key %s of the value is unknown", ev.KeyID)
+	}
+	dataKey, err := open(kek, ev.DataKey, []byte(ev.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("data key error: %w", err)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := open(data, ev.Ciphertext, associatedData(v))
+	if err != nil {
+		return nil, fmt.Errorf("value of record %s property %s error: %w", v.RecordID, v.PropertyID, err)
+	}
+	var schema domain.ValueJSONSchema
+	if err := json.Unmarshal(plain, &schema); err != nil {
+		return nil, fmt.Errorf("decrypted value unmarshal error: %s", err)
+	}
+	return domain.ValidatedValue(schema.V, v.Type)
+}
+
+func (k *Keyring) sum(ad, plain []byte) string {
+	h := hmac.New(sha256.New, k.mac)
+	h.Write(ad)
+	h.Write(plain)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// MaskKey is the key of hashed masks derived from the MAC key, so hashes of masked values are not sums of values.
+func (k *Keyring) MaskKey() []byte {
+	h := hmac.New(sha256.New, k.mac)
+	h.Write([]byte("mask"))
+	return h.Sum(nil)
+}
+
+// associatedData binds the envelope to the value of the record and the property.
+func associatedData(v domain.Value) []byte {
+	out := make([]byte, 0, 32+len(v.Type.Code()))
+	out = append(out, v.RecordID[:]...)
+	out = append(out, v.PropertyID[:]...)
+	return append(out, v.Type.Code()...)
+}
+
+func decodeKey(s string) ([]byte, error) {
+	out, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("base64 decode error: %s", err)
+	}
+	if len(out) != keySize {
+		return nil, fmt.Errorf("key must be of %d bytes", keySize)
+	}
+	return out, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cipher error: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal prepends the random nonce to the sealed data.
+func seal(aead cipher.AEAD, plain, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("nonce error: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plain, ad), nil
+}
+
+func open(aead cipher.AEAD, sealed, ad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed data is too short")
+	}
+	nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, data, ad)
+}
@@ -0,0 +1,282 @@
+package migrations
+
+import (
+	"database/sql"
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00044, down00044)
+}
+
+func up00044(tx *sql.Tx) error {
+	query := `-- Sequence properties
+DO $$ BEGIN
+	CREATE TYPE property_kinds AS ENUM ('regular', 'sequence');
+
+	CREATE TYPE sequence_reset_periods AS ENUM ('never', 'yearly', 'monthly');
+
+	ALTER TABLE IF EXISTS properties
+		ADD COLUMN kind property_kinds NOT NULL DEFAULT 'regular';
+
+	CREATE TABLE property_sequences (
+		property_id uuid PRIMARY KEY,
+		prefix varchar(64) NOT NULL DEFAULT '',
+		template varchar(128) NOT NULL DEFAULT '{prefix}{n}',
+		reset_period sequence_reset_periods NOT NULL DEFAULT 'never',
+		CONSTRAINT fk_property
+			FOREIGN KEY (property_id)
+				REFERENCES properties(id)
+	);
+
+	CREATE TABLE sequence_counters (
+		property_id uuid NOT NULL,
+		"period" varchar(7) NOT NULL,
+		last_number bigint NOT NULL DEFAULT 0,
+		PRIMARY KEY (property_id, "period"),
+		CONSTRAINT fk_property_sequence
+			FOREIGN KEY (property_id)
+				REFERENCES property_sequences(property_id)
+	);
+
+	CREATE FUNCTION properties_sequence_check_bw() RETURNS TRIGGER AS $properties_sequence_check_bw$
+		BEGIN
+			IF NEW.kind <> 'sequence'::property_kinds THEN
+				RETURN NEW;
+			END IF;
+
+			IF NEW."types" <> ARRAY['text'::types] THEN
+				RAISE EXCEPTION 'sequence types mismatch' USING DETAIL = 'KEYS(properties.kind, properties."types") VALUES(' || NEW.kind || ', {' || array_to_string(NEW."types", ', ') || '})';
+			END IF;
+
+			IF NEW.owner_reference_type_id IS NULL THEN
+				RAISE EXCEPTION 'sequence owner reference type ID missing' USING DETAIL = 'KEYS(properties.kind, properties.owner_reference_type_id) VALUES(' || NEW.kind || ', NULL)';
+			END IF;
+
+			RETURN NEW;
+		END;
+	$properties_sequence_check_bw$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER t_properties_sequence_check_bw BEFORE INSERT OR UPDATE ON properties
+		FOR EACH ROW EXECUTE PROCEDURE properties_sequence_check_bw();
+
+	CREATE FUNCTION sequence_period(sequence_reset_periods, timestamp) RETURNS varchar(7) AS $sequence_period$
+		BEGIN
+			CASE $1
+				WHEN 'yearly' THEN
+					RETURN to_char($2, 'YYYY');
+				WHEN 'monthly' THEN
+					RETURN to_char($2, 'YYYY-MM');
+				ELSE
+					RETURN '';
+			END CASE;
+		END;
+	$sequence_period$ LANGUAGE plpgsql;
+
+	-- Takes the next number of the sequence property atomically and formats it by the template
+	CREATE FUNCTION next_sequence_number(uuid, timestamp) RETURNS text AS $next_sequence_number$
+		DECLARE
+			seq property_sequences%ROWTYPE;
+			num bigint;
+			width int;
+			res text;
+		BEGIN
+			SELECT * INTO STRICT seq FROM property_sequences WHERE property_id = $1;
+
+			INSERT INTO sequence_counters (property_id, "period", last_number)
+			VALUES ($1, sequence_period(seq.reset_period, $2), 1)
+			ON CONFLICT (property_id, "period") DO UPDATE SET
+				last_number = sequence_counters.last_number + 1
+			RETURNING last_number INTO num;
+
+			res := replace(seq.template, '{yyyy}', to_char($2, 'YYYY'));
+			res := replace(res, '{yy}', to_char($2, 'YY'));
+			res := replace(res, '{mm}', to_char($2, 'MM'));
+			width := COALESCE((regexp_match(res, '\{n:(\d+)\}'))[1]::int, 0);
+			res := regexp_replace(res, '\{n(:\d+)?\}', lpad(num::text, GREATEST(width, length(num::text)), '0'), 'g');
+
+			RETURN replace(res, '{prefix}', seq.prefix);
+		END;
+	$next_sequence_number$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION record_sequences_assign() RETURNS TRIGGER AS $record_sequences_assign$
+		BEGIN
+			INSERT INTO "values" (owner_id, property_id, "type", value)
+			SELECT NEW.id, p.id, 'text'::types, jsonb_build_object('v', next_sequence_number(p.id, NEW.change_at))
+			FROM properties p
+			WHERE p.kind = 'sequence'::property_kinds AND p.owner_reference_type_id = NEW.reference_type_id;
+
+			RETURN NEW;
+		END;
+	$record_sequences_assign$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER t_record_sequences_assign AFTER INSERT ON records
+		FOR EACH ROW EXECUTE PROCEDURE record_sequences_assign();
+
+	DROP FUNCTION new_property(text, text, "types"[], uuid[], uuid);
+	CREATE FUNCTION new_property(
+		text,
+		text,
+		"types"[],
+		uuid[] DEFAULT NULL,
+		uuid DEFAULT NULL,
+		property_kinds DEFAULT 'regular',
+		text DEFAULT NULL,
+		text DEFAULT NULL,
+		sequence_reset_periods DEFAULT NULL
+	) RETURNS uuid AS $new_property$
+		DECLARE
+			res uuid;
+		BEGIN
+			res := uuid_generate_v4();
+
+			INSERT INTO properties (id, "name", description, "types", reference_type_ids, owner_reference_type_id, kind)
+			VALUES (res, $1, $2, $3, $4, $5, $6);
+
+			IF $6 = 'sequence'::property_kinds THEN
+				IF COALESCE($8, '{prefix}{n}') !~ '\{n(:\d+)?\}' THEN
+					RAISE EXCEPTION 'sequence template without number placeholder' USING DETAIL = 'KEYS(property_sequences.template) VALUES(' || $8 || ')';
+				END IF;
+
+				INSERT INTO property_sequences (property_id, prefix, template, reset_period)
+				VALUES (res, COALESCE($7, ''), COALESCE($8, '{prefix}{n}'), COALESCE($9, 'never'));
+			END IF;
+
+			RETURN res;
+		END;
+	$new_property$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION property_sequence_json(uuid) RETURNS json AS $property_sequence_json$
+		BEGIN
+			RETURN (
+				SELECT json_build_object(
+					'prefix', prefix,
+					'template', template,
+					'reset_period', reset_period
+				)
+				FROM property_sequences
+				WHERE property_id = $1
+			);
+		END;
+	$property_sequence_json$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION get_property(uuid) RETURNS SETOF json AS $get_property$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', id,
+						'name', "name",
+						'description', description,
+						'types', "types",
+						'reference_type_ids', reference_type_ids,
+						'owner_reference_type_id', owner_reference_type_id,
+						'kind', kind,
+						'sequence', property_sequence_json(id),
+						'sum', "sum",
+						'change_at', change_at::timestamptz
+					)
+				FROM properties
+				WHERE id = $1;
+		END;
+	$get_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION update_property(uuid, text, text) RETURNS SETOF json AS $update_property$
+		BEGIN
+			RETURN QUERY UPDATE properties SET
+				"name" = COALESCE($2, "name", $2),
+				description = COALESCE($3, description, $3)
+			WHERE id = $1
+			RETURNING json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'sum', "sum",
+				'change_at', change_at::timestamptz
+			);
+		END;
+	$update_property$ LANGUAGE plpgsql;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00044(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	CREATE OR REPLACE FUNCTION update_property(uuid, text, text) RETURNS SETOF json AS $update_property$
+		BEGIN
+			RETURN QUERY UPDATE properties SET
+				"name" = COALESCE($2, "name", $2),
+				description = COALESCE($3, description, $3)
+			WHERE id = $1
+			RETURNING json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'sum', "sum",
+				'change_at', change_at::timestamptz
+			);
+		END;
+	$update_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION get_property(uuid) RETURNS SETOF json AS $get_property$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', id,
+						'name', "name",
+						'description', description,
+						'types', "types",
+						'reference_type_ids', reference_type_ids,
+						'owner_reference_type_id', owner_reference_type_id,
+						'sum', "sum",
+						'change_at', change_at::timestamptz
+					)
+				FROM properties
+				WHERE id = $1;
+		END;
+	$get_property$ LANGUAGE plpgsql;
+
+	DROP FUNCTION property_sequence_json(uuid);
+
+	DROP FUNCTION new_property(text, text, "types"[], uuid[], uuid, property_kinds, text, text, sequence_reset_periods);
+	CREATE FUNCTION new_property(text, text, "types"[], uuid[] DEFAULT NULL, uuid DEFAULT NULL) RETURNS uuid AS $new_property$
+		DECLARE
+			res uuid;
+		BEGIN
+			res := uuid_generate_v4();
+
+			INSERT INTO properties (id, "name", description, "types", reference_type_ids, owner_reference_type_id)
+			VALUES (res, $1, $2, $3, $4, $5);
+
+			RETURN res;
+		END;
+	$new_property$ LANGUAGE plpgsql;
+
+	DROP TRIGGER t_record_sequences_assign ON records;
+	DROP FUNCTION record_sequences_assign();
+	DROP FUNCTION next_sequence_number(uuid, timestamp);
+	DROP FUNCTION sequence_period(sequence_reset_periods, timestamp);
+
+	DROP TRIGGER t_properties_sequence_check_bw ON properties;
+	DROP FUNCTION properties_sequence_check_bw();
+
+	DROP TABLE sequence_counters;
+	DROP TABLE property_sequences;
+
+	ALTER TABLE IF EXISTS properties DROP COLUMN kind;
+
+	DROP TYPE sequence_reset_periods;
+	DROP TYPE property_kinds;
+END $$;`
+	return execQuery(query, tx)
+}
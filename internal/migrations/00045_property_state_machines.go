@@ -0,0 +1,337 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00045, down00045)
+}
+
+func up00045(tx *sql.Tx) error {
+	query := `-- State machine properties
+DO $$ BEGIN
+	ALTER TYPE property_kinds ADD VALUE 'state_machine';
+
+	CREATE TABLE property_state_machines (
+		property_id uuid PRIMARY KEY,
+		states text[] NOT NULL,
+		initial text NOT NULL,
+		CONSTRAINT fk_property
+			FOREIGN KEY (property_id)
+				REFERENCES properties(id)
+	);
+
+	CREATE TABLE state_transitions (
+		property_id uuid NOT NULL,
+		from_state text NOT NULL,
+		to_state text NOT NULL,
+		PRIMARY KEY (property_id, from_state, to_state),
+		CONSTRAINT fk_property_state_machine
+			FOREIGN KEY (property_id)
+				REFERENCES property_state_machines(property_id)
+	);
+
+	CREATE FUNCTION properties_state_machine_check_bw() RETURNS TRIGGER AS $properties_state_machine_check_bw$
+		BEGIN
+			IF NEW.kind::text <> 'state_machine' THEN
+				RETURN NEW;
+			END IF;
+
+			IF NEW."types" <> ARRAY['text'::types] THEN
+				RAISE EXCEPTION 'state machine types mismatch' USING DETAIL = 'KEYS(properties.kind, properties."types") VALUES(' || NEW.kind || ', {' || array_to_string(NEW."types", ', ') || '})';
+			END IF;
+
+			RETURN NEW;
+		END;
+	$properties_state_machine_check_bw$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER t_properties_state_machine_check_bw BEFORE INSERT OR UPDATE ON properties
+		FOR EACH ROW EXECUTE PROCEDURE properties_state_machine_check_bw();
+
+	-- Checks the new value against the current one which is read from the table
+	-- cause INSERT ... ON CONFLICT of set_value fires BEFORE INSERT triggers for existing values too
+	CREATE FUNCTION values_state_transition_check_bw() RETURNS TRIGGER AS $values_state_transition_check_bw$
+		DECLARE
+			sm property_state_machines%ROWTYPE;
+			cur text;
+			nxt text;
+		BEGIN
+			SELECT * INTO sm FROM property_state_machines WHERE property_id = NEW.property_id;
+			IF NOT FOUND THEN
+				RETURN NEW;
+			END IF;
+
+			nxt := NEW.value->>'v';
+			IF nxt IS NULL OR NOT nxt = ANY(sm.states) THEN
+				RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS("values".property_id, "values".value) VALUES(' || NEW.property_id || ', ' || NEW.value || ')';
+			END IF;
+
+			SELECT value->>'v' INTO cur FROM "values" WHERE owner_id = NEW.owner_id AND property_id = NEW.property_id;
+			IF cur IS NULL THEN
+				IF nxt <> sm.initial THEN
+					RAISE EXCEPTION 'state transition not allowed' USING DETAIL = 'KEYS("values".property_id, from, to) VALUES(' || NEW.property_id || ', NULL, ' || nxt || ')';
+				END IF;
+			ELSIF cur <> nxt AND NOT EXISTS (
+				SELECT FROM state_transitions
+				WHERE property_id = NEW.property_id AND from_state = cur AND to_state = nxt
+			) THEN
+				RAISE EXCEPTION 'state transition not allowed' USING DETAIL = 'KEYS("values".property_id, from, to) VALUES(' || NEW.property_id || ', ' || cur || ', ' || nxt || ')';
+			END IF;
+
+			RETURN NEW;
+		END;
+	$values_state_transition_check_bw$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER t_values_state_transition_check_bw BEFORE INSERT OR UPDATE ON "values"
+		FOR EACH ROW EXECUTE PROCEDURE values_state_transition_check_bw();
+
+	DROP FUNCTION new_property(text, text, "types"[], uuid[], uuid, property_kinds, text, text, sequence_reset_periods);
+	CREATE FUNCTION new_property(
+		text,
+		text,
+		"types"[],
+		uuid[] DEFAULT NULL,
+		uuid DEFAULT NULL,
+		property_kinds DEFAULT 'regular',
+		text DEFAULT NULL,
+		text DEFAULT NULL,
+		sequence_reset_periods DEFAULT NULL,
+		text[] DEFAULT NULL,
+		text DEFAULT NULL,
+		json DEFAULT NULL
+	) RETURNS uuid AS $new_property$
+		DECLARE
+			res uuid;
+			tr json;
+		BEGIN
+			res := uuid_generate_v4();
+
+			INSERT INTO properties (id, "name", description, "types", reference_type_ids, owner_reference_type_id, kind)
+			VALUES (res, $1, $2, $3, $4, $5, $6);
+
+			IF $6 = 'sequence'::property_kinds THEN
+				IF COALESCE($8, '{prefix}{n}') !~ '\{n(:\d+)?\}' THEN
+					RAISE EXCEPTION 'sequence template without number placeholder' USING DETAIL = 'KEYS(property_sequences.template) VALUES(' || $8 || ')';
+				END IF;
+
+				INSERT INTO property_sequences (property_id, prefix, template, reset_period)
+				VALUES (res, COALESCE($7, ''), COALESCE($8, '{prefix}{n}'), COALESCE($9, 'never'));
+			ELSIF $6::text = 'state_machine' THEN
+				IF $11 IS NULL OR NOT $11 = ANY($10) THEN
+					RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS(property_state_machines.initial) VALUES(' || COALESCE($11, 'NULL') || ')';
+				END IF;
+
+				INSERT INTO property_state_machines (property_id, states, initial)
+				VALUES (res, $10, $11);
+
+				FOR tr IN SELECT * FROM json_array_elements(COALESCE($12, '[]'::json)) LOOP
+					IF NOT (tr->>'from') = ANY($10) OR NOT (tr->>'to') = ANY($10) THEN
+						RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS(state_transitions.from_state, state_transitions.to_state) VALUES(' || COALESCE(tr->>'from', 'NULL') || ', ' || COALESCE(tr->>'to', 'NULL') || ')';
+					END IF;
+
+					INSERT INTO state_transitions (property_id, from_state, to_state)
+					VALUES (res, tr->>'from', tr->>'to')
+					ON CONFLICT DO NOTHING;
+				END LOOP;
+			END IF;
+
+			RETURN res;
+		END;
+	$new_property$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION property_state_machine_json(uuid) RETURNS json AS $property_state_machine_json$
+		BEGIN
+			RETURN (
+				SELECT json_build_object(
+					'states', sm.states,
+					'initial', sm.initial,
+					'transitions', COALESCE((
+						SELECT json_agg(json_build_object('from', st.from_state, 'to', st.to_state) ORDER BY st.from_state, st.to_state)
+						FROM state_transitions st
+						WHERE st.property_id = sm.property_id
+					), '[]'::json)
+				)
+				FROM property_state_machines sm
+				WHERE sm.property_id = $1
+			);
+		END;
+	$property_state_machine_json$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION get_state_transitions(uuid, uuid) RETURNS SETOF json AS $get_state_transitions$
+		DECLARE
+			sm property_state_machines%ROWTYPE;
+			cur text;
+		BEGIN
+			IF NOT EXISTS (SELECT FROM records WHERE id = $1) OR NOT EXISTS (SELECT FROM properties WHERE id = $2) THEN
+				RETURN;
+			END IF;
+
+			SELECT * INTO sm FROM property_state_machines WHERE property_id = $2;
+			IF NOT FOUND THEN
+				RAISE EXCEPTION 'property is not a state machine' USING DETAIL = 'KEYS(properties.id) VALUES(' || $2 || ')';
+			END IF;
+
+			SELECT value->>'v' INTO cur FROM "values" WHERE owner_id = $1 AND property_id = $2;
+
+			RETURN QUERY
+				SELECT json_build_object(
+					'record_id', $1,
+					'property_id', $2,
+					'state', cur,
+					'transitions', CASE
+						WHEN cur IS NULL THEN json_build_array(sm.initial)
+						ELSE COALESCE((
+							SELECT json_agg(to_state ORDER BY to_state)
+							FROM state_transitions
+							WHERE property_id = $2 AND from_state = cur AND to_state <> cur
+						), '[]'::json)
+					END
+				);
+		END;
+	$get_state_transitions$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION get_property(uuid) RETURNS SETOF json AS $get_property$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', id,
+						'name', "name",
+						'description', description,
+						'types', "types",
+						'reference_type_ids', reference_type_ids,
+						'owner_reference_type_id', owner_reference_type_id,
+						'kind', kind,
+						'sequence', property_sequence_json(id),
+						'state_machine', property_state_machine_json(id),
+						'sum', "sum",
+						'change_at', change_at::timestamptz
+					)
+				FROM properties
+				WHERE id = $1;
+		END;
+	$get_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION update_property(uuid, text, text) RETURNS SETOF json AS $update_property$
+		BEGIN
+			RETURN QUERY UPDATE properties SET
+				"name" = COALESCE($2, "name", $2),
+				description = COALESCE($3, description, $3)
+			WHERE id = $1
+			RETURNING json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'state_machine', property_state_machine_json(id),
+				'sum', "sum",
+				'change_at', change_at::timestamptz
+			);
+		END;
+	$update_property$ LANGUAGE plpgsql;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00045(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	CREATE OR REPLACE FUNCTION update_property(uuid, text, text) RETURNS SETOF json AS $update_property$
+		BEGIN
+			RETURN QUERY UPDATE properties SET
+				"name" = COALESCE($2, "name", $2),
+				description = COALESCE($3, description, $3)
+			WHERE id = $1
+			RETURNING json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'sum', "sum",
+				'change_at', change_at::timestamptz
+			);
+		END;
+	$update_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION get_property(uuid) RETURNS SETOF json AS $get_property$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', id,
+						'name', "name",
+						'description', description,
+						'types', "types",
+						'reference_type_ids', reference_type_ids,
+						'owner_reference_type_id', owner_reference_type_id,
+						'kind', kind,
+						'sequence', property_sequence_json(id),
+						'sum', "sum",
+						'change_at', change_at::timestamptz
+					)
+				FROM properties
+				WHERE id = $1;
+		END;
+	$get_property$ LANGUAGE plpgsql;
+
+	DROP FUNCTION get_state_transitions(uuid, uuid);
+	DROP FUNCTION property_state_machine_json(uuid);
+
+	DROP FUNCTION new_property(text, text, "types"[], uuid[], uuid, property_kinds, text, text, sequence_reset_periods, text[], text, json);
+	CREATE FUNCTION new_property(
+		text,
+		text,
+		"types"[],
+		uuid[] DEFAULT NULL,
+		uuid DEFAULT NULL,
+		property_kinds DEFAULT 'regular',
+		text DEFAULT NULL,
+		text DEFAULT NULL,
+		sequence_reset_periods DEFAULT NULL
+	) RETURNS uuid AS $new_property$
+		DECLARE
+			res uuid;
+		BEGIN
+			res := uuid_generate_v4();
+
+			INSERT INTO properties (id, "name", description, "types", reference_type_ids, owner_reference_type_id, kind)
+			VALUES (res, $1, $2, $3, $4, $5, $6);
+
+			IF $6 = 'sequence'::property_kinds THEN
+				IF COALESCE($8, '{prefix}{n}') !~ '\{n(:\d+)?\}' THEN
+					RAISE EXCEPTION 'sequence template without number placeholder' USING DETAIL = 'KEYS(property_sequences.template) VALUES(' || $8 || ')';
+				END IF;
+
+				INSERT INTO property_sequences (property_id, prefix, template, reset_period)
+				VALUES (res, COALESCE($7, ''), COALESCE($8, '{prefix}{n}'), COALESCE($9, 'never'));
+			END IF;
+
+			RETURN res;
+		END;
+	$new_property$ LANGUAGE plpgsql;
+
+	DROP TRIGGER t_values_state_transition_check_bw ON "values";
+	DROP FUNCTION values_state_transition_check_bw();
+
+	DROP TRIGGER t_properties_state_machine_check_bw ON properties;
+	DROP FUNCTION properties_state_machine_check_bw();
+
+	DROP TABLE state_transitions;
+	DROP TABLE property_state_machines;
+
+	-- PostgreSQL can not drop a value of enum so state machine properties become regular ones
+	UPDATE properties SET kind = 'regular' WHERE kind::text = 'state_machine';
+END $$;`
+	return execQuery(query, tx)
+}
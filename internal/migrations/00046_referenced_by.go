@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00046, down00046)
+}
+
+func up00046(tx *sql.Tx) error {
+	query := `-- Reverse reference lookup
+DO $$ BEGIN
+	CREATE INDEX IF NOT EXISTS values_ref_idx ON "values" ((value->>'v')) WHERE "type" = 'ref'::types;
+
+	CREATE FUNCTION get_referenced_by(uuid, uuid DEFAULT NULL, uuid DEFAULT NULL, int DEFAULT 100, int DEFAULT 0) RETURNS SETOF json AS $get_referenced_by$
+		BEGIN
+			IF NOT EXISTS (SELECT FROM records WHERE id = $1) THEN
+				RAISE EXCEPTION 'record not found' USING DETAIL = 'KEYS(records.id) VALUES(' || $1 || ')';
+			END IF;
+
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'record_id', v.owner_id,
+						'property_id', v.property_id,
+						'reference_type_id', r.reference_type_id
+					)
+				FROM "values" v
+				JOIN records r ON r.id = v.owner_id
+				WHERE v."type" = 'ref'::types
+				AND v.value->>'v' = $1::text
+				AND ($2 IS NULL OR v.property_id = $2)
+				AND ($3 IS NULL OR r.reference_type_id = $3)
+				ORDER BY v.owner_id, v.property_id
+				LIMIT $4
+				OFFSET $5;
+		END;
+	$get_referenced_by$ LANGUAGE plpgsql;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00046(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	DROP FUNCTION IF EXISTS get_referenced_by(uuid, uuid, uuid, int, int);
+
+	DROP INDEX IF EXISTS values_ref_idx;
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,94 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00047, down00047)
+}
+
+func up00047(tx *sql.Tx) error {
+	query := `-- Graph traversal over reference values
+DO $$ BEGIN
+	CREATE OR REPLACE FUNCTION get_record_graph(uuid, uuid[] DEFAULT NULL, int DEFAULT 3) RETURNS SETOF json AS $get_record_graph$
+		BEGIN
+			IF NOT EXISTS (SELECT FROM records WHERE id = $1) THEN
+				RAISE EXCEPTION 'record not found' USING DETAIL = 'KEYS(records.id) VALUES(' || $1 || ')';
+			END IF;
+
+			RETURN QUERY
+				WITH RECURSIVE walk(owner_id, property_id, target_id, depth, "path", cycle) AS (
+					SELECT
+						v.owner_id,
+						v.property_id,
+						(v.value->>'v')::uuid,
+						1,
+						ARRAY[v.owner_id],
+						(v.value->>'v')::uuid = v.owner_id
+					FROM "values" v
+					WHERE v.owner_id = $1
+					AND v."type" = 'ref'::types
+					AND ($2 IS NULL OR v.property_id = ANY($2))
+					UNION ALL
+					SELECT
+						v.owner_id,
+						v.property_id,
+						(v.value->>'v')::uuid,
+						w.depth + 1,
+						w."path" || v.owner_id,
+						(v.value->>'v')::uuid = ANY(w."path" || v.owner_id)
+					FROM walk w
+					JOIN "values" v ON v.owner_id = w.target_id
+					WHERE NOT w.cycle
+					AND w.depth < $3
+					AND v."type" = 'ref'::types
+					AND ($2 IS NULL OR v.property_id = ANY($2))
+				)
+				SELECT json_build_object(
+					'nodes', (
+						SELECT json_agg(json_build_object(
+							'record_id', n.id,
+							'reference_type_id', r.reference_type_id,
+							'depth', n.depth
+						) ORDER BY n.depth, n.id)
+						FROM (
+							SELECT id, min(depth) AS depth
+							FROM (
+								SELECT $1 AS id, 0 AS depth
+								UNION ALL
+								SELECT target_id, depth FROM walk
+							) visited
+							GROUP BY id
+						) n
+						LEFT JOIN records r ON r.id = n.id
+					),
+					'edges', COALESCE((
+						SELECT json_agg(json_build_object(
+							'from', e.owner_id,
+							'to', e.target_id,
+							'property_id', e.property_id,
+							'cycle', e.cycle
+						) ORDER BY e.owner_id, e.property_id)
+						FROM (
+							SELECT owner_id, property_id, target_id, bool_or(cycle) AS cycle
+							FROM walk
+							GROUP BY owner_id, property_id, target_id
+						) e
+					), '[]'::json)
+				);
+		END;
+	$get_record_graph$ LANGUAGE plpgsql;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00047(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	DROP FUNCTION IF EXISTS get_record_graph(uuid, uuid[], int);
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00048, down00048)
+}
+
+func up00048(tx *sql.Tx) error {
+	query := `-- Batched get function for records with values
+CREATE OR REPLACE FUNCTION get_records(uuid[], boolean DEFAULT FALSE) RETURNS SETOF json AS $get_records$
+	BEGIN
+		RETURN QUERY
+			SELECT
+				json_build_object(
+					'id', r.id,
+					'reference_type_id', r.reference_type_id,
+					'name', r."name",
+					'description', r.description,
+					'deletion_mark', r.deletion_mark,
+					'sum', r."sum",
+					'change_at', r.change_at::timestamptz,
+					'values', CASE WHEN $2 THEN COALESCE((
+						SELECT json_agg(json_build_object(
+							'owner_id', v.owner_id,
+							'property_id', v.property_id,
+							'type', v."type",
+							'reference_type_id', v.reference_type_id,
+							'value', v.value,
+							'sum', v."sum",
+							'change_at', v.change_at::timestamptz
+						) ORDER BY v.property_id)
+						FROM "values" v
+						WHERE v.owner_id = r.id
+					), '[]'::json) END
+				)
+			FROM records r
+			WHERE r.id = ANY($1);
+	END;
+$get_records$ LANGUAGE plpgsql;`
+	return execQuery(query, tx)
+}
+
+func down00048(tx *sql.Tx) error {
+	query := `DROP FUNCTION IF EXISTS get_records(uuid[], boolean);`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,385 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00049, down00049)
+}
+
+func up00049(tx *sql.Tx) error {
+	query := `-- Dump export and import
+DO $$ BEGIN
+	-- Import sets datatom.skip_changes locally to its transaction unless imported data should be published
+	CREATE OR REPLACE FUNCTION value_after_state_change() RETURNS TRIGGER AS $value_after_state_change$
+		BEGIN
+			IF current_setting('datatom.skip_changes', true) = 'on' THEN
+				RETURN NEW;
+			END IF;
+			INSERT INTO value_changes (record_id, property_id) VALUES (NEW.owner_id, NEW.property_id);
+			RETURN NEW;
+		END;
+	$value_after_state_change$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION record_after_state_change() RETURNS TRIGGER AS $record_after_state_change$
+		BEGIN
+			IF current_setting('datatom.skip_changes', true) = 'on' THEN
+				RETURN NEW;
+			END IF;
+			INSERT INTO record_changes (record_id) VALUES (NEW.id);
+			RETURN NEW;
+		END;
+	$record_after_state_change$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION property_after_state_change() RETURNS TRIGGER AS $property_after_state_change$
+		BEGIN
+			IF current_setting('datatom.skip_changes', true) = 'on' THEN
+				RETURN NEW;
+			END IF;
+			INSERT INTO property_changes (property_id) VALUES (NEW.id);
+			RETURN NEW;
+		END;
+	$property_after_state_change$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION reference_type_after_state_change() RETURNS TRIGGER AS $reference_type_after_state_change$
+		BEGIN
+			IF current_setting('datatom.skip_changes', true) = 'on' THEN
+				RETURN NEW;
+			END IF;
+			INSERT INTO reference_type_changes (reference_type_id) VALUES (NEW.id);
+			RETURN NEW;
+		END;
+	$reference_type_after_state_change$ LANGUAGE plpgsql;
+
+	-- Imported records bring their sequence numbers and states with them,
+	-- so import sets datatom.import locally to its transaction to keep them as is
+	CREATE OR REPLACE FUNCTION record_sequences_assign() RETURNS TRIGGER AS $record_sequences_assign$
+		BEGIN
+			IF current_setting('datatom.import', true) = 'on' THEN
+				RETURN NEW;
+			END IF;
+
+			INSERT INTO "values" (owner_id, property_id, "type", value)
+			SELECT NEW.id, p.id, 'text'::types, jsonb_build_object('v', next_sequence_number(p.id, NEW.change_at))
+			FROM properties p
+			WHERE p.kind = 'sequence'::property_kinds AND p.owner_reference_type_id = NEW.reference_type_id;
+
+			RETURN NEW;
+		END;
+	$record_sequences_assign$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION values_state_transition_check_bw() RETURNS TRIGGER AS $values_state_transition_check_bw$
+		DECLARE
+			sm property_state_machines%ROWTYPE;
+			cur text;
+			nxt text;
+		BEGIN
+			SELECT * INTO sm FROM property_state_machines WHERE property_id = NEW.property_id;
+			IF NOT FOUND THEN
+				RETURN NEW;
+			END IF;
+
+			nxt := NEW.value->>'v';
+			IF nxt IS NULL OR NOT nxt = ANY(sm.states) THEN
+				RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS("values".property_id, "values".value) VALUES(' || NEW.property_id || ', ' || NEW.value || ')';
+			END IF;
+
+			IF current_setting('datatom.import', true) = 'on' THEN
+				RETURN NEW;
+			END IF;
+
+			SELECT value->>'v' INTO cur FROM "values" WHERE owner_id = NEW.owner_id AND property_id = NEW.property_id;
+			IF cur IS NULL THEN
+				IF nxt <> sm.initial THEN
+					RAISE EXCEPTION 'state transition not allowed' USING DETAIL = 'KEYS("values".property_id, from, to) VALUES(' || NEW.property_id || ', NULL, ' || nxt || ')';
+				END IF;
+			ELSIF cur <> nxt AND NOT EXISTS (
+				SELECT FROM state_transitions
+				WHERE property_id = NEW.property_id AND from_state = cur AND to_state = nxt
+			) THEN
+				RAISE EXCEPTION 'state transition not allowed' USING DETAIL = 'KEYS("values".property_id, from, to) VALUES(' || NEW.property_id || ', ' || cur || ', ' || nxt || ')';
+			END IF;
+
+			RETURN NEW;
+		END;
+	$values_state_transition_check_bw$ LANGUAGE plpgsql;
+
+	-- Inserts the property with the given ID, new_property generates it
+	CREATE FUNCTION insert_property(
+		uuid,
+		text,
+		text,
+		"types"[],
+		uuid[] DEFAULT NULL,
+		uuid DEFAULT NULL,
+		property_kinds DEFAULT 'regular',
+		text DEFAULT NULL,
+		text DEFAULT NULL,
+		sequence_reset_periods DEFAULT NULL,
+		text[] DEFAULT NULL,
+		text DEFAULT NULL,
+		json DEFAULT NULL
+	) RETURNS uuid AS $insert_property$
+		DECLARE
+			tr json;
+		BEGIN
+			INSERT INTO properties (id, "name", description, "types", reference_type_ids, owner_reference_type_id, kind)
+			VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+			IF $7 = 'sequence'::property_kinds THEN
+				IF COALESCE($9, '{prefix}{n}') !~ '\{n(:\d+)?\}' THEN
+					RAISE EXCEPTION 'sequence template without number placeholder' USING DETAIL = 'KEYS(property_sequences.template) VALUES(' || $9 || ')';
+				END IF;
+
+				INSERT INTO property_sequences (property_id, prefix, template, reset_period)
+				VALUES ($1, COALESCE($8, ''), COALESCE($9, '{prefix}{n}'), COALESCE($10, 'never'));
+			ELSIF $7::text = 'state_machine' THEN
+				IF $12 IS NULL OR NOT $12 = ANY($11) THEN
+					RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS(property_state_machines.initial) VALUES(' || COALESCE($12, 'NULL') || ')';
+				END IF;
+
+				INSERT INTO property_state_machines (property_id, states, initial)
+				VALUES ($1, $11, $12);
+
+				FOR tr IN SELECT * FROM json_array_elements(COALESCE($13, '[]'::json)) LOOP
+					IF NOT (tr->>'from') = ANY($11) OR NOT (tr->>'to') = ANY($11) THEN
+						RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS(state_transitions.from_state, state_transitions.to_state) VALUES(' || COALESCE(tr->>'from', 'NULL') || ', ' || COALESCE(tr->>'to', 'NULL') || ')';
+					END IF;
+
+					INSERT INTO state_transitions (property_id, from_state, to_state)
+					VALUES ($1, tr->>'from', tr->>'to')
+					ON CONFLICT DO NOTHING;
+				END LOOP;
+			END IF;
+
+			RETURN $1;
+		END;
+	$insert_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION new_property(
+		text,
+		text,
+		"types"[],
+		uuid[] DEFAULT NULL,
+		uuid DEFAULT NULL,
+		property_kinds DEFAULT 'regular',
+		text DEFAULT NULL,
+		text DEFAULT NULL,
+		sequence_reset_periods DEFAULT NULL,
+		text[] DEFAULT NULL,
+		text DEFAULT NULL,
+		json DEFAULT NULL
+	) RETURNS uuid AS $new_property$
+		BEGIN
+			RETURN insert_property(uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+		END;
+	$new_property$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION dump_reference_types() RETURNS SETOF json AS $dump_reference_types$
+		SELECT
+			json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description
+			)
+		FROM reference_types
+		ORDER BY id;
+	$dump_reference_types$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION dump_properties() RETURNS SETOF json AS $dump_properties$
+		SELECT
+			json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'state_machine', property_state_machine_json(id)
+			)
+		FROM properties
+		ORDER BY id;
+	$dump_properties$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION dump_sequence_counters() RETURNS SETOF json AS $dump_sequence_counters$
+		SELECT
+			json_build_object(
+				'property_id', property_id,
+				'period', "period",
+				'last_number', last_number
+			)
+		FROM sequence_counters
+		ORDER BY property_id, "period";
+	$dump_sequence_counters$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION dump_records() RETURNS SETOF json AS $dump_records$
+		SELECT
+			json_build_object(
+				'id', id,
+				'reference_type_id', reference_type_id,
+				'name', "name",
+				'description', description,
+				'deletion_mark', deletion_mark
+			)
+		FROM records
+		ORDER BY id;
+	$dump_records$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION dump_values() RETURNS SETOF json AS $dump_values$
+		SELECT
+			json_build_object(
+				'owner_id', owner_id,
+				'property_id', property_id,
+				'type', "type",
+				'reference_type_id', reference_type_id,
+				'value', value
+			)
+		FROM "values"
+		ORDER BY owner_id, property_id;
+	$dump_values$ LANGUAGE sql STABLE;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00049(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	DROP FUNCTION dump_values();
+	DROP FUNCTION dump_records();
+	DROP FUNCTION dump_sequence_counters();
+	DROP FUNCTION dump_properties();
+	DROP FUNCTION dump_reference_types();
+
+	CREATE OR REPLACE FUNCTION new_property(
+		text,
+		text,
+		"types"[],
+		uuid[] DEFAULT NULL,
+		uuid DEFAULT NULL,
+		property_kinds DEFAULT 'regular',
+		text DEFAULT NULL,
+		text DEFAULT NULL,
+		sequence_reset_periods DEFAULT NULL,
+		text[] DEFAULT NULL,
+		text DEFAULT NULL,
+		json DEFAULT NULL
+	) RETURNS uuid AS $new_property$
+		DECLARE
+			res uuid;
+			tr json;
+		BEGIN
+			res := uuid_generate_v4();
+
+			INSERT INTO properties (id, "name", description, "types", reference_type_ids, owner_reference_type_id, kind)
+			VALUES (res, $1, $2, $3, $4, $5, $6);
+
+			IF $6 = 'sequence'::property_kinds THEN
+				IF COALESCE($8, '{prefix}{n}') !~ '\{n(:\d+)?\}' THEN
+					RAISE EXCEPTION 'sequence template without number placeholder' USING DETAIL = 'KEYS(property_sequences.template) VALUES(' || $8 || ')';
+				END IF;
+
+				INSERT INTO property_sequences (property_id, prefix, template, reset_period)
+				VALUES (res, COALESCE($7, ''), COALESCE($8, '{prefix}{n}'), COALESCE($9, 'never'));
+			ELSIF $6::text = 'state_machine' THEN
+				IF $11 IS NULL OR NOT $11 = ANY($10) THEN
+					RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS(property_state_machines.initial) VALUES(' || COALESCE($11, 'NULL') || ')';
+				END IF;
+
+				INSERT INTO property_state_machines (property_id, states, initial)
+				VALUES (res, $10, $11);
+
+				FOR tr IN SELECT * FROM json_array_elements(COALESCE($12, '[]'::json)) LOOP
+					IF NOT (tr->>'from') = ANY($10) OR NOT (tr->>'to') = ANY($10) THEN
+						RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS(state_transitions.from_state, state_transitions.to_state) VALUES(' || COALESCE(tr->>'from', 'NULL') || ', ' || COALESCE(tr->>'to', 'NULL') || ')';
+					END IF;
+
+					INSERT INTO state_transitions (property_id, from_state, to_state)
+					VALUES (res, tr->>'from', tr->>'to')
+					ON CONFLICT DO NOTHING;
+				END LOOP;
+			END IF;
+
+			RETURN res;
+		END;
+	$new_property$ LANGUAGE plpgsql;
+
+	DROP FUNCTION insert_property(uuid, text, text, "types"[], uuid[], uuid, property_kinds, text, text, sequence_reset_periods, text[], text, json);
+
+	CREATE OR REPLACE FUNCTION values_state_transition_check_bw() RETURNS TRIGGER AS $values_state_transition_check_bw$
+		DECLARE
+			sm property_state_machines%ROWTYPE;
+			cur text;
+			nxt text;
+		BEGIN
+			SELECT * INTO sm FROM property_state_machines WHERE property_id = NEW.property_id;
+			IF NOT FOUND THEN
+				RETURN NEW;
+			END IF;
+
+			nxt := NEW.value->>'v';
+			IF nxt IS NULL OR NOT nxt = ANY(sm.states) THEN
+				RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS("values".property_id, "values".value) VALUES(' || NEW.property_id || ', ' || NEW.value || ')';
+			END IF;
+
+			SELECT value->>'v' INTO cur FROM "values" WHERE owner_id = NEW.owner_id AND property_id = NEW.property_id;
+			IF cur IS NULL THEN
+				IF nxt <> sm.initial THEN
+					RAISE EXCEPTION 'state transition not allowed' USING DETAIL = 'KEYS("values".property_id, from, to) VALUES(' || NEW.property_id || ', NULL, ' || nxt || ')';
+				END IF;
+			ELSIF cur <> nxt AND NOT EXISTS (
+				SELECT FROM state_transitions
+				WHERE property_id = NEW.property_id AND from_state = cur AND to_state = nxt
+			) THEN
+				RAISE EXCEPTION 'state transition not allowed' USING DETAIL = 'KEYS("values".property_id, from, to) VALUES(' || NEW.property_id || ', ' || cur || ', ' || nxt || ')';
+			END IF;
+
+			RETURN NEW;
+		END;
+	$values_state_transition_check_bw$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION record_sequences_assign() RETURNS TRIGGER AS $record_sequences_assign$
+		BEGIN
+			INSERT INTO "values" (owner_id, property_id, "type", value)
+			SELECT NEW.id, p.id, 'text'::types, jsonb_build_object('v', next_sequence_number(p.id, NEW.change_at))
+			FROM properties p
+			WHERE p.kind = 'sequence'::property_kinds AND p.owner_reference_type_id = NEW.reference_type_id;
+
+			RETURN NEW;
+		END;
+	$record_sequences_assign$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION reference_type_after_state_change() RETURNS TRIGGER AS $reference_type_after_state_change$
+		BEGIN
+			INSERT INTO reference_type_changes (reference_type_id) VALUES (NEW.id);
+			RETURN NEW;
+		END;
+	$reference_type_after_state_change$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION property_after_state_change() RETURNS TRIGGER AS $property_after_state_change$
+		BEGIN
+			INSERT INTO property_changes (property_id) VALUES (NEW.id);
+			RETURN NEW;
+		END;
+	$property_after_state_change$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION record_after_state_change() RETURNS TRIGGER AS $record_after_state_change$
+		BEGIN
+			INSERT INTO record_changes (record_id) VALUES (NEW.id);
+			RETURN NEW;
+		END;
+	$record_after_state_change$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION value_after_state_change() RETURNS TRIGGER AS $value_after_state_change$
+		BEGIN
+			INSERT INTO value_changes (record_id, property_id) VALUES (NEW.owner_id, NEW.property_id);
+			RETURN NEW;
+		END;
+	$value_after_state_change$ LANGUAGE plpgsql;
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00050, down00050)
+}
+
+func up00050(tx *sql.Tx) error {
+	query := `-- Tabular export of a reference type
+DO $$ BEGIN
+	CREATE FUNCTION get_table_columns(uuid) RETURNS SETOF json AS $get_table_columns$
+		BEGIN
+			IF NOT EXISTS (SELECT FROM reference_types WHERE id = $1) THEN
+				RAISE EXCEPTION 'reference type not found' USING DETAIL = 'KEYS(reference_types.id) VALUES(' || $1 || ')';
+			END IF;
+
+			RETURN QUERY
+				SELECT p
+				FROM properties x, get_property(x.id) p
+				WHERE x.owner_reference_type_id = $1
+				ORDER BY x."name", x.id;
+		END;
+	$get_table_columns$ LANGUAGE plpgsql;
+
+	-- SQL function is not materialized by the caller so rows are streamed
+	CREATE FUNCTION get_table_rows(uuid, boolean DEFAULT NULL, text DEFAULT NULL) RETURNS SETOF json AS $get_table_rows$
+		SELECT
+			json_build_object(
+				'id', r.id,
+				'reference_type_id', r.reference_type_id,
+				'name', r."name",
+				'description', r.description,
+				'deletion_mark', r.deletion_mark,
+				'sum', r."sum",
+				'change_at', r.change_at::timestamptz,
+				'values', COALESCE((
+					SELECT json_agg(json_build_object(
+						'owner_id', v.owner_id,
+						'property_id', v.property_id,
+						'type', v."type",
+						'reference_type_id', v.reference_type_id,
+						'value', v.value,
+						'sum', v."sum",
+						'change_at', v.change_at::timestamptz,
+						'ref_name', rr."name"
+					) ORDER BY v.property_id)
+					FROM "values" v
+					JOIN properties p ON p.id = v.property_id AND p.owner_reference_type_id = $1
+					LEFT JOIN records rr ON rr.id = CASE WHEN v."type" = 'ref' THEN (v.value->>'v')::uuid END
+					WHERE v.owner_id = r.id
+				), '[]'::json)
+			)
+		FROM records r
+		WHERE r.reference_type_id = $1
+			AND ($2 IS NULL OR r.deletion_mark = $2)
+			AND ($3 IS NULL OR position(lower($3) IN lower(r."name")) > 0)
+		ORDER BY r."name", r.id;
+	$get_table_rows$ LANGUAGE sql STABLE;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00050(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	DROP FUNCTION get_table_rows(uuid, boolean, text);
+	DROP FUNCTION get_table_columns(uuid);
+END $$;`
+	return execQuery(query, tx)
+}
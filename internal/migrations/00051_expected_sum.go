@@ -0,0 +1,100 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00051, down00051)
+}
+
+func up00051(tx *sql.Tx) error {
+	query := `-- Updates checked against the expected sum of the object
+DO $$ BEGIN
+	-- The row is locked before the check so the sum can not be changed by a concurrent update.
+	-- Missing objects are left to the update which reports them as not found.
+	CREATE FUNCTION update_ref_type(uuid, text, text, text) RETURNS SETOF json AS $update_ref_type$
+		DECLARE
+			cur text;
+		BEGIN
+			IF $4 IS NOT NULL THEN
+				SELECT "sum" INTO cur FROM reference_types WHERE id = $1 FOR UPDATE;
+				IF FOUND AND cur <> $4 THEN
+					RAISE EXCEPTION 'sum mismatch' USING DETAIL = 'KEYS(reference_types.id, expected, actual) VALUES(' || $1 || ', ' || $4 || ', ' || cur || ')';
+				END IF;
+			END IF;
+
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', u.id,
+						'name', u."name",
+						'description', u.description,
+						'sum', u."sum",
+						'change_at', u.change_at::timestamptz
+					)
+				FROM update_ref_type($1, $2, $3) u;
+		END;
+	$update_ref_type$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION update_record(uuid, text, text, bool, text) RETURNS SETOF json AS $update_record$
+		DECLARE
+			cur text;
+		BEGIN
+			IF $5 IS NOT NULL THEN
+				SELECT "sum" INTO cur FROM records WHERE id = $1 FOR UPDATE;
+				IF FOUND AND cur <> $5 THEN
+					RAISE EXCEPTION 'sum mismatch' USING DETAIL = 'KEYS(records.id, expected, actual) VALUES(' || $1 || ', ' || $5 || ', ' || cur || ')';
+				END IF;
+			END IF;
+
+			RETURN QUERY SELECT * FROM update_record($1, $2, $3, $4);
+		END;
+	$update_record$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION update_property(uuid, text, text, text) RETURNS SETOF json AS $update_property$
+		DECLARE
+			cur text;
+		BEGIN
+			IF $4 IS NOT NULL THEN
+				SELECT "sum" INTO cur FROM properties WHERE id = $1 FOR UPDATE;
+				IF FOUND AND cur <> $4 THEN
+					RAISE EXCEPTION 'sum mismatch' USING DETAIL = 'KEYS(properties.id, expected, actual) VALUES(' || $1 || ', ' || $4 || ', ' || cur || ')';
+				END IF;
+			END IF;
+
+			RETURN QUERY SELECT * FROM update_property($1, $2, $3);
+		END;
+	$update_property$ LANGUAGE plpgsql;
+
+	-- A missing value does not match any sum
+	CREATE FUNCTION set_value(uuid, uuid, "types", uuid, json, text) RETURNS SETOF json AS $set_value$
+		DECLARE
+			cur text;
+		BEGIN
+			IF $6 IS NOT NULL THEN
+				SELECT "sum" INTO cur FROM "values" WHERE owner_id = $1 AND property_id = $2 FOR UPDATE;
+				IF NOT FOUND OR cur <> $6 THEN
+					RAISE EXCEPTION 'sum mismatch' USING DETAIL = 'KEYS("values".owner_id, "values".property_id, expected, actual) VALUES(' || $1 || ', ' || $2 || ', ' || $6 || ', ' || COALESCE(cur, 'NULL') || ')';
+				END IF;
+			END IF;
+
+			RETURN QUERY SELECT * FROM set_value($1, $2, $3, $4, $5);
+		END;
+	$set_value$ LANGUAGE plpgsql;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00051(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	DROP FUNCTION set_value(uuid, uuid, "types", uuid, json, text);
+	DROP FUNCTION update_property(uuid, text, text, text);
+	DROP FUNCTION update_record(uuid, text, text, bool, text);
+	DROP FUNCTION update_ref_type(uuid, text, text, text);
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,107 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00052, down00052)
+}
+
+func up00052(tx *sql.Tx) error {
+	query := `-- Idempotency keys and objects created with IDs of clients
+DO $$ BEGIN
+	CREATE FUNCTION insert_ref_type(uuid, text, text) RETURNS uuid AS $insert_ref_type$
+		INSERT INTO reference_types (id, "name", description)
+		VALUES ($1, $2, $3)
+		RETURNING id;
+	$insert_ref_type$ LANGUAGE sql;
+
+	CREATE FUNCTION insert_record(uuid, text, text, bool DEFAULT FALSE, uuid DEFAULT NULL) RETURNS uuid AS $insert_record$
+		INSERT INTO records (id, "name", description, deletion_mark, reference_type_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;
+	$insert_record$ LANGUAGE sql;
+
+	-- Status is NULL while the first request is in progress
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		"key" text NOT NULL,
+		"scope" text NOT NULL,
+		fingerprint char(64) NOT NULL,
+		status int,
+		headers jsonb,
+		body bytea,
+		created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		expires_at timestamp NOT NULL,
+		PRIMARY KEY("key", "scope")
+	);
+
+	CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+	-- Returns NULL if the key is reserved by the call and the stored response otherwise
+	CREATE FUNCTION reserve_idempotency_key(text, text, text, interval) RETURNS json AS $reserve_idempotency_key$
+		DECLARE
+			k idempotency_keys%ROWTYPE;
+		BEGIN
+			DELETE FROM idempotency_keys WHERE "key" = $1 AND "scope" = $2 AND expires_at <= CURRENT_TIMESTAMP;
+
+			INSERT INTO idempotency_keys ("key", "scope", fingerprint, expires_at)
+			VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4)
+			ON CONFLICT ("key", "scope") DO NOTHING;
+			IF FOUND THEN
+				RETURN NULL;
+			END IF;
+
+			SELECT * INTO k FROM idempotency_keys WHERE "key" = $1 AND "scope" = $2;
+			IF k.fingerprint <> $3 THEN
+				RAISE EXCEPTION 'idempotency key reused' USING DETAIL = 'KEYS(idempotency_keys.key, idempotency_keys.scope) VALUES(' || $1 || ', ' || $2 || ')';
+			END IF;
+			IF k.status IS NULL THEN
+				RAISE EXCEPTION 'idempotency key in progress' USING DETAIL = 'KEYS(idempotency_keys.key, idempotency_keys.scope) VALUES(' || $1 || ', ' || $2 || ')';
+			END IF;
+
+			RETURN json_build_object(
+				'status', k.status,
+				'headers', k.headers,
+				'body', encode(k.body, 'base64')
+			);
+		END;
+	$reserve_idempotency_key$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION save_idempotent_response(text, text, int, jsonb, bytea) RETURNS void AS $save_idempotent_response$
+		UPDATE idempotency_keys SET
+			status = $3,
+			headers = $4,
+			body = $5
+		WHERE "key" = $1 AND "scope" = $2;
+	$save_idempotent_response$ LANGUAGE sql;
+
+	CREATE FUNCTION release_idempotency_key(text, text) RETURNS void AS $release_idempotency_key$
+		DELETE FROM idempotency_keys WHERE "key" = $1 AND "scope" = $2 AND status IS NULL;
+	$release_idempotency_key$ LANGUAGE sql;
+
+	CREATE FUNCTION purge_idempotency_keys() RETURNS bigint AS $purge_idempotency_keys$
+		WITH deleted AS (
+			DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP RETURNING 1
+		)
+		SELECT count(*) FROM deleted;
+	$purge_idempotency_keys$ LANGUAGE sql;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00052(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	DROP FUNCTION purge_idempotency_keys();
+	DROP FUNCTION release_idempotency_key(text, text);
+	DROP FUNCTION save_idempotent_response(text, text, int, jsonb, bytea);
+	DROP FUNCTION reserve_idempotency_key(text, text, text, interval);
+	DROP TABLE IF EXISTS idempotency_keys;
+	DROP FUNCTION insert_record(uuid, text, text, bool, uuid);
+	DROP FUNCTION insert_ref_type(uuid, text, text);
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00053, down00053)
+}
+
+func up00053(tx *sql.Tx) error {
+	query := `-- Batched get functions for reference types and properties and the filtered list of records
+DO $$ BEGIN
+	CREATE FUNCTION get_ref_types(uuid[]) RETURNS SETOF json AS $get_ref_types$
+		SELECT p
+		FROM reference_types x, get_ref_type(x.id) p
+		WHERE x.id = ANY($1);
+	$get_ref_types$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION get_properties(uuid[]) RETURNS SETOF json AS $get_properties$
+		SELECT p
+		FROM properties x, get_property(x.id) p
+		WHERE x.id = ANY($1);
+	$get_properties$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION list_records(uuid DEFAULT NULL, boolean DEFAULT NULL, text DEFAULT NULL, integer DEFAULT 100, integer DEFAULT 0) RETURNS SETOF json AS $list_records$
+		SELECT
+			json_build_object(
+				'id', r.id,
+				'reference_type_id', r.reference_type_id,
+				'name', r."name",
+				'description', r.description,
+				'deletion_mark', r.deletion_mark,
+				'sum', r."sum",
+				'change_at', r.change_at::timestamptz
+			)
+		FROM records r
+		WHERE ($1 IS NULL OR r.reference_type_id = $1)
+			AND ($2 IS NULL OR r.deletion_mark = $2)
+			AND ($3 IS NULL OR position(lower($3) IN lower(r."name")) > 0)
+		ORDER BY r."name", r.id
+		LIMIT $4 OFFSET $5;
+	$list_records$ LANGUAGE sql STABLE;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00053(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	DROP FUNCTION list_records(uuid, boolean, text, integer, integer);
+	DROP FUNCTION get_properties(uuid[]);
+	DROP FUNCTION get_ref_types(uuid[]);
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,158 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00054, down00054)
+}
+
+func up00054(tx *sql.Tx) error {
+	query := `-- Change log retained after registered changes are purged by the sender
+DO $$ BEGIN
+	-- IDs are the ones of registered changes, so streams of changes are resumed by them
+	CREATE TABLE change_log (
+		id bigint PRIMARY KEY,
+		change_type change_types NOT NULL,
+		reference_type_id uuid,
+		property_id uuid,
+		record_id uuid,
+		changed_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX change_log_changed_at_idx ON change_log (changed_at);
+
+	-- Listeners of the changes channel are notified when the transaction of the change is committed
+	CREATE FUNCTION log_change(bigint, change_types, uuid, uuid, uuid) RETURNS void AS $log_change$
+		BEGIN
+			INSERT INTO change_log (id, change_type, reference_type_id, property_id, record_id)
+			VALUES ($1, $2, $3, $4, $5);
+			PERFORM pg_notify('changes', $1::text);
+		END;
+	$log_change$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION reference_type_change_logging() RETURNS TRIGGER AS $reference_type_change_logging$
+		BEGIN
+			PERFORM log_change(NEW.id, 'ref_type', NEW.reference_type_id, NULL, NULL);
+			RETURN NEW;
+		END;
+	$reference_type_change_logging$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER t_reference_type_change_logging AFTER INSERT ON reference_type_changes
+		FOR EACH ROW EXECUTE PROCEDURE reference_type_change_logging();
+
+	-- Properties belong to their owner reference types
+	CREATE FUNCTION property_change_logging() RETURNS TRIGGER AS $property_change_logging$
+		BEGIN
+			PERFORM log_change(
+				NEW.id, 'property',
+				(SELECT owner_reference_type_id FROM properties WHERE id = NEW.property_id),
+				NEW.property_id, NULL
+			);
+			RETURN NEW;
+		END;
+	$property_change_logging$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER t_property_change_logging AFTER INSERT ON property_changes
+		FOR EACH ROW EXECUTE PROCEDURE property_change_logging();
+
+	CREATE FUNCTION record_change_logging() RETURNS TRIGGER AS $record_change_logging$
+		BEGIN
+			PERFORM log_change(
+				NEW.id, 'record',
+				(SELECT reference_type_id FROM records WHERE id = NEW.record_id),
+				NULL, NEW.record_id
+			);
+			RETURN NEW;
+		END;
+	$record_change_logging$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER t_record_change_logging AFTER INSERT ON record_changes
+		FOR EACH ROW EXECUTE PROCEDURE record_change_logging();
+
+	CREATE FUNCTION value_change_logging() RETURNS TRIGGER AS $value_change_logging$
+		BEGIN
+			PERFORM log_change(
+				NEW.id, 'value',
+				(SELECT reference_type_id FROM records WHERE id = NEW.record_id),
+				NEW.property_id, NEW.record_id
+			);
+			RETURN NEW;
+		END;
+	$value_change_logging$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER t_value_change_logging AFTER INSERT ON value_changes
+		FOR EACH ROW EXECUTE PROCEDURE value_change_logging();
+
+	-- Changes which are not sent yet
+	INSERT INTO change_log (id, change_type, reference_type_id, property_id, record_id)
+	SELECT rtc.id, 'ref_type'::change_types, rtc.reference_type_id, NULL::uuid, NULL::uuid
+	FROM reference_type_changes rtc
+	UNION ALL
+	SELECT pc.id, 'property'::change_types, p.owner_reference_type_id, pc.property_id, NULL
+	FROM property_changes pc LEFT JOIN properties p ON p.id = pc.property_id
+	UNION ALL
+	SELECT rc.id, 'record'::change_types, r.reference_type_id, NULL, rc.record_id
+	FROM record_changes rc LEFT JOIN records r ON r.id = rc.record_id
+	UNION ALL
+	SELECT vc.id, 'value'::change_types, r.reference_type_id, vc.property_id, vc.record_id
+	FROM value_changes vc LEFT JOIN records r ON r.id = vc.record_id;
+
+	-- Changes after the ID filtered by reference types and properties if they are not NULL
+	CREATE FUNCTION get_change_log(bigint, uuid[] DEFAULT NULL, uuid[] DEFAULT NULL, integer DEFAULT 100) RETURNS SETOF json AS $get_change_log$
+		SELECT
+			json_build_object(
+				'id', cl.id,
+				'change_type', cl.change_type,
+				'reference_type_id', cl.reference_type_id,
+				'property_id', cl.property_id,
+				'record_id', cl.record_id,
+				'changed_at', cl.changed_at::timestamptz
+			)
+		FROM change_log cl
+		WHERE cl.id > $1
+			AND ($2 IS NULL OR cl.reference_type_id = ANY($2))
+			AND ($3 IS NULL OR cl.property_id = ANY($3))
+		ORDER BY cl.id
+		LIMIT $4;
+	$get_change_log$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION get_last_change_id() RETURNS bigint AS $get_last_change_id$
+		SELECT COALESCE(max(id), 0) FROM change_log;
+	$get_last_change_id$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION purge_change_log(interval) RETURNS bigint AS $purge_change_log$
+		WITH deleted AS (
+			DELETE FROM change_log WHERE changed_at < CURRENT_TIMESTAMP - $1 RETURNING id
+		)
+		SELECT count(*) FROM deleted;
+	$purge_change_log$ LANGUAGE sql;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00054(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	DROP FUNCTION purge_change_log(interval);
+	DROP FUNCTION get_last_change_id();
+	DROP FUNCTION get_change_log(bigint, uuid[], uuid[], integer);
+
+	DROP TRIGGER t_value_change_logging ON value_changes;
+	DROP TRIGGER t_record_change_logging ON record_changes;
+	DROP TRIGGER t_property_change_logging ON property_changes;
+	DROP TRIGGER t_reference_type_change_logging ON reference_type_changes;
+
+	DROP FUNCTION value_change_logging();
+	DROP FUNCTION record_change_logging();
+	DROP FUNCTION property_change_logging();
+	DROP FUNCTION reference_type_change_logging();
+	DROP FUNCTION log_change(bigint, change_types, uuid, uuid, uuid);
+
+	DROP TABLE change_log;
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,86 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00055, down00055)
+}
+
+func up00055(tx *sql.Tx) error {
+	query := `-- Cursors of consumers polling the change log
+DO $$ BEGIN
+	CREATE TABLE change_consumers (
+		name text PRIMARY KEY,
+		last_change_id bigint NOT NULL,
+		created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE FUNCTION change_consumer_json(change_consumers) RETURNS json AS $change_consumer_json$
+		SELECT json_build_object(
+			'name', $1.name,
+			'last_change_id', $1.last_change_id,
+			'created_at', $1.created_at::timestamptz,
+			'updated_at', $1.updated_at::timestamptz
+		);
+	$change_consumer_json$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION get_change_consumers() RETURNS SETOF json AS $get_change_consumers$
+		SELECT change_consumer_json(cc) FROM change_consumers cc ORDER BY cc.name;
+	$get_change_consumers$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION get_change_consumer(text) RETURNS json AS $get_change_consumer$
+		SELECT change_consumer_json(cc) FROM change_consumers cc WHERE cc.name = $1;
+	$get_change_consumer$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION set_change_consumer(text, bigint) RETURNS json AS $set_change_consumer$
+		INSERT INTO change_consumers AS cc (name, last_change_id) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET last_change_id = EXCLUDED.last_change_id, updated_at = CURRENT_TIMESTAMP
+		RETURNING change_consumer_json(cc.*);
+	$set_change_consumer$ LANGUAGE sql;
+
+	CREATE FUNCTION delete_change_consumer(text) RETURNS boolean AS $delete_change_consumer$
+		WITH deleted AS (
+			DELETE FROM change_consumers WHERE name = $1 RETURNING name
+		)
+		SELECT count(*) > 0 FROM deleted;
+	$delete_change_consumer$ LANGUAGE sql;
+
+	-- Changes are kept for the retention and until the slowest consumer has read them
+	CREATE OR REPLACE FUNCTION purge_change_log(interval) RETURNS bigint AS $purge_change_log$
+		WITH deleted AS (
+			DELETE FROM change_log
+			WHERE changed_at < CURRENT_TIMESTAMP - $1
+				AND id <= COALESCE((SELECT min(last_change_id) FROM change_consumers), id)
+			RETURNING id
+		)
+		SELECT count(*) FROM deleted;
+	$purge_change_log$ LANGUAGE sql;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00055(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	CREATE OR REPLACE FUNCTION purge_change_log(interval) RETURNS bigint AS $purge_change_log$
+		WITH deleted AS (
+			DELETE FROM change_log WHERE changed_at < CURRENT_TIMESTAMP - $1 RETURNING id
+		)
+		SELECT count(*) FROM deleted;
+	$purge_change_log$ LANGUAGE sql;
+
+	DROP FUNCTION delete_change_consumer(text);
+	DROP FUNCTION set_change_consumer(text, bigint);
+	DROP FUNCTION get_change_consumer(text);
+	DROP FUNCTION get_change_consumers();
+	DROP FUNCTION change_consumer_json(change_consumers);
+
+	DROP TABLE change_consumers;
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,251 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00056, down00056)
+}
+
+func up00056(tx *sql.Tx) error {
+	query := `-- Webhooks are delivered changes of the change log after their cursors in order
+DO $$ BEGIN
+	CREATE TABLE webhooks (
+		id uuid PRIMARY KEY,
+		url text NOT NULL,
+		secret text NOT NULL,
+		change_types change_types[],
+		reference_type_ids uuid[],
+		property_ids uuid[],
+		enabled boolean NOT NULL DEFAULT true,
+		last_change_id bigint NOT NULL,
+		failures integer NOT NULL DEFAULT 0,
+		next_attempt_at timestamp,
+		disabled_reason text,
+		created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE webhook_deliveries (
+		id bigserial PRIMARY KEY,
+		webhook_id uuid NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+		change_id bigint NOT NULL,
+		attempt integer NOT NULL,
+		status_code integer,
+		error text,
+		duration_ms integer NOT NULL,
+		delivered_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);
+	CREATE INDEX webhook_deliveries_delivered_at_idx ON webhook_deliveries (delivered_at);
+
+	-- The secret is not selected cause it is never responded
+	CREATE FUNCTION webhook_json(webhooks, boolean DEFAULT false) RETURNS json AS $webhook_json$
+		SELECT json_build_object(
+			'id', $1.id,
+			'url', $1.url,
+			'secret', CASE WHEN $2 THEN $1.secret END,
+			'change_types', $1.change_types,
+			'reference_type_ids', $1.reference_type_ids,
+			'property_ids', $1.property_ids,
+			'enabled', $1.enabled,
+			'last_change_id', $1.last_change_id,
+			'failures', $1.failures,
+			'next_attempt_at', $1.next_attempt_at::timestamptz,
+			'disabled_reason', $1.disabled_reason,
+			'created_at', $1.created_at::timestamptz,
+			'updated_at', $1.updated_at::timestamptz
+		);
+	$webhook_json$ LANGUAGE sql STABLE;
+
+	-- New webhooks are delivered changes logged after they are added
+	CREATE FUNCTION new_webhook(text, text, change_types[], uuid[], uuid[]) RETURNS uuid AS $new_webhook$
+		DECLARE
+			res uuid;
+		BEGIN
+			res := uuid_generate_v4();
+
+			INSERT INTO webhooks (id, url, secret, change_types, reference_type_ids, property_ids, last_change_id)
+			VALUES (res, $1, $2, $3, $4, $5, get_last_change_id());
+
+			RETURN res;
+		END;
+	$new_webhook$ LANGUAGE plpgsql;
+
+	-- The secret is kept if it is NULL, enabling resets failures of the disabled webhook
+	CREATE FUNCTION update_webhook(uuid, text, text, change_types[], uuid[], uuid[], boolean) RETURNS boolean AS $update_webhook$
+		WITH updated AS (
+			UPDATE webhooks SET
+				url = $2,
+				secret = COALESCE($3, secret),
+				change_types = $4,
+				reference_type_ids = $5,
+				property_ids = $6,
+				failures = CASE WHEN $7 AND NOT enabled THEN 0 ELSE failures END,
+				next_attempt_at = CASE WHEN $7 AND NOT enabled THEN NULL ELSE next_attempt_at END,
+				disabled_reason = CASE WHEN $7 THEN NULL ELSE disabled_reason END,
+				enabled = $7,
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+			RETURNING id
+		)
+		SELECT count(*) > 0 FROM updated;
+	$update_webhook$ LANGUAGE sql;
+
+	CREATE FUNCTION delete_webhook(uuid) RETURNS boolean AS $delete_webhook$
+		WITH deleted AS (
+			DELETE FROM webhooks WHERE id = $1 RETURNING id
+		)
+		SELECT count(*) > 0 FROM deleted;
+	$delete_webhook$ LANGUAGE sql;
+
+	CREATE FUNCTION get_webhooks() RETURNS SETOF json AS $get_webhooks$
+		SELECT webhook_json(w) FROM webhooks w ORDER BY w.created_at, w.id;
+	$get_webhooks$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION get_webhook(uuid) RETURNS json AS $get_webhook$
+		SELECT webhook_json(w) FROM webhooks w WHERE w.id = $1;
+	$get_webhook$ LANGUAGE sql STABLE;
+
+	-- Enabled webhooks which retries are due, with secrets for signing
+	CREATE FUNCTION get_due_webhooks() RETURNS SETOF json AS $get_due_webhooks$
+		SELECT webhook_json(w, true)
+		FROM webhooks w
+		WHERE w.enabled AND (w.next_attempt_at IS NULL OR w.next_attempt_at <= CURRENT_TIMESTAMP)
+		ORDER BY w.created_at, w.id;
+	$get_due_webhooks$ LANGUAGE sql STABLE;
+
+	-- The delivery is logged if the change ID is not NULL. The webhook is disabled with the reason
+	-- if it is not NULL, so webhooks disabled meanwhile are not enabled by the worker.
+	CREATE FUNCTION set_webhook_state(uuid, bigint, integer, timestamptz, text, bigint, integer, integer, text, integer) RETURNS void AS $set_webhook_state$
+		BEGIN
+			UPDATE webhooks SET
+				last_change_id = $2,
+				failures = $3,
+				next_attempt_at = $4,
+				enabled = enabled AND $5 IS NULL,
+				disabled_reason = COALESCE($5, disabled_reason)
+			WHERE id = $1;
+
+			IF $6 IS NOT NULL THEN
+				INSERT INTO webhook_deliveries (webhook_id, change_id, attempt, status_code, error, duration_ms)
+				VALUES ($1, $6, $7, $8, $9, $10);
+			END IF;
+		END;
+	$set_webhook_state$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION get_webhook_deliveries(uuid, integer DEFAULT 100) RETURNS SETOF json AS $get_webhook_deliveries$
+		SELECT
+			json_build_object(
+				'id', wd.id,
+				'webhook_id', wd.webhook_id,
+				'change_id', wd.change_id,
+				'attempt', wd.attempt,
+				'status_code', wd.status_code,
+				'error', wd.error,
+				'duration_ms', wd.duration_ms,
+				'delivered_at', wd.delivered_at::timestamptz
+			)
+		FROM webhook_deliveries wd
+		WHERE wd.webhook_id = $1
+		ORDER BY wd.id DESC
+		LIMIT $2;
+	$get_webhook_deliveries$ LANGUAGE sql STABLE;
+
+	CREATE FUNCTION purge_webhook_deliveries(interval) RETURNS bigint AS $purge_webhook_deliveries$
+		WITH deleted AS (
+			DELETE FROM webhook_deliveries WHERE delivered_at < CURRENT_TIMESTAMP - $1 RETURNING id
+		)
+		SELECT count(*) FROM deleted;
+	$purge_webhook_deliveries$ LANGUAGE sql;
+
+	-- Changes are filtered by types as well
+	DROP FUNCTION get_change_log(bigint, uuid[], uuid[], integer);
+
+	CREATE FUNCTION get_change_log(bigint, uuid[] DEFAULT NULL, uuid[] DEFAULT NULL, integer DEFAULT 100, change_types[] DEFAULT NULL) RETURNS SETOF json AS $get_change_log$
+		SELECT
+			json_build_object(
+				'id', cl.id,
+				'change_type', cl.change_type,
+				'reference_type_id', cl.reference_type_id,
+				'property_id', cl.property_id,
+				'record_id', cl.record_id,
+				'changed_at', cl.changed_at::timestamptz
+			)
+		FROM change_log cl
+		WHERE cl.id > $1
+			AND ($2 IS NULL OR cl.reference_type_id = ANY($2))
+			AND ($3 IS NULL OR cl.property_id = ANY($3))
+			AND ($5 IS NULL OR cl.change_type = ANY($5))
+		ORDER BY cl.id
+		LIMIT $4;
+	$get_change_log$ LANGUAGE sql STABLE;
+
+	-- Enabled webhooks keep changes they are not delivered yet
+	CREATE OR REPLACE FUNCTION purge_change_log(interval) RETURNS bigint AS $purge_change_log$
+		WITH deleted AS (
+			DELETE FROM change_log
+			WHERE changed_at < CURRENT_TIMESTAMP - $1
+				AND id <= COALESCE((SELECT min(last_change_id) FROM change_consumers), id)
+				AND id <= COALESCE((SELECT min(last_change_id) FROM webhooks WHERE enabled), id)
+			RETURNING id
+		)
+		SELECT count(*) FROM deleted;
+	$purge_change_log$ LANGUAGE sql;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00056(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	CREATE OR REPLACE FUNCTION purge_change_log(interval) RETURNS bigint AS $purge_change_log$
+		WITH deleted AS (
+			DELETE FROM change_log
+			WHERE changed_at < CURRENT_TIMESTAMP - $1
+				AND id <= COALESCE((SELECT min(last_change_id) FROM change_consumers), id)
+			RETURNING id
+		)
+		SELECT count(*) FROM deleted;
+	$purge_change_log$ LANGUAGE sql;
+
+	DROP FUNCTION get_change_log(bigint, uuid[], uuid[], integer, change_types[]);
+
+	CREATE FUNCTION get_change_log(bigint, uuid[] DEFAULT NULL, uuid[] DEFAULT NULL, integer DEFAULT 100) RETURNS SETOF json AS $get_change_log$
+		SELECT
+			json_build_object(
+				'id', cl.id,
+				'change_type', cl.change_type,
+				'reference_type_id', cl.reference_type_id,
+				'property_id', cl.property_id,
+				'record_id', cl.record_id,
+				'changed_at', cl.changed_at::timestamptz
+			)
+		FROM change_log cl
+		WHERE cl.id > $1
+			AND ($2 IS NULL OR cl.reference_type_id = ANY($2))
+			AND ($3 IS NULL OR cl.property_id = ANY($3))
+		ORDER BY cl.id
+		LIMIT $4;
+	$get_change_log$ LANGUAGE sql STABLE;
+
+	DROP FUNCTION purge_webhook_deliveries(interval);
+	DROP FUNCTION get_webhook_deliveries(uuid, integer);
+	DROP FUNCTION set_webhook_state(uuid, bigint, integer, timestamptz, text, bigint, integer, integer, text, integer);
+	DROP FUNCTION get_due_webhooks();
+	DROP FUNCTION get_webhook(uuid);
+	DROP FUNCTION get_webhooks();
+	DROP FUNCTION delete_webhook(uuid);
+	DROP FUNCTION update_webhook(uuid, text, text, change_types[], uuid[], uuid[], boolean);
+	DROP FUNCTION new_webhook(text, text, change_types[], uuid[], uuid[]);
+	DROP FUNCTION webhook_json(webhooks, boolean);
+
+	DROP TABLE webhook_deliveries;
+	DROP TABLE webhooks;
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,365 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00057, down00057)
+}
+
+func up00057(tx *sql.Tx) error {
+	query := `-- Access control lists of records
+DO $$ BEGIN
+	CREATE TYPE acl_grantee_types AS ENUM ('subject', 'group');
+	CREATE TYPE acl_accesses AS ENUM ('read', 'write');
+
+	CREATE TABLE record_acl (
+		record_id uuid NOT NULL REFERENCES records (id) ON DELETE CASCADE,
+		grantee_type acl_grantee_types NOT NULL,
+		grantee text NOT NULL,
+		access acl_accesses NOT NULL,
+		PRIMARY KEY (record_id, grantee_type, grantee)
+	);
+
+	-- Entries are ordered so the sum of the record does not depend on the order they are set in
+	CREATE FUNCTION record_acl_json(uuid) RETURNS json AS $record_acl_json$
+		SELECT json_agg(json_build_object(
+			'grantee_type', a.grantee_type,
+			'grantee', a.grantee,
+			'access', a.access
+		) ORDER BY a.grantee_type, a.grantee)
+		FROM record_acl a
+		WHERE a.record_id = $1;
+	$record_acl_json$ LANGUAGE sql STABLE;
+
+	-- The record without entries is readable by everyone, write entries allow reading too
+	CREATE FUNCTION record_acl_allows(uuid, text, text[], acl_accesses DEFAULT 'read') RETURNS boolean AS $record_acl_allows$
+		SELECT NOT EXISTS (SELECT FROM record_acl a WHERE a.record_id = $1)
+			OR EXISTS (
+				SELECT FROM record_acl a
+				WHERE a.record_id = $1
+					AND a.access >= $4
+					AND (
+						(a.grantee_type = 'subject' AND a.grantee = $2)
+						OR (a.grantee_type = 'group' AND a.grantee = ANY($3))
+					)
+			);
+	$record_acl_allows$ LANGUAGE sql STABLE;
+
+	-- The ACL is a part of the sum, so the record is sent again when its ACL is changed
+	CREATE OR REPLACE FUNCTION record_state_change() RETURNS TRIGGER AS $record_state_change$
+		DECLARE
+			acl json;
+		BEGIN
+			NEW."sum" = record_sum(NEW."name", NEW.description, NEW.deletion_mark);
+			acl := record_acl_json(NEW.id);
+			IF acl IS NOT NULL THEN
+				NEW."sum" = encode(sha256(convert_to(NEW."sum" || '|' || acl::text, 'UTF-8')), 'hex');
+			END IF;
+			NEW.change_at = CURRENT_TIMESTAMP;
+			RETURN NEW;
+		END;
+	$record_state_change$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION get_record_acls(uuid[]) RETURNS SETOF json AS $get_record_acls$
+		SELECT json_build_object('record_id', r.id, 'acl', record_acl_json(r.id))
+		FROM records r
+		WHERE r.id = ANY($1) AND EXISTS (SELECT FROM record_acl a WHERE a.record_id = r.id);
+	$get_record_acls$ LANGUAGE sql STABLE;
+
+	-- Replaces the ACL of the record by the array of entries, the update of the record
+	-- recalculates its sum and registers its change
+	CREATE FUNCTION set_record_acl(uuid, json) RETURNS json AS $set_record_acl$
+		BEGIN
+			PERFORM FROM records WHERE id = $1 FOR UPDATE;
+			IF NOT FOUND THEN
+				RETURN NULL;
+			END IF;
+
+			DELETE FROM record_acl WHERE record_id = $1;
+			INSERT INTO record_acl (record_id, grantee_type, grantee, access)
+			SELECT $1, (e->>'grantee_type')::acl_grantee_types, e->>'grantee', (e->>'access')::acl_accesses
+			FROM json_array_elements(COALESCE($2, '[]'::json)) e;
+
+			UPDATE records SET change_at = CURRENT_TIMESTAMP WHERE id = $1;
+			RETURN (SELECT get_record($1));
+		END;
+	$set_record_acl$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION get_record(uuid) RETURNS SETOF json AS $get_record$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', id,
+						'reference_type_id', reference_type_id,
+						'name', "name",
+						'description', description,
+						'deletion_mark', deletion_mark,
+						'sum', "sum",
+						'change_at', change_at::timestamptz,
+						'acl', record_acl_json(id)
+					)
+				FROM records
+				WHERE id = $1;
+		END;
+	$get_record$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION get_records(uuid[], boolean DEFAULT FALSE) RETURNS SETOF json AS $get_records$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', r.id,
+						'reference_type_id', r.reference_type_id,
+						'name', r."name",
+						'description', r.description,
+						'deletion_mark', r.deletion_mark,
+						'sum', r."sum",
+						'change_at', r.change_at::timestamptz,
+						'acl', record_acl_json(r.id),
+						'values', CASE WHEN $2 THEN COALESCE((
+							SELECT json_agg(json_build_object(
+								'owner_id', v.owner_id,
+								'property_id', v.property_id,
+								'type', v."type",
+								'reference_type_id', v.reference_type_id,
+								'value', v.value,
+								'sum', v."sum",
+								'change_at', v.change_at::timestamptz
+							) ORDER BY v.property_id)
+							FROM "values" v
+							WHERE v.owner_id = r.id
+						), '[]'::json) END
+					)
+				FROM records r
+				WHERE r.id = ANY($1);
+		END;
+	$get_records$ LANGUAGE plpgsql;
+
+	-- Records are filtered by their ACLs in the query so pages are not shortened by hidden records,
+	-- the NULL principal subject means no filter
+	DROP FUNCTION list_records(uuid, boolean, text, integer, integer);
+	CREATE FUNCTION list_records(
+		uuid DEFAULT NULL,
+		boolean DEFAULT NULL,
+		text DEFAULT NULL,
+		integer DEFAULT 100,
+		integer DEFAULT 0,
+		text DEFAULT NULL,
+		text[] DEFAULT NULL
+	) RETURNS SETOF json AS $list_records$
+		SELECT
+			json_build_object(
+				'id', r.id,
+				'reference_type_id', r.reference_type_id,
+				'name', r."name",
+				'description', r.description,
+				'deletion_mark', r.deletion_mark,
+				'sum', r."sum",
+				'change_at', r.change_at::timestamptz,
+				'acl', record_acl_json(r.id)
+			)
+		FROM records r
+		WHERE ($1 IS NULL OR r.reference_type_id = $1)
+			AND ($2 IS NULL OR r.deletion_mark = $2)
+			AND ($3 IS NULL OR position(lower($3) IN lower(r."name")) > 0)
+			AND ($6 IS NULL OR record_acl_allows(r.id, $6, $7))
+		ORDER BY r."name", r.id
+		LIMIT $4 OFFSET $5;
+	$list_records$ LANGUAGE sql STABLE;
+
+	CREATE OR REPLACE FUNCTION get_table_rows(uuid, boolean DEFAULT NULL, text DEFAULT NULL) RETURNS SETOF json AS $get_table_rows$
+		SELECT
+			json_build_object(
+				'id', r.id,
+				'reference_type_id', r.reference_type_id,
+				'name', r."name",
+				'description', r.description,
+				'deletion_mark', r.deletion_mark,
+				'sum', r."sum",
+				'change_at', r.change_at::timestamptz,
+				'acl', record_acl_json(r.id),
+				'values', COALESCE((
+					SELECT json_agg(json_build_object(
+						'owner_id', v.owner_id,
+						'property_id', v.property_id,
+						'type', v."type",
+						'reference_type_id', v.reference_type_id,
+						'value', v.value,
+						'sum', v."sum",
+						'change_at', v.change_at::timestamptz,
+						'ref_name', rr."name"
+					) ORDER BY v.property_id)
+					FROM "values" v
+					JOIN properties p ON p.id = v.property_id AND p.owner_reference_type_id = $1
+					LEFT JOIN records rr ON rr.id = CASE WHEN v."type" = 'ref' THEN (v.value->>'v')::uuid END
+					WHERE v.owner_id = r.id
+				), '[]'::json)
+			)
+		FROM records r
+		WHERE r.reference_type_id = $1
+			AND ($2 IS NULL OR r.deletion_mark = $2)
+			AND ($3 IS NULL OR position(lower($3) IN lower(r."name")) > 0)
+		ORDER BY r."name", r.id;
+	$get_table_rows$ LANGUAGE sql STABLE;
+
+	-- Dumps keep ACLs so they are restored by the import
+	CREATE OR REPLACE FUNCTION dump_records() RETURNS SETOF json AS $dump_records$
+		SELECT
+			json_build_object(
+				'id', id,
+				'reference_type_id', reference_type_id,
+				'name', "name",
+				'description', description,
+				'deletion_mark', deletion_mark,
+				'acl', record_acl_json(id)
+			)
+		FROM records
+		ORDER BY id;
+	$dump_records$ LANGUAGE sql STABLE;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00057(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	CREATE OR REPLACE FUNCTION dump_records() RETURNS SETOF json AS $dump_records$
+		SELECT
+			json_build_object(
+				'id', id,
+				'reference_type_id', reference_type_id,
+				'name', "name",
+				'description', description,
+				'deletion_mark', deletion_mark
+			)
+		FROM records
+		ORDER BY id;
+	$dump_records$ LANGUAGE sql STABLE;
+
+	CREATE OR REPLACE FUNCTION get_table_rows(uuid, boolean DEFAULT NULL, text DEFAULT NULL) RETURNS SETOF json AS $get_table_rows$
+		SELECT
+			json_build_object(
+				'id', r.id,
+				'reference_type_id', r.reference_type_id,
+				'name', r."name",
+				'description', r.description,
+				'deletion_mark', r.deletion_mark,
+				'sum', r."sum",
+				'change_at', r.change_at::timestamptz,
+				'values', COALESCE((
+					SELECT json_agg(json_build_object(
+						'owner_id', v.owner_id,
+						'property_id', v.property_id,
+						'type', v."type",
+						'reference_type_id', v.reference_type_id,
+						'value', v.value,
+						'sum', v."sum",
+						'change_at', v.change_at::timestamptz,
+						'ref_name', rr."name"
+					) ORDER BY v.property_id)
+					FROM "values" v
+					JOIN properties p ON p.id = v.property_id AND p.owner_reference_type_id = $1
+					LEFT JOIN records rr ON rr.id = CASE WHEN v."type" = 'ref' THEN (v.value->>'v')::uuid END
+					WHERE v.owner_id = r.id
+				), '[]'::json)
+			)
+		FROM records r
+		WHERE r.reference_type_id = $1
+			AND ($2 IS NULL OR r.deletion_mark = $2)
+			AND ($3 IS NULL OR position(lower($3) IN lower(r."name")) > 0)
+		ORDER BY r."name", r.id;
+	$get_table_rows$ LANGUAGE sql STABLE;
+
+	DROP FUNCTION list_records(uuid, boolean, text, integer, integer, text, text[]);
+	CREATE FUNCTION list_records(uuid DEFAULT NULL, boolean DEFAULT NULL, text DEFAULT NULL, integer DEFAULT 100, integer DEFAULT 0) RETURNS SETOF json AS $list_records$
+		SELECT
+			json_build_object(
+				'id', r.id,
+				'reference_type_id', r.reference_type_id,
+				'name', r."name",
+				'description', r.description,
+				'deletion_mark', r.deletion_mark,
+				'sum', r."sum",
+				'change_at', r.change_at::timestamptz
+			)
+		FROM records r
+		WHERE ($1 IS NULL OR r.reference_type_id = $1)
+			AND ($2 IS NULL OR r.deletion_mark = $2)
+			AND ($3 IS NULL OR position(lower($3) IN lower(r."name")) > 0)
+		ORDER BY r."name", r.id
+		LIMIT $4 OFFSET $5;
+	$list_records$ LANGUAGE sql STABLE;
+
+	CREATE OR REPLACE FUNCTION get_records(uuid[], boolean DEFAULT FALSE) RETURNS SETOF json AS $get_records$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', r.id,
+						'reference_type_id', r.reference_type_id,
+						'name', r."name",
+						'description', r.description,
+						'deletion_mark', r.deletion_mark,
+						'sum', r."sum",
+						'change_at', r.change_at::timestamptz,
+						'values', CASE WHEN $2 THEN COALESCE((
+							SELECT json_agg(json_build_object(
+								'owner_id', v.owner_id,
+								'property_id', v.property_id,
+								'type', v."type",
+								'reference_type_id', v.reference_type_id,
+								'value', v.value,
+								'sum', v."sum",
+								'change_at', v.change_at::timestamptz
+							) ORDER BY v.property_id)
+							FROM "values" v
+							WHERE v.owner_id = r.id
+						), '[]'::json) END
+					)
+				FROM records r
+				WHERE r.id = ANY($1);
+		END;
+	$get_records$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION get_record(uuid) RETURNS SETOF json AS $get_record$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', id,
+						'reference_type_id', reference_type_id,
+						'name', "name",
+						'description', description,
+						'deletion_mark', deletion_mark,
+						'sum', "sum",
+						'change_at', change_at::timestamptz
+					)
+				FROM records
+				WHERE id = $1;
+		END;
+	$get_record$ LANGUAGE plpgsql;
+
+	DROP FUNCTION set_record_acl(uuid, json);
+	DROP FUNCTION get_record_acls(uuid[]);
+
+	CREATE OR REPLACE FUNCTION record_state_change() RETURNS TRIGGER AS $record_state_change$
+		BEGIN
+			NEW."sum" = record_sum(NEW."name", NEW.description, NEW.deletion_mark);
+			NEW.change_at = CURRENT_TIMESTAMP;
+			RETURN NEW;
+		END;
+	$record_state_change$ LANGUAGE plpgsql;
+
+	DROP FUNCTION record_acl_allows(uuid, text, text[], acl_accesses);
+	DROP FUNCTION record_acl_json(uuid);
+	DROP TABLE record_acl;
+	DROP TYPE acl_accesses;
+	DROP TYPE acl_grantee_types;
+END $$;`
+	return execQuery(query, tx)
+}
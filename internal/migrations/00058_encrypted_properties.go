@@ -0,0 +1,358 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00058, down00058)
+}
+
+func up00058(tx *sql.Tx) error {
+	query := `-- Encrypted properties
+DO $$ BEGIN
+	ALTER TABLE properties ADD COLUMN encrypted boolean NOT NULL DEFAULT false;
+
+	-- Sequences and state machines are evaluated by the database and references are followed by it,
+	-- so their values can not be encrypted
+	CREATE FUNCTION properties_encryption_check_bw() RETURNS TRIGGER AS $properties_encryption_check_bw$
+		BEGIN
+			IF NOT NEW.encrypted THEN
+				RETURN NEW;
+			END IF;
+
+			IF NEW.kind <> 'regular'::property_kinds OR 'ref'::types = ANY(NEW."types") THEN
+				RAISE EXCEPTION 'encrypted property kind mismatch' USING DETAIL = 'KEYS(properties.kind, properties."types") VALUES(' || NEW.kind || ', {' || array_to_string(NEW."types", ', ') || '})';
+			END IF;
+
+			RETURN NEW;
+		END;
+	$properties_encryption_check_bw$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER t_properties_encryption_check_bw BEFORE INSERT OR UPDATE ON properties
+		FOR EACH ROW EXECUTE PROCEDURE properties_encryption_check_bw();
+
+	-- Values are encrypted by the service, so plain values of encrypted properties are rejected
+	CREATE FUNCTION values_encryption_check_bw() RETURNS TRIGGER AS $values_encryption_check_bw$
+		BEGIN
+			IF NOT NEW.value ? 'enc' AND EXISTS (SELECT FROM properties WHERE id = NEW.property_id AND encrypted) THEN
+				RAISE EXCEPTION 'value of encrypted property is not encrypted' USING DETAIL = 'KEYS("values".owner_id, "values".property_id) VALUES(' || NEW.owner_id || ', ' || NEW.property_id || ')';
+			END IF;
+
+			RETURN NEW;
+		END;
+	$values_encryption_check_bw$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER t_values_encryption_check_bw BEFORE INSERT OR UPDATE ON "values"
+		FOR EACH ROW EXECUTE PROCEDURE values_encryption_check_bw();
+
+	-- Envelopes are sealed with random keys, so the sum of the encrypted value is of the MAC of its plain value
+	CREATE OR REPLACE FUNCTION value_state_change() RETURNS TRIGGER AS $value_state_change$
+		BEGIN
+			NEW."sum" = encode(sha256(convert_to(
+				CASE WHEN NEW.value ? 'enc' THEN NEW.value->'enc'->>'mac' ELSE NEW.value::TEXT END
+				|| '::' || NEW."type" || COALESCE(NEW.reference_type_id::TEXT, ''), 'UTF-8')), 'hex');
+			NEW.change_at = CURRENT_TIMESTAMP;
+			RETURN NEW;
+		END;
+	$value_state_change$ LANGUAGE plpgsql;
+
+	DROP FUNCTION new_property(text, text, "types"[], uuid[], uuid, property_kinds, text, text, sequence_reset_periods, text[], text, json);
+	DROP FUNCTION insert_property(uuid, text, text, "types"[], uuid[], uuid, property_kinds, text, text, sequence_reset_periods, text[], text, json);
+
+	CREATE FUNCTION insert_property(
+		uuid,
+		text,
+		text,
+		"types"[],
+		uuid[] DEFAULT NULL,
+		uuid DEFAULT NULL,
+		property_kinds DEFAULT 'regular',
+		text DEFAULT NULL,
+		text DEFAULT NULL,
+		sequence_reset_periods DEFAULT NULL,
+		text[] DEFAULT NULL,
+		text DEFAULT NULL,
+		json DEFAULT NULL,
+		boolean DEFAULT false
+	) RETURNS uuid AS $insert_property$
+		DECLARE
+			tr json;
+		BEGIN
+			INSERT INTO properties (id, "name", description, "types", reference_type_ids, owner_reference_type_id, kind, encrypted)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $14);
+
+			IF $7 = 'sequence'::property_kinds THEN
+				IF COALESCE($9, '{prefix}{n}') !~ '\{n(:\d+)?\}' THEN
+					RAISE EXCEPTION 'sequence template without number placeholder' USING DETAIL = 'KEYS(property_sequences.template) VALUES(' || $9 || ')';
+				END IF;
+
+				INSERT INTO property_sequences (property_id, prefix, template, reset_period)
+				VALUES ($1, COALESCE($8, ''), COALESCE($9, '{prefix}{n}'), COALESCE($10, 'never'));
+			ELSIF $7::text = 'state_machine' THEN
+				IF $12 IS NULL OR NOT $12 = ANY($11) THEN
+					RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS(property_state_machines.initial) VALUES(' || COALESCE($12, 'NULL') || ')';
+				END IF;
+
+				INSERT INTO property_state_machines (property_id, states, initial)
+				VALUES ($1, $11, $12);
+
+				FOR tr IN SELECT * FROM json_array_elements(COALESCE($13, '[]'::json)) LOOP
+					IF NOT (tr->>'from') = ANY($11) OR NOT (tr->>'to') = ANY($11) THEN
+						RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS(state_transitions.from_state, state_transitions.to_state) VALUES(' || COALESCE(tr->>'from', 'NULL') || ', ' || COALESCE(tr->>'to', 'NULL') || ')';
+					END IF;
+
+					INSERT INTO state_transitions (property_id, from_state, to_state)
+					VALUES ($1, tr->>'from', tr->>'to')
+					ON CONFLICT DO NOTHING;
+				END LOOP;
+			END IF;
+
+			RETURN $1;
+		END;
+	$insert_property$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION new_property(
+		text,
+		text,
+		"types"[],
+		uuid[] DEFAULT NULL,
+		uuid DEFAULT NULL,
+		property_kinds DEFAULT 'regular',
+		text DEFAULT NULL,
+		text DEFAULT NULL,
+		sequence_reset_periods DEFAULT NULL,
+		text[] DEFAULT NULL,
+		text DEFAULT NULL,
+		json DEFAULT NULL,
+		boolean DEFAULT false
+	) RETURNS uuid AS $new_property$
+		BEGIN
+			RETURN insert_property(uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);
+		END;
+	$new_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION get_property(uuid) RETURNS SETOF json AS $get_property$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', id,
+						'name', "name",
+						'description', description,
+						'types', "types",
+						'reference_type_ids', reference_type_ids,
+						'owner_reference_type_id', owner_reference_type_id,
+						'kind', kind,
+						'sequence', property_sequence_json(id),
+						'state_machine', property_state_machine_json(id),
+						'encrypted', encrypted,
+						'sum', "sum",
+						'change_at', change_at::timestamptz
+					)
+				FROM properties
+				WHERE id = $1;
+		END;
+	$get_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION update_property(uuid, text, text) RETURNS SETOF json AS $update_property$
+		BEGIN
+			RETURN QUERY UPDATE properties SET
+				"name" = COALESCE($2, "name", $2),
+				description = COALESCE($3, description, $3)
+			WHERE id = $1
+			RETURNING json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'state_machine', property_state_machine_json(id),
+				'encrypted', encrypted,
+				'sum', "sum",
+				'change_at', change_at::timestamptz
+			);
+		END;
+	$update_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION dump_properties() RETURNS SETOF json AS $dump_properties$
+		SELECT
+			json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'state_machine', property_state_machine_json(id),
+				'encrypted', encrypted
+			)
+		FROM properties
+		ORDER BY id;
+	$dump_properties$ LANGUAGE sql STABLE;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00058(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	CREATE OR REPLACE FUNCTION dump_properties() RETURNS SETOF json AS $dump_properties$
+		SELECT
+			json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'state_machine', property_state_machine_json(id)
+			)
+		FROM properties
+		ORDER BY id;
+	$dump_properties$ LANGUAGE sql STABLE;
+
+	CREATE OR REPLACE FUNCTION update_property(uuid, text, text) RETURNS SETOF json AS $update_property$
+		BEGIN
+			RETURN QUERY UPDATE properties SET
+				"name" = COALESCE($2, "name", $2),
+				description = COALESCE($3, description, $3)
+			WHERE id = $1
+			RETURNING json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'state_machine', property_state_machine_json(id),
+				'sum', "sum",
+				'change_at', change_at::timestamptz
+			);
+		END;
+	$update_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION get_property(uuid) RETURNS SETOF json AS $get_property$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', id,
+						'name', "name",
+						'description', description,
+						'types', "types",
+						'reference_type_ids', reference_type_ids,
+						'owner_reference_type_id', owner_reference_type_id,
+						'kind', kind,
+						'sequence', property_sequence_json(id),
+						'state_machine', property_state_machine_json(id),
+						'sum', "sum",
+						'change_at', change_at::timestamptz
+					)
+				FROM properties
+				WHERE id = $1;
+		END;
+	$get_property$ LANGUAGE plpgsql;
+
+	DROP FUNCTION new_property(text, text, "types"[], uuid[], uuid, property_kinds, text, text, sequence_reset_periods, text[], text, json, boolean);
+	DROP FUNCTION insert_property(uuid, text, text, "types"[], uuid[], uuid, property_kinds, text, text, sequence_reset_periods, text[], text, json, boolean);
+
+	CREATE FUNCTION insert_property(
+		uuid,
+		text,
+		text,
+		"types"[],
+		uuid[] DEFAULT NULL,
+		uuid DEFAULT NULL,
+		property_kinds DEFAULT 'regular',
+		text DEFAULT NULL,
+		text DEFAULT NULL,
+		sequence_reset_periods DEFAULT NULL,
+		text[] DEFAULT NULL,
+		text DEFAULT NULL,
+		json DEFAULT NULL
+	) RETURNS uuid AS $insert_property$
+		DECLARE
+			tr json;
+		BEGIN
+			INSERT INTO properties (id, "name", description, "types", reference_type_ids, owner_reference_type_id, kind)
+			VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+			IF $7 = 'sequence'::property_kinds THEN
+				IF COALESCE($9, '{prefix}{n}') !~ '\{n(:\d+)?\}' THEN
+					RAISE EXCEPTION 'sequence template without number placeholder' USING DETAIL = 'KEYS(property_sequences.template) VALUES(' || $9 || ')';
+				END IF;
+
+				INSERT INTO property_sequences (property_id, prefix, template, reset_period)
+				VALUES ($1, COALESCE($8, ''), COALESCE($9, '{prefix}{n}'), COALESCE($10, 'never'));
+			ELSIF $7::text = 'state_machine' THEN
+				IF $12 IS NULL OR NOT $12 = ANY($11) THEN
+					RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS(property_state_machines.initial) VALUES(' || COALESCE($12, 'NULL') || ')';
+				END IF;
+
+				INSERT INTO property_state_machines (property_id, states, initial)
+				VALUES ($1, $11, $12);
+
+				FOR tr IN SELECT * FROM json_array_elements(COALESCE($13, '[]'::json)) LOOP
+					IF NOT (tr->>'from') = ANY($11) OR NOT (tr->>'to') = ANY($11) THEN
+						RAISE EXCEPTION 'unknown state' USING DETAIL = 'KEYS(state_transitions.from_state, state_transitions.to_state) VALUES(' || COALESCE(tr->>'from', 'NULL') || ', ' || COALESCE(tr->>'to', 'NULL') || ')';
+					END IF;
+
+					INSERT INTO state_transitions (property_id, from_state, to_state)
+					VALUES ($1, tr->>'from', tr->>'to')
+					ON CONFLICT DO NOTHING;
+				END LOOP;
+			END IF;
+
+			RETURN $1;
+		END;
+	$insert_property$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION new_property(
+		text,
+		text,
+		"types"[],
+		uuid[] DEFAULT NULL,
+		uuid DEFAULT NULL,
+		property_kinds DEFAULT 'regular',
+		text DEFAULT NULL,
+		text DEFAULT NULL,
+		sequence_reset_periods DEFAULT NULL,
+		text[] DEFAULT NULL,
+		text DEFAULT NULL,
+		json DEFAULT NULL
+	) RETURNS uuid AS $new_property$
+		BEGIN
+			RETURN insert_property(uuid_generate_v4(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+		END;
+	$new_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION value_state_change() RETURNS TRIGGER AS $value_state_change$
+		BEGIN
+			NEW."sum" = encode(sha256(convert_to(NEW.value::TEXT || '::' || NEW."type" || COALESCE(NEW.reference_type_id::TEXT, ''), 'UTF-8')), 'hex');
+			NEW.change_at = CURRENT_TIMESTAMP;
+			RETURN NEW;
+		END;
+	$value_state_change$ LANGUAGE plpgsql;
+
+	DROP TRIGGER t_values_encryption_check_bw ON "values";
+	DROP FUNCTION values_encryption_check_bw();
+
+	DROP TRIGGER t_properties_encryption_check_bw ON properties;
+	DROP FUNCTION properties_encryption_check_bw();
+
+	ALTER TABLE properties DROP COLUMN encrypted;
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,280 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00059, down00059)
+}
+
+func up00059(tx *sql.Tx) error {
+	query := `-- Masking policies of properties and the audit of unmasked reads
+DO $$ BEGIN
+	CREATE TYPE mask_kinds AS ENUM ('hide', 'partial', 'hash');
+
+	CREATE TABLE property_masks (
+		property_id uuid PRIMARY KEY REFERENCES properties (id) ON DELETE CASCADE,
+		kind mask_kinds NOT NULL,
+		reveal integer NOT NULL DEFAULT 0 CHECK (reveal >= 0),
+		unmasked_roles text[] NOT NULL DEFAULT '{}'
+	);
+
+	-- Reads are audited after the fact, so records of deleted properties and records are kept
+	CREATE TABLE unmasked_reads (
+		id bigserial PRIMARY KEY,
+		subject text NOT NULL,
+		roles text[] NOT NULL DEFAULT '{}',
+		record_id uuid NOT NULL,
+		property_id uuid NOT NULL,
+		read_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX unmasked_reads_property_id_idx ON unmasked_reads (property_id, id);
+	CREATE INDEX unmasked_reads_subject_idx ON unmasked_reads (subject, id);
+
+	-- Roles are ordered so the sum of the property does not depend on the order they are set in
+	CREATE FUNCTION property_mask_json(uuid) RETURNS json AS $property_mask_json$
+		SELECT json_build_object(
+			'kind', m.kind,
+			'reveal', m.reveal,
+			'unmasked_roles', ARRAY(SELECT unnest(m.unmasked_roles) ORDER BY 1)
+		)
+		FROM property_masks m
+		WHERE m.property_id = $1;
+	$property_mask_json$ LANGUAGE sql STABLE;
+
+	-- The mask is a part of the sum, so the property is sent again when its mask is changed
+	CREATE OR REPLACE FUNCTION property_state_change() RETURNS TRIGGER AS $property_state_change$
+		DECLARE
+			mask json;
+		BEGIN
+			NEW."sum" = property_sum(NEW."name", NEW.description);
+			mask := property_mask_json(NEW.id);
+			IF mask IS NOT NULL THEN
+				NEW."sum" = encode(sha256(convert_to(NEW."sum" || '|' || mask::text, 'UTF-8')), 'hex');
+			END IF;
+			NEW.change_at = CURRENT_TIMESTAMP;
+			RETURN NEW;
+		END;
+	$property_state_change$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION get_property_masks(uuid[]) RETURNS SETOF json AS $get_property_masks$
+		SELECT json_build_object('property_id', m.property_id, 'mask', property_mask_json(m.property_id))
+		FROM property_masks m
+		WHERE m.property_id = ANY($1);
+	$get_property_masks$ LANGUAGE sql STABLE;
+
+	-- Replaces the mask of the property, NULL removes it. The update of the property
+	-- recalculates its sum and registers its change
+	CREATE FUNCTION set_property_mask(uuid, json) RETURNS json AS $set_property_mask$
+		BEGIN
+			PERFORM FROM properties WHERE id = $1 FOR UPDATE;
+			IF NOT FOUND THEN
+				RETURN NULL;
+			END IF;
+
+			DELETE FROM property_masks WHERE property_id = $1;
+			IF $2 IS NOT NULL THEN
+				INSERT INTO property_masks (property_id, kind, reveal, unmasked_roles)
+				VALUES (
+					$1,
+					($2->>'kind')::mask_kinds,
+					COALESCE(($2->>'reveal')::integer, 0),
+					ARRAY(SELECT json_array_elements_text(COALESCE($2->'unmasked_roles', '[]'::json)))
+				);
+			END IF;
+
+			UPDATE properties SET change_at = CURRENT_TIMESTAMP WHERE id = $1;
+			RETURN (SELECT get_property($1));
+		END;
+	$set_property_mask$ LANGUAGE plpgsql;
+
+	CREATE FUNCTION register_unmasked_reads(json) RETURNS void AS $register_unmasked_reads$
+		INSERT INTO unmasked_reads (subject, roles, record_id, property_id)
+		SELECT
+			r->>'subject',
+			ARRAY(SELECT json_array_elements_text(COALESCE(r->'roles', '[]'::json))),
+			(r->>'record_id')::uuid,
+			(r->>'property_id')::uuid
+		FROM json_array_elements($1) r;
+	$register_unmasked_reads$ LANGUAGE sql;
+
+	CREATE FUNCTION get_unmasked_reads(bigint DEFAULT 0, uuid DEFAULT NULL, text DEFAULT NULL, integer DEFAULT 100) RETURNS SETOF json AS $get_unmasked_reads$
+		SELECT
+			json_build_object(
+				'id', u.id,
+				'subject', u.subject,
+				'roles', u.roles,
+				'record_id', u.record_id,
+				'property_id', u.property_id,
+				'read_at', u.read_at
+			)
+		FROM unmasked_reads u
+		WHERE u.id > $1
+			AND ($2 IS NULL OR u.property_id = $2)
+			AND ($3 IS NULL OR u.subject = $3)
+		ORDER BY u.id
+		LIMIT $4;
+	$get_unmasked_reads$ LANGUAGE sql STABLE;
+
+	CREATE OR REPLACE FUNCTION get_property(uuid) RETURNS SETOF json AS $get_property$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', id,
+						'name', "name",
+						'description', description,
+						'types', "types",
+						'reference_type_ids', reference_type_ids,
+						'owner_reference_type_id', owner_reference_type_id,
+						'kind', kind,
+						'sequence', property_sequence_json(id),
+						'state_machine', property_state_machine_json(id),
+						'encrypted', encrypted,
+						'mask', property_mask_json(id),
+						'sum', "sum",
+						'change_at', change_at::timestamptz
+					)
+				FROM properties
+				WHERE id = $1;
+		END;
+	$get_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION update_property(uuid, text, text) RETURNS SETOF json AS $update_property$
+		BEGIN
+			RETURN QUERY UPDATE properties SET
+				"name" = COALESCE($2, "name", $2),
+				description = COALESCE($3, description, $3)
+			WHERE id = $1
+			RETURNING json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'state_machine', property_state_machine_json(id),
+				'encrypted', encrypted,
+				'mask', property_mask_json(id),
+				'sum', "sum",
+				'change_at', change_at::timestamptz
+			);
+		END;
+	$update_property$ LANGUAGE plpgsql;
+
+	-- Dumps keep masks so they are restored by the import
+	CREATE OR REPLACE FUNCTION dump_properties() RETURNS SETOF json AS $dump_properties$
+		SELECT
+			json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'state_machine', property_state_machine_json(id),
+				'encrypted', encrypted,
+				'mask', property_mask_json(id)
+			)
+		FROM properties
+		ORDER BY id;
+	$dump_properties$ LANGUAGE sql STABLE;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00059(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	CREATE OR REPLACE FUNCTION dump_properties() RETURNS SETOF json AS $dump_properties$
+		SELECT
+			json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'state_machine', property_state_machine_json(id),
+				'encrypted', encrypted
+			)
+		FROM properties
+		ORDER BY id;
+	$dump_properties$ LANGUAGE sql STABLE;
+
+	CREATE OR REPLACE FUNCTION update_property(uuid, text, text) RETURNS SETOF json AS $update_property$
+		BEGIN
+			RETURN QUERY UPDATE properties SET
+				"name" = COALESCE($2, "name", $2),
+				description = COALESCE($3, description, $3)
+			WHERE id = $1
+			RETURNING json_build_object(
+				'id', id,
+				'name', "name",
+				'description', description,
+				'types', "types",
+				'reference_type_ids', reference_type_ids,
+				'owner_reference_type_id', owner_reference_type_id,
+				'kind', kind,
+				'sequence', property_sequence_json(id),
+				'state_machine', property_state_machine_json(id),
+				'encrypted', encrypted,
+				'sum', "sum",
+				'change_at', change_at::timestamptz
+			);
+		END;
+	$update_property$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION get_property(uuid) RETURNS SETOF json AS $get_property$
+		BEGIN
+			RETURN QUERY
+				SELECT
+					json_build_object(
+						'id', id,
+						'name', "name",
+						'description', description,
+						'types', "types",
+						'reference_type_ids', reference_type_ids,
+						'owner_reference_type_id', owner_reference_type_id,
+						'kind', kind,
+						'sequence', property_sequence_json(id),
+						'state_machine', property_state_machine_json(id),
+						'encrypted', encrypted,
+						'sum', "sum",
+						'change_at', change_at::timestamptz
+					)
+				FROM properties
+				WHERE id = $1;
+		END;
+	$get_property$ LANGUAGE plpgsql;
+
+	DROP FUNCTION get_unmasked_reads(bigint, uuid, text, integer);
+	DROP FUNCTION register_unmasked_reads(json);
+	DROP FUNCTION set_property_mask(uuid, json);
+	DROP FUNCTION get_property_masks(uuid[]);
+
+	CREATE OR REPLACE FUNCTION property_state_change() RETURNS TRIGGER AS $property_state_change$
+		BEGIN
+			NEW."sum" = property_sum(NEW."name", NEW.description);
+			NEW.change_at = CURRENT_TIMESTAMP;
+			RETURN NEW;
+		END;
+	$property_state_change$ LANGUAGE plpgsql;
+
+	DROP FUNCTION property_mask_json(uuid);
+	DROP TABLE unmasked_reads;
+	DROP TABLE property_masks;
+	DROP TYPE mask_kinds;
+END $$;`
+	return execQuery(query, tx)
+}
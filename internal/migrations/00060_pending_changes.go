@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00060, down00060)
+}
+
+func up00060(tx *sql.Tx) error {
+	query := `-- Count and age of registered changes which are not sent yet
+CREATE FUNCTION get_pending_changes() RETURNS json AS $get_pending_changes$
+	SELECT json_build_object('count', count(c.id), 'oldest_changed_at', min(cl.changed_at)::timestamptz)
+	FROM (
+		SELECT id FROM reference_type_changes
+		UNION ALL
+		SELECT id FROM property_changes
+		UNION ALL
+		SELECT id FROM record_changes
+		UNION ALL
+		SELECT id FROM value_changes
+	) c
+	LEFT JOIN change_log cl ON cl.id = c.id;
+$get_pending_changes$ LANGUAGE sql STABLE;`
+	return execQuery(query, tx)
+}
+
+func down00060(tx *sql.Tx) error {
+	query := `DROP FUNCTION get_pending_changes();`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00061, down00061)
+}
+
+func up00061(tx *sql.Tx) error {
+	query := `-- Trace contexts of changes, so changes are sent in the traces they are made in
+DO $$ BEGIN
+	ALTER TABLE change_log ADD COLUMN trace_parent text;
+
+	-- The setting is set by the service before queries, it is NULL for changes made by other clients
+	CREATE OR REPLACE FUNCTION log_change(bigint, change_types, uuid, uuid, uuid) RETURNS void AS $log_change$
+		BEGIN
+			INSERT INTO change_log (id, change_type, reference_type_id, property_id, record_id, trace_parent)
+			VALUES ($1, $2, $3, $4, $5, NULLIF(current_setting('datatom.trace_parent', true), ''));
+			PERFORM pg_notify('changes', $1::text);
+		END;
+	$log_change$ LANGUAGE plpgsql;
+
+	DROP FUNCTION get_changes();
+
+	CREATE FUNCTION get_changes() RETURNS TABLE (id bigint, change_type change_types, "key" json, trace_parent text) AS $get_changes$
+		BEGIN
+			RETURN QUERY
+				SELECT c.id, c.change_type, c."key", cl.trace_parent
+				FROM (
+					SELECT rtc.id AS id, 'ref_type'::change_types AS change_type, json_build_object('id', rtc.reference_type_id) AS "key"
+					FROM reference_type_changes rtc
+					UNION ALL
+					SELECT pc.id, 'property'::change_types, json_build_object('id', pc.property_id)
+					FROM property_changes pc
+					UNION ALL
+					SELECT rc.id, 'record'::change_types, json_build_object('id', rc.record_id)
+					FROM record_changes rc
+					UNION ALL
+					SELECT vc.id, 'value'::change_types, json_build_object('owner_id', vc.record_id, 'property_id', vc.property_id)
+					FROM value_changes vc
+				) c
+				LEFT JOIN change_log cl ON cl.id = c.id
+				ORDER BY c.id
+				LIMIT 5000;
+		END;
+	$get_changes$ LANGUAGE plpgsql;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00061(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	DROP FUNCTION get_changes();
+
+	CREATE FUNCTION get_changes() RETURNS TABLE (id bigint, change_type change_types, "key" json) AS $get_changes$
+		BEGIN
+			RETURN QUERY
+				SELECT rtc.id AS id, 'ref_type'::change_types AS change_type, json_build_object('id', rtc.reference_type_id) AS "key"
+				FROM reference_type_changes rtc
+				UNION ALL
+				SELECT pc.id, 'property'::change_types, json_build_object('id', pc.property_id)
+				FROM property_changes pc
+				UNION ALL
+				SELECT rc.id, 'record'::change_types, json_build_object('id', rc.record_id)
+				FROM record_changes rc
+				UNION ALL
+				SELECT vc.id, 'value'::change_types, json_build_object('owner_id', vc.record_id, 'property_id', vc.property_id)
+				FROM value_changes vc
+				ORDER BY id
+				LIMIT 5000;
+		END;
+	$get_changes$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION log_change(bigint, change_types, uuid, uuid, uuid) RETURNS void AS $log_change$
+		BEGIN
+			INSERT INTO change_log (id, change_type, reference_type_id, property_id, record_id)
+			VALUES ($1, $2, $3, $4, $5);
+			PERFORM pg_notify('changes', $1::text);
+		END;
+	$log_change$ LANGUAGE plpgsql;
+
+	ALTER TABLE change_log DROP COLUMN trace_parent;
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,94 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00062, down00062)
+}
+
+func up00062(tx *sql.Tx) error {
+	query := `-- Breadth-first walk of the record graph, every record is expanded once a level at most and the walk
+-- stops at 10000 hops or edges, so graphs with shared records do not enumerate all their paths
+DO $$ BEGIN
+	CREATE OR REPLACE FUNCTION get_record_graph(uuid, uuid[] DEFAULT NULL, int DEFAULT 3) RETURNS SETOF json AS $get_record_graph$
+		DECLARE
+			max_edges CONSTANT int := 10000;
+		BEGIN
+			IF NOT EXISTS (SELECT FROM records WHERE id = $1) THEN
+				RAISE EXCEPTION 'record not found' USING DETAIL = 'KEYS(records.id) VALUES(' || $1 || ')';
+			END IF;
+
+			RETURN QUERY
+				-- Records of the level are reached by their first path, records on the path are not walked again
+				WITH RECURSIVE walk(id, depth, "path") AS (
+					SELECT $1, 0, ARRAY[$1]
+					UNION ALL
+					SELECT h.id, h.depth, h."path"
+					FROM (
+						SELECT
+							(v.value->>'v')::uuid AS id,
+							w.depth + 1 AS depth,
+							w."path" || (v.value->>'v')::uuid AS "path",
+							row_number() OVER (PARTITION BY (v.value->>'v')::uuid ORDER BY w."path") AS n
+						FROM walk w
+						JOIN "values" v ON v.owner_id = w.id
+						WHERE w.depth < $3
+						AND v."type" = 'ref'::types
+						AND ($2 IS NULL OR v.property_id = ANY($2))
+						AND NOT (v.value->>'v')::uuid = ANY(w."path")
+					) h
+					WHERE h.n = 1
+				),
+				visited AS (
+					SELECT id, depth, "path" FROM walk LIMIT max_edges + 1
+				),
+				nodes AS (
+					SELECT DISTINCT ON (id) id, depth, "path" FROM visited ORDER BY id, depth, "path"
+				),
+				-- The edge is a cycle when it leads back to a record on the path from the root to its owner
+				edges AS (
+					SELECT o.depth, v.owner_id, v.property_id, t.id AS target_id, t.id = ANY(o."path") AS cycle
+					FROM nodes o
+					JOIN "values" v ON v.owner_id = o.id
+					JOIN nodes t ON t.id = (v.value->>'v')::uuid
+					WHERE o.depth < $3
+					AND v."type" = 'ref'::types
+					AND ($2 IS NULL OR v.property_id = ANY($2))
+					ORDER BY o.depth, v.owner_id, v.property_id
+					LIMIT max_edges + 1
+				)
+				SELECT json_build_object(
+					'nodes', (
+						SELECT json_agg(json_build_object(
+							'record_id', n.id,
+							'reference_type_id', r.reference_type_id,
+							'depth', n.depth
+						) ORDER BY n.depth, n.id)
+						FROM nodes n
+						LEFT JOIN records r ON r.id = n.id
+					),
+					'edges', COALESCE((
+						SELECT json_agg(json_build_object(
+							'from', e.owner_id,
+							'to', e.target_id,
+							'property_id', e.property_id,
+							'cycle', e.cycle
+						) ORDER BY e.owner_id, e.property_id)
+						FROM (SELECT * FROM edges ORDER BY depth, owner_id, property_id LIMIT max_edges) e
+					), '[]'::json),
+					'truncated', (SELECT count(*) FROM visited) > max_edges OR (SELECT count(*) FROM edges) > max_edges
+				);
+		END;
+	$get_record_graph$ LANGUAGE plpgsql STABLE;
+END $$;`
+	return execQuery(query, tx)
+}
+
+// The walk of all the paths is restored
+func down00062(tx *sql.Tx) error {
+	return up00047(tx)
+}
@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00063, down00063)
+}
+
+func up00063(tx *sql.Tx) error {
+	query := `-- Changes are read up to the horizon of commits, so changes committed later with lower IDs are not skipped
+DO $$ BEGIN
+	-- IDs of changes are taken from changed_data_id_seq before commit, a change with the lower ID may be committed
+	-- later than changes after it. Changes are registered after the data they are of is written, so transactions
+	-- which may still commit lower IDs have transaction IDs below horizon_xid, which is the transaction ID to be
+	-- assigned next when the change is logged. Changes logged before are NULL and are readable.
+	ALTER TABLE change_log ADD COLUMN horizon_xid xid8;
+
+	-- The statement takes the snapshot of its own, so it sees transaction IDs assigned up to the logging
+	CREATE OR REPLACE FUNCTION log_change(bigint, change_types, uuid, uuid, uuid) RETURNS void AS $log_change$
+		BEGIN
+			INSERT INTO change_log (id, change_type, reference_type_id, property_id, record_id, trace_parent, horizon_xid)
+			VALUES ($1, $2, $3, $4, $5, NULLIF(current_setting('datatom.trace_parent', true), ''), pg_snapshot_xmax(pg_current_snapshot()));
+			PERFORM pg_notify('changes', $1::text);
+		END;
+	$log_change$ LANGUAGE plpgsql;
+
+	-- The change is readable when no transaction below its horizon is in progress. Changes are read up to
+	-- the first change after the cursor which is not readable yet, whatever the filter is.
+	CREATE FUNCTION change_log_horizon(bigint) RETURNS bigint AS $change_log_horizon$
+		SELECT COALESCE(min(cl.id), 9223372036854775807)
+		FROM change_log cl
+		WHERE cl.id > $1 AND cl.horizon_xid > pg_snapshot_xmin(pg_current_snapshot());
+	$change_log_horizon$ LANGUAGE sql STABLE;
+
+	CREATE OR REPLACE FUNCTION get_change_log(bigint, uuid[] DEFAULT NULL, uuid[] DEFAULT NULL, integer DEFAULT 100, change_types[] DEFAULT NULL) RETURNS SETOF json AS $get_change_log$
+		SELECT
+			json_build_object(
+				'id', cl.id,
+				'change_type', cl.change_type,
+				'reference_type_id', cl.reference_type_id,
+				'property_id', cl.property_id,
+				'record_id', cl.record_id,
+				'changed_at', cl.changed_at::timestamptz
+			)
+		FROM change_log cl
+		WHERE cl.id > $1
+			AND cl.id < change_log_horizon($1)
+			AND ($2 IS NULL OR cl.reference_type_id = ANY($2))
+			AND ($3 IS NULL OR cl.property_id = ANY($3))
+			AND ($5 IS NULL OR cl.change_type = ANY($5))
+		ORDER BY cl.id
+		LIMIT $4;
+	$get_change_log$ LANGUAGE sql STABLE;
+
+	-- Reading of new changes starts below the horizon, so changes committed later with lower IDs are read as well
+	CREATE OR REPLACE FUNCTION get_last_change_id() RETURNS bigint AS $get_last_change_id$
+		SELECT COALESCE(max(cl.id), 0) FROM change_log cl WHERE cl.id < change_log_horizon(0);
+	$get_last_change_id$ LANGUAGE sql STABLE;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00063(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	CREATE OR REPLACE FUNCTION get_last_change_id() RETURNS bigint AS $get_last_change_id$
+		SELECT COALESCE(max(id), 0) FROM change_log;
+	$get_last_change_id$ LANGUAGE sql STABLE;
+
+	CREATE OR REPLACE FUNCTION get_change_log(bigint, uuid[] DEFAULT NULL, uuid[] DEFAULT NULL, integer DEFAULT 100, change_types[] DEFAULT NULL) RETURNS SETOF json AS $get_change_log$
+		SELECT
+			json_build_object(
+				'id', cl.id,
+				'change_type', cl.change_type,
+				'reference_type_id', cl.reference_type_id,
+				'property_id', cl.property_id,
+				'record_id', cl.record_id,
+				'changed_at', cl.changed_at::timestamptz
+			)
+		FROM change_log cl
+		WHERE cl.id > $1
+			AND ($2 IS NULL OR cl.reference_type_id = ANY($2))
+			AND ($3 IS NULL OR cl.property_id = ANY($3))
+			AND ($5 IS NULL OR cl.change_type = ANY($5))
+		ORDER BY cl.id
+		LIMIT $4;
+	$get_change_log$ LANGUAGE sql STABLE;
+
+	DROP FUNCTION change_log_horizon(bigint);
+
+	CREATE OR REPLACE FUNCTION log_change(bigint, change_types, uuid, uuid, uuid) RETURNS void AS $log_change$
+		BEGIN
+			INSERT INTO change_log (id, change_type, reference_type_id, property_id, record_id, trace_parent)
+			VALUES ($1, $2, $3, $4, $5, NULLIF(current_setting('datatom.trace_parent', true), ''));
+			PERFORM pg_notify('changes', $1::text);
+		END;
+	$log_change$ LANGUAGE plpgsql;
+
+	ALTER TABLE change_log DROP COLUMN horizon_xid;
+END $$;`
+	return execQuery(query, tx)
+}
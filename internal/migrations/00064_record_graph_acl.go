@@ -0,0 +1,101 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00064, down00064)
+}
+
+func up00064(tx *sql.Tx) error {
+	query := `-- The walk of the record graph by the subject and groups of the principal does not pass records
+-- hidden from the principal by their ACLs, so records reachable through hidden records only are not visited
+DO $$ BEGIN
+	DROP FUNCTION get_record_graph(uuid, uuid[], int);
+
+	CREATE FUNCTION get_record_graph(uuid, uuid[] DEFAULT NULL, int DEFAULT 3, text DEFAULT NULL, text[] DEFAULT NULL) RETURNS SETOF json AS $get_record_graph$
+		DECLARE
+			max_edges CONSTANT int := 10000;
+		BEGIN
+			IF NOT EXISTS (SELECT FROM records WHERE id = $1) THEN
+				RAISE EXCEPTION 'record not found' USING DETAIL = 'KEYS(records.id) VALUES(' || $1 || ')';
+			END IF;
+
+			RETURN QUERY
+				-- Records of the level are reached by their first path, records on the path are not walked again
+				WITH RECURSIVE walk(id, depth, "path") AS (
+					SELECT $1, 0, ARRAY[$1]
+					UNION ALL
+					SELECT h.id, h.depth, h."path"
+					FROM (
+						SELECT
+							(v.value->>'v')::uuid AS id,
+							w.depth + 1 AS depth,
+							w."path" || (v.value->>'v')::uuid AS "path",
+							row_number() OVER (PARTITION BY (v.value->>'v')::uuid ORDER BY w."path") AS n
+						FROM walk w
+						JOIN "values" v ON v.owner_id = w.id
+						WHERE w.depth < $3
+						AND v."type" = 'ref'::types
+						AND ($2 IS NULL OR v.property_id = ANY($2))
+						AND NOT (v.value->>'v')::uuid = ANY(w."path")
+						AND ($4 IS NULL OR record_acl_allows((v.value->>'v')::uuid, $4, $5))
+					) h
+					WHERE h.n = 1
+				),
+				visited AS (
+					SELECT id, depth, "path" FROM walk LIMIT max_edges + 1
+				),
+				nodes AS (
+					SELECT DISTINCT ON (id) id, depth, "path" FROM visited ORDER BY id, depth, "path"
+				),
+				-- The edge is a cycle when it leads back to a record on the path from the root to its owner
+				edges AS (
+					SELECT o.depth, v.owner_id, v.property_id, t.id AS target_id, t.id = ANY(o."path") AS cycle
+					FROM nodes o
+					JOIN "values" v ON v.owner_id = o.id
+					JOIN nodes t ON t.id = (v.value->>'v')::uuid
+					WHERE o.depth < $3
+					AND v."type" = 'ref'::types
+					AND ($2 IS NULL OR v.property_id = ANY($2))
+					ORDER BY o.depth, v.owner_id, v.property_id
+					LIMIT max_edges + 1
+				)
+				SELECT json_build_object(
+					'nodes', (
+						SELECT json_agg(json_build_object(
+							'record_id', n.id,
+							'reference_type_id', r.reference_type_id,
+							'depth', n.depth
+						) ORDER BY n.depth, n.id)
+						FROM nodes n
+						LEFT JOIN records r ON r.id = n.id
+					),
+					'edges', COALESCE((
+						SELECT json_agg(json_build_object(
+							'from', e.owner_id,
+							'to', e.target_id,
+							'property_id', e.property_id,
+							'cycle', e.cycle
+						) ORDER BY e.owner_id, e.property_id)
+						FROM (SELECT * FROM edges ORDER BY depth, owner_id, property_id LIMIT max_edges) e
+					), '[]'::json),
+					'truncated', (SELECT count(*) FROM visited) > max_edges OR (SELECT count(*) FROM edges) > max_edges
+				);
+		END;
+	$get_record_graph$ LANGUAGE plpgsql STABLE;
+END $$;`
+	return execQuery(query, tx)
+}
+
+// The walk without ACLs is restored
+func down00064(tx *sql.Tx) error {
+	query := `DROP FUNCTION get_record_graph(uuid, uuid[], int, text, text[]);`
+	if err := execQuery(query, tx); err != nil {
+		return err
+	}
+	return up00062(tx)
+}
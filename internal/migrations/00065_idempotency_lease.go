@@ -0,0 +1,100 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00065, down00065)
+}
+
+func up00065(tx *sql.Tx) error {
+	query := `-- Reservations of requests in progress are leased, so a retry takes over the reservation of the request
+-- which is not finished by the end of the lease, e.g. when the service crashed, instead of waiting for the key to expire
+DO $$ BEGIN
+	ALTER TABLE idempotency_keys ADD COLUMN locked_until timestamp;
+
+	DROP FUNCTION reserve_idempotency_key(text, text, text, interval);
+
+	-- Returns NULL if the key is reserved by the call and the stored response otherwise
+	CREATE FUNCTION reserve_idempotency_key(text, text, text, interval, interval) RETURNS json AS $reserve_idempotency_key$
+		DECLARE
+			k idempotency_keys%ROWTYPE;
+		BEGIN
+			DELETE FROM idempotency_keys WHERE "key" = $1 AND "scope" = $2 AND expires_at <= CURRENT_TIMESTAMP;
+
+			INSERT INTO idempotency_keys ("key", "scope", fingerprint, expires_at, locked_until)
+			VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4, CURRENT_TIMESTAMP + $5)
+			ON CONFLICT ("key", "scope") DO NOTHING;
+			IF FOUND THEN
+				RETURN NULL;
+			END IF;
+
+			-- The key is locked, so concurrent retries do not take over the reservation both
+			SELECT * INTO k FROM idempotency_keys WHERE "key" = $1 AND "scope" = $2 FOR UPDATE;
+			IF NOT FOUND THEN
+				RAISE EXCEPTION 'idempotency key in progress' USING DETAIL = 'KEYS(idempotency_keys.key, idempotency_keys.scope) VALUES(' || $1 || ', ' || $2 || ')';
+			END IF;
+			IF k.fingerprint <> $3 THEN
+				RAISE EXCEPTION 'idempotency key reused' USING DETAIL = 'KEYS(idempotency_keys.key, idempotency_keys.scope) VALUES(' || $1 || ', ' || $2 || ')';
+			END IF;
+			IF k.status IS NULL THEN
+				-- Keys reserved before leases have no lease and are taken over as well
+				IF k.locked_until > CURRENT_TIMESTAMP THEN
+					RAISE EXCEPTION 'idempotency key in progress' USING DETAIL = 'KEYS(idempotency_keys.key, idempotency_keys.scope) VALUES(' || $1 || ', ' || $2 || ')';
+				END IF;
+				UPDATE idempotency_keys SET locked_until = CURRENT_TIMESTAMP + $5 WHERE "key" = $1 AND "scope" = $2;
+				RETURN NULL;
+			END IF;
+
+			RETURN json_build_object(
+				'status', k.status,
+				'headers', k.headers,
+				'body', encode(k.body, 'base64')
+			);
+		END;
+	$reserve_idempotency_key$ LANGUAGE plpgsql;
+END $$;`
+	return execQuery(query, tx)
+}
+
+func down00065(tx *sql.Tx) error {
+	query := `
+DO $$ BEGIN
+	DROP FUNCTION reserve_idempotency_key(text, text, text, interval, interval);
+
+	CREATE FUNCTION reserve_idempotency_key(text, text, text, interval) RETURNS json AS $reserve_idempotency_key$
+		DECLARE
+			k idempotency_keys%ROWTYPE;
+		BEGIN
+			DELETE FROM idempotency_keys WHERE "key" = $1 AND "scope" = $2 AND expires_at <= CURRENT_TIMESTAMP;
+
+			INSERT INTO idempotency_keys ("key", "scope", fingerprint, expires_at)
+			VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4)
+			ON CONFLICT ("key", "scope") DO NOTHING;
+			IF FOUND THEN
+				RETURN NULL;
+			END IF;
+
+			SELECT * INTO k FROM idempotency_keys WHERE "key" = $1 AND "scope" = $2;
+			IF k.fingerprint <> $3 THEN
+				RAISE EXCEPTION 'idempotency key reused' USING DETAIL = 'KEYS(idempotency_keys.key, idempotency_keys.scope) VALUES(' || $1 || ', ' || $2 || ')';
+			END IF;
+			IF k.status IS NULL THEN
+				RAISE EXCEPTION 'idempotency key in progress' USING DETAIL = 'KEYS(idempotency_keys.key, idempotency_keys.scope) VALUES(' || $1 || ', ' || $2 || ')';
+			END IF;
+
+			RETURN json_build_object(
+				'status', k.status,
+				'headers', k.headers,
+				'body', encode(k.body, 'base64')
+			);
+		END;
+	$reserve_idempotency_key$ LANGUAGE plpgsql;
+
+	ALTER TABLE idempotency_keys DROP COLUMN locked_until;
+END $$;`
+	return execQuery(query, tx)
+}
@@ -0,0 +1,235 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/pkg/openapi"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// publicRoutes are served without credentials, e.g. to probes of orchestrators.
+var publicRoutes = map[string]bool{
+	"GET /openapi.json": true,
+	"GET /health/ping":  true,
+	"GET /health/info":  true,
+}
+
+// routePermission is the permission the route needs, scope sets the reference type the request is scoped by.
+type routePermission struct {
+	operation domain.Operation
+	access    domain.Access
+	scope     routeScope
+}
+
+// routeScope finds the reference type of the request by its URL parameters or its JSON body.
+type routeScope func(ctx context.Context, am *api.AuthManager, perm domain.Permission, rctx *chi.Context, body []byte) (domain.Permission, error)
+
+// routePermissions by methods and path templates of routes of all API versions. The server is not created
+// if any route is neither public nor listed here, so new routes are not left unprotected.
+var routePermissions = map[string]routePermission{
+	"POST /ref_type":       {domain.OperationRefTypes, domain.AccessWrite, nil},
+	"GET /ref_type/{id}":   {domain.OperationRefTypes, domain.AccessRead, refTypeParam("id")},
+	"PUT /ref_type/{id}":   {domain.OperationRefTypes, domain.AccessWrite, refTypeParam("id")},
+	"PATCH /ref_type/{id}": {domain.OperationRefTypes, domain.AccessWrite, refTypeParam("id")},
+
+	"POST /property":       {domain.OperationProperties, domain.AccessWrite, refTypeField("owner_reference_type_id")},
+	"GET /property/{id}":   {domain.OperationProperties, domain.AccessRead, propertyParam("id")},
+	"PUT /property/{id}":   {domain.OperationProperties, domain.AccessWrite, propertyParam("id")},
+	"PATCH /property/{id}": {domain.OperationProperties, domain.AccessWrite, propertyParam("id")},
+
+	"PUT /property/{id}/mask":    {domain.OperationProperties, domain.AccessWrite, propertyParam("id")},
+	"DELETE /property/{id}/mask": {domain.OperationProperties, domain.AccessWrite, propertyParam("id")},
+
+	"POST /record":                   {domain.OperationRecords, domain.AccessWrite, refTypeField("reference_type_id")},
+	"GET /record/{id}":               {domain.OperationRecords, domain.AccessRead, recordParam("id")},
+	"PUT /record/{id}":               {domain.OperationRecords, domain.AccessWrite, recordParam("id")},
+	"PATCH /record/{id}":             {domain.OperationRecords, domain.AccessWrite, recordParam("id")},
+	"GET /record/{id}/referenced_by": {domain.OperationRecords, domain.AccessRead, recordParam("id")},
+	"GET /record/{id}/graph":         {domain.OperationRecords, domain.AccessRead, recordParam("id")},
+	"GET /record/{id}/acl":           {domain.OperationRecords, domain.AccessRead, recordParam("id")},
+	"PUT /record/{id}/acl":           {domain.OperationRecords, domain.AccessWrite, recordParam("id")},
+
+	"PUT /value":                                       {domain.OperationValues, domain.AccessWrite, recordField("record_id")},
+	"GET /value/{record_id}/{property_id}":             {domain.OperationValues, domain.AccessRead, recordParam("record_id")},
+	"GET /value/{record_id}/{property_id}/transitions": {domain.OperationValues, domain.AccessRead, recordParam("record_id")},
+
+	// Batches, GraphQL requests and dumps may touch any reference type, mutations of GraphQL need the write access.
+	// Operations of batches are authorized by their reference types by the batch manager,
+	// objects GraphQL requests resolve and change are authorized by their reference types by the schema
+	"POST /batch":   {domain.OperationBatch, domain.AccessWrite, nil},
+	"POST /graphql": {domain.OperationGraphQL, domain.AccessRead, nil},
+	"GET /dump":     {domain.OperationDump, domain.AccessRead, nil},
+	"POST /dump":    {domain.OperationDump, domain.AccessWrite, nil},
+
+	"POST /import/csv/{ref_type_id}": {domain.OperationImport, domain.AccessWrite, refTypeParam("ref_type_id")},
+	"GET /export/{ref_type_id}":      {domain.OperationExport, domain.AccessRead, refTypeParam("ref_type_id")},
+
+	"GET /changes":                     {domain.OperationChanges, domain.AccessRead, nil},
+	"GET /changes/stream":              {domain.OperationChanges, domain.AccessRead, nil},
+	"GET /changes/ws":                  {domain.OperationChanges, domain.AccessRead, nil},
+	"GET /changes/consumers":           {domain.OperationChanges, domain.AccessRead, nil},
+	"GET /changes/consumers/{name}":    {domain.OperationChanges, domain.AccessRead, nil},
+	"PUT /changes/consumers/{name}":    {domain.OperationChanges, domain.AccessWrite, nil},
+	"DELETE /changes/consumers/{name}": {domain.OperationChanges, domain.AccessWrite, nil},
+
+	"POST /webhooks":                {domain.OperationWebhooks, domain.AccessWrite, nil},
+	"GET /webhooks":                 {domain.OperationWebhooks, domain.AccessRead, nil},
+	"GET /webhooks/{id}":            {domain.OperationWebhooks, domain.AccessRead, nil},
+	"PUT /webhooks/{id}":            {domain.OperationWebhooks, domain.AccessWrite, nil},
+	"DELETE /webhooks/{id}":         {domain.OperationWebhooks, domain.AccessWrite, nil},
+	"GET /webhooks/{id}/deliveries": {domain.OperationWebhooks, domain.AccessRead, nil},
+
+	"POST /dataway/tom":            {domain.OperationDataway, domain.AccessWrite, nil},
+	"GET /dataway/tom":             {domain.OperationDataway, domain.AccessRead, nil},
+	"POST /dataway/subscription":   {domain.OperationDataway, domain.AccessWrite, nil},
+	"DELETE /dataway/subscription": {domain.OperationDataway, domain.AccessWrite, nil},
+
+	"GET /audit/unmasked_reads": {domain.OperationAudit, domain.AccessRead, nil},
+}
+
+// checkRoutePermissions returns an error if any route of the router has no permission.
+func checkRoutePermissions(routes chi.Routes) error {
+	return chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		key := method + " " + openapi.PathTemplate(route)
+		if _, ok := routePermissions[key]; !ok && !publicRoutes[key] {
+			return fmt.Errorf("permission of route %s is not set", key)
+		}
+		return nil
+	})
+}
+
+// newAuthMiddleware authenticates requests and checks the permissions of their routes before they reach
+// handlers, the principal is passed to handlers by the context. Middlewares run before routing,
+// so the route is matched here. Nothing is checked while authentication is disabled.
+func newAuthMiddleware(s *server, routes chi.Routes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !s.authManager.Enabled() {
+				next.ServeHTTP(w, req)
+				return
+			}
+			path := strings.TrimSuffix(routePath(req), "/")
+			if path == "" {
+				path = "/"
+			}
+			rctx := chi.NewRouteContext()
+			if !routes.Match(rctx, req.Method, path) {
+				// Not found and not allowed methods are answered by the router
+				next.ServeHTTP(w, req)
+				return
+			}
+			key := req.Method + " " + openapi.PathTemplate(rctx.RoutePattern())
+			if publicRoutes[key] {
+				next.ServeHTTP(w, req)
+				return
+			}
+			res, p, err := handlers.Authenticate(req.Context(), s.authManager, handlers.AuthRequestSchema{
+				Authorization: req.Header.Get("Authorization"),
+				APIKey:        req.Header.Get("X-API-Key"),
+			})
+			if err != nil {
+				if res.Status == http.StatusInternalServerError {
+					s.logger.Errorf("authenticate error: %s", err)
+				} else {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+				}
+				s.problemResp(w, req, res.Status, err)
+				return
+			}
+			rp := routePermissions[key]
+			perm := domain.Permission{Operation: rp.operation, Access: rp.access}
+			if rp.scope != nil {
+				var body []byte
+				if req.Body != nil && req.Body != http.NoBody {
+					if body, err = io.ReadAll(req.Body); err != nil {
+						s.problemResp(w, req, http.StatusInternalServerError, nil)
+						s.logger.Errorf("read body error: %s", err)
+						return
+					}
+					req.Body = io.NopCloser(bytes.NewReader(body))
+				}
+				if perm, err = rp.scope(req.Context(), s.authManager, perm, rctx, body); err != nil {
+					s.problemResp(w, req, http.StatusInternalServerError, nil)
+					s.logger.Errorf("scope request error: %s", err)
+					return
+				}
+			}
+			res, err = handlers.Authorize(req.Context(), s.authManager, p, perm)
+			if err != nil {
+				if res.Status == http.StatusInternalServerError {
+					s.logger.Errorf("authorize error: %s", err)
+				}
+				s.problemResp(w, req, res.Status, err)
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(api.ContextWithPrincipal(req.Context(), p)))
+		})
+	}
+}
+
+// Requests with IDs which are not valid are not scoped, so they need grants for all reference types.
+
+func refTypeParam(name string) routeScope {
+	return func(_ context.Context, _ *api.AuthManager, perm domain.Permission, rctx *chi.Context, _ []byte) (domain.Permission, error) {
+		perm.RefTypeID, _ = uuid.Parse(rctx.URLParam(name))
+		return perm, nil
+	}
+}
+
+func recordParam(name string) routeScope {
+	return func(ctx context.Context, am *api.AuthManager, perm domain.Permission, rctx *chi.Context, _ []byte) (domain.Permission, error) {
+		id, err := uuid.Parse(rctx.URLParam(name))
+		if err != nil {
+			return perm, nil
+		}
+		return am.RecordPermission(ctx, perm.Operation, perm.Access, id)
+	}
+}
+
+func propertyParam(name string) routeScope {
+	return func(ctx context.Context, am *api.AuthManager, perm domain.Permission, rctx *chi.Context, _ []byte) (domain.Permission, error) {
+		id, err := uuid.Parse(rctx.URLParam(name))
+		if err != nil {
+			return perm, nil
+		}
+		return am.PropertyPermission(ctx, perm.Operation, perm.Access, id)
+	}
+}
+
+func refTypeField(name string) routeScope {
+	return func(_ context.Context, _ *api.AuthManager, perm domain.Permission, _ *chi.Context, body []byte) (domain.Permission, error) {
+		perm.RefTypeID, _ = uuid.Parse(bodyField(body, name))
+		return perm, nil
+	}
+}
+
+func recordField(name string) routeScope {
+	return func(ctx context.Context, am *api.AuthManager, perm domain.Permission, _ *chi.Context, body []byte) (domain.Permission, error) {
+		id, err := uuid.Parse(bodyField(body, name))
+		if err != nil {
+			return perm, nil
+		}
+		return am.RecordPermission(ctx, perm.Operation, perm.Access, id)
+	}
+}
+
+// bodyField returns the string field of the JSON object or an empty string, bodies are validated later.
+func bodyField(body []byte, name string) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return ""
+	}
+	var out string
+	_ = json.Unmarshal(fields[name], &out)
+	return out
+}
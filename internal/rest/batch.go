@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"datatom/internal/handlers"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+func newBatchHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
+			s.logger.Errorf("read body error: %s", err)
+			return
+		}
+		var schema handlers.BatchRequestSchema
+		if err := json.Unmarshal(b, &schema); err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
+			return
+		}
+		res, err := handlers.Batch(req.Context(), s.batchManager, schema)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("batch error: %s", err)
+			}
+			switch {
+			case res.Payload != nil:
+				s.jsonResp(w, res.Status, res.Payload)
+			default:
+				s.problemResp(w, req, res.Status, err)
+			}
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
@@ -0,0 +1,248 @@
+package rest
+
+import (
+	"context"
+	"datatom/internal/handlers"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/net/websocket"
+)
+
+// sseHeartbeatInterval keeps idle event streams from being closed by proxies.
+const sseHeartbeatInterval = time.Second * 15
+
+func changeStreamRequestSchema(req *http.Request) handlers.ChangeStreamRequestSchema {
+	q := req.URL.Query()
+	return handlers.ChangeStreamRequestSchema{
+		After:       q.Get("after"),
+		LastEventID: req.Header.Get("Last-Event-ID"),
+		RefTypeIDs:  q["reference_type_id"],
+		PropertyIDs: q["property_id"],
+	}
+}
+
+// sseWriter writes changes as server-sent events with IDs of the changes,
+// so a reconnected event source resumes the stream by the Last-Event-ID header.
+type sseWriter struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	mu     sync.Mutex
+	opened bool
+}
+
+func (sw *sseWriter) Open() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	h := sw.w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	sw.w.WriteHeader(http.StatusOK)
+	sw.opened = true
+	return sw.rc.Flush()
+}
+
+func (sw *sseWriter) WriteChange(c handlers.ChangeResponseSchema) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return sw.write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", c.ID, c.Type, data))
+}
+
+func (sw *sseWriter) heartbeat() error {
+	return sw.write(": ping\n\n")
+}
+
+func (sw *sseWriter) write(s string) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if _, err := sw.w.Write([]byte(s)); err != nil {
+		return err
+	}
+	return sw.rc.Flush()
+}
+
+func (sw *sseWriter) isOpened() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.opened
+}
+
+func newChangeStreamHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.liftDeadlines(w)
+		ctx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+		sw := &sseWriter{w: w, rc: http.NewResponseController(w)}
+		go func() {
+			ticker := time.NewTicker(sseHeartbeatInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if !sw.isOpened() {
+						continue
+					}
+					if err := sw.heartbeat(); err != nil {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+		res, err := handlers.StreamChanges(ctx, s.changeLogManager, changeStreamRequestSchema(req), sw)
+		if err != nil {
+			if sw.isOpened() {
+				// The client has gone if the context is done
+				if ctx.Err() == nil {
+					s.logger.Errorf("stream changes error: %s", err)
+				}
+				return
+			}
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("stream changes error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+		}
+	}
+}
+
+// wsWriter sends changes as JSON text frames, the connection is opened by the handshake.
+type wsWriter struct {
+	conn *websocket.Conn
+}
+
+func (ww wsWriter) Open() error {
+	return nil
+}
+
+func (ww wsWriter) WriteChange(c handlers.ChangeResponseSchema) error {
+	return websocket.JSON.Send(ww.conn, c)
+}
+
+func newChangeWebSocketHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		schema := changeStreamRequestSchema(req)
+		// The request is checked before the handshake, so errors are responded as problems
+		if _, err := schema.ChangeLogRequest(); err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, err)
+			return
+		}
+		s.liftDeadlines(w)
+		// Server instead of Handler accepts any origin as the rest of the API does
+		ws := websocket.Server{Handler: func(conn *websocket.Conn) {
+			ctx, cancel := context.WithCancel(req.Context())
+			defer cancel()
+			// Frames of the client are not expected, reading fails when the connection is closed
+			go func() {
+				defer cancel()
+				var msg []byte
+				for {
+					if err := websocket.Message.Receive(conn, &msg); err != nil {
+						return
+					}
+				}
+			}()
+			if _, err := handlers.StreamChanges(ctx, s.changeLogManager, schema, wsWriter{conn: conn}); err != nil && ctx.Err() == nil {
+				s.logger.Errorf("stream changes error: %s", err)
+			}
+		}}
+		ws.ServeHTTP(w, req)
+	}
+}
+
+func newChangeFeedHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		res, err := handlers.GetChangeFeed(req.Context(), s.changeLogManager, handlers.ChangeFeedRequestSchema{
+			After:       q.Get("after"),
+			Consumer:    q.Get("consumer"),
+			Limit:       q.Get("limit"),
+			RefTypeIDs:  q["reference_type_id"],
+			PropertyIDs: q["property_id"],
+		})
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("get change feed error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newGetChangeConsumersHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		res, err := handlers.GetChangeConsumers(req.Context(), s.changeLogManager)
+		if err != nil {
+			s.logger.Errorf("get change consumers error: %s", err)
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newGetChangeConsumerHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		res, err := handlers.GetChangeConsumer(req.Context(), s.changeLogManager, chi.URLParam(req, "name"))
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("get change consumer error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newSetChangeConsumerHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
+			s.logger.Errorf("read body error: %s", err)
+			return
+		}
+		var schema handlers.SetChangeConsumerRequestSchema
+		if err := json.Unmarshal(b, &schema); err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
+			return
+		}
+		schema.Name = chi.URLParam(req, "name")
+		res, err := handlers.SetChangeConsumer(req.Context(), s.changeLogManager, schema)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("set change consumer error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newDeleteChangeConsumerHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		res, err := handlers.DeleteChangeConsumer(req.Context(), s.changeLogManager, chi.URLParam(req, "name"))
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("delete change consumer error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.emptyResp(w, res.Status)
+	}
+}
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"datatom/internal/handlers"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Parts of the multipart form above the limit are stored in temporary files.
+const maxCSVImportMemory = 32 << 20
+
+// newImportCSVHandler takes the multipart form with the JSON mapping and the CSV file.
+func newImportCSVHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.liftDeadlines(w)
+		schema := handlers.CSVImportRequestSchema{RefTypeID: chi.URLParam(req, "ref_type_id")}
+		if v := req.URL.Query().Get("dry_run"); v != "" {
+			dryRun, err := strconv.ParseBool(v)
+			if err != nil {
+				s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("parse dry_run error: %s", err))
+				return
+			}
+			schema.DryRun = dryRun
+		}
+		if err := req.ParseMultipartForm(maxCSVImportMemory); err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("parse multipart form error: %s", err))
+			return
+		}
+		defer req.MultipartForm.RemoveAll()
+		if err := json.Unmarshal([]byte(req.FormValue("mapping")), &schema.Mapping); err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("mapping unmarshal error: %s", err))
+			return
+		}
+		f, _, err := req.FormFile("file")
+		if err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("CSV file error: %s", err))
+			return
+		}
+		defer f.Close()
+		res, err := handlers.ImportCSV(req.Context(), s.refTypeManager, s.propertyManager, s.importManager, schema, f)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("import CSV error: %s", err)
+			}
+			if res.Payload != nil {
+				s.jsonResp(w, res.Status, res.Payload)
+				return
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
@@ -12,15 +12,10 @@ func newRegisterTomHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		res, err := handlers.RegisterTom(req.Context(), s.dwGRPCConn, s.storedConfigsManager)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("register tom error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
 		s.textResp(w, res.Status, res.Payload)
@@ -31,15 +26,10 @@ func newGetTomIDHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		res, err := handlers.GetTomID(req.Context(), s.storedConfigsManager)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("get property error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
 		s.jsonResp(w, res.Status, res.Payload)
@@ -50,13 +40,13 @@ func newSubscribeHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.SubscribeSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
 			return
 		}
 		res, err := handlers.Subscribe(req.Context(), handlers.SubscribeRequest{
@@ -66,15 +56,10 @@ func newSubscribeHandler(s *server) http.HandlerFunc {
 			Schema:      schema,
 		})
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest, http.StatusMethodNotAllowed:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("add subscription error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
 		s.emptyResp(w, res.Status)
@@ -85,13 +70,13 @@ func newDeleteSubscriptionHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.SubscribeSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
 			return
 		}
 		res, err := handlers.DeleteSubscription(req.Context(), handlers.DeleteSubscriptionRequest{
@@ -101,15 +86,10 @@ func newDeleteSubscriptionHandler(s *server) http.HandlerFunc {
 			Schema:      schema,
 		})
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest, http.StatusMethodNotAllowed:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("delete subscription error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
 		s.emptyResp(w, res.Status)
@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// streamWriter sends the header before the first write only,
+// so the status can still be changed while nothing is exported.
+type streamWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	started     bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if !sw.started {
+		sw.w.Header().Set("Content-Type", sw.contentType)
+		sw.w.WriteHeader(http.StatusOK)
+		sw.started = true
+	}
+	return sw.w.Write(p)
+}
+
+// liftDeadlines removes the server deadlines for the dump which may be streamed long.
+func (s *server) liftDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		s.logger.Warnf("lift read deadline error: %s", err)
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Warnf("lift write deadline error: %s", err)
+	}
+}
+
+func newExportDumpHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.liftDeadlines(w)
+		sw := &streamWriter{w: w, contentType: "application/x-ndjson"}
+		res, err := handlers.ExportDump(req.Context(), s.dumpManager, sw)
+		if err != nil {
+			s.logger.Errorf("export dump error: %s", err)
+			if !sw.started {
+				s.problemResp(w, req, res.Status, err)
+			}
+			return
+		}
+		if !sw.started {
+			// Empty tom
+			if _, err := sw.Write(nil); err != nil {
+				s.errorHandler(err)
+			}
+		}
+	}
+}
+
+func newImportDumpHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.liftDeadlines(w)
+		var opts domain.ImportDumpOptions
+		if v := req.URL.Query().Get("register_changes"); v != "" {
+			registerChanges, err := strconv.ParseBool(v)
+			if err != nil {
+				s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("parse register_changes error: %s", err))
+				return
+			}
+			opts.RegisterChanges = registerChanges
+		}
+		res, err := handlers.ImportDump(req.Context(), s.dumpManager, req.Body, opts)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("import dump error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"datatom/internal/handlers"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// setETag sends the sum of the result as the strong entity tag.
+func setETag(w http.ResponseWriter, res handlers.Result) {
+	if res.Sum != "" {
+		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, res.Sum))
+	}
+}
+
+// ifMatch returns the sum expected by the If-Match header. The sum is empty if the header
+// is missing or is the wildcard so nothing has to be checked. Only one entity tag is supported
+// and a weak one never matches cause If-Match uses the strong comparison.
+func ifMatch(req *http.Request) (string, error) {
+	header := strings.TrimSpace(req.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return "", nil
+	}
+	if strings.Contains(header, ",") {
+		return "", fmt.Errorf("only one entity tag is supported by If-Match")
+	}
+	if strings.HasPrefix(header, "W/") {
+		// A quoted sum is never weak so the tag can not match
+		return header, nil
+	}
+	tag, ok := unquoteETag(header)
+	if !ok {
+		return "", fmt.Errorf("invalid entity tag %s of If-Match", header)
+	}
+	return tag, nil
+}
+
+// notModified reports whether the If-None-Match header matches the sum by the weak comparison.
+func notModified(req *http.Request, sum string) bool {
+	header := strings.TrimSpace(req.Header.Get("If-None-Match"))
+	if header == "" || sum == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if v, ok := unquoteETag(tag); ok && v == sum {
+			return true
+		}
+	}
+	return false
+}
+
+func unquoteETag(tag string) (string, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return "", false
+	}
+	return tag[1 : len(tag)-1], true
+}
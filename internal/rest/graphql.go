@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"datatom/internal/graphql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// newGraphQLHandler executes the GraphQL request of the JSON body. Errors of the request
+// are reported by the errors of the response, only malformed bodies get problems.
+func newGraphQLHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
+			s.logger.Errorf("read body error: %s", err)
+			return
+		}
+		var schema graphql.Request
+		if err := json.Unmarshal(b, &schema); err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
+			return
+		}
+		if schema.Query == "" {
+			s.problemResp(w, req, http.StatusBadRequest, errors.New("query is empty"))
+			return
+		}
+		payload, err := json.Marshal(s.graphQLSchema.Exec(req.Context(), schema))
+		if err != nil {
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
+			s.logger.Errorf("GraphQL response marshal error: %s", err)
+			return
+		}
+		s.jsonResp(w, http.StatusOK, payload)
+	}
+}
@@ -17,7 +17,8 @@ func newInfoHandler(s *server) http.HandlerFunc {
 		res, err := handlers.Info(s.appInfo)
 		if err != nil {
 			s.logger.Errorf("get app info error: %s", err)
-			s.emptyResp(w, res.Status)
+			s.problemResp(w, req, res.Status, err)
+			return
 		}
 		s.jsonResp(w, res.Status, res.Payload)
 	}
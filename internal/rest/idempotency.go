@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"io"
+	"net/http"
+)
+
+// replayedHeaders are stored with the response to the first request and sent to the repeated ones.
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// newIdempotentHandler processes a request with the Idempotency-Key header only once
+// and replays the response to it on retries. Requests without the header are passed as is.
+// Keys are scoped by paths under API versions, so retries by aliases at the root get the same response.
+func newIdempotentHandler(s *server, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, req)
+			return
+		}
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
+			s.logger.Errorf("read body error: %s", err)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(b))
+		schema := handlers.IdempotencyRequestSchema{
+			Key:    key,
+			Method: req.Method,
+			Path:   versionedPath(req),
+			Body:   b,
+		}
+		if p := api.PrincipalFromContext(req.Context()); p != nil {
+			schema.Subject = p.Subject
+		}
+		res, stored, err := handlers.ReserveIdempotencyKey(req.Context(), s.idempotencyManager, schema)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("reserve idempotency key error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		if stored != nil {
+			for k, v := range stored.Headers {
+				w.Header().Set(k, v)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			if err := writeResp(w, stored.Status, stored.Body); err != nil {
+				s.errorHandler(err)
+			}
+			return
+		}
+		// The key is released if the response is not stored, e.g. the handler panics,
+		// otherwise retries are rejected until the lease of the reservation ends
+		saved := false
+		defer func() {
+			if saved {
+				return
+			}
+			if err := handlers.ReleaseIdempotencyKey(context.Background(), s.idempotencyManager, schema); err != nil {
+				s.logger.Errorf("release idempotency key error: %s", err)
+			}
+		}()
+		rw := &recordingResponseWriter{ResponseWriter: w}
+		next(rw, req)
+		// The request context may be already done but the response has to be stored anyway
+		if err := handlers.SaveIdempotentResponse(context.Background(), s.idempotencyManager, schema, rw.response()); err != nil {
+			s.logger.Errorf("save idempotent response error: %s", err)
+			return
+		}
+		saved = true
+	}
+}
+
+// recordingResponseWriter keeps a copy of the response written through it.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingResponseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingResponseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *recordingResponseWriter) response() domain.IdempotentResponse {
+	out := domain.IdempotentResponse{
+		Status:  rw.status,
+		Headers: make(map[string]string, len(replayedHeaders)),
+		Body:    rw.body.Bytes(),
+	}
+	if out.Status == 0 {
+		out.Status = http.StatusOK
+	}
+	for _, k := range replayedHeaders {
+		if v := rw.Header().Get(k); v != "" {
+			out.Headers[k] = v
+		}
+	}
+	return out
+}
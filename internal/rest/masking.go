@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"datatom/internal/handlers"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newSetPropertyMaskHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
+			s.logger.Errorf("read body error: %s", err)
+			return
+		}
+		var schema handlers.SetPropertyMaskRequestSchema
+		if err := json.Unmarshal(b, &schema); err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
+			return
+		}
+		schema.ID = chi.URLParam(req, "id")
+		res, err := handlers.SetPropertyMask(req.Context(), s.propertyManager, schema)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("set property mask error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		setETag(w, res)
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newDeletePropertyMaskHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		res, err := handlers.DeletePropertyMask(req.Context(), s.propertyManager, chi.URLParam(req, "id"))
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("delete property mask error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		setETag(w, res)
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newGetUnmaskedReadsHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		res, err := handlers.GetUnmaskedReads(req.Context(), s.maskManager, handlers.UnmaskedReadsRequestSchema{
+			After:      q.Get("after"),
+			PropertyID: q.Get("property_id"),
+			Subject:    q.Get("subject"),
+			Limit:      q.Get("limit"),
+		})
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("get unmasked reads error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
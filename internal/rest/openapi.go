@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"bytes"
+	"datatom/internal/domain"
+	"datatom/pkg/openapi"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newOpenAPIHandler serves the document of the API version, it is checked against routes of the version by tests.
+func newOpenAPIHandler(s *server, document []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.jsonResp(w, http.StatusOK, document)
+	}
+}
+
+// newValidationMiddleware checks JSON bodies of requests by schemas of the OpenAPI document of the version
+// before they reach handlers. Middlewares run before routing, so the route is matched here.
+func newValidationMiddleware(s *server, routes chi.Routes, doc *openapi.Document) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			schema := requestBodySchema(routes, doc, req)
+			if schema == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				s.problemResp(w, req, http.StatusInternalServerError, nil)
+				s.logger.Errorf("read body error: %s", err)
+				return
+			}
+			req.Body = io.NopCloser(bytes.NewReader(b))
+			if err := schema.ValidateJSON(b); err != nil {
+				var vErr *openapi.ValidationError
+				if errors.As(err, &vErr) && vErr.Field != "" {
+					err = domain.NewFieldError(vErr.Field, err)
+				}
+				s.problemResp(w, req, http.StatusBadRequest, err)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// requestBodySchema returns the schema of the JSON body of the request operation or nil if there is nothing to check.
+func requestBodySchema(routes chi.Routes, doc *openapi.Document, req *http.Request) *openapi.Schema {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	path := strings.TrimSuffix(routePath(req), "/")
+	if path == "" {
+		path = "/"
+	}
+	rctx := chi.NewRouteContext()
+	if !routes.Match(rctx, req.Method, path) {
+		return nil
+	}
+	op := doc.Operation(openapi.PathTemplate(rctx.RoutePattern()), req.Method)
+	if op == nil {
+		return nil
+	}
+	return op.RequestBody.JSONSchema()
+}
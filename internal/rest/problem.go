@@ -0,0 +1,72 @@
+package rest
+
+import (
+	"datatom/internal/domain"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const problemTypePrefix = "urn:datatom:problem:"
+
+// problem is the error response of RFC 7807. Code is stable for clients, Field is the offending
+// field of the request and Details are keys and values of the cause reported by the database.
+type problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	Field    string            `json:"field,omitempty"`
+	Details  map[string]string `json:"details,omitempty"`
+}
+
+// newProblem describes the error, internals of server errors are not exposed.
+func newProblem(req *http.Request, status int, err error) problem {
+	out := problem{
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: req.URL.Path,
+	}
+	if status >= http.StatusInternalServerError || err == nil {
+		out.Code = statusCode(status)
+		out.Type = problemTypePrefix + out.Code
+		return out
+	}
+	out.Detail = err.Error()
+	out.Field = domain.ErrorField(err)
+	out.Details = domain.ErrorDetails(err)
+	out.Code = domain.ErrorCode(err)
+	if out.Code == "" {
+		if out.Field != "" {
+			out.Code = "invalid_field"
+		} else {
+			out.Code = statusCode(status)
+		}
+	}
+	out.Type = problemTypePrefix + out.Code
+	return out
+}
+
+// statusCode is the code of errors which are not domain ones, e.g. "bad_request".
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+func (s *server) problemResp(w http.ResponseWriter, req *http.Request, status int, err error) {
+	payload, mErr := json.Marshal(newProblem(req, status, err))
+	if mErr != nil {
+		s.logger.Errorf("problem marshal error: %s", mErr)
+		s.emptyResp(w, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	if err := writeResp(w, status, payload); err != nil {
+		s.errorHandler(err)
+	}
+}
@@ -14,26 +14,21 @@ func newAddPropertyHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.AddPropertyRequestSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
 			return
 		}
 		res, err := handlers.AddProperty(req.Context(), s.propertyManager, schema)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("add property error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
 		w.Header().Set("Location", fmt.Sprintf("%s/%s", req.URL.String(), res.Payload))
@@ -45,29 +40,33 @@ func newUpdPropertyHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.UpdPropertyRequestSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
 			return
 		}
 		schema.ID = chi.URLParam(req, "id")
+		sum, err := ifMatch(req)
+		if err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, err)
+			return
+		}
+		if sum != "" {
+			schema.ExpectedSum = sum
+		}
 		res, err := handlers.UpdateProperty(req.Context(), s.propertyManager, schema)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("update property error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
+		setETag(w, res)
 		s.emptyResp(w, res.Status)
 	}
 }
@@ -76,29 +75,33 @@ func newPatchPropertyHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.UpdPropertyRequestSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
 			return
 		}
 		schema.ID = chi.URLParam(req, "id")
+		sum, err := ifMatch(req)
+		if err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, err)
+			return
+		}
+		if sum != "" {
+			schema.ExpectedSum = sum
+		}
 		res, err := handlers.PatchProperty(req.Context(), s.propertyManager, schema)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("patch property error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
+		setETag(w, res)
 		s.jsonResp(w, res.Status, res.Payload)
 	}
 }
@@ -107,15 +110,15 @@ func newGetPropertyHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		res, err := handlers.GetProperty(req.Context(), s.propertyManager, chi.URLParam(req, "id"))
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("get property error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		setETag(w, res)
+		if notModified(req, res.Sum) {
+			s.emptyResp(w, http.StatusNotModified)
 			return
 		}
 		s.jsonResp(w, res.Status, res.Payload)
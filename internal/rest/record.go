@@ -14,26 +14,21 @@ func newAddRecordHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.AddRecordRequestSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
 			return
 		}
 		res, err := handlers.AddRecord(req.Context(), s.recordManager, schema)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("add record error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
 		w.Header().Set("Location", fmt.Sprintf("%s/%s", req.URL.String(), res.Payload))
@@ -45,29 +40,33 @@ func newUpdRecordHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.UpdRecordRequestSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
 			return
 		}
 		schema.ID = chi.URLParam(req, "id")
+		sum, err := ifMatch(req)
+		if err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, err)
+			return
+		}
+		if sum != "" {
+			schema.ExpectedSum = sum
+		}
 		res, err := handlers.UpdateRecord(req.Context(), s.recordManager, schema)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("update record error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
+		setETag(w, res)
 		s.emptyResp(w, res.Status)
 	}
 }
@@ -76,48 +75,147 @@ func newPatchRecordHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.UpdRecordRequestSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
 			return
 		}
 		schema.ID = chi.URLParam(req, "id")
+		sum, err := ifMatch(req)
+		if err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, err)
+			return
+		}
+		if sum != "" {
+			schema.ExpectedSum = sum
+		}
 		res, err := handlers.PatchRecord(req.Context(), s.recordManager, schema)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("patch record error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
+		setETag(w, res)
 		s.jsonResp(w, res.Status, res.Payload)
 	}
 }
 
 func newGetRecordHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		res, err := handlers.GetRecord(req.Context(), s.recordManager, chi.URLParam(req, "id"))
+		var res handlers.Result
+		var err error
+		query := req.URL.Query()
+		expand := handlers.ExpandRequestSchema{
+			Expand: query.Get("expand"),
+			Depth:  query.Get("depth"),
+		}
+		if expand.IsEmpty() {
+			res, err = handlers.GetRecord(req.Context(), s.recordManager, chi.URLParam(req, "id"))
+		} else {
+			res, err = handlers.GetExpandedRecord(req.Context(), s.recordManager, chi.URLParam(req, "id"), expand)
+		}
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("get record error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		setETag(w, res)
+		if notModified(req, res.Sum) {
+			s.emptyResp(w, http.StatusNotModified)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newGetReferencedByHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		schema := handlers.ReferencedByRequestSchema{
+			ID:         chi.URLParam(req, "id"),
+			PropertyID: query.Get("property_id"),
+			RefTypeID:  query.Get("reference_type_id"),
+			Limit:      query.Get("limit"),
+			Offset:     query.Get("offset"),
+		}
+		res, err := handlers.GetReferencedBy(req.Context(), s.recordManager, schema)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("get referenced by error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newGetRecordGraphHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		schema := handlers.GraphRequestSchema{
+			ID:          chi.URLParam(req, "id"),
+			PropertyIDs: query["property_id"],
+			Depth:       query.Get("depth"),
+		}
+		res, err := handlers.GetRecordGraph(req.Context(), s.recordManager, schema)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("get record graph error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newGetRecordACLHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		res, err := handlers.GetRecordACL(req.Context(), s.recordManager, chi.URLParam(req, "id"))
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("get record ACL error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		setETag(w, res)
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newSetRecordACLHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
+			s.logger.Errorf("read body error: %s", err)
+			return
+		}
+		var schema handlers.SetRecordACLRequestSchema
+		if err := json.Unmarshal(b, &schema); err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
+			return
+		}
+		schema.ID = chi.URLParam(req, "id")
+		res, err := handlers.SetRecordACL(req.Context(), s.recordManager, schema)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("set record ACL error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
+		setETag(w, res)
 		s.jsonResp(w, res.Status, res.Payload)
 	}
 }
@@ -14,26 +14,21 @@ func newAddRefTypeHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.AddRefTypeRequestSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
 			return
 		}
 		res, err := handlers.AddRefType(req.Context(), s.refTypeManager, schema)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("add reference type error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
 		w.Header().Set("Location", fmt.Sprintf("%s/%s", req.URL.String(), res.Payload))
@@ -45,29 +40,33 @@ func newUpdRefTypeHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.UpdRefTypeRequestSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
 			return
 		}
 		schema.ID = chi.URLParam(req, "id")
+		sum, err := ifMatch(req)
+		if err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, err)
+			return
+		}
+		if sum != "" {
+			schema.ExpectedSum = sum
+		}
 		res, err := handlers.UpdateRefType(req.Context(), s.refTypeManager, schema)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("update reference type error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
+		setETag(w, res)
 		s.emptyResp(w, res.Status)
 	}
 }
@@ -76,29 +75,33 @@ func newPatchRefTypeHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.UpdRefTypeRequestSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
 			return
 		}
 		schema.ID = chi.URLParam(req, "id")
+		sum, err := ifMatch(req)
+		if err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, err)
+			return
+		}
+		if sum != "" {
+			schema.ExpectedSum = sum
+		}
 		res, err := handlers.PatchRefType(req.Context(), s.refTypeManager, schema)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("patch reference type error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
+		setETag(w, res)
 		s.jsonResp(w, res.Status, res.Payload)
 	}
 }
@@ -107,15 +110,15 @@ func newGetRefTypeHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		res, err := handlers.GetRefType(req.Context(), s.refTypeManager, chi.URLParam(req, "id"))
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("get reference type error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		setETag(w, res)
+		if notModified(req, res.Sum) {
+			s.emptyResp(w, http.StatusNotModified)
 			return
 		}
 		s.jsonResp(w, res.Status, res.Payload)
@@ -4,6 +4,7 @@ import (
 	"datatom/internal"
 	"datatom/internal/api"
 	"datatom/internal/domain"
+	"datatom/internal/graphql"
 	"datatom/internal/grpc"
 	"datatom/pkg/log"
 	"fmt"
@@ -26,6 +27,7 @@ type server struct {
 	srv          *http.Server
 	errorHandler func(error)
 	timeout      time.Duration
+	routes       chi.Routes
 
 	dwGRPCConn *grpc.Connection
 	appInfo    internal.Info
@@ -35,6 +37,17 @@ type server struct {
 	propertyManager      *api.PropertyManager
 	valueManager         *api.ValueManager
 	storedConfigsManager *api.StoredConfigsManager
+	batchManager         *api.BatchManager
+	importManager        *api.ImportManager
+	dumpManager          *api.DumpManager
+	tableManager         *api.TableManager
+	idempotencyManager   *api.IdempotencyManager
+	changeLogManager     *api.ChangeLogManager
+	webhookManager       *api.WebhookManager
+	authManager          *api.AuthManager
+	maskManager          *api.MaskManager
+
+	graphQLSchema *graphql.Schema
 }
 
 func (s *server) Serve() error {
@@ -53,8 +66,22 @@ type Config struct {
 	PropertyManager      *api.PropertyManager
 	ValueManager         *api.ValueManager
 	StoredConfigsManager *api.StoredConfigsManager
+	BatchManager         *api.BatchManager
+	ImportManager        *api.ImportManager
+	DumpManager          *api.DumpManager
+	TableManager         *api.TableManager
+	IdempotencyManager   *api.IdempotencyManager
+	ChangeLogManager     *api.ChangeLogManager
+	WebhookManager       *api.WebhookManager
+	// AuthManager authenticates requests and authorizes them by routes, see routePermissions
+	AuthManager *api.AuthManager
+	MaskManager *api.MaskManager
 
 	DatawayGRPCConnection *grpc.Connection
+
+	// Deprecations of API versions by their names like v1,
+	// routes at the root are always deprecated and configured by the empty name.
+	Deprecations map[string]*Deprecation
 }
 
 func NewServer(c Config) (domain.Server, error) {
@@ -87,6 +114,33 @@ func NewServer(c Config) (domain.Server, error) {
 	if c.StoredConfigsManager == nil {
 		return nil, fmt.Errorf("stored configs manager must be not nil")
 	}
+	if c.BatchManager == nil {
+		return nil, fmt.Errorf("batch manager must be not nil")
+	}
+	if c.ImportManager == nil {
+		return nil, fmt.Errorf("import manager must be not nil")
+	}
+	if c.DumpManager == nil {
+		return nil, fmt.Errorf("dump manager must be not nil")
+	}
+	if c.TableManager == nil {
+		return nil, fmt.Errorf("table manager must be not nil")
+	}
+	if c.IdempotencyManager == nil {
+		return nil, fmt.Errorf("idempotency manager must be not nil")
+	}
+	if c.ChangeLogManager == nil {
+		return nil, fmt.Errorf("change log manager must be not nil")
+	}
+	if c.WebhookManager == nil {
+		return nil, fmt.Errorf("webhook manager must be not nil")
+	}
+	if c.AuthManager == nil {
+		return nil, fmt.Errorf("auth manager must be not nil")
+	}
+	if c.MaskManager == nil {
+		return nil, fmt.Errorf("mask manager must be not nil")
+	}
 	if c.Timeout == 0 {
 		c.Timeout = defaultHTTPServerTimeout
 	}
@@ -102,20 +156,63 @@ func NewServer(c Config) (domain.Server, error) {
 		propertyManager:      c.PropertyManager,
 		valueManager:         c.ValueManager,
 		storedConfigsManager: c.StoredConfigsManager,
+		batchManager:         c.BatchManager,
+		importManager:        c.ImportManager,
+		dumpManager:          c.DumpManager,
+		tableManager:         c.TableManager,
+		idempotencyManager:   c.IdempotencyManager,
+		changeLogManager:     c.ChangeLogManager,
+		webhookManager:       c.WebhookManager,
+		authManager:          c.AuthManager,
+		maskManager:          c.MaskManager,
+	}
+	out.graphQLSchema, err = graphql.NewSchema(graphql.Config{
+		RefTypeManager:  c.RefTypeManager,
+		RecordManager:   c.RecordManager,
+		PropertyManager: c.PropertyManager,
+		ValueManager:    c.ValueManager,
+		AuthManager:     c.AuthManager,
+		ErrorHandler: func(err error) {
+			l.Errorf("GraphQL error: %s", err)
+		},
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	router := chi.NewRouter()
+	router.Use(newTracingMiddleware())
+	router.Use(newMetricsMiddleware())
 	router.Use(mw.StripSlashes)
 	router.Use(mw.GetHead)
-	router.Use(mw.Timeout(out.timeout))
-
-	router.Mount("/health", healthRouter(out))
-	router.Mount("/ref_type", refTypeRouter(out))
-	router.Mount("/record", recordRouter(out))
-	router.Mount("/property", propertyRouter(out))
-	router.Mount("/value", valueRouter(out))
-	router.Mount("/dataway", datawayRouter(out))
+	router.NotFound(func(w http.ResponseWriter, req *http.Request) {
+		out.problemResp(w, req, http.StatusNotFound, nil)
+	})
+	router.MethodNotAllowed(func(w http.ResponseWriter, req *http.Request) {
+		out.problemResp(w, req, http.StatusMethodNotAllowed, nil)
+	})
+	for _, v := range apiVersions {
+		vr, err := out.versionRouter(v, c.Deprecations[v.name])
+		if err != nil {
+			return nil, err
+		}
+		router.Mount("/"+v.name, vr)
+	}
+	// Routes at the root are kept as aliases of the first version for clients which do not know versions
+	var legacy Deprecation
+	if d, ok := c.Deprecations[legacyAPIVersion]; ok && d != nil {
+		legacy = *d
+	}
+	if legacy.Successor == "" {
+		legacy.Successor = apiVersions[0].name
+	}
+	vr, err := out.versionRouter(apiVersions[0], &legacy)
+	if err != nil {
+		return nil, err
+	}
+	router.Mount("/", vr)
 
+	out.routes = router
 	out.srv = &http.Server{
 		Addr:         fmt.Sprintf(":%d", c.Port),
 		WriteTimeout: time.Second * 7,
@@ -126,6 +223,11 @@ func NewServer(c Config) (domain.Server, error) {
 	return out, nil
 }
 
+// Routes of the server.
+func (s *server) Routes() chi.Routes {
+	return s.routes
+}
+
 func healthRouter(s *server) *chi.Mux {
 	r := chi.NewRouter()
 	r.Get("/ping", newPingHandler(s))
@@ -135,7 +237,7 @@ func healthRouter(s *server) *chi.Mux {
 
 func refTypeRouter(s *server) *chi.Mux {
 	r := chi.NewRouter()
-	r.Post("/", newAddRefTypeHandler(s))
+	r.Post("/", newIdempotentHandler(s, newAddRefTypeHandler(s)))
 	r.Put(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newUpdRefTypeHandler(s))
 	r.Patch(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newPatchRefTypeHandler(s))
 	r.Get(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newGetRefTypeHandler(s))
@@ -144,25 +246,98 @@ func refTypeRouter(s *server) *chi.Mux {
 
 func recordRouter(s *server) *chi.Mux {
 	r := chi.NewRouter()
-	r.Post("/", newAddRecordHandler(s))
+	r.Post("/", newIdempotentHandler(s, newAddRecordHandler(s)))
 	r.Put(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newUpdRecordHandler(s))
 	r.Patch(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newPatchRecordHandler(s))
 	r.Get(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newGetRecordHandler(s))
+	r.Get(fmt.Sprintf("/{id:%s}/referenced_by", regexUUIDTemplate), newGetReferencedByHandler(s))
+	r.Get(fmt.Sprintf("/{id:%s}/graph", regexUUIDTemplate), newGetRecordGraphHandler(s))
+	r.Get(fmt.Sprintf("/{id:%s}/acl", regexUUIDTemplate), newGetRecordACLHandler(s))
+	r.Put(fmt.Sprintf("/{id:%s}/acl", regexUUIDTemplate), newSetRecordACLHandler(s))
 	return r
 }
 
 func propertyRouter(s *server) *chi.Mux {
 	r := chi.NewRouter()
-	r.Post("/", newAddPropertyHandler(s))
+	r.Post("/", newIdempotentHandler(s, newAddPropertyHandler(s)))
 	r.Put(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newUpdPropertyHandler(s))
 	r.Patch(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newPatchPropertyHandler(s))
 	r.Get(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newGetPropertyHandler(s))
+	r.Put(fmt.Sprintf("/{id:%s}/mask", regexUUIDTemplate), newSetPropertyMaskHandler(s))
+	r.Delete(fmt.Sprintf("/{id:%s}/mask", regexUUIDTemplate), newDeletePropertyMaskHandler(s))
 	return r
 }
 
 func valueRouter(s *server) *chi.Mux {
 	r := chi.NewRouter()
 	r.Put("/", newSetValueHandler(s))
+	r.Get(fmt.Sprintf("/{record_id:%s}/{property_id:%s}", regexUUIDTemplate, regexUUIDTemplate), newGetValueHandler(s))
+	r.Get(fmt.Sprintf("/{record_id:%s}/{property_id:%s}/transitions", regexUUIDTemplate, regexUUIDTemplate), newGetStateTransitionsHandler(s))
+	return r
+}
+
+func batchRouter(s *server) *chi.Mux {
+	r := chi.NewRouter()
+	r.Post("/", newBatchHandler(s))
+	return r
+}
+
+func graphQLRouter(s *server) *chi.Mux {
+	r := chi.NewRouter()
+	r.Post("/", newGraphQLHandler(s))
+	return r
+}
+
+func dumpRouter(s *server) *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/", newExportDumpHandler(s))
+	r.Post("/", newImportDumpHandler(s))
+	return r
+}
+
+func importRouter(s *server) *chi.Mux {
+	r := chi.NewRouter()
+	r.Post(fmt.Sprintf("/csv/{ref_type_id:%s}", regexUUIDTemplate), newImportCSVHandler(s))
+	return r
+}
+
+func exportRouter(s *server) *chi.Mux {
+	r := chi.NewRouter()
+	r.Get(fmt.Sprintf("/{ref_type_id:%s}", regexUUIDTemplate), newExportTableHandler(s))
+	return r
+}
+
+func changeRouter(s *server) *chi.Mux {
+	r := chi.NewRouter()
+	r.Group(func(r chi.Router) {
+		r.Use(mw.Timeout(s.timeout))
+
+		r.Get("/", newChangeFeedHandler(s))
+		r.Get("/consumers", newGetChangeConsumersHandler(s))
+		r.Get("/consumers/{name}", newGetChangeConsumerHandler(s))
+		r.Put("/consumers/{name}", newSetChangeConsumerHandler(s))
+		r.Delete("/consumers/{name}", newDeleteChangeConsumerHandler(s))
+	})
+	// Streams of changes last until clients disconnect
+	r.Get("/stream", newChangeStreamHandler(s))
+	r.Get("/ws", newChangeWebSocketHandler(s))
+	return r
+}
+
+func webhookRouter(s *server) *chi.Mux {
+	r := chi.NewRouter()
+	r.Post("/", newAddWebhookHandler(s))
+	r.Get("/", newGetWebhooksHandler(s))
+	r.Get(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newGetWebhookHandler(s))
+	r.Put(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newUpdWebhookHandler(s))
+	r.Delete(fmt.Sprintf("/{id:%s}", regexUUIDTemplate), newDeleteWebhookHandler(s))
+	r.Get(fmt.Sprintf("/{id:%s}/deliveries", regexUUIDTemplate), newGetWebhookDeliveriesHandler(s))
+	return r
+}
+
+func auditRouter(s *server) *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/unmasked_reads", newGetUnmaskedReadsHandler(s))
 	return r
 }
 
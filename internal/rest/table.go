@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"datatom/internal/handlers"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newExportTableHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.liftDeadlines(w)
+		q := req.URL.Query()
+		schema := handlers.TableRequestSchema{
+			RefTypeID:    chi.URLParam(req, "ref_type_id"),
+			Format:       q.Get("format"),
+			DeletionMark: q.Get("deletion_mark"),
+			Name:         q.Get("name"),
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, schema.FileName()))
+		sw := &streamWriter{w: w, contentType: schema.ContentType()}
+		res, err := handlers.ExportTable(req.Context(), s.tableManager, schema, sw)
+		if err != nil {
+			if sw.started {
+				s.logger.Errorf("export table error: %s", err)
+				return
+			}
+			w.Header().Del("Content-Disposition")
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("export table error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		if !sw.started {
+			if _, err := sw.Write(nil); err != nil {
+				s.errorHandler(err)
+			}
+		}
+	}
+}
@@ -6,34 +6,86 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func newSetValueHandler(s *server) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		b, err := io.ReadAll(req.Body)
 		if err != nil {
-			s.textResp(w, http.StatusInternalServerError, "read body error")
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
 			s.logger.Errorf("read body error: %s", err)
 			return
 		}
 		var schema handlers.SetValueRequestSchema
 		if err := json.Unmarshal(b, &schema); err != nil {
-			s.textResp(w, http.StatusBadRequest, fmt.Sprintf("body unmarshal error: %s", err))
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
+			return
+		}
+		sum, err := ifMatch(req)
+		if err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, err)
 			return
 		}
+		if sum != "" {
+			schema.ExpectedSum = sum
+		}
 		res, err := handlers.SetValue(req.Context(), s.valueManager, schema)
 		if err != nil {
-			switch res.Status {
-			case http.StatusBadRequest:
-				s.textResp(w, res.Status, err.Error())
-			case http.StatusInternalServerError:
+			if res.Status == http.StatusInternalServerError {
 				s.logger.Errorf("set value error: %s", err)
-				fallthrough
-			default:
-				s.emptyResp(w, res.Status)
 			}
+			s.problemResp(w, req, res.Status, err)
 			return
 		}
+		setETag(w, res)
 		s.emptyResp(w, res.Status)
 	}
 }
+
+func newGetStateTransitionsHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		schema := handlers.GetValueRequestSchema{
+			RecordID:   chi.URLParam(req, "record_id"),
+			PropertyID: chi.URLParam(req, "property_id"),
+		}
+		res, err := handlers.GetStateTransitions(req.Context(), s.valueManager, schema)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("get state transitions error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newGetValueHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		schema := handlers.GetValueRequestSchema{
+			RecordID:   chi.URLParam(req, "record_id"),
+			PropertyID: chi.URLParam(req, "property_id"),
+		}
+		expand := handlers.ExpandRequestSchema{
+			Expand: query.Get("expand"),
+			Depth:  query.Get("depth"),
+		}
+		res, err := handlers.GetValue(req.Context(), s.valueManager, s.recordManager, schema, expand)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("get value error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		setETag(w, res)
+		if notModified(req, res.Sum) {
+			s.emptyResp(w, http.StatusNotModified)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
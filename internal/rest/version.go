@@ -0,0 +1,136 @@
+package rest
+
+import (
+	"context"
+	"datatom/pkg/openapi"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	mw "github.com/go-chi/chi/v5/middleware"
+)
+
+// legacyAPIVersion is the name of routes at the root which are aliases of the first version.
+const legacyAPIVersion = ""
+
+//go:embed openapi_v1.json
+var openAPIDocumentV1 []byte
+
+// apiVersion of the REST API is mounted under /<name> side by side with other versions.
+// The document describes all its routes and is used to validate request bodies.
+type apiVersion struct {
+	name     string
+	document []byte
+	mount    func(s *server, r chi.Router)
+}
+
+// apiVersions in order of their release. A new version gets its own routers for changed
+// resources and may reuse routers of the former one for the rest.
+var apiVersions = []apiVersion{
+	{name: "v1", document: openAPIDocumentV1, mount: mountV1},
+}
+
+// Deprecation of the API version. The Deprecation header of RFC 9745 holds Since or true if it is not set,
+// the Sunset header of RFC 8594 is sent if Sunset is set and Successor is linked as the successor version.
+type Deprecation struct {
+	Since     time.Time
+	Sunset    time.Time
+	Successor string
+}
+
+// versionRouter returns routes of the version, d is nil for versions which are not deprecated.
+func (s *server) versionRouter(v apiVersion, d *Deprecation) (*chi.Mux, error) {
+	doc, err := openapi.Parse(v.document)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAPI document of %s error: %s", v.name, err)
+	}
+	r := chi.NewRouter()
+	r.Use(newVersionedPathMiddleware(v))
+	if d != nil {
+		r.Use(newDeprecationMiddleware(*d))
+	}
+	// Credentials are checked before bodies are
+	r.Use(newAuthMiddleware(s, r))
+	r.Use(newValidationMiddleware(s, r, doc))
+	r.Get("/openapi.json", newOpenAPIHandler(s, v.document))
+	v.mount(s, r)
+	if err := checkRoutePermissions(r); err != nil {
+		return nil, fmt.Errorf("routes of %s error: %s", v.name, err)
+	}
+	return r, nil
+}
+
+func mountV1(s *server, r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(mw.Timeout(s.timeout))
+
+		r.Mount("/health", healthRouter(s))
+		r.Mount("/ref_type", refTypeRouter(s))
+		r.Mount("/record", recordRouter(s))
+		r.Mount("/property", propertyRouter(s))
+		r.Mount("/value", valueRouter(s))
+		r.Mount("/dataway", datawayRouter(s))
+		r.Mount("/batch", batchRouter(s))
+		r.Mount("/graphql", graphQLRouter(s))
+		r.Mount("/webhooks", webhookRouter(s))
+		r.Mount("/audit", auditRouter(s))
+	})
+	// Dump, import and export are limited by timeouts of managers instead of the server one
+	r.Mount("/dump", dumpRouter(s))
+	r.Mount("/import", importRouter(s))
+	r.Mount("/export", exportRouter(s))
+	// Streams of changes are not limited by the server timeout, the rest of change routes are
+	r.Mount("/changes", changeRouter(s))
+}
+
+func newDeprecationMiddleware(d Deprecation) func(http.Handler) http.Handler {
+	deprecation := "true"
+	if !d.Since.IsZero() {
+		deprecation = "@" + strconv.FormatInt(d.Since.Unix(), 10)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Deprecation", deprecation)
+			if !d.Sunset.IsZero() {
+				w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if d.Successor != "" {
+				w.Header().Set("Link", fmt.Sprintf(`</%s%s>; rel="successor-version"`, d.Successor, routePath(req)))
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+type versionedPathContextKey struct{}
+
+// newVersionedPathMiddleware passes the path of the request under the API version by the context.
+// Routes at the root are aliases of the first version, so their paths are of it, e.g. /v1/record for /record.
+func newVersionedPathMiddleware(v apiVersion) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			path := "/" + v.name + strings.TrimSuffix(routePath(req), "/")
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), versionedPathContextKey{}, path)))
+		})
+	}
+}
+
+// versionedPath is the path of the request under its API version.
+func versionedPath(req *http.Request) string {
+	if path, ok := req.Context().Value(versionedPathContextKey{}).(string); ok {
+		return path
+	}
+	return req.URL.Path
+}
+
+// routePath is the path of the request within the router it is mounted to.
+func routePath(req *http.Request) string {
+	if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePath != "" {
+		return rctx.RoutePath
+	}
+	return req.URL.Path
+}
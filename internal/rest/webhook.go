@@ -0,0 +1,117 @@
+package rest
+
+import (
+	"datatom/internal/handlers"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newAddWebhookHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
+			s.logger.Errorf("read body error: %s", err)
+			return
+		}
+		var schema handlers.AddWebhookRequestSchema
+		if err := json.Unmarshal(b, &schema); err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
+			return
+		}
+		res, err := handlers.AddWebhook(req.Context(), s.webhookManager, schema)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("add webhook error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("%s/%s", req.URL.String(), res.Payload))
+		s.textResp(w, res.Status, res.Payload)
+	}
+}
+
+func newUpdWebhookHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			s.problemResp(w, req, http.StatusInternalServerError, nil)
+			s.logger.Errorf("read body error: %s", err)
+			return
+		}
+		var schema handlers.UpdWebhookRequestSchema
+		if err := json.Unmarshal(b, &schema); err != nil {
+			s.problemResp(w, req, http.StatusBadRequest, fmt.Errorf("body unmarshal error: %s", err))
+			return
+		}
+		schema.ID = chi.URLParam(req, "id")
+		res, err := handlers.UpdateWebhook(req.Context(), s.webhookManager, schema)
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("update webhook error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.emptyResp(w, res.Status)
+	}
+}
+
+func newDeleteWebhookHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		res, err := handlers.DeleteWebhook(req.Context(), s.webhookManager, chi.URLParam(req, "id"))
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("delete webhook error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.emptyResp(w, res.Status)
+	}
+}
+
+func newGetWebhooksHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		res, err := handlers.GetWebhooks(req.Context(), s.webhookManager)
+		if err != nil {
+			s.logger.Errorf("get webhooks error: %s", err)
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newGetWebhookHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		res, err := handlers.GetWebhook(req.Context(), s.webhookManager, chi.URLParam(req, "id"))
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("get webhook error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
+
+func newGetWebhookDeliveriesHandler(s *server) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		res, err := handlers.GetWebhookDeliveries(req.Context(), s.webhookManager, chi.URLParam(req, "id"), req.URL.Query().Get("limit"))
+		if err != nil {
+			if res.Status == http.StatusInternalServerError {
+				s.logger.Errorf("get webhook deliveries error: %s", err)
+			}
+			s.problemResp(w, req, res.Status, err)
+			return
+		}
+		s.jsonResp(w, res.Status, res.Payload)
+	}
+}
@@ -0,0 +1,58 @@
+package routines
+
+import (
+	"context"
+	"datatom/internal/api"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultDeliverWebhooksInterval = time.Second
+
+// DeliverWebhooksConfig of the worker. Webhooks are delivered when changes are notified
+// and every Interval, so retries are made when they are due.
+type DeliverWebhooksConfig struct {
+	Logger           *zap.SugaredLogger
+	WebhookManager   *api.WebhookManager
+	ChangeLogManager *api.ChangeLogManager
+	Interval         time.Duration
+}
+
+// DeliverWebhooks runs the worker until the context is done.
+func DeliverWebhooks(ctx context.Context, c DeliverWebhooksConfig) error {
+	if c.Interval == 0 {
+		c.Interval = defaultDeliverWebhooksInterval
+	}
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		changed := c.ChangeLogManager.Changed()
+		if err := c.WebhookManager.Deliver(ctx); err != nil && ctx.Err() == nil {
+			c.Logger.Errorln(err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+type PurgeWebhookDeliveriesConfig struct {
+	Logger         *zap.SugaredLogger
+	WebhookManager *api.WebhookManager
+}
+
+func purgeWebhookDeliveries(c PurgeWebhookDeliveriesConfig) error {
+	n, err := c.WebhookManager.PurgeDeliveries(context.Background())
+	if err != nil {
+		return fmt.Errorf("purge webhook deliveries error: %w", err)
+	}
+	if n > 0 {
+		c.Logger.Infof("%d logged webhook deliveries purged", n)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package routines
+
+import (
+	"context"
+	"datatom/pkg/metrics"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	sendingBatchSize = metrics.Factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "sending",
+		Name:      "batch_size",
+		Help:      "Changes read by runs of sending.",
+		Buckets:   []float64{0, 1, 10, 50, 100, 500, 1000, 5000},
+	})
+	sendingDuration = metrics.Factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "sending",
+		Name:      "duration_seconds",
+		Help:      "Duration of runs of sending.",
+		Buckets:   []float64{.01, .05, .1, .5, 1, 2.5, 5, 10, 30, 60},
+	})
+	sendingFailures = metrics.Factory.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "sending",
+		Name:      "failures_total",
+		Help:      "Runs of sending stopped by errors.",
+	})
+	pendingChanges = metrics.Factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "sending",
+		Name:      "pending_changes",
+		Help:      "Registered changes which are not sent yet.",
+	})
+	oldestPendingChangeAge = metrics.Factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "sending",
+		Name:      "oldest_pending_change_age_seconds",
+		Help:      "Age of the oldest change which is not sent yet, zero if all changes are sent.",
+	})
+)
+
+// observeSending measures the run of sending and the changes left after it.
+func observeSending(c SendChangedDataConfig, start time.Time, err error) {
+	sendingDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		sendingFailures.Inc()
+	}
+	pending, err := c.ChangedDataManager.Pending(context.Background())
+	if err != nil {
+		c.Logger.Errorf("get pending changes error: %s", err)
+		return
+	}
+	pendingChanges.Set(float64(pending.Count))
+	var age time.Duration
+	if !pending.OldestChangedAt.IsZero() {
+		age = time.Since(pending.OldestChangedAt)
+	}
+	oldestPendingChangeAge.Set(age.Seconds())
+}
@@ -0,0 +1,25 @@
+package routines
+
+import (
+	"context"
+	"datatom/internal/api"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+type PurgeChangeLogConfig struct {
+	Logger           *zap.SugaredLogger
+	ChangeLogManager *api.ChangeLogManager
+}
+
+func purgeChangeLog(c PurgeChangeLogConfig) error {
+	n, err := c.ChangeLogManager.Purge(context.Background())
+	if err != nil {
+		return fmt.Errorf("purge change log error: %w", err)
+	}
+	if n > 0 {
+		c.Logger.Infof("%d logged changes purged", n)
+	}
+	return nil
+}
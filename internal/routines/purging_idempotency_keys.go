@@ -0,0 +1,25 @@
+package routines
+
+import (
+	"context"
+	"datatom/internal/api"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+type PurgeIdempotencyKeysConfig struct {
+	Logger             *zap.SugaredLogger
+	IdempotencyManager *api.IdempotencyManager
+}
+
+func purgeIdempotencyKeys(c PurgeIdempotencyKeysConfig) error {
+	n, err := c.IdempotencyManager.Purge(context.Background())
+	if err != nil {
+		return fmt.Errorf("purge idempotency keys error: %w", err)
+	}
+	if n > 0 {
+		c.Logger.Infof("%d expired idempotency keys purged", n)
+	}
+	return nil
+}
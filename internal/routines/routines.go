@@ -1,8 +1,42 @@
 package routines
 
+import "time"
+
 func NewSendChangedDataRoutine(c SendChangedDataConfig) func() error {
 	return func() error {
+		start := time.Now()
 		err := sendChangedData(c)
+		observeSending(c, start, err)
+		if err != nil {
+			c.Logger.Errorln(err.Error())
+		}
+		return err
+	}
+}
+
+func NewPurgeIdempotencyKeysRoutine(c PurgeIdempotencyKeysConfig) func() error {
+	return func() error {
+		err := purgeIdempotencyKeys(c)
+		if err != nil {
+			c.Logger.Errorln(err.Error())
+		}
+		return err
+	}
+}
+
+func NewPurgeChangeLogRoutine(c PurgeChangeLogConfig) func() error {
+	return func() error {
+		err := purgeChangeLog(c)
+		if err != nil {
+			c.Logger.Errorln(err.Error())
+		}
+		return err
+	}
+}
+
+func NewPurgeWebhookDeliveriesRoutine(c PurgeWebhookDeliveriesConfig) func() error {
+	return func() error {
+		err := purgeWebhookDeliveries(c)
 		if err != nil {
 			c.Logger.Errorln(err.Error())
 		}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"datatom/internal/api"
 	"datatom/internal/domain"
+	"datatom/pkg/tracing"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"time"
 )
@@ -43,6 +46,7 @@ func sendChangedData(c SendChangedDataConfig) error {
 	if err != nil {
 		return fmt.Errorf("get changed data error: %w", err)
 	}
+	sendingBatchSize.Observe(float64(len(changes)))
 	last, errOut := processChanges(c, tomID, changes)
 	if errOut != nil {
 		last--
@@ -57,32 +61,48 @@ func sendChangedData(c SendChangedDataConfig) error {
 
 func processChanges(c SendChangedDataConfig, tomID uuid.UUID, changes []domain.ChangedData) (int, error) {
 	for i, change := range changes {
-		sender, err := newSender(c, tomID, change)
-		if err != nil {
-			return i, err
-		}
-		if err := executeSender(c.DBManager, sender); err != nil {
+		if err := processChange(c, tomID, change); err != nil {
 			return i, err
 		}
 	}
 	return len(changes) - 1, nil
 }
 
-func newSender(c SendChangedDataConfig, tomID uuid.UUID, change domain.ChangedData) (api.Sender, error) {
+// processChange sends the change in the trace it is made in, so the publishing is a part of the trace.
+func processChange(c SendChangedDataConfig, tomID uuid.UUID, change domain.ChangedData) (err error) {
+	ctx, span := tracing.Tracer().Start(
+		tracing.ContextWithTraceParent(context.Background(), change.TraceParent),
+		"send "+change.DataType.String(),
+		trace.WithAttributes(attribute.Int64("datatom.change.id", change.ID)),
+	)
+	defer func() { tracing.End(span, err) }()
+	sender, err := newSender(ctx, c, tomID, change)
+	if err != nil {
+		return err
+	}
+	return executeSender(ctx, c.DBManager, sender)
+}
+
+func newSender(ctx context.Context, c SendChangedDataConfig, tomID uuid.UUID, change domain.ChangedData) (api.Sender, error) {
 	switch change.DataType {
 	case domain.ChangedDataValue:
-		value, err := c.ValueManager.GetByKey(context.Background(), change.Key)
+		value, err := c.ValueManager.GetByKey(ctx, change.Key)
 		if err != nil {
 			return nil, fmt.Errorf("get changed value error: %s", err)
 		}
+		acls, err := c.RecordManager.GetACLs(ctx, []uuid.UUID{value.RecordID})
+		if err != nil {
+			return nil, fmt.Errorf("get ACL of changed value error: %s", err)
+		}
 		return c.ValueManager.GetSender(domain.SendValueRequest{
 			Value:       *value,
+			ACL:         acls[value.RecordID],
 			TomID:       tomID,
 			Exchange:    c.Exchange,
 			RoutingKeys: c.RoutingKeys,
 		}), nil
 	case domain.ChangedDataRecord:
-		record, err := c.RecordManager.GetByKey(context.Background(), change.Key)
+		record, err := c.RecordManager.GetByKey(ctx, change.Key)
 		if err != nil {
 			return nil, fmt.Errorf("get changed record error: %s", err)
 		}
@@ -93,7 +113,7 @@ func newSender(c SendChangedDataConfig, tomID uuid.UUID, change domain.ChangedDa
 			RoutingKeys: c.RoutingKeys,
 		}), nil
 	case domain.ChangedDataProperty:
-		property, err := c.PropertyManager.GetByKey(context.Background(), change.Key)
+		property, err := c.PropertyManager.GetByKey(ctx, change.Key)
 		if err != nil {
 			return nil, fmt.Errorf("get changed property error: %s", err)
 		}
@@ -104,7 +124,7 @@ func newSender(c SendChangedDataConfig, tomID uuid.UUID, change domain.ChangedDa
 			RoutingKeys: c.RoutingKeys,
 		}), nil
 	case domain.ChangedDataRefType:
-		refType, err := c.ReferenceTypeManager.GetByKey(context.Background(), change.Key)
+		refType, err := c.ReferenceTypeManager.GetByKey(ctx, change.Key)
 		if err != nil {
 			return nil, fmt.Errorf("get changed reference type error: %s", err)
 		}
@@ -119,8 +139,8 @@ func newSender(c SendChangedDataConfig, tomID uuid.UUID, change domain.ChangedDa
 	}
 }
 
-func executeSender(man *api.DBManager, sender api.Sender) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+func executeSender(ctx context.Context, man *api.DBManager, sender api.Sender) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 	tx, err := man.BeginTransaction(ctx)
 	if err != nil {
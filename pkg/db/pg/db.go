@@ -35,6 +35,8 @@ type Config struct {
 	DatabaseName string
 	SSLMode      SSLMode
 	PoolMaxConn  uint
+	// TraceChanges sets TraceParentSetting of connections before queries, it costs a round trip per acquire
+	TraceChanges bool
 }
 
 func NewConnConfig(c Config) (*pgx.ConnConfig, error) {
@@ -45,7 +47,15 @@ func NewPoolConfig(c Config) (*pgxpool.Config, error) {
 	if c.PoolMaxConn == 0 {
 		c.PoolMaxConn = 10
 	}
-	return pgxpool.ParseConfig(poolConnectionString(c))
+	out, err := pgxpool.ParseConfig(poolConnectionString(c))
+	if err != nil {
+		return nil, err
+	}
+	out.ConnConfig.Tracer = QueryTracer{}
+	if c.TraceChanges {
+		out.BeforeAcquire = setTraceParent
+	}
+	return out, nil
 }
 
 func connectionString(c Config) string {
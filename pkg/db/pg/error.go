@@ -15,6 +15,14 @@ func IsNotUniqueError(err error) bool {
 	return ok && pgErr.Code == "23505"
 }
 
+func IsForeignKeyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	pgErr, ok := err.(*pgconn.PgError)
+	return ok && pgErr.Code == "23503"
+}
+
 func IsNoRowsError(err error) bool {
 	return errors.Is(err, pgx.ErrNoRows)
 }
@@ -0,0 +1,71 @@
+package pg
+
+import (
+	"context"
+	"datatom/pkg/tracing"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceParentSetting of connections holds the traceparent of the query, so data changed by it,
+// e.g. changes registered by triggers, keep the trace the query is made in.
+const TraceParentSetting = "datatom.trace_parent"
+
+const setTraceParentQuery = `SELECT set_config('` + TraceParentSetting + `', $1, false);`
+
+// setTraceParent is called before connections are acquired from the pool. The setting is set on every acquire,
+// so connections do not keep traces of former queries.
+func setTraceParent(ctx context.Context, conn *pgx.Conn) bool {
+	_, err := conn.Exec(ctx, setTraceParentQuery, tracing.TraceParent(ctx))
+	return err == nil
+}
+
+// QueryTracer starts spans of queries, repositories call database functions,
+// so spans are named by the function the query calls.
+type QueryTracer struct{}
+
+// querySpanKey holds the span of the query, so spans of callers are not ended with queries which are not traced.
+type querySpanKey struct{}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if data.SQL == setTraceParentQuery {
+		return ctx
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "postgres "+queryOperation(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.statement", data.SQL),
+		),
+	)
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if span, ok := ctx.Value(querySpanKey{}).(trace.Span); ok {
+		tracing.End(span, data.Err)
+	}
+}
+
+// queryOperation is the name of the first function the query calls or its first word.
+func queryOperation(sql string) string {
+	if i := strings.IndexByte(sql, '('); i > 0 {
+		name := strings.TrimRightFunc(sql[:i], unicode.IsSpace)
+		if j := strings.LastIndexFunc(name, func(r rune) bool { return !isIdentRune(r) }); j < len(name)-1 {
+			return name[j+1:]
+		}
+	}
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "query"
+	}
+	return strings.ToUpper(fields[0])
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
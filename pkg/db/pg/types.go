@@ -3,6 +3,7 @@ package pg
 import (
 	"datatom/pkg/helper"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 func NullUUID(v uuid.UUID) uuid.NullUUID {
@@ -19,3 +20,7 @@ func ArrayUUID(in []uuid.UUID) [][16]byte {
 	}
 	return out
 }
+
+func NullString(v string) pgtype.Text {
+	return pgtype.Text{String: v, Valid: v != ""}
+}
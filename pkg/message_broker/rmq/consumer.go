@@ -29,7 +29,7 @@ func NewConsumer(c ConsumerConfig) *Consumer {
 	return &Consumer{
 		conn:    c.Conn,
 		queue:   c.Queue,
-		handler: c.Handler,
+		handler: instrumentHandler(c.Handler),
 		args:    c.QueueArgs,
 		l:       c.Logger,
 	}
@@ -0,0 +1,45 @@
+package rmq
+
+import (
+	"datatom/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	rmq "github.com/wagslane/go-rabbitmq"
+)
+
+var (
+	publishedMessages = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "broker",
+		Name:      "published_messages_total",
+		Help:      "Messages published by types of deliveries and results.",
+	}, []string{"type", "result"})
+	consumedMessages = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "broker",
+		Name:      "consumed_messages_total",
+		Help:      "Messages consumed by types of deliveries and actions taken on them.",
+	}, []string{"type", "action"})
+)
+
+func actionLabel(a rmq.Action) string {
+	switch a {
+	case rmq.Ack:
+		return "ack"
+	case rmq.NackDiscard:
+		return "nack_discard"
+	case rmq.NackRequeue:
+		return "nack_requeue"
+	default:
+		return "manual"
+	}
+}
+
+// instrumentHandler counts actions the handler takes on deliveries.
+func instrumentHandler(h rmq.Handler) rmq.Handler {
+	return func(d rmq.Delivery) rmq.Action {
+		action := h(d)
+		consumedMessages.WithLabelValues(d.Type, actionLabel(action)).Inc()
+		return action
+	}
+}
@@ -2,6 +2,7 @@ package rmq
 
 import (
 	"context"
+	"datatom/pkg/tracing"
 	"errors"
 	rmq "github.com/wagslane/go-rabbitmq"
 	"go.uber.org/zap"
@@ -16,6 +17,8 @@ type Publishing struct {
 	routingKeys []string
 	msg         []byte
 	opts        []func(*rmq.PublishOptions)
+	// options set by opts label metrics and spans of the publishing
+	options rmq.PublishOptions
 }
 
 type PublisherConfig struct {
@@ -48,14 +51,31 @@ func NewPublishing(p *Publisher, routingKeys []string, msg []byte, opts ...func(
 		routingKeys: routingKeys,
 		msg:         msg,
 		opts:        opts,
+		options:     publishingOptions(opts),
 	}
 }
 
+// Publish sends the message with the trace context of ctx in its headers.
 func (p *Publishing) Publish(ctx context.Context) error {
-	return p.p.PublishWithContext(
+	ctx, span := startPublishSpan(ctx, p.options, p.routingKeys)
+	err := p.p.PublishWithContext(
 		ctx,
 		p.msg,
 		p.routingKeys,
-		p.opts...,
+		p.tracedOpts(ctx)...,
 	)
+	tracing.End(span, err)
+	result := "published"
+	if err != nil {
+		result = "failed"
+	}
+	publishedMessages.WithLabelValues(p.options.Type, result).Inc()
+	return err
+}
+
+// tracedOpts are options of the publishing with headers carrying the trace context of ctx.
+func (p *Publishing) tracedOpts(ctx context.Context) []func(*rmq.PublishOptions) {
+	out := make([]func(*rmq.PublishOptions), 0, len(p.opts)+1)
+	out = append(out, p.opts...)
+	return append(out, rmq.WithPublishOptionsHeaders(tracedHeaders(ctx, p.options.Headers)))
 }
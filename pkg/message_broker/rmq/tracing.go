@@ -0,0 +1,79 @@
+package rmq
+
+import (
+	"context"
+	"datatom/pkg/tracing"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	rmq "github.com/wagslane/go-rabbitmq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const messagingSystem = "rabbitmq"
+
+// headersCarrier passes trace contexts in headers of messages.
+type headersCarrier amqp.Table
+
+func (hc headersCarrier) Get(key string) string {
+	v, _ := hc[key].(string)
+	return v
+}
+
+func (hc headersCarrier) Set(key, value string) {
+	hc[key] = value
+}
+
+func (hc headersCarrier) Keys() []string {
+	out := make([]string, 0, len(hc))
+	for k := range hc {
+		out = append(out, k)
+	}
+	return out
+}
+
+func publishingOptions(opts []func(*rmq.PublishOptions)) rmq.PublishOptions {
+	var out rmq.PublishOptions
+	for _, opt := range opts {
+		opt(&out)
+	}
+	return out
+}
+
+func startPublishSpan(ctx context.Context, o rmq.PublishOptions, routingKeys []string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, o.Type+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", messagingSystem),
+			attribute.String("messaging.destination.name", o.Exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", strings.Join(routingKeys, ",")),
+			attribute.String("messaging.message.type", o.Type),
+		),
+	)
+}
+
+// tracedHeaders returns a copy of headers with the trace context of ctx, so headers set by options are kept.
+func tracedHeaders(ctx context.Context, headers rmq.Table) rmq.Table {
+	out := make(rmq.Table, len(headers)+2)
+	for k, v := range headers {
+		out[k] = v
+	}
+	tracing.Inject(ctx, headersCarrier(out))
+	return out
+}
+
+// StartConsumeSpan starts the span of the delivery as a child of the trace context of its headers.
+func StartConsumeSpan(d rmq.Delivery) (context.Context, trace.Span) {
+	ctx := tracing.Extract(context.Background(), headersCarrier(d.Headers))
+	return tracing.Tracer().Start(ctx, d.Type+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", messagingSystem),
+			attribute.String("messaging.source.name", d.Exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", d.RoutingKey),
+			attribute.String("messaging.message.id", d.MessageId),
+			attribute.String("messaging.message.type", d.Type),
+		),
+	)
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes names of all metrics of the service.
+const Namespace = "datatom"
+
+const defaultPort = 9100
+
+var registry = prometheus.NewRegistry()
+
+// Factory registers metrics of packages in the registry of the service, so they are
+// declared where they are measured.
+var Factory = promauto.With(registry)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Register collectors which are created at runtime, e.g. ones of connection pools.
+func Register(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves all metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+type Config struct {
+	Port uint
+}
+
+// Server serves metrics at /metrics on its own port, so they are scraped without credentials of the API.
+type Server struct {
+	srv *http.Server
+}
+
+func NewServer(c Config) *Server {
+	if c.Port == 0 {
+		c.Port = defaultPort
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return &Server{
+		srv: &http.Server{
+			Addr:        fmt.Sprintf(":%d", c.Port),
+			Handler:     mux,
+			ReadTimeout: time.Second * 5,
+			IdleTimeout: time.Second * 10,
+		},
+	}
+}
+
+func (s *Server) Serve() error {
+	return s.srv.ListenAndServe()
+}
@@ -0,0 +1,220 @@
+// Package openapi reads OpenAPI 3 documents and validates JSON values by their schemas.
+// Only the subset of the specification which is used to describe the service is supported.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	ContentTypeJSON = "application/json"
+
+	componentSchemaRefPrefix = "#/components/schemas/"
+)
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Put    *Operation `json:"put,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Patch  *Operation `json:"patch,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+}
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Tags        []string            `json:"tags"`
+	Parameters  []Parameter         `json:"parameters"`
+	RequestBody *RequestBody        `json:"requestBody"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description"`
+	Required    bool                 `json:"required"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Parse reads the document and resolves references to schemas of components.
+func Parse(b []byte) (*Document, error) {
+	var out Document
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("document unmarshal error: %s", err)
+	}
+	r := resolver{doc: &out, visited: make(map[*Schema]struct{})}
+	for _, s := range out.Components.Schemas {
+		if err := r.resolve(s); err != nil {
+			return nil, err
+		}
+	}
+	for path, item := range out.Paths {
+		for method, op := range item.Operations() {
+			if err := r.resolveOperation(op); err != nil {
+				return nil, fmt.Errorf("%s %s: %s", method, path, err)
+			}
+		}
+	}
+	return &out, nil
+}
+
+// Operations of the path by HTTP methods.
+func (pi PathItem) Operations() map[string]*Operation {
+	out := make(map[string]*Operation)
+	for method, op := range map[string]*Operation{
+		http.MethodGet:    pi.Get,
+		http.MethodPut:    pi.Put,
+		http.MethodPost:   pi.Post,
+		http.MethodPatch:  pi.Patch,
+		http.MethodDelete: pi.Delete,
+	} {
+		if op != nil {
+			out[method] = op
+		}
+	}
+	return out
+}
+
+// Operation returns the operation of the path template like /record/{id} or nil if it is not described.
+func (d *Document) Operation(path, method string) *Operation {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil
+	}
+	return item.Operations()[strings.ToUpper(method)]
+}
+
+// JSONSchema of the request body, it is nil if the operation takes no JSON.
+func (rb *RequestBody) JSONSchema() *Schema {
+	if rb == nil {
+		return nil
+	}
+	return rb.Content[ContentTypeJSON].Schema
+}
+
+type resolver struct {
+	doc     *Document
+	visited map[*Schema]struct{}
+}
+
+func (r resolver) resolveOperation(op *Operation) error {
+	for _, p := range op.Parameters {
+		if err := r.resolve(p.Schema); err != nil {
+			return err
+		}
+	}
+	if op.RequestBody != nil {
+		for _, mt := range op.RequestBody.Content {
+			if err := r.resolve(mt.Schema); err != nil {
+				return err
+			}
+		}
+	}
+	for _, resp := range op.Responses {
+		for _, mt := range resp.Content {
+			if err := r.resolve(mt.Schema); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r resolver) resolve(s *Schema) error {
+	if s == nil {
+		return nil
+	}
+	if _, ok := r.visited[s]; ok {
+		return nil
+	}
+	r.visited[s] = struct{}{}
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, componentSchemaRefPrefix)
+		if !ok {
+			return fmt.Errorf("unsupported reference %s", s.Ref)
+		}
+		target, ok := r.doc.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("unknown schema %s", s.Ref)
+		}
+		s.target = target
+		return nil
+	}
+	for _, p := range s.Properties {
+		if err := r.resolve(p); err != nil {
+			return err
+		}
+	}
+	return r.resolve(s.Items)
+}
+
+// PathTemplate converts the route pattern with regular expressions of parameters like /record/{id:[0-9]+}/
+// to the path template /record/{id} of the document.
+func PathTemplate(pattern string) string {
+	var b strings.Builder
+	depth, skip := 0, false
+	for _, r := range pattern {
+		switch {
+		case r == '{':
+			depth++
+			if depth == 1 {
+				b.WriteRune(r)
+				continue
+			}
+		case r == '}':
+			depth--
+			if depth == 0 {
+				skip = false
+				b.WriteRune(r)
+				continue
+			}
+		case r == ':' && depth == 1:
+			skip = true
+			continue
+		}
+		if !skip {
+			b.WriteRune(r)
+		}
+	}
+	out := strings.TrimSuffix(strings.TrimSuffix(b.String(), "/*"), "/")
+	if out == "" {
+		return "/"
+	}
+	return out
+}
@@ -0,0 +1,224 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is the subset of JSON Schema of OpenAPI 3.0. The empty schema allows any value.
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	MinItems    *int               `json:"minItems,omitempty"`
+	MaxItems    *int               `json:"maxItems,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+
+	target *Schema
+}
+
+// ValidationError names the field of the value which does not match the schema.
+// Field is a path like sequence.template or operations[1].op, it is empty for the value itself.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("body %s", e.Message)
+	}
+	return fmt.Sprintf("%s %s", e.Field, e.Message)
+}
+
+// ValidateJSON checks the JSON document, errors of the document are returned as is
+// and mismatches with the schema are of the *ValidationError type.
+func (s *Schema) ValidateJSON(b []byte) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return fmt.Errorf("body unmarshal error: %s", err)
+	}
+	return s.Validate(v)
+}
+
+// Validate checks the value decoded from JSON with numbers as json.Number or float64.
+func (s *Schema) Validate(v any) error {
+	return s.validate(v, "")
+}
+
+func (s *Schema) validate(v any, field string) error {
+	if s.target != nil {
+		return s.target.validate(v, field)
+	}
+	if v == nil {
+		if s.Type == "" || s.Nullable {
+			return nil
+		}
+		return &ValidationError{Field: field, Message: "must not be null"}
+	}
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		return s.validateObject(v, field)
+	case "array":
+		return s.validateArray(v, field)
+	case "string":
+		return s.validateString(v, field)
+	case "integer", "number":
+		return s.validateNumber(v, field)
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return &ValidationError{Field: field, Message: "must be a boolean"}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported type %s of schema", s.Type)
+	}
+}
+
+func (s *Schema) validateObject(v any, field string) error {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return &ValidationError{Field: field, Message: "must be an object"}
+	}
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			return &ValidationError{Field: joinField(field, name), Message: "is required"}
+		}
+	}
+	// Sorted names give the same error for the same value
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p, ok := s.Properties[name]
+		if !ok {
+			continue
+		}
+		if err := p.validate(obj[name], joinField(field, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateArray(v any, field string) error {
+	arr, ok := v.([]any)
+	if !ok {
+		return &ValidationError{Field: field, Message: "must be an array"}
+	}
+	if s.MinItems != nil && len(arr) < *s.MinItems {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("must have at least %d items", *s.MinItems)}
+	}
+	if s.MaxItems != nil && len(arr) > *s.MaxItems {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("must have at most %d items", *s.MaxItems)}
+	}
+	if s.Items == nil {
+		return nil
+	}
+	for i, item := range arr {
+		if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateString(v any, field string) error {
+	str, ok := v.(string)
+	if !ok {
+		return &ValidationError{Field: field, Message: "must be a string"}
+	}
+	n := len([]rune(str))
+	if s.MinLength != nil && n < *s.MinLength {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("must be at least %d characters long", *s.MinLength)}
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("must be at most %d characters long", *s.MaxLength)}
+	}
+	if len(s.Enum) > 0 && !contains(s.Enum, str) {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("must be one of %s", strings.Join(s.Enum, ", "))}
+	}
+	switch s.Format {
+	case "uuid":
+		if _, err := uuid.Parse(str); err != nil {
+			return &ValidationError{Field: field, Message: "must be a UUID"}
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return &ValidationError{Field: field, Message: "must be a date and time of RFC 3339"}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateNumber(v any, field string) error {
+	mismatch := &ValidationError{Field: field, Message: "must be a number"}
+	if s.Type == "integer" {
+		mismatch.Message = "must be an integer"
+	}
+	var f float64
+	switch n := v.(type) {
+	case json.Number:
+		var err error
+		if s.Type == "integer" {
+			_, err = n.Int64()
+		}
+		if err == nil {
+			f, err = n.Float64()
+		}
+		if err != nil {
+			return mismatch
+		}
+	case float64:
+		if s.Type == "integer" && n != float64(int64(n)) {
+			return mismatch
+		}
+		f = n
+	default:
+		return mismatch
+	}
+	if s.Minimum != nil && f < *s.Minimum {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("must be at least %v", *s.Minimum)}
+	}
+	if s.Maximum != nil && f > *s.Maximum {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("must be at most %v", *s.Maximum)}
+	}
+	return nil
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
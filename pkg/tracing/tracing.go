@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of all spans of the service.
+const instrumentationName = "datatom"
+
+type Exporter uint
+
+// Spans are not exported while the exporter is none, trace contexts of requests and messages
+// are passed on anyway.
+const (
+	ExporterNone Exporter = iota
+	ExporterOTLP
+	ExporterStdout
+)
+
+func (e Exporter) String() string {
+	switch e {
+	case ExporterNone:
+		return "none"
+	case ExporterOTLP:
+		return "otlp"
+	case ExporterStdout:
+		return "stdout"
+	default:
+		return "unknown"
+	}
+}
+
+func (e Exporter) Code() string {
+	return e.String()
+}
+
+func ExporterFromCode(code string) (Exporter, error) {
+	switch code {
+	case "none", "":
+		return ExporterNone, nil
+	case "otlp":
+		return ExporterOTLP, nil
+	case "stdout":
+		return ExporterStdout, nil
+	default:
+		return ExporterNone, fmt.Errorf(`unknown exporter "%s" of traces`, code)
+	}
+}
+
+type Config struct {
+	Exporter Exporter
+	// OTLPEndpoint is host:port of the OTLP HTTP receiver, the exporter reads OTEL_EXPORTER_OTLP_* variables if it is empty
+	OTLPEndpoint string
+	// OTLPInsecure sends spans over plain HTTP
+	OTLPInsecure   bool
+	ServiceName    string
+	ServiceVersion string
+}
+
+// Init sets the global tracer provider and the W3C propagator, shutdown flushes spans which are not exported yet.
+func Init(ctx context.Context, c Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	var exporter sdktrace.SpanExporter
+	switch c.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if c.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(c.OTLPEndpoint))
+		}
+		if c.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, errors.New("unknown exporter of traces")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s exporter of traces error: %w", c.Exporter, err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(c.ServiceName),
+		semconv.ServiceVersion(c.ServiceVersion),
+	))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Tracer of the service, it is the one of the global provider, so spans are started by the provider set by Init.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Inject writes the trace context of ctx to the carrier, e.g. headers of messages.
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// Extract returns ctx with the trace context read from the carrier.
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// End ends the span and records the error if it is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceParent returns the W3C traceparent of the span of ctx, it is empty if there is no span.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// ContextWithTraceParent returns ctx with the remote span of the W3C traceparent, e.g. one stored with data.
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": traceParent})
+}
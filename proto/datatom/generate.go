@@ -0,0 +1,3 @@
+package datatom
+
+//go:generate protoc -I=. -I=../../third_party/dataway --go-grpc_out=../.. --go_out=../.. datatom.proto
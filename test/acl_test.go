@@ -0,0 +1,330 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/internal/rest"
+	"datatom/test/mocks"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	aclHR = domain.ACL{
+		{GranteeType: domain.GranteeGroup, Grantee: "hr", Access: domain.AccessWrite},
+		{GranteeType: domain.GranteeSubject, Grantee: "auditor", Access: domain.AccessRead},
+	}
+	aclEmployee = &domain.Principal{Subject: "employee", Groups: []string{"staff"}}
+	aclHRUser   = &domain.Principal{Subject: "hr-user", Groups: []string{"staff", "hr"}}
+	aclAuditor  = &domain.Principal{Subject: "auditor"}
+)
+
+type ACLTestSuite struct {
+	suite.Suite
+	man  *api.RecordManager
+	repo *mocks.RecordRepository
+}
+
+func TestACL(t *testing.T) {
+	suite.Run(t, new(ACLTestSuite))
+}
+
+func (s *ACLTestSuite) SetupTest() {
+	s.man, s.repo, _ = newTestRecordMockedManager(s.T())
+}
+
+func (s *ACLTestSuite) TestAllows() {
+	type testCase struct {
+		name      string
+		acl       domain.ACL
+		principal *domain.Principal
+		access    domain.Access
+		want      bool
+	}
+	cases := []testCase{
+		{name: "no entries", principal: aclEmployee, access: domain.AccessWrite, want: true},
+		{name: "no principal", acl: aclHR, access: domain.AccessWrite, want: true},
+		{name: "group", acl: aclHR, principal: aclHRUser, access: domain.AccessWrite, want: true},
+		{name: "subject reads", acl: aclHR, principal: aclAuditor, access: domain.AccessRead, want: true},
+		{name: "subject does not write", acl: aclHR, principal: aclAuditor, access: domain.AccessWrite},
+		{name: "not granted", acl: aclHR, principal: aclEmployee, access: domain.AccessRead},
+		{
+			name:      "subject is not a group",
+			acl:       domain.ACL{{GranteeType: domain.GranteeSubject, Grantee: "hr", Access: domain.AccessRead}},
+			principal: aclHRUser,
+			access:    domain.AccessRead,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			s.Equal(c.want, c.acl.Allows(c.principal, c.access))
+		})
+	}
+}
+
+func (s *ACLTestSuite) TestGet() {
+	id := uuid.New()
+	s.repo.On("GetRecord", mock.Anything, id).Return(&domain.Record{ID: id, ACL: aclHR}, nil)
+
+	_, err := s.man.Get(api.ContextWithPrincipal(context.Background(), aclEmployee), id)
+	s.ErrorIs(err, domain.ErrRecordNotFound)
+
+	r, err := s.man.Get(api.ContextWithPrincipal(context.Background(), aclHRUser), id)
+	s.Require().NoError(err)
+	s.Equal(id, r.ID)
+
+	r, err = s.man.Get(context.Background(), id)
+	s.Require().NoError(err)
+	s.Equal(aclHR, r.ACL)
+}
+
+func (s *ACLTestSuite) TestGetMany() {
+	hidden := domain.RecordWithValues{Record: domain.Record{ID: uuid.New(), ACL: aclHR}}
+	open := domain.RecordWithValues{Record: domain.Record{ID: uuid.New()}}
+	ids := []uuid.UUID{hidden.ID, open.ID}
+	s.repo.On("GetRecords", mock.Anything, ids, true).
+		Return(func(context.Context, []uuid.UUID, bool) []domain.RecordWithValues {
+			return []domain.RecordWithValues{hidden, open}
+		}, nil)
+
+	actual, err := s.man.GetMany(api.ContextWithPrincipal(context.Background(), aclEmployee), ids, true)
+	s.Require().NoError(err)
+	s.Equal([]domain.RecordWithValues{open}, actual)
+
+	actual, err = s.man.GetMany(api.ContextWithPrincipal(context.Background(), aclAuditor), ids, true)
+	s.Require().NoError(err)
+	s.Equal([]domain.RecordWithValues{hidden, open}, actual)
+}
+
+func (s *ACLTestSuite) TestList() {
+	req := domain.ListRecordsRequest{Limit: 10}
+	expected := req
+	expected.Principal = aclEmployee
+	s.repo.On("ListRecords", mock.Anything, expected).Return([]domain.Record{}, nil).Once()
+
+	_, err := s.man.List(api.ContextWithPrincipal(context.Background(), aclEmployee), req)
+	s.Require().NoError(err)
+}
+
+func (s *ACLTestSuite) TestUpdate() {
+	id := uuid.New()
+	name := "salary"
+	req := domain.UpdRecordRequest{ID: id, Name: &name}
+	s.repo.
+		On("GetRecordACLs", mock.Anything, []uuid.UUID{id}).Return(map[uuid.UUID]domain.ACL{id: aclHR}, nil).
+		On("UpdateRecord", mock.Anything, req, nil).Return(&domain.Record{ID: id, Name: name}, nil).Once()
+
+	type testCase struct {
+		name      string
+		principal *domain.Principal
+		wantErr   error
+	}
+	cases := []testCase{
+		{name: "hidden", principal: aclEmployee, wantErr: domain.ErrRecordNotFound},
+		{name: "read only", principal: aclAuditor, wantErr: domain.ErrForbidden},
+		{name: "granted", principal: aclHRUser},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			_, err := s.man.Update(api.ContextWithPrincipal(context.Background(), c.principal), req)
+			if c.wantErr != nil {
+				s.ErrorIs(err, c.wantErr)
+				return
+			}
+			s.NoError(err)
+		})
+	}
+}
+
+// The graph is walked by the principal, so the record reachable through the hidden one only is not visited.
+func (s *ACLTestSuite) TestGraph() {
+	root, leaf := uuid.New(), uuid.New()
+	req := domain.GraphRequest{RecordID: root, MaxDepth: 2}
+	walked := req
+	walked.Principal = aclEmployee
+	graph := &domain.Graph{
+		Nodes: []domain.GraphNode{{RecordID: root}, {RecordID: leaf, Depth: 1}},
+		Edges: []domain.GraphEdge{{From: root, To: leaf}},
+	}
+	s.repo.
+		On("GetRecordACLs", mock.Anything, []uuid.UUID{root}).Return(map[uuid.UUID]domain.ACL{}, nil).Once().
+		On("GetRecordGraph", mock.Anything, walked).Return(graph, nil).Once()
+
+	actual, err := s.man.Graph(api.ContextWithPrincipal(context.Background(), aclEmployee), req)
+	s.Require().NoError(err)
+	s.Equal(graph, actual)
+}
+
+func (s *ACLTestSuite) TestReferencedBy() {
+	id, hidden, open := uuid.New(), uuid.New(), uuid.New()
+	req := domain.ReferencedByRequest{RecordID: id, Limit: 10}
+	s.repo.
+		On("GetRecordACLs", mock.Anything, []uuid.UUID{id}).Return(map[uuid.UUID]domain.ACL{}, nil).
+		On("GetRecordACLs", mock.Anything, []uuid.UUID{hidden, open}).Return(map[uuid.UUID]domain.ACL{hidden: aclHR}, nil).
+		On("GetReferencedBy", mock.Anything, req).Return([]domain.RecordReference{{RecordID: hidden}, {RecordID: open}}, nil)
+
+	actual, err := s.man.ReferencedBy(api.ContextWithPrincipal(context.Background(), aclEmployee), req)
+	s.Require().NoError(err)
+	s.Equal([]domain.RecordReference{{RecordID: open}}, actual)
+}
+
+func (s *ACLTestSuite) TestGetExpanded() {
+	id, hidden := uuid.New(), uuid.New()
+	propertyID := uuid.New()
+	ref := domain.Value{RecordID: id, PropertyID: propertyID, Type: domain.TypeReference, Value: hidden}
+	s.repo.
+		On("GetRecords", mock.Anything, []uuid.UUID{id}, true).
+		Return([]domain.RecordWithValues{{Record: domain.Record{ID: id}, Values: []domain.Value{ref}}}, nil).
+		On("GetRecords", mock.Anything, []uuid.UUID{hidden}, false).
+		Return([]domain.RecordWithValues{{Record: domain.Record{ID: hidden, ACL: aclHR}}}, nil)
+
+	actual, err := s.man.GetExpanded(
+		api.ContextWithPrincipal(context.Background(), aclEmployee),
+		id,
+		domain.ExpandRequest{Refs: true, Depth: 1},
+	)
+	s.Require().NoError(err)
+	s.Require().Len(actual.Values, 1)
+	s.Nil(actual.Values[0].Ref)
+}
+
+func (s *ACLTestSuite) TestValue() {
+	valueMan, valueRepo, _ := newTestValueMockedManager(s.T())
+	recordRepo := valueMan.RecordRepository.(*mocks.RecordRepository)
+	id := uuid.New()
+	req := domain.GetValueRequest{RecordID: id, PropertyID: uuid.New()}
+	recordRepo.On("GetRecordACLs", mock.Anything, []uuid.UUID{id}).Return(map[uuid.UUID]domain.ACL{id: aclHR}, nil)
+	valueRepo.On("GetValue", mock.Anything, req).Return(&domain.Value{RecordID: id}, nil).Once()
+
+	_, err := valueMan.Get(api.ContextWithPrincipal(context.Background(), aclEmployee), req)
+	s.ErrorIs(err, domain.ErrRecordNotFound)
+	_, err = valueMan.Set(api.ContextWithPrincipal(context.Background(), aclAuditor), domain.SetValueRequest{RecordID: id})
+	s.ErrorIs(err, domain.ErrForbidden)
+	_, err = valueMan.Get(api.ContextWithPrincipal(context.Background(), aclAuditor), req)
+	s.NoError(err)
+}
+
+func (s *ACLTestSuite) TestExportTable() {
+	tableMan, tableRepo := newTestTableMockedManager(s.T())
+	hidden := domain.TableRow{Record: domain.Record{ID: uuid.New(), ACL: aclHR}}
+	open := domain.TableRow{Record: domain.Record{ID: uuid.New()}}
+	req := domain.TableRequest{RefTypeID: uuid.New()}
+	tableRepo.On("ExportTable", mock.Anything, req, mock.Anything).
+		Run(func(args mock.Arguments) {
+			w := args.Get(2).(domain.TableWriter)
+			s.Require().NoError(w.WriteRow(hidden))
+			s.Require().NoError(w.WriteRow(open))
+		}).
+		Return(nil)
+	w := &aclTestTableWriter{}
+
+	s.Require().NoError(tableMan.Export(api.ContextWithPrincipal(context.Background(), aclEmployee), req, w))
+	s.Equal([]domain.TableRow{open}, w.rows)
+}
+
+type aclTestTableWriter struct {
+	rows []domain.TableRow
+}
+
+func (w *aclTestTableWriter) WriteHeader([]domain.Property) error {
+	return nil
+}
+
+func (w *aclTestTableWriter) WriteRow(row domain.TableRow) error {
+	w.rows = append(w.rows, row)
+	return nil
+}
+
+func (s *ACLTestSuite) TestSetACL() {
+	id := uuid.New()
+	acl := domain.ACL{{GranteeType: domain.GranteeGroup, Grantee: "hr", Access: domain.AccessRead}}
+	s.repo.
+		On("GetRecordACLs", mock.Anything, []uuid.UUID{id}).Return(map[uuid.UUID]domain.ACL{id: aclHR}, nil).
+		On("SetRecordACL", mock.Anything, id, acl).Return(&domain.Record{ID: id, ACL: acl, Sum: "s1"}, nil).Once()
+
+	type testCase struct {
+		name       string
+		principal  *domain.Principal
+		body       string
+		wantStatus int
+		wantBody   string
+	}
+	cases := []testCase{
+		{
+			name:       "duplicated grantee",
+			principal:  aclHRUser,
+			body:       `{"acl":[{"grantee_type":"group","grantee":"hr","access":"read"},{"grantee_type":"group","grantee":"hr","access":"write"}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown access",
+			principal:  aclHRUser,
+			body:       `{"acl":[{"grantee_type":"group","grantee":"hr","access":"admin"}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "read only",
+			principal:  aclAuditor,
+			body:       `{"acl":[{"grantee_type":"group","grantee":"hr","access":"read"}]}`,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "granted",
+			principal:  aclHRUser,
+			body:       `{"acl":[{"grantee_type":"group","grantee":"hr","access":"read"}]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"record_id":"` + id.String() + `","acl":[{"grantee_type":"group","grantee":"hr","access":"read"}]}`,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			var schema handlers.SetRecordACLRequestSchema
+			s.Require().NoError(json.Unmarshal([]byte(c.body), &schema))
+			schema.ID = id.String()
+			res, err := handlers.SetRecordACL(api.ContextWithPrincipal(context.Background(), c.principal), s.man, schema)
+			s.Equal(c.wantStatus, res.Status)
+			if c.wantBody == "" {
+				s.Error(err)
+				return
+			}
+			s.Require().NoError(err)
+			s.JSONEq(c.wantBody, string(res.Payload))
+			s.Equal("s1", res.Sum)
+		})
+	}
+}
+
+func (s *ACLTestSuite) TestREST() {
+	c, recordRepo := newTestServerConfig(s.T())
+	srv, err := rest.NewServer(c)
+	s.Require().NoError(err)
+	handler := srv.(interface{ Routes() chi.Routes }).Routes().(http.Handler)
+	id := uuid.New()
+	recordRepo.On("GetRecord", mock.Anything, id).Return(&domain.Record{ID: id, ACL: aclHR, Sum: "s1"}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/record/"+id.String()+"/acl", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	s.Equal(`"s1"`, rec.Header().Get("ETag"))
+	s.JSONEq(`{"record_id":"`+id.String()+`","acl":[`+
+		`{"grantee_type":"group","grantee":"hr","access":"write"},`+
+		`{"grantee_type":"subject","grantee":"auditor","access":"read"}]}`, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodPut, "/v1/record/"+id.String()+"/acl", strings.NewReader(`{"acl":[{"grantee_type":"role","grantee":"hr","access":"read"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	s.Equal(http.StatusBadRequest, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,412 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"datatom/internal/api"
+	"datatom/internal/auth"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/internal/rest"
+	"datatom/test/mocks"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	rmq "github.com/wagslane/go-rabbitmq"
+)
+
+const (
+	testJWTIssuer   = "https://id.example.com"
+	testJWTAudience = "datatom"
+	testJWTKeyID    = "k1"
+)
+
+type AuthTestSuite struct {
+	suite.Suite
+	handler    http.Handler
+	recordRepo *mocks.RecordRepository
+	man        *api.AuthManager
+	jwtAuth    *auth.JWTAuthenticator
+	key        *rsa.PrivateKey
+	refTypeID  uuid.UUID
+}
+
+func TestAuth(t *testing.T) {
+	suite.Run(t, new(AuthTestSuite))
+}
+
+func (s *AuthTestSuite) SetupTest() {
+	var err error
+	s.refTypeID = uuid.New()
+	s.key, err = rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": testJWTKeyID,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+	}}})
+	s.Require().NoError(err)
+	jwksFile := filepath.Join(s.T().TempDir(), "jwks.json")
+	s.Require().NoError(os.WriteFile(jwksFile, jwks, 0o600))
+	s.jwtAuth, err = auth.NewJWTAuthenticator(auth.JWTConfig{
+		JWKSFile:   jwksFile,
+		Issuer:     testJWTIssuer,
+		Audience:   testJWTAudience,
+		RolesClaim: "realm_access.roles",
+	})
+	s.Require().NoError(err)
+	keyAuth, err := auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Subject: "reader", SHA256: testKeySum("reader-key"), Roles: []string{"reader"}},
+		{Subject: "editor", SHA256: testKeySum("editor-key"), Roles: []string{"editor"}},
+	})
+	s.Require().NoError(err)
+
+	c, recordRepo := newTestServerConfig(s.T())
+	s.man = newTestAuthManager(s.T(), c.RecordManager, c.PropertyManager, []domain.Role{
+		{Name: "reader", Grants: []domain.Grant{{
+			Operations: []domain.Operation{domain.OperationRecords, domain.OperationValues},
+			RefTypeIDs: []uuid.UUID{s.refTypeID},
+			Access:     domain.AccessRead,
+		}}},
+		{Name: "editor", Grants: []domain.Grant{{Access: domain.AccessWrite}}},
+	}, keyAuth, s.jwtAuth)
+	c.AuthManager = s.man
+	srv, err := rest.NewServer(c)
+	s.Require().NoError(err)
+	var ok bool
+	s.handler, ok = srv.(interface{ Routes() chi.Routes }).Routes().(http.Handler)
+	s.Require().True(ok)
+	s.recordRepo = recordRepo
+}
+
+func testKeySum(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *AuthTestSuite) token(claims jwt.MapClaims) string {
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = testJWTKeyID
+	out, err := t.SignedString(s.key)
+	s.Require().NoError(err)
+	return out
+}
+
+func (s *AuthTestSuite) TestGrantAllows() {
+	rtID := uuid.New()
+	type testCase struct {
+		name  string
+		grant domain.Grant
+		perm  domain.Permission
+		want  bool
+	}
+	cases := []testCase{
+		{
+			name:  "write implies read",
+			grant: domain.Grant{Access: domain.AccessWrite},
+			perm:  domain.Permission{Operation: domain.OperationDump, Access: domain.AccessRead},
+			want:  true,
+		},
+		{
+			name:  "read does not imply write",
+			grant: domain.Grant{Access: domain.AccessRead},
+			perm:  domain.Permission{Operation: domain.OperationDump, Access: domain.AccessWrite},
+		},
+		{
+			name:  "other operation",
+			grant: domain.Grant{Operations: []domain.Operation{domain.OperationRecords}, Access: domain.AccessWrite},
+			perm:  domain.Permission{Operation: domain.OperationValues, Access: domain.AccessRead, RefTypeID: rtID},
+		},
+		{
+			name:  "granted reference type",
+			grant: domain.Grant{RefTypeIDs: []uuid.UUID{rtID}, Access: domain.AccessRead},
+			perm:  domain.Permission{Operation: domain.OperationRecords, Access: domain.AccessRead, RefTypeID: rtID},
+			want:  true,
+		},
+		{
+			name:  "other reference type",
+			grant: domain.Grant{RefTypeIDs: []uuid.UUID{rtID}, Access: domain.AccessRead},
+			perm:  domain.Permission{Operation: domain.OperationRecords, Access: domain.AccessRead, RefTypeID: uuid.New()},
+		},
+		{
+			name:  "not scoped request",
+			grant: domain.Grant{RefTypeIDs: []uuid.UUID{rtID}, Access: domain.AccessWrite},
+			perm:  domain.Permission{Operation: domain.OperationBatch, Access: domain.AccessWrite},
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			s.Equal(c.want, c.grant.Allows(c.perm))
+		})
+	}
+}
+
+func (s *AuthTestSuite) TestREST() {
+	id := uuid.New()
+	otherID := uuid.New()
+	s.recordRepo.
+		On("GetRecord", mock.Anything, id).Return(&domain.Record{ID: id, ReferenceTypeID: s.refTypeID, Name: "chair"}, nil).
+		On("GetRecord", mock.Anything, otherID).Return(&domain.Record{ID: otherID, ReferenceTypeID: uuid.New()}, nil)
+
+	type testCase struct {
+		name       string
+		method     string
+		path       string
+		headers    map[string]string
+		wantStatus int
+		wantCode   string
+	}
+	cases := []testCase{
+		{
+			name:       "public route",
+			method:     http.MethodGet,
+			path:       "/v1/health/ping",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "no credentials",
+			method:     http.MethodGet,
+			path:       "/v1/record/" + id.String(),
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "unauthenticated",
+		},
+		{
+			name:       "unknown API key",
+			method:     http.MethodGet,
+			path:       "/v1/record/" + id.String(),
+			headers:    map[string]string{"X-API-Key": "unknown"},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "unauthenticated",
+		},
+		{
+			name:       "not bearer token",
+			method:     http.MethodGet,
+			path:       "/record/" + id.String(),
+			headers:    map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "unauthenticated",
+		},
+		{
+			name:       "granted reference type",
+			method:     http.MethodGet,
+			path:       "/v1/record/" + id.String(),
+			headers:    map[string]string{"X-API-Key": "reader-key"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "legacy route",
+			method:     http.MethodGet,
+			path:       "/record/" + id.String(),
+			headers:    map[string]string{"X-API-Key": "reader-key"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "other reference type",
+			method:     http.MethodGet,
+			path:       "/v1/record/" + otherID.String(),
+			headers:    map[string]string{"X-API-Key": "reader-key"},
+			wantStatus: http.StatusForbidden,
+			wantCode:   "forbidden",
+		},
+		{
+			name:       "write is not granted",
+			method:     http.MethodPatch,
+			path:       "/v1/record/" + id.String(),
+			headers:    map[string]string{"X-API-Key": "reader-key"},
+			wantStatus: http.StatusForbidden,
+			wantCode:   "forbidden",
+		},
+		{
+			name:       "not scoped operation",
+			method:     http.MethodGet,
+			path:       "/v1/dump",
+			headers:    map[string]string{"X-API-Key": "reader-key"},
+			wantStatus: http.StatusForbidden,
+			wantCode:   "forbidden",
+		},
+		{
+			name:   "JWT",
+			method: http.MethodGet,
+			path:   "/v1/record/" + otherID.String(),
+			headers: map[string]string{"Authorization": "Bearer " + s.token(jwt.MapClaims{
+				"sub":          "user",
+				"iss":          testJWTIssuer,
+				"aud":          testJWTAudience,
+				"exp":          time.Now().Add(time.Minute).Unix(),
+				"realm_access": map[string]any{"roles": []string{"editor"}},
+			})},
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			req := httptest.NewRequest(c.method, c.path, nil)
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			s.handler.ServeHTTP(rec, req)
+			s.Require().Equal(c.wantStatus, rec.Code, rec.Body.String())
+			if c.wantStatus == http.StatusUnauthorized {
+				s.Equal("Bearer", rec.Header().Get("WWW-Authenticate"))
+			}
+			if c.wantCode != "" {
+				var p struct {
+					Code string `json:"code"`
+				}
+				s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &p))
+				s.Equal(c.wantCode, p.Code)
+			}
+		})
+	}
+}
+
+func (s *AuthTestSuite) TestJWT() {
+	valid := jwt.MapClaims{
+		"sub":          "user",
+		"iss":          testJWTIssuer,
+		"aud":          testJWTAudience,
+		"exp":          time.Now().Add(time.Minute).Unix(),
+		"realm_access": map[string]any{"roles": []string{"reader", "editor"}},
+	}
+	with := func(k string, v any) jwt.MapClaims {
+		out := jwt.MapClaims{}
+		for key, value := range valid {
+			out[key] = value
+		}
+		if v == nil {
+			delete(out, k)
+		} else {
+			out[k] = v
+		}
+		return out
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, valid)
+	forged.Header["kid"] = testJWTKeyID
+	forgedToken, err := forged.SignedString(otherKey)
+	s.Require().NoError(err)
+
+	type testCase struct {
+		name    string
+		token   string
+		want    *domain.Principal
+		wantErr bool
+	}
+	cases := []testCase{
+		{
+			name:  "valid",
+			token: s.token(valid),
+			want:  &domain.Principal{Subject: "user", Roles: []string{"reader", "editor"}},
+		},
+		{
+			name:  "groups",
+			token: s.token(with("groups", []string{"hr", "payroll"})),
+			want:  &domain.Principal{Subject: "user", Roles: []string{"reader", "editor"}, Groups: []string{"hr", "payroll"}},
+		},
+		{
+			name:    "expired",
+			token:   s.token(with("exp", time.Now().Add(-time.Minute).Unix())),
+			wantErr: true,
+		},
+		{
+			name:    "without expiration",
+			token:   s.token(with("exp", nil)),
+			wantErr: true,
+		},
+		{
+			name:    "other issuer",
+			token:   s.token(with("iss", "https://other.example.com")),
+			wantErr: true,
+		},
+		{
+			name:    "other audience",
+			token:   s.token(with("aud", "other")),
+			wantErr: true,
+		},
+		{
+			name:    "without subject",
+			token:   s.token(with("sub", nil)),
+			wantErr: true,
+		},
+		{
+			name:    "signed by other key",
+			token:   forgedToken,
+			wantErr: true,
+		},
+		{
+			name:    "not signed",
+			token:   strings.Join(strings.Split(s.token(valid), ".")[:2], ".") + ".",
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			actual, err := s.jwtAuth.Authenticate(context.Background(), domain.Credentials{Token: c.token})
+			if c.wantErr {
+				s.Require().ErrorIs(err, domain.ErrUnauthenticated)
+				return
+			}
+			s.Require().NoError(err)
+			s.Equal(c.want, actual)
+		})
+	}
+}
+
+func (s *AuthTestSuite) TestConsumeHandler() {
+	valueMan, valueRepo, _ := newTestValueMockedManager(s.T())
+	recordID := uuid.New()
+	propertyID := uuid.New()
+	valueMan.RecordRepository.(*mocks.RecordRepository).
+		On("GetRecordACLs", mock.Anything, []uuid.UUID{recordID}).Return(map[uuid.UUID]domain.ACL{}, nil)
+	s.recordRepo.On("GetRecord", mock.Anything, recordID).Return(&domain.Record{ID: recordID, ReferenceTypeID: s.refTypeID}, nil)
+	valueRepo.On("SetValue", mock.Anything, mock.Anything, mock.Anything).
+		Return(&domain.Value{RecordID: recordID, PropertyID: propertyID, Type: domain.TypeNumber, Value: float64(1)}, nil).Once()
+	handle := handlers.NewConsumeHandler(handlers.ConsumeHandlerConfig{
+		ValueManager: valueMan,
+		AuthManager:  s.man,
+	})
+	body := []byte(`{"record_id":"` + recordID.String() + `","property_id":"` + propertyID.String() + `","type":"number","value":1}`)
+
+	type testCase struct {
+		name    string
+		headers amqp.Table
+		want    rmq.Action
+	}
+	cases := []testCase{
+		{name: "no credentials", want: rmq.NackDiscard},
+		{name: "write is not granted", headers: amqp.Table{"X-API-Key": "reader-key"}, want: rmq.NackDiscard},
+		{name: "granted", headers: amqp.Table{"X-API-Key": "editor-key"}, want: rmq.Ack},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			actual := handle(rmq.Delivery{Delivery: amqp.Delivery{
+				Type:    domain.DeliveryTypeValue,
+				Headers: c.headers,
+				Body:    body,
+			}})
+			s.Equal(c.want, actual)
+		})
+	}
+}
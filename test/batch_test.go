@@ -0,0 +1,294 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/test/mocks"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type BatchHandlersTestSuite struct {
+	suite.Suite
+	man          *api.BatchManager
+	tb           *testTransactionBeginner
+	recordRepo   *mocks.RecordRepository
+	propertyRepo *mocks.PropertyRepository
+	valueRepo    *mocks.ValueRepository
+}
+
+func TestBatchHandlers(t *testing.T) {
+	suite.Run(t, new(BatchHandlersTestSuite))
+}
+
+func (s *BatchHandlersTestSuite) SetupTest() {
+	s.man, s.tb, s.recordRepo, s.propertyRepo, s.valueRepo = newTestBatchMockedManager(s.T())
+}
+
+func batchOperation(op, tempID, data string) handlers.BatchOperationSchema {
+	return handlers.BatchOperationSchema{Op: op, TempID: tempID, Data: json.RawMessage(data)}
+}
+
+func (s *BatchHandlersTestSuite) TestBatch() {
+	rID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	pID := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	refID := uuid.MustParse("33333333-3333-3333-3333-333333333333")
+	s.recordRepo.
+		On("AddRecord", mock.Anything, domain.AddRecordRequest{Name: "record"}, mock.Anything).Return(rID, nil).Once()
+	s.propertyRepo.
+		On("AddProperty", mock.Anything, domain.AddPropertyRequest{
+			Name:       "ref",
+			Types:      []domain.Type{domain.TypeReference},
+			RefTypeIDs: []uuid.UUID{},
+		}, mock.Anything).Return(pID, nil).Once()
+	s.valueRepo.
+		On("SetValue", mock.Anything, domain.SetValueRequest{
+			RecordID:   rID,
+			PropertyID: pID,
+			Type:       domain.TypeReference,
+			Value:      refID.String(),
+		}, mock.Anything).Return(&domain.Value{}, nil).Once().
+		On("SetValue", mock.Anything, domain.SetValueRequest{
+			RecordID:   rID,
+			PropertyID: pID,
+			Type:       domain.TypeText,
+			Value:      "$100",
+		}, mock.Anything).Return(&domain.Value{}, nil).Once().
+		On("SetValue", mock.Anything, domain.SetValueRequest{
+			RecordID:   rID,
+			PropertyID: pID,
+			Type:       domain.TypeText,
+			Value:      "$r",
+		}, mock.Anything).Return(&domain.Value{}, nil).Once().
+		On("SetValue", mock.Anything, domain.SetValueRequest{
+			RecordID:   rID,
+			PropertyID: pID,
+			Type:       domain.TypeReference,
+			Value:      rID.String(),
+		}, mock.Anything).Return(&domain.Value{}, nil).Once()
+	req := handlers.BatchRequestSchema{Operations: []handlers.BatchOperationSchema{
+		batchOperation(handlers.BatchOpAddRecord, "r", `{"name":"record"}`),
+		batchOperation(handlers.BatchOpAddProperty, "p", `{"name":"ref","types":["ref"]}`),
+		batchOperation(handlers.BatchOpSetValue, "", fmt.Sprintf(`{"record_id":"$r","property_id":"$p","type":"ref","value":"%s"}`, refID)),
+		batchOperation(handlers.BatchOpSetValue, "", `{"record_id":"$r","property_id":"$p","type":"text","value":"$100"}`),
+		// Temp IDs are resolved in ID fields and values of ref type only
+		batchOperation(handlers.BatchOpSetValue, "", `{"record_id":"$r","property_id":"$p","type":"text","value":"$r"}`),
+		batchOperation(handlers.BatchOpSetValue, "", `{"record_id":"$r","property_id":"$p","type":"ref","value":"$r"}`),
+	}}
+	payload := []byte(fmt.Sprintf(`{"results":[{"index":0,"op":"add_record","temp_id":"r","id":"%s"},{"index":1,"op":"add_property","temp_id":"p","id":"%s"},{"index":2,"op":"set_value"},{"index":3,"op":"set_value"},{"index":4,"op":"set_value"},{"index":5,"op":"set_value"}]}`, rID, pID))
+
+	actual, err := handlers.Batch(context.Background(), s.man, req)
+	s.Require().NoError(err)
+	s.Equal(handlers.Result{Status: http.StatusOK, Payload: payload}, actual)
+	s.True(s.tb.tx.committed)
+	s.False(s.tb.tx.rolledBack)
+}
+
+func (s *BatchHandlersTestSuite) TestBatchRollback() {
+	rID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	pID := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	s.recordRepo.
+		On("AddRecord", mock.Anything, domain.AddRecordRequest{Name: "record"}, mock.Anything).Return(rID, nil).Once().
+		On("AddRecord", mock.Anything, domain.AddRecordRequest{Name: "error"}, mock.Anything).Return(uuid.Nil, errors.New("error")).Once()
+	s.valueRepo.
+		On("SetValue", mock.Anything, domain.SetValueRequest{
+			RecordID:   rID,
+			PropertyID: pID,
+			Type:       domain.TypeText,
+			Value:      "archived",
+		}, mock.Anything).Return(nil, domain.ErrStateTransitionNotAllowedPG).Once()
+
+	type testCase struct {
+		name    string
+		req     handlers.BatchRequestSchema
+		want    handlers.Result
+		wantTx  bool
+		wantErr error
+	}
+	cases := []testCase{
+		{
+			name: "rollback on domain error",
+			req: handlers.BatchRequestSchema{Operations: []handlers.BatchOperationSchema{
+				batchOperation(handlers.BatchOpAddRecord, "r", `{"name":"record"}`),
+				batchOperation(handlers.BatchOpSetValue, "", fmt.Sprintf(`{"record_id":"$r","property_id":"%s","type":"text","value":"archived"}`, pID)),
+			}},
+			want: handlers.Result{
+				Status:  http.StatusConflict,
+				Payload: []byte(`{"index":1,"op":"set_value","error":"state transition not allowed"}`),
+			},
+			wantTx:  true,
+			wantErr: domain.ErrStateTransitionNotAllowedPG,
+		},
+		{
+			name: "rollback on internal error",
+			req: handlers.BatchRequestSchema{Operations: []handlers.BatchOperationSchema{
+				batchOperation(handlers.BatchOpAddRecord, "", `{"name":"error"}`),
+			}},
+			want: handlers.Result{
+				Status:  http.StatusInternalServerError,
+				Payload: []byte(`{"index":0,"op":"add_record","error":"Internal Server Error"}`),
+			},
+			wantTx: true,
+		},
+		{
+			name: "rollback on unknown operation",
+			req: handlers.BatchRequestSchema{Operations: []handlers.BatchOperationSchema{
+				batchOperation("delete_record", "", `{}`),
+			}},
+			want: handlers.Result{
+				Status:  http.StatusBadRequest,
+				Payload: []byte(`{"index":0,"op":"delete_record","error":"unknown type \"delete_record\" of batch operation"}`),
+			},
+			wantTx:  true,
+			wantErr: domain.ErrUnknownType,
+		},
+		{
+			name: "rollback on temp ID used before its operation",
+			req: handlers.BatchRequestSchema{Operations: []handlers.BatchOperationSchema{
+				batchOperation(handlers.BatchOpUpdateRecord, "", `{"id":"$r","name":"record"}`),
+				batchOperation(handlers.BatchOpAddRecord, "r", `{"name":"record"}`),
+			}},
+			want: handlers.Result{
+				Status:  http.StatusBadRequest,
+				Payload: []byte(`{"index":0,"op":"update_record","error":"temp ID r is used before its operation"}`),
+			},
+			wantTx: true,
+		},
+		{
+			name: "duplicated temp ID",
+			req: handlers.BatchRequestSchema{Operations: []handlers.BatchOperationSchema{
+				batchOperation(handlers.BatchOpAddRecord, "r", `{"name":"record"}`),
+				batchOperation(handlers.BatchOpAddRecord, "r", `{"name":"record"}`),
+			}},
+			want: handlers.Result{
+				Status:  http.StatusBadRequest,
+				Payload: []byte(`{"index":1,"op":"add_record","error":"temp ID r duplicated"}`),
+			},
+		},
+		{
+			name:    "no operations",
+			req:     handlers.BatchRequestSchema{},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: domain.ErrExpected,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			s.tb.tx = nil
+			actual, err := handlers.Batch(context.Background(), s.man, c.req)
+			s.Require().Error(err)
+			if c.wantErr != nil {
+				s.ErrorIs(err, c.wantErr)
+			}
+			s.Equal(c.want.Status, actual.Status)
+			s.Equal(string(c.want.Payload), string(actual.Payload))
+			if c.wantTx {
+				s.Require().NotNil(s.tb.tx)
+				s.True(s.tb.tx.rolledBack)
+				s.False(s.tb.tx.committed)
+			} else {
+				s.Nil(s.tb.tx)
+			}
+		})
+	}
+}
+
+func (s *BatchHandlersTestSuite) TestBatchAuthorization() {
+	rtA := uuid.MustParse("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa")
+	rtB := uuid.MustParse("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb")
+	rID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	pID := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	s.man, s.tb, s.recordRepo, s.propertyRepo, s.valueRepo = newTestBatchMockedManager(s.T(), domain.Role{
+		Name: "editor",
+		Grants: []domain.Grant{{
+			Operations: []domain.Operation{domain.OperationRecords, domain.OperationValues},
+			RefTypeIDs: []uuid.UUID{rtA},
+			Access:     domain.AccessWrite,
+		}},
+	})
+	ctx := api.ContextWithPrincipal(context.Background(), &domain.Principal{Subject: "editor", Roles: []string{"editor"}})
+
+	s.Run("operations on the granted reference type", func() {
+		s.recordRepo.
+			On("AddRecord", mock.Anything, domain.AddRecordRequest{Name: "record", ReferenceTypeID: rtA}, mock.Anything).Return(rID, nil).Once().
+			// The record added by the batch is found within its transaction
+			On("GetRecordRefTypeID", mock.Anything, rID, mock.Anything).Return(rtA, nil).Once().
+			On("GetRecordACLs", mock.Anything, []uuid.UUID{rID}).Return(map[uuid.UUID]domain.ACL{}, nil).Once()
+		s.valueRepo.
+			On("SetValue", mock.Anything, domain.SetValueRequest{
+				RecordID:   rID,
+				PropertyID: pID,
+				Type:       domain.TypeText,
+				Value:      "text",
+			}, mock.Anything).Return(&domain.Value{}, nil).Once()
+		req := handlers.BatchRequestSchema{Operations: []handlers.BatchOperationSchema{
+			batchOperation(handlers.BatchOpAddRecord, "r", fmt.Sprintf(`{"name":"record","reference_type_id":"%s"}`, rtA)),
+			batchOperation(handlers.BatchOpSetValue, "", fmt.Sprintf(`{"record_id":"$r","property_id":"%s","type":"text","value":"text"}`, pID)),
+		}}
+
+		actual, err := handlers.Batch(ctx, s.man, req)
+		s.Require().NoError(err)
+		s.Equal(http.StatusOK, actual.Status)
+		s.True(s.tb.tx.committed)
+	})
+
+	cases := []struct {
+		name    string
+		prepare func()
+		op      handlers.BatchOperationSchema
+		payload string
+	}{
+		{
+			name: "record of another reference type",
+			op:   batchOperation(handlers.BatchOpAddRecord, "", fmt.Sprintf(`{"name":"record","reference_type_id":"%s"}`, rtB)),
+			payload: fmt.Sprintf(`{"index":0,"op":"add_record","error":"forbidden: write access to records of reference type %s is not granted to editor"}`,
+				rtB),
+		},
+		{
+			name: "value of record of another reference type",
+			prepare: func() {
+				s.recordRepo.On("GetRecordRefTypeID", mock.Anything, rID, mock.Anything).Return(rtB, nil).Once()
+			},
+			op: batchOperation(handlers.BatchOpSetValue, "", fmt.Sprintf(`{"record_id":"%s","property_id":"%s","type":"text","value":"text"}`, rID, pID)),
+			payload: fmt.Sprintf(`{"index":0,"op":"set_value","error":"forbidden: write access to values of reference type %s is not granted to editor"}`,
+				rtB),
+		},
+		{
+			name: "property of the granted reference type",
+			op:   batchOperation(handlers.BatchOpAddProperty, "", fmt.Sprintf(`{"name":"text","types":["text"],"owner_reference_type_id":"%s"}`, rtA)),
+			payload: fmt.Sprintf(`{"index":0,"op":"add_property","error":"forbidden: write access to properties of reference type %s is not granted to editor"}`,
+				rtA),
+		},
+		{
+			name: "update of property of another reference type",
+			prepare: func() {
+				s.propertyRepo.On("GetPropertyOwnerRefTypeID", mock.Anything, pID, mock.Anything).Return(rtB, nil).Once()
+			},
+			op: batchOperation(handlers.BatchOpUpdateProperty, "", fmt.Sprintf(`{"id":"%s","name":"text"}`, pID)),
+			payload: fmt.Sprintf(`{"index":0,"op":"update_property","error":"forbidden: write access to properties of reference type %s is not granted to editor"}`,
+				rtB),
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			if c.prepare != nil {
+				c.prepare()
+			}
+			actual, err := handlers.Batch(ctx, s.man, handlers.BatchRequestSchema{Operations: []handlers.BatchOperationSchema{c.op}})
+			s.Require().ErrorIs(err, domain.ErrForbidden)
+			s.Equal(http.StatusForbidden, actual.Status)
+			s.Equal(c.payload, string(actual.Payload))
+			s.True(s.tb.tx.rolledBack)
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/internal/rest"
+	"datatom/test/mocks"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/websocket"
+)
+
+type ChangeLogTestSuite struct {
+	suite.Suite
+	man  *api.ChangeLogManager
+	repo *mocks.ChangeLogRepository
+}
+
+func TestChangeLog(t *testing.T) {
+	suite.Run(t, new(ChangeLogTestSuite))
+}
+
+func (s *ChangeLogTestSuite) SetupTest() {
+	s.man, s.repo = newTestChangeLogMockedManager(s.T())
+}
+
+func changeLogRequest(after int64, limit uint) any {
+	return mock.MatchedBy(func(r domain.ChangeLogRequest) bool {
+		return r.AfterID == after && r.Limit == limit
+	})
+}
+
+// listen runs listening of the manager and returns the function it notifies streams by.
+func (s *ChangeLogTestSuite) listen(ctx context.Context) func(int64) {
+	notifyCh := make(chan func(int64), 1)
+	s.repo.On("ListenChanges", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		notifyCh <- args.Get(1).(func(int64))
+		<-args.Get(0).(context.Context).Done()
+	}).Return(nil).Once()
+	go func() {
+		_ = s.man.Listen(ctx)
+	}()
+	return <-notifyCh
+}
+
+func (s *ChangeLogTestSuite) TestStream() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notify := s.listen(ctx)
+	s.repo.
+		// Changes logged while the log is read wake the stream up
+		On("GetChangeLog", mock.Anything, changeLogRequest(5, 1000)).Run(func(mock.Arguments) {
+		notify(8)
+	}).Return([]domain.Change{{ID: 6}, {ID: 7}}, nil).Once().
+		On("GetChangeLog", mock.Anything, changeLogRequest(7, 1000)).Return([]domain.Change{{ID: 8}}, nil).Once()
+
+	var actual []int64
+	done := make(chan error)
+	go func() {
+		done <- s.man.Stream(ctx, domain.ChangeLogRequest{AfterID: 5}, func(c domain.Change) error {
+			actual = append(actual, c.ID)
+			if c.ID == 8 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		s.NoError(err)
+	case <-time.After(time.Second * 5):
+		s.FailNow("stream is not woken up")
+	}
+	s.Equal([]int64{6, 7, 8}, actual)
+}
+
+// Change 7 is committed while change 6 is in progress, the stream waits for the commit of 6.
+func (s *ChangeLogTestSuite) TestStreamLateCommit() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notify := s.listen(ctx)
+	s.repo.
+		On("GetChangeLog", mock.Anything, changeLogRequest(5, 1000)).Run(func(mock.Arguments) {
+		notify(6)
+	}).Return(nil, nil).Once().
+		On("GetChangeLog", mock.Anything, changeLogRequest(5, 1000)).Return([]domain.Change{{ID: 6}, {ID: 7}}, nil).Once()
+
+	var actual []int64
+	done := make(chan error)
+	go func() {
+		done <- s.man.Stream(ctx, domain.ChangeLogRequest{AfterID: 5}, func(c domain.Change) error {
+			actual = append(actual, c.ID)
+			if c.ID == 7 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		s.NoError(err)
+	case <-time.After(time.Second * 5):
+		s.FailNow("stream is not woken up")
+	}
+	s.Equal([]int64{6, 7}, actual)
+}
+
+func (s *ChangeLogTestSuite) TestStreamPages() {
+	errEmit := errors.New("closed")
+	s.repo.
+		On("GetChangeLog", mock.Anything, changeLogRequest(0, 2)).Return([]domain.Change{{ID: 1}, {ID: 2}}, nil).Once().
+		On("GetChangeLog", mock.Anything, changeLogRequest(2, 2)).Return([]domain.Change{{ID: 3}}, nil).Once()
+
+	var actual []int64
+	// The full page is followed by the next one without waiting
+	err := s.man.Stream(context.Background(), domain.ChangeLogRequest{Limit: 2}, func(c domain.Change) error {
+		actual = append(actual, c.ID)
+		if c.ID == 3 {
+			return errEmit
+		}
+		return nil
+	})
+	s.ErrorIs(err, errEmit)
+	s.Equal([]int64{1, 2, 3}, actual)
+}
+
+type testChangeWriter struct {
+	opened  bool
+	changes []handlers.ChangeResponseSchema
+	cancel  func()
+}
+
+func (w *testChangeWriter) Open() error {
+	w.opened = true
+	return nil
+}
+
+func (w *testChangeWriter) WriteChange(c handlers.ChangeResponseSchema) error {
+	w.changes = append(w.changes, c)
+	w.cancel()
+	return nil
+}
+
+func (s *ChangeLogTestSuite) TestStreamChanges() {
+	rtID := uuid.New()
+	propertyID := uuid.New()
+	recordID := uuid.New()
+	changedAt := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	value := domain.Change{ID: 11, DataType: domain.ChangedDataValue, RefTypeID: rtID, PropertyID: propertyID, RecordID: recordID, ChangedAt: changedAt}
+	filter := domain.ChangeFilter{RefTypeIDs: []uuid.UUID{rtID}, PropertyIDs: []uuid.UUID{propertyID}}
+	s.repo.
+		On("GetLastChangeID", mock.Anything).Return(int64(10), nil).Once().
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{AfterID: 10, Limit: 1000}).Return([]domain.Change{value}, nil).Once().
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{AfterID: 3, Filter: filter, Limit: 1000}).
+		Return([]domain.Change{{ID: 4, DataType: domain.ChangedDataRefType, RefTypeID: rtID, ChangedAt: changedAt}}, nil).Once()
+
+	type testCase struct {
+		name       string
+		req        handlers.ChangeStreamRequestSchema
+		wantStatus int
+		wantField  string
+		want       []handlers.ChangeResponseSchema
+	}
+	rtIDStr := rtID.String()
+	propertyIDStr := propertyID.String()
+	recordIDStr := recordID.String()
+	cases := []testCase{
+		{
+			name:       "new changes",
+			wantStatus: http.StatusOK,
+			want: []handlers.ChangeResponseSchema{
+				{ID: 11, Type: "value", RefTypeID: &rtIDStr, PropertyID: &propertyIDStr, RecordID: &recordIDStr, ChangedAt: changedAt},
+			},
+		},
+		{
+			name: "resumed by the last event ID",
+			req: handlers.ChangeStreamRequestSchema{
+				After:       "1",
+				LastEventID: "3",
+				RefTypeIDs:  []string{rtIDStr},
+				PropertyIDs: []string{propertyIDStr},
+			},
+			wantStatus: http.StatusOK,
+			want: []handlers.ChangeResponseSchema{
+				{ID: 4, Type: "ref_type", RefTypeID: &rtIDStr, ChangedAt: changedAt},
+			},
+		},
+		{
+			name:       "invalid after",
+			req:        handlers.ChangeStreamRequestSchema{After: "x"},
+			wantStatus: http.StatusBadRequest,
+			wantField:  "after",
+		},
+		{
+			name:       "negative last event ID",
+			req:        handlers.ChangeStreamRequestSchema{LastEventID: "-1"},
+			wantStatus: http.StatusBadRequest,
+			wantField:  "last_event_id",
+		},
+		{
+			name:       "invalid property",
+			req:        handlers.ChangeStreamRequestSchema{PropertyIDs: []string{"x"}},
+			wantStatus: http.StatusBadRequest,
+			wantField:  "property_id",
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			w := &testChangeWriter{cancel: cancel}
+			res, err := handlers.StreamChanges(ctx, s.man, c.req, w)
+			s.Equal(c.wantStatus, res.Status)
+			if c.wantField != "" {
+				s.Equal(c.wantField, domain.ErrorField(err))
+				s.False(w.opened)
+				return
+			}
+			s.NoError(err)
+			s.True(w.opened)
+			s.Equal(c.want, w.changes)
+		})
+	}
+}
+
+// serveChanges returns the test HTTP server of the REST API streaming changes of the repository.
+func (s *ChangeLogTestSuite) serveChanges() *httptest.Server {
+	c, _ := newTestServerConfig(s.T())
+	c.ChangeLogManager = s.man
+	srv, err := rest.NewServer(c)
+	s.Require().NoError(err)
+	r, ok := srv.(interface{ Routes() chi.Routes })
+	s.Require().True(ok)
+	handler, ok := r.Routes().(http.Handler)
+	s.Require().True(ok)
+	ts := httptest.NewServer(handler)
+	s.T().Cleanup(ts.Close)
+	return ts
+}
+
+func (s *ChangeLogTestSuite) TestServerSentEvents() {
+	rtID := uuid.New()
+	recordID := uuid.New()
+	s.repo.
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{
+			AfterID: 3,
+			Filter:  domain.ChangeFilter{RefTypeIDs: []uuid.UUID{rtID}},
+			Limit:   1000,
+		}).
+		Return([]domain.Change{{ID: 4, DataType: domain.ChangedDataRecord, RefTypeID: rtID, RecordID: recordID}}, nil).Once()
+	ts := s.serveChanges()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/changes/stream?after=1&reference_type_id="+rtID.String(), nil)
+	s.Require().NoError(err)
+	req.Header.Set("Last-Event-ID", "3")
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Equal(http.StatusOK, resp.StatusCode)
+	s.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && scanner.Text() != "" {
+		lines = append(lines, scanner.Text())
+	}
+	s.Require().Len(lines, 3)
+	s.Equal("id: 4", lines[0])
+	s.Equal("event: record", lines[1])
+	var actual handlers.ChangeResponseSchema
+	s.Require().NoError(json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &actual))
+	s.Equal(int64(4), actual.ID)
+	s.Equal(recordID.String(), *actual.RecordID)
+	s.Nil(actual.PropertyID)
+
+	resp, err = http.Get(ts.URL + "/v1/changes/stream?reference_type_id=x")
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Equal(http.StatusBadRequest, resp.StatusCode)
+	s.Equal("application/problem+json", resp.Header.Get("Content-Type"))
+}
+
+func (s *ChangeLogTestSuite) TestWebSocket() {
+	propertyID := uuid.New()
+	s.repo.
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{
+			AfterID: 7,
+			Filter:  domain.ChangeFilter{PropertyIDs: []uuid.UUID{propertyID}},
+			Limit:   1000,
+		}).
+		Return([]domain.Change{{ID: 8, DataType: domain.ChangedDataProperty, PropertyID: propertyID}}, nil).Once()
+	ts := s.serveChanges()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/v1/changes/ws?after=7&property_id=" + propertyID.String()
+	conn, err := websocket.Dial(wsURL, "", ts.URL)
+	s.Require().NoError(err)
+	defer conn.Close()
+	var actual handlers.ChangeResponseSchema
+	s.Require().NoError(websocket.JSON.Receive(conn, &actual))
+	s.Equal(int64(8), actual.ID)
+	s.Equal("property", actual.Type)
+	s.Equal(propertyID.String(), *actual.PropertyID)
+}
+
+func (s *ChangeLogTestSuite) TestGetChangeFeed() {
+	rtID := uuid.New()
+	s.repo.
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{AfterID: 4, Limit: 100}).
+		Return([]domain.Change{{ID: 5, DataType: domain.ChangedDataRefType, RefTypeID: rtID}, {ID: 7, DataType: domain.ChangedDataRefType, RefTypeID: rtID}}, nil).Once().
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{AfterID: 9, Filter: domain.ChangeFilter{RefTypeIDs: []uuid.UUID{rtID}}, Limit: 1000}).
+		Return(nil, nil).Once().
+		On("GetChangeConsumer", mock.Anything, "erp").Return(&domain.ChangeConsumer{Name: "erp", LastChangeID: 4}, nil).Once().
+		On("GetChangeConsumer", mock.Anything, "gone").Return(nil, domain.ErrChangeConsumerNotFound).Once()
+
+	type testCase struct {
+		name       string
+		req        handlers.ChangeFeedRequestSchema
+		wantStatus int
+		wantField  string
+		wantIDs    []int64
+		wantNext   int64
+	}
+	cases := []testCase{
+		{
+			name:       "cursor of the consumer",
+			req:        handlers.ChangeFeedRequestSchema{Consumer: "erp"},
+			wantStatus: http.StatusOK,
+			wantIDs:    []int64{5, 7},
+			wantNext:   7,
+		},
+		{
+			name:       "after takes precedence over the consumer, limit is clamped",
+			req:        handlers.ChangeFeedRequestSchema{After: "9", Consumer: "erp", Limit: "5000", RefTypeIDs: []string{rtID.String()}},
+			wantStatus: http.StatusOK,
+			wantIDs:    []int64{},
+			wantNext:   9,
+		},
+		{
+			name:       "consumer not found",
+			req:        handlers.ChangeFeedRequestSchema{Consumer: "gone"},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid consumer",
+			req:        handlers.ChangeFeedRequestSchema{Consumer: "erp/1"},
+			wantStatus: http.StatusBadRequest,
+			wantField:  "consumer",
+		},
+		{
+			name:       "invalid limit",
+			req:        handlers.ChangeFeedRequestSchema{Limit: "0"},
+			wantStatus: http.StatusBadRequest,
+			wantField:  "limit",
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			res, err := handlers.GetChangeFeed(context.Background(), s.man, c.req)
+			s.Equal(c.wantStatus, res.Status)
+			if c.wantStatus != http.StatusOK {
+				s.Error(err)
+				s.Equal(c.wantField, domain.ErrorField(err))
+				return
+			}
+			s.Require().NoError(err)
+			var actual handlers.ChangeFeedResponseSchema
+			s.Require().NoError(json.Unmarshal(res.Payload, &actual))
+			ids := []int64{}
+			for _, ch := range actual.Changes {
+				ids = append(ids, ch.ID)
+			}
+			s.Equal(c.wantIDs, ids)
+			s.Equal(c.wantNext, actual.Next)
+		})
+	}
+}
+
+// Change 6 is committed while change 5 is in progress, so the log is read up to 5 and the cursor
+// stays before it until it is committed.
+func (s *ChangeLogTestSuite) TestGetChangeFeedLateCommit() {
+	s.repo.
+		On("GetChangeConsumer", mock.Anything, "erp").Return(&domain.ChangeConsumer{Name: "erp", LastChangeID: 4}, nil).Twice().
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{AfterID: 4, Limit: 100}).Return(nil, nil).Once().
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{AfterID: 4, Limit: 100}).
+		Return([]domain.Change{{ID: 5, DataType: domain.ChangedDataRecord}, {ID: 6, DataType: domain.ChangedDataRecord}}, nil).Once()
+
+	get := func() handlers.ChangeFeedResponseSchema {
+		res, err := handlers.GetChangeFeed(context.Background(), s.man, handlers.ChangeFeedRequestSchema{Consumer: "erp"})
+		s.Require().NoError(err)
+		var actual handlers.ChangeFeedResponseSchema
+		s.Require().NoError(json.Unmarshal(res.Payload, &actual))
+		return actual
+	}
+	actual := get()
+	s.Empty(actual.Changes)
+	s.Equal(int64(4), actual.Next)
+
+	actual = get()
+	s.Require().Len(actual.Changes, 2)
+	s.Equal(int64(5), actual.Changes[0].ID)
+	s.Equal(int64(6), actual.Next)
+}
+
+func (s *ChangeLogTestSuite) TestChangeConsumers() {
+	s.repo.
+		On("SetChangeConsumer", mock.Anything, "erp", int64(12)).Return(&domain.ChangeConsumer{Name: "erp", LastChangeID: 12}, nil).Once().
+		On("DeleteChangeConsumer", mock.Anything, "gone").Return(domain.ErrChangeConsumerNotFound).Once()
+	ts := s.serveChanges()
+
+	put := func(name, body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPut, ts.URL+"/v1/changes/consumers/"+name, strings.NewReader(body))
+		s.Require().NoError(err)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+		s.T().Cleanup(func() {
+			_ = resp.Body.Close()
+		})
+		return resp
+	}
+	resp := put("erp", `{"last_change_id": 12}`)
+	s.Equal(http.StatusOK, resp.StatusCode)
+	var actual handlers.ChangeConsumerResponseSchema
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&actual))
+	s.Equal(handlers.ChangeConsumerResponseSchema{Name: "erp", LastChangeID: 12}, actual)
+
+	s.Equal(http.StatusBadRequest, put("erp", `{}`).StatusCode)
+	s.Equal(http.StatusBadRequest, put("erp", `{"last_change_id": -1}`).StatusCode)
+
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+"/v1/changes/consumers/gone", nil)
+	s.Require().NoError(err)
+	resp, err = http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Equal(http.StatusNotFound, resp.StatusCode)
+}
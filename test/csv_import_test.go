@@ -0,0 +1,251 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/test/mocks"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type CSVImportHandlersTestSuite struct {
+	suite.Suite
+	rtm         *api.RefTypeManager
+	pm          *api.PropertyManager
+	im          *api.ImportManager
+	tb          *testTransactionBeginner
+	refTypeRepo *mocks.RefTypeRepository
+	propRepo    *mocks.PropertyRepository
+	recordRepo  *mocks.RecordRepository
+	valueRepo   *mocks.ValueRepository
+}
+
+func TestCSVImportHandlers(t *testing.T) {
+	suite.Run(t, new(CSVImportHandlersTestSuite))
+}
+
+func (s *CSVImportHandlersTestSuite) SetupTest() {
+	s.rtm, s.refTypeRepo, _ = newTestRefTypeMockedManager(s.T())
+	s.pm, s.propRepo, _ = newTestPropertyMockedManager(s.T())
+	s.im, s.tb, s.recordRepo, s.valueRepo = newTestImportMockedManager(s.T())
+}
+
+var (
+	csvRefTypeID  = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	csvPriceID    = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	csvDeliveryID = uuid.MustParse("33333333-3333-3333-3333-333333333333")
+)
+
+func (s *CSVImportHandlersTestSuite) expectMapping() {
+	s.refTypeRepo.
+		On("GetRefType", mock.Anything, csvRefTypeID).Return(&domain.RefType{ID: csvRefTypeID}, nil).Once()
+	s.propRepo.
+		On("GetProperty", mock.Anything, csvPriceID).
+		Return(&domain.Property{ID: csvPriceID, Types: []domain.Type{domain.TypeNumber}}, nil).Once().
+		On("GetProperty", mock.Anything, csvDeliveryID).
+		Return(&domain.Property{ID: csvDeliveryID, Types: []domain.Type{domain.TypeDate, domain.TypeText}}, nil).Once()
+}
+
+func csvImportRequest(dryRun bool) handlers.CSVImportRequestSchema {
+	return handlers.CSVImportRequestSchema{
+		RefTypeID: csvRefTypeID.String(),
+		DryRun:    dryRun,
+		Mapping: handlers.CSVMappingSchema{
+			Header:    true,
+			Delimiter: ";",
+			Columns: []handlers.CSVColumnSchema{
+				{Header: "Name", Target: handlers.CSVTargetName},
+				{Header: "Price", Target: handlers.CSVTargetProperty, PropertyID: csvPriceID.String(), DecimalSeparator: ",", ThousandsSeparator: " "},
+				{Header: "Delivery", Target: handlers.CSVTargetProperty, PropertyID: csvDeliveryID.String(), Type: "date", DateLayouts: []string{"02.01.2006"}},
+			},
+		},
+	}
+}
+
+func (s *CSVImportHandlersTestSuite) TestImportCSV() {
+	s.expectMapping()
+	rID1 := uuid.MustParse("44444444-4444-4444-4444-444444444444")
+	rID2 := uuid.MustParse("55555555-5555-5555-5555-555555555555")
+	s.recordRepo.
+		On("AddRecord", mock.Anything, domain.AddRecordRequest{Name: "chair", ReferenceTypeID: csvRefTypeID}, mock.Anything).Return(rID1, nil).Once().
+		On("AddRecord", mock.Anything, domain.AddRecordRequest{Name: "table", ReferenceTypeID: csvRefTypeID}, mock.Anything).Return(rID2, nil).Once()
+	s.valueRepo.
+		On("SetValue", mock.Anything, domain.SetValueRequest{
+			RecordID: rID1, PropertyID: csvPriceID, Type: domain.TypeNumber, Value: 1250.5,
+		}, mock.Anything).Return(&domain.Value{}, nil).Once().
+		On("SetValue", mock.Anything, domain.SetValueRequest{
+			RecordID: rID1, PropertyID: csvDeliveryID, Type: domain.TypeDate, Value: time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC),
+		}, mock.Anything).Return(&domain.Value{}, nil).Once().
+		On("SetValue", mock.Anything, domain.SetValueRequest{
+			RecordID: rID2, PropertyID: csvPriceID, Type: domain.TypeNumber, Value: float64(99),
+		}, mock.Anything).Return(&domain.Value{}, nil).Once()
+	// Empty cells are not set
+	csv := "Name;Price;Delivery\nchair;1 250,5;31.07.2023\ntable;99;\n"
+
+	actual, err := handlers.ImportCSV(context.Background(), s.rtm, s.pm, s.im, csvImportRequest(false), strings.NewReader(csv))
+	s.Require().NoError(err)
+	s.Equal(handlers.Result{
+		Status:  http.StatusOK,
+		Payload: []byte(`{"dry_run":false,"rows":2,"valid":2,"imported":2,"errors":[]}`),
+	}, actual)
+	s.True(s.tb.tx.committed)
+	s.False(s.tb.tx.rolledBack)
+	s.Len(s.tb.tx.savepoints, 2)
+}
+
+func (s *CSVImportHandlersTestSuite) TestImportCSVRowErrors() {
+	for _, dryRun := range []bool{true, false} {
+		s.SetupTest()
+		s.expectMapping()
+		rID := uuid.MustParse("44444444-4444-4444-4444-444444444444")
+		s.recordRepo.
+			On("AddRecord", mock.Anything, domain.AddRecordRequest{Name: "chair", ReferenceTypeID: csvRefTypeID}, mock.Anything).Return(rID, nil).Once().
+			On("AddRecord", mock.Anything, domain.AddRecordRequest{Name: "lamp", ReferenceTypeID: csvRefTypeID}, mock.Anything).Return(rID, nil).Once()
+		s.valueRepo.
+			On("SetValue", mock.Anything, domain.SetValueRequest{
+				RecordID: rID, PropertyID: csvPriceID, Type: domain.TypeNumber, Value: float64(10),
+			}, mock.Anything).Return(&domain.Value{}, nil).Once().
+			On("SetValue", mock.Anything, domain.SetValueRequest{
+				RecordID: rID, PropertyID: csvPriceID, Type: domain.TypeNumber, Value: float64(20),
+			}, mock.Anything).Return(nil, domain.ErrUnexpectedTypePG).Once()
+		csv := "Name;Price;Delivery\nchair;10;\ntable;ten;2023-07-31\nlamp;20;\nsofa\n"
+		payload := `{"dry_run":` + map[bool]string{true: "true", false: "false"}[dryRun] + `,"rows":4,"valid":1,"imported":0,"errors":[` +
+			`{"row":3,"column":"Price","error":"parse number error: strconv.ParseFloat: parsing \"ten\": invalid syntax"},` +
+			`{"row":3,"column":"Delivery","error":"parse date error: parsing time \"2023-07-31\" as \"02.01.2006\": cannot parse \"23-07-31\" as \".\""},` +
+			`{"row":4,"column":"Price","error":"unexpected type"},` +
+			`{"row":5,"column":"Price","error":"column missing"},` +
+			`{"row":5,"column":"Delivery","error":"column missing"}]}`
+
+		actual, err := handlers.ImportCSV(context.Background(), s.rtm, s.pm, s.im, csvImportRequest(dryRun), strings.NewReader(csv))
+		if dryRun {
+			s.Require().NoError(err)
+			s.Equal(http.StatusOK, actual.Status)
+		} else {
+			s.Require().Error(err)
+			s.Equal(http.StatusBadRequest, actual.Status)
+		}
+		s.JSONEq(payload, string(actual.Payload))
+		s.False(s.tb.tx.committed)
+		s.True(s.tb.tx.rolledBack)
+		s.Require().Len(s.tb.tx.savepoints, 2)
+		s.True(s.tb.tx.savepoints[0].committed)
+		s.True(s.tb.tx.savepoints[1].rolledBack)
+	}
+}
+
+func (s *CSVImportHandlersTestSuite) TestImportCSVMalformedRows() {
+	s.expectMapping()
+	rID := uuid.MustParse("44444444-4444-4444-4444-444444444444")
+	s.recordRepo.
+		On("AddRecord", mock.Anything, domain.AddRecordRequest{Name: "chair", ReferenceTypeID: csvRefTypeID}, mock.Anything).Return(rID, nil).Once()
+	s.valueRepo.
+		On("SetValue", mock.Anything, domain.SetValueRequest{
+			RecordID: rID, PropertyID: csvPriceID, Type: domain.TypeNumber, Value: float64(10),
+		}, mock.Anything).Return(&domain.Value{}, nil).Once()
+	// Lines of malformed rows are taken from errors of the reader, they have no positions of fields
+	csv := "Name;Price;Delivery\nchair;10;\na\"b;1;\n\"abc\n"
+	payload := `{"dry_run":false,"rows":3,"valid":1,"imported":0,"errors":[` +
+		`{"row":3,"error":"parse error on line 3, column 2: bare \" in non-quoted-field"},` +
+		`{"row":4,"error":"parse error on line 4, column 6: extraneous or missing \" in quoted-field"}]}`
+
+	actual, err := handlers.ImportCSV(context.Background(), s.rtm, s.pm, s.im, csvImportRequest(false), strings.NewReader(csv))
+	s.Require().Error(err)
+	s.Equal(http.StatusBadRequest, actual.Status)
+	s.JSONEq(payload, string(actual.Payload))
+	s.False(s.tb.tx.committed)
+	s.True(s.tb.tx.rolledBack)
+}
+
+func (s *CSVImportHandlersTestSuite) TestImportCSVAbortRollback() {
+	s.expectMapping()
+	s.recordRepo.
+		On("AddRecord", mock.Anything, domain.AddRecordRequest{Name: "chair", ReferenceTypeID: csvRefTypeID}, mock.Anything).
+		Return(uuid.Nil, errors.New("connection lost")).Once()
+
+	actual, err := handlers.ImportCSV(context.Background(), s.rtm, s.pm, s.im, csvImportRequest(false), strings.NewReader("Name;Price;Delivery\nchair;10;\n"))
+	s.Require().EqualError(err, "connection lost")
+	s.Equal(handlers.Result{Status: http.StatusInternalServerError}, actual)
+	s.False(s.tb.tx.committed)
+	s.True(s.tb.tx.rolledBack)
+}
+
+func (s *CSVImportHandlersTestSuite) TestImportCSVMappingErrors() {
+	type testCase struct {
+		name     string
+		prepare  func()
+		req      handlers.CSVImportRequestSchema
+		csv      string
+		expected handlers.Result
+		wantErr  error
+	}
+	req := csvImportRequest(false)
+	unknownColumn := csvImportRequest(false)
+	unknownColumn.Mapping.Columns = []handlers.CSVColumnSchema{{Header: "Color", Target: handlers.CSVTargetName}}
+	wrongType := csvImportRequest(false)
+	wrongType.Mapping.Columns = []handlers.CSVColumnSchema{{Index: new(int), Target: handlers.CSVTargetProperty, PropertyID: csvDeliveryID.String()}}
+	cases := []testCase{
+		{
+			name: "reference type not found",
+			prepare: func() {
+				s.refTypeRepo.On("GetRefType", mock.Anything, csvRefTypeID).Return(nil, domain.ErrRefTypeNotFound).Once()
+			},
+			req:      req,
+			csv:      "Name;Price;Delivery\n",
+			expected: handlers.Result{Status: http.StatusNotFound},
+			wantErr:  domain.ErrRefTypeNotFound,
+		},
+		{
+			name: "unknown column",
+			prepare: func() {
+				s.refTypeRepo.On("GetRefType", mock.Anything, csvRefTypeID).Return(&domain.RefType{ID: csvRefTypeID}, nil).Once()
+			},
+			req:      unknownColumn,
+			csv:      "Name;Price;Delivery\n",
+			expected: handlers.Result{Status: http.StatusBadRequest},
+		},
+		{
+			name: "property type required",
+			prepare: func() {
+				s.refTypeRepo.On("GetRefType", mock.Anything, csvRefTypeID).Return(&domain.RefType{ID: csvRefTypeID}, nil).Once()
+				s.propRepo.On("GetProperty", mock.Anything, csvDeliveryID).
+					Return(&domain.Property{ID: csvDeliveryID, Types: []domain.Type{domain.TypeDate, domain.TypeText}}, nil).Once()
+			},
+			req:      wrongType,
+			csv:      "Name;Price;Delivery\n",
+			expected: handlers.Result{Status: http.StatusBadRequest},
+			wantErr:  domain.ErrExpected,
+		},
+		{
+			name: "empty CSV",
+			prepare: func() {
+				s.refTypeRepo.On("GetRefType", mock.Anything, csvRefTypeID).Return(&domain.RefType{ID: csvRefTypeID}, nil).Once()
+			},
+			req:      req,
+			expected: handlers.Result{Status: http.StatusBadRequest},
+			wantErr:  domain.ErrExpected,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			s.SetupTest()
+			c.prepare()
+			actual, err := handlers.ImportCSV(context.Background(), s.rtm, s.pm, s.im, c.req, strings.NewReader(c.csv))
+			s.Require().Error(err)
+			if c.wantErr != nil {
+				s.True(errors.Is(err, c.wantErr), err.Error())
+			}
+			s.Equal(c.expected, actual)
+			s.Nil(s.tb.tx)
+		})
+	}
+}
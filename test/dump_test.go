@@ -0,0 +1,284 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/test/mocks"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type DumpHandlersTestSuite struct {
+	suite.Suite
+	man        *api.DumpManager
+	repo       *mocks.DumpRepository
+	recordRepo *mocks.RecordRepository
+}
+
+func TestDumpHandlers(t *testing.T) {
+	suite.Run(t, new(DumpHandlersTestSuite))
+}
+
+func (s *DumpHandlersTestSuite) SetupTest() {
+	s.man, s.repo, s.recordRepo = newTestDumpMockedManager(s.T())
+}
+
+func testDumpItems() []domain.DumpItem {
+	rtID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	pID := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	rID := uuid.MustParse("33333333-3333-3333-3333-333333333333")
+	return []domain.DumpItem{
+		{
+			Kind:    domain.DumpItemRefType,
+			RefType: &domain.RefType{ID: rtID, Name: "orders"},
+		},
+		{
+			Kind: domain.DumpItemProperty,
+			Property: &domain.Property{
+				ID:             pID,
+				Name:           "number",
+				Types:          []domain.Type{domain.TypeText},
+				RefTypeIDs:     []uuid.UUID{},
+				OwnerRefTypeID: rtID,
+				Kind:           domain.PropertyKindSequence,
+				Sequence:       &domain.Sequence{Prefix: "ORD-", Template: "{prefix}{n}"},
+			},
+		},
+		{
+			Kind:            domain.DumpItemSequenceCounter,
+			SequenceCounter: &domain.SequenceCounter{PropertyID: pID, LastNumber: 1},
+		},
+		{
+			Kind:   domain.DumpItemRecord,
+			Record: &domain.Record{ID: rID, ReferenceTypeID: rtID, Name: "order"},
+		},
+		{
+			Kind:  domain.DumpItemValue,
+			Value: &domain.Value{RecordID: rID, PropertyID: pID, Type: domain.TypeText, Value: "ORD-1"},
+		},
+	}
+}
+
+const testDump = `{"kind":"reference_type","data":{"id":"11111111-1111-1111-1111-111111111111","name":"orders","description":""}}
+{"kind":"property","data":{"id":"22222222-2222-2222-2222-222222222222","name":"number","description":"","types":["text"],"reference_type_ids":null,"owner_reference_type_id":"11111111-1111-1111-1111-111111111111","kind":"sequence","sequence":{"prefix":"ORD-","template":"{prefix}{n}","reset_period":"never"}}}
+{"kind":"sequence_counter","data":{"property_id":"22222222-2222-2222-2222-222222222222","period":"","last_number":1}}
+{"kind":"record","data":{"id":"33333333-3333-3333-3333-333333333333","name":"order","description":"","deletion_mark":false,"reference_type_id":"11111111-1111-1111-1111-111111111111"}}
+{"kind":"value","data":{"record_id":"33333333-3333-3333-3333-333333333333","property_id":"22222222-2222-2222-2222-222222222222","type":"text","reference_type_id":null,"value":"ORD-1"}}
+`
+
+func (s *DumpHandlersTestSuite) TestExportDump() {
+	s.repo.
+		On("ExportDump", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			emit := args.Get(1).(func(domain.DumpItem) error)
+			for _, item := range testDumpItems() {
+				s.Require().NoError(emit(item))
+			}
+		}).
+		Return(nil).Once()
+
+	var buf bytes.Buffer
+	actual, err := handlers.ExportDump(context.Background(), s.man, &buf)
+	s.Require().NoError(err)
+	s.Equal(handlers.Result{Status: http.StatusOK}, actual)
+	s.Equal(testDump, buf.String())
+}
+
+func (s *DumpHandlersTestSuite) TestExportDumpError() {
+	s.repo.
+		On("ExportDump", mock.Anything, mock.Anything).Return(errors.New("error")).Once()
+
+	var buf bytes.Buffer
+	actual, err := handlers.ExportDump(context.Background(), s.man, &buf)
+	s.Error(err)
+	s.Equal(handlers.Result{Status: http.StatusInternalServerError}, actual)
+}
+
+func (s *DumpHandlersTestSuite) TestExportDumpACL() {
+	items := testDumpItems()
+	hidden := uuid.MustParse("44444444-4444-4444-4444-444444444444")
+	items = append(items[:4:4],
+		domain.DumpItem{
+			Kind:   domain.DumpItemRecord,
+			Record: &domain.Record{ID: hidden, ReferenceTypeID: items[0].RefType.ID, Name: "salary", ACL: aclHR},
+		},
+		items[4],
+		domain.DumpItem{
+			Kind:  domain.DumpItemValue,
+			Value: &domain.Value{RecordID: hidden, PropertyID: items[1].Property.ID, Type: domain.TypeText, Value: "ORD-2"},
+		},
+	)
+	s.repo.
+		On("ExportDump", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			emit := args.Get(1).(func(domain.DumpItem) error)
+			for _, item := range items {
+				s.Require().NoError(emit(item))
+			}
+		}).
+		Return(nil).Twice()
+
+	// The record and its value are hidden from the employee by the ACL of the record
+	var buf bytes.Buffer
+	_, err := handlers.ExportDump(api.ContextWithPrincipal(context.Background(), aclEmployee), s.man, &buf)
+	s.Require().NoError(err)
+	s.Equal(testDump, buf.String())
+
+	buf.Reset()
+	_, err = handlers.ExportDump(api.ContextWithPrincipal(context.Background(), aclAuditor), s.man, &buf)
+	s.Require().NoError(err)
+	s.Contains(buf.String(), `"id":"44444444-4444-4444-4444-444444444444"`)
+	s.Contains(buf.String(), `"value":"ORD-2"`)
+}
+
+func (s *DumpHandlersTestSuite) TestImportDumpACL() {
+	rID := testDumpItems()[3].Record.ID
+	s.repo.
+		On("ImportDump", mock.Anything, mock.Anything, domain.ImportDumpOptions{}).
+		Return(func(_ context.Context, src domain.DumpSource, _ domain.ImportDumpOptions) (*domain.DumpStats, error) {
+			if _, err := drainDumpSource(src); err != nil {
+				return nil, err
+			}
+			return &domain.DumpStats{}, nil
+		})
+	s.recordRepo.
+		On("GetRecordACLs", mock.Anything, []uuid.UUID{rID}).Return(map[uuid.UUID]domain.ACL{rID: aclHR}, nil)
+
+	// The record is checked once for the record and its value
+	actual, err := handlers.ImportDump(api.ContextWithPrincipal(context.Background(), aclHRUser), s.man, strings.NewReader(testDump), domain.ImportDumpOptions{})
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, actual.Status)
+	s.recordRepo.AssertNumberOfCalls(s.T(), "GetRecordACLs", 1)
+
+	actual, err = handlers.ImportDump(api.ContextWithPrincipal(context.Background(), aclAuditor), s.man, strings.NewReader(testDump), domain.ImportDumpOptions{})
+	s.ErrorIs(err, domain.ErrForbidden)
+	s.Equal(http.StatusForbidden, actual.Status)
+
+	actual, err = handlers.ImportDump(api.ContextWithPrincipal(context.Background(), aclEmployee), s.man, strings.NewReader(testDump), domain.ImportDumpOptions{})
+	s.ErrorIs(err, domain.ErrNotFound)
+	s.Equal(http.StatusNotFound, actual.Status)
+}
+
+// drainDumpSource reads the source like the repository does and returns its error if any.
+func drainDumpSource(src domain.DumpSource) ([]domain.DumpItem, error) {
+	var out []domain.DumpItem
+	for {
+		item, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, *item)
+	}
+}
+
+func (s *DumpHandlersTestSuite) TestImportDump() {
+	opts := domain.ImportDumpOptions{RegisterChanges: true}
+	var imported []domain.DumpItem
+	s.repo.
+		On("ImportDump", mock.Anything, mock.Anything, opts).
+		Return(func(_ context.Context, src domain.DumpSource, _ domain.ImportDumpOptions) (*domain.DumpStats, error) {
+			var err error
+			imported, err = drainDumpSource(src)
+			if err != nil {
+				return nil, err
+			}
+			return &domain.DumpStats{RefTypes: 1, Properties: 1, SequenceCounters: 1, Records: 1, Values: 1}, nil
+		}).Once()
+
+	// Empty lines are skipped
+	actual, err := handlers.ImportDump(context.Background(), s.man, strings.NewReader(testDump+"\n"), opts)
+	s.Require().NoError(err)
+	s.Equal(handlers.Result{
+		Status:  http.StatusOK,
+		Payload: []byte(`{"reference_types":1,"properties":1,"sequence_counters":1,"records":1,"values":1}`),
+	}, actual)
+	s.Equal(testDumpItems(), imported)
+}
+
+func (s *DumpHandlersTestSuite) TestImportDumpErrors() {
+	type testCase struct {
+		name     string
+		dump     string
+		repoErr  error
+		expected handlers.Result
+		wantErr  error
+	}
+	cases := []testCase{
+		{
+			name:     "broken line",
+			dump:     "{\"kind\":\"reference_type\"\n",
+			expected: handlers.Result{Status: http.StatusBadRequest},
+			wantErr:  domain.ErrParseError,
+		},
+		{
+			name:     "unknown kind",
+			dump:     `{"kind":"user","data":{}}`,
+			expected: handlers.Result{Status: http.StatusBadRequest},
+			wantErr:  domain.ErrParseError,
+		},
+		{
+			name:     "wrong value",
+			dump:     `{"kind":"value","data":{"record_id":"33333333-3333-3333-3333-333333333333","property_id":"22222222-2222-2222-2222-222222222222","type":"number","value":"one"}}`,
+			expected: handlers.Result{Status: http.StatusBadRequest},
+			wantErr:  domain.ErrParseError,
+		},
+		{
+			name:     "out of order",
+			repoErr:  fmt.Errorf("%w: record after value", domain.ErrDumpItemOrder),
+			expected: handlers.Result{Status: http.StatusBadRequest},
+			wantErr:  domain.ErrDumpItemOrder,
+		},
+		{
+			name:     "duplicated",
+			repoErr:  fmt.Errorf("record 33333333-3333-3333-3333-333333333333: %w", domain.ErrDumpItemDuplicated),
+			expected: handlers.Result{Status: http.StatusBadRequest},
+			wantErr:  domain.ErrDumpItemDuplicated,
+		},
+		{
+			name:     "PG check",
+			repoErr:  fmt.Errorf("property 22222222-2222-2222-2222-222222222222: %w", domain.ErrSequenceTemplatePG),
+			expected: handlers.Result{Status: http.StatusBadRequest},
+			wantErr:  domain.ErrSequenceTemplatePG,
+		},
+		{
+			name:     "internal error",
+			repoErr:  errors.New("error"),
+			expected: handlers.Result{Status: http.StatusInternalServerError},
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			call := s.repo.
+				On("ImportDump", mock.Anything, mock.Anything, domain.ImportDumpOptions{}).
+				Return(func(_ context.Context, src domain.DumpSource, _ domain.ImportDumpOptions) (*domain.DumpStats, error) {
+					if _, err := drainDumpSource(src); err != nil {
+						return nil, err
+					}
+					return nil, c.repoErr
+				}).Once()
+			defer call.Unset()
+
+			actual, err := handlers.ImportDump(context.Background(), s.man, strings.NewReader(c.dump), domain.ImportDumpOptions{})
+			s.Require().Error(err)
+			if c.wantErr != nil {
+				s.ErrorIs(err, c.wantErr)
+			}
+			s.Equal(c.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"datatom/internal/adapter/rmq"
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/internal/keyring"
+	"datatom/internal/routines"
+	"datatom/pkg/db"
+	"datatom/test/mocks"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type EncryptionTestSuite struct {
+	suite.Suite
+	macKey string
+	keys   []keyring.KeyConfig
+	kr     *keyring.Keyring
+}
+
+func TestEncryption(t *testing.T) {
+	suite.Run(t, new(EncryptionTestSuite))
+}
+
+func (s *EncryptionTestSuite) SetupTest() {
+	s.macKey = s.newKey()
+	s.keys = []keyring.KeyConfig{{ID: "k1", Key: s.newKey()}, {ID: "k2", Key: s.newKey()}}
+	var err error
+	s.kr, err = keyring.New(keyring.Config{Primary: "k1", MACKey: s.macKey, Keys: s.keys})
+	s.Require().NoError(err)
+}
+
+func (s *EncryptionTestSuite) newKey() string {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	s.Require().NoError(err)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func (s *EncryptionTestSuite) TestNew() {
+	_, err := keyring.New(keyring.Config{Primary: "k3", MACKey: s.macKey, Keys: s.keys})
+	s.Error(err)
+	_, err = keyring.New(keyring.Config{Primary: "k1", MACKey: "c2hvcnQ=", Keys: s.keys})
+	s.Error(err)
+	_, err = keyring.New(keyring.Config{Primary: "k1", MACKey: s.macKey, Keys: append(s.keys, s.keys[0])})
+	s.Error(err)
+}
+
+func (s *EncryptionTestSuite) TestRoundTrip() {
+	cases := []domain.Value{
+		{Type: domain.TypeText, Value: "secret"},
+		{Type: domain.TypeNumber, Value: 7.5},
+		{Type: domain.TypeBool, Value: false},
+		{Type: domain.TypeDate, Value: time.Date(2023, 2, 13, 21, 21, 21, 0, time.UTC)},
+		{Type: domain.TypeUUID, Value: uuid.New()},
+	}
+	for _, v := range cases {
+		v.RecordID = uuid.New()
+		v.PropertyID = uuid.New()
+		ev, err := s.kr.Encrypt(v)
+		s.Require().NoError(err, v.Type.String())
+		s.Equal("k1", ev.KeyID)
+		s.NotContains(string(ev.Ciphertext), "secret")
+		plain := v
+		plain.Value = ev
+		got, err := s.kr.Decrypt(plain)
+		s.Require().NoError(err, v.Type.String())
+		s.Equal(v.Value, got, v.Type.String())
+	}
+}
+
+func (s *EncryptionTestSuite) TestMAC() {
+	v := domain.Value{RecordID: uuid.New(), PropertyID: uuid.New(), Type: domain.TypeText, Value: "secret"}
+	first, err := s.kr.Encrypt(v)
+	s.Require().NoError(err)
+	second, err := s.kr.Encrypt(v)
+	s.Require().NoError(err)
+	s.NotEqual(first.Ciphertext, second.Ciphertext)
+	s.Equal(first.MAC, second.MAC)
+
+	v.Value = "another secret"
+	changed, err := s.kr.Encrypt(v)
+	s.Require().NoError(err)
+	s.NotEqual(first.MAC, changed.MAC)
+
+	v.Value = "secret"
+	v.RecordID = uuid.New()
+	other, err := s.kr.Encrypt(v)
+	s.Require().NoError(err)
+	s.NotEqual(first.MAC, other.MAC)
+}
+
+func (s *EncryptionTestSuite) TestMaskKey() {
+	s.Len(s.kr.MaskKey(), 32)
+	s.Equal(s.kr.MaskKey(), s.kr.MaskKey())
+	macKey, err := base64.StdEncoding.DecodeString(s.macKey)
+	s.Require().NoError(err)
+	s.NotEqual(macKey, s.kr.MaskKey())
+
+	// The key is of the MAC key only, so hashes do not change with the rotation of keys
+	rotated, err := keyring.New(keyring.Config{Primary: "k2", MACKey: s.macKey, Keys: s.keys})
+	s.Require().NoError(err)
+	s.Equal(s.kr.MaskKey(), rotated.MaskKey())
+}
+
+func (s *EncryptionTestSuite) TestBoundToValue() {
+	v := domain.Value{RecordID: uuid.New(), PropertyID: uuid.New(), Type: domain.TypeText, Value: "secret"}
+	ev, err := s.kr.Encrypt(v)
+	s.Require().NoError(err)
+	v.Value = ev
+	v.RecordID = uuid.New()
+	_, err = s.kr.Decrypt(v)
+	s.Error(err)
+}
+
+func (s *EncryptionTestSuite) TestRotation() {
+	v := domain.Value{RecordID: uuid.New(), PropertyID: uuid.New(), Type: domain.TypeText, Value: "secret"}
+	ev, err := s.kr.Encrypt(v)
+	s.Require().NoError(err)
+	v.Value = ev
+
+	rotated, err := keyring.New(keyring.Config{Primary: "k2", MACKey: s.macKey, Keys: s.keys})
+	s.Require().NoError(err)
+	got, err := rotated.Decrypt(v)
+	s.Require().NoError(err)
+	s.Equal("secret", got)
+	again, err := rotated.Encrypt(domain.Value{RecordID: v.RecordID, PropertyID: v.PropertyID, Type: v.Type, Value: "secret"})
+	s.Require().NoError(err)
+	s.Equal("k2", again.KeyID)
+	s.Equal(ev.MAC, again.MAC)
+
+	dropped, err := keyring.New(keyring.Config{Primary: "k2", MACKey: s.macKey, Keys: s.keys[1:]})
+	s.Require().NoError(err)
+	_, err = dropped.Decrypt(v)
+	s.Error(err)
+}
+
+func (s *EncryptionTestSuite) TestValueJSON() {
+	ev := domain.EncryptedValue{KeyID: "k1", DataKey: []byte{1}, Ciphertext: []byte{2}, MAC: "ab"}
+	b, err := domain.ValueAsJSON(ev, domain.TypeText)
+	s.Require().NoError(err)
+	s.JSONEq(`{"enc":{"kid":"k1","dk":"AQ==","ct":"Ag==","mac":"ab"}}`, string(b))
+
+	var schema domain.ValueJSONSchema
+	s.Require().NoError(json.Unmarshal(b, &schema))
+	got, err := schema.Validated(domain.TypeText)
+	s.Require().NoError(err)
+	s.Equal(ev, got)
+
+	plain, err := domain.ValueAsJSON("text", domain.TypeText)
+	s.Require().NoError(err)
+	s.JSONEq(`{"v":"text"}`, string(plain))
+
+	// Envelopes which are not decrypted are rendered like they are stored
+	b, err = json.Marshal(handlers.ValueToResponseSchema(domain.Value{Type: domain.TypeText, Value: ev}))
+	s.Require().NoError(err)
+	s.Contains(string(b), `"value":{"enc":{"kid":"k1"`)
+}
+
+func (s *EncryptionTestSuite) TestDump() {
+	value := domain.Value{
+		RecordID:   uuid.New(),
+		PropertyID: uuid.New(),
+		Type:       domain.TypeText,
+		Value:      domain.EncryptedValue{KeyID: "k1", DataKey: []byte{1}, Ciphertext: []byte{2}, MAC: "ab"},
+	}
+	schema, err := handlers.DumpItemToSchema(domain.DumpItem{Kind: domain.DumpItemValue, Value: &value})
+	s.Require().NoError(err)
+	item, err := schema.DumpItem()
+	s.Require().NoError(err)
+	s.Equal(value.Value, item.Value.Value)
+
+	property := domain.Property{ID: uuid.New(), Name: "salary", Types: []domain.Type{domain.TypeNumber}, Encrypted: true}
+	schema, err = handlers.DumpItemToSchema(domain.DumpItem{Kind: domain.DumpItemProperty, Property: &property})
+	s.Require().NoError(err)
+	item, err = schema.DumpItem()
+	s.Require().NoError(err)
+	s.True(item.Property.Encrypted)
+}
+
+func (s *EncryptionTestSuite) newValueManager() (*api.ValueManager, *mocks.ValueRepository) {
+	repo := mocks.NewValueRepository(s.T())
+	man, err := api.NewValueManager(api.ValueConfig{
+		Repository:       repo,
+		RecordRepository: mocks.NewRecordRepository(s.T()),
+		Broker:           mocks.NewValueBroker(s.T()),
+		Cipher:           s.kr,
+		Timeout:          time.Second,
+	})
+	s.Require().NoError(err)
+	return man, repo
+}
+
+func (s *EncryptionTestSuite) TestSetValue() {
+	man, repo := s.newValueManager()
+	req := domain.SetValueRequest{RecordID: uuid.New(), PropertyID: uuid.New(), Type: domain.TypeText, Value: "secret"}
+	repo.On("IsEncryptedProperty", mock.Anything, req.PropertyID, nil).Return(true, nil).Once()
+	var stored domain.EncryptedValue
+	repo.On("SetValue", mock.Anything, mock.MatchedBy(func(r domain.SetValueRequest) bool {
+		ev, ok := r.Value.(domain.EncryptedValue)
+		stored = ev
+		return ok && r.RecordID == req.RecordID
+	}), nil).Return(func(_ context.Context, r domain.SetValueRequest, _ db.Transaction) *domain.Value {
+		return &domain.Value{RecordID: r.RecordID, PropertyID: r.PropertyID, Type: r.Type, Value: r.Value}
+	}, nil).Once()
+	got, err := man.Set(context.Background(), req)
+	s.Require().NoError(err)
+	s.Equal("secret", got.Value)
+	s.NotEmpty(stored.MAC)
+
+	plain := domain.SetValueRequest{RecordID: uuid.New(), PropertyID: uuid.New(), Type: domain.TypeText, Value: "public"}
+	repo.On("IsEncryptedProperty", mock.Anything, plain.PropertyID, nil).Return(false, nil).Once()
+	repo.On("SetValue", mock.Anything, plain, nil).Return(&domain.Value{Type: domain.TypeText, Value: "public"}, nil).Once()
+	got, err = man.Set(context.Background(), plain)
+	s.Require().NoError(err)
+	s.Equal("public", got.Value)
+}
+
+func (s *EncryptionTestSuite) TestGetValue() {
+	man, repo := s.newValueManager()
+	v := domain.Value{RecordID: uuid.New(), PropertyID: uuid.New(), Type: domain.TypeNumber, Value: 42.0}
+	ev, err := s.kr.Encrypt(v)
+	s.Require().NoError(err)
+	stored := v
+	stored.Value = ev
+	req := domain.GetValueRequest{RecordID: v.RecordID, PropertyID: v.PropertyID}
+	repo.On("GetValue", mock.Anything, req).Return(&stored, nil).Once()
+	got, err := man.Get(context.Background(), req)
+	s.Require().NoError(err)
+	s.Equal(42.0, got.Value)
+
+	// Without the keyring envelopes are read as they are
+	plainMan, plainRepo, _ := newTestValueMockedManager(s.T())
+	plainRepo.On("GetValue", mock.Anything, req).Return(&stored, nil).Once()
+	got, err = plainMan.Get(context.Background(), req)
+	s.Require().NoError(err)
+	s.Equal(ev, got.Value)
+}
+
+func (s *EncryptionTestSuite) TestGetRecords() {
+	repo := mocks.NewRecordRepository(s.T())
+	man, err := api.NewRecordManager(api.RecordConfig{
+		Repository: repo,
+		Broker:     mocks.NewRecordBroker(s.T()),
+		Cipher:     s.kr,
+		Timeout:    time.Second,
+	})
+	s.Require().NoError(err)
+	v := domain.Value{RecordID: uuid.New(), PropertyID: uuid.New(), Type: domain.TypeText, Value: "secret"}
+	ev, err := s.kr.Encrypt(v)
+	s.Require().NoError(err)
+	stored := v
+	stored.Value = ev
+	record := domain.RecordWithValues{Record: domain.Record{ID: v.RecordID}, Values: []domain.Value{stored}}
+	repo.On("GetRecords", mock.Anything, []uuid.UUID{v.RecordID}, true).Return([]domain.RecordWithValues{record}, nil).Once()
+	got, err := man.GetMany(context.Background(), []uuid.UUID{v.RecordID}, true)
+	s.Require().NoError(err)
+	s.Require().Len(got, 1)
+	s.Equal("secret", got[0].Values[0].Value)
+}
+
+// testValueBroker keeps messages the broker of the mode publishes.
+type testValueBroker struct {
+	mode     rmq.EncryptedValuesMode
+	messages [][]byte
+}
+
+func (b *testValueBroker) SendValue(_ context.Context, req domain.SendValueRequest) error {
+	msg, err := rmq.ValueMessage(req, b.mode)
+	if err != nil {
+		return err
+	}
+	b.messages = append(b.messages, msg)
+	return nil
+}
+
+// The changed value of the encrypted property is sent as it is stored, so the plain value is never published.
+func (s *EncryptionTestSuite) TestSendChangedValue() {
+	v := domain.Value{RecordID: uuid.New(), PropertyID: uuid.New(), Type: domain.TypeText, Value: "4111111111111111", Sum: "1"}
+	ev, err := s.kr.Encrypt(v)
+	s.Require().NoError(err)
+	stored := v
+	stored.Value = ev
+	key := []byte(`{"owner_id":"` + v.RecordID.String() + `","property_id":"` + v.PropertyID.String() + `"}`)
+
+	for _, mode := range []rmq.EncryptedValuesMode{rmq.EncryptedValuesMasked, rmq.EncryptedValuesCiphertext} {
+		s.Run(mode.String(), func() {
+			broker := &testValueBroker{mode: mode}
+			valueRepo := mocks.NewValueRepository(s.T())
+			recordRepo := mocks.NewRecordRepository(s.T())
+			valueMan, err := api.NewValueManager(api.ValueConfig{
+				Repository:       valueRepo,
+				RecordRepository: recordRepo,
+				Broker:           broker,
+				Cipher:           s.kr,
+				Timeout:          time.Second,
+			})
+			s.Require().NoError(err)
+			recordMan, err := api.NewRecordManager(api.RecordConfig{
+				Repository: recordRepo,
+				Broker:     mocks.NewRecordBroker(s.T()),
+				Cipher:     s.kr,
+				Timeout:    time.Second,
+			})
+			s.Require().NoError(err)
+			changedDataMan, changedDataRepo := newTestChangedDataManager(s.T())
+			storedConfigsMan, storedConfigsRepo := newTestStoredConfigsManager(s.T())
+			dbm, err := api.NewDBManager(api.DBConfig{Repository: &testTransactionBeginner{}})
+			s.Require().NoError(err)
+
+			storedConfigsRepo.On("GetStoredConfigDatawayTomID", mock.Anything).Return(domain.StoredConfigUUID{Value: uuid.New()}, nil).Once()
+			changedDataRepo.
+				On("GetChanges", mock.Anything).Return([]domain.ChangedData{{ID: 1, DataType: domain.ChangedDataValue, Key: key}}, nil).Once().
+				On("PurgeChanges", mock.Anything, int64(1), nil).Return(nil).Once().
+				On("GetPendingChanges", mock.Anything).Return(domain.PendingChanges{}, nil).Once()
+			valueRepo.
+				On("GetValue", mock.Anything, domain.GetValueRequest{RecordID: v.RecordID, PropertyID: v.PropertyID}).Return(&stored, nil).Once().
+				On("GetValueSentStateForUpdate", mock.Anything, mock.Anything, mock.Anything).Return(nil, domain.ErrSentDataNotFound).Once().
+				On("SetSentValue", mock.Anything, mock.Anything, mock.Anything).Return(&domain.ValueSentState{}, nil).Once()
+			recordRepo.On("GetRecordACLs", mock.Anything, []uuid.UUID{v.RecordID}).Return(map[uuid.UUID]domain.ACL{}, nil).Once()
+
+			send := routines.NewSendChangedDataRoutine(routines.SendChangedDataConfig{
+				Logger:               zap.NewNop().Sugar(),
+				RecordManager:        recordMan,
+				ValueManager:         valueMan,
+				ChangedDataManager:   changedDataMan,
+				StoredConfigsManager: storedConfigsMan,
+				DBManager:            dbm,
+			})
+			s.Require().NoError(send())
+			s.Require().Len(broker.messages, 1)
+			s.NotContains(string(broker.messages[0]), "4111111111111111")
+			var actual rmq.ValueSchema
+			s.Require().NoError(json.Unmarshal(broker.messages[0], &actual))
+			s.Nil(actual.Value)
+			if mode == rmq.EncryptedValuesCiphertext {
+				s.False(actual.Masked)
+				s.Require().NotNil(actual.Encrypted)
+				s.Equal(ev, actual.Encrypted.EncryptedValue())
+			} else {
+				s.True(actual.Masked)
+				s.Nil(actual.Encrypted)
+			}
+		})
+	}
+}
+
+func (s *EncryptionTestSuite) TestBrokerMode() {
+	mode, err := rmq.EncryptedValuesModeFromCode("")
+	s.Require().NoError(err)
+	s.Equal(rmq.EncryptedValuesMasked, mode)
+	mode, err = rmq.EncryptedValuesModeFromCode("ciphertext")
+	s.Require().NoError(err)
+	s.Equal(rmq.EncryptedValuesCiphertext, mode)
+	_, err = rmq.EncryptedValuesModeFromCode("plain")
+	s.ErrorIs(err, domain.ErrUnknownType)
+}
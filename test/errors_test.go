@@ -0,0 +1,141 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/test/mocks"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorsTestSuite struct {
+	suite.Suite
+	man  *api.RecordManager
+	repo *mocks.RecordRepository
+}
+
+func TestErrors(t *testing.T) {
+	suite.Run(t, new(ErrorsTestSuite))
+}
+
+func (s *ErrorsTestSuite) SetupTest() {
+	s.man, s.repo, _ = newTestRecordMockedManager(s.T())
+}
+
+func (s *ErrorsTestSuite) TestErrorCode() {
+	type testCase struct {
+		name string
+		err  error
+		want string
+	}
+	cases := []testCase{
+		{
+			name: "specific",
+			err:  domain.ErrRecordNotFound,
+			want: "record_not_found",
+		},
+		{
+			name: "general",
+			err:  fmt.Errorf("consumer %w", domain.ErrNotFound),
+			want: "not_found",
+		},
+		{
+			name: "detailed",
+			err: &domain.DetailedError{
+				Err:     domain.ErrSumMismatch,
+				Details: map[string]string{"records.id": "id"},
+			},
+			want: "sum_mismatch",
+		},
+		{
+			name: "not domain",
+			err:  errors.New("error"),
+			want: "",
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			s.Equal(c.want, domain.ErrorCode(c.err))
+		})
+	}
+}
+
+func (s *ErrorsTestSuite) TestDetailedError() {
+	err := &domain.DetailedError{
+		Err:     domain.ErrStateTransitionNotAllowedPG,
+		Field:   "property_id",
+		Details: map[string]string{"values.property_id": "id", "from": "new", "to": "done"},
+	}
+	wrapped := fmt.Errorf("set value error: %w", err)
+
+	s.EqualError(err, domain.ErrStateTransitionNotAllowedPG.Error())
+	s.ErrorIs(wrapped, domain.ErrStateTransitionNotAllowedPG)
+	s.Equal("property_id", domain.ErrorField(wrapped))
+	s.Equal(err.Details, domain.ErrorDetails(wrapped))
+	s.Empty(domain.ErrorField(domain.ErrStateTransitionNotAllowedPG))
+	s.Nil(domain.ErrorDetails(domain.ErrStateTransitionNotAllowedPG))
+}
+
+func (s *ErrorsTestSuite) TestHandlersErrorField() {
+	id := uuid.New()
+	s.repo.On("UpdateRecord", mock.Anything, mock.Anything, mock.Anything).Return(nil, &domain.DetailedError{
+		Err:     domain.ErrSumMismatch,
+		Details: map[string]string{"records.id": id.String(), "expected": "1", "actual": "2"},
+	})
+	name, description := "name", "description"
+
+	type testCase struct {
+		name      string
+		schema    handlers.UpdRecordRequestSchema
+		want      handlers.Result
+		wantCode  string
+		wantField string
+	}
+	cases := []testCase{
+		{
+			name:      "parse id error",
+			schema:    handlers.UpdRecordRequestSchema{ID: "id", Name: &name, Description: &description, DeletionMark: new(bool)},
+			want:      handlers.Result{Status: http.StatusBadRequest},
+			wantCode:  "",
+			wantField: "id",
+		},
+		{
+			name:      "expected error",
+			schema:    handlers.UpdRecordRequestSchema{ID: id.String()},
+			want:      handlers.Result{Status: http.StatusBadRequest},
+			wantCode:  "expected",
+			wantField: "name",
+		},
+		{
+			name: "detailed repository error",
+			schema: handlers.UpdRecordRequestSchema{
+				ID:           id.String(),
+				Name:         &name,
+				Description:  &description,
+				DeletionMark: new(bool),
+				ExpectedSum:  "1",
+			},
+			want:      handlers.Result{Status: http.StatusPreconditionFailed},
+			wantCode:  "sum_mismatch",
+			wantField: "",
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			actual, err := handlers.UpdateRecord(context.Background(), s.man, c.schema)
+			s.Require().Error(err)
+			s.Equal(c.want, actual)
+			s.Equal(c.wantCode, domain.ErrorCode(err))
+			s.Equal(c.wantField, domain.ErrorField(err))
+		})
+	}
+}
@@ -0,0 +1,396 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/graphql"
+	"datatom/test/mocks"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type GraphQLTestSuite struct {
+	suite.Suite
+	schema       *graphql.Schema
+	refTypeRepo  *mocks.RefTypeRepository
+	recordRepo   *mocks.RecordRepository
+	propertyRepo *mocks.PropertyRepository
+	valueRepo    *mocks.ValueRepository
+	errs         []error
+}
+
+func TestGraphQL(t *testing.T) {
+	suite.Run(t, new(GraphQLTestSuite))
+}
+
+func (s *GraphQLTestSuite) SetupTest() {
+	refTypeMan, refTypeRepo, _ := newTestRefTypeMockedManager(s.T())
+	recordMan, recordRepo, _ := newTestRecordMockedManager(s.T())
+	propertyMan, propertyRepo, _ := newTestPropertyMockedManager(s.T())
+	valueMan, valueRepo, _ := newTestValueMockedManager(s.T())
+	s.errs = nil
+	schema, err := graphql.NewSchema(graphql.Config{
+		RefTypeManager:  refTypeMan,
+		RecordManager:   recordMan,
+		PropertyManager: propertyMan,
+		ValueManager:    valueMan,
+		AuthManager:     newTestAuthManager(s.T(), recordMan, propertyMan, nil),
+		ErrorHandler: func(err error) {
+			s.errs = append(s.errs, err)
+		},
+	})
+	s.Require().NoError(err)
+	s.schema = schema
+	s.refTypeRepo = refTypeRepo
+	s.recordRepo = recordRepo
+	s.propertyRepo = propertyRepo
+	s.valueRepo = valueRepo
+}
+
+type graphQLResult struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func (s *GraphQLTestSuite) exec(query string, variables map[string]any) graphQLResult {
+	b, err := json.Marshal(s.schema.Exec(context.Background(), graphql.Request{Query: query, Variables: variables}))
+	s.Require().NoError(err)
+	var out graphQLResult
+	s.Require().NoError(json.Unmarshal(b, &out))
+	return out
+}
+
+// sameIDs matches IDs of a batch in any order.
+func sameIDs(want ...uuid.UUID) any {
+	return mock.MatchedBy(func(ids []uuid.UUID) bool {
+		if len(ids) != len(want) {
+			return false
+		}
+		set := make(map[uuid.UUID]struct{}, len(ids))
+		for _, id := range ids {
+			set[id] = struct{}{}
+		}
+		for _, id := range want {
+			if _, ok := set[id]; !ok {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (s *GraphQLTestSuite) TestBatching() {
+	rtID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	nameID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	refID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	t1 := uuid.MustParse("00000000-0000-0000-0000-000000000011")
+	t2 := uuid.MustParse("00000000-0000-0000-0000-000000000012")
+	r1 := uuid.MustParse("00000000-0000-0000-0000-000000000021")
+	r2 := uuid.MustParse("00000000-0000-0000-0000-000000000022")
+	r3 := uuid.MustParse("00000000-0000-0000-0000-000000000023")
+	record := func(id uuid.UUID, name string, values ...domain.Value) domain.RecordWithValues {
+		for i := range values {
+			values[i].RecordID = id
+		}
+		return domain.RecordWithValues{
+			Record: domain.Record{ID: id, ReferenceTypeID: rtID, Name: name},
+			Values: values,
+		}
+	}
+	ref := func(id uuid.UUID) domain.Value {
+		return domain.Value{PropertyID: refID, Type: domain.TypeReference, RefTypeID: rtID, Value: id}
+	}
+	text := func(v string) domain.Value {
+		return domain.Value{PropertyID: nameID, Type: domain.TypeText, Value: v}
+	}
+	listed := []domain.RecordWithValues{
+		record(r1, "r1", text("r1"), ref(t1)),
+		record(r2, "r2", text("r2"), ref(t1)),
+		record(r3, "r3", text("r3"), ref(t2)),
+	}
+	targets := []domain.RecordWithValues{
+		record(t1, "t1", text("first")),
+		record(t2, "t2", text("second")),
+	}
+	s.recordRepo.
+		On("ListRecords", mock.Anything, domain.ListRecordsRequest{
+			RefTypeID: rtID,
+			Filter:    domain.RecordFilter{Name: "r"},
+			Limit:     10,
+		}).Return([]domain.Record{listed[0].Record, listed[1].Record, listed[2].Record}, nil).Once().
+		On("GetRecords", mock.Anything, sameIDs(r1, r2, r3), true).Return(listed, nil).Once().
+		On("GetRecords", mock.Anything, sameIDs(t1, t2), true).Return(targets, nil).Once()
+	s.propertyRepo.On("GetProperties", mock.Anything, sameIDs(nameID, refID)).Return([]domain.Property{
+		{ID: nameID, Name: "name"},
+		{ID: refID, Name: "ref"},
+	}, nil).Once()
+	s.refTypeRepo.On("GetRefTypes", mock.Anything, sameIDs(rtID)).Return([]domain.RefType{{ID: rtID, Name: "type"}}, nil).Once()
+
+	query := `query($rt: ID) {
+		records(refTypeId: $rt, filter: {name: "r"}, limit: 10) {
+			name
+			refType { name }
+			values {
+				property { name }
+				ref { name values { value } }
+			}
+		}
+	}`
+	actual := s.exec(query, map[string]any{"rt": rtID.String()})
+	s.Require().Empty(actual.Errors)
+	s.JSONEq(`{"records": [
+		{"name": "r1", "refType": {"name": "type"}, "values": [
+			{"property": {"name": "name"}, "ref": null},
+			{"property": {"name": "ref"}, "ref": {"name": "t1", "values": [{"value": "first"}]}}
+		]},
+		{"name": "r2", "refType": {"name": "type"}, "values": [
+			{"property": {"name": "name"}, "ref": null},
+			{"property": {"name": "ref"}, "ref": {"name": "t1", "values": [{"value": "first"}]}}
+		]},
+		{"name": "r3", "refType": {"name": "type"}, "values": [
+			{"property": {"name": "name"}, "ref": null},
+			{"property": {"name": "ref"}, "ref": {"name": "t2", "values": [{"value": "second"}]}}
+		]}
+	]}`, string(actual.Data))
+}
+
+func (s *GraphQLTestSuite) TestErrors() {
+	idE := uuid.New()
+	idSum := uuid.New()
+	errSum := &domain.DetailedError{
+		Err:     domain.ErrSumMismatch,
+		Details: map[string]string{"expected": "1", "actual": "2"},
+	}
+	s.recordRepo.
+		On("GetRecords", mock.Anything, []uuid.UUID{idE}, true).Return(nil, errors.New("connection refused")).
+		On("UpdateRecord", mock.Anything, mock.Anything, mock.Anything).Return(nil, errSum)
+
+	type testCase struct {
+		name      string
+		query     string
+		wantMsg   string
+		wantCode  string
+		wantField string
+		wantErrs  int
+	}
+	cases := []testCase{
+		{
+			name:      "parse id error",
+			query:     `{ record(id: "id") { id } }`,
+			wantCode:  "invalid_field",
+			wantField: "id",
+		},
+		{
+			name:      "limit error",
+			query:     `{ records(limit: 0) { id } }`,
+			wantCode:  "invalid_field",
+			wantField: "limit",
+		},
+		{
+			name:      "parse argument of the REST schema error",
+			query:     `{ value(recordId: "id", propertyId: "id") { sum } }`,
+			wantCode:  "invalid_field",
+			wantField: "recordId",
+		},
+		{
+			name:     "internal error",
+			query:    `{ record(id: "` + idE.String() + `") { id } }`,
+			wantMsg:  "internal server error",
+			wantCode: "internal_server_error",
+			wantErrs: 1,
+		},
+		{
+			name:     "domain error",
+			query:    `mutation { updateRecord(input: {id: "` + idSum.String() + `", name: "name", expectedSum: "1"}) { sum } }`,
+			wantMsg:  domain.ErrSumMismatch.Error(),
+			wantCode: "sum_mismatch",
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			s.errs = nil
+			actual := s.exec(c.query, nil)
+			s.Require().Len(actual.Errors, 1)
+			if c.wantMsg != "" {
+				s.Equal(c.wantMsg, actual.Errors[0].Message)
+			}
+			s.Equal(c.wantCode, actual.Errors[0].Extensions["code"])
+			if c.wantField != "" {
+				s.Equal(c.wantField, actual.Errors[0].Extensions["field"])
+			} else {
+				s.NotContains(actual.Errors[0].Extensions, "field")
+			}
+			s.Len(s.errs, c.wantErrs)
+		})
+	}
+}
+
+func (s *GraphQLTestSuite) TestMutations() {
+	recordID := uuid.New()
+	propertyID := uuid.New()
+	name := "name"
+	s.recordRepo.
+		On("UpdateRecord", mock.Anything, domain.UpdRecordRequest{ID: recordID, Name: &name, ExpectedSum: "1"}, mock.Anything).
+		Return(&domain.Record{ID: recordID, Name: name, Sum: "2"}, nil).Once()
+	s.valueRepo.
+		On("SetValue", mock.Anything, domain.SetValueRequest{
+			RecordID:   recordID,
+			PropertyID: propertyID,
+			Type:       domain.TypeNumber,
+			Value:      float64(5),
+		}, mock.Anything).
+		Return(&domain.Value{RecordID: recordID, PropertyID: propertyID, Type: domain.TypeNumber, Value: float64(5), Sum: "3"}, nil).Once()
+
+	actual := s.exec(`mutation($id: ID!) {
+		updateRecord(input: {id: $id, name: "name", expectedSum: "1"}) { name sum }
+	}`, map[string]any{"id": recordID.String()})
+	s.Require().Empty(actual.Errors)
+	s.JSONEq(`{"updateRecord": {"name": "name", "sum": "2"}}`, string(actual.Data))
+
+	// Integers of literals are passed to the manager like numbers of JSON bodies
+	actual = s.exec(`mutation {
+		setValue(input: {recordId: "`+recordID.String()+`", propertyId: "`+propertyID.String()+`", type: "number", value: 5}) { value sum }
+	}`, nil)
+	s.Require().Empty(actual.Errors)
+	s.JSONEq(`{"setValue": {"value": 5, "sum": "3"}}`, string(actual.Data))
+}
+
+func (s *GraphQLTestSuite) TestAuthorization() {
+	rtA := uuid.New()
+	rtB := uuid.New()
+	rA := uuid.New()
+	rB := uuid.New()
+	propertyID := uuid.New()
+	refTypeMan, _, _ := newTestRefTypeMockedManager(s.T())
+	recordMan, recordRepo, _ := newTestRecordMockedManager(s.T())
+	propertyMan, _, _ := newTestPropertyMockedManager(s.T())
+	valueMan, _, _ := newTestValueMockedManager(s.T())
+	// The editor reads and writes records of the reference type A and reads their values only
+	roles := []domain.Role{{
+		Name: "editor",
+		Grants: []domain.Grant{
+			{Operations: []domain.Operation{domain.OperationGraphQL}, Access: domain.AccessWrite},
+			{Operations: []domain.Operation{domain.OperationRecords}, Access: domain.AccessWrite, RefTypeIDs: []uuid.UUID{rtA}},
+			{Operations: []domain.Operation{domain.OperationValues}, Access: domain.AccessRead, RefTypeIDs: []uuid.UUID{rtA}},
+		},
+	}}
+	schema, err := graphql.NewSchema(graphql.Config{
+		RefTypeManager:  refTypeMan,
+		RecordManager:   recordMan,
+		PropertyManager: propertyMan,
+		ValueManager:    valueMan,
+		AuthManager:     newTestAuthManager(s.T(), recordMan, propertyMan, roles),
+	})
+	s.Require().NoError(err)
+	ctx := api.ContextWithPrincipal(context.Background(), &domain.Principal{Subject: "editor", Roles: []string{"editor"}})
+	// Records are read by loaders of each request
+	recordRepo.
+		On("GetRecords", mock.Anything, sameIDs(rA), true).Return([]domain.RecordWithValues{{
+		Record: domain.Record{ID: rA, ReferenceTypeID: rtA, Name: "a"},
+		Values: []domain.Value{{RecordID: rA, PropertyID: propertyID, Type: domain.TypeReference, RefTypeID: rtB, Value: rB}},
+	}}, nil).
+		On("GetRecords", mock.Anything, sameIDs(rB), true).Return([]domain.RecordWithValues{{
+		Record: domain.Record{ID: rB, ReferenceTypeID: rtB, Name: "b"},
+	}}, nil).
+		On("GetRecord", mock.Anything, rB).Return(&domain.Record{ID: rB, ReferenceTypeID: rtB}, nil).Once()
+
+	type testCase struct {
+		name      string
+		query     string
+		wantData  string
+		wantPaths []string
+	}
+	cases := []testCase{
+		{
+			name:     "record of the granted reference type",
+			query:    `{ record(id: "` + rA.String() + `") { name } }`,
+			wantData: `{"record": {"name": "a"}}`,
+		},
+		{
+			name:      "referenced records are scoped by their reference types",
+			query:     `{ record(id: "` + rA.String() + `") { name values { ref { name } } } another: record(id: "` + rB.String() + `") { name } }`,
+			wantData:  `{"record": {"name": "a", "values": [{"ref": null}]}, "another": null}`,
+			wantPaths: []string{"record.values.0.ref", "another"},
+		},
+		{
+			name:      "records of all reference types",
+			query:     `{ records { id } }`,
+			wantData:  `null`,
+			wantPaths: []string{"records"},
+		},
+		{
+			name:      "record added to the reference type which is not granted",
+			query:     `mutation { addRecord(input: {refTypeId: "` + rtB.String() + `", name: "b"}) { id } }`,
+			wantData:  `null`,
+			wantPaths: []string{"addRecord"},
+		},
+		{
+			name:      "record of the reference type which is not granted is updated",
+			query:     `mutation { updateRecord(input: {id: "` + rB.String() + `", name: "b"}) { id } }`,
+			wantData:  `null`,
+			wantPaths: []string{"updateRecord"},
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			b, err := json.Marshal(schema.Exec(ctx, graphql.Request{Query: c.query}))
+			s.Require().NoError(err)
+			var actual struct {
+				Data   json.RawMessage `json:"data"`
+				Errors []struct {
+					Path       []any          `json:"path"`
+					Extensions map[string]any `json:"extensions"`
+				} `json:"errors"`
+			}
+			s.Require().NoError(json.Unmarshal(b, &actual))
+			s.JSONEq(c.wantData, string(actual.Data))
+			paths := []string{}
+			for _, e := range actual.Errors {
+				s.Equal("forbidden", e.Extensions["code"])
+				path := make([]string, 0, len(e.Path))
+				for _, p := range e.Path {
+					path = append(path, fmt.Sprint(p))
+				}
+				paths = append(paths, strings.Join(path, "."))
+			}
+			s.ElementsMatch(c.wantPaths, paths)
+		})
+	}
+}
+
+func (s *GraphQLTestSuite) TestHandler() {
+	srv, recordRepo := newTestServer(s.T())
+	r, ok := srv.(interface{ Routes() chi.Routes })
+	s.Require().True(ok)
+	handler, ok := r.Routes().(http.Handler)
+	s.Require().True(ok)
+	id := uuid.New()
+	recordRepo.On("GetRecords", mock.Anything, []uuid.UUID{id}, true).
+		Return([]domain.RecordWithValues{{Record: domain.Record{ID: id, Name: "name"}}}, nil).Once()
+
+	rec := httptest.NewRecorder()
+	body := `{"query": "query($id: ID!) { record(id: $id) { name } }", "variables": {"id": "` + id.String() + `"}}`
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(body)))
+	s.Equal(http.StatusOK, rec.Code)
+	s.JSONEq(`{"data": {"record": {"name": "name"}}}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(`{"variables": {}}`)))
+	s.Equal(http.StatusBadRequest, rec.Code)
+	s.Equal("application/problem+json", rec.Header().Get("Content-Type"))
+}
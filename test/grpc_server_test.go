@@ -0,0 +1,348 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"datatom/internal/auth"
+	"datatom/internal/domain"
+	grpcserver "datatom/internal/grpc/server"
+	"datatom/internal/pb"
+	"datatom/test/mocks"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type GRPCServerTestSuite struct {
+	suite.Suite
+	cli        pb.DatatomClient
+	conn       *grpc.ClientConn
+	srv        *grpc.Server
+	recordRepo *mocks.RecordRepository
+	valueRepo  *mocks.ValueRepository
+	tableRepo  *mocks.TableRepository
+	errs       []error
+}
+
+func TestGRPCServer(t *testing.T) {
+	suite.Run(t, new(GRPCServerTestSuite))
+}
+
+func (s *GRPCServerTestSuite) SetupTest() {
+	s.start(nil)
+}
+
+// start serves the service with the auth manager which has the authenticators.
+func (s *GRPCServerTestSuite) start(roles []domain.Role, authenticators ...domain.Authenticator) {
+	refTypeMan, _, _ := newTestRefTypeMockedManager(s.T())
+	recordMan, recordRepo, _ := newTestRecordMockedManager(s.T())
+	propertyMan, _, _ := newTestPropertyMockedManager(s.T())
+	valueMan, valueRepo, _ := newTestValueMockedManager(s.T())
+	tableMan, tableRepo := newTestTableMockedManager(s.T())
+	s.errs = nil
+	c := grpcserver.Config{
+		RefTypeManager:  refTypeMan,
+		RecordManager:   recordMan,
+		PropertyManager: propertyMan,
+		ValueManager:    valueMan,
+		TableManager:    tableMan,
+		AuthManager:     newTestAuthManager(s.T(), recordMan, propertyMan, roles, authenticators...),
+		ErrorHandler: func(err error) {
+			s.errs = append(s.errs, err)
+		},
+	}
+	svc, err := grpcserver.NewService(c)
+	s.Require().NoError(err)
+	opts, err := grpcserver.NewServerOptions(c)
+	s.Require().NoError(err)
+
+	lis := bufconn.Listen(1 << 20)
+	s.srv = grpc.NewServer(opts...)
+	pb.RegisterDatatomServer(s.srv, svc)
+	go func() {
+		_ = s.srv.Serve(lis)
+	}()
+	s.conn, err = grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	s.Require().NoError(err)
+	s.cli = pb.NewDatatomClient(s.conn)
+	s.recordRepo = recordRepo
+	s.valueRepo = valueRepo
+	s.tableRepo = tableRepo
+}
+
+func (s *GRPCServerTestSuite) TearDownTest() {
+	s.Require().NoError(s.conn.Close())
+	s.srv.Stop()
+}
+
+// errorInfo returns the code of the status and its ErrorInfo.
+func (s *GRPCServerTestSuite) errorInfo(err error) (codes.Code, *errdetails.ErrorInfo) {
+	st, ok := status.FromError(err)
+	s.Require().True(ok)
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return st.Code(), info
+		}
+	}
+	s.Fail("error info expected")
+	return st.Code(), nil
+}
+
+func (s *GRPCServerTestSuite) TestErrors() {
+	idE := uuid.New()
+	idNF := uuid.New()
+	errSum := &domain.DetailedError{
+		Err:     domain.ErrSumMismatch,
+		Details: map[string]string{"expected": "1", "actual": "2"},
+	}
+	s.recordRepo.
+		On("GetRecords", mock.Anything, []uuid.UUID{idE}, true).Return(nil, errors.New("connection refused")).
+		On("GetRecords", mock.Anything, []uuid.UUID{idNF}, true).Return(nil, nil).
+		On("UpdateRecord", mock.Anything, mock.Anything, mock.Anything).Return(nil, errSum)
+	ctx := context.Background()
+
+	type testCase struct {
+		name     string
+		call     func() error
+		wantCode codes.Code
+		wantMsg  string
+		wantInfo map[string]string
+		reason   string
+		wantErrs int
+	}
+	cases := []testCase{
+		{
+			name: "invalid id",
+			call: func() error {
+				_, err := s.cli.GetRecord(ctx, &pb.UUID{Value: []byte{1, 2}})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+			reason:   "invalid_field",
+			wantInfo: map[string]string{"field": "id"},
+		},
+		{
+			name: "unknown type of the REST schema",
+			call: func() error {
+				_, err := s.cli.SetValue(ctx, &pb.SetValueRequest{
+					RecordId:   pb.UUIDToPb(uuid.New()),
+					PropertyId: pb.UUIDToPb(uuid.New()),
+					Type:       "unknown",
+				})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+			reason:   "bad_request",
+		},
+		{
+			name: "not found",
+			call: func() error {
+				_, err := s.cli.GetRecord(ctx, pb.UUIDToPb(idNF))
+				return err
+			},
+			wantCode: codes.NotFound,
+			reason:   "record_not_found",
+		},
+		{
+			name: "sum mismatch",
+			call: func() error {
+				name := "name"
+				_, err := s.cli.UpdateRecord(ctx, &pb.UpdateRecordRequest{Id: pb.UUIDToPb(uuid.New()), Name: &name, ExpectedSum: "1"})
+				return err
+			},
+			wantCode: codes.FailedPrecondition,
+			reason:   "sum_mismatch",
+			wantInfo: map[string]string{"expected": "1", "actual": "2"},
+		},
+		{
+			name: "internal error",
+			call: func() error {
+				_, err := s.cli.GetRecord(ctx, pb.UUIDToPb(idE))
+				return err
+			},
+			wantCode: codes.Internal,
+			wantMsg:  "internal server error",
+			reason:   "internal_server_error",
+			wantErrs: 1,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			s.errs = nil
+			err := c.call()
+			s.Require().Error(err)
+			code, info := s.errorInfo(err)
+			s.Equal(c.wantCode, code)
+			if c.wantMsg != "" {
+				s.Equal(c.wantMsg, status.Convert(err).Message())
+			}
+			s.Equal(c.reason, info.Reason)
+			if c.wantInfo != nil {
+				s.Equal(c.wantInfo, info.Metadata)
+			} else {
+				s.Empty(info.Metadata)
+			}
+			s.Len(s.errs, c.wantErrs)
+		})
+	}
+}
+
+func (s *GRPCServerTestSuite) TestSetValue() {
+	recordID := uuid.New()
+	propertyID := uuid.New()
+	changeAt := time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)
+	s.valueRepo.
+		On("SetValue", mock.Anything, domain.SetValueRequest{
+			RecordID:   recordID,
+			PropertyID: propertyID,
+			Type:       domain.TypeNumber,
+			Value:      float64(5),
+		}, mock.Anything).
+		Return(&domain.Value{RecordID: recordID, PropertyID: propertyID, Type: domain.TypeNumber, Value: float64(5), Sum: "3", ChangeAt: changeAt}, nil).Once()
+
+	actual, err := s.cli.SetValue(context.Background(), &pb.SetValueRequest{
+		RecordId:   pb.UUIDToPb(recordID),
+		PropertyId: pb.UUIDToPb(propertyID),
+		Type:       "number",
+		Value:      structpb.NewNumberValue(5),
+	})
+	s.Require().NoError(err)
+	s.Equal(float64(5), actual.GetValue().GetNumberValue())
+	s.Equal("3", actual.GetSum())
+	s.Equal(changeAt, actual.GetChangeAt().AsTime())
+	s.Nil(actual.GetReferenceTypeId())
+}
+
+func (s *GRPCServerTestSuite) TestListRecords() {
+	rtID := uuid.New()
+	refID := uuid.New()
+	records := make([]domain.Record, 1001)
+	for i := range records {
+		records[i] = domain.Record{ID: uuid.New(), ReferenceTypeID: rtID}
+	}
+	lastRecords := []domain.RecordWithValues{{
+		Record: records[1000],
+		Values: []domain.Value{{RecordID: records[1000].ID, Type: domain.TypeReference, RefTypeID: rtID, Value: refID}},
+	}}
+	s.recordRepo.
+		On("ListRecords", mock.Anything, domain.ListRecordsRequest{RefTypeID: rtID, Limit: 1000}).Return(records[:1000], nil).Once().
+		On("ListRecords", mock.Anything, domain.ListRecordsRequest{RefTypeID: rtID, Limit: 1000, Offset: 1000}).Return(records[1000:], nil).Once().
+		On("GetRecords", mock.Anything, mock.MatchedBy(func(ids []uuid.UUID) bool { return len(ids) == 1000 }), true).Return(nil, nil).Once().
+		On("GetRecords", mock.Anything, []uuid.UUID{records[1000].ID}, true).Return(lastRecords, nil).Once()
+
+	stream, err := s.cli.ListRecords(context.Background(), &pb.ListRecordsRequest{
+		ReferenceTypeId: pb.UUIDToPb(rtID),
+		WithValues:      true,
+	})
+	s.Require().NoError(err)
+	var actual []*pb.Record
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		s.Require().NoError(err)
+		actual = append(actual, r)
+	}
+	// Records of the first page are deleted before their values are read
+	s.Require().Len(actual, 1)
+	s.Equal(records[1000].ID[:], actual[0].GetId().GetValue())
+	s.Require().Len(actual[0].GetValues(), 1)
+	s.Equal(refID.String(), actual[0].GetValues()[0].GetValue().GetStringValue())
+}
+
+func (s *GRPCServerTestSuite) TestExportTable() {
+	rtID := uuid.New()
+	recordID := uuid.New()
+	propertyID := uuid.New()
+	makerID := uuid.New()
+	deletionMark := false
+	s.tableRepo.
+		On("ExportTable", mock.Anything, domain.TableRequest{
+			RefTypeID: rtID,
+			Filter:    domain.RecordFilter{DeletionMark: &deletionMark},
+		}, mock.Anything).
+		Run(func(args mock.Arguments) {
+			w := args.Get(2).(domain.TableWriter)
+			s.Require().NoError(w.WriteHeader([]domain.Property{{ID: propertyID, Name: "Maker"}}))
+			s.Require().NoError(w.WriteRow(domain.TableRow{
+				Record: domain.Record{ID: recordID, Name: "chair"},
+				Values: []domain.TableValue{
+					{Value: domain.Value{PropertyID: propertyID, Type: domain.TypeReference, Value: makerID}, RefName: "Acme"},
+				},
+			}))
+		}).Return(nil).Once()
+
+	stream, err := s.cli.ExportTable(context.Background(), &pb.ExportTableRequest{
+		ReferenceTypeId: pb.UUIDToPb(rtID),
+		DeletionMark:    &deletionMark,
+	})
+	s.Require().NoError(err)
+	columns, err := stream.Recv()
+	s.Require().NoError(err)
+	s.Require().Len(columns.GetColumns().GetProperties(), 1)
+	s.Equal("Maker", columns.GetColumns().GetProperties()[0].GetName())
+	row, err := stream.Recv()
+	s.Require().NoError(err)
+	s.Equal("chair", row.GetRow().GetName())
+	s.Require().Len(row.GetRow().GetValues(), 1)
+	s.Equal("Acme", row.GetRow().GetValues()[0].GetRefName())
+	s.Equal(makerID.String(), row.GetRow().GetValues()[0].GetValue().GetStringValue())
+	_, err = stream.Recv()
+	s.ErrorIs(err, io.EOF)
+}
+
+func (s *GRPCServerTestSuite) TestAuth() {
+	s.TearDownTest()
+	rtID := uuid.New()
+	keyAuth, err := auth.NewAPIKeyAuthenticator([]auth.APIKey{{Subject: "reader", SHA256: testKeySum("reader-key"), Roles: []string{"reader"}}})
+	s.Require().NoError(err)
+	s.start([]domain.Role{{Name: "reader", Grants: []domain.Grant{{RefTypeIDs: []uuid.UUID{rtID}, Access: domain.AccessRead}}}}, keyAuth)
+	id := uuid.New()
+	record := domain.Record{ID: id, ReferenceTypeID: rtID, Name: "chair"}
+	s.recordRepo.
+		On("GetRecord", mock.Anything, id).Return(&record, nil).
+		On("GetRecords", mock.Anything, []uuid.UUID{id}, true).Return([]domain.RecordWithValues{{Record: record}}, nil)
+
+	_, err = s.cli.GetRecord(context.Background(), pb.UUIDToPb(id))
+	code, info := s.errorInfo(err)
+	s.Equal(codes.Unauthenticated, code)
+	s.Equal("unauthenticated", info.Reason)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "reader-key")
+	actual, err := s.cli.GetRecord(ctx, pb.UUIDToPb(id))
+	s.Require().NoError(err)
+	s.Equal("chair", actual.GetName())
+
+	name := "table"
+	_, err = s.cli.UpdateRecord(ctx, &pb.UpdateRecordRequest{Id: pb.UUIDToPb(id), Name: &name})
+	code, info = s.errorInfo(err)
+	s.Equal(codes.PermissionDenied, code)
+	s.Equal("forbidden", info.Reason)
+
+	// Streams are authorized by their request messages
+	stream, err := s.cli.ListRecords(ctx, &pb.ListRecordsRequest{ReferenceTypeId: pb.UUIDToPb(uuid.New())})
+	s.Require().NoError(err)
+	_, err = stream.Recv()
+	code, _ = s.errorInfo(err)
+	s.Equal(codes.PermissionDenied, code)
+}
@@ -1,13 +1,20 @@
 package test
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"runtime"
 	"testing"
 	"time"
 
 	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/rest"
+	"datatom/pkg/db"
 	"datatom/test/mocks"
+
+	"go.uber.org/zap"
 )
 
 func newTestRefTypeMockedManager(t *testing.T) (*api.RefTypeManager, *mocks.RefTypeRepository, *mocks.RefTypeBroker) {
@@ -56,9 +63,10 @@ func newTestValueMockedManager(t *testing.T) (*api.ValueManager, *mocks.ValueRep
 	repo := mocks.NewValueRepository(t)
 	broker := mocks.NewValueBroker(t)
 	out, err := api.NewValueManager(api.ValueConfig{
-		Repository: repo,
-		Broker:     broker,
-		Timeout:    time.Second,
+		Repository:       repo,
+		RecordRepository: mocks.NewRecordRepository(t),
+		Broker:           broker,
+		Timeout:          time.Second,
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -90,9 +98,227 @@ func newTestStoredConfigsManager(t *testing.T) (*api.StoredConfigsManager, *mock
 	return out, repo
 }
 
+func newTestDumpMockedManager(t *testing.T) (*api.DumpManager, *mocks.DumpRepository, *mocks.RecordRepository) {
+	repo := mocks.NewDumpRepository(t)
+	recordRepo := mocks.NewRecordRepository(t)
+	out, err := api.NewDumpManager(api.DumpConfig{
+		Repository:       repo,
+		RecordRepository: recordRepo,
+		Timeout:          time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, repo, recordRepo
+}
+
+func newTestTableMockedManager(t *testing.T) (*api.TableManager, *mocks.TableRepository) {
+	repo := mocks.NewTableRepository(t)
+	out, err := api.NewTableManager(api.TableConfig{
+		Repository: repo,
+		Timeout:    time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, repo
+}
+
+func newTestIdempotencyMockedManager(t *testing.T) (*api.IdempotencyManager, *mocks.IdempotencyRepository) {
+	repo := mocks.NewIdempotencyRepository(t)
+	out, err := api.NewIdempotencyManager(api.IdempotencyConfig{
+		Repository: repo,
+		TTL:        time.Hour,
+		Lease:      time.Minute,
+		Timeout:    time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, repo
+}
+
+func newTestChangeLogMockedManager(t *testing.T) (*api.ChangeLogManager, *mocks.ChangeLogRepository) {
+	repo := mocks.NewChangeLogRepository(t)
+	out, err := api.NewChangeLogManager(api.ChangeLogConfig{
+		Repository:   repo,
+		PollInterval: time.Hour,
+		Timeout:      time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, repo
+}
+
+func newTestMaskMockedManager(t *testing.T) (*api.MaskManager, *mocks.PropertyRepository, *mocks.AuditRepository) {
+	propertyRepo := mocks.NewPropertyRepository(t)
+	auditRepo := mocks.NewAuditRepository(t)
+	out, err := api.NewMaskManager(api.MaskConfig{
+		PropertyRepository: propertyRepo,
+		AuditRepository:    auditRepo,
+		Timeout:            time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, propertyRepo, auditRepo
+}
+
+func newTestWebhookMockedManager(t *testing.T, changeLogMan *api.ChangeLogManager) (*api.WebhookManager, *mocks.WebhookRepository) {
+	repo := mocks.NewWebhookRepository(t)
+	out, err := api.NewWebhookManager(api.WebhookConfig{
+		Repository:       repo,
+		ChangeLogManager: changeLogMan,
+		RetryBase:        time.Second,
+		RetryMax:         time.Second * 4,
+		MaxFailures:      3,
+		Timeout:          time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, repo
+}
+
 func funcName(t *testing.T, f any) string {
 	if reflect.ValueOf(f).Kind() != reflect.Func {
 		t.Fatalf("%v is not a function", f)
 	}
 	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
 }
+
+type testTransaction struct {
+	committed  bool
+	rolledBack bool
+	savepoints []*testTransaction
+}
+
+func (t *testTransaction) Begin(context.Context) (db.Transaction, error) {
+	sp := &testTransaction{}
+	t.savepoints = append(t.savepoints, sp)
+	return sp, nil
+}
+
+// Rollback of the committed transaction does nothing like the one of pgx.
+func (t *testTransaction) Rollback(context.Context) error {
+	if t.committed {
+		return errors.New("transaction is closed")
+	}
+	t.rolledBack = true
+	return nil
+}
+
+func (t *testTransaction) Commit(context.Context) error {
+	t.committed = true
+	return nil
+}
+
+type testTransactionBeginner struct {
+	tx *testTransaction
+}
+
+func (tb *testTransactionBeginner) BeginTransaction(context.Context) (db.Transaction, error) {
+	tb.tx = &testTransaction{}
+	return tb.tx, nil
+}
+
+func newTestBatchMockedManager(t *testing.T, roles ...domain.Role) (*api.BatchManager, *testTransactionBeginner, *mocks.RecordRepository, *mocks.PropertyRepository, *mocks.ValueRepository) {
+	tb := &testTransactionBeginner{}
+	dbm, err := api.NewDBManager(api.DBConfig{Repository: tb})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordMan, recordRepo, _ := newTestRecordMockedManager(t)
+	propertyMan, propertyRepo, _ := newTestPropertyMockedManager(t)
+	valueRepo := mocks.NewValueRepository(t)
+	out, err := api.NewBatchManager(api.BatchConfig{
+		DBManager:          dbm,
+		RecordRepository:   recordRepo,
+		PropertyRepository: propertyRepo,
+		ValueRepository:    valueRepo,
+		AuthManager:        newTestAuthManager(t, recordMan, propertyMan, roles),
+		Timeout:            time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, tb, recordRepo, propertyRepo, valueRepo
+}
+
+func newTestImportMockedManager(t *testing.T) (*api.ImportManager, *testTransactionBeginner, *mocks.RecordRepository, *mocks.ValueRepository) {
+	tb := &testTransactionBeginner{}
+	dbm, err := api.NewDBManager(api.DBConfig{Repository: tb})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordRepo := mocks.NewRecordRepository(t)
+	valueRepo := mocks.NewValueRepository(t)
+	out, err := api.NewImportManager(api.ImportConfig{
+		DBManager:        dbm,
+		RecordRepository: recordRepo,
+		ValueRepository:  valueRepo,
+		Timeout:          time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, tb, recordRepo, valueRepo
+}
+
+// newTestServer returns the REST server with mocked managers and the repository of records.
+func newTestServer(t *testing.T) (domain.Server, *mocks.RecordRepository) {
+	c, recordRepo := newTestServerConfig(t)
+	out, err := rest.NewServer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, recordRepo
+}
+
+func newTestServerConfig(t *testing.T) (rest.Config, *mocks.RecordRepository) {
+	refTypeMan, _, _ := newTestRefTypeMockedManager(t)
+	recordMan, recordRepo, _ := newTestRecordMockedManager(t)
+	propertyMan, _, _ := newTestPropertyMockedManager(t)
+	valueMan, _, _ := newTestValueMockedManager(t)
+	storedConfigsMan, _ := newTestStoredConfigsManager(t)
+	batchMan, _, _, _, _ := newTestBatchMockedManager(t)
+	importMan, _, _, _ := newTestImportMockedManager(t)
+	dumpMan, _, _ := newTestDumpMockedManager(t)
+	tableMan, _ := newTestTableMockedManager(t)
+	idempotencyMan, _ := newTestIdempotencyMockedManager(t)
+	changeLogMan, _ := newTestChangeLogMockedManager(t)
+	webhookMan, _ := newTestWebhookMockedManager(t, changeLogMan)
+	maskMan, _, _ := newTestMaskMockedManager(t)
+	return rest.Config{
+		Logger:               zap.NewNop().Sugar(),
+		RefTypeManager:       refTypeMan,
+		RecordManager:        recordMan,
+		PropertyManager:      propertyMan,
+		ValueManager:         valueMan,
+		StoredConfigsManager: storedConfigsMan,
+		BatchManager:         batchMan,
+		ImportManager:        importMan,
+		DumpManager:          dumpMan,
+		TableManager:         tableMan,
+		IdempotencyManager:   idempotencyMan,
+		ChangeLogManager:     changeLogMan,
+		WebhookManager:       webhookMan,
+		AuthManager:          newTestAuthManager(t, recordMan, propertyMan, nil),
+		MaskManager:          maskMan,
+	}, recordRepo
+}
+
+// newTestAuthManager returns the manager with authentication disabled if there are no authenticators.
+func newTestAuthManager(t *testing.T, recordMan *api.RecordManager, propertyMan *api.PropertyManager, roles []domain.Role, authenticators ...domain.Authenticator) *api.AuthManager {
+	out, err := api.NewAuthManager(api.AuthConfig{
+		Authenticators:  authenticators,
+		Roles:           roles,
+		RecordManager:   recordMan,
+		PropertyManager: propertyMan,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
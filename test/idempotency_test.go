@@ -0,0 +1,221 @@
+package test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/internal/rest"
+	"datatom/test/mocks"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type IdempotencyHandlersTestSuite struct {
+	suite.Suite
+	man  *api.IdempotencyManager
+	repo *mocks.IdempotencyRepository
+}
+
+func TestIdempotencyHandlers(t *testing.T) {
+	suite.Run(t, new(IdempotencyHandlersTestSuite))
+}
+
+func (s *IdempotencyHandlersTestSuite) SetupTest() {
+	s.man, s.repo = newTestIdempotencyMockedManager(s.T())
+}
+
+func idempotencyRequest(key string, body string) (handlers.IdempotencyRequestSchema, domain.IdempotencyKey) {
+	sum := sha256.Sum256([]byte(body))
+	return handlers.IdempotencyRequestSchema{
+		Key:    key,
+		Method: http.MethodPost,
+		Path:   "/record",
+		Body:   []byte(body),
+	}, domain.IdempotencyKey{
+		Key:         key,
+		Scope:       "POST /record",
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+}
+
+func (s *IdempotencyHandlersTestSuite) TestReserve() {
+	req, key := idempotencyRequest("new", `{"name":"rec"}`)
+	reqStored, keyStored := idempotencyRequest("stored", `{"name":"rec"}`)
+	reqEProgress, keyEProgress := idempotencyRequest("progress", `{"name":"rec"}`)
+	reqEReused, keyEReused := idempotencyRequest("reused", `{"name":"other"}`)
+	reqE, keyE := idempotencyRequest("error", `{"name":"rec"}`)
+	reqEEmpty, _ := idempotencyRequest(" ", `{"name":"rec"}`)
+	stored := &domain.IdempotentResponse{
+		Status:  http.StatusCreated,
+		Headers: map[string]string{"Location": "/record/12345678-1234-1234-1234-123456789012"},
+		Body:    []byte("12345678-1234-1234-1234-123456789012"),
+	}
+	s.repo.
+		On("ReserveIdempotencyKey", mock.Anything, key, time.Hour, time.Minute).Return(nil, nil).
+		On("ReserveIdempotencyKey", mock.Anything, keyStored, time.Hour, time.Minute).Return(stored, nil).
+		On("ReserveIdempotencyKey", mock.Anything, keyEProgress, time.Hour, time.Minute).Return(nil, domain.ErrIdempotencyKeyInProgress).
+		On("ReserveIdempotencyKey", mock.Anything, keyEReused, time.Hour, time.Minute).Return(nil, domain.ErrIdempotencyKeyReused).
+		On("ReserveIdempotencyKey", mock.Anything, keyE, time.Hour, time.Minute).Return(nil, errors.New("error"))
+
+	type testCase struct {
+		name    string
+		req     handlers.IdempotencyRequestSchema
+		want    handlers.Result
+		wantRes *domain.IdempotentResponse
+		wantErr bool
+		err     error
+	}
+	cases := []testCase{
+		{
+			name: "reserve",
+			req:  req,
+			want: handlers.Result{Status: http.StatusOK},
+		},
+		{
+			name:    "reserve repeated",
+			req:     reqStored,
+			want:    handlers.Result{Status: http.StatusOK},
+			wantRes: stored,
+		},
+		{
+			name:    "reserve in progress error",
+			req:     reqEProgress,
+			want:    handlers.Result{Status: http.StatusConflict},
+			wantErr: true,
+			err:     domain.ErrIdempotencyKeyInProgress,
+		},
+		{
+			name:    "reserve reused error",
+			req:     reqEReused,
+			want:    handlers.Result{Status: http.StatusUnprocessableEntity},
+			wantErr: true,
+			err:     domain.ErrIdempotencyKeyReused,
+		},
+		{
+			name:    "reserve error",
+			req:     reqE,
+			want:    handlers.Result{Status: http.StatusInternalServerError},
+			wantErr: true,
+			err:     errors.New("error"),
+		},
+		{
+			name:    "reserve empty key error",
+			req:     reqEEmpty,
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			actual, res, err := handlers.ReserveIdempotencyKey(context.Background(), s.man, c.req)
+			if c.wantErr {
+				s.Require().Error(err)
+				if c.err != nil {
+					s.Require().EqualError(err, c.err.Error())
+				}
+			} else {
+				s.Require().NoError(err)
+			}
+			s.EqualValues(c.want, actual)
+			s.Equal(c.wantRes, res)
+		})
+	}
+}
+
+func (s *IdempotencyHandlersTestSuite) TestSave() {
+	req, key := idempotencyRequest("created", `{"name":"rec"}`)
+	reqBad, keyBad := idempotencyRequest("bad", `{"name":"rec"}`)
+	reqFailed, keyFailed := idempotencyRequest("failed", `{"name":"rec"}`)
+	created := domain.IdempotentResponse{Status: http.StatusCreated, Body: []byte("id")}
+	bad := domain.IdempotentResponse{Status: http.StatusBadRequest, Body: []byte("error")}
+	failed := domain.IdempotentResponse{Status: http.StatusInternalServerError}
+	s.repo.
+		On("SaveIdempotentResponse", mock.Anything, key, created).Return(nil).
+		On("SaveIdempotentResponse", mock.Anything, keyBad, bad).Return(nil).
+		On("ReleaseIdempotencyKey", mock.Anything, keyFailed).Return(nil)
+
+	s.Require().NoError(handlers.SaveIdempotentResponse(context.Background(), s.man, req, created))
+	s.Require().NoError(handlers.SaveIdempotentResponse(context.Background(), s.man, reqBad, bad))
+	s.Require().NoError(handlers.SaveIdempotentResponse(context.Background(), s.man, reqFailed, failed))
+	s.repo.AssertNotCalled(s.T(), "SaveIdempotentResponse", mock.Anything, keyFailed, mock.Anything)
+}
+
+func (s *IdempotencyHandlersTestSuite) TestScopeBySubject() {
+	req, key := idempotencyRequest("new", `{"name":"rec"}`)
+	req.Subject = "alice"
+	actual, err := req.IdempotencyKey()
+	s.Require().NoError(err)
+	s.Equal(`POST /record "alice"`, actual.Scope)
+	s.NotEqual(key.Scope, actual.Scope)
+}
+
+func (s *IdempotencyHandlersTestSuite) TestScopeOfAliases() {
+	c, _ := newTestServerConfig(s.T())
+	c.IdempotencyManager = s.man
+	srv, err := rest.NewServer(c)
+	s.Require().NoError(err)
+	r, ok := srv.(interface{ Routes() chi.Routes })
+	s.Require().True(ok)
+	handler, ok := r.Routes().(http.Handler)
+	s.Require().True(ok)
+
+	body := `{"name":"rec"}`
+	_, key := idempotencyRequest("alias", body)
+	key.Scope = "POST /v1/record"
+	stored := &domain.IdempotentResponse{Status: http.StatusCreated, Body: []byte("12345678-1234-1234-1234-123456789012")}
+	s.repo.On("ReserveIdempotencyKey", mock.Anything, key, time.Hour, time.Minute).Return(stored, nil).Times(3)
+
+	// Retries by the root alias and with the trailing slash have the key of the versioned route
+	for _, path := range []string{"/v1/record", "/record", "/record/"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "alias")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		s.Equal(http.StatusCreated, w.Code, path)
+		s.Equal("true", w.Header().Get("Idempotent-Replayed"), path)
+	}
+}
+
+func (s *IdempotencyHandlersTestSuite) TestReleaseOnPanic() {
+	c, recordRepo := newTestServerConfig(s.T())
+	c.IdempotencyManager = s.man
+	srv, err := rest.NewServer(c)
+	s.Require().NoError(err)
+	r, ok := srv.(interface{ Routes() chi.Routes })
+	s.Require().True(ok)
+	handler, ok := r.Routes().(http.Handler)
+	s.Require().True(ok)
+
+	body := `{"name":"rec"}`
+	_, key := idempotencyRequest("panic", body)
+	key.Scope = "POST /v1/record"
+	s.repo.
+		On("ReserveIdempotencyKey", mock.Anything, key, time.Hour, time.Minute).Return(nil, nil).Once().
+		On("ReleaseIdempotencyKey", mock.Anything, key).Return(nil).Once()
+	recordRepo.
+		On("AddRecord", mock.Anything, mock.Anything, mock.Anything).Return(uuid.Nil, nil).
+		Run(func(mock.Arguments) { panic("handler failed") }).Once()
+	req := httptest.NewRequest(http.MethodPost, "/v1/record", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "panic")
+
+	s.PanicsWithValue("handler failed", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	s.repo.AssertCalled(s.T(), "ReleaseIdempotencyKey", mock.Anything, key)
+	s.repo.AssertNotCalled(s.T(), "SaveIdempotentResponse", mock.Anything, mock.Anything, mock.Anything)
+}
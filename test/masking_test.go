@@ -0,0 +1,278 @@
+package test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/test/mocks"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	maskSupport = &domain.Principal{Subject: "support", Roles: []string{"support"}}
+	maskAuditor = &domain.Principal{Subject: "auditor", Roles: []string{"support", "billing"}}
+	maskHashKey = []byte("mask hash key of the test 32 b!!")
+)
+
+type MaskingTestSuite struct {
+	suite.Suite
+	mask         domain.MaskPolicy
+	man          *api.MaskManager
+	propertyRepo *mocks.PropertyRepository
+	auditRepo    *mocks.AuditRepository
+}
+
+func TestMasking(t *testing.T) {
+	suite.Run(t, new(MaskingTestSuite))
+}
+
+func (s *MaskingTestSuite) SetupTest() {
+	s.mask = domain.MaskPolicy{Kind: domain.MaskPartial, Reveal: 4, UnmaskedRoles: []string{"billing"}}
+	s.man, s.propertyRepo, s.auditRepo = newTestMaskMockedManager(s.T())
+	s.man.HashKey = maskHashKey
+}
+
+func (s *MaskingTestSuite) TestMask() {
+	type testCase struct {
+		name string
+		mask domain.MaskPolicy
+		in   any
+		want any
+	}
+	cases := []testCase{
+		{"partial", s.mask, "4111111111111111", "************1111"},
+		{"partial of number", s.mask, 380501234567.0, "********4567"},
+		{"partial of runes", domain.MaskPolicy{Kind: domain.MaskPartial, Reveal: 2}, "пароль", "****ль"},
+		{"partial of short value", s.mask, "123", "***"},
+		{"partial without reveal", domain.MaskPolicy{Kind: domain.MaskPartial}, "secret", "******"},
+		{"hash", domain.MaskPolicy{Kind: domain.MaskHash}, "secret", "92ab06c42d374c088b22be54b3f1166c018096864f53023c70b352accd9b4c91"},
+		{"hide", domain.MaskPolicy{Kind: domain.MaskHide}, "secret", nil},
+		{"envelope", s.mask, domain.EncryptedValue{KeyID: "k1", MAC: "ab"}, nil},
+		{"null", s.mask, nil, nil},
+	}
+	for _, c := range cases {
+		s.Equal(domain.MaskedValue{Value: c.want}, c.mask.Mask(domain.Value{Value: c.in}, maskHashKey), c.name)
+	}
+	date := time.Date(2023, 2, 13, 21, 21, 21, 0, time.UTC)
+	// Dates are masked by their RFC 3339 text
+	hash := domain.MaskPolicy{Kind: domain.MaskHash}
+	s.Equal(hash.Mask(domain.Value{Value: date.Format(time.RFC3339)}, maskHashKey), hash.Mask(domain.Value{Value: date}, maskHashKey))
+}
+
+func (s *MaskingTestSuite) TestMaskHash() {
+	hash := domain.MaskPolicy{Kind: domain.MaskHash}
+	// Hashes of guessed values do not match hashed ones without the key
+	sum := sha256.Sum256([]byte("secret"))
+	s.NotEqual(domain.MaskedValue{Value: hex.EncodeToString(sum[:])}, hash.Mask(domain.Value{Value: "secret"}, maskHashKey))
+	s.NotEqual(hash.Mask(domain.Value{Value: "secret"}, maskHashKey), hash.Mask(domain.Value{Value: "secret"}, []byte("other key")))
+	// Values are hidden without the key
+	s.Equal(domain.MaskedValue{}, hash.Mask(domain.Value{Value: "secret"}, nil))
+}
+
+func (s *MaskingTestSuite) TestUnmasks() {
+	s.True(s.mask.Unmasks(nil))
+	s.False(s.mask.Unmasks(maskSupport))
+	s.True(s.mask.Unmasks(maskAuditor))
+}
+
+func (s *MaskingTestSuite) TestMaskKind() {
+	for _, k := range []domain.MaskKind{domain.MaskHide, domain.MaskPartial, domain.MaskHash} {
+		got, err := domain.MaskKindFromCode(k.Code())
+		s.Require().NoError(err)
+		s.Equal(k, got)
+	}
+	_, err := domain.MaskKindFromCode("blur")
+	s.ErrorIs(err, domain.ErrUnknownType)
+}
+
+func (s *MaskingTestSuite) newValueManager() *api.ValueManager {
+	valueRepo := mocks.NewValueRepository(s.T())
+	recordRepo := mocks.NewRecordRepository(s.T())
+	recordRepo.On("GetRecordACLs", mock.Anything, mock.Anything).Return(map[uuid.UUID]domain.ACL{}, nil).Maybe()
+	man, err := api.NewValueManager(api.ValueConfig{
+		Repository:       valueRepo,
+		RecordRepository: recordRepo,
+		Broker:           mocks.NewValueBroker(s.T()),
+		Masker:           s.man,
+		Timeout:          time.Second,
+	})
+	s.Require().NoError(err)
+	return man
+}
+
+func (s *MaskingTestSuite) TestGetValue() {
+	man := s.newValueManager()
+	v := domain.Value{RecordID: uuid.New(), PropertyID: uuid.New(), Type: domain.TypeText, Value: "4111111111111111"}
+	req := domain.GetValueRequest{RecordID: v.RecordID, PropertyID: v.PropertyID}
+	man.Repository.(*mocks.ValueRepository).On("GetValue", mock.Anything, req).
+		Return(func(context.Context, domain.GetValueRequest) *domain.Value {
+			out := v
+			return &out
+		}, nil)
+	s.propertyRepo.On("GetPropertyMasks", mock.Anything, []uuid.UUID{v.PropertyID}).
+		Return(map[uuid.UUID]domain.MaskPolicy{v.PropertyID: s.mask}, nil)
+
+	got, err := man.Get(api.ContextWithPrincipal(context.Background(), maskSupport), req)
+	s.Require().NoError(err)
+	s.Equal(domain.MaskedValue{Value: "************1111"}, got.Value)
+
+	s.auditRepo.On("RegisterUnmaskedReads", mock.Anything, []domain.UnmaskedRead{{
+		Subject:    maskAuditor.Subject,
+		Roles:      maskAuditor.Roles,
+		RecordID:   v.RecordID,
+		PropertyID: v.PropertyID,
+	}}).Return(nil).Once()
+	got, err = man.Get(api.ContextWithPrincipal(context.Background(), maskAuditor), req)
+	s.Require().NoError(err)
+	s.Equal(v.Value, got.Value)
+
+	// The service itself and clients without authentication read values as they are and are not audited
+	got, err = man.Get(context.Background(), req)
+	s.Require().NoError(err)
+	s.Equal(v.Value, got.Value)
+	s.propertyRepo.AssertNumberOfCalls(s.T(), "GetPropertyMasks", 2)
+}
+
+func (s *MaskingTestSuite) TestAuditFailure() {
+	man := s.newValueManager()
+	v := domain.Value{RecordID: uuid.New(), PropertyID: uuid.New(), Type: domain.TypeText, Value: "secret"}
+	req := domain.GetValueRequest{RecordID: v.RecordID, PropertyID: v.PropertyID}
+	man.Repository.(*mocks.ValueRepository).On("GetValue", mock.Anything, req).Return(&v, nil).Once()
+	s.propertyRepo.On("GetPropertyMasks", mock.Anything, []uuid.UUID{v.PropertyID}).
+		Return(map[uuid.UUID]domain.MaskPolicy{v.PropertyID: s.mask}, nil).Once()
+	s.auditRepo.On("RegisterUnmaskedReads", mock.Anything, mock.Anything).Return(context.DeadlineExceeded).Once()
+	_, err := man.Get(api.ContextWithPrincipal(context.Background(), maskAuditor), req)
+	s.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func (s *MaskingTestSuite) TestGetRecords() {
+	repo := mocks.NewRecordRepository(s.T())
+	man, err := api.NewRecordManager(api.RecordConfig{
+		Repository: repo,
+		Broker:     mocks.NewRecordBroker(s.T()),
+		Masker:     s.man,
+		Timeout:    time.Second,
+	})
+	s.Require().NoError(err)
+	masked, plain := uuid.New(), uuid.New()
+	first := domain.RecordWithValues{Record: domain.Record{ID: uuid.New()}, Values: []domain.Value{
+		{PropertyID: masked, Type: domain.TypeText, Value: "+380501234567"},
+		{PropertyID: plain, Type: domain.TypeText, Value: "Kyiv"},
+	}}
+	first.Values[0].RecordID, first.Values[1].RecordID = first.ID, first.ID
+	second := domain.RecordWithValues{Record: domain.Record{ID: uuid.New()}, Values: []domain.Value{
+		{RecordID: uuid.Nil, PropertyID: masked, Type: domain.TypeText, Value: "+380671234567"},
+	}}
+	second.Values[0].RecordID = second.ID
+	ids := []uuid.UUID{first.ID, second.ID}
+	repo.On("GetRecords", mock.Anything, ids, true).Return([]domain.RecordWithValues{first, second}, nil).Once()
+	s.propertyRepo.On("GetPropertyMasks", mock.Anything, []uuid.UUID{masked, plain, masked}).
+		Return(map[uuid.UUID]domain.MaskPolicy{masked: s.mask}, nil).Once()
+
+	got, err := man.GetMany(api.ContextWithPrincipal(context.Background(), maskSupport), ids, true)
+	s.Require().NoError(err)
+	s.Require().Len(got, 2)
+	s.Equal(domain.MaskedValue{Value: "*********4567"}, got[0].Values[0].Value)
+	s.Equal("Kyiv", got[0].Values[1].Value)
+	s.Equal(domain.MaskedValue{Value: "*********4567"}, got[1].Values[0].Value)
+}
+
+func (s *MaskingTestSuite) TestExportTable() {
+	repo := mocks.NewTableRepository(s.T())
+	man, err := api.NewTableManager(api.TableConfig{Repository: repo, Masker: s.man, Timeout: time.Second})
+	s.Require().NoError(err)
+	propertyID := uuid.New()
+	req := domain.TableRequest{RefTypeID: uuid.New()}
+	row := domain.TableRow{Record: domain.Record{ID: uuid.New()}, Values: []domain.TableValue{
+		{Value: domain.Value{PropertyID: propertyID, Type: domain.TypeText, Value: "4111111111111111"}},
+	}}
+	repo.On("ExportTable", mock.Anything, req, mock.Anything).
+		Run(func(args mock.Arguments) {
+			w := args.Get(2).(domain.TableWriter)
+			s.Require().NoError(w.WriteHeader([]domain.Property{{ID: propertyID, Name: "Card", Mask: &s.mask}}))
+			s.Require().NoError(w.WriteRow(row))
+		}).
+		Return(nil).Once()
+	w := &aclTestTableWriter{}
+	s.Require().NoError(man.Export(api.ContextWithPrincipal(context.Background(), maskSupport), req, w))
+	s.Require().Len(w.rows, 1)
+	s.Equal(domain.MaskedValue{Value: "************1111"}, w.rows[0].Values[0].Value.Value)
+	s.auditRepo.AssertNotCalled(s.T(), "RegisterUnmaskedReads", mock.Anything, mock.Anything)
+}
+
+func (s *MaskingTestSuite) TestResponseSchema() {
+	b, err := json.Marshal(handlers.ValueToResponseSchema(domain.Value{Type: domain.TypeText, Value: domain.MaskedValue{Value: "**34"}}))
+	s.Require().NoError(err)
+	s.Contains(string(b), `"value":"**34","masked":true`)
+
+	b, err = json.Marshal(handlers.ValueToResponseSchema(domain.Value{Type: domain.TypeText, Value: "1234"}))
+	s.Require().NoError(err)
+	s.NotContains(string(b), "masked")
+}
+
+func (s *MaskingTestSuite) TestMaskRequest() {
+	mask, err := handlers.PropertyMaskSchema{Kind: "partial", Reveal: 4, UnmaskedRoles: []string{"billing"}}.MaskPolicy()
+	s.Require().NoError(err)
+	s.Equal(&s.mask, mask)
+
+	_, err = handlers.PropertyMaskSchema{Kind: "blur"}.MaskPolicy()
+	s.ErrorIs(err, domain.ErrUnknownType)
+	s.Equal("kind", domain.ErrorField(err))
+	_, err = handlers.PropertyMaskSchema{Kind: "hash", Reveal: 4}.MaskPolicy()
+	s.Equal("reveal", domain.ErrorField(err))
+	_, err = handlers.PropertyMaskSchema{Kind: "hide", UnmaskedRoles: []string{""}}.MaskPolicy()
+	s.Equal("unmasked_roles[0]", domain.ErrorField(err))
+}
+
+func (s *MaskingTestSuite) TestSetMask() {
+	man, repo, _ := newTestPropertyMockedManager(s.T())
+	id := uuid.New()
+	repo.On("SetPropertyMask", mock.Anything, id, &s.mask).Return(&domain.Property{ID: id, Mask: &s.mask, Sum: "sum"}, nil).Once()
+	res, err := handlers.SetPropertyMask(context.Background(), man, handlers.SetPropertyMaskRequestSchema{
+		ID:                 id.String(),
+		PropertyMaskSchema: handlers.PropertyMaskSchema{Kind: "partial", Reveal: 4, UnmaskedRoles: []string{"billing"}},
+	})
+	s.Require().NoError(err)
+	s.Equal("sum", res.Sum)
+	s.Contains(string(res.Payload), `"mask":{"kind":"partial","reveal":4,"unmasked_roles":["billing"]}`)
+
+	repo.On("SetPropertyMask", mock.Anything, id, (*domain.MaskPolicy)(nil)).Return(nil, domain.ErrPropertyNotFound).Once()
+	res, err = handlers.DeletePropertyMask(context.Background(), man, id.String())
+	s.ErrorIs(err, domain.ErrPropertyNotFound)
+	s.Equal(404, res.Status)
+}
+
+func (s *MaskingTestSuite) TestUnmaskedReads() {
+	read := domain.UnmaskedRead{ID: 8, Subject: "auditor", RecordID: uuid.New(), PropertyID: uuid.New(), ReadAt: time.Now().UTC()}
+	s.auditRepo.On("GetUnmaskedReads", mock.Anything, domain.UnmaskedReadsRequest{AfterID: 7, Subject: "auditor", Limit: 100}).
+		Return([]domain.UnmaskedRead{read}, nil).Once()
+	res, err := handlers.GetUnmaskedReads(context.Background(), s.man, handlers.UnmaskedReadsRequestSchema{After: "7", Subject: "auditor"})
+	s.Require().NoError(err)
+	var got handlers.UnmaskedReadsResponseSchema
+	s.Require().NoError(json.Unmarshal(res.Payload, &got))
+	s.Equal(int64(8), got.Next)
+	s.Require().Len(got.Reads, 1)
+	s.Equal([]string{}, got.Reads[0].Roles)
+
+	_, err = handlers.GetUnmaskedReads(context.Background(), s.man, handlers.UnmaskedReadsRequestSchema{PropertyID: "x"})
+	s.Equal("property_id", domain.ErrorField(err))
+}
+
+func (s *MaskingTestSuite) TestDump() {
+	property := domain.Property{ID: uuid.New(), Name: "card", Types: []domain.Type{domain.TypeText}, Mask: &s.mask}
+	schema, err := handlers.DumpItemToSchema(domain.DumpItem{Kind: domain.DumpItemProperty, Property: &property})
+	s.Require().NoError(err)
+	item, err := schema.DumpItem()
+	s.Require().NoError(err)
+	s.Equal(&s.mask, item.Property.Mask)
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"datatom/internal/domain"
+	pkgpg "datatom/pkg/db/pg"
+	"datatom/pkg/metrics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+}
+
+func TestMetrics(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
+
+func (s *MetricsTestSuite) scrape() string {
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	s.Require().Equal(http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
+
+func (s *MetricsTestSuite) TestHTTP() {
+	srv, _ := newTestServer(s.T())
+	r, ok := srv.(interface{ Routes() chi.Routes })
+	s.Require().True(ok)
+	handler, ok := r.Routes().(http.Handler)
+	s.Require().True(ok)
+	id := uuid.NewString()
+	for _, path := range []string{"/v1/health/ping", "/v1/unknown/" + id} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	out := s.scrape()
+	// Routes are labeled by their templates, so paths with IDs do not make new series
+	s.Contains(out, `datatom_http_request_duration_seconds_count{method="GET",route="/v1/health/ping",status="200"}`)
+	s.Contains(out, `status="404"`)
+	s.NotContains(out, id)
+	s.Contains(out, "go_goroutines")
+}
+
+func (s *MetricsTestSuite) TestPool() {
+	cfg, err := pkgpg.NewPoolConfig(pkgpg.Config{Address: "localhost", Port: 5432, PoolMaxConn: 3})
+	s.Require().NoError(err)
+	// Connections are created on demand, so the pool is not connected
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	s.Require().NoError(err)
+	defer pool.Close()
+
+	reg := prometheus.NewPedanticRegistry()
+	s.Require().NoError(reg.Register(pkgpg.NewPoolCollector(pool)))
+	s.NoError(testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP datatom_db_pool_acquired_conns Connections in use.
+# TYPE datatom_db_pool_acquired_conns gauge
+datatom_db_pool_acquired_conns 0
+# HELP datatom_db_pool_max_conns Maximum size of the pool.
+# TYPE datatom_db_pool_max_conns gauge
+datatom_db_pool_max_conns 3
+`), "datatom_db_pool_acquired_conns", "datatom_db_pool_max_conns"))
+	n, err := testutil.GatherAndCount(reg)
+	s.Require().NoError(err)
+	s.Equal(8, n)
+}
+
+func (s *MetricsTestSuite) TestPendingChanges() {
+	man, repo := newTestChangedDataManager(s.T())
+	want := domain.PendingChanges{Count: 3, OldestChangedAt: time.Now().Add(-time.Minute).UTC()}
+	repo.On("GetPendingChanges", mock.Anything).Return(want, nil).Once()
+	got, err := man.Pending(context.Background())
+	s.Require().NoError(err)
+	s.Equal(want, got)
+}
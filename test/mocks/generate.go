@@ -19,3 +19,15 @@ package mocks
 //go:generate go run github.com/vektra/mockery/v2@latest --dir ../../internal/domain --name StoredConfigRepository --output "."
 
 //go:generate go run github.com/vektra/mockery/v2@latest --dir ../../internal/domain --name ChangedDataRepository --output "."
+
+//go:generate go run github.com/vektra/mockery/v2@latest --dir ../../internal/domain --name DumpRepository --output "."
+
+//go:generate go run github.com/vektra/mockery/v2@latest --dir ../../internal/domain --name TableRepository --output "."
+
+//go:generate go run github.com/vektra/mockery/v2@latest --dir ../../internal/domain --name IdempotencyRepository --output "."
+
+//go:generate go run github.com/vektra/mockery/v2@latest --dir ../../internal/domain --name ChangeLogRepository --output "."
+
+//go:generate go run github.com/vektra/mockery/v2@latest --dir ../../internal/domain --name WebhookRepository --output "."
+
+//go:generate go run github.com/vektra/mockery/v2@latest --dir ../../internal/domain --name AuditRepository --output "."
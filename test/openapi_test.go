@@ -0,0 +1,180 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"datatom/internal/domain"
+	"datatom/pkg/openapi"
+	"datatom/test/mocks"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type OpenAPITestSuite struct {
+	suite.Suite
+	routes     chi.Routes
+	handler    http.Handler
+	recordRepo *mocks.RecordRepository
+}
+
+func TestOpenAPI(t *testing.T) {
+	suite.Run(t, new(OpenAPITestSuite))
+}
+
+func (s *OpenAPITestSuite) SetupTest() {
+	srv, recordRepo := newTestServer(s.T())
+	r, ok := srv.(interface{ Routes() chi.Routes })
+	s.Require().True(ok)
+	s.routes = r.Routes()
+	s.handler, ok = s.routes.(http.Handler)
+	s.Require().True(ok)
+	s.recordRepo = recordRepo
+}
+
+func (s *OpenAPITestSuite) serve(method, path, body string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, httptest.NewRequest(method, path, r))
+	return rec
+}
+
+func (s *OpenAPITestSuite) TestRoutes() {
+	rec := s.serve(http.MethodGet, "/v1/openapi.json", "")
+	s.Require().Equal(http.StatusOK, rec.Code)
+	doc, err := openapi.Parse(rec.Body.Bytes())
+	s.Require().NoError(err)
+
+	var documented []string
+	for path, item := range doc.Paths {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+	// Routes at the root are aliases of v1 ones
+	var routed, legacy []string
+	err = chi.Walk(s.routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if path, ok := strings.CutPrefix(route, "/v1/"); ok {
+			routed = append(routed, method+" "+openapi.PathTemplate("/"+path))
+		} else {
+			legacy = append(legacy, method+" "+openapi.PathTemplate(route))
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+	sort.Strings(documented)
+	sort.Strings(routed)
+	sort.Strings(legacy)
+	s.Require().NotEmpty(routed)
+	s.Equal(routed, documented)
+	s.Equal(routed, legacy)
+}
+
+func (s *OpenAPITestSuite) TestDeprecation() {
+	rec := s.serve(http.MethodGet, "/v1/health/ping", "")
+	s.Equal(http.StatusOK, rec.Code)
+	s.Empty(rec.Header().Get("Deprecation"))
+
+	rec = s.serve(http.MethodGet, "/health/ping", "")
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("true", rec.Header().Get("Deprecation"))
+	s.Equal(`</v1/health/ping>; rel="successor-version"`, rec.Header().Get("Link"))
+}
+
+func (s *OpenAPITestSuite) TestValidation() {
+	id := uuid.New()
+	s.recordRepo.On("AddRecord", mock.Anything, domain.AddRecordRequest{Name: "rec"}, nil).Return(id, nil)
+
+	type testCase struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		want      int
+		wantField string
+	}
+	cases := []testCase{
+		{
+			name:   "valid",
+			method: http.MethodPost,
+			path:   "/v1/record",
+			body:   `{"name":"rec"}`,
+			want:   http.StatusCreated,
+		},
+		{
+			name:      "type error",
+			method:    http.MethodPost,
+			path:      "/v1/record/",
+			body:      `{"name":1}`,
+			want:      http.StatusBadRequest,
+			wantField: "name",
+		},
+		{
+			name:      "enum error",
+			method:    http.MethodPost,
+			path:      "/v1/property",
+			body:      `{"types":["text","color"]}`,
+			want:      http.StatusBadRequest,
+			wantField: "types[1]",
+		},
+		{
+			name:      "required error",
+			method:    http.MethodPut,
+			path:      "/v1/value",
+			body:      `{"property_id":"` + id.String() + `","type":"text","value":"v"}`,
+			want:      http.StatusBadRequest,
+			wantField: "record_id",
+		},
+		{
+			name:      "nested error",
+			method:    http.MethodPost,
+			path:      "/property",
+			body:      `{"types":["text"],"kind":"sequence","sequence":{"reset_period":"daily"}}`,
+			want:      http.StatusBadRequest,
+			wantField: "sequence.reset_period",
+		},
+		{
+			name:   "body error",
+			method: http.MethodPost,
+			path:   "/batch",
+			body:   `[]`,
+			want:   http.StatusBadRequest,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			rec := s.serve(c.method, c.path, c.body)
+			s.Equal(c.want, rec.Code)
+			if c.want != http.StatusBadRequest {
+				return
+			}
+			s.Equal("application/problem+json", rec.Header().Get("Content-Type"))
+			var problem map[string]any
+			s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &problem))
+			if c.wantField == "" {
+				s.NotContains(problem, "field")
+				s.Equal("bad_request", problem["code"])
+			} else {
+				s.Equal(c.wantField, problem["field"])
+				s.Equal("invalid_field", problem["code"])
+			}
+		})
+	}
+}
+
+func (s *OpenAPITestSuite) TestNotFound() {
+	rec := s.serve(http.MethodGet, "/unknown", "")
+	s.Equal(http.StatusNotFound, rec.Code)
+	s.Equal("application/problem+json", rec.Header().Get("Content-Type"))
+}
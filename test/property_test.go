@@ -39,8 +39,8 @@ func (s *PropertyManagerTestSuite) TestAdd() {
 	req := domain.AddPropertyRequest{Name: "prop"}
 	reqE := domain.AddPropertyRequest{Name: "error"}
 	s.repo.
-		On("AddProperty", mock.Anything, req).Return(id, nil).
-		On("AddProperty", mock.Anything, reqE).Return(uuid.Nil, errors.New("error"))
+		On("AddProperty", mock.Anything, req, nil).Return(id, nil).
+		On("AddProperty", mock.Anything, reqE, nil).Return(uuid.Nil, errors.New("error"))
 
 	type args struct {
 		ctx context.Context
@@ -93,8 +93,8 @@ func (s *PropertyManagerTestSuite) TestUpdate() {
 		Description: "description",
 	}
 	s.repo.
-		On("UpdateProperty", mock.Anything, req).Return(prop, nil).
-		On("UpdateProperty", mock.Anything, reqE).Return(nil, domain.ErrPropertyNotFound)
+		On("UpdateProperty", mock.Anything, req, nil).Return(prop, nil).
+		On("UpdateProperty", mock.Anything, reqE, nil).Return(nil, domain.ErrPropertyNotFound)
 
 	type args struct {
 		ctx context.Context
@@ -527,11 +527,126 @@ func (s *PropertyHandlersTestSuite) TestAdd() {
 		Types:          []string{"ref"},
 		OwnerRefTypeID: "hello",
 	}
+	mockReqSeq := domain.AddPropertyRequest{
+		Name:           "number",
+		Types:          []domain.Type{domain.TypeText},
+		RefTypeIDs:     []uuid.UUID{},
+		OwnerRefTypeID: uuid.MustParse(validUUID1),
+		Kind:           domain.PropertyKindSequence,
+		Sequence: &domain.Sequence{
+			Prefix:      "INV-",
+			Template:    "{prefix}{yyyy}-{n:6}",
+			ResetPeriod: domain.SequenceResetYearly,
+		},
+	}
+	mockReqSeqD := domain.AddPropertyRequest{
+		Name:           "default number",
+		Types:          []domain.Type{domain.TypeText},
+		RefTypeIDs:     []uuid.UUID{},
+		OwnerRefTypeID: uuid.MustParse(validUUID1),
+		Kind:           domain.PropertyKindSequence,
+		Sequence:       &domain.Sequence{Template: domain.DefaultSequenceTemplate},
+	}
+	mockReqSeqEPG := domain.AddPropertyRequest{
+		Name:           "number PG error",
+		Types:          []domain.Type{domain.TypeText},
+		RefTypeIDs:     []uuid.UUID{},
+		OwnerRefTypeID: uuid.MustParse(validUUID1),
+		Kind:           domain.PropertyKindSequence,
+		Sequence:       &domain.Sequence{Template: "{prefix}"},
+	}
+	reqSeq := handlers.AddPropertyRequestSchema{
+		Name:           mockReqSeq.Name,
+		Types:          []string{"text"},
+		OwnerRefTypeID: validUUID1,
+		Kind:           "sequence",
+		Sequence: &handlers.PropertySequenceSchema{
+			Prefix:      "INV-",
+			Template:    "{prefix}{yyyy}-{n:6}",
+			ResetPeriod: "yearly",
+		},
+	}
+	reqSeqD := handlers.AddPropertyRequestSchema{
+		Name:           mockReqSeqD.Name,
+		OwnerRefTypeID: validUUID1,
+		Kind:           "sequence",
+		Sequence:       &handlers.PropertySequenceSchema{},
+	}
+	reqSeqEPG := handlers.AddPropertyRequestSchema{
+		Name:           mockReqSeqEPG.Name,
+		OwnerRefTypeID: validUUID1,
+		Kind:           "sequence",
+		Sequence:       &handlers.PropertySequenceSchema{Template: "{prefix}"},
+	}
+	reqESeqWoS := handlers.AddPropertyRequestSchema{
+		Name:           mockReqE.Name,
+		OwnerRefTypeID: validUUID1,
+		Kind:           "sequence",
+	}
+	reqESeqRedundant := handlers.AddPropertyRequestSchema{
+		Name:     mockReqE.Name,
+		Types:    []string{"text"},
+		Sequence: &handlers.PropertySequenceSchema{},
+	}
+	reqEKind := handlers.AddPropertyRequestSchema{
+		Name:  mockReqE.Name,
+		Types: []string{"text"},
+		Kind:  "hello",
+	}
+	reqESeqPeriod := handlers.AddPropertyRequestSchema{
+		Name:           mockReqE.Name,
+		OwnerRefTypeID: validUUID1,
+		Kind:           "sequence",
+		Sequence:       &handlers.PropertySequenceSchema{ResetPeriod: "daily"},
+	}
+	mockReqSM := domain.AddPropertyRequest{
+		Name:       "status",
+		Types:      []domain.Type{domain.TypeText},
+		RefTypeIDs: []uuid.UUID{},
+		Kind:       domain.PropertyKindStateMachine,
+		StateMachine: &domain.StateMachine{
+			States:  []string{"draft", "approved", "archived"},
+			Initial: "draft",
+			Transitions: []domain.StateTransition{
+				{From: "draft", To: "approved"},
+				{From: "approved", To: "archived"},
+			},
+		},
+	}
+	reqSM := handlers.AddPropertyRequestSchema{
+		Name: mockReqSM.Name,
+		Kind: "state_machine",
+		StateMachine: &handlers.PropertyStateMachineSchema{
+			States: []string{"draft", "approved", "archived"},
+			Transitions: []handlers.PropertyStateTransitionSchema{
+				{From: "draft", To: "approved"},
+				{From: "approved", To: "archived"},
+			},
+		},
+	}
+	reqESMWoS := handlers.AddPropertyRequestSchema{
+		Name: mockReqE.Name,
+		Kind: "state_machine",
+	}
+	reqESMWoStates := handlers.AddPropertyRequestSchema{
+		Name:         mockReqE.Name,
+		Kind:         "state_machine",
+		StateMachine: &handlers.PropertyStateMachineSchema{},
+	}
+	reqESMRedundant := handlers.AddPropertyRequestSchema{
+		Name:         mockReqE.Name,
+		Types:        []string{"text"},
+		StateMachine: &handlers.PropertyStateMachineSchema{States: []string{"draft"}},
+	}
 	s.repo.
-		On("AddProperty", mock.Anything, mockReq).Return(id, nil).
-		On("AddProperty", mock.Anything, mockReqM).Return(id, nil).
-		On("AddProperty", mock.Anything, mockReqE).Return(uuid.Nil, errors.New("error")).
-		On("AddProperty", mock.Anything, mockReqEPG).Return(uuid.Nil, domain.ErrTypesConditionNotMatchedPG)
+		On("AddProperty", mock.Anything, mockReqSM, nil).Return(id, nil).
+		On("AddProperty", mock.Anything, mockReq, nil).Return(id, nil).
+		On("AddProperty", mock.Anything, mockReqM, nil).Return(id, nil).
+		On("AddProperty", mock.Anything, mockReqSeq, nil).Return(id, nil).
+		On("AddProperty", mock.Anything, mockReqSeqD, nil).Return(id, nil).
+		On("AddProperty", mock.Anything, mockReqSeqEPG, nil).Return(uuid.Nil, domain.ErrSequenceTemplatePG).
+		On("AddProperty", mock.Anything, mockReqE, nil).Return(uuid.Nil, errors.New("error")).
+		On("AddProperty", mock.Anything, mockReqEPG, nil).Return(uuid.Nil, domain.ErrTypesConditionNotMatchedPG)
 
 	type args struct {
 		ctx context.Context
@@ -602,6 +717,89 @@ func (s *PropertyHandlersTestSuite) TestAdd() {
 			want:    handlers.TextResult{Status: http.StatusBadRequest},
 			wantErr: true,
 		},
+		{
+			name: "add sequence",
+			args: args{ctx: context.Background(), req: reqSeq},
+			want: handlers.TextResult{
+				Payload: id.String(),
+				Status:  http.StatusCreated,
+			},
+		},
+		{
+			name: "add sequence with default settings",
+			args: args{ctx: context.Background(), req: reqSeqD},
+			want: handlers.TextResult{
+				Payload: id.String(),
+				Status:  http.StatusCreated,
+			},
+		},
+		{
+			name:    "add sequence DB error",
+			args:    args{ctx: context.Background(), req: reqSeqEPG},
+			want:    handlers.TextResult{Status: http.StatusBadRequest},
+			wantErr: true,
+			err:     domain.ErrSequenceTemplatePG,
+		},
+		{
+			name:    "add sequence without settings error",
+			args:    args{ctx: context.Background(), req: reqESeqWoS},
+			want:    handlers.TextResult{Status: http.StatusBadRequest},
+			wantErr: true,
+			likeErr: true,
+			err:     domain.ErrExpected,
+		},
+		{
+			name:    "add redundant sequence settings error",
+			args:    args{ctx: context.Background(), req: reqESeqRedundant},
+			want:    handlers.TextResult{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "add unknown kind error",
+			args:    args{ctx: context.Background(), req: reqEKind},
+			want:    handlers.TextResult{Status: http.StatusBadRequest},
+			wantErr: true,
+			likeErr: true,
+			err:     domain.ErrUnknownType,
+		},
+		{
+			name:    "add unknown sequence reset period error",
+			args:    args{ctx: context.Background(), req: reqESeqPeriod},
+			want:    handlers.TextResult{Status: http.StatusBadRequest},
+			wantErr: true,
+			likeErr: true,
+			err:     domain.ErrUnknownType,
+		},
+		{
+			name: "add state machine",
+			args: args{ctx: context.Background(), req: reqSM},
+			want: handlers.TextResult{
+				Payload: id.String(),
+				Status:  http.StatusCreated,
+			},
+		},
+		{
+			name:    "add state machine without settings error",
+			args:    args{ctx: context.Background(), req: reqESMWoS},
+			want:    handlers.TextResult{Status: http.StatusBadRequest},
+			wantErr: true,
+			likeErr: true,
+			err:     domain.ErrExpected,
+		},
+		{
+			name:    "add state machine without states error",
+			args:    args{ctx: context.Background(), req: reqESMWoStates},
+			want:    handlers.TextResult{Status: http.StatusBadRequest},
+			wantErr: true,
+			likeErr: true,
+			err:     domain.ErrExpected,
+		},
+		{
+			name:    "add redundant state machine settings error",
+			args:    args{ctx: context.Background(), req: reqESMRedundant},
+			want:    handlers.TextResult{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
 	}
 	for _, c := range cases {
 		s.Run(c.name, func() {
@@ -675,9 +873,9 @@ func (s *PropertyHandlersTestSuite) TestUpdate() {
 		Description: descr,
 	}
 	s.repo.
-		On("UpdateProperty", mock.Anything, mockReq).Return(prop, nil).
-		On("UpdateProperty", mock.Anything, mockReqE).Return(nil, errors.New("error")).
-		On("UpdateProperty", mock.Anything, mockReqENF).Return(nil, domain.ErrPropertyNotFound)
+		On("UpdateProperty", mock.Anything, mockReq, nil).Return(prop, nil).
+		On("UpdateProperty", mock.Anything, mockReqE, nil).Return(nil, errors.New("error")).
+		On("UpdateProperty", mock.Anything, mockReqENF, nil).Return(nil, domain.ErrPropertyNotFound)
 
 	type args struct {
 		ctx context.Context
@@ -817,14 +1015,14 @@ func (s *PropertyHandlersTestSuite) TestPatch() {
 		Description: descr,
 		Types:       []domain.Type{domain.TypeText},
 	}
-	payload := []byte(fmt.Sprintf(`{"id":"%s","name":"%s","description":"%s","types":["%s"],"reference_type_ids":null,"owner_reference_type_id":null}`, id, name, descr, prop.Types[0].Code()))
+	payload := []byte(fmt.Sprintf(`{"id":"%s","name":"%s","description":"%s","types":["%s"],"reference_type_ids":null,"owner_reference_type_id":null,"kind":"regular"}`, id, name, descr, prop.Types[0].Code()))
 	s.repo.
-		On("UpdateProperty", mock.Anything, mockReq).Return(prop, nil).
-		On("UpdateProperty", mock.Anything, mockReqWoN).Return(prop, nil).
-		On("UpdateProperty", mock.Anything, mockReqWoD).Return(prop, nil).
-		On("UpdateProperty", mock.Anything, mockReqWoAll).Return(prop, nil).
-		On("UpdateProperty", mock.Anything, mockReqE).Return(nil, errors.New("error")).
-		On("UpdateProperty", mock.Anything, mockReqENF).Return(nil, domain.ErrPropertyNotFound)
+		On("UpdateProperty", mock.Anything, mockReq, nil).Return(prop, nil).
+		On("UpdateProperty", mock.Anything, mockReqWoN, nil).Return(prop, nil).
+		On("UpdateProperty", mock.Anything, mockReqWoD, nil).Return(prop, nil).
+		On("UpdateProperty", mock.Anything, mockReqWoAll, nil).Return(prop, nil).
+		On("UpdateProperty", mock.Anything, mockReqE, nil).Return(nil, errors.New("error")).
+		On("UpdateProperty", mock.Anything, mockReqENF, nil).Return(nil, domain.ErrPropertyNotFound)
 
 	type args struct {
 		ctx context.Context
@@ -919,10 +1117,27 @@ func (s *PropertyHandlersTestSuite) TestGet() {
 		Description: descr,
 		Types:       []domain.Type{domain.TypeText},
 	}
-	payload := []byte(fmt.Sprintf(`{"id":"%s","name":"%s","description":"%s","types":["%s"],"reference_type_ids":null,"owner_reference_type_id":null}`, id, name, descr, prop.Types[0].Code()))
+	payload := []byte(fmt.Sprintf(`{"id":"%s","name":"%s","description":"%s","types":["%s"],"reference_type_ids":null,"owner_reference_type_id":null,"kind":"regular"}`, id, name, descr, prop.Types[0].Code()))
+	idS := "55555555-5555-5555-5555-555555555555"
+	idRT := "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	propS := &domain.Property{
+		ID:             uuid.MustParse(idS),
+		Name:           name,
+		Description:    descr,
+		Types:          []domain.Type{domain.TypeText},
+		OwnerRefTypeID: uuid.MustParse(idRT),
+		Kind:           domain.PropertyKindSequence,
+		Sequence: &domain.Sequence{
+			Prefix:      "INV-",
+			Template:    "{prefix}{yyyy}-{n:6}",
+			ResetPeriod: domain.SequenceResetMonthly,
+		},
+	}
+	payloadS := []byte(fmt.Sprintf(`{"id":"%s","name":"%s","description":"%s","types":["text"],"reference_type_ids":null,"owner_reference_type_id":"%s","kind":"sequence","sequence":{"prefix":"INV-","template":"{prefix}{yyyy}-{n:6}","reset_period":"monthly"}}`, idS, name, descr, idRT))
 	payloadE := []byte("parse property id error: ")
 	s.repo.
 		On("GetProperty", mock.Anything, uuid.MustParse(id)).Return(prop, nil).
+		On("GetProperty", mock.Anything, uuid.MustParse(idS)).Return(propS, nil).
 		On("GetProperty", mock.Anything, uuid.MustParse(idE)).Return(nil, errors.New("error")).
 		On("GetProperty", mock.Anything, uuid.MustParse(idENF)).Return(nil, domain.ErrPropertyNotFound)
 
@@ -947,6 +1162,14 @@ func (s *PropertyHandlersTestSuite) TestGet() {
 				Payload: payload,
 			},
 		},
+		{
+			name: "get sequence",
+			args: args{ctx: context.Background(), id: idS},
+			want: handlers.Result{
+				Status:  http.StatusOK,
+				Payload: payloadS,
+			},
+		},
 		{
 			name:    "get error",
 			args:    args{ctx: context.Background(), id: idE},
@@ -39,8 +39,8 @@ func (s *RecordManagerTestSuite) TestAdd() {
 	req := domain.AddRecordRequest{Name: "prop"}
 	reqE := domain.AddRecordRequest{Name: "error"}
 	s.repo.
-		On("AddRecord", mock.Anything, req).Return(id, nil).
-		On("AddRecord", mock.Anything, reqE).Return(uuid.Nil, errors.New("error"))
+		On("AddRecord", mock.Anything, req, nil).Return(id, nil).
+		On("AddRecord", mock.Anything, reqE, nil).Return(uuid.Nil, errors.New("error"))
 
 	type args struct {
 		ctx context.Context
@@ -93,8 +93,8 @@ func (s *RecordManagerTestSuite) TestUpdate() {
 		Description: "description",
 	}
 	s.repo.
-		On("UpdateRecord", mock.Anything, req).Return(rec, nil).
-		On("UpdateRecord", mock.Anything, reqE).Return(nil, domain.ErrRecordNotFound)
+		On("UpdateRecord", mock.Anything, req, nil).Return(rec, nil).
+		On("UpdateRecord", mock.Anything, reqE, nil).Return(nil, domain.ErrRecordNotFound)
 
 	type args struct {
 		ctx context.Context
@@ -451,6 +451,78 @@ func (s *RecordManagerTestSuite) TestGetSender() {
 	}
 }
 
+func (s *RecordManagerTestSuite) TestGetExpanded() {
+	idA := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	idB := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	idC := uuid.MustParse("33333333-3333-3333-3333-333333333333")
+	idP := uuid.MustParse("44444444-4444-4444-4444-444444444444")
+	idPN := uuid.MustParse("55555555-5555-5555-5555-555555555555")
+	idENF := uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+	recA := domain.RecordWithValues{
+		Record: domain.Record{ID: idA},
+		Values: []domain.Value{
+			{RecordID: idA, PropertyID: idP, Type: domain.TypeReference, Value: idB},
+			{RecordID: idA, PropertyID: idPN, Type: domain.TypeNumber, Value: 7},
+		},
+	}
+	recB := domain.RecordWithValues{
+		Record: domain.Record{ID: idB},
+		Values: []domain.Value{
+			{RecordID: idB, PropertyID: idP, Type: domain.TypeReference, Value: idA},
+			{RecordID: idB, PropertyID: idPN, Type: domain.TypeReference, Value: idC},
+		},
+	}
+	recC := domain.RecordWithValues{Record: domain.Record{ID: idC}, Values: []domain.Value{}}
+	s.repo.
+		On("GetRecords", mock.Anything, []uuid.UUID{idA}, true).Return([]domain.RecordWithValues{recA}, nil).Times(3).
+		On("GetRecords", mock.Anything, []uuid.UUID{idA}, false).Return([]domain.RecordWithValues{{Record: recA.Record}}, nil).Once().
+		On("GetRecords", mock.Anything, []uuid.UUID{idB}, true).Return([]domain.RecordWithValues{recB}, nil).Twice().
+		On("GetRecords", mock.Anything, []uuid.UUID{idC}, true).Return([]domain.RecordWithValues{recC}, nil).Once().
+		On("GetRecords", mock.Anything, []uuid.UUID{idC}, false).Return([]domain.RecordWithValues{{Record: recC.Record}}, nil).Once().
+		On("GetRecords", mock.Anything, []uuid.UUID{idENF}, true).Return([]domain.RecordWithValues{}, nil).Once()
+
+	s.Run("get expanded without refs", func() {
+		actual, err := s.man.GetExpanded(context.Background(), idA, domain.ExpandRequest{})
+		s.Require().NoError(err)
+		s.Equal(idA, actual.ID)
+		s.Nil(actual.Values)
+	})
+	s.Run("get expanded values", func() {
+		actual, err := s.man.GetExpanded(context.Background(), idA, domain.ExpandRequest{Values: true, Depth: 1})
+		s.Require().NoError(err)
+		s.Require().Len(actual.Values, 2)
+		s.Nil(actual.Values[0].Ref)
+	})
+	s.Run("get expanded refs with cycle", func() {
+		actual, err := s.man.GetExpanded(context.Background(), idA, domain.ExpandRequest{Values: true, Refs: true, Depth: 2})
+		s.Require().NoError(err)
+		s.Require().Len(actual.Values, 2)
+		refB := actual.Values[0].Ref
+		s.Require().NotNil(refB)
+		s.Equal(idB, refB.ID)
+		s.Nil(actual.Values[1].Ref)
+		s.Require().Len(refB.Values, 2)
+		s.Same(actual, refB.Values[0].Ref)
+		s.Require().NotNil(refB.Values[1].Ref)
+		s.Equal(idC, refB.Values[1].Ref.ID)
+	})
+	s.Run("get expanded refs without values", func() {
+		actual, err := s.man.GetExpanded(context.Background(), idA, domain.ExpandRequest{Refs: true, Depth: 2})
+		s.Require().NoError(err)
+		refB := actual.Values[0].Ref
+		s.Require().NotNil(refB)
+		s.Require().Len(refB.Values, 2)
+		refC := refB.Values[1].Ref
+		s.Require().NotNil(refC)
+		s.Equal(idC, refC.ID)
+		s.Nil(refC.Values)
+	})
+	s.Run("get expanded error not found", func() {
+		_, err := s.man.GetExpanded(context.Background(), idENF, domain.ExpandRequest{Values: true, Refs: true, Depth: 1})
+		s.ErrorIs(err, domain.ErrRecordNotFound)
+	})
+}
+
 type RecordHandlersTestSuite struct {
 	suite.Suite
 	man  *api.RecordManager
@@ -474,20 +546,27 @@ func (s *RecordHandlersTestSuite) TestAdd() {
 		ReferenceTypeID: uuid.MustParse(validUUID),
 	}
 	mockReqE := domain.AddRecordRequest{Name: "error"}
+	mockReqID := domain.AddRecordRequest{ID: id, Name: "rec"}
+	mockReqEID := domain.AddRecordRequest{ID: uuid.MustParse(validUUID), Name: "rec"}
 	req := handlers.AddRecordRequestSchema{Name: mockReq.Name}
 	reqRT := handlers.AddRecordRequestSchema{
 		Name:            mockReq.Name,
 		ReferenceTypeID: validUUID,
 	}
 	reqE := handlers.AddRecordRequestSchema{Name: mockReqE.Name}
+	reqID := handlers.AddRecordRequestSchema{ID: mockReqID.ID.String(), Name: mockReqID.Name}
+	reqEID := handlers.AddRecordRequestSchema{ID: mockReqEID.ID.String(), Name: mockReqEID.Name}
+	reqEIDParse := handlers.AddRecordRequestSchema{ID: "hello", Name: mockReq.Name}
 	reqERTParse := handlers.AddRecordRequestSchema{
 		Name:            mockReq.Name,
 		ReferenceTypeID: "hello",
 	}
 	s.repo.
-		On("AddRecord", mock.Anything, mockReq).Return(id, nil).
-		On("AddRecord", mock.Anything, mockReqRT).Return(id, nil).
-		On("AddRecord", mock.Anything, mockReqE).Return(uuid.Nil, errors.New("error"))
+		On("AddRecord", mock.Anything, mockReq, nil).Return(id, nil).
+		On("AddRecord", mock.Anything, mockReqRT, nil).Return(id, nil).
+		On("AddRecord", mock.Anything, mockReqE, nil).Return(uuid.Nil, errors.New("error")).
+		On("AddRecord", mock.Anything, mockReqID, nil).Return(id, nil).
+		On("AddRecord", mock.Anything, mockReqEID, nil).Return(uuid.Nil, domain.ErrRecordAlreadyExists)
 
 	type args struct {
 		ctx context.Context
@@ -517,6 +596,27 @@ func (s *RecordHandlersTestSuite) TestAdd() {
 				Status:  http.StatusCreated,
 			},
 		},
+		{
+			name: "add with client ID",
+			args: args{ctx: context.Background(), req: reqID},
+			want: handlers.TextResult{
+				Payload: id.String(),
+				Status:  http.StatusCreated,
+			},
+		},
+		{
+			name:    "add error client ID exists",
+			args:    args{ctx: context.Background(), req: reqEID},
+			want:    handlers.TextResult{Status: http.StatusConflict},
+			wantErr: true,
+			err:     domain.ErrRecordAlreadyExists,
+		},
+		{
+			name:    "add parse client ID error",
+			args:    args{ctx: context.Background(), req: reqEIDParse},
+			want:    handlers.TextResult{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
 		{
 			name:    "add error",
 			args:    args{ctx: context.Background(), req: reqE},
@@ -615,9 +715,9 @@ func (s *RecordHandlersTestSuite) TestUpdate() {
 		DeletionMark: delMark,
 	}
 	s.repo.
-		On("UpdateRecord", mock.Anything, mockReq).Return(rec, nil).
-		On("UpdateRecord", mock.Anything, mockReqE).Return(nil, errors.New("error")).
-		On("UpdateRecord", mock.Anything, mockReqENF).Return(nil, domain.ErrRecordNotFound)
+		On("UpdateRecord", mock.Anything, mockReq, nil).Return(rec, nil).
+		On("UpdateRecord", mock.Anything, mockReqE, nil).Return(nil, errors.New("error")).
+		On("UpdateRecord", mock.Anything, mockReqENF, nil).Return(nil, domain.ErrRecordNotFound)
 
 	type args struct {
 		ctx context.Context
@@ -729,6 +829,11 @@ func (s *RecordHandlersTestSuite) TestPatch() {
 		Description: &descr,
 	}
 	mockReqWoAll := domain.UpdRecordRequest{ID: uuid.MustParse(id)}
+	mockReqESum := domain.UpdRecordRequest{
+		ID:          uuid.MustParse(id),
+		Name:        &name,
+		ExpectedSum: "stale",
+	}
 	mockReqE := domain.UpdRecordRequest{
 		ID:           uuid.MustParse("eeeeeeee-eeee-eeee-eeee-eeeeeeeeeeee"),
 		Name:         &name,
@@ -763,6 +868,11 @@ func (s *RecordHandlersTestSuite) TestPatch() {
 		Description: mockReq.Description,
 	}
 	reqWoAll := handlers.UpdRecordRequestSchema{ID: mockReq.ID.String()}
+	reqESum := handlers.UpdRecordRequestSchema{
+		ID:          mockReqESum.ID.String(),
+		Name:        mockReqESum.Name,
+		ExpectedSum: mockReqESum.ExpectedSum,
+	}
 	reqE := handlers.UpdRecordRequestSchema{
 		ID:           mockReqE.ID.String(),
 		Name:         mockReqE.Name,
@@ -789,13 +899,14 @@ func (s *RecordHandlersTestSuite) TestPatch() {
 	}
 	payload := []byte(fmt.Sprintf(`{"id":"%s","name":"%s","description":"%s","deletion_mark":%v,"reference_type_id":null}`, id, name, descr, delMark))
 	s.repo.
-		On("UpdateRecord", mock.Anything, mockReq).Return(rec, nil).
-		On("UpdateRecord", mock.Anything, mockReqWoN).Return(rec, nil).
-		On("UpdateRecord", mock.Anything, mockReqWoD).Return(rec, nil).
-		On("UpdateRecord", mock.Anything, mockReqWoDM).Return(rec, nil).
-		On("UpdateRecord", mock.Anything, mockReqWoAll).Return(rec, nil).
-		On("UpdateRecord", mock.Anything, mockReqE).Return(nil, errors.New("error")).
-		On("UpdateRecord", mock.Anything, mockReqENF).Return(nil, domain.ErrRecordNotFound)
+		On("UpdateRecord", mock.Anything, mockReq, nil).Return(rec, nil).
+		On("UpdateRecord", mock.Anything, mockReqWoN, nil).Return(rec, nil).
+		On("UpdateRecord", mock.Anything, mockReqWoD, nil).Return(rec, nil).
+		On("UpdateRecord", mock.Anything, mockReqWoDM, nil).Return(rec, nil).
+		On("UpdateRecord", mock.Anything, mockReqWoAll, nil).Return(rec, nil).
+		On("UpdateRecord", mock.Anything, mockReqE, nil).Return(nil, errors.New("error")).
+		On("UpdateRecord", mock.Anything, mockReqENF, nil).Return(nil, domain.ErrRecordNotFound).
+		On("UpdateRecord", mock.Anything, mockReqESum, nil).Return(nil, domain.ErrSumMismatch)
 
 	type args struct {
 		ctx context.Context
@@ -863,6 +974,13 @@ func (s *RecordHandlersTestSuite) TestPatch() {
 			wantErr: true,
 			err:     domain.ErrRecordNotFound,
 		},
+		{
+			name:    "patch error sum mismatch",
+			args:    args{ctx: context.Background(), req: reqESum},
+			want:    handlers.Result{Status: http.StatusPreconditionFailed},
+			wantErr: true,
+			err:     domain.ErrSumMismatch,
+		},
 		{
 			name:    "patch parse JSON error",
 			args:    args{ctx: context.Background(), req: reqEParse},
@@ -907,6 +1025,7 @@ func (s *RecordHandlersTestSuite) TestGet() {
 		Description:     descr,
 		DeletionMark:    delMark,
 		ReferenceTypeID: uuid.MustParse(idRT),
+		Sum:             "sum",
 	}
 	payload := []byte(fmt.Sprintf(`{"id":"%s","name":"%s","description":"%s","deletion_mark":%v,"reference_type_id":null}`, id, name, descr, delMark))
 	payloadRT := []byte(fmt.Sprintf(`{"id":"%s","name":"%s","description":"%s","deletion_mark":%v,"reference_type_id":"%s"}`, idR, name, descr, delMark, idRT))
@@ -944,6 +1063,7 @@ func (s *RecordHandlersTestSuite) TestGet() {
 			want: handlers.Result{
 				Status:  http.StatusOK,
 				Payload: payloadRT,
+				Sum:     "sum",
 			},
 		},
 		{
@@ -992,3 +1112,339 @@ func (s *RecordHandlersTestSuite) TestGet() {
 		})
 	}
 }
+
+func (s *RecordHandlersTestSuite) TestGetReferencedBy() {
+	id := "12345678-1234-1234-1234-123456789012"
+	idR := "11111111-1111-1111-1111-111111111111"
+	idP := "22222222-2222-2222-2222-222222222222"
+	idRT := "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	idE := "eeeeeeee-eeee-eeee-eeee-eeeeeeeeeeee"
+	idENF := "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	req := domain.ReferencedByRequest{RecordID: uuid.MustParse(id), Limit: 100}
+	reqF := domain.ReferencedByRequest{
+		RecordID:   uuid.MustParse(id),
+		PropertyID: uuid.MustParse(idP),
+		RefTypeID:  uuid.MustParse(idRT),
+		Limit:      10,
+		Offset:     20,
+	}
+	reqE := domain.ReferencedByRequest{RecordID: uuid.MustParse(idE), Limit: 100}
+	reqENF := domain.ReferencedByRequest{RecordID: uuid.MustParse(idENF), Limit: 100}
+	refs := []domain.RecordReference{
+		{RecordID: uuid.MustParse(idR), PropertyID: uuid.MustParse(idP), RefTypeID: uuid.MustParse(idRT)},
+		{RecordID: uuid.MustParse(idE), PropertyID: uuid.MustParse(idP)},
+	}
+	payload := []byte(fmt.Sprintf(`{"items":[{"record_id":"%s","property_id":"%s","reference_type_id":"%s"},{"record_id":"%s","property_id":"%s","reference_type_id":null}],"limit":100,"offset":0}`, idR, idP, idRT, idE, idP))
+	payloadF := []byte(`{"items":[],"limit":10,"offset":20}`)
+	s.repo.
+		On("GetReferencedBy", mock.Anything, req).Return(refs, nil).
+		On("GetReferencedBy", mock.Anything, reqF).Return([]domain.RecordReference{}, nil).
+		On("GetReferencedBy", mock.Anything, reqE).Return(nil, errors.New("error")).
+		On("GetReferencedBy", mock.Anything, reqENF).Return(nil, domain.ErrRecordNotFound)
+
+	type args struct {
+		ctx context.Context
+		req handlers.ReferencedByRequestSchema
+	}
+	type testCase struct {
+		name    string
+		args    args
+		want    handlers.Result
+		wantErr bool
+		err     error
+	}
+	cases := []testCase{
+		{
+			name: "get referenced by",
+			args: args{ctx: context.Background(), req: handlers.ReferencedByRequestSchema{ID: id}},
+			want: handlers.Result{Status: http.StatusOK, Payload: payload},
+		},
+		{
+			name: "get referenced by with filters and page",
+			args: args{ctx: context.Background(), req: handlers.ReferencedByRequestSchema{
+				ID:         id,
+				PropertyID: idP,
+				RefTypeID:  idRT,
+				Limit:      "10",
+				Offset:     "20",
+			}},
+			want: handlers.Result{Status: http.StatusOK, Payload: payloadF},
+		},
+		{
+			name:    "get referenced by error",
+			args:    args{ctx: context.Background(), req: handlers.ReferencedByRequestSchema{ID: idE}},
+			want:    handlers.Result{Status: http.StatusInternalServerError},
+			wantErr: true,
+			err:     errors.New("error"),
+		},
+		{
+			name:    "get referenced by error not found",
+			args:    args{ctx: context.Background(), req: handlers.ReferencedByRequestSchema{ID: idENF}},
+			want:    handlers.Result{Status: http.StatusNotFound},
+			wantErr: true,
+			err:     domain.ErrRecordNotFound,
+		},
+		{
+			name:    "get referenced by error parse ID",
+			args:    args{ctx: context.Background(), req: handlers.ReferencedByRequestSchema{ID: "hello"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "get referenced by error parse property ID",
+			args:    args{ctx: context.Background(), req: handlers.ReferencedByRequestSchema{ID: id, PropertyID: "hello"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "get referenced by error parse reference type ID",
+			args:    args{ctx: context.Background(), req: handlers.ReferencedByRequestSchema{ID: id, RefTypeID: "hello"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "get referenced by error zero limit",
+			args:    args{ctx: context.Background(), req: handlers.ReferencedByRequestSchema{ID: id, Limit: "0"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "get referenced by error too big limit",
+			args:    args{ctx: context.Background(), req: handlers.ReferencedByRequestSchema{ID: id, Limit: "1001"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "get referenced by error parse offset",
+			args:    args{ctx: context.Background(), req: handlers.ReferencedByRequestSchema{ID: id, Offset: "-1"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			actual, err := handlers.GetReferencedBy(c.args.ctx, s.man, c.args.req)
+			if c.wantErr {
+				s.Require().Error(err)
+				if c.err != nil {
+					s.Require().EqualError(err, c.err.Error())
+				}
+			} else {
+				s.Require().NoError(err)
+			}
+			s.EqualValues(c.want, actual)
+		})
+	}
+}
+
+func (s *RecordHandlersTestSuite) TestGetGraph() {
+	id := "12345678-1234-1234-1234-123456789012"
+	idR := "11111111-1111-1111-1111-111111111111"
+	idP := "22222222-2222-2222-2222-222222222222"
+	idRT := "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	idE := "eeeeeeee-eeee-eeee-eeee-eeeeeeeeeeee"
+	idENF := "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	req := domain.GraphRequest{RecordID: uuid.MustParse(id), MaxDepth: 3}
+	reqF := domain.GraphRequest{
+		RecordID:    uuid.MustParse(id),
+		PropertyIDs: []uuid.UUID{uuid.MustParse(idP)},
+		MaxDepth:    5,
+	}
+	reqE := domain.GraphRequest{RecordID: uuid.MustParse(idE), MaxDepth: 3}
+	reqENF := domain.GraphRequest{RecordID: uuid.MustParse(idENF), MaxDepth: 3}
+	graph := &domain.Graph{
+		Nodes: []domain.GraphNode{
+			{RecordID: uuid.MustParse(id), RefTypeID: uuid.MustParse(idRT)},
+			{RecordID: uuid.MustParse(idR), Depth: 1},
+		},
+		Edges: []domain.GraphEdge{
+			{From: uuid.MustParse(id), To: uuid.MustParse(idR), PropertyID: uuid.MustParse(idP)},
+			{From: uuid.MustParse(idR), To: uuid.MustParse(id), PropertyID: uuid.MustParse(idP), Cycle: true},
+		},
+	}
+	graphF := &domain.Graph{
+		Nodes:     []domain.GraphNode{{RecordID: uuid.MustParse(id)}},
+		Truncated: true,
+	}
+	payload := []byte(fmt.Sprintf(`{"nodes":[{"record_id":"%s","reference_type_id":"%s","depth":0},{"record_id":"%s","reference_type_id":null,"depth":1}],"edges":[{"from":"%s","to":"%s","property_id":"%s","cycle":false},{"from":"%s","to":"%s","property_id":"%s","cycle":true}],"truncated":false}`, id, idRT, idR, id, idR, idP, idR, id, idP))
+	payloadF := []byte(fmt.Sprintf(`{"nodes":[{"record_id":"%s","reference_type_id":null,"depth":0}],"edges":[],"truncated":true}`, id))
+	s.repo.
+		On("GetRecordGraph", mock.Anything, req).Return(graph, nil).
+		On("GetRecordGraph", mock.Anything, reqF).Return(graphF, nil).
+		On("GetRecordGraph", mock.Anything, reqE).Return(nil, errors.New("error")).
+		On("GetRecordGraph", mock.Anything, reqENF).Return(nil, domain.ErrRecordNotFound)
+
+	type args struct {
+		ctx context.Context
+		req handlers.GraphRequestSchema
+	}
+	type testCase struct {
+		name    string
+		args    args
+		want    handlers.Result
+		wantErr bool
+		err     error
+	}
+	cases := []testCase{
+		{
+			name: "get graph",
+			args: args{ctx: context.Background(), req: handlers.GraphRequestSchema{ID: id}},
+			want: handlers.Result{Status: http.StatusOK, Payload: payload},
+		},
+		{
+			name: "get graph by properties truncated",
+			args: args{ctx: context.Background(), req: handlers.GraphRequestSchema{ID: id, PropertyIDs: []string{idP}, Depth: "5"}},
+			want: handlers.Result{Status: http.StatusOK, Payload: payloadF},
+		},
+		{
+			name:    "get graph error",
+			args:    args{ctx: context.Background(), req: handlers.GraphRequestSchema{ID: idE}},
+			want:    handlers.Result{Status: http.StatusInternalServerError},
+			wantErr: true,
+			err:     errors.New("error"),
+		},
+		{
+			name:    "get graph error not found",
+			args:    args{ctx: context.Background(), req: handlers.GraphRequestSchema{ID: idENF}},
+			want:    handlers.Result{Status: http.StatusNotFound},
+			wantErr: true,
+			err:     domain.ErrRecordNotFound,
+		},
+		{
+			name:    "get graph error parse ID",
+			args:    args{ctx: context.Background(), req: handlers.GraphRequestSchema{ID: "hello"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "get graph error parse property ID",
+			args:    args{ctx: context.Background(), req: handlers.GraphRequestSchema{ID: id, PropertyIDs: []string{idP, "hello"}}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "get graph error zero depth",
+			args:    args{ctx: context.Background(), req: handlers.GraphRequestSchema{ID: id, Depth: "0"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "get graph error too big depth",
+			args:    args{ctx: context.Background(), req: handlers.GraphRequestSchema{ID: id, Depth: "17"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			actual, err := handlers.GetRecordGraph(c.args.ctx, s.man, c.args.req)
+			if c.wantErr {
+				s.Require().Error(err)
+				if c.err != nil {
+					s.Require().EqualError(err, c.err.Error())
+				}
+			} else {
+				s.Require().NoError(err)
+			}
+			s.EqualValues(c.want, actual)
+		})
+	}
+}
+
+func (s *RecordHandlersTestSuite) TestGetExpanded() {
+	id := "11111111-1111-1111-1111-111111111111"
+	idB := "22222222-2222-2222-2222-222222222222"
+	idP := "44444444-4444-4444-4444-444444444444"
+	idPN := "55555555-5555-5555-5555-555555555555"
+	idRT := "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	idE := "eeeeeeee-eeee-eeee-eeee-eeeeeeeeeeee"
+	idENF := "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	rec := domain.RecordWithValues{
+		Record: domain.Record{ID: uuid.MustParse(id), Name: "A"},
+		Values: []domain.Value{
+			{
+				RecordID:   uuid.MustParse(id),
+				PropertyID: uuid.MustParse(idP),
+				Type:       domain.TypeReference,
+				RefTypeID:  uuid.MustParse(idRT),
+				Value:      uuid.MustParse(idB),
+			},
+			{RecordID: uuid.MustParse(id), PropertyID: uuid.MustParse(idPN), Type: domain.TypeNumber, Value: 7},
+		},
+	}
+	recB := domain.RecordWithValues{
+		Record: domain.Record{ID: uuid.MustParse(idB), Name: "B", ReferenceTypeID: uuid.MustParse(idRT)},
+	}
+	payload := []byte(fmt.Sprintf(`{"id":"%s","name":"A","description":"","deletion_mark":false,"reference_type_id":null,"values":[{"record_id":"%s","property_id":"%s","type":"ref","reference_type_id":"%s","value":"%s","ref":{"id":"%s","name":"B","description":"","deletion_mark":false,"reference_type_id":"%s"}},{"record_id":"%s","property_id":"%s","type":"number","reference_type_id":null,"value":7}]}`, id, id, idP, idRT, idB, idB, idRT, id, idPN))
+	s.repo.
+		On("GetRecords", mock.Anything, []uuid.UUID{uuid.MustParse(id)}, true).Return([]domain.RecordWithValues{rec}, nil).
+		On("GetRecords", mock.Anything, []uuid.UUID{uuid.MustParse(idB)}, false).Return([]domain.RecordWithValues{recB}, nil).
+		On("GetRecords", mock.Anything, []uuid.UUID{uuid.MustParse(idE)}, true).Return(nil, errors.New("error")).
+		On("GetRecords", mock.Anything, []uuid.UUID{uuid.MustParse(idENF)}, true).Return([]domain.RecordWithValues{}, nil)
+
+	type args struct {
+		ctx    context.Context
+		id     string
+		expand handlers.ExpandRequestSchema
+	}
+	type testCase struct {
+		name    string
+		args    args
+		want    handlers.Result
+		wantErr bool
+		err     error
+	}
+	cases := []testCase{
+		{
+			name: "get expanded",
+			args: args{ctx: context.Background(), id: id, expand: handlers.ExpandRequestSchema{Expand: "refs"}},
+			want: handlers.Result{Status: http.StatusOK, Payload: payload},
+		},
+		{
+			name:    "get expanded error",
+			args:    args{ctx: context.Background(), id: idE, expand: handlers.ExpandRequestSchema{Expand: "values"}},
+			want:    handlers.Result{Status: http.StatusInternalServerError},
+			wantErr: true,
+			err:     errors.New("error"),
+		},
+		{
+			name:    "get expanded error not found",
+			args:    args{ctx: context.Background(), id: idENF, expand: handlers.ExpandRequestSchema{Expand: "values"}},
+			want:    handlers.Result{Status: http.StatusNotFound},
+			wantErr: true,
+			err:     domain.ErrRecordNotFound,
+		},
+		{
+			name:    "get expanded error parse ID",
+			args:    args{ctx: context.Background(), id: "hello", expand: handlers.ExpandRequestSchema{Expand: "values"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "get expanded error unknown expand",
+			args:    args{ctx: context.Background(), id: id, expand: handlers.ExpandRequestSchema{Expand: "values,hello"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "get expanded error too big depth",
+			args:    args{ctx: context.Background(), id: id, expand: handlers.ExpandRequestSchema{Expand: "refs", Depth: "4"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			actual, err := handlers.GetExpandedRecord(c.args.ctx, s.man, c.args.id, c.args.expand)
+			if c.wantErr {
+				s.Require().Error(err)
+				if c.err != nil {
+					s.Require().EqualError(err, c.err.Error())
+				}
+			} else {
+				s.Require().NoError(err)
+			}
+			s.EqualValues(c.want, actual)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/test/mocks"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type TableHandlersTestSuite struct {
+	suite.Suite
+	man  *api.TableManager
+	repo *mocks.TableRepository
+}
+
+func TestTableHandlers(t *testing.T) {
+	suite.Run(t, new(TableHandlersTestSuite))
+}
+
+func (s *TableHandlersTestSuite) SetupTest() {
+	s.man, s.repo = newTestTableMockedManager(s.T())
+}
+
+var (
+	tableRefTypeID = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	tablePriceID   = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	tableMakerID   = uuid.MustParse("33333333-3333-3333-3333-333333333333")
+	tableDateID    = uuid.MustParse("44444444-4444-4444-4444-444444444444")
+	tableRecordID  = uuid.MustParse("55555555-5555-5555-5555-555555555555")
+	tableMakerRID  = uuid.MustParse("66666666-6666-6666-6666-666666666666")
+)
+
+func (s *TableHandlersTestSuite) expectExport(req domain.TableRequest) {
+	s.repo.
+		On("ExportTable", mock.Anything, req, mock.Anything).
+		Run(func(args mock.Arguments) {
+			w := args.Get(2).(domain.TableWriter)
+			s.Require().NoError(w.WriteHeader([]domain.Property{
+				{ID: tableDateID, Name: "Delivery"},
+				{ID: tableMakerID, Name: "Maker"},
+				{ID: tablePriceID, Name: "Price"},
+			}))
+			s.Require().NoError(w.WriteRow(domain.TableRow{
+				Record: domain.Record{ID: tableRecordID, Name: "chair, wooden"},
+				Values: []domain.TableValue{
+					{Value: domain.Value{PropertyID: tablePriceID, Type: domain.TypeNumber, Value: 1250.5}},
+					{Value: domain.Value{PropertyID: tableMakerID, Type: domain.TypeReference, Value: tableMakerRID}, RefName: "Acme"},
+				},
+			}))
+			s.Require().NoError(w.WriteRow(domain.TableRow{
+				Record: domain.Record{ID: tableMakerRID, Name: "stool", DeletionMark: true},
+				Values: []domain.TableValue{
+					{Value: domain.Value{PropertyID: tableDateID, Type: domain.TypeDate, Value: time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)}},
+					{Value: domain.Value{PropertyID: tableMakerID, Type: domain.TypeReference, Value: tableRecordID}},
+				},
+			}))
+		}).
+		Return(nil).Once()
+}
+
+func (s *TableHandlersTestSuite) TestExportTableCSV() {
+	deletionMark := false
+	s.expectExport(domain.TableRequest{
+		RefTypeID: tableRefTypeID,
+		Filter:    domain.RecordFilter{DeletionMark: &deletionMark, Name: "ch"},
+	})
+	expected := "ID,Name,Description,Deletion mark,Delivery,Maker,Price\n" +
+		tableRecordID.String() + ",\"chair, wooden\",,false,,Acme,1250.5\n" +
+		tableMakerRID.String() + ",stool,,true,2023-07-31T00:00:00Z," + tableRecordID.String() + ",\n"
+
+	var buf bytes.Buffer
+	req := handlers.TableRequestSchema{RefTypeID: tableRefTypeID.String(), DeletionMark: "false", Name: "ch"}
+	actual, err := handlers.ExportTable(context.Background(), s.man, req, &buf)
+	s.Require().NoError(err)
+	s.Equal(handlers.Result{Status: http.StatusOK}, actual)
+	s.Equal(expected, buf.String())
+	s.Equal("text/csv; charset=UTF-8", req.ContentType())
+}
+
+func (s *TableHandlersTestSuite) TestExportTableXLSX() {
+	s.expectExport(domain.TableRequest{RefTypeID: tableRefTypeID})
+
+	var buf bytes.Buffer
+	req := handlers.TableRequestSchema{RefTypeID: tableRefTypeID.String(), Format: handlers.TableFormatXLSX}
+	actual, err := handlers.ExportTable(context.Background(), s.man, req, &buf)
+	s.Require().NoError(err)
+	s.Equal(handlers.Result{Status: http.StatusOK}, actual)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	s.Require().NoError(err)
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		s.Require().NoError(err)
+		b, err := io.ReadAll(r)
+		s.Require().NoError(err)
+		files[f.Name] = string(b)
+	}
+	s.Contains(files, "[Content_Types].xml")
+	s.Contains(files, "xl/workbook.xml")
+	s.Contains(files["xl/worksheets/sheet1.xml"],
+		`<row r="2"><c r="A2" t="inlineStr"><is><t xml:space="preserve">`+tableRecordID.String()+`</t></is></c>`+
+			`<c r="B2" t="inlineStr"><is><t xml:space="preserve">chair, wooden</t></is></c>`+
+			`<c r="C2" t="inlineStr"><is><t xml:space="preserve"></t></is></c>`+
+			`<c r="D2" t="b"><v>0</v></c>`+
+			`<c r="F2" t="inlineStr"><is><t xml:space="preserve">Acme</t></is></c>`+
+			`<c r="G2"><v>1250.5</v></c></row>`)
+}
+
+func (s *TableHandlersTestSuite) TestExportTableErrors() {
+	type testCase struct {
+		name     string
+		req      handlers.TableRequestSchema
+		repoErr  error
+		expected handlers.Result
+	}
+	cases := []testCase{
+		{
+			name:     "wrong reference type id",
+			req:      handlers.TableRequestSchema{RefTypeID: "1"},
+			expected: handlers.Result{Status: http.StatusBadRequest},
+		},
+		{
+			name:     "unknown format",
+			req:      handlers.TableRequestSchema{RefTypeID: tableRefTypeID.String(), Format: "pdf"},
+			expected: handlers.Result{Status: http.StatusBadRequest},
+		},
+		{
+			name:     "wrong deletion mark",
+			req:      handlers.TableRequestSchema{RefTypeID: tableRefTypeID.String(), DeletionMark: "maybe"},
+			expected: handlers.Result{Status: http.StatusBadRequest},
+		},
+		{
+			name:     "reference type not found",
+			req:      handlers.TableRequestSchema{RefTypeID: tableRefTypeID.String(), Format: handlers.TableFormatXLSX},
+			repoErr:  domain.ErrRefTypeNotFound,
+			expected: handlers.Result{Status: http.StatusNotFound},
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			if c.repoErr != nil {
+				call := s.repo.On("ExportTable", mock.Anything, mock.Anything, mock.Anything).Return(c.repoErr).Once()
+				defer call.Unset()
+			}
+			var buf bytes.Buffer
+			actual, err := handlers.ExportTable(context.Background(), s.man, c.req, &buf)
+			s.Error(err)
+			s.Equal(c.expected, actual)
+			s.Zero(buf.Len())
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pkgpg "datatom/pkg/db/pg"
+	pkgrmq "datatom/pkg/message_broker/rmq"
+	"datatom/pkg/tracing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/suite"
+	rmq "github.com/wagslane/go-rabbitmq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testTraceParent = "00-" + testTraceID + "-00f067aa0ba902b7-01"
+)
+
+type TracingTestSuite struct {
+	suite.Suite
+	recorder *tracetest.SpanRecorder
+	provider trace.TracerProvider
+}
+
+func TestTracing(t *testing.T) {
+	suite.Run(t, new(TracingTestSuite))
+}
+
+func (s *TracingTestSuite) SetupSuite() {
+	_, err := tracing.Init(context.Background(), tracing.Config{})
+	s.Require().NoError(err)
+	s.provider = otel.GetTracerProvider()
+}
+
+func (s *TracingTestSuite) SetupTest() {
+	s.recorder = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(s.recorder)))
+}
+
+func (s *TracingTestSuite) TearDownSuite() {
+	otel.SetTracerProvider(s.provider)
+}
+
+func (s *TracingTestSuite) span(name string) sdktrace.ReadOnlySpan {
+	for _, span := range s.recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	s.FailNow("span is not ended", name)
+	return nil
+}
+
+func (s *TracingTestSuite) TestExporterFromCode() {
+	for _, e := range []tracing.Exporter{tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout} {
+		got, err := tracing.ExporterFromCode(e.Code())
+		s.Require().NoError(err)
+		s.Equal(e, got)
+	}
+	got, err := tracing.ExporterFromCode("")
+	s.NoError(err)
+	s.Equal(tracing.ExporterNone, got)
+	_, err = tracing.ExporterFromCode("jaeger")
+	s.Error(err)
+}
+
+func (s *TracingTestSuite) TestTraceParent() {
+	s.Empty(tracing.TraceParent(context.Background()))
+	ctx := tracing.ContextWithTraceParent(context.Background(), testTraceParent)
+	s.Equal(testTraceParent, tracing.TraceParent(ctx))
+	s.Equal(context.Background(), tracing.ContextWithTraceParent(context.Background(), ""))
+}
+
+func (s *TracingTestSuite) TestHTTP() {
+	srv, _ := newTestServer(s.T())
+	r, ok := srv.(interface{ Routes() chi.Routes })
+	s.Require().True(ok)
+	handler, ok := r.Routes().(http.Handler)
+	s.Require().True(ok)
+	req := httptest.NewRequest(http.MethodGet, "/v1/health/ping", nil)
+	req.Header.Set("traceparent", testTraceParent)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+
+	span := s.span("GET /v1/health/ping")
+	s.Equal(trace.SpanKindServer, span.SpanKind())
+	s.Equal(testTraceID, span.SpanContext().TraceID().String())
+	s.True(span.Parent().IsRemote())
+}
+
+func (s *TracingTestSuite) TestQuery() {
+	parentCtx, parent := tracing.Tracer().Start(context.Background(), "parent")
+	tracer := pkgpg.QueryTracer{}
+	ctx := tracer.TraceQueryStart(parentCtx, nil, pgx.TraceQueryStartData{SQL: `SELECT * FROM get_value($1, $2);`})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("connection lost")})
+
+	span := s.span("postgres get_value")
+	s.Equal(parent.SpanContext().SpanID(), span.Parent().SpanID())
+	s.Equal(codes.Error, span.Status().Code)
+
+	ctx = tracer.TraceQueryStart(parentCtx, nil, pgx.TraceQueryStartData{SQL: `LISTEN changes;`})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	s.span("postgres LISTEN")
+	// Queries do not end spans of callers
+	s.True(parent.IsRecording())
+	parent.End()
+}
+
+func (s *TracingTestSuite) TestConsume() {
+	d := rmq.Delivery{Delivery: amqp.Delivery{
+		Headers:    amqp.Table{"traceparent": testTraceParent},
+		Type:       "value",
+		MessageId:  "m1",
+		RoutingKey: "tom",
+	}}
+	ctx, span := pkgrmq.StartConsumeSpan(d)
+	span.End()
+	s.Equal(span.SpanContext(), trace.SpanContextFromContext(ctx))
+
+	got := s.span("value process")
+	s.Equal(trace.SpanKindConsumer, got.SpanKind())
+	s.Equal(testTraceID, got.SpanContext().TraceID().String())
+	s.Equal("00f067aa0ba902b7", got.Parent().SpanID().String())
+}
@@ -350,8 +350,8 @@ func (s *ValueManagerTestSuite) TestSet() {
 	reqErr := domain.SetValueRequest{RecordID: rID, PropertyID: pID, Type: domain.TypeBool, Value: true}
 	resp := &domain.Value{RecordID: rID, PropertyID: pID}
 	s.repo.
-		On("SetValue", mock.Anything, req).Return(resp, nil).
-		On("SetValue", mock.Anything, reqErr).Return(nil, domain.ErrUnexpectedTypePG)
+		On("SetValue", mock.Anything, req, nil).Return(resp, nil).
+		On("SetValue", mock.Anything, reqErr, nil).Return(nil, domain.ErrUnexpectedTypePG)
 
 	type args struct {
 		ctx context.Context
@@ -784,6 +784,26 @@ func (s *ValueHandlersTestSuite) TestSet() {
 		Type:       mockReqEPG.Type.String(),
 		Value:      mockReqEPG.Value,
 	}
+	mockReqETr := domain.SetValueRequest{
+		RecordID:   rUUID,
+		PropertyID: uuid.MustParse("44444444-4444-4444-4444-444444444444"),
+		Type:       domain.TypeText,
+		Value:      "archived",
+	}
+	reqETr := handlers.SetValueRequestSchema{
+		RecordID:   mockReqETr.RecordID.String(),
+		PropertyID: mockReqETr.PropertyID.String(),
+		Type:       mockReqETr.Type.String(),
+		Value:      mockReqETr.Value,
+	}
+	mockReqSum := mockReq
+	mockReqSum.ExpectedSum = "sum"
+	reqSum := req
+	reqSum.ExpectedSum = mockReqSum.ExpectedSum
+	mockReqESum := mockReq
+	mockReqESum.ExpectedSum = "stale"
+	reqESum := req
+	reqESum.ExpectedSum = mockReqESum.ExpectedSum
 	reqERParse := handlers.SetValueRequestSchema{
 		RecordID:   "hello",
 		PropertyID: mockReq.PropertyID.String(),
@@ -822,11 +842,21 @@ func (s *ValueHandlersTestSuite) TestSet() {
 		RefTypeID:  rtUUID,
 		Value:      7,
 	}
+	valSum := &domain.Value{
+		RecordID:   rUUID,
+		PropertyID: pUUID,
+		Type:       domain.TypeNumber,
+		Value:      7,
+		Sum:        "new",
+	}
 	s.repo.
-		On("SetValue", mock.Anything, mockReq).Return(val, nil).
-		On("SetValue", mock.Anything, mockReqRT).Return(valRT, nil).
-		On("SetValue", mock.Anything, mockReqE).Return(nil, errors.New("error")).
-		On("SetValue", mock.Anything, mockReqEPG).Return(nil, domain.ErrUnexpectedTypePG)
+		On("SetValue", mock.Anything, mockReq, nil).Return(val, nil).
+		On("SetValue", mock.Anything, mockReqSum, nil).Return(valSum, nil).
+		On("SetValue", mock.Anything, mockReqESum, nil).Return(nil, domain.ErrSumMismatch).
+		On("SetValue", mock.Anything, mockReqRT, nil).Return(valRT, nil).
+		On("SetValue", mock.Anything, mockReqE, nil).Return(nil, errors.New("error")).
+		On("SetValue", mock.Anything, mockReqEPG, nil).Return(nil, domain.ErrUnexpectedTypePG).
+		On("SetValue", mock.Anything, mockReqETr, nil).Return(nil, domain.ErrStateTransitionNotAllowedPG)
 
 	type args struct {
 		ctx context.Context
@@ -850,6 +880,18 @@ func (s *ValueHandlersTestSuite) TestSet() {
 			args: args{ctx: context.Background(), req: reqRT},
 			want: handlers.Result{Status: http.StatusNoContent},
 		},
+		{
+			name: "set with expected sum",
+			args: args{ctx: context.Background(), req: reqSum},
+			want: handlers.Result{Status: http.StatusNoContent, Sum: "new"},
+		},
+		{
+			name:    "set sum mismatch error",
+			args:    args{ctx: context.Background(), req: reqESum},
+			want:    handlers.Result{Status: http.StatusPreconditionFailed},
+			wantErr: true,
+			err:     domain.ErrSumMismatch,
+		},
 		{
 			name:    "set error",
 			args:    args{ctx: context.Background(), req: reqE},
@@ -864,6 +906,13 @@ func (s *ValueHandlersTestSuite) TestSet() {
 			wantErr: true,
 			err:     domain.ErrUnexpectedTypePG,
 		},
+		{
+			name:    "set state transition not allowed error",
+			args:    args{ctx: context.Background(), req: reqETr},
+			want:    handlers.Result{Status: http.StatusConflict},
+			wantErr: true,
+			err:     domain.ErrStateTransitionNotAllowedPG,
+		},
 		{
 			name:    "set parse record ID error",
 			args:    args{ctx: context.Background(), req: reqERParse},
@@ -904,3 +953,218 @@ func (s *ValueHandlersTestSuite) TestSet() {
 		})
 	}
 }
+
+func (s *ValueHandlersTestSuite) TestGetStateTransitions() {
+	rID := "11111111-1111-1111-1111-111111111111"
+	pID := "22222222-2222-2222-2222-222222222222"
+	pIDn := "33333333-3333-3333-3333-333333333333"
+	pIDr := "44444444-4444-4444-4444-444444444444"
+	pIDe := "eeeeeeee-eeee-eeee-eeee-eeeeeeeeeeee"
+	pIDnf := "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	rUUID := uuid.MustParse(rID)
+	state := "draft"
+	req := domain.GetValueRequest{RecordID: rUUID, PropertyID: uuid.MustParse(pID)}
+	reqN := domain.GetValueRequest{RecordID: rUUID, PropertyID: uuid.MustParse(pIDn)}
+	reqR := domain.GetValueRequest{RecordID: rUUID, PropertyID: uuid.MustParse(pIDr)}
+	reqE := domain.GetValueRequest{RecordID: rUUID, PropertyID: uuid.MustParse(pIDe)}
+	reqENF := domain.GetValueRequest{RecordID: rUUID, PropertyID: uuid.MustParse(pIDnf)}
+	transitions := &domain.StateTransitions{
+		RecordID:    req.RecordID,
+		PropertyID:  req.PropertyID,
+		State:       &state,
+		Transitions: []string{"approved", "rejected"},
+	}
+	transitionsN := &domain.StateTransitions{
+		RecordID:    reqN.RecordID,
+		PropertyID:  reqN.PropertyID,
+		Transitions: []string{"draft"},
+	}
+	payload := []byte(fmt.Sprintf(`{"record_id":"%s","property_id":"%s","state":"draft","transitions":["approved","rejected"]}`, rID, pID))
+	payloadN := []byte(fmt.Sprintf(`{"record_id":"%s","property_id":"%s","state":null,"transitions":["draft"]}`, rID, pIDn))
+	s.repo.
+		On("GetStateTransitions", mock.Anything, req).Return(transitions, nil).
+		On("GetStateTransitions", mock.Anything, reqN).Return(transitionsN, nil).
+		On("GetStateTransitions", mock.Anything, reqR).Return(nil, domain.ErrNotStateMachinePG).
+		On("GetStateTransitions", mock.Anything, reqE).Return(nil, errors.New("error")).
+		On("GetStateTransitions", mock.Anything, reqENF).Return(nil, fmt.Errorf("record or property %w", domain.ErrNotFound))
+
+	type args struct {
+		ctx context.Context
+		req handlers.GetValueRequestSchema
+	}
+	type testCase struct {
+		name    string
+		args    args
+		want    handlers.Result
+		wantErr bool
+		err     error
+	}
+	cases := []testCase{
+		{
+			name: "get transitions",
+			args: args{ctx: context.Background(), req: handlers.GetValueRequestSchema{RecordID: rID, PropertyID: pID}},
+			want: handlers.Result{Status: http.StatusOK, Payload: payload},
+		},
+		{
+			name: "get transitions without value",
+			args: args{ctx: context.Background(), req: handlers.GetValueRequestSchema{RecordID: rID, PropertyID: pIDn}},
+			want: handlers.Result{Status: http.StatusOK, Payload: payloadN},
+		},
+		{
+			name:    "get transitions of regular property error",
+			args:    args{ctx: context.Background(), req: handlers.GetValueRequestSchema{RecordID: rID, PropertyID: pIDr}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+			err:     domain.ErrNotStateMachinePG,
+		},
+		{
+			name:    "get transitions error",
+			args:    args{ctx: context.Background(), req: handlers.GetValueRequestSchema{RecordID: rID, PropertyID: pIDe}},
+			want:    handlers.Result{Status: http.StatusInternalServerError},
+			wantErr: true,
+			err:     errors.New("error"),
+		},
+		{
+			name:    "get transitions error not found",
+			args:    args{ctx: context.Background(), req: handlers.GetValueRequestSchema{RecordID: rID, PropertyID: pIDnf}},
+			want:    handlers.Result{Status: http.StatusNotFound},
+			wantErr: true,
+			err:     fmt.Errorf("record or property %w", domain.ErrNotFound),
+		},
+		{
+			name:    "get transitions parse record ID error",
+			args:    args{ctx: context.Background(), req: handlers.GetValueRequestSchema{RecordID: "hello", PropertyID: pID}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name:    "get transitions parse property ID error",
+			args:    args{ctx: context.Background(), req: handlers.GetValueRequestSchema{RecordID: rID, PropertyID: "hello"}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			actual, err := handlers.GetStateTransitions(c.args.ctx, s.man, c.args.req)
+			if c.wantErr {
+				s.Require().Error(err)
+				if c.err != nil {
+					s.Require().EqualError(err, c.err.Error())
+				}
+			} else {
+				s.Require().NoError(err)
+			}
+			s.EqualValues(c.want, actual)
+		})
+	}
+}
+
+func (s *ValueHandlersTestSuite) TestGet() {
+	rID := "11111111-1111-1111-1111-111111111111"
+	pID := "22222222-2222-2222-2222-222222222222"
+	pIDN := "33333333-3333-3333-3333-333333333333"
+	pIDnf := "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	refID := "44444444-4444-4444-4444-444444444444"
+	rtID := "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	rman, rrepo, _ := newTestRecordMockedManager(s.T())
+	req := domain.GetValueRequest{RecordID: uuid.MustParse(rID), PropertyID: uuid.MustParse(pID)}
+	reqN := domain.GetValueRequest{RecordID: uuid.MustParse(rID), PropertyID: uuid.MustParse(pIDN)}
+	reqENF := domain.GetValueRequest{RecordID: uuid.MustParse(rID), PropertyID: uuid.MustParse(pIDnf)}
+	val := &domain.Value{
+		RecordID:   uuid.MustParse(rID),
+		PropertyID: uuid.MustParse(pID),
+		Type:       domain.TypeReference,
+		RefTypeID:  uuid.MustParse(rtID),
+		Value:      uuid.MustParse(refID),
+	}
+	valN := &domain.Value{RecordID: uuid.MustParse(rID), PropertyID: uuid.MustParse(pIDN), Type: domain.TypeNumber, Value: 7}
+	ref := domain.RecordWithValues{
+		Record: domain.Record{ID: uuid.MustParse(refID), Name: "ref", ReferenceTypeID: uuid.MustParse(rtID)},
+		Values: []domain.Value{},
+	}
+	payload := []byte(fmt.Sprintf(`{"record_id":"%s","property_id":"%s","type":"ref","reference_type_id":"%s","value":"%s"}`, rID, pID, rtID, refID))
+	payloadE := []byte(fmt.Sprintf(`{"record_id":"%s","property_id":"%s","type":"ref","reference_type_id":"%s","value":"%s","ref":{"id":"%s","name":"ref","description":"","deletion_mark":false,"reference_type_id":"%s"}}`, rID, pID, rtID, refID, refID, rtID))
+	payloadN := []byte(fmt.Sprintf(`{"record_id":"%s","property_id":"%s","type":"number","reference_type_id":null,"value":7}`, rID, pIDN))
+	s.repo.
+		On("GetValue", mock.Anything, req).Return(val, nil).
+		On("GetValue", mock.Anything, reqN).Return(valN, nil).
+		On("GetValue", mock.Anything, reqENF).Return(nil, domain.ErrValueNotFound)
+	rrepo.
+		On("GetRecords", mock.Anything, []uuid.UUID{uuid.MustParse(refID)}, true).Return([]domain.RecordWithValues{ref}, nil)
+
+	type args struct {
+		ctx    context.Context
+		req    handlers.GetValueRequestSchema
+		expand handlers.ExpandRequestSchema
+	}
+	type testCase struct {
+		name    string
+		args    args
+		want    handlers.Result
+		wantErr bool
+		err     error
+	}
+	cases := []testCase{
+		{
+			name: "get",
+			args: args{ctx: context.Background(), req: handlers.GetValueRequestSchema{RecordID: rID, PropertyID: pID}},
+			want: handlers.Result{Status: http.StatusOK, Payload: payload},
+		},
+		{
+			name: "get expanded",
+			args: args{
+				ctx:    context.Background(),
+				req:    handlers.GetValueRequestSchema{RecordID: rID, PropertyID: pID},
+				expand: handlers.ExpandRequestSchema{Expand: "refs,values"},
+			},
+			want: handlers.Result{Status: http.StatusOK, Payload: payloadE},
+		},
+		{
+			name: "get expanded not ref",
+			args: args{
+				ctx:    context.Background(),
+				req:    handlers.GetValueRequestSchema{RecordID: rID, PropertyID: pIDN},
+				expand: handlers.ExpandRequestSchema{Expand: "refs"},
+			},
+			want: handlers.Result{Status: http.StatusOK, Payload: payloadN},
+		},
+		{
+			name:    "get error not found",
+			args:    args{ctx: context.Background(), req: handlers.GetValueRequestSchema{RecordID: rID, PropertyID: pIDnf}},
+			want:    handlers.Result{Status: http.StatusNotFound},
+			wantErr: true,
+			err:     domain.ErrValueNotFound,
+		},
+		{
+			name:    "get parse record ID error",
+			args:    args{ctx: context.Background(), req: handlers.GetValueRequestSchema{RecordID: "hello", PropertyID: pID}},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+		{
+			name: "get unknown expand error",
+			args: args{
+				ctx:    context.Background(),
+				req:    handlers.GetValueRequestSchema{RecordID: rID, PropertyID: pID},
+				expand: handlers.ExpandRequestSchema{Expand: "hello"},
+			},
+			want:    handlers.Result{Status: http.StatusBadRequest},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			actual, err := handlers.GetValue(c.args.ctx, s.man, rman, c.args.req, c.args.expand)
+			if c.wantErr {
+				s.Require().Error(err)
+				if c.err != nil {
+					s.Require().EqualError(err, c.err.Error())
+				}
+			} else {
+				s.Require().NoError(err)
+			}
+			s.EqualValues(c.want, actual)
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"datatom/internal/api"
+	"datatom/internal/domain"
+	"datatom/internal/handlers"
+	"datatom/test/mocks"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type WebhookTestSuite struct {
+	suite.Suite
+	man           *api.WebhookManager
+	repo          *mocks.WebhookRepository
+	changeLogRepo *mocks.ChangeLogRepository
+	partner       *testWebhookPartner
+}
+
+func TestWebhook(t *testing.T) {
+	suite.Run(t, new(WebhookTestSuite))
+}
+
+// testWebhookPartner is the local stand-in of the partner receiving webhooks.
+type testWebhookPartner struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []testWebhookRequest
+}
+
+type testWebhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newTestWebhookPartner() *testWebhookPartner {
+	out := &testWebhookPartner{status: http.StatusOK}
+	out.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		out.mu.Lock()
+		defer out.mu.Unlock()
+		out.requests = append(out.requests, testWebhookRequest{header: req.Header.Clone(), body: b})
+		w.WriteHeader(out.status)
+	}))
+	return out
+}
+
+func (s *WebhookTestSuite) SetupTest() {
+	var changeLogMan *api.ChangeLogManager
+	changeLogMan, s.changeLogRepo = newTestChangeLogMockedManager(s.T())
+	s.man, s.repo = newTestWebhookMockedManager(s.T(), changeLogMan)
+	s.partner = newTestWebhookPartner()
+}
+
+func (s *WebhookTestSuite) TearDownTest() {
+	s.partner.Close()
+}
+
+func (s *WebhookTestSuite) webhook(state domain.WebhookState) domain.Webhook {
+	return domain.Webhook{
+		ID:           uuid.New(),
+		URL:          s.partner.URL + "/hooks",
+		Secret:       "0123456789abcdef",
+		Filter:       domain.ChangeFilter{ChangeTypes: []domain.ChangedDataType{domain.ChangedDataValue}},
+		Enabled:      true,
+		WebhookState: state,
+	}
+}
+
+func (s *WebhookTestSuite) TestDeliver() {
+	wh := s.webhook(domain.WebhookState{LastChangeID: 3})
+	recordID := uuid.New()
+	s.repo.On("GetDueWebhooks", mock.Anything).Return([]domain.Webhook{wh}, nil).Once()
+	s.changeLogRepo.
+		On("GetLastChangeID", mock.Anything).Return(int64(10), nil).Once().
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{AfterID: 3, Filter: wh.Filter, Limit: 100}).
+		Return([]domain.Change{
+			{ID: 5, DataType: domain.ChangedDataValue, RecordID: recordID},
+			{ID: 8, DataType: domain.ChangedDataValue, RecordID: recordID},
+		}, nil).Once()
+	delivered := func(changeID int64) any {
+		return mock.MatchedBy(func(d *domain.WebhookDelivery) bool {
+			return d != nil && d.ChangeID == changeID && d.Attempt == 1 && d.StatusCode == http.StatusOK && d.IsSuccessful()
+		})
+	}
+	var nilDelivery *domain.WebhookDelivery
+	s.repo.
+		On("SetWebhookState", mock.Anything, wh.ID, domain.WebhookState{LastChangeID: 5}, delivered(5)).Return(nil).Once().
+		On("SetWebhookState", mock.Anything, wh.ID, domain.WebhookState{LastChangeID: 8}, delivered(8)).Return(nil).Once().
+		// Changes up to the horizon of commits do not match the filter
+		On("SetWebhookState", mock.Anything, wh.ID, domain.WebhookState{LastChangeID: 10}, nilDelivery).Return(nil).Once()
+
+	s.Require().NoError(s.man.Deliver(context.Background()))
+	s.Require().Len(s.partner.requests, 2)
+	for i, changeID := range []int64{5, 8} {
+		r := s.partner.requests[i]
+		s.Equal(wh.ID.String(), r.header.Get(api.WebhookIDHeader))
+		s.Equal(strconv.FormatInt(changeID, 10), r.header.Get(api.WebhookChangeIDHeader))
+		timestamp, err := strconv.ParseInt(r.header.Get(api.WebhookTimestampHeader), 10, 64)
+		s.Require().NoError(err)
+		s.Equal(api.SignWebhookPayload(wh.Secret, timestamp, r.body), r.header.Get(api.WebhookSignatureHeader))
+		var payload struct {
+			WebhookID uuid.UUID                     `json:"webhook_id"`
+			Attempt   int                           `json:"attempt"`
+			Change    handlers.ChangeResponseSchema `json:"change"`
+		}
+		s.Require().NoError(json.Unmarshal(r.body, &payload))
+		s.Equal(wh.ID, payload.WebhookID)
+		s.Equal(1, payload.Attempt)
+		s.Equal(changeID, payload.Change.ID)
+		s.Equal("value", payload.Change.Type)
+		s.Equal(recordID.String(), *payload.Change.RecordID)
+		s.Nil(payload.Change.PropertyID)
+	}
+}
+
+// Change 7 is committed while change 6 is in progress, so the horizon of commits is 5 and
+// the cursor is not moved over 6 until it is committed and delivered.
+func (s *WebhookTestSuite) TestDeliverLateCommit() {
+	wh := s.webhook(domain.WebhookState{LastChangeID: 5})
+	s.repo.On("GetDueWebhooks", mock.Anything).Return([]domain.Webhook{wh}, nil).Once()
+	s.changeLogRepo.
+		On("GetLastChangeID", mock.Anything).Return(int64(5), nil).Once().
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{AfterID: 5, Filter: wh.Filter, Limit: 100}).Return(nil, nil).Once()
+	s.Require().NoError(s.man.Deliver(context.Background()))
+	s.Empty(s.partner.requests)
+
+	s.repo.On("GetDueWebhooks", mock.Anything).Return([]domain.Webhook{wh}, nil).Once()
+	s.changeLogRepo.
+		On("GetLastChangeID", mock.Anything).Return(int64(7), nil).Once().
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{AfterID: 5, Filter: wh.Filter, Limit: 100}).
+		Return([]domain.Change{{ID: 6, DataType: domain.ChangedDataValue}}, nil).Once()
+	s.repo.
+		On("SetWebhookState", mock.Anything, wh.ID, domain.WebhookState{LastChangeID: 6}, mock.Anything).Return(nil).Once().
+		// Change 7 does not match the filter
+		On("SetWebhookState", mock.Anything, wh.ID, domain.WebhookState{LastChangeID: 7}, (*domain.WebhookDelivery)(nil)).Return(nil).Once()
+	s.Require().NoError(s.man.Deliver(context.Background()))
+	s.Len(s.partner.requests, 1)
+}
+
+func (s *WebhookTestSuite) TestRetries() {
+	s.partner.status = http.StatusServiceUnavailable
+	retried := s.webhook(domain.WebhookState{LastChangeID: 3, Failures: 1})
+	failing := s.webhook(domain.WebhookState{LastChangeID: 3, Failures: 2})
+	s.repo.On("GetDueWebhooks", mock.Anything).Return([]domain.Webhook{retried, failing}, nil).Once()
+	s.changeLogRepo.
+		On("GetLastChangeID", mock.Anything).Return(int64(10), nil).Twice().
+		On("GetChangeLog", mock.Anything, domain.ChangeLogRequest{AfterID: 3, Filter: retried.Filter, Limit: 100}).
+		Return([]domain.Change{{ID: 5, DataType: domain.ChangedDataValue}, {ID: 8, DataType: domain.ChangedDataValue}}, nil).Twice()
+
+	states := map[uuid.UUID]domain.WebhookState{}
+	deliveries := map[uuid.UUID]*domain.WebhookDelivery{}
+	var mu sync.Mutex
+	s.repo.On("SetWebhookState", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mu.Lock()
+		defer mu.Unlock()
+		id := args.Get(1).(uuid.UUID)
+		states[id] = args.Get(2).(domain.WebhookState)
+		deliveries[id] = args.Get(3).(*domain.WebhookDelivery)
+	}).Return(nil).Twice()
+
+	start := time.Now()
+	s.Require().NoError(s.man.Deliver(context.Background()))
+	// The failure stops delivering of next changes till the retry
+	s.Len(s.partner.requests, 2)
+
+	state := states[retried.ID]
+	s.Equal(int64(3), state.LastChangeID)
+	s.Equal(2, state.Failures)
+	s.Empty(state.DisabledReason)
+	// The second retry is after the doubled base
+	s.WithinRange(state.NextAttemptAt, start.Add(time.Second*2), time.Now().Add(time.Second*2))
+	s.Equal(int64(5), deliveries[retried.ID].ChangeID)
+	s.Equal(2, deliveries[retried.ID].Attempt)
+	s.Equal(http.StatusServiceUnavailable, deliveries[retried.ID].StatusCode)
+	s.Equal("unexpected status 503 Service Unavailable", deliveries[retried.ID].Error)
+
+	state = states[failing.ID]
+	s.Equal(3, state.Failures)
+	s.True(state.NextAttemptAt.IsZero())
+	s.Equal("3 deliveries of change 5 failed, the last one with: unexpected status 503 Service Unavailable", state.DisabledReason)
+}
+
+func (s *WebhookTestSuite) TestUnreachable() {
+	wh := s.webhook(domain.WebhookState{LastChangeID: 3})
+	s.partner.Close()
+	s.repo.On("GetDueWebhooks", mock.Anything).Return([]domain.Webhook{wh}, nil).Once()
+	s.changeLogRepo.
+		On("GetLastChangeID", mock.Anything).Return(int64(5), nil).Once().
+		On("GetChangeLog", mock.Anything, mock.Anything).Return([]domain.Change{{ID: 5, DataType: domain.ChangedDataValue}}, nil).Once()
+	s.repo.On("SetWebhookState", mock.Anything, wh.ID, mock.MatchedBy(func(state domain.WebhookState) bool {
+		return state.LastChangeID == 3 && state.Failures == 1 && !state.NextAttemptAt.IsZero()
+	}), mock.MatchedBy(func(d *domain.WebhookDelivery) bool {
+		return d.StatusCode == 0 && d.Error != "" && !d.IsSuccessful()
+	})).Return(nil).Once()
+
+	s.NoError(s.man.Deliver(context.Background()))
+}
+
+func (s *WebhookTestSuite) TestAddWebhook() {
+	rtID := uuid.New()
+	s.repo.On("AddWebhook", mock.Anything, domain.Webhook{
+		URL:     "https://partner.example/hooks",
+		Secret:  "0123456789abcdef",
+		Enabled: true,
+		Filter: domain.ChangeFilter{
+			ChangeTypes: []domain.ChangedDataType{domain.ChangedDataRecord, domain.ChangedDataValue},
+			RefTypeIDs:  []uuid.UUID{rtID},
+		},
+	}).Return(rtID, nil).Once()
+
+	type testCase struct {
+		name       string
+		req        handlers.AddWebhookRequestSchema
+		wantStatus int
+		wantField  string
+	}
+	valid := handlers.AddWebhookRequestSchema{
+		URL:         "https://partner.example/hooks",
+		Secret:      "0123456789abcdef",
+		ChangeTypes: []string{"record", "value"},
+		RefTypeIDs:  []string{rtID.String()},
+	}
+	with := func(f func(*handlers.AddWebhookRequestSchema)) handlers.AddWebhookRequestSchema {
+		out := valid
+		f(&out)
+		return out
+	}
+	cases := []testCase{
+		{name: "added", req: valid, wantStatus: http.StatusCreated},
+		{
+			name:       "relative url",
+			req:        with(func(r *handlers.AddWebhookRequestSchema) { r.URL = "/hooks" }),
+			wantStatus: http.StatusBadRequest,
+			wantField:  "url",
+		},
+		{
+			name:       "ftp url",
+			req:        with(func(r *handlers.AddWebhookRequestSchema) { r.URL = "ftp://partner.example/hooks" }),
+			wantStatus: http.StatusBadRequest,
+			wantField:  "url",
+		},
+		{
+			name:       "short secret",
+			req:        with(func(r *handlers.AddWebhookRequestSchema) { r.Secret = "secret" }),
+			wantStatus: http.StatusBadRequest,
+			wantField:  "secret",
+		},
+		{
+			name:       "unknown change type",
+			req:        with(func(r *handlers.AddWebhookRequestSchema) { r.ChangeTypes = []string{"tom"} }),
+			wantStatus: http.StatusBadRequest,
+			wantField:  "change_types",
+		},
+		{
+			name:       "invalid property",
+			req:        with(func(r *handlers.AddWebhookRequestSchema) { r.PropertyIDs = []string{"x"} }),
+			wantStatus: http.StatusBadRequest,
+			wantField:  "property_ids",
+		},
+	}
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			res, err := handlers.AddWebhook(context.Background(), s.man, c.req)
+			s.Equal(c.wantStatus, res.Status)
+			if c.wantField != "" {
+				s.Equal(c.wantField, domain.ErrorField(err))
+				return
+			}
+			s.Require().NoError(err)
+			s.Equal(rtID.String(), res.Payload)
+		})
+	}
+}
+
+func (s *WebhookTestSuite) TestGetWebhook() {
+	wh := s.webhook(domain.WebhookState{LastChangeID: 3, DisabledReason: "failed"})
+	wh.Enabled = false
+	wh.Secret = ""
+	s.repo.
+		On("GetWebhook", mock.Anything, wh.ID).Return(&wh, nil).Once().
+		On("GetWebhook", mock.Anything, mock.Anything).Return(nil, domain.ErrWebhookNotFound).Once()
+
+	res, err := handlers.GetWebhook(context.Background(), s.man, wh.ID.String())
+	s.Require().NoError(err)
+	var actual map[string]any
+	s.Require().NoError(json.Unmarshal(res.Payload, &actual))
+	s.NotContains(actual, "secret")
+	s.Equal([]any{"value"}, actual["change_types"])
+	s.Equal([]any{}, actual["property_ids"])
+	s.Equal("failed", actual["disabled_reason"])
+	s.Nil(actual["next_attempt_at"])
+
+	res, err = handlers.GetWebhookDeliveries(context.Background(), s.man, uuid.NewString(), "")
+	s.ErrorIs(err, domain.ErrWebhookNotFound)
+	s.Equal(http.StatusNotFound, res.Status)
+}